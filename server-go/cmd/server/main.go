@@ -14,6 +14,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/photosync/server/internal/blobstore"
 	"github.com/photosync/server/internal/config"
 	"github.com/photosync/server/internal/handlers"
 	custommw "github.com/photosync/server/internal/middleware"
@@ -89,7 +90,6 @@ func main() {
 	// Initialize database
 	var db *sql.DB
 	var photoRepo repository.PhotoRepo
-	var photoRepoPostgres *repository.PhotoRepositoryPostgres
 
 	if cfg.UsePostgres() {
 		log.Println("Using PostgreSQL database")
@@ -97,8 +97,7 @@ func main() {
 		if err != nil {
 			log.Fatalf("Failed to initialize PostgreSQL database: %v", err)
 		}
-		photoRepoPostgres = repository.NewPhotoRepositoryPostgres(db)
-		photoRepo = photoRepoPostgres
+		photoRepo = repository.NewPhotoRepositoryPostgres(db)
 	} else {
 		log.Println("Using SQLite database")
 		db, err = repository.NewSQLiteDB(cfg.DatabasePath)
@@ -122,10 +121,12 @@ func main() {
 	configOverrideRepo := repository.NewConfigOverrideRepository(db)
 	smtpConfigRepo := repository.NewSMTPConfigRepository(db)
 	resetTokenRepo := repository.NewPasswordResetTokenRepository(db)
+	appPasswordRepo := repository.NewAppPasswordRepository(db)
 
 	// Collection repositories
 	collectionRepo := repository.NewCollectionRepository(db)
 	collectionPhotoRepo := repository.NewCollectionPhotoRepository(db)
+	collectionLinkRepo := repository.NewCollectionLinkRepository(db)
 	collectionShareRepo := repository.NewCollectionShareRepository(db)
 
 	// Theme and user preferences repositories
@@ -134,15 +135,33 @@ func main() {
 
 	// Sync state repository
 	deviceSyncStateRepo := repository.NewDeviceSyncStateRepository(db)
+	photoChangeRepo := repository.NewPhotoChangeRepository(db)
+	restorePlanRepo := repository.NewRestorePlanRepository(db)
 
 	// File integrity repositories
 	orphanFileRepo := repository.NewOrphanFileRepository(db)
 	fileConflictRepo := repository.NewFileConflictRepository(db)
 
+	// Blob stores for originals and thumbnails. They share one local store
+	// unless configured apart, e.g. originals on S3 and thumbnails on the NAS.
+	originalsStore, err := blobstore.New(cfg.PhotoStorage.OriginalsStore())
+	if err != nil {
+		log.Fatalf("Failed to initialize originals storage: %v", err)
+	}
+	thumbnailStore := originalsStore
+	blobStores := []blobstore.BlobStore{originalsStore}
+	if cfg.PhotoStorage.ThumbnailsStore() != cfg.PhotoStorage.OriginalsStore() {
+		thumbnailStore, err = blobstore.New(cfg.PhotoStorage.ThumbnailsStore())
+		if err != nil {
+			log.Fatalf("Failed to initialize thumbnail storage: %v", err)
+		}
+		blobStores = append(blobStores, thumbnailStore)
+	}
+
 	// Initialize services
 	hashService := services.NewHashService()
 	storageService, err := services.NewPhotoStorageService(
-		cfg.PhotoStorage.BasePath,
+		originalsStore,
 		cfg.PhotoStorage.AllowedExtensions,
 		cfg.PhotoStorage.MaxFileSizeMB,
 	)
@@ -152,11 +171,58 @@ func main() {
 
 	// EXIF, thumbnail, and metadata services
 	exifService := services.NewEXIFService()
-	thumbnailService := services.NewThumbnailService(cfg.PhotoStorage.BasePath)
-	metadataService := services.NewMetadataService(cfg.PhotoStorage.BasePath)
+	thumbnailService := services.NewThumbnailService(originalsStore, thumbnailStore)
+	metadataService := services.NewMetadataService(originalsStore)
+
+	// Video service (optional - only if ffmpeg is installed)
+	var videoService *services.VideoService
+	if services.IsFFmpegAvailable() {
+		videoService = services.NewVideoService(originalsStore)
+		thumbnailService.SetVideoService(videoService)
+		log.Println("ffmpeg found, video thumbnails enabled")
+	} else {
+		log.Println("ffmpeg not found, videos will be stored without thumbnails")
+	}
+
+	// Galleries that remove metadata need exiftool for anything but JPEG, PNG, WebP and HEIC
+	if !services.IsExiftoolAvailable() {
+		log.Println("Warning: exiftool not found; galleries that remove photo metadata will not serve videos or GIF, BMP and TIFF originals")
+	}
+
+	// Shared upload pipeline and resumable upload sessions
+	uploadService := services.NewUploadService(
+		photoRepo, storageService, hashService, exifService, thumbnailService, metadataService, videoService,
+	)
+	uploadSessionService, err := services.NewUploadSessionService(
+		cfg.PhotoStorage.BasePath, cfg.PhotoStorage.UploadSessionTTLHours,
+	)
+	if err != nil {
+		log.Fatalf("Failed to initialize upload session service: %v", err)
+	}
+	uploadBatchService, err := services.NewUploadBatchService(
+		cfg.PhotoStorage.BasePath, cfg.PhotoStorage.UploadSessionTTLHours, cfg.PhotoStorage.BatchUploadWorkers,
+		photoRepo, hashService, storageService, uploadService,
+	)
+	if err != nil {
+		log.Fatalf("Failed to initialize upload batch service: %v", err)
+	}
+
+	// Trash (soft delete) with timed purge
+	trashService := services.NewTrashService(
+		photoRepo, storageService, thumbnailService, cfg.PhotoStorage.TrashRetentionDays,
+	)
+
+	// Near-duplicate detection by perceptual hash
+	duplicateGroupRepo := repository.NewDuplicateGroupRepository(db)
+	duplicateService := services.NewDuplicateService(
+		photoRepo, duplicateGroupRepo, userRepo, thumbnailService, trashService,
+	)
 
 	// Maintenance service for background tasks
-	maintenanceService := services.NewMaintenanceService(photoRepo, thumbnailService, cfg.PhotoStorage.BasePath)
+	maintenanceService := services.NewMaintenanceService(
+		photoRepo, thumbnailService, trashService, duplicateService,
+	)
+	maintenanceService.SetChangeLog(photoChangeRepo, cfg.PhotoStorage.ChangeLogRetentionDays)
 	maintenanceService.Start()
 
 	// File scanner service for orphan/conflict detection
@@ -164,7 +230,7 @@ func main() {
 	if cfg.FileScanner.Enabled {
 		fileScannerService = services.NewFileScannerService(
 			photoRepo, orphanFileRepo, fileConflictRepo,
-			metadataService, hashService, cfg.PhotoStorage.BasePath,
+			metadataService, hashService, originalsStore,
 			cfg.FileScanner.IntervalHours,
 		)
 		if cfg.FileScanner.AutoStart {
@@ -264,17 +330,28 @@ func main() {
 		fileScannerService.SetWebSocketHub(wsHub)
 	}
 
+	// Per-user storage quotas, enforced on upload and orphan claims
+	quotaService := services.NewQuotaService(userRepo, deviceRepo, fcmService)
+	quotaService.SetWebSocketHub(wsHub)
+	uploadService.SetQuotaService(quotaService)
+
+	// Real-time library events for devices and browsers, with resume on reconnect
+	libraryEventService := services.NewLibraryEventService(wsHub)
+	uploadService.SetLibraryEvents(libraryEventService)
+	trashService.SetLibraryEvents(libraryEventService)
+	maintenanceService.SetLibraryEvents(libraryEventService)
+
 	// Delete service
 	deleteTimeout := 60 // 60 seconds for delete approval
 	deleteService := services.NewDeleteService(
-		userRepo, deviceRepo, deleteRequestRepo, photoRepo,
+		userRepo, deviceRepo, deleteRequestRepo, trashService,
 		fcmService, deleteTimeout,
 	)
 
 	// Admin service
 	adminService := services.NewAdminService(
 		userRepo, deviceRepo, sessionRepo, photoRepo, setupConfigRepo,
-		cfg.PhotoStorage.BasePath,
+		cfg.PhotoStorage.BasePath, blobStores,
 		Version, BuildDate, ContainerBuildDate,
 	)
 
@@ -293,6 +370,55 @@ func main() {
 		collectionRepo, collectionPhotoRepo, collectionShareRepo,
 		photoRepo, userRepo, themeService, userPrefsRepo,
 	)
+	collectionService.SetLibraryEvents(libraryEventService)
+	collectionService.SetCollectionLinks(collectionLinkRepo)
+	collectionLinkService := services.NewCollectionLinkService(collectionLinkRepo, collectionRepo)
+	galleryDownloadService := services.NewGalleryDownloadService(collectionPhotoRepo, storageService, thumbnailService)
+	galleryDownloadService.SetRateLimit(cfg.Security.GalleryDownloadsPerHour)
+
+	// Full library export (originals, sidecars and manifest)
+	exportService := services.NewExportService(
+		photoRepo, collectionRepo, collectionPhotoRepo, deviceRepo, storageService,
+	)
+
+	// Download manifests for restoring a library onto a device
+	restoreService := services.NewRestoreService(restorePlanRepo, photoRepo, deviceRepo, deviceSyncStateRepo)
+
+	// Background imports of Google Takeout and Apple Photos exports
+	importService, err := services.NewImportService(
+		cfg.PhotoStorage.BasePath, cfg.PhotoStorage.ImportRoot,
+		storageService, uploadService, collectionService,
+	)
+	if err != nil {
+		log.Fatalf("Failed to initialize import service: %v", err)
+	}
+	importService.SetWebSocketHub(wsHub)
+	importService.SetQuotaService(quotaService)
+	importService.SetMaxArchiveSize(cfg.PhotoStorage.MaxImportSizeMB * 1024 * 1024)
+	importService.Start()
+
+	// Watched inbox folders for scanners and card readers
+	var inboxService *services.InboxService
+	if cfg.Inbox.Enabled() {
+		var inboxes []services.InboxDir
+		if cfg.Inbox.Path != "" {
+			if cfg.Inbox.DefaultOwner == "" {
+				log.Printf("Warning: inbox %s has no defaultOwner and is not watched", cfg.Inbox.Path)
+			} else {
+				inboxes = append(inboxes, services.InboxDir{Path: cfg.Inbox.Path, Owner: cfg.Inbox.DefaultOwner})
+			}
+		}
+		for email, path := range cfg.Inbox.UserPaths {
+			inboxes = append(inboxes, services.InboxDir{Path: path, Owner: email})
+		}
+		if len(inboxes) > 0 {
+			inboxService = services.NewInboxService(
+				inboxes, cfg.Inbox.PollSeconds, cfg.Inbox.SettleSeconds,
+				userRepo, storageService, uploadService,
+			)
+			inboxService.Start()
+		}
+	}
 
 	// Determine web directory for static files and templates
 	webDir := filepath.Join(getExecutableDir(), "web")
@@ -302,7 +428,7 @@ func main() {
 	}
 
 	// Initialize handlers
-	photoHandler := handlers.NewPhotoHandler(photoRepo, storageService, hashService, exifService, thumbnailService, metadataService)
+	photoHandler := handlers.NewPhotoHandler(photoRepo, storageService, trashService, uploadService, uploadSessionService, uploadBatchService)
 	healthHandler := handlers.NewHealthHandler(setupConfigRepo)
 	setupHandler := handlers.NewSetupHandler(setupService, configService, smtpService)
 	deviceHandler := handlers.NewDeviceHandler(deviceRepo)
@@ -311,41 +437,77 @@ func main() {
 	adminHandler := handlers.NewAdminHandler(adminService)
 	configHandler := handlers.NewConfigHandler(configService, smtpService)
 
+	// Signed, expiring media URLs for <img> tags and native image loaders
+	mediaURLService, err := services.NewMediaURLService(cfg.Security.MediaURLSecret, cfg.Security.MediaURLTTLMinutes)
+	if err != nil {
+		log.Fatalf("Failed to initialize media URL signing: %v", err)
+	}
+	if cfg.Security.MediaURLSecret == "" {
+		log.Println("Warning: mediaUrlSecret is not set; media URLs will stop working when the server restarts")
+	}
+	mediaHandler := handlers.NewMediaHandler(photoRepo, storageService, thumbnailService, mediaURLService)
+
 	// Mobile authentication handlers
 	mobileAuthHandler := handlers.NewMobileAuthHandler(mobileAuthService, deviceRepo, userRepo)
 	passwordResetHandler := handlers.NewPasswordResetHandler(passwordResetService, authService)
-	// Web gallery handler requires PostgreSQL for location features
-	var webGalleryHandler *handlers.WebGalleryHandler
-	if photoRepoPostgres != nil {
-		webGalleryHandler = handlers.NewWebGalleryHandler(photoRepoPostgres, cfg.PhotoStorage.BasePath)
-	}
+	// Web gallery handler
+	webGalleryHandler := handlers.NewWebGalleryHandler(photoRepo, thumbnailService, trashService, storageService)
+	webGalleryHandler.SetMediaURLs(mediaURLService)
+
+	// Trash handler (API key and session)
+	trashHandler := handlers.NewTrashHandler(trashService)
+
+	// Search handler (API key and session)
+	searchHandler := handlers.NewSearchHandler(photoRepo)
+	searchHandler.SetMediaURLs(mediaURLService)
+
+	// Duplicate review handler (API key and session)
+	duplicateHandler := handlers.NewDuplicateHandler(duplicateService)
 
 	// Collection handler
 	collectionHandler := handlers.NewCollectionHandler(collectionService)
+	collectionHandler.SetMediaURLs(mediaURLService)
+	collectionLinkHandler := handlers.NewCollectionLinkHandler(collectionLinkService)
 
 	// Theme handler
 	themeHandler := handlers.NewThemeHandler(themeService)
 
 	// User handler
 	userHandler := handlers.NewUserHandler(userPrefsRepo)
+	exportHandler := handlers.NewExportHandler(exportService, userRepo)
+	importHandler := handlers.NewImportHandler(importService, userRepo)
+	appPasswordHandler := handlers.NewAppPasswordHandler(appPasswordRepo)
+
+	// WebDAV handler (read-only library mount)
+	webDAVHandler := handlers.NewWebDAVHandler(
+		userRepo, appPasswordRepo, photoRepo, collectionRepo, collectionPhotoRepo,
+		deviceRepo, storageService, cfg.Security.APIKeyHeader,
+	)
 
 	// Invite handler
 	inviteHandler := handlers.NewInviteHandler(inviteTokenRepo, userRepo, smtpService, serverURL)
 
 	// Sync handler
-	syncHandler := handlers.NewSyncHandler(photoRepo, deviceRepo, deviceSyncStateRepo, storageService)
+	syncHandler := handlers.NewSyncHandler(photoRepo, deviceRepo, deviceSyncStateRepo, photoChangeRepo, storageService, thumbnailService)
+	syncHandler.SetMediaURLs(mediaURLService)
+	restoreHandler := handlers.NewRestoreHandler(restoreService)
 
 	// Public gallery handler
 	publicGalleryHandler := handlers.NewPublicGalleryHandler(
 		collectionService, collectionRepo, collectionPhotoRepo,
-		photoRepo, cfg.PhotoStorage.BasePath, webDir,
+		photoRepo, thumbnailService, storageService, webDir,
 	)
+	publicGalleryHandler.SetCollectionLinks(collectionLinkService)
+	publicGalleryHandler.SetMediaURLs(mediaURLService)
+	publicGalleryHandler.SetDownloads(galleryDownloadService)
 
 	// File integrity handlers
 	orphanHandler := handlers.NewOrphanHandler(
-		orphanFileRepo, photoRepo, deviceRepo, cfg.PhotoStorage.BasePath,
+		orphanFileRepo, photoRepo, deviceRepo,
 		storageService, hashService, exifService, thumbnailService, metadataService,
 	)
+	orphanHandler.SetQuotaService(quotaService)
+	orphanHandler.SetLibraryEvents(libraryEventService)
 	conflictHandler := handlers.NewConflictHandler(fileConflictRepo, photoRepo, metadataService)
 	var scannerHandler *handlers.ScannerHandler
 	if fileScannerService != nil {
@@ -354,18 +516,28 @@ func main() {
 
 	// WebSocket handler
 	wsHandler := handlers.NewWebSocketHandler(wsHub, authService)
+	wsHandler.SetLibraryEvents(libraryEventService)
 
 	// Setup router - use two routers to avoid Logger on WebSocket routes
 	// WebSocket needs raw http.Hijacker which Logger middleware breaks
 
+	// chi only routes methods it knows; WebDAV clients send PROPFIND
+	chi.RegisterMethod("PROPFIND")
+
+	// Forwarded client addresses are only believed from configured proxies
+	trustedProxies, err := custommw.ParseTrustedProxies(cfg.Security.TrustedProxies)
+	if err != nil {
+		log.Fatalf("Invalid security.trustedProxies: %v", err)
+	}
+
 	// Main router with minimal middleware
 	r := chi.NewRouter()
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(custommw.RealIP(trustedProxies))
 
 	// WebSocket routes (no Logger)
-	r.Get("/ws", wsHandler.HandleConnection)
+	r.With(custommw.OptionalUserAuth(sessionRepo, userRepo, cfg.Security.APIKeyHeader)).Get("/ws", wsHandler.HandleConnection)
 	r.Get("/ws/auth", wsHandler.HandleAuthConnection)
 
 	// App router with Logger and other middleware
@@ -456,17 +628,57 @@ func main() {
 
 		// Current user info (mobile)
 		r.Get("/api/users/me", userHandler.GetCurrentUser)
+		r.Get("/api/users/me/export", exportHandler.ExportMyLibrary)
+		r.Route("/api/users/me/app-passwords", func(r chi.Router) {
+			r.Get("/", appPasswordHandler.ListAppPasswords)
+			r.Post("/", appPasswordHandler.CreateAppPassword)
+			r.Delete("/{id}", appPasswordHandler.DeleteAppPassword)
+		})
 
 		// Photo upload API (mobile)
 		r.Route("/api/photos", func(r chi.Router) {
 			r.Post("/upload", photoHandler.Upload)
+			r.Route("/uploads", func(r chi.Router) {
+				r.Post("/", photoHandler.CreateUploadSession)
+				r.Get("/{id}", photoHandler.GetUploadSession)
+				r.Head("/{id}", photoHandler.GetUploadSession)
+				r.Patch("/{id}", photoHandler.UploadChunk)
+				r.Post("/{id}/complete", photoHandler.CompleteUploadSession)
+				r.Delete("/{id}", photoHandler.CancelUploadSession)
+			})
+			r.Route("/batches", func(r chi.Router) {
+				r.Post("/", photoHandler.CreateUploadBatch)
+				r.Get("/{token}", photoHandler.GetUploadBatch)
+				r.Post("/{token}/files", photoHandler.UploadBatchFiles)
+				r.Delete("/{token}", photoHandler.CancelUploadBatch)
+			})
 			r.Post("/check", photoHandler.CheckHashes)
+			r.Get("/search", searchHandler.Search)
+			r.Route("/trash", func(r chi.Router) {
+				r.Get("/", trashHandler.ListTrash)
+				r.Post("/{id}/restore", trashHandler.RestorePhoto)
+				r.Delete("/", trashHandler.EmptyTrash)
+			})
+			r.Route("/duplicates", func(r chi.Router) {
+				r.Get("/", duplicateHandler.ListGroups)
+				r.Post("/{id}/resolve", duplicateHandler.ResolveGroup)
+				r.Post("/{id}/dismiss", duplicateHandler.DismissGroup)
+			})
 			r.Get("/", photoHandler.List)
 			r.Get("/{id}", photoHandler.GetByID)
 			r.Get("/{id}/thumbnail", syncHandler.GetThumbnail)
 			r.Delete("/{id}", photoHandler.Delete)
 		})
 
+		// Library imports (mobile and scripts)
+		r.Route("/api/imports", func(r chi.Router) {
+			r.Post("/", importHandler.CreateImport)
+			r.Get("/", importHandler.ListImports)
+			r.Get("/{id}", importHandler.GetImport)
+			r.Delete("/{id}", importHandler.CancelImport)
+			r.Post("/{id}/resume", importHandler.ResumeImport)
+		})
+
 		// Device registration (mobile)
 		r.Route("/api/devices", func(r chi.Router) {
 			r.Post("/register", deviceHandler.RegisterDevice)
@@ -478,10 +690,21 @@ func main() {
 		r.Route("/api/sync", func(r chi.Router) {
 			r.Get("/status", syncHandler.GetSyncStatus)
 			r.Post("/photos", syncHandler.SyncPhotos)
+			r.Get("/changes", syncHandler.GetChanges)
 			r.Get("/legacy-photos", syncHandler.GetLegacyPhotos)
 			r.Post("/claim-legacy", syncHandler.ClaimLegacy)
 			r.Get("/thumbnail/{id}", syncHandler.GetThumbnail)
 			r.Get("/download/{hash}", syncHandler.DownloadPhotoByHash)
+
+			// Restoring the library onto a device
+			r.Route("/restore", func(r chi.Router) {
+				r.Post("/", restoreHandler.CreatePlan)
+				r.Get("/", restoreHandler.ListPlans)
+				r.Get("/{id}", restoreHandler.GetPlan)
+				r.Get("/{id}/items", restoreHandler.GetManifest)
+				r.Post("/{id}/confirm", restoreHandler.ConfirmItems)
+				r.Delete("/{id}", restoreHandler.CancelPlan)
+			})
 		})
 
 		// Photo download (mobile)
@@ -499,20 +722,39 @@ func main() {
 		r.Get("/api/users/me", userHandler.GetCurrentUser)
 		r.Get("/api/users/me/preferences", userHandler.GetPreferences)
 		r.Put("/api/users/me/preferences", userHandler.UpdatePreferences)
+		r.Get("/api/users/me/export", exportHandler.ExportMyLibrary)
+		r.Route("/api/users/me/app-passwords", func(r chi.Router) {
+			r.Get("/", appPasswordHandler.ListAppPasswords)
+			r.Post("/", appPasswordHandler.CreateAppPassword)
+			r.Delete("/{id}", appPasswordHandler.DeleteAppPassword)
+		})
 
 		// Delete request routes
 		r.Post("/api/web/delete/initiate", webDeleteHandler.InitiateDelete)
 		r.Get("/api/web/delete/status/{id}", webDeleteHandler.CheckStatus)
 
-		if webGalleryHandler != nil {
-			r.Route("/api/web/photos", func(r chi.Router) {
-				r.Get("/", webGalleryHandler.ListPhotos)
-				r.Get("/locations", webGalleryHandler.ListPhotosWithLocation)
-				r.Get("/{id}/image", webGalleryHandler.ServeImage)
-				r.Get("/{id}/thumbnail", webGalleryHandler.ServeThumbnail)
-				r.Delete("/{id}", webGalleryHandler.DeletePhoto)
-			})
-		}
+		r.Route("/api/web/photos", func(r chi.Router) {
+			r.Get("/", webGalleryHandler.ListPhotos)
+			r.Get("/search", searchHandler.Search)
+			r.Get("/locations", webGalleryHandler.ListPhotosWithLocation)
+			r.Get("/{id}/image", webGalleryHandler.ServeImage)
+			r.Get("/{id}/thumbnail", webGalleryHandler.ServeThumbnail)
+			r.Delete("/{id}", webGalleryHandler.DeletePhoto)
+		})
+
+		// Trash routes
+		r.Route("/api/web/trash", func(r chi.Router) {
+			r.Get("/", trashHandler.ListTrash)
+			r.Post("/{id}/restore", trashHandler.RestorePhoto)
+			r.Delete("/", trashHandler.EmptyTrash)
+		})
+
+		// Duplicate review routes
+		r.Route("/api/web/duplicates", func(r chi.Router) {
+			r.Get("/", duplicateHandler.ListGroups)
+			r.Post("/{id}/resolve", duplicateHandler.ResolveGroup)
+			r.Post("/{id}/dismiss", duplicateHandler.DismissGroup)
+		})
 
 		// Collection management routes
 		r.Route("/api/web/collections", func(r chi.Router) {
@@ -527,7 +769,21 @@ func main() {
 			r.Delete("/{id}/photos", collectionHandler.RemovePhotos)
 			r.Put("/{id}/photos/reorder", collectionHandler.ReorderPhotos)
 			r.Post("/{id}/shares", collectionHandler.ShareWithUsers)
+			r.Put("/{id}/shares/{userId}", collectionHandler.UpdateShare)
 			r.Delete("/{id}/shares/{userId}", collectionHandler.RemoveShare)
+			r.Get("/{id}/links", collectionLinkHandler.ListLinks)
+			r.Post("/{id}/links", collectionLinkHandler.CreateLink)
+			r.Delete("/{id}/links/{linkId}", collectionLinkHandler.RevokeLink)
+			r.Get("/{id}/links/{linkId}/access", collectionLinkHandler.GetAccessLog)
+		})
+
+		// Library imports
+		r.Route("/api/web/imports", func(r chi.Router) {
+			r.Post("/", importHandler.CreateImport)
+			r.Get("/", importHandler.ListImports)
+			r.Get("/{id}", importHandler.GetImport)
+			r.Delete("/{id}", importHandler.CancelImport)
+			r.Post("/{id}/resume", importHandler.ResumeImport)
 		})
 
 		// User orphan file routes (view/ignore/claim their own orphans)
@@ -552,6 +808,8 @@ func main() {
 			r.Post("/users/{id}/reset-api-key", adminHandler.ResetAPIKey)
 			r.Post("/users/{id}/password", adminHandler.SetUserPassword)
 			r.Post("/users/{id}/invite", inviteHandler.HandleGenerateInvite)
+			r.Get("/users/{id}/export", exportHandler.AdminExportUserLibrary)
+			r.Post("/imports", importHandler.AdminImportDirectory)
 
 			// User's devices
 			r.Get("/users/{id}/devices", adminHandler.GetUserDevices)
@@ -610,6 +868,14 @@ func main() {
 				json.NewEncoder(w).Encode(map[string]string{"status": "triggered"})
 			})
 
+			// Watched inbox status (only if configured)
+			if inboxService != nil {
+				r.Get("/inbox/status", func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "application/json")
+					json.NewEncoder(w).Encode(inboxService.GetStatus())
+				})
+			}
+
 			// Thumbnail stats
 			r.Get("/thumbnail-stats", func(w http.ResponseWriter, r *http.Request) {
 				ctx := r.Context()
@@ -700,12 +966,12 @@ func main() {
 
 				for _, photo := range photos {
 					// Skip unsupported formats
-					if !services.IsSupportedFormat(photo.StoredPath) || services.IsHEIC(photo.StoredPath) {
+					if !thumbnailService.CanGenerateThumbnails(photo.StoredPath) {
 						skipped++
 						continue
 					}
 
-					result, err := thumbnailService.RegenerateThumbnailsFromFile(photo.ID, photo.StoredPath)
+					result, err := thumbnailService.RegenerateThumbnailsFromFile(photo.ID, photo.StoredPath, photo.Orientation)
 					if err != nil {
 						log.Printf("Failed to regenerate thumbnails for %s: %v", photo.ID, err)
 						failed++
@@ -757,6 +1023,9 @@ func main() {
 	// Public gallery routes (no auth required)
 	appRouter.Get("/gallery/{slug}", publicGalleryHandler.ViewGalleryBySlug)
 	appRouter.Get("/gallery/s/{token}", publicGalleryHandler.ViewGalleryByToken)
+	appRouter.Post("/gallery/s/{token}", publicGalleryHandler.UnlockGalleryByToken)
+	appRouter.Get("/gallery/{slug}/download", publicGalleryHandler.DownloadGalleryBySlug)
+	appRouter.Get("/gallery/s/{token}/download", publicGalleryHandler.DownloadGalleryByToken)
 	appRouter.Get("/gallery/photos/{photoId}/image", publicGalleryHandler.ServeGalleryImage)
 	appRouter.Get("/gallery/photos/{photoId}/thumbnail", publicGalleryHandler.ServeGalleryThumbnail)
 
@@ -765,6 +1034,14 @@ func main() {
 		http.ServeFile(w, req, filepath.Join(webDir, "collections.html"))
 	})
 
+	// Signed media URLs (the signature is the authorization)
+	appRouter.Get(services.MediaURLPrefix+"{id}/{variant}", mediaHandler.ServeMedia)
+	appRouter.Head(services.MediaURLPrefix+"{id}/{variant}", mediaHandler.ServeMedia)
+
+	// Read-only WebDAV mount of each user's library (Basic auth with an API key or app password)
+	appRouter.Handle(handlers.WebDAVPrefix, webDAVHandler)
+	appRouter.Handle(handlers.WebDAVPrefix+"/*", webDAVHandler)
+
 	// Web UI pages
 	appRouter.Get("/login.html", func(w http.ResponseWriter, req *http.Request) {
 		http.ServeFile(w, req, filepath.Join(webDir, "login.html"))
@@ -806,6 +1083,23 @@ func main() {
 			} else if expired > 0 {
 				log.Printf("Expired %d old recovery tokens", expired)
 			}
+
+			// Remove abandoned resumable uploads
+			if _, err := uploadSessionService.CleanupExpired(); err != nil {
+				log.Printf("ERROR: Failed to clean up expired upload sessions: %v", err)
+			}
+			if _, err := uploadBatchService.CleanupExpired(); err != nil {
+				log.Printf("ERROR: Failed to clean up expired upload batches: %v", err)
+			}
+			if _, err := importService.CleanupExpired(); err != nil {
+				log.Printf("ERROR: Failed to clean up finished imports: %v", err)
+			}
+			if _, err := restoreService.CleanupFinished(ctx); err != nil {
+				log.Printf("ERROR: Failed to clean up finished restore plans: %v", err)
+			}
+			if _, err := collectionLinkService.CleanupAccessLog(ctx); err != nil {
+				log.Printf("ERROR: Failed to clean up share link access log: %v", err)
+			}
 		}
 	}()
 
@@ -813,6 +1107,9 @@ func main() {
 	go func() {
 		log.Printf("PhotoSync Server starting on %s", cfg.ServerAddress)
 		log.Printf("Photo storage path: %s", cfg.PhotoStorage.BasePath)
+		if originals := cfg.PhotoStorage.OriginalsStore(); originals.Driver == blobstore.DriverS3 {
+			log.Printf("Originals stored in S3 bucket %s at %s", originals.S3.Bucket, originals.S3.Endpoint)
+		}
 		log.Printf("Max file size: %dMB", cfg.PhotoStorage.MaxFileSizeMB)
 		log.Printf("Web UI available at http://localhost%s", cfg.ServerAddress)
 
@@ -0,0 +1,183 @@
+// Package blobstore abstracts where photo originals and thumbnails live.
+// Keys are slash-separated paths relative to the store root, such as
+// "2026/01/IMG_0001.jpg" or "2026/01/.thumbs/<id>_small.jpg", so the same
+// stored_path values work against the local filesystem and object storage.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Driver names accepted by New
+const (
+	DriverLocal = "local"
+	DriverS3    = "s3"
+)
+
+var (
+	// ErrNotExist is returned when a key has no object
+	ErrNotExist = errors.New("blob does not exist")
+	// ErrInvalidKey is returned for empty, absolute or traversing keys
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// BlobStore is a flat key/value store for file contents
+type BlobStore interface {
+	// Put writes the object at key, replacing any existing object
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+
+	// Get opens the object for reading. The reader supports seeking so it can
+	// be served with HTTP range requests.
+	Get(ctx context.Context, key string) (io.ReadSeekCloser, *ObjectInfo, error)
+
+	// Stat returns the object's metadata, or ErrNotExist
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+
+	// Delete removes the object. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+
+	// List calls fn for every object whose key starts with prefix
+	List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
+
+	// Move renames an object, replacing any object at dstKey
+	Move(ctx context.Context, srcKey, dstKey string) error
+}
+
+// LocalPather is implemented by stores whose objects are plain files.
+// Tools that need a real path (ffmpeg, exiftool, http.ServeFile) use it to
+// avoid copying.
+type LocalPather interface {
+	LocalPath(key string) (string, error)
+}
+
+// Config selects and configures a BlobStore driver
+type Config struct {
+	Driver string `json:"driver"` // "local" (default) or "s3"
+
+	// Local driver
+	BasePath string `json:"basePath,omitempty"`
+
+	// S3 driver
+	S3 S3Config `json:"s3"`
+}
+
+// New creates the store described by cfg
+func New(cfg Config) (BlobStore, error) {
+	switch strings.ToLower(cfg.Driver) {
+	case "", DriverLocal:
+		return NewLocalStore(cfg.BasePath)
+	case DriverS3:
+		return NewS3Store(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown blob store driver %q", cfg.Driver)
+	}
+}
+
+// Exists reports whether an object exists at key
+func Exists(ctx context.Context, store BlobStore, key string) bool {
+	_, err := store.Stat(ctx, key)
+	return err == nil
+}
+
+// IsLocal reports whether the store keeps objects as local files
+func IsLocal(store BlobStore) bool {
+	_, ok := store.(LocalPather)
+	return ok
+}
+
+// WithLocalFile runs fn with a filesystem path holding the object's contents.
+// Local stores pass their own path; other stores download to a temp file. When
+// writeBack is true, changes fn makes to the file are uploaded afterwards.
+func WithLocalFile(ctx context.Context, store BlobStore, key string, writeBack bool, fn func(path string) error) error {
+	if lp, ok := store.(LocalPather); ok {
+		p, err := lp.LocalPath(key)
+		if err != nil {
+			return err
+		}
+		return fn(p)
+	}
+
+	src, _, err := store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// Keep the extension so tools that sniff by name still work
+	tmp, err := os.CreateTemp("", "blob-*"+path.Ext(key))
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := fn(tmpPath); err != nil {
+		return err
+	}
+	if !writeBack {
+		return nil
+	}
+
+	f, err := os.Open(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return store.Put(ctx, key, f, info.Size())
+}
+
+// ReadAll returns the full contents of an object
+func ReadAll(ctx context.Context, store BlobStore, key string) ([]byte, error) {
+	r, _, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// CleanKey normalizes a key to forward slashes and rejects keys that would
+// escape the store root
+func CleanKey(key string) (string, error) {
+	key = strings.ReplaceAll(strings.TrimSpace(key), "\\", "/")
+	if key == "" || strings.HasPrefix(key, "/") {
+		return "", ErrInvalidKey
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == ".." {
+			return "", ErrInvalidKey
+		}
+	}
+
+	cleaned := path.Clean(key)
+	if cleaned == "." {
+		return "", ErrInvalidKey
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,232 @@
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore keeps objects as files under a base directory
+type LocalStore struct {
+	basePath string
+}
+
+// NewLocalStore creates a LocalStore rooted at basePath, creating the directory if needed
+func NewLocalStore(basePath string) (*LocalStore, error) {
+	if strings.TrimSpace(basePath) == "" {
+		return nil, fmt.Errorf("base path cannot be empty")
+	}
+
+	absPath, err := filepath.Abs(basePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(absPath, 0755); err != nil {
+		return nil, err
+	}
+
+	return &LocalStore{basePath: absPath}, nil
+}
+
+// BasePath returns the absolute root directory
+func (s *LocalStore) BasePath() string {
+	return s.basePath
+}
+
+// LocalPath returns the absolute file path for a key
+func (s *LocalStore) LocalPath(key string) (string, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	fullPath := filepath.Join(s.basePath, filepath.FromSlash(cleaned))
+	if fullPath != s.basePath && !strings.HasPrefix(fullPath, s.basePath+string(os.PathSeparator)) {
+		return "", ErrInvalidKey
+	}
+	return fullPath, nil
+}
+
+// Put writes the object through a temp file so readers never see a partial write
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	fullPath, err := s.LocalPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), ".put-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, fullPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// Get opens the file for reading
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadSeekCloser, *ObjectInfo, error) {
+	fullPath, err := s.LocalPath(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := os.Open(fullPath)
+	if os.IsNotExist(err) {
+		return nil, nil, ErrNotExist
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, nil, ErrNotExist
+	}
+
+	return f, s.objectInfo(key, info), nil
+}
+
+// Stat returns the file's size and modification time
+func (s *LocalStore) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	fullPath, err := s.LocalPath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(fullPath)
+	if os.IsNotExist(err) || (err == nil && info.IsDir()) {
+		return nil, ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.objectInfo(key, info), nil
+}
+
+// Delete removes the file
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	fullPath, err := s.LocalPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// List walks the directory tree and reports every file whose key starts with prefix
+func (s *LocalStore) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	// Only walk the deepest directory the prefix pins down
+	root := s.basePath
+	if dir := prefix[:strings.LastIndex(prefix, "/")+1]; dir != "" {
+		fullDir, err := s.LocalPath(dir)
+		if err != nil {
+			return err
+		}
+		root = fullDir
+	}
+
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.basePath, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		return fn(*s.objectInfo(key, info))
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Move renames the file, copying across devices when a rename is not possible
+func (s *LocalStore) Move(ctx context.Context, srcKey, dstKey string) error {
+	srcPath, err := s.LocalPath(srcKey)
+	if err != nil {
+		return err
+	}
+	dstPath, err := s.LocalPath(dstKey)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(srcPath); os.IsNotExist(err) {
+		return ErrNotExist
+	}
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return err
+	}
+
+	if err := os.Rename(srcPath, dstPath); err == nil {
+		return nil
+	}
+
+	// Rename fails across devices; fall back to copy and delete
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	if err := s.Put(ctx, dstKey, src, info.Size()); err != nil {
+		return err
+	}
+	return os.Remove(srcPath)
+}
+
+func (s *LocalStore) objectInfo(key string, info os.FileInfo) *ObjectInfo {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		cleaned = key
+	}
+	return &ObjectInfo{Key: cleaned, Size: info.Size(), ModTime: info.ModTime()}
+}
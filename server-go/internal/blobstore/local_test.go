@@ -0,0 +1,56 @@
+package blobstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(path, content string) error {
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	base := t.TempDir()
+	store, err := NewLocalStore(base)
+	require.NoError(t, err)
+
+	require.NoError(t, store.Put(ctx, "2024/03/a.jpg", strings.NewReader("aaa"), 3))
+	require.NoError(t, store.Put(ctx, "2024/03/.thumbs/a_small.jpg", strings.NewReader("t"), 1))
+
+	data, err := os.ReadFile(filepath.Join(base, "2024", "03", "a.jpg"))
+	require.NoError(t, err)
+	assert.Equal(t, "aaa", string(data), "keys map onto the directory layout")
+
+	info, err := store.Stat(ctx, "2024/03/a.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), info.Size)
+
+	_, err = store.Stat(ctx, "2024/03")
+	assert.ErrorIs(t, err, ErrNotExist, "directories are not objects")
+
+	var keys []string
+	require.NoError(t, store.List(ctx, "2024/03/", func(info ObjectInfo) error {
+		keys = append(keys, info.Key)
+		return nil
+	}))
+	assert.ElementsMatch(t, []string{"2024/03/a.jpg", "2024/03/.thumbs/a_small.jpg"}, keys)
+
+	require.NoError(t, store.Move(ctx, "2024/03/a.jpg", "device/2024/03/a.jpg"))
+	assert.False(t, Exists(ctx, store, "2024/03/a.jpg"))
+	assert.True(t, Exists(ctx, store, "device/2024/03/a.jpg"))
+
+	require.NoError(t, store.Delete(ctx, "device/2024/03/a.jpg"))
+	require.NoError(t, store.Delete(ctx, "device/2024/03/a.jpg"))
+
+	for _, key := range []string{"", "../etc/passwd", "2024/../../x", "/abs"} {
+		_, err := store.LocalPath(key)
+		assert.ErrorIs(t, err, ErrInvalidKey, key)
+	}
+}
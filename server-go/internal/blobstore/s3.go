@@ -0,0 +1,386 @@
+package blobstore
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Config configures the S3-compatible driver (AWS S3, MinIO, Garage, R2, ...)
+type S3Config struct {
+	Endpoint        string `json:"endpoint"` // e.g. "https://s3.eu-west-1.amazonaws.com" or "http://minio:9000"
+	Region          string `json:"region"`
+	Bucket          string `json:"bucket"`
+	AccessKeyID     string `json:"accessKeyId"`
+	SecretAccessKey string `json:"secretAccessKey"`
+	Prefix          string `json:"prefix"`         // Optional key prefix inside the bucket
+	ForcePathStyle  bool   `json:"forcePathStyle"` // Put the bucket in the path rather than the host name (MinIO)
+}
+
+// S3Store keeps objects in an S3-compatible bucket. Requests are signed with
+// AWS Signature Version 4; uploads stream with an unsigned payload.
+type S3Store struct {
+	cfg      S3Config
+	endpoint *url.URL
+	prefix   string
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewS3Store creates an S3Store from cfg
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 storage requires an endpoint and a bucket")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("s3 storage requires access credentials")
+	}
+
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	prefix := strings.Trim(cfg.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	return &S3Store{
+		cfg:      cfg,
+		endpoint: endpoint,
+		prefix:   prefix,
+		client:   &http.Client{Timeout: 10 * time.Minute},
+		now:      time.Now,
+	}, nil
+}
+
+// Put uploads the object in a single request
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	objectKey, err := s.objectKey(key)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(ctx, http.MethodPut, objectKey, nil, nil, r, size)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return s.checkResponse(resp, "PUT", key)
+}
+
+// Get opens the object. Seeking re-requests the remainder with a Range header.
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadSeekCloser, *ObjectInfo, error) {
+	objectKey, err := s.objectKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := s.do(ctx, http.MethodGet, objectKey, nil, nil, nil, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.checkResponse(resp, "GET", key); err != nil {
+		resp.Body.Close()
+		return nil, nil, err
+	}
+
+	info := s.infoFromHeaders(key, resp.Header)
+	if resp.ContentLength >= 0 {
+		info.Size = resp.ContentLength
+	}
+
+	return &s3Object{ctx: ctx, store: s, objectKey: objectKey, key: key, size: info.Size, body: resp.Body}, info, nil
+}
+
+// Stat issues a HEAD request for the object
+func (s *S3Store) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	objectKey, err := s.objectKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(ctx, http.MethodHead, objectKey, nil, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := s.checkResponse(resp, "HEAD", key); err != nil {
+		return nil, err
+	}
+	return s.infoFromHeaders(key, resp.Header), nil
+}
+
+// Delete removes the object; S3 treats missing keys as already deleted
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	objectKey, err := s.objectKey(key)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(ctx, http.MethodDelete, objectKey, nil, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := s.checkResponse(resp, "DELETE", key); err != nil && !errors.Is(err, ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// listBucketResult is the ListObjectsV2 response body
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List pages through ListObjectsV2 for the prefix
+func (s *S3Store) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", s.prefix+prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil, nil, 0)
+		if err != nil {
+			return err
+		}
+
+		var result listBucketResult
+		err = s.checkResponse(resp, "LIST", prefix)
+		if err == nil {
+			err = xml.NewDecoder(resp.Body).Decode(&result)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, c := range result.Contents {
+			info := ObjectInfo{
+				Key:     strings.TrimPrefix(c.Key, s.prefix),
+				Size:    c.Size,
+				ModTime: c.LastModified,
+			}
+			if err := fn(info); err != nil {
+				return err
+			}
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Move copies the object server-side and deletes the source
+func (s *S3Store) Move(ctx context.Context, srcKey, dstKey string) error {
+	srcObject, err := s.objectKey(srcKey)
+	if err != nil {
+		return err
+	}
+	dstObject, err := s.objectKey(dstKey)
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{
+		"X-Amz-Copy-Source": "/" + s.cfg.Bucket + "/" + s3Escape(srcObject, false),
+	}
+	resp, err := s.do(ctx, http.MethodPut, dstObject, nil, headers, nil, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := s.checkResponse(resp, "COPY", srcKey); err != nil {
+		return err
+	}
+
+	// A copy can fail after the 200 status has been sent; the error is then in the body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(string(body), "<Error>") {
+		return s.parseError(http.StatusInternalServerError, body, "COPY", srcKey)
+	}
+
+	return s.Delete(ctx, srcKey)
+}
+
+// objectKey maps a store key to the key inside the bucket
+func (s *S3Store) objectKey(key string) (string, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return s.prefix + cleaned, nil
+}
+
+// do builds, signs and sends a request for an object (or the bucket when objectKey is empty)
+func (s *S3Store) do(ctx context.Context, method, objectKey string, query url.Values, headers map[string]string, body io.Reader, size int64) (*http.Response, error) {
+	u := *s.endpoint
+	escapedPath := strings.TrimRight(u.EscapedPath(), "/")
+	if s.cfg.ForcePathStyle {
+		escapedPath += "/" + s3Escape(s.cfg.Bucket, true)
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+	}
+	escapedPath += "/" + s3Escape(objectKey, false)
+
+	decodedPath, err := url.PathUnescape(escapedPath)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = decodedPath
+	u.RawPath = escapedPath
+	if query != nil {
+		u.RawQuery = query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	payloadHash := emptyPayloadHash
+	if body != nil {
+		req.ContentLength = size
+		payloadHash = unsignedPayload
+	}
+	signV4(req, s.cfg.AccessKeyID, s.cfg.SecretAccessKey, s.cfg.Region, s.now(), payloadHash)
+
+	return s.client.Do(req)
+}
+
+// s3Error is the XML error body returned by S3
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// checkResponse turns non-2xx responses into errors, mapping missing keys to ErrNotExist
+func (s *S3Store) checkResponse(resp *http.Response, op, key string) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	return s.parseError(resp.StatusCode, body, op, key)
+}
+
+func (s *S3Store) parseError(status int, body []byte, op, key string) error {
+	var e s3Error
+	xml.Unmarshal(body, &e)
+
+	if status == http.StatusNotFound || e.Code == "NoSuchKey" {
+		return ErrNotExist
+	}
+	if e.Code == "" {
+		e.Code = http.StatusText(status)
+	}
+	return fmt.Errorf("s3 %s %s: %s: %s (status %d)", op, key, e.Code, e.Message, status)
+}
+
+func (s *S3Store) infoFromHeaders(key string, h http.Header) *ObjectInfo {
+	info := &ObjectInfo{Key: key}
+	if size, err := strconv.ParseInt(h.Get("Content-Length"), 10, 64); err == nil {
+		info.Size = size
+	}
+	if modTime, err := http.ParseTime(h.Get("Last-Modified")); err == nil {
+		info.ModTime = modTime
+	}
+	return info
+}
+
+// s3Object is a seekable reader over an S3 object. The first body comes from
+// Get; after a seek the next Read opens a ranged GET from the new offset.
+type s3Object struct {
+	ctx       context.Context
+	store     *S3Store
+	objectKey string
+	key       string
+	size      int64
+	offset    int64
+	body      io.ReadCloser
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+
+	if o.body == nil {
+		headers := map[string]string{"Range": fmt.Sprintf("bytes=%d-", o.offset)}
+		resp, err := o.store.do(o.ctx, http.MethodGet, o.objectKey, nil, headers, nil, 0)
+		if err != nil {
+			return 0, err
+		}
+		if err := o.store.checkResponse(resp, "GET", o.key); err != nil {
+			resp.Body.Close()
+			return 0, err
+		}
+		o.body = resp.Body
+	}
+
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = o.offset + offset
+	case io.SeekEnd:
+		abs = o.size + offset
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if abs < 0 {
+		return 0, fmt.Errorf("negative position %d", abs)
+	}
+
+	if abs != o.offset && o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+	o.offset = abs
+	return abs, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+	err := o.body.Close()
+	o.body = nil
+	return err
+}
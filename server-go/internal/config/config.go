@@ -5,6 +5,9 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+
+	"github.com/photosync/server/internal/blobstore"
 )
 
 // Config holds all application configuration
@@ -15,6 +18,7 @@ type Config struct {
 	PhotoStorage  PhotoStorage `json:"photoStorage"`
 	Security      Security     `json:"security"`
 	FileScanner   FileScanner  `json:"fileScanner"`
+	Inbox         Inbox        `json:"inbox"`
 }
 
 // FileScanner configuration for background file integrity scanning
@@ -24,6 +28,22 @@ type FileScanner struct {
 	AutoStart     bool `json:"autoStart"`
 }
 
+// Inbox configuration for folders watched for dropped files, such as a
+// scanner or card reader writing to the NAS over SMB. Files in Path belong to
+// DefaultOwner; UserPaths gives individual users their own inbox.
+type Inbox struct {
+	Path          string            `json:"path"`
+	DefaultOwner  string            `json:"defaultOwner"` // Email of the user who owns files dropped in Path
+	UserPaths     map[string]string `json:"userPaths"`    // User email -> inbox directory
+	PollSeconds   int               `json:"pollSeconds"`
+	SettleSeconds int               `json:"settleSeconds"` // A file must be unchanged this long before it is ingested
+}
+
+// Enabled returns true if any inbox directory is configured
+func (i Inbox) Enabled() bool {
+	return i.Path != "" || len(i.UserPaths) > 0
+}
+
 // UsePostgres returns true if PostgreSQL should be used
 func (c *Config) UsePostgres() bool {
 	return c.DatabaseURL != ""
@@ -31,15 +51,69 @@ func (c *Config) UsePostgres() bool {
 
 // PhotoStorage configuration
 type PhotoStorage struct {
-	BasePath          string   `json:"basePath"`
-	MaxFileSizeMB     int64    `json:"maxFileSizeMB"`
-	AllowedExtensions []string `json:"allowedExtensions"`
+	BasePath              string   `json:"basePath"`
+	MaxFileSizeMB         int64    `json:"maxFileSizeMB"`
+	AllowedExtensions     []string `json:"allowedExtensions"`
+	UploadSessionTTLHours int      `json:"uploadSessionTTLHours"`
+	BatchUploadWorkers    int      `json:"batchUploadWorkers"` // Files processed in parallel per batch upload request
+	TrashRetentionDays    int      `json:"trashRetentionDays"`
+
+	// ChangeLogRetentionDays is how long deletion tombstones stay in the sync
+	// change log. Devices offline for longer must do a full resync.
+	ChangeLogRetentionDays int `json:"changeLogRetentionDays"`
+
+	// ImportRoot is the server directory admins may import exported
+	// libraries from. Directory imports are disabled when it is empty.
+	ImportRoot string `json:"importRoot"`
+
+	// MaxImportSizeMB caps the archives uploaded in one import request
+	MaxImportSizeMB int64 `json:"maxImportSizeMB"`
+
+	// Originals and Thumbnails select where photo files and their .thumbs/
+	// renditions are kept. Both default to the local filesystem at BasePath;
+	// BasePath itself always holds upload staging and server config.
+	Originals  blobstore.Config `json:"originals"`
+	Thumbnails blobstore.Config `json:"thumbnails"`
+}
+
+// OriginalsStore returns the blob store configuration for photo originals
+func (p PhotoStorage) OriginalsStore() blobstore.Config {
+	return p.withLocalDefault(p.Originals)
+}
+
+// ThumbnailsStore returns the blob store configuration for thumbnails
+func (p PhotoStorage) ThumbnailsStore() blobstore.Config {
+	return p.withLocalDefault(p.Thumbnails)
+}
+
+func (p PhotoStorage) withLocalDefault(cfg blobstore.Config) blobstore.Config {
+	if cfg.Driver == "" {
+		cfg.Driver = blobstore.DriverLocal
+	}
+	if cfg.Driver == blobstore.DriverLocal && cfg.BasePath == "" {
+		cfg.BasePath = p.BasePath
+	}
+	return cfg
 }
 
 // Security configuration
 type Security struct {
 	APIKey       string `json:"apiKey"`
 	APIKeyHeader string `json:"apiKeyHeader"`
+
+	// MediaURLSecret signs media URLs; when empty a random secret is used and
+	// issued URLs stop working when the server restarts
+	MediaURLSecret     string `json:"mediaUrlSecret"`
+	MediaURLTTLMinutes int    `json:"mediaUrlTtlMinutes"`
+
+	// GalleryDownloadsPerHour caps the gallery ZIP downloads each IP address
+	// may start per hour; 0 means no limit
+	GalleryDownloadsPerHour int `json:"galleryDownloadsPerHour"`
+
+	// TrustedProxies lists the reverse proxies (IPs or CIDRs) whose
+	// X-Forwarded-For and X-Real-IP headers name the client. Other requests
+	// are attributed to the connecting address.
+	TrustedProxies []string `json:"trustedProxies"`
 }
 
 // Default configuration
@@ -52,17 +126,30 @@ func defaultConfig() *Config {
 			MaxFileSizeMB: 50,
 			AllowedExtensions: []string{
 				".jpg", ".jpeg", ".png", ".gif", ".webp", ".heic", ".heif",
+				".mp4", ".mov", ".m4v", ".3gp",
 			},
+			UploadSessionTTLHours: 24,
+			BatchUploadWorkers:    4,
+			TrashRetentionDays:    30,
+
+			ChangeLogRetentionDays: 90,
+			MaxImportSizeMB:        20480,
 		},
 		Security: Security{
-			APIKey:       "CHANGE_THIS_TO_A_SECURE_API_KEY_AT_LEAST_32_CHARS",
-			APIKeyHeader: "X-API-Key",
+			APIKey:             "CHANGE_THIS_TO_A_SECURE_API_KEY_AT_LEAST_32_CHARS",
+			APIKeyHeader:       "X-API-Key",
+			MediaURLTTLMinutes: 60,
+			TrustedProxies:     []string{"127.0.0.1/8", "::1/128"},
 		},
 		FileScanner: FileScanner{
 			Enabled:       true,
 			IntervalHours: 24,
 			AutoStart:     false,
 		},
+		Inbox: Inbox{
+			PollSeconds:   30,
+			SettleSeconds: 10,
+		},
 	}
 }
 
@@ -95,9 +182,86 @@ func Load() (*Config, error) {
 	if basePath := os.Getenv("PHOTO_STORAGE_PATH"); basePath != "" {
 		cfg.PhotoStorage.BasePath = basePath
 	}
+	if ttl := os.Getenv("UPLOAD_SESSION_TTL_HOURS"); ttl != "" {
+		if hours, err := strconv.Atoi(ttl); err == nil && hours > 0 {
+			cfg.PhotoStorage.UploadSessionTTLHours = hours
+		}
+	}
+	if workers := os.Getenv("BATCH_UPLOAD_WORKERS"); workers != "" {
+		if n, err := strconv.Atoi(workers); err == nil && n > 0 {
+			cfg.PhotoStorage.BatchUploadWorkers = n
+		}
+	}
+	if retention := os.Getenv("TRASH_RETENTION_DAYS"); retention != "" {
+		if days, err := strconv.Atoi(retention); err == nil && days > 0 {
+			cfg.PhotoStorage.TrashRetentionDays = days
+		}
+	}
+	if retention := os.Getenv("CHANGE_LOG_RETENTION_DAYS"); retention != "" {
+		if days, err := strconv.Atoi(retention); err == nil && days > 0 {
+			cfg.PhotoStorage.ChangeLogRetentionDays = days
+		}
+	}
+
+	if importRoot := os.Getenv("IMPORT_ROOT"); importRoot != "" {
+		cfg.PhotoStorage.ImportRoot = importRoot
+	}
+	if maxImport := os.Getenv("MAX_IMPORT_SIZE_MB"); maxImport != "" {
+		if mb, err := strconv.ParseInt(maxImport, 10, 64); err == nil && mb > 0 {
+			cfg.PhotoStorage.MaxImportSizeMB = mb
+		}
+	}
+
+	// Object storage for originals
+	if driver := os.Getenv("ORIGINALS_STORAGE_DRIVER"); driver != "" {
+		cfg.PhotoStorage.Originals.Driver = driver
+	}
+	if thumbPath := os.Getenv("THUMBNAILS_STORAGE_PATH"); thumbPath != "" {
+		cfg.PhotoStorage.Thumbnails.Driver = blobstore.DriverLocal
+		cfg.PhotoStorage.Thumbnails.BasePath = thumbPath
+	}
+	s3 := &cfg.PhotoStorage.Originals.S3
+	if endpoint := os.Getenv("S3_ENDPOINT"); endpoint != "" {
+		s3.Endpoint = endpoint
+	}
+	if region := os.Getenv("S3_REGION"); region != "" {
+		s3.Region = region
+	}
+	if bucket := os.Getenv("S3_BUCKET"); bucket != "" {
+		s3.Bucket = bucket
+	}
+	if accessKey := os.Getenv("S3_ACCESS_KEY_ID"); accessKey != "" {
+		s3.AccessKeyID = accessKey
+	}
+	if secretKey := os.Getenv("S3_SECRET_ACCESS_KEY"); secretKey != "" {
+		s3.SecretAccessKey = secretKey
+	}
+	if prefix := os.Getenv("S3_PREFIX"); prefix != "" {
+		s3.Prefix = prefix
+	}
+	if pathStyle := os.Getenv("S3_FORCE_PATH_STYLE"); pathStyle != "" {
+		s3.ForcePathStyle = pathStyle == "true" || pathStyle == "1"
+	}
+
 	if apiKey := os.Getenv("API_KEY"); apiKey != "" {
 		cfg.Security.APIKey = apiKey
 	}
+	if secret := os.Getenv("MEDIA_URL_SECRET"); secret != "" {
+		cfg.Security.MediaURLSecret = secret
+	}
+	if ttl := os.Getenv("MEDIA_URL_TTL_MINUTES"); ttl != "" {
+		if minutes, err := strconv.Atoi(ttl); err == nil && minutes > 0 {
+			cfg.Security.MediaURLTTLMinutes = minutes
+		}
+	}
+	if downloads := os.Getenv("GALLERY_DOWNLOADS_PER_HOUR"); downloads != "" {
+		if perHour, err := strconv.Atoi(downloads); err == nil && perHour >= 0 {
+			cfg.Security.GalleryDownloadsPerHour = perHour
+		}
+	}
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		cfg.Security.TrustedProxies = strings.Split(proxies, ",")
+	}
 
 	// File scanner configuration
 	if enabled := os.Getenv("FILE_SCANNER_ENABLED"); enabled != "" {
@@ -112,6 +276,19 @@ func Load() (*Config, error) {
 		cfg.FileScanner.AutoStart = autoStart == "true" || autoStart == "1"
 	}
 
+	// Watched inbox
+	if inboxPath := os.Getenv("INBOX_PATH"); inboxPath != "" {
+		cfg.Inbox.Path = inboxPath
+	}
+	if owner := os.Getenv("INBOX_DEFAULT_OWNER"); owner != "" {
+		cfg.Inbox.DefaultOwner = owner
+	}
+	if poll := os.Getenv("INBOX_POLL_SECONDS"); poll != "" {
+		if seconds, err := strconv.Atoi(poll); err == nil && seconds > 0 {
+			cfg.Inbox.PollSeconds = seconds
+		}
+	}
+
 	// Ensure photo storage directory exists
 	if err := os.MkdirAll(cfg.PhotoStorage.BasePath, 0755); err != nil {
 		return nil, err
@@ -112,7 +112,7 @@ func (h *AdminHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 
 // UpdateUser updates a user's details
 // @Summary Update user
-// @Description Update a user's details
+// @Description Update a user's details and storage quotas (omit quotaBytes/quotaPhotos to leave them unchanged, 0 = unlimited)
 // @Tags admin
 // @Accept json
 // @Produce json
@@ -141,6 +141,10 @@ func (h *AdminHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Email already registered", http.StatusConflict)
 			return
 		}
+		if err == models.ErrNegativeQuota {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
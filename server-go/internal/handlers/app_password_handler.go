@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/photosync/server/internal/middleware"
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+)
+
+// AppPasswordHandler manages the app passwords a user signs in to WebDAV with
+type AppPasswordHandler struct {
+	appPasswordRepo repository.AppPasswordRepo
+}
+
+// NewAppPasswordHandler creates a new AppPasswordHandler
+func NewAppPasswordHandler(appPasswordRepo repository.AppPasswordRepo) *AppPasswordHandler {
+	return &AppPasswordHandler{
+		appPasswordRepo: appPasswordRepo,
+	}
+}
+
+// ListAppPasswords returns the current user's app passwords
+// @Summary List app passwords
+// @Description List the app passwords of the current user. The passwords themselves are never returned.
+// @Tags users
+// @Produce json
+// @Success 200 {object} models.AppPasswordListResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Security SessionAuth
+// @Router /api/users/me/app-passwords [get]
+func (h *AppPasswordHandler) ListAppPasswords(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	passwords, err := h.appPasswordRepo.GetAllForUser(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error listing app passwords: %v", err)
+		http.Error(w, "Failed to list app passwords", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.AppPasswordListResponse{AppPasswords: passwords})
+}
+
+// CreateAppPassword creates an app password for the current user
+// @Summary Create app password
+// @Description Create a password for clients such as WebDAV mounts that sign in with the account email and a password. The password is only shown in this response.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body models.CreateAppPasswordRequest true "App password name"
+// @Success 201 {object} models.CreateAppPasswordResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Security SessionAuth
+// @Router /api/users/me/app-passwords [post]
+func (h *AppPasswordHandler) CreateAppPassword(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.CreateAppPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ap, password, err := models.NewAppPassword(user.ID, req.Name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.appPasswordRepo.Add(r.Context(), ap); err != nil {
+		log.Printf("Error creating app password: %v", err)
+		http.Error(w, "Failed to create app password", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.CreateAppPasswordResponse{AppPassword: *ap, Password: password})
+}
+
+// DeleteAppPassword revokes one of the current user's app passwords
+// @Summary Revoke app password
+// @Tags users
+// @Param id path string true "App password ID"
+// @Success 204 "App password revoked"
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Security SessionAuth
+// @Router /api/users/me/app-passwords/{id} [delete]
+func (h *AppPasswordHandler) DeleteAppPassword(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	deleted, err := h.appPasswordRepo.Delete(r.Context(), chi.URLParam(r, "id"), user.ID)
+	if err != nil {
+		log.Printf("Error deleting app password: %v", err)
+		http.Error(w, "Failed to revoke app password", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, models.ErrAppPasswordNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
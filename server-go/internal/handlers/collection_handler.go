@@ -13,6 +13,7 @@ import (
 // CollectionHandler handles collection API endpoints
 type CollectionHandler struct {
 	collectionService *services.CollectionService
+	mediaURLs         *services.MediaURLService
 }
 
 // NewCollectionHandler creates a new CollectionHandler
@@ -22,6 +23,11 @@ func NewCollectionHandler(collectionService *services.CollectionService) *Collec
 	}
 }
 
+// SetMediaURLs enables signed media URLs for the photos in collection responses
+func (h *CollectionHandler) SetMediaURLs(mediaURLs *services.MediaURLService) {
+	h.mediaURLs = mediaURLs
+}
+
 // ListCollections returns collections owned by and shared with the user
 func (h *CollectionHandler) ListCollections(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUserFromContext(r.Context())
@@ -64,6 +70,10 @@ func (h *CollectionHandler) CreateCollection(w http.ResponseWriter, r *http.Requ
 			http.Error(w, "Invalid theme", http.StatusBadRequest)
 			return
 		}
+		if isSmartRulesError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Failed to create collection", http.StatusInternalServerError)
 		return
 	}
@@ -101,8 +111,8 @@ func (h *CollectionHandler) GetCollection(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Get photos for this collection
-	photos, err := h.collectionService.GetPhotos(r.Context(), collectionID, user.ID)
+	// Get photos for this collection with who added them
+	entries, err := h.collectionService.GetPhotoEntries(r.Context(), collectionID, user.ID)
 	if err != nil {
 		http.Error(w, "Failed to get photos", http.StatusInternalServerError)
 		return
@@ -118,9 +128,12 @@ func (h *CollectionHandler) GetCollection(w http.ResponseWriter, r *http.Request
 		}
 	}
 
+	for _, e := range entries {
+		attachMediaURLs(h.mediaURLs, e.Photo)
+	}
 	response := models.CollectionResponse{
 		Collection: collection,
-		Photos:     toCollectionPhotos(photos),
+		Photos:     entries,
 		Shares:     shares,
 	}
 
@@ -162,6 +175,14 @@ func (h *CollectionHandler) UpdateCollection(w http.ResponseWriter, r *http.Requ
 			http.Error(w, "Slug already exists", http.StatusConflict)
 			return
 		}
+		if err == models.ErrCollectionNotSmart {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if isSmartRulesError(err) || err == models.ErrCollectionInvalidStripping {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Failed to update collection", http.StatusInternalServerError)
 		return
 	}
@@ -277,6 +298,10 @@ func (h *CollectionHandler) AddPhotos(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "You can only add your own photos", http.StatusForbidden)
 			return
 		}
+		if err == models.ErrCollectionSmart {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, "Failed to add photos", http.StatusInternalServerError)
 		return
 	}
@@ -314,6 +339,10 @@ func (h *CollectionHandler) RemovePhotos(w http.ResponseWriter, r *http.Request)
 			http.Error(w, "Access denied", http.StatusForbidden)
 			return
 		}
+		if err == models.ErrCollectionSmart {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, "Failed to remove photos", http.StatusInternalServerError)
 		return
 	}
@@ -351,6 +380,10 @@ func (h *CollectionHandler) ReorderPhotos(w http.ResponseWriter, r *http.Request
 			http.Error(w, "Access denied", http.StatusForbidden)
 			return
 		}
+		if err == models.ErrCollectionSmart {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, "Failed to reorder photos", http.StatusInternalServerError)
 		return
 	}
@@ -378,8 +411,12 @@ func (h *CollectionHandler) ShareWithUsers(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	failedEmails, err := h.collectionService.ShareWithUsers(r.Context(), collectionID, user.ID, req.Emails)
+	failedEmails, err := h.collectionService.ShareWithUsers(r.Context(), collectionID, user.ID, req.Emails, req.Role)
 	if err != nil {
+		if err == models.ErrCollectionInvalidShareRole {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		if err == models.ErrCollectionNotFound {
 			http.Error(w, "Collection not found", http.StatusNotFound)
 			return
@@ -433,6 +470,49 @@ func (h *CollectionHandler) RemoveShare(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// UpdateShare changes the role of a user a collection is shared with
+func (h *CollectionHandler) UpdateShare(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	collectionID := chi.URLParam(r, "id")
+	userID := chi.URLParam(r, "userId")
+
+	if collectionID == "" || userID == "" {
+		http.Error(w, "Collection ID and User ID required", http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err := h.collectionService.UpdateShareRole(r.Context(), collectionID, user.ID, userID, req.Role)
+	if err != nil {
+		if err == models.ErrCollectionNotFound || err == models.ErrCollectionShareNotFound {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err == models.ErrCollectionAccessDenied {
+			http.Error(w, "Access denied", http.StatusForbidden)
+			return
+		}
+		if err == models.ErrCollectionInvalidShareRole {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to update share", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // GetThemes returns available themes
 func (h *CollectionHandler) GetThemes(w http.ResponseWriter, r *http.Request) {
 	response := models.ThemesResponse{
@@ -443,13 +523,12 @@ func (h *CollectionHandler) GetThemes(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// Helper to convert photos to collection photo format
-func toCollectionPhotos(photos []*models.Photo) []*models.CollectionPhotoWithDetails {
-	result := make([]*models.CollectionPhotoWithDetails, len(photos))
-	for i, p := range photos {
-		result[i] = &models.CollectionPhotoWithDetails{
-			Photo: p,
-		}
+// isSmartRulesError reports whether err rejects the rules of a smart collection
+func isSmartRulesError(err error) bool {
+	switch err {
+	case models.ErrSmartRulesEmpty, models.ErrSmartRulesInvalidDates, models.ErrSmartRulesInvalidBox,
+		models.ErrSmartRulesInvalidRadius, models.ErrSmartRulesPatternTooLong, models.ErrSmartRulesInvalidDays:
+		return true
 	}
-	return result
+	return false
 }
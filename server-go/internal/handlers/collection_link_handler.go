@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/photosync/server/internal/middleware"
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/services"
+)
+
+// CollectionLinkHandler manages the share links of a user's collections
+type CollectionLinkHandler struct {
+	linkService *services.CollectionLinkService
+}
+
+// NewCollectionLinkHandler creates a new CollectionLinkHandler
+func NewCollectionLinkHandler(linkService *services.CollectionLinkService) *CollectionLinkHandler {
+	return &CollectionLinkHandler{
+		linkService: linkService,
+	}
+}
+
+// CreateLink adds a share link to a collection
+// @Summary Create a share link
+// @Description Create a link that opens the collection's gallery at /gallery/s/{token}, whatever the collection's visibility. A link can have a label, a password, an expiry time and a maximum number of views.
+// @Tags collections
+// @Accept json
+// @Produce json
+// @Param id path string true "Collection ID"
+// @Param request body models.CreateCollectionLinkRequest true "Link settings"
+// @Success 201 {object} models.CollectionLink
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/web/collections/{id}/links [post]
+func (h *CollectionLinkHandler) CreateLink(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.CreateCollectionLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	link, err := h.linkService.CreateLink(r.Context(), user.ID, chi.URLParam(r, "id"), req)
+	if err != nil {
+		h.respondLinkError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(link)
+}
+
+// ListLinks returns a collection's share links
+// @Summary List share links
+// @Description List a collection's share links with their view counts, newest first. Revoked and expired links are included.
+// @Tags collections
+// @Produce json
+// @Param id path string true "Collection ID"
+// @Success 200 {object} models.CollectionLinkListResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/web/collections/{id}/links [get]
+func (h *CollectionLinkHandler) ListLinks(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	links, err := h.linkService.ListLinks(r.Context(), user.ID, chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondLinkError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.CollectionLinkListResponse{Links: links})
+}
+
+// RevokeLink stops a share link from working
+// @Summary Revoke a share link
+// @Description Revoke one share link. The collection's other links keep working.
+// @Tags collections
+// @Param id path string true "Collection ID"
+// @Param linkId path string true "Link ID"
+// @Success 204
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse "Already revoked"
+// @Router /api/web/collections/{id}/links/{linkId} [delete]
+func (h *CollectionLinkHandler) RevokeLink(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err := h.linkService.RevokeLink(r.Context(), user.ID, chi.URLParam(r, "id"), chi.URLParam(r, "linkId"))
+	if err != nil {
+		h.respondLinkError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAccessLog returns the visits to a share link
+// @Summary Get a share link's access log
+// @Description List visits to a share link, newest first, with the visitor's IP address and user agent and whether they were let in. Visits are kept for 90 days.
+// @Tags collections
+// @Produce json
+// @Param id path string true "Collection ID"
+// @Param linkId path string true "Link ID"
+// @Param limit query int false "Maximum entries (default and max 500)"
+// @Success 200 {object} models.CollectionLinkAccessResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/web/collections/{id}/links/{linkId}/access [get]
+func (h *CollectionLinkHandler) GetAccessLog(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	accesses, err := h.linkService.AccessLog(r.Context(), user.ID, chi.URLParam(r, "id"), chi.URLParam(r, "linkId"), limit)
+	if err != nil {
+		h.respondLinkError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.CollectionLinkAccessResponse{Accesses: accesses})
+}
+
+// respondLinkError maps share link errors to HTTP responses
+func (h *CollectionLinkHandler) respondLinkError(w http.ResponseWriter, err error) {
+	switch err {
+	case models.ErrCollectionNotFound, models.ErrCollectionLinkNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case models.ErrCollectionAccessDenied:
+		http.Error(w, "Access denied", http.StatusForbidden)
+	case models.ErrCollectionLinkRevoked:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case models.ErrCollectionLinkLabelTooLong, models.ErrCollectionLinkExpiryInPast, models.ErrCollectionLinkInvalidViews:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Share link error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/photosync/server/internal/middleware"
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/services"
+)
+
+// DuplicateHandler handles near-duplicate review endpoints for both API key and session users
+type DuplicateHandler struct {
+	duplicateService *services.DuplicateService
+}
+
+// NewDuplicateHandler creates a new DuplicateHandler
+func NewDuplicateHandler(duplicateService *services.DuplicateService) *DuplicateHandler {
+	return &DuplicateHandler{
+		duplicateService: duplicateService,
+	}
+}
+
+// ListGroups returns the current user's pending near-duplicate groups
+// @Summary List duplicate groups
+// @Description Get the user's groups of visually similar photos awaiting review
+// @Tags duplicates
+// @Produce json
+// @Success 200 {object} models.DuplicateGroupListResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Security SessionAuth
+// @Router /api/photos/duplicates [get]
+// @Router /api/web/duplicates [get]
+func (h *DuplicateHandler) ListGroups(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	groups, err := h.duplicateService.ListGroups(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error listing duplicate groups: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.DuplicateGroupListResponse{Groups: groups})
+}
+
+// ResolveGroup keeps one photo of a group and trashes the rest
+// @Summary Resolve a duplicate group
+// @Description Keep the chosen photo and move the other photos in the group to the trash
+// @Tags duplicates
+// @Accept json
+// @Produce json
+// @Param id path string true "Duplicate group ID"
+// @Param request body models.ResolveDuplicateGroupRequest true "Photo to keep"
+// @Success 200 {object} models.ResolveDuplicateGroupResponse
+// @Failure 400 {object} models.ErrorResponse "Photo is not part of the group"
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse "Group not found"
+// @Security ApiKeyAuth
+// @Security SessionAuth
+// @Router /api/photos/duplicates/{id}/resolve [post]
+// @Router /api/web/duplicates/{id}/resolve [post]
+func (h *DuplicateHandler) ResolveGroup(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	groupID := chi.URLParam(r, "id")
+	if groupID == "" {
+		http.Error(w, "Group ID required", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ResolveDuplicateGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.KeepPhotoID == "" {
+		http.Error(w, "keepPhotoId is required", http.StatusBadRequest)
+		return
+	}
+
+	trashed, err := h.duplicateService.Resolve(r.Context(), user.ID, groupID, req.KeepPhotoID)
+	if err != nil {
+		switch err {
+		case models.ErrDuplicateGroupNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case models.ErrPhotoNotInGroup:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			log.Printf("Error resolving duplicate group %s: %v", groupID, err)
+			http.Error(w, "Failed to resolve duplicate group", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ResolveDuplicateGroupResponse{
+		KeptPhotoID:     req.KeepPhotoID,
+		TrashedPhotoIDs: trashed,
+	})
+}
+
+// DismissGroup marks a group as not duplicates
+// @Summary Dismiss a duplicate group
+// @Description Mark the photos in a group as distinct so the group is not suggested again
+// @Tags duplicates
+// @Param id path string true "Duplicate group ID"
+// @Success 204 "Group dismissed"
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse "Group not found"
+// @Security ApiKeyAuth
+// @Security SessionAuth
+// @Router /api/photos/duplicates/{id}/dismiss [post]
+// @Router /api/web/duplicates/{id}/dismiss [post]
+func (h *DuplicateHandler) DismissGroup(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	groupID := chi.URLParam(r, "id")
+	if groupID == "" {
+		http.Error(w, "Group ID required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.duplicateService.Dismiss(r.Context(), user.ID, groupID); err != nil {
+		if err == models.ErrDuplicateGroupNotFound {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		log.Printf("Error dismissing duplicate group %s: %v", groupID, err)
+		http.Error(w, "Failed to dismiss duplicate group", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
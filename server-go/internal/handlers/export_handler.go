@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/photosync/server/internal/middleware"
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+	"github.com/photosync/server/internal/services"
+)
+
+// ExportHandler streams library exports for users and administrators
+type ExportHandler struct {
+	exportService *services.ExportService
+	userRepo      repository.UserRepo
+}
+
+// NewExportHandler creates a new ExportHandler
+func NewExportHandler(exportService *services.ExportService, userRepo repository.UserRepo) *ExportHandler {
+	return &ExportHandler{
+		exportService: exportService,
+		userRepo:      userRepo,
+	}
+}
+
+// ExportMyLibrary streams the current user's library as an archive
+// @Summary Export my library
+// @Description Stream the user's originals in Year/Month folders, each with a JSON sidecar (EXIF, GPS, collections, origin device), followed by manifest.json with SHA-256 hashes. Photos whose original is unavailable are listed under "missing" in the manifest.
+// @Tags users
+// @Produce application/zip
+// @Produce application/x-tar
+// @Param format query string false "Archive format: zip or tar" default(zip)
+// @Param from query string false "Only photos taken on or after this date (RFC3339 or YYYY-MM-DD)"
+// @Param to query string false "Only photos taken on or before this date (RFC3339 or YYYY-MM-DD)"
+// @Param collectionId query string false "Only photos in this collection"
+// @Success 200 {file} file "Export archive"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse "Collection not found"
+// @Security ApiKeyAuth
+// @Security SessionAuth
+// @Router /api/users/me/export [get]
+func (h *ExportHandler) ExportMyLibrary(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	h.export(w, r, user.ID)
+}
+
+// AdminExportUserLibrary streams any user's library as an archive
+// @Summary Export a user's library
+// @Description Admin variant of /api/users/me/export for any user. Accepts the same filters.
+// @Tags admin
+// @Produce application/zip
+// @Produce application/x-tar
+// @Param id path string true "User ID"
+// @Param format query string false "Archive format: zip or tar" default(zip)
+// @Param from query string false "Only photos taken on or after this date (RFC3339 or YYYY-MM-DD)"
+// @Param to query string false "Only photos taken on or before this date (RFC3339 or YYYY-MM-DD)"
+// @Param collectionId query string false "Only photos in this collection"
+// @Success 200 {file} file "Export archive"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse "User or collection not found"
+// @Security SessionAuth
+// @Router /api/admin/users/{id}/export [get]
+func (h *ExportHandler) AdminExportUserLibrary(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "id")
+	user, err := h.userRepo.GetByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error getting user for export: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	h.export(w, r, user.ID)
+}
+
+func (h *ExportHandler) export(w http.ResponseWriter, r *http.Request, userID string) {
+	opts, err := models.ParseExportOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts.UserID = userID
+
+	if err := h.exportService.Validate(r.Context(), opts); err != nil {
+		if err == models.ErrCollectionNotFound {
+			http.Error(w, "Collection not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error validating export: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w = newProgressDeadlineWriter(w)
+	contentType := "application/zip"
+	if opts.Format == models.ExportFormatTar {
+		contentType = "application/x-tar"
+	}
+	filename := fmt.Sprintf("photosync-export-%s.%s", time.Now().UTC().Format("20060102"), opts.Format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+
+	manifest, err := h.exportService.Write(r.Context(), w, opts)
+	if err != nil {
+		// Headers are already sent; the client sees a truncated archive
+		log.Printf("Export for user %s aborted: %v", userID, err)
+		return
+	}
+	log.Printf("Exported %d photos (%d bytes, %d missing) for user %s", manifest.Count, manifest.TotalSize, len(manifest.Missing), userID)
+}
@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/photosync/server/internal/middleware"
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+	"github.com/photosync/server/internal/services"
+)
+
+// importUploadTimeout bounds how long one import request may take to upload.
+// Exported libraries take far longer than the server-wide timeouts allow, but
+// a connection that trickles data must not hold a spool file open forever.
+const importUploadTimeout = 12 * time.Hour
+
+// ImportHandler starts and tracks library imports
+type ImportHandler struct {
+	importService *services.ImportService
+	userRepo      repository.UserRepo
+}
+
+// NewImportHandler creates a new ImportHandler
+func NewImportHandler(importService *services.ImportService, userRepo repository.UserRepo) *ImportHandler {
+	return &ImportHandler{
+		importService: importService,
+		userRepo:      userRepo,
+	}
+}
+
+// CreateImport uploads an exported library and queues its import
+// @Summary Import a library archive
+// @Description Upload a Google Takeout or Apple Photos export as zip, tar or tar.gz and import it in the background. Send the archives as multipart/form-data file parts (Takeout's split archives can be sent together) or one archive as the raw body. Media is paired with its .json or .xmp sidecar for date, location and caption, deduplicated by hash, and album folders become collections. Progress arrives over /ws as import_progress and import_complete messages.
+// @Tags imports
+// @Accept multipart/form-data
+// @Accept application/zip
+// @Accept application/x-tar
+// @Accept application/gzip
+// @Produce json
+// @Success 202 {object} models.ImportJobResponse "Import queued"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse "Archives larger than maxImportSizeMB"
+// @Failure 415 {object} models.ErrorResponse "Unsupported archive type"
+// @Failure 507 {object} models.ErrorResponse "Over quota or out of disk space"
+// @Security ApiKeyAuth
+// @Security SessionAuth
+// @Router /api/imports [post]
+func (h *ImportHandler) CreateImport(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Refuse what cannot fit before reading any of it
+	if err := h.importService.CheckArchiveUpload(r.Context(), user.ID, r.ContentLength); err != nil {
+		h.respondImportError(w, err)
+		return
+	}
+	if limit := h.importService.MaxArchiveSize(); limit > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+
+	var src services.BatchFileSource
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, "Invalid multipart body", http.StatusBadRequest)
+			return
+		}
+		src = services.NewMultipartBatchSource(reader)
+	} else {
+		format := models.ImportArchiveFormatForContentType(mediaType)
+		if format == "" {
+			http.Error(w, models.ErrImportUnsupportedArchive.Error(), http.StatusUnsupportedMediaType)
+			return
+		}
+		src = services.NewSingleArchiveSource(format, r.Body)
+	}
+
+	rc := http.NewResponseController(w)
+	deadline := time.Now().Add(importUploadTimeout)
+	rc.SetReadDeadline(deadline)
+	rc.SetWriteDeadline(deadline)
+
+	job, err := h.importService.CreateFromArchives(user.ID, src)
+	if err != nil {
+		h.respondImportError(w, err)
+		return
+	}
+
+	h.respondJob(w, http.StatusAccepted, job)
+}
+
+// ListImports returns the user's import jobs
+// @Summary List imports
+// @Description List the user's import jobs, newest first, with their error reports
+// @Tags imports
+// @Produce json
+// @Success 200 {object} models.ImportJobListResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Security SessionAuth
+// @Router /api/imports [get]
+func (h *ImportHandler) ListImports(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	jobs, err := h.importService.List(user.ID)
+	if err != nil {
+		h.respondImportError(w, err)
+		return
+	}
+
+	resp := models.ImportJobListResponse{Jobs: []models.ImportJobResponse{}}
+	for _, job := range jobs {
+		resp.Jobs = append(resp.Jobs, job.ToResponse())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GetImport returns an import job's progress and error report
+// @Summary Get import status
+// @Tags imports
+// @Produce json
+// @Param id path string true "Import ID"
+// @Success 200 {object} models.ImportJobResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Security SessionAuth
+// @Router /api/imports/{id} [get]
+func (h *ImportHandler) GetImport(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	job, err := h.importService.Get(chi.URLParam(r, "id"), user.ID)
+	if err != nil {
+		h.respondImportError(w, err)
+		return
+	}
+
+	h.respondJob(w, http.StatusOK, job)
+}
+
+// CancelImport stops a queued or running import
+// @Summary Cancel an import
+// @Description Stop an import. Photos already imported stay in the library and the import can be resumed later.
+// @Tags imports
+// @Produce json
+// @Param id path string true "Import ID"
+// @Success 202 {object} models.ImportJobResponse "Cancellation requested"
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse "Import already finished"
+// @Security ApiKeyAuth
+// @Security SessionAuth
+// @Router /api/imports/{id} [delete]
+func (h *ImportHandler) CancelImport(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	job, err := h.importService.Cancel(chi.URLParam(r, "id"), user.ID)
+	if err != nil {
+		h.respondImportError(w, err)
+		return
+	}
+
+	h.respondJob(w, http.StatusAccepted, job)
+}
+
+// ResumeImport queues a failed or cancelled import again
+// @Summary Resume an import
+// @Description Queue a failed or cancelled import again. Files it already handled are skipped.
+// @Tags imports
+// @Produce json
+// @Param id path string true "Import ID"
+// @Success 202 {object} models.ImportJobResponse "Import queued"
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse "Import is not failed or cancelled"
+// @Security ApiKeyAuth
+// @Security SessionAuth
+// @Router /api/imports/{id}/resume [post]
+func (h *ImportHandler) ResumeImport(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	job, err := h.importService.Resume(chi.URLParam(r, "id"), user.ID)
+	if err != nil {
+		h.respondImportError(w, err)
+		return
+	}
+
+	h.respondJob(w, http.StatusAccepted, job)
+}
+
+// AdminImportDirectory imports a server directory into a user's library
+// @Summary Import a server directory
+// @Description Import a Takeout or Apple Photos export that is already on the server. The path must lie below the configured importRoot; relative paths are taken from it.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body models.CreateDirectoryImportRequest true "Target user and directory"
+// @Success 202 {object} models.ImportJobResponse "Import queued"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse "User or directory not found"
+// @Security SessionAuth
+// @Router /api/admin/imports [post]
+func (h *ImportHandler) AdminImportDirectory(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateDirectoryImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.UserID == "" || req.Path == "" {
+		http.Error(w, "userId and path are required", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.userRepo.GetByID(r.Context(), req.UserID)
+	if err != nil {
+		log.Printf("Error getting user for import: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	job, err := h.importService.CreateFromDirectory(user.ID, req.Path)
+	if err != nil {
+		h.respondImportError(w, err)
+		return
+	}
+
+	h.respondJob(w, http.StatusAccepted, job)
+}
+
+func (h *ImportHandler) respondJob(w http.ResponseWriter, status int, job *models.ImportJob) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(job.ToResponse())
+}
+
+// respondImportError maps import errors to HTTP responses
+func (h *ImportHandler) respondImportError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		err = models.ErrImportArchiveTooLarge
+	}
+
+	switch err {
+	case models.ErrImportNotFound, models.ErrImportPathNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case models.ErrImportUnsupportedArchive, models.ErrImportNoArchives,
+		models.ErrImportPathNotAllowed, models.ErrImportDirectoryDisabled:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case models.ErrImportFinished, models.ErrImportNotResumable:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case models.ErrImportArchiveTooLarge:
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case models.ErrImportInsufficientSpace, models.ErrStorageQuotaExceeded, models.ErrPhotoQuotaExceeded:
+		http.Error(w, err.Error(), http.StatusInsufficientStorage)
+	default:
+		log.Printf("Error handling import: %v", err)
+		http.Error(w, "Failed to process import", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/photosync/server/internal/blobstore"
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+	"github.com/photosync/server/internal/services"
+)
+
+// MediaHandler serves originals and thumbnails through signed media URLs
+type MediaHandler struct {
+	photoRepo        repository.PhotoRepo
+	storageService   *services.PhotoStorageService
+	thumbnailService *services.ThumbnailService
+	mediaURLs        *services.MediaURLService
+}
+
+// NewMediaHandler creates a new MediaHandler
+func NewMediaHandler(
+	photoRepo repository.PhotoRepo,
+	storageService *services.PhotoStorageService,
+	thumbnailService *services.ThumbnailService,
+	mediaURLs *services.MediaURLService,
+) *MediaHandler {
+	return &MediaHandler{
+		photoRepo:        photoRepo,
+		storageService:   storageService,
+		thumbnailService: thumbnailService,
+		mediaURLs:        mediaURLs,
+	}
+}
+
+// ServeMedia serves one variant of a photo from a signed URL
+// @Summary Get media from a signed URL
+// @Description Serve a photo's original or a thumbnail. The URL comes from the media field of sync, gallery, search and collection responses and needs no API key or session until it expires. Range requests and ETag revalidation are supported.
+// @Tags media
+// @Produce octet-stream
+// @Param id path string true "Photo ID"
+// @Param variant path string true "original, small, medium or large; gallery pages add a -nolocation or -nometadata suffix"
+// @Param exp query int true "Expiry (Unix seconds)"
+// @Param sig query string true "Signature"
+// @Success 200 {file} binary
+// @Success 206 {file} binary "Partial content"
+// @Success 304 "Not modified"
+// @Failure 403 {string} string "Invalid or expired URL"
+// @Failure 404 {string} string "Photo not found"
+// @Router /media/{id}/{variant} [get]
+func (h *MediaHandler) ServeMedia(w http.ResponseWriter, r *http.Request) {
+	photoID := chi.URLParam(r, "id")
+	variant := models.MediaVariant(chi.URLParam(r, "variant"))
+	if !variant.IsValid() {
+		http.Error(w, "Unknown media variant", http.StatusNotFound)
+		return
+	}
+
+	expires, err := h.mediaURLs.Verify(photoID, variant, r.URL.Query().Get("exp"), r.URL.Query().Get("sig"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	photo, err := h.photoRepo.GetByID(r.Context(), photoID)
+	if err != nil {
+		log.Printf("Error getting photo for media URL: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if photo == nil || photo.IsTrashed() {
+		http.Error(w, "Photo not found", http.StatusNotFound)
+		return
+	}
+
+	file, info, served, err := h.open(r, photo, variant)
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	// Originals keep their name so the content type follows the extension.
+	// Thumbnails are JPEGs, and so are stripped copies of HEIC originals.
+	name := photo.OriginalFilename
+	if served.Size() != models.MediaOriginal || served.Stripping().Strips() && services.IsHEIC(photo.StoredPath) {
+		name = photo.ID + ".jpg"
+		w.Header().Set("Content-Type", "image/jpeg")
+	}
+
+	// The bytes behind a variant only change if the photo is replaced, which
+	// gives it a new hash. Caches may keep the file until the URL expires.
+	maxAge := int(time.Until(expires).Seconds())
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	w.Header().Set("ETag", fmt.Sprintf(`"%s-%s"`, photo.FileHash, served))
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", maxAge))
+
+	// ServeContent handles Range, If-Range and If-None-Match
+	http.ServeContent(w, r, name, info.ModTime, file)
+}
+
+// open opens the requested variant and reports which one it is. A photo
+// without that thumbnail size is served from its original. Variants with
+// metadata stripped fall back to the stripped original, never to the stored
+// file.
+func (h *MediaHandler) open(r *http.Request, photo *models.Photo, variant models.MediaVariant) (io.ReadSeekCloser, *blobstore.ObjectInfo, models.MediaVariant, error) {
+	var thumbPath *string
+	switch variant.Size() {
+	case models.MediaSmall:
+		thumbPath = photo.ThumbSmall
+	case models.MediaMedium:
+		thumbPath = photo.ThumbMedium
+	case models.MediaLarge:
+		thumbPath = photo.ThumbLarge
+	}
+
+	if thumbPath != nil && *thumbPath != "" && h.thumbnailService != nil {
+		if file, info, err := h.thumbnailService.OpenThumbnail(r.Context(), *thumbPath); err == nil {
+			return file, info, variant, nil
+		}
+	}
+
+	if strip := variant.Stripping(); strip.Strips() {
+		original := strip.Variant(models.MediaOriginal)
+		if h.thumbnailService == nil {
+			return nil, nil, original, fmt.Errorf("cannot strip metadata without the thumbnail service")
+		}
+		path, err := h.thumbnailService.GetStrippedRendition(photo.ID, photo.StoredPath, photo.Orientation, strip)
+		if err != nil {
+			log.Printf("Warning: not serving %s of photo %s: %v", variant, photo.ID, err)
+			return nil, nil, original, err
+		}
+		file, info, err := h.thumbnailService.OpenThumbnail(r.Context(), path)
+		return file, info, original, err
+	}
+
+	file, info, err := h.storageService.Open(r.Context(), photo.StoredPath)
+	return file, info, models.MediaOriginal, err
+}
+
+// attachMediaURLs adds signed media URLs to photos when signing is enabled
+func attachMediaURLs(mediaURLs *services.MediaURLService, photos ...*models.Photo) {
+	if mediaURLs != nil {
+		mediaURLs.Attach(photos...)
+	}
+}
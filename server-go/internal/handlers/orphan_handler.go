@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -23,12 +22,13 @@ type OrphanHandler struct {
 	orphanFileRepo   repository.OrphanFileRepo
 	photoRepo        repository.PhotoRepo
 	deviceRepo       repository.DeviceRepo
-	storagePath      string
 	storageService   *services.PhotoStorageService
 	hashService      *services.HashService
 	exifService      *services.EXIFService
 	thumbnailService *services.ThumbnailService
 	metadataService  *services.MetadataService
+	quotaService     *services.QuotaService
+	libraryEvents    *services.LibraryEventService
 }
 
 // NewOrphanHandler creates a new OrphanHandler
@@ -36,7 +36,6 @@ func NewOrphanHandler(
 	orphanFileRepo repository.OrphanFileRepo,
 	photoRepo repository.PhotoRepo,
 	deviceRepo repository.DeviceRepo,
-	storagePath string,
 	storageService *services.PhotoStorageService,
 	hashService *services.HashService,
 	exifService *services.EXIFService,
@@ -47,7 +46,6 @@ func NewOrphanHandler(
 		orphanFileRepo:   orphanFileRepo,
 		photoRepo:        photoRepo,
 		deviceRepo:       deviceRepo,
-		storagePath:      storagePath,
 		storageService:   storageService,
 		hashService:      hashService,
 		exifService:      exifService,
@@ -56,6 +54,16 @@ func NewOrphanHandler(
 	}
 }
 
+// SetQuotaService enables quota checks when orphans are claimed into a library
+func (h *OrphanHandler) SetQuotaService(quotaService *services.QuotaService) {
+	h.quotaService = quotaService
+}
+
+// SetLibraryEvents enables real-time events for claimed and assigned orphans
+func (h *OrphanHandler) SetLibraryEvents(libraryEvents *services.LibraryEventService) {
+	h.libraryEvents = libraryEvents
+}
+
 // ====================
 // User Endpoints
 // ====================
@@ -113,11 +121,14 @@ func (h *OrphanHandler) ListMyOrphans(w http.ResponseWriter, r *http.Request) {
 // @Tags orphans
 // @Produce json
 // @Param id path string true "Orphan file ID"
-// @Param request body models.ClaimOrphanRequest false "Optional device ID"
+// @Param request body models.ClaimOrphanRequest false "Optional device ID and expected content hash"
 // @Success 200 {object} models.ClaimOrphanResponse
+// @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
 // @Failure 403 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse "File content does not match the expected or scanned hash"
+// @Failure 507 {object} models.ErrorResponse
 // @Security SessionAuth
 // @Router /api/web/orphans/{id}/claim [post]
 func (h *OrphanHandler) ClaimOrphan(w http.ResponseWriter, r *http.Request) {
@@ -156,8 +167,20 @@ func (h *OrphanHandler) ClaimOrphan(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create photo record from orphan
-	photo, err := h.createPhotoFromOrphan(r.Context(), orphan, user.ID, deviceID)
+	photo, err := h.createPhotoFromOrphan(r.Context(), orphan, user.ID, deviceID, req.ContentSHA256)
 	if err != nil {
+		if models.IsQuotaError(err) {
+			http.Error(w, err.Error(), http.StatusInsufficientStorage)
+			return
+		}
+		if err == models.ErrHashMismatch {
+			respondHashMismatch(w)
+			return
+		}
+		if err == models.ErrInvalidContentHash {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Failed to create photo: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -388,6 +411,10 @@ func (h *OrphanHandler) AdminAssignOrphan(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if h.libraryEvents != nil {
+		h.libraryEvents.Publish(req.UserID, models.NewOrphanAssignedEvent(orphanID))
+	}
+
 	// Fetch updated record
 	orphan, _ = h.orphanFileRepo.GetByID(r.Context(), orphanID)
 
@@ -421,10 +448,9 @@ func (h *OrphanHandler) AdminDeleteOrphan(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Delete file from disk
-	fullPath := filepath.Join(h.storagePath, orphan.FilePath)
-	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
-		http.Error(w, "Failed to delete file: "+err.Error(), http.StatusInternalServerError)
+	// Delete file from storage
+	if !h.storageService.Delete(orphan.FilePath) && h.storageService.Exists(orphan.FilePath) {
+		http.Error(w, "Failed to delete file", http.StatusInternalServerError)
 		return
 	}
 
@@ -479,6 +505,12 @@ func (h *OrphanHandler) AdminBulkAssignOrphans(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	if h.libraryEvents != nil && count > 0 {
+		for _, orphanID := range req.OrphanIDs {
+			h.libraryEvents.Publish(req.UserID, models.NewOrphanAssignedEvent(orphanID))
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]int{"assigned": count})
 }
@@ -516,8 +548,7 @@ func (h *OrphanHandler) AdminBulkDeleteOrphans(w http.ResponseWriter, r *http.Re
 			continue
 		}
 
-		fullPath := filepath.Join(h.storagePath, orphan.FilePath)
-		if err := os.Remove(fullPath); err == nil || os.IsNotExist(err) {
+		if h.storageService.Delete(orphan.FilePath) || !h.storageService.Exists(orphan.FilePath) {
 			deletedFiles++
 		}
 	}
@@ -546,6 +577,8 @@ func (h *OrphanHandler) AdminBulkDeleteOrphans(w http.ResponseWriter, r *http.Re
 // @Failure 401 {object} models.ErrorResponse
 // @Failure 403 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse "File content does not match the scanned hash"
+// @Failure 507 {object} models.ErrorResponse
 // @Security SessionAuth
 // @Router /api/admin/orphans/{id}/claim [post]
 func (h *OrphanHandler) AdminClaimOrphan(w http.ResponseWriter, r *http.Request) {
@@ -580,8 +613,16 @@ func (h *OrphanHandler) AdminClaimOrphan(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Create photo record from orphan
-	photo, err := h.createPhotoFromOrphan(r.Context(), orphan, req.UserID, req.DeviceID)
+	photo, err := h.createPhotoFromOrphan(r.Context(), orphan, req.UserID, req.DeviceID, "")
 	if err != nil {
+		if models.IsQuotaError(err) {
+			http.Error(w, err.Error(), http.StatusInsufficientStorage)
+			return
+		}
+		if err == models.ErrHashMismatch {
+			respondHashMismatch(w)
+			return
+		}
 		http.Error(w, "Failed to create photo: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -673,7 +714,7 @@ func (h *OrphanHandler) AdminBulkClaimOrphans(w http.ResponseWriter, r *http.Req
 		}
 
 		// Create photo record from orphan
-		photo, err := h.createPhotoFromOrphan(r.Context(), orphan, req.UserID, req.DeviceID)
+		photo, err := h.createPhotoFromOrphan(r.Context(), orphan, req.UserID, req.DeviceID, "")
 		if err != nil {
 			response.FailedCount++
 			response.Errors = append(response.Errors, models.BulkClaimOrphanError{
@@ -727,10 +768,8 @@ func (h *OrphanHandler) GetOrphanThumbnail(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	fullPath := filepath.Join(h.storagePath, orphan.FilePath)
-
 	// Read the file
-	content, err := os.ReadFile(fullPath)
+	content, err := h.storageService.ReadFile(orphan.FilePath)
 	if err != nil {
 		http.Error(w, "Failed to read file: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -809,25 +848,36 @@ func sanitizeDeviceName(name string) string {
 
 // createPhotoFromOrphan creates a photo record from an orphan file
 // It moves the file to a device-organized folder structure
-func (h *OrphanHandler) createPhotoFromOrphan(ctx context.Context, orphan *models.OrphanFile, userID, deviceID string) (*models.Photo, error) {
-	fullPath := filepath.Join(h.storagePath, orphan.FilePath)
-
+// The file is re-hashed and must still match both the hash recorded when it
+// was scanned and expectedHash, if given
+func (h *OrphanHandler) createPhotoFromOrphan(ctx context.Context, orphan *models.OrphanFile, userID, deviceID, expectedHash string) (*models.Photo, error) {
 	// Read file content
-	content, err := os.ReadFile(fullPath)
+	content, err := h.storageService.ReadFile(orphan.FilePath)
 	if err != nil {
 		return nil, err
 	}
 
-	// Compute hash (use existing if available)
+	// Compute hash (use existing if the hash service is unavailable)
 	var fileHash string
-	if orphan.FileHash != nil {
-		fileHash = *orphan.FileHash
-	} else if h.hashService != nil {
+	if h.hashService != nil {
 		fileHash = h.hashService.ComputeHashBytes(content)
+
+		// A file that changed since the scan may be truncated or half-written
+		if orphan.FileHash != nil {
+			if err := h.hashService.Verify(*orphan.FileHash, fileHash); err != nil {
+				log.Printf("Orphan %s changed since it was scanned: recorded %s, now %s", orphan.ID, *orphan.FileHash, fileHash)
+				return nil, err
+			}
+		}
+		if err := h.hashService.Verify(expectedHash, fileHash); err != nil {
+			return nil, err
+		}
+	} else if orphan.FileHash != nil {
+		fileHash = *orphan.FileHash
 	}
 
-	// Check for duplicate by hash
-	existing, err := h.photoRepo.GetByHash(ctx, fileHash)
+	// Check for duplicate by hash in the claiming user's library
+	existing, err := h.photoRepo.GetByHashAndUser(ctx, fileHash, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -835,9 +885,15 @@ func (h *OrphanHandler) createPhotoFromOrphan(ctx context.Context, orphan *model
 		return nil, models.ErrDuplicatePhoto
 	}
 
+	if h.quotaService != nil {
+		if err := h.quotaService.CheckUpload(ctx, userID, orphan.FileSize); err != nil {
+			return nil, err
+		}
+	}
+
 	// Extract EXIF metadata
 	var exifData *services.EXIFData
-	if h.exifService != nil {
+	if h.exifService != nil && !models.IsVideoFile(orphan.FilePath) {
 		exifData, _ = h.exifService.ExtractFromBytes(content)
 	}
 	if exifData == nil {
@@ -897,8 +953,14 @@ func (h *OrphanHandler) createPhotoFromOrphan(ctx context.Context, orphan *model
 	photo.Altitude = exifData.Altitude
 
 	// Generate thumbnails (using new stored path)
-	if h.thumbnailService != nil && services.IsSupportedFormat(originalFilename) {
-		thumbResult, err := h.thumbnailService.GenerateThumbnails(content, photo.ID, storedPath, exifData.Orientation)
+	if h.thumbnailService != nil && h.thumbnailService.CanGenerateThumbnails(originalFilename) {
+		var thumbResult *services.ThumbnailResult
+		var err error
+		if photo.IsVideo() {
+			thumbResult, err = h.thumbnailService.GenerateVideoThumbnails(photo.ID, storedPath)
+		} else {
+			thumbResult, err = h.thumbnailService.GenerateThumbnails(content, photo.ID, storedPath, exifData.Orientation)
+		}
 		if err != nil {
 			log.Printf("Warning: failed to generate thumbnails for claimed orphan: %v", err)
 		} else {
@@ -914,6 +976,12 @@ func (h *OrphanHandler) createPhotoFromOrphan(ctx context.Context, orphan *model
 	if err := h.photoRepo.Add(ctx, photo); err != nil {
 		return nil, err
 	}
+	if h.quotaService != nil {
+		h.quotaService.NotifyAdded(ctx, userID, photo.FileSize)
+	}
+	if h.libraryEvents != nil {
+		h.libraryEvents.Publish(userID, models.NewPhotoEvent(models.LibraryEventPhotoAdded, photo))
+	}
 
 	// Update embedded metadata to reflect new ownership
 	if h.metadataService != nil {
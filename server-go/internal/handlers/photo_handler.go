@@ -1,16 +1,17 @@
 package handlers
 
 import (
-	"bytes"
 	"encoding/json"
-	"io"
 	"log"
+	"mime"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/photosync/server/internal/middleware"
 	"github.com/photosync/server/internal/models"
 	"github.com/photosync/server/internal/repository"
 	"github.com/photosync/server/internal/services"
@@ -18,30 +19,30 @@ import (
 
 // PhotoHandler handles photo-related endpoints
 type PhotoHandler struct {
-	repo             repository.PhotoRepo
-	storageService   *services.PhotoStorageService
-	hashService      *services.HashService
-	exifService      *services.EXIFService
-	thumbnailService *services.ThumbnailService
-	metadataService  *services.MetadataService
+	repo                 repository.PhotoRepo
+	storageService       *services.PhotoStorageService
+	trashService         *services.TrashService
+	uploadService        *services.UploadService
+	uploadSessionService *services.UploadSessionService
+	uploadBatchService   *services.UploadBatchService
 }
 
 // NewPhotoHandler creates a new PhotoHandler
 func NewPhotoHandler(
 	repo repository.PhotoRepo,
 	storageService *services.PhotoStorageService,
-	hashService *services.HashService,
-	exifService *services.EXIFService,
-	thumbnailService *services.ThumbnailService,
-	metadataService *services.MetadataService,
+	trashService *services.TrashService,
+	uploadService *services.UploadService,
+	uploadSessionService *services.UploadSessionService,
+	uploadBatchService *services.UploadBatchService,
 ) *PhotoHandler {
 	return &PhotoHandler{
-		repo:             repo,
-		storageService:   storageService,
-		hashService:      hashService,
-		exifService:      exifService,
-		thumbnailService: thumbnailService,
-		metadataService:  metadataService,
+		repo:                 repo,
+		storageService:       storageService,
+		trashService:         trashService,
+		uploadService:        uploadService,
+		uploadSessionService: uploadSessionService,
+		uploadBatchService:   uploadBatchService,
 	}
 }
 
@@ -55,10 +56,14 @@ func NewPhotoHandler(
 // @Param originalFilename formData string false "Original filename (uses uploaded filename if not provided)"
 // @Param dateTaken formData string false "Date photo was taken (RFC3339 format)"
 // @Param deviceId formData string false "Device ID that originated the photo (for sync tracking)"
+// @Param contentSha256 formData string false "SHA-256 of the file as the device computed it; the X-Content-SHA256 header may be used instead"
+// @Param X-Content-SHA256 header string false "SHA-256 of the file as the device computed it"
 // @Success 200 {object} models.UploadResult "Photo uploaded successfully (or duplicate found)"
 // @Failure 400 {object} models.ErrorResponse "Invalid request"
 // @Failure 401 {object} models.ErrorResponse "Unauthorized - invalid API key"
+// @Failure 422 {object} models.ErrorResponse "Received content does not match the asserted hash (code hash_mismatch)"
 // @Failure 500 {object} models.ErrorResponse "Server error"
+// @Failure 507 {object} models.ErrorResponse "Storage quota exceeded"
 // @Security ApiKeyAuth
 // @Router /api/photos/upload [post]
 func (h *PhotoHandler) Upload(w http.ResponseWriter, r *http.Request) {
@@ -82,182 +87,44 @@ func (h *PhotoHandler) Upload(w http.ResponseWriter, r *http.Request) {
 		originalFilename = header.Filename
 	}
 
-	dateTakenStr := r.FormValue("dateTaken")
-	dateTaken := time.Now().UTC()
-	if dateTakenStr != "" {
+	var dateTaken *time.Time
+	if dateTakenStr := r.FormValue("dateTaken"); dateTakenStr != "" {
 		if parsed, err := time.Parse(time.RFC3339, dateTakenStr); err == nil {
-			dateTaken = parsed
+			dateTaken = &parsed
 		}
 	}
 
 	// Get device ID for origin tracking (optional)
 	deviceID := r.FormValue("deviceId")
 
-	// Read file content for hashing
-	content, err := io.ReadAll(file)
-	if err != nil {
-		h.respondError(w, http.StatusInternalServerError, "Failed to read file.")
-		return
-	}
-
-	// Compute hash
-	fileHash := h.hashService.ComputeHashBytes(content)
-
-	// Check for duplicate
-	existing, err := h.repo.GetByHash(r.Context(), fileHash)
-	if err != nil {
-		log.Printf("Error checking hash: %v", err)
-		h.respondError(w, http.StatusInternalServerError, "Database error.")
-		return
+	// The hash the device computed lets us catch truncated or corrupted transfers
+	expectedHash := r.Header.Get("X-Content-SHA256")
+	if expectedHash == "" {
+		expectedHash = r.FormValue("contentSha256")
 	}
 
-	if existing != nil {
-		log.Printf("Duplicate photo detected: %s", fileHash)
-		h.respondJSON(w, http.StatusOK, models.DuplicateUploadResult(
-			existing.ID,
-			existing.StoredPath,
-			existing.UploadedAt,
-		))
-		return
+	opts := services.UploadOptions{
+		OriginalFilename: originalFilename,
+		DateTaken:        dateTaken,
+		DeviceID:         deviceID,
+		ExpectedHash:     expectedHash,
 	}
-
-	// Extract EXIF metadata
-	exifData, err := h.exifService.ExtractFromBytes(content)
-	if err != nil {
-		log.Printf("Warning: failed to extract EXIF data: %v", err)
-		exifData = &services.EXIFData{Orientation: 1}
+	if user := middleware.GetUserFromContext(r.Context()); user != nil {
+		opts.UserID = user.ID
 	}
 
-	// Use EXIF date if available and no date was provided
-	if dateTakenStr == "" && exifData.DateTaken != nil {
-		dateTaken = *exifData.DateTaken
-	}
-
-	// Store the file
-	storedPath, err := h.storageService.Store(
-		bytes.NewReader(content),
-		originalFilename,
-		dateTaken,
-		int64(len(content)),
-	)
+	result, err := h.uploadService.Process(r.Context(), file, header.Size, opts)
 	if err != nil {
-		log.Printf("Error storing file: %v", err)
-		switch err {
-		case models.ErrFileTooLarge:
-			h.respondError(w, http.StatusBadRequest, err.Error())
-		case models.ErrInvalidExtension:
-			h.respondError(w, http.StatusBadRequest, err.Error())
-		default:
-			h.respondError(w, http.StatusInternalServerError, "Failed to store file.")
-		}
-		return
-	}
-
-	// Create database record
-	photo, err := models.NewPhoto(originalFilename, storedPath, fileHash, int64(len(content)), dateTaken)
-	if err != nil {
-		h.storageService.Delete(storedPath) // Clean up
-		h.respondError(w, http.StatusBadRequest, err.Error())
-		return
-	}
-
-	// Copy EXIF metadata to photo
-	photo.CameraMake = exifData.CameraMake
-	photo.CameraModel = exifData.CameraModel
-	photo.LensModel = exifData.LensModel
-	photo.FocalLength = exifData.FocalLength
-	photo.Aperture = exifData.Aperture
-	photo.ShutterSpeed = exifData.ShutterSpeed
-	photo.ISO = exifData.ISO
-	photo.Orientation = exifData.Orientation
-	photo.Latitude = exifData.Latitude
-	photo.Longitude = exifData.Longitude
-	photo.Altitude = exifData.Altitude
-
-	// Set origin device if provided
-	if deviceID != "" {
-		photo.OriginDeviceID = &deviceID
-	}
-
-	// Generate thumbnails (if supported format)
-	if services.IsSupportedFormat(originalFilename) {
-		thumbResult, err := h.thumbnailService.GenerateThumbnails(content, photo.ID, storedPath, exifData.Orientation)
-		if err != nil {
-			log.Printf("Warning: failed to generate thumbnails: %v", err)
-		} else {
-			photo.ThumbSmall = &thumbResult.SmallPath
-			photo.ThumbMedium = &thumbResult.MediumPath
-			photo.ThumbLarge = &thumbResult.LargePath
-			photo.Width = &thumbResult.Width
-			photo.Height = &thumbResult.Height
-		}
-	} else if services.IsHEIC(originalFilename) {
-		log.Printf("HEIC file detected, thumbnail generation skipped (requires libheif)")
-	}
-
-	if err := h.repo.Add(r.Context(), photo); err != nil {
-		h.storageService.Delete(storedPath) // Clean up
-		if photo.ThumbSmall != nil {
-			h.thumbnailService.DeleteThumbnails(*photo.ThumbSmall, *photo.ThumbMedium, *photo.ThumbLarge)
-		}
-
-		// Check if this is a unique constraint violation (race condition with concurrent upload)
-		errStr := err.Error()
-		if strings.Contains(errStr, "duplicate key") || strings.Contains(errStr, "UNIQUE constraint") {
-			log.Printf("Duplicate detected via constraint for hash: %s", fileHash)
-			// Look up the existing record
-			existing, lookupErr := h.repo.GetByHash(r.Context(), fileHash)
-			if lookupErr == nil && existing != nil {
-				h.respondJSON(w, http.StatusOK, models.DuplicateUploadResult(
-					existing.ID,
-					existing.StoredPath,
-					existing.UploadedAt,
-				))
-				return
-			}
-		}
-
-		log.Printf("Error saving to database: %v", err)
-		h.respondError(w, http.StatusInternalServerError, "Failed to save photo record.")
+		h.respondUploadError(w, err)
 		return
 	}
 
-	// Embed full metadata in file for cross-referencing and integrity (non-blocking)
-	if h.metadataService != nil {
-		// Capture values for goroutine
-		photoID := photo.ID
-		userID := ""
-		if photo.UserID != nil {
-			userID = *photo.UserID
-		}
-		originDeviceID := ""
-		if photo.OriginDeviceID != nil {
-			originDeviceID = *photo.OriginDeviceID
-		}
-		uploadedAt := photo.UploadedAt
-
-		go func() {
-			metadata := services.PhotoMetadata{
-				PhotoID:    photoID,
-				UserID:     userID,
-				DeviceID:   originDeviceID,
-				FileHash:   fileHash,
-				UploadedAt: uploadedAt,
-			}
-			if err := h.metadataService.EmbedFullMetadata(storedPath, metadata); err != nil {
-				log.Printf("Warning: failed to embed metadata for %s: %v", photoID, err)
-			}
-		}()
-	}
-
-	log.Printf("Photo uploaded: %s -> %s (GPS: %v)", photo.ID, storedPath, photo.Latitude != nil)
-
-	h.respondJSON(w, http.StatusOK, models.NewUploadResult(photo.ID, storedPath, photo.UploadedAt))
+	h.respondJSON(w, http.StatusOK, result)
 }
 
 // CheckHashes checks which hashes already exist
 // @Summary Check if photos exist by hash
-// @Description Check which SHA256 hashes already exist on the server. Useful for avoiding duplicate uploads.
+// @Description Check which SHA256 hashes already exist in the caller's library. Useful for avoiding duplicate uploads.
 // @Tags photos
 // @Accept json
 // @Produce json
@@ -297,7 +164,14 @@ func (h *PhotoHandler) CheckHashes(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	existing, err := h.repo.GetExistingHashes(r.Context(), normalized)
+	// Only the caller's own photos count; content owned by other users still needs uploading
+	var existing []string
+	var err error
+	if user := middleware.GetUserFromContext(r.Context()); user != nil {
+		existing, err = h.repo.GetExistingHashesForUser(r.Context(), normalized, user.ID)
+	} else {
+		existing, err = h.repo.GetExistingHashes(r.Context(), normalized)
+	}
 	if err != nil {
 		log.Printf("Error checking hashes: %v", err)
 		h.respondError(w, http.StatusInternalServerError, "Database error.")
@@ -412,12 +286,12 @@ func (h *PhotoHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	h.respondJSON(w, http.StatusOK, models.PhotoToResponse(photo))
 }
 
-// Delete removes a photo by ID
+// Delete moves a photo to the trash by ID
 // @Summary Delete a photo
-// @Description Delete a photo by its ID. This removes both the database record and the file.
+// @Description Move a photo to the trash by its ID. The file is permanently removed when the trash is emptied or the retention period ends.
 // @Tags photos
 // @Param id path string true "Photo ID (UUID)"
-// @Success 204 "Photo deleted successfully"
+// @Success 204 "Photo moved to trash"
 // @Failure 400 {object} models.ErrorResponse "Invalid request"
 // @Failure 401 {object} models.ErrorResponse "Unauthorized - invalid API key"
 // @Failure 404 {object} models.ErrorResponse "Photo not found"
@@ -443,36 +317,372 @@ func (h *PhotoHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Delete file and thumbnails
-	h.storageService.Delete(photo.StoredPath)
-	if photo.ThumbSmall != nil || photo.ThumbMedium != nil || photo.ThumbLarge != nil {
-		var small, medium, large string
-		if photo.ThumbSmall != nil {
-			small = *photo.ThumbSmall
+	// Move to trash; the file and thumbnails are purged once the retention period ends
+	if err := h.trashService.MoveToTrash(r.Context(), id); err != nil {
+		if err == models.ErrPhotoNotFound {
+			h.respondError(w, http.StatusNotFound, "Photo not found.")
+			return
 		}
-		if photo.ThumbMedium != nil {
-			medium = *photo.ThumbMedium
+		log.Printf("Error trashing photo: %v", err)
+		h.respondError(w, http.StatusInternalServerError, "Database error.")
+		return
+	}
+
+	log.Printf("Photo moved to trash: %s", id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateUploadSession starts a resumable upload
+// @Summary Start a resumable upload
+// @Description Create an upload session for a large file. Send the file in chunks with PATCH, then complete the session.
+// @Tags photos
+// @Accept json
+// @Produce json
+// @Param request body models.CreateUploadSessionRequest true "File details"
+// @Success 201 {object} models.UploadSessionResponse "Upload session created"
+// @Failure 400 {object} models.ErrorResponse "Invalid request"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized - invalid API key"
+// @Failure 500 {object} models.ErrorResponse "Server error"
+// @Failure 507 {object} models.ErrorResponse "Storage quota exceeded"
+// @Security ApiKeyAuth
+// @Router /api/photos/uploads [post]
+func (h *PhotoHandler) CreateUploadSession(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized.")
+		return
+	}
+
+	var req models.CreateUploadSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body.")
+		return
+	}
+
+	// Reject files the storage would refuse before any bytes are sent
+	if err := h.storageService.ValidateUpload(req.OriginalFilename, req.TotalSize); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := user.CheckQuota(req.TotalSize); err != nil {
+		h.respondError(w, http.StatusInsufficientStorage, err.Error())
+		return
+	}
+
+	session, err := h.uploadSessionService.Create(user.ID, &req)
+	if err != nil {
+		if _, ok := err.(models.PhotoError); ok {
+			h.respondError(w, http.StatusBadRequest, err.Error())
+			return
 		}
-		if photo.ThumbLarge != nil {
-			large = *photo.ThumbLarge
+		log.Printf("Error creating upload session: %v", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to create upload session.")
+		return
+	}
+
+	w.Header().Set("Location", "/api/photos/uploads/"+session.ID)
+	w.Header().Set("Upload-Offset", "0")
+	h.respondJSON(w, http.StatusCreated, session.ToResponse())
+}
+
+// GetUploadSession returns the state of a resumable upload
+// @Summary Get upload session status
+// @Description Get the current offset of a resumable upload so an interrupted client knows where to resume. HEAD returns only the Upload-Offset header.
+// @Tags photos
+// @Produce json
+// @Param id path string true "Upload session ID"
+// @Success 200 {object} models.UploadSessionResponse "Upload session state"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized - invalid API key"
+// @Failure 404 {object} models.ErrorResponse "Upload session not found"
+// @Failure 410 {object} models.ErrorResponse "Upload session expired"
+// @Security ApiKeyAuth
+// @Router /api/photos/uploads/{id} [get]
+func (h *PhotoHandler) GetUploadSession(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized.")
+		return
+	}
+
+	session, err := h.uploadSessionService.Get(chi.URLParam(r, "id"), user.ID)
+	if err != nil {
+		h.respondUploadSessionError(w, err)
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(session.TotalSize, 10))
+	w.Header().Set("Cache-Control", "no-store")
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, session.ToResponse())
+}
+
+// UploadChunk appends a chunk to a resumable upload
+// @Summary Upload a chunk
+// @Description Append raw bytes to an upload session. The Upload-Offset header must match the session's current offset.
+// @Tags photos
+// @Accept application/offset+octet-stream
+// @Produce json
+// @Param id path string true "Upload session ID"
+// @Param Upload-Offset header int true "Byte offset this chunk starts at"
+// @Success 200 {object} models.UploadSessionResponse "Chunk accepted"
+// @Failure 400 {object} models.ErrorResponse "Invalid request"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized - invalid API key"
+// @Failure 404 {object} models.ErrorResponse "Upload session not found"
+// @Failure 409 {object} models.ErrorResponse "Offset does not match"
+// @Failure 410 {object} models.ErrorResponse "Upload session expired"
+// @Security ApiKeyAuth
+// @Router /api/photos/uploads/{id} [patch]
+func (h *PhotoHandler) UploadChunk(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized.")
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		h.respondError(w, http.StatusBadRequest, "A valid Upload-Offset header is required.")
+		return
+	}
+
+	session, err := h.uploadSessionService.WriteChunk(chi.URLParam(r, "id"), user.ID, offset, r.Body)
+	if session != nil {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	}
+	if err != nil {
+		h.respondUploadSessionError(w, err)
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, session.ToResponse())
+}
+
+// CompleteUploadSession finalizes a resumable upload
+// @Summary Complete a resumable upload
+// @Description Run the uploaded file through duplicate detection, EXIF extraction, storage and thumbnail generation.
+// @Tags photos
+// @Produce json
+// @Param id path string true "Upload session ID"
+// @Param X-Content-SHA256 header string false "SHA-256 of the whole file, if not given when the session was created"
+// @Success 200 {object} models.UploadResult "Photo uploaded successfully (or duplicate found)"
+// @Failure 400 {object} models.ErrorResponse "Invalid request"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized - invalid API key"
+// @Failure 404 {object} models.ErrorResponse "Upload session not found"
+// @Failure 409 {object} models.ErrorResponse "Upload is not complete"
+// @Failure 422 {object} models.ErrorResponse "Assembled file does not match the asserted hash (code hash_mismatch); the session is discarded"
+// @Failure 500 {object} models.ErrorResponse "Server error"
+// @Failure 507 {object} models.ErrorResponse "Storage quota exceeded"
+// @Security ApiKeyAuth
+// @Router /api/photos/uploads/{id}/complete [post]
+func (h *PhotoHandler) CompleteUploadSession(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized.")
+		return
+	}
+
+	var result *models.UploadResult
+	var processErr error
+	err := h.uploadSessionService.Complete(chi.URLParam(r, "id"), user.ID, func(session *models.UploadSession, file *os.File, fileHash string) error {
+		expectedHash := r.Header.Get("X-Content-SHA256")
+		if expectedHash == "" {
+			expectedHash = session.ContentSHA256
 		}
-		h.thumbnailService.DeleteThumbnails(small, medium, large)
+
+		result, processErr = h.uploadService.Process(r.Context(), file, session.TotalSize, services.UploadOptions{
+			OriginalFilename: session.OriginalFilename,
+			DateTaken:        session.DateTaken,
+			DeviceID:         session.DeviceID,
+			UserID:           user.ID,
+			ExpectedHash:     expectedHash,
+			FileHash:         fileHash,
+		})
+		return processErr
+	})
+	if processErr != nil {
+		// A hash mismatch discards the session, so the client starts over
+		h.respondUploadError(w, processErr)
+		return
+	}
+	if err != nil {
+		h.respondUploadSessionError(w, err)
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// CancelUploadSession abandons a resumable upload
+// @Summary Cancel a resumable upload
+// @Description Delete an upload session and any data received so far
+// @Tags photos
+// @Param id path string true "Upload session ID"
+// @Success 204 "Upload session deleted"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized - invalid API key"
+// @Failure 404 {object} models.ErrorResponse "Upload session not found"
+// @Security ApiKeyAuth
+// @Router /api/photos/uploads/{id} [delete]
+func (h *PhotoHandler) CancelUploadSession(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized.")
+		return
+	}
+
+	if err := h.uploadSessionService.Delete(chi.URLParam(r, "id"), user.ID); err != nil {
+		h.respondUploadSessionError(w, err)
+		return
 	}
 
-	// Delete from database
-	deleted, err := h.repo.Delete(r.Context(), id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateUploadBatch accepts a backup manifest
+// @Summary Start a batch upload
+// @Description Post a manifest of files (hash, size, filename, dateTaken). The response lists which hashes the server needs and an upload token for sending them to /api/photos/batches/{token}/files.
+// @Tags photos
+// @Accept json
+// @Produce json
+// @Param request body models.CreateUploadBatchRequest true "Manifest (max 5000 files)"
+// @Success 201 {object} models.UploadBatchResponse "Batch created"
+// @Failure 400 {object} models.ErrorResponse "Invalid request"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized - invalid API key"
+// @Failure 500 {object} models.ErrorResponse "Server error"
+// @Security ApiKeyAuth
+// @Router /api/photos/batches [post]
+func (h *PhotoHandler) CreateUploadBatch(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized.")
+		return
+	}
+
+	var req models.CreateUploadBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body.")
+		return
+	}
+
+	batch, err := h.uploadBatchService.Create(r.Context(), user.ID, &req)
 	if err != nil {
-		log.Printf("Error deleting photo: %v", err)
-		h.respondError(w, http.StatusInternalServerError, "Database error.")
+		h.respondUploadBatchError(w, err)
 		return
 	}
 
-	if !deleted {
-		h.respondError(w, http.StatusNotFound, "Photo not found.")
+	w.Header().Set("Location", "/api/photos/batches/"+batch.ID)
+	h.respondJSON(w, http.StatusCreated, batch.ToResponse())
+}
+
+// GetUploadBatch returns the progress of a batch upload
+// @Summary Get batch upload status
+// @Description Get which manifest entries are still needed, so an interrupted client knows what to send next
+// @Tags photos
+// @Produce json
+// @Param token path string true "Upload token"
+// @Success 200 {object} models.UploadBatchResponse "Batch state"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized - invalid API key"
+// @Failure 404 {object} models.ErrorResponse "Batch not found"
+// @Failure 410 {object} models.ErrorResponse "Batch expired"
+// @Security ApiKeyAuth
+// @Router /api/photos/batches/{token} [get]
+func (h *PhotoHandler) GetUploadBatch(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized.")
+		return
+	}
+
+	batch, err := h.uploadBatchService.Get(chi.URLParam(r, "token"), user.ID)
+	if err != nil {
+		h.respondUploadBatchError(w, err)
 		return
 	}
 
-	log.Printf("Photo deleted: %s", id)
+	w.Header().Set("Cache-Control", "no-store")
+	h.respondJSON(w, http.StatusOK, batch.ToResponse())
+}
+
+// UploadBatchFiles streams files for a batch upload
+// @Summary Upload batch files
+// @Description Stream many files in one multipart/form-data or application/x-tar body. Files are matched to the manifest by content hash and processed in parallel; the response reports a result per file. Needed files can be spread over several requests. A part's X-Content-SHA256 header (or a tar entry's PHOTOSYNC.sha256 PAX record) names the entry a file is meant to be, so a corrupted transfer is reported against it.
+// @Tags photos
+// @Accept multipart/form-data
+// @Accept application/x-tar
+// @Produce json
+// @Param token path string true "Upload token"
+// @Success 200 {object} models.UploadBatchResult "Per-file results"
+// @Failure 400 {object} models.ErrorResponse "Invalid request"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized - invalid API key"
+// @Failure 404 {object} models.ErrorResponse "Batch not found"
+// @Failure 410 {object} models.ErrorResponse "Batch expired"
+// @Failure 415 {object} models.ErrorResponse "Unsupported content type"
+// @Security ApiKeyAuth
+// @Router /api/photos/batches/{token}/files [post]
+func (h *PhotoHandler) UploadBatchFiles(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized.")
+		return
+	}
+
+	useProgressDeadlineBody(w, r)
+
+	var src services.BatchFileSource
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "multipart/form-data":
+		reader, err := r.MultipartReader()
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, "Invalid multipart body.")
+			return
+		}
+		src = services.NewMultipartBatchSource(reader)
+	case "application/x-tar", "application/tar":
+		src = services.NewTarBatchSource(r.Body)
+	default:
+		h.respondError(w, http.StatusUnsupportedMediaType, "Request must be multipart/form-data or application/x-tar.")
+		return
+	}
+
+	result, err := h.uploadBatchService.Process(r.Context(), chi.URLParam(r, "token"), user.ID, src)
+	if err != nil {
+		if result == nil {
+			h.respondUploadBatchError(w, err)
+			return
+		}
+		// Files received before the body broke off are kept and reported
+		log.Printf("Batch upload %s ended early: %v", result.UploadToken, err)
+	}
+
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// CancelUploadBatch abandons a batch upload
+// @Summary Cancel a batch upload
+// @Description Delete a batch manifest. Files already uploaded stay in the library.
+// @Tags photos
+// @Param token path string true "Upload token"
+// @Success 204 "Batch deleted"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized - invalid API key"
+// @Failure 404 {object} models.ErrorResponse "Batch not found"
+// @Security ApiKeyAuth
+// @Router /api/photos/batches/{token} [delete]
+func (h *PhotoHandler) CancelUploadBatch(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized.")
+		return
+	}
+
+	if err := h.uploadBatchService.Delete(chi.URLParam(r, "token"), user.ID); err != nil {
+		h.respondUploadBatchError(w, err)
+		return
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -488,3 +698,62 @@ func (h *PhotoHandler) respondJSON(w http.ResponseWriter, status int, data inter
 func (h *PhotoHandler) respondError(w http.ResponseWriter, status int, message string) {
 	h.respondJSON(w, status, models.ErrorResponse{Error: message})
 }
+
+// respondUploadError maps errors from the upload pipeline to HTTP responses
+func (h *PhotoHandler) respondUploadError(w http.ResponseWriter, err error) {
+	switch err {
+	case models.ErrFileTooLarge, models.ErrInvalidExtension,
+		models.ErrEmptyFilename, models.ErrInvalidFileSize, models.ErrInvalidContentHash:
+		h.respondError(w, http.StatusBadRequest, err.Error())
+	case models.ErrHashMismatch:
+		respondHashMismatch(w)
+	case models.ErrStorageQuotaExceeded, models.ErrPhotoQuotaExceeded:
+		h.respondError(w, http.StatusInsufficientStorage, err.Error())
+	default:
+		log.Printf("Error processing upload: %v", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to store file.")
+	}
+}
+
+// respondHashMismatch tells the client the file it sent is not the one it
+// described, with a code it can act on wherever the file was sent
+func respondHashMismatch(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Error: models.ErrHashMismatch.Error(),
+		Code:  models.ErrorCodeHashMismatch,
+	})
+}
+
+// respondUploadSessionError maps upload session errors to HTTP responses
+func (h *PhotoHandler) respondUploadSessionError(w http.ResponseWriter, err error) {
+	switch err {
+	case models.ErrUploadSessionNotFound:
+		h.respondError(w, http.StatusNotFound, err.Error())
+	case models.ErrUploadSessionExpired:
+		h.respondError(w, http.StatusGone, err.Error())
+	case models.ErrUploadOffsetMismatch, models.ErrUploadIncomplete:
+		h.respondError(w, http.StatusConflict, err.Error())
+	case models.ErrUploadChunkTooLarge:
+		h.respondError(w, http.StatusRequestEntityTooLarge, err.Error())
+	default:
+		log.Printf("Error handling upload session: %v", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to process upload session.")
+	}
+}
+
+// respondUploadBatchError maps upload batch errors to HTTP responses
+func (h *PhotoHandler) respondUploadBatchError(w http.ResponseWriter, err error) {
+	switch err {
+	case models.ErrUploadBatchNotFound:
+		h.respondError(w, http.StatusNotFound, err.Error())
+	case models.ErrUploadBatchExpired:
+		h.respondError(w, http.StatusGone, err.Error())
+	case models.ErrUploadBatchEmpty, models.ErrUploadBatchTooLarge:
+		h.respondError(w, http.StatusBadRequest, err.Error())
+	default:
+		log.Printf("Error handling upload batch: %v", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to process upload batch.")
+	}
+}
@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"fmt"
 	"html/template"
 	"io"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/photosync/server/internal/models"
@@ -19,7 +22,11 @@ type PublicGalleryHandler struct {
 	collectionRepo      repository.CollectionRepo
 	collectionPhotoRepo repository.CollectionPhotoRepo
 	photoRepo           repository.PhotoRepo
-	storagePath         string
+	thumbnailService    *services.ThumbnailService
+	storageService      *services.PhotoStorageService
+	linkService         *services.CollectionLinkService
+	mediaURLs           *services.MediaURLService
+	downloads           *services.GalleryDownloadService
 	templatePath        string
 }
 
@@ -29,7 +36,8 @@ func NewPublicGalleryHandler(
 	collectionRepo repository.CollectionRepo,
 	collectionPhotoRepo repository.CollectionPhotoRepo,
 	photoRepo repository.PhotoRepo,
-	storagePath string,
+	thumbnailService *services.ThumbnailService,
+	storageService *services.PhotoStorageService,
 	templatePath string,
 ) *PublicGalleryHandler {
 	return &PublicGalleryHandler{
@@ -37,11 +45,29 @@ func NewPublicGalleryHandler(
 		collectionRepo:      collectionRepo,
 		collectionPhotoRepo: collectionPhotoRepo,
 		photoRepo:           photoRepo,
-		storagePath:         storagePath,
+		thumbnailService:    thumbnailService,
+		storageService:      storageService,
 		templatePath:        templatePath,
 	}
 }
 
+// SetCollectionLinks enables share links with passwords, expiry and view limits
+func (h *PublicGalleryHandler) SetCollectionLinks(linkService *services.CollectionLinkService) {
+	h.linkService = linkService
+}
+
+// SetMediaURLs makes gallery pages load photos through signed media URLs.
+// Galleries opened through a share link need them when the collection is
+// not public.
+func (h *PublicGalleryHandler) SetMediaURLs(mediaURLs *services.MediaURLService) {
+	h.mediaURLs = mediaURLs
+}
+
+// SetDownloads lets visitors download galleries whose owner allows it as a ZIP
+func (h *PublicGalleryHandler) SetDownloads(downloads *services.GalleryDownloadService) {
+	h.downloads = downloads
+}
+
 // ViewGalleryBySlug serves the public gallery page by slug
 func (h *PublicGalleryHandler) ViewGalleryBySlug(w http.ResponseWriter, r *http.Request) {
 	slug := chi.URLParam(r, "slug")
@@ -63,27 +89,229 @@ func (h *PublicGalleryHandler) ViewGalleryBySlug(w http.ResponseWriter, r *http.
 	h.renderGallery(w, r, collection)
 }
 
-// ViewGalleryByToken serves the gallery page via secret link
+// ViewGalleryByToken serves the gallery page via secret link. Share links
+// are checked for revocation, expiry and their view limit, and a password
+// prompt is shown until the visitor has entered the link's password. A
+// collection's own secret token is a share link too.
 func (h *PublicGalleryHandler) ViewGalleryByToken(w http.ResponseWriter, r *http.Request) {
 	token := chi.URLParam(r, "token")
-	if token == "" {
+	if token == "" || h.linkService == nil {
+		http.Error(w, "Gallery not found", http.StatusNotFound)
+		return
+	}
+
+	link, collection, err := h.linkService.Open(r.Context(), token)
+	if err == models.ErrCollectionLinkNotFound {
+		http.Error(w, "Gallery not found", http.StatusNotFound)
+		return
+	}
+	h.serveLink(w, r, link, collection, err)
+}
+
+// UnlockGalleryByToken checks the password of a share link
+func (h *PublicGalleryHandler) UnlockGalleryByToken(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	if token == "" || h.linkService == nil {
 		http.Error(w, "Gallery not found", http.StatusNotFound)
 		return
 	}
 
-	collection, err := h.collectionService.GetCollectionBySecretToken(r.Context(), token)
+	link, collection, err := h.linkService.Open(r.Context(), token)
+	if err == models.ErrCollectionLinkNotFound {
+		http.Error(w, "Gallery not found", http.StatusNotFound)
+		return
+	}
+	if link == nil {
+		log.Printf("Error opening share link: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		h.refuseLink(w, r, link, err)
+		return
+	}
+
+	value, until, ok := h.linkService.Unlock(link, r.PostFormValue("password"))
+	if !ok {
+		h.linkService.RecordAccess(r.Context(), link, getClientIP(r), r.UserAgent(), models.LinkAccessWrongPassword)
+		h.renderPasswordPrompt(w, r, collection, "Incorrect password", http.StatusUnauthorized)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     linkUnlockCookie(link),
+		Value:    value,
+		Path:     "/gallery/s/" + token,
+		Expires:  until,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+}
+
+// serveLink renders the gallery behind a share link if the visitor may see
+// it. Every visit is logged against the link.
+func (h *PublicGalleryHandler) serveLink(w http.ResponseWriter, r *http.Request, link *models.CollectionLink, collection *models.Collection, err error) {
+	if link == nil {
+		log.Printf("Error opening share link: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
 	if err != nil {
-		if err == models.ErrCollectionNotFound {
-			http.Error(w, "Gallery not found", http.StatusNotFound)
+		h.refuseLink(w, r, link, err)
+		return
+	}
+
+	if !h.linkUnlocked(r, link) {
+		h.linkService.RecordAccess(r.Context(), link, getClientIP(r), r.UserAgent(), models.LinkAccessPasswordRequired)
+		h.renderPasswordPrompt(w, r, collection, "", http.StatusOK)
+		return
+	}
+
+	if err := h.linkService.CountView(r.Context(), link); err != nil {
+		if err == models.ErrCollectionLinkViewLimit {
+			h.refuseLink(w, r, link, err)
 			return
 		}
+		log.Printf("Error counting share link view: %v", err)
 		http.Error(w, "Internal error", http.StatusInternalServerError)
 		return
 	}
 
+	h.linkService.RecordAccess(r.Context(), link, getClientIP(r), r.UserAgent(), models.LinkAccessGranted)
 	h.renderGallery(w, r, collection)
 }
 
+// refuseLink logs and answers a visit to a link that can no longer be used
+func (h *PublicGalleryHandler) refuseLink(w http.ResponseWriter, r *http.Request, link *models.CollectionLink, err error) {
+	h.linkService.RecordAccess(r.Context(), link, getClientIP(r), r.UserAgent(), models.AccessOutcome(err))
+	switch err {
+	case models.ErrCollectionLinkExpired:
+		http.Error(w, "This link has expired", http.StatusGone)
+	case models.ErrCollectionLinkViewLimit:
+		http.Error(w, "This link has reached its view limit", http.StatusGone)
+	default:
+		http.Error(w, "Gallery not found", http.StatusNotFound)
+	}
+}
+
+// linkUnlocked reports whether the visitor has entered the link's password,
+// or the link has none
+func (h *PublicGalleryHandler) linkUnlocked(r *http.Request, link *models.CollectionLink) bool {
+	if !link.HasPassword {
+		return true
+	}
+	cookie, _ := r.Cookie(linkUnlockCookie(link))
+	return cookie != nil && h.linkService.IsUnlocked(link, cookie.Value)
+}
+
+// linkUnlockCookie names the cookie that remembers a link's password was entered
+func linkUnlockCookie(link *models.CollectionLink) string {
+	return "ps_link_" + link.ID
+}
+
+// DownloadGalleryBySlug streams a public gallery as a ZIP
+func (h *PublicGalleryHandler) DownloadGalleryBySlug(w http.ResponseWriter, r *http.Request) {
+	collection, err := h.collectionService.GetCollectionBySlug(r.Context(), chi.URLParam(r, "slug"))
+	if err != nil {
+		if err == models.ErrCollectionNotFound || err == models.ErrCollectionAccessDenied {
+			http.Error(w, "Gallery not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	size, ok := h.checkDownload(w, r, collection)
+	if !ok {
+		return
+	}
+	h.writeDownload(w, r, collection, size)
+}
+
+// DownloadGalleryByToken streams the gallery behind a secret link as a ZIP.
+// A share link must still be usable and, if it has a password, unlocked.
+// Downloads are logged against the link but do not count as views.
+func (h *PublicGalleryHandler) DownloadGalleryByToken(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	if token == "" || h.linkService == nil {
+		http.Error(w, "Gallery not found", http.StatusNotFound)
+		return
+	}
+
+	link, collection, err := h.linkService.Open(r.Context(), token)
+	if err == models.ErrCollectionLinkNotFound {
+		http.Error(w, "Gallery not found", http.StatusNotFound)
+		return
+	}
+	if link == nil {
+		log.Printf("Error opening share link: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		h.refuseLink(w, r, link, err)
+		return
+	}
+	if !h.linkUnlocked(r, link) {
+		http.Redirect(w, r, "/gallery/s/"+token, http.StatusSeeOther)
+		return
+	}
+
+	size, ok := h.checkDownload(w, r, collection)
+	if !ok {
+		return
+	}
+	h.linkService.RecordAccess(r.Context(), link, getClientIP(r), r.UserAgent(), models.LinkAccessGranted)
+	h.writeDownload(w, r, collection, size)
+}
+
+// checkDownload answers the request itself unless the collection may be
+// downloaded in the requested size now
+func (h *PublicGalleryHandler) checkDownload(w http.ResponseWriter, r *http.Request, collection *models.Collection) (models.GalleryDownloadSize, bool) {
+	if h.downloads == nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return "", false
+	}
+
+	size, err := models.ParseGalleryDownloadSize(r.URL.Query().Get("size"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+
+	switch err := h.downloads.Check(collection, getClientIP(r)); err {
+	case nil:
+		return size, true
+	case models.ErrGalleryDownloadsDisabled:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case models.ErrGalleryDownloadRateLimited:
+		w.Header().Set("Retry-After", "3600")
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+	}
+	return "", false
+}
+
+// writeDownload streams the ZIP. Errors after the headers are sent can only
+// truncate the archive.
+func (h *PublicGalleryHandler) writeDownload(w http.ResponseWriter, r *http.Request, collection *models.Collection, size models.GalleryDownloadSize) {
+	w = newProgressDeadlineWriter(w)
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", collection.Slug+".zip"))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	count, err := h.downloads.Write(r.Context(), w, collection, size)
+	if err != nil {
+		log.Printf("Gallery download of collection %s aborted: %v", collection.ID, err)
+		return
+	}
+	log.Printf("Gallery download of collection %s: %d photos (%s) to %s", collection.ID, count, size, getClientIP(r))
+}
+
 // ServeGalleryImage serves an image from a public gallery
 func (h *PublicGalleryHandler) ServeGalleryImage(w http.ResponseWriter, r *http.Request) {
 	photoID := chi.URLParam(r, "photoId")
@@ -101,8 +329,10 @@ func (h *PublicGalleryHandler) ServeGalleryImage(w http.ResponseWriter, r *http.
 		return
 	}
 
-	// Check visibility
-	if collection.Visibility != models.VisibilityPublic && collection.Visibility != models.VisibilitySecretLink {
+	// Only public galleries load images by collection ID. Galleries behind a
+	// share link use signed media URLs, so revoking or expiring the link and
+	// its password cannot be bypassed by anyone who saw the IDs.
+	if collection.Visibility != models.VisibilityPublic {
 		http.Error(w, "Not found", http.StatusNotFound)
 		return
 	}
@@ -115,13 +345,34 @@ func (h *PublicGalleryHandler) ServeGalleryImage(w http.ResponseWriter, r *http.
 	}
 
 	photo, err := h.photoRepo.GetByID(r.Context(), photoID)
-	if err != nil || photo == nil {
+	if err != nil || photo == nil || photo.IsTrashed() {
 		http.Error(w, "Not found", http.StatusNotFound)
 		return
 	}
 
-	imagePath := filepath.Join(h.storagePath, photo.StoredPath)
-	h.serveFile(w, imagePath)
+	file, err := h.openGalleryImage(w, r, collection, photo)
+	h.serveFile(w, file, err)
+}
+
+// openGalleryImage opens the original of a gallery photo, with the metadata
+// the collection's owner chose to hide removed. If that fails the photo is
+// not served at all.
+func (h *PublicGalleryHandler) openGalleryImage(w http.ResponseWriter, r *http.Request, collection *models.Collection, photo *models.Photo) (io.ReadSeekCloser, error) {
+	strip := collection.MetadataStripping
+	if !strip.Strips() {
+		return openImage(w, r, h.storageService, h.thumbnailService, photo)
+	}
+	if h.thumbnailService == nil {
+		return nil, fmt.Errorf("cannot strip metadata without the thumbnail service")
+	}
+
+	path, err := h.thumbnailService.GetStrippedRendition(photo.ID, photo.StoredPath, photo.Orientation, strip)
+	if err != nil {
+		log.Printf("Warning: not serving photo %s of collection %s: %v", photo.ID, collection.ID, err)
+		return nil, err
+	}
+	file, _, err := h.thumbnailService.OpenThumbnail(r.Context(), path)
+	return file, err
 }
 
 // ServeGalleryThumbnail serves a thumbnail from a public gallery
@@ -142,8 +393,10 @@ func (h *PublicGalleryHandler) ServeGalleryThumbnail(w http.ResponseWriter, r *h
 		return
 	}
 
-	// Check visibility
-	if collection.Visibility != models.VisibilityPublic && collection.Visibility != models.VisibilitySecretLink {
+	// Only public galleries load images by collection ID. Galleries behind a
+	// share link use signed media URLs, so revoking or expiring the link and
+	// its password cannot be bypassed by anyone who saw the IDs.
+	if collection.Visibility != models.VisibilityPublic {
 		http.Error(w, "Not found", http.StatusNotFound)
 		return
 	}
@@ -156,7 +409,7 @@ func (h *PublicGalleryHandler) ServeGalleryThumbnail(w http.ResponseWriter, r *h
 	}
 
 	photo, err := h.photoRepo.GetByID(r.Context(), photoID)
-	if err != nil || photo == nil {
+	if err != nil || photo == nil || photo.IsTrashed() {
 		http.Error(w, "Not found", http.StatusNotFound)
 		return
 	}
@@ -174,18 +427,15 @@ func (h *PublicGalleryHandler) ServeGalleryThumbnail(w http.ResponseWriter, r *h
 		thumbPath = photo.ThumbSmall
 	}
 
-	// Serve thumbnail if exists
+	// Serve thumbnail if exists, otherwise fall back to the original
 	if thumbPath != nil && *thumbPath != "" {
-		fullPath := filepath.Join(h.storagePath, *thumbPath)
-		if _, err := os.Stat(fullPath); err == nil {
-			h.serveFile(w, fullPath)
+		if file, _, err := h.thumbnailService.OpenThumbnail(r.Context(), *thumbPath); err == nil {
+			h.serveFile(w, file, nil)
 			return
 		}
 	}
-
-	// Fallback to original
-	imagePath := filepath.Join(h.storagePath, photo.StoredPath)
-	h.serveFile(w, imagePath)
+	file, err := h.openGalleryImage(w, r, collection, photo)
+	h.serveFile(w, file, err)
 }
 
 // renderGallery renders the gallery HTML page
@@ -196,6 +446,9 @@ func (h *PublicGalleryHandler) renderGallery(w http.ResponseWriter, r *http.Requ
 		http.Error(w, "Failed to load photos", http.StatusInternalServerError)
 		return
 	}
+	if h.mediaURLs != nil {
+		h.mediaURLs.AttachStripped(collection.MetadataStripping, photos...)
+	}
 
 	// Get theme CSS
 	themeCSS, err := h.collectionService.GetThemeCSS(r.Context(), string(collection.Theme))
@@ -223,6 +476,9 @@ func (h *PublicGalleryHandler) renderGallery(w http.ResponseWriter, r *http.Requ
 		CustomCSS:  customCSS,
 		BaseURL:    baseURL,
 	}
+	if collection.AllowDownloads && h.downloads != nil {
+		data.DownloadURL = strings.TrimSuffix(r.URL.Path, "/") + "/download"
+	}
 
 	// Try to load template
 	templateFile := filepath.Join(h.templatePath, "gallery", "public.html")
@@ -254,9 +510,31 @@ func (h *PublicGalleryHandler) renderEmbeddedGallery(w http.ResponseWriter, data
 	}
 }
 
-// serveFile serves a file with proper content type
-func (h *PublicGalleryHandler) serveFile(w http.ResponseWriter, path string) {
-	file, err := os.Open(path)
+// renderPasswordPrompt asks for the password of a protected share link
+func (h *PublicGalleryHandler) renderPasswordPrompt(w http.ResponseWriter, r *http.Request, collection *models.Collection, message string, status int) {
+	themeCSS, err := h.collectionService.GetThemeCSS(r.Context(), string(collection.Theme))
+	if err != nil {
+		themeCSS = ""
+	}
+
+	data := struct {
+		Collection *models.Collection
+		ThemeCSS   string
+		Error      string
+	}{collection, themeCSS, message}
+
+	tmpl := template.Must(template.New("password").Parse(embeddedPasswordTemplate))
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Printf("Failed to render password prompt: %v", err)
+	}
+}
+
+// serveFile serves an opened file with proper content type
+func (h *PublicGalleryHandler) serveFile(w http.ResponseWriter, file io.ReadSeekCloser, err error) {
 	if err != nil {
 		http.Error(w, "File not found", http.StatusNotFound)
 		return
@@ -345,6 +623,18 @@ const embeddedGalleryTemplate = `<!DOCTYPE html>
             object-fit: cover;
         }
 
+        .play-badge {
+            position: absolute;
+            bottom: 10px;
+            left: 10px;
+            padding: 4px 8px;
+            border-radius: 4px;
+            background: rgba(0,0,0,0.6);
+            color: white;
+            font-size: 0.85rem;
+            pointer-events: none;
+        }
+
         /* Lightbox */
         .lightbox {
             display: none;
@@ -393,6 +683,16 @@ const embeddedGalleryTemplate = `<!DOCTYPE html>
         .lightbox-prev { left: 10px; }
         .lightbox-next { right: 10px; }
 
+        .download-all {
+            display: inline-block;
+            margin-top: 16px;
+            padding: 8px 20px;
+            border: 1px solid var(--text-muted);
+            border-radius: 6px;
+            color: inherit;
+            text-decoration: none;
+        }
+
         .photo-count {
             text-align: center;
             padding: 20px;
@@ -406,14 +706,16 @@ const embeddedGalleryTemplate = `<!DOCTYPE html>
     <header class="header">
         <h1>{{.Collection.Name}}</h1>
         {{if .Collection.Description}}<p>{{.Collection.Description}}</p>{{end}}
+        {{if .DownloadURL}}<a class="download-all" href="{{.DownloadURL}}" download>Download all</a>{{end}}
     </header>
 
     <main class="gallery">
         <div class="photo-grid">
             {{range $i, $photo := .Photos}}
             <div class="photo-card" data-index="{{$i}}" onclick="openLightbox({{$i}})">
-                <img src="/gallery/photos/{{$photo.ID}}/thumbnail?c={{$.Collection.ID}}&size=medium"
+                <img src="{{if $photo.Media}}{{$photo.Media.Medium}}{{else}}/gallery/photos/{{$photo.ID}}/thumbnail?c={{$.Collection.ID}}&size=medium{{end}}"
                      alt="Photo" loading="lazy">
+                {{if $photo.IsVideo}}<span class="play-badge">&#9654;</span>{{end}}
             </div>
             {{end}}
         </div>
@@ -431,7 +733,7 @@ const embeddedGalleryTemplate = `<!DOCTYPE html>
     <script>
         const photos = [
             {{range $i, $photo := .Photos}}
-            {id: "{{$photo.ID}}", collectionId: "{{$.Collection.ID}}"}{{if lt $i (len $.Photos)}},{{end}}
+            {id: "{{$photo.ID}}", url: "{{if $photo.Media}}{{$photo.Media.Original}}{{else}}/gallery/photos/{{$photo.ID}}/image?c={{$.Collection.ID}}{{end}}"}{{if lt $i (len $.Photos)}},{{end}}
             {{end}}
         ];
 
@@ -451,8 +753,7 @@ const embeddedGalleryTemplate = `<!DOCTYPE html>
 
         function updateLightboxImage() {
             const photo = photos[currentIndex];
-            document.getElementById('lightbox-img').src =
-                '/gallery/photos/' + photo.id + '/image?c=' + photo.collectionId;
+            document.getElementById('lightbox-img').src = photo.url;
         }
 
         function nextPhoto() {
@@ -479,3 +780,82 @@ const embeddedGalleryTemplate = `<!DOCTYPE html>
     </script>
 </body>
 </html>`
+
+// Embedded password prompt for protected share links
+const embeddedPasswordTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="robots" content="noindex">
+    <title>{{.Collection.Name}}</title>
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+
+        {{.ThemeCSS}}
+
+        body {
+            font-family: var(--font-family, -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif);
+            background-color: var(--bg-color, #0f172a);
+            color: var(--text-color, #f1f5f9);
+            min-height: 100vh;
+            display: flex;
+            justify-content: center;
+            align-items: center;
+        }
+
+        .prompt {
+            width: 100%;
+            max-width: 360px;
+            padding: 32px;
+            border-radius: 8px;
+            background-color: var(--card-color, #1e293b);
+            border: 1px solid var(--border-color, #334155);
+        }
+
+        .prompt h1 {
+            font-size: 1.5rem;
+            margin-bottom: 8px;
+        }
+
+        .prompt p {
+            color: var(--text-muted, #94a3b8);
+            margin-bottom: 20px;
+        }
+
+        .prompt input {
+            width: 100%;
+            padding: 10px 12px;
+            margin-bottom: 12px;
+            border-radius: 6px;
+            border: 1px solid var(--border-color, #334155);
+            background: var(--bg-color, #0f172a);
+            color: var(--text-color, #f1f5f9);
+            font-size: 1rem;
+        }
+
+        .prompt button {
+            width: 100%;
+            padding: 10px 12px;
+            border: none;
+            border-radius: 6px;
+            background: var(--accent-color, #3b82f6);
+            color: white;
+            font-size: 1rem;
+            cursor: pointer;
+        }
+
+        .prompt .error {
+            color: #f87171;
+        }
+    </style>
+</head>
+<body>
+    <form class="prompt" method="post">
+        <h1>{{.Collection.Name}}</h1>
+        {{if .Error}}<p class="error">{{.Error}}</p>{{else}}<p>This gallery is password protected.</p>{{end}}
+        <input type="password" name="password" placeholder="Password" autocomplete="current-password" autofocus required>
+        <button type="submit">View gallery</button>
+    </form>
+</body>
+</html>`
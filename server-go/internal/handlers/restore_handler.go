@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/photosync/server/internal/middleware"
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/services"
+)
+
+// RestoreHandler serves the download manifests devices restore the library from
+type RestoreHandler struct {
+	restoreService *services.RestoreService
+}
+
+// NewRestoreHandler creates a new RestoreHandler
+func NewRestoreHandler(restoreService *services.RestoreService) *RestoreHandler {
+	return &RestoreHandler{
+		restoreService: restoreService,
+	}
+}
+
+// CreatePlan creates a restore plan for a device
+// @Summary Create a restore plan
+// @Description Fix the set of photos a device should download. Without filters the plan covers the whole library; sourceDeviceId, collectionId, year or dateFrom/dateTo narrow it down, for example to everything uploaded from the old phone or everything from 2021.
+// @Tags sync
+// @Accept json
+// @Produce json
+// @Param request body models.CreateRestorePlanRequest true "Device and filters"
+// @Success 201 {object} models.RestorePlan
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse "Device not found"
+// @Security ApiKeyAuth
+// @Router /api/sync/restore [post]
+func (h *RestoreHandler) CreatePlan(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.CreateRestorePlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	plan, err := h.restoreService.CreatePlan(r.Context(), user.ID, req)
+	if err != nil {
+		h.respondRestoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(plan)
+}
+
+// ListPlans returns the user's restore plans
+// @Summary List restore plans
+// @Description List restore plans with their progress, newest first. Pass deviceId or X-Device-ID to list one device's plans.
+// @Tags sync
+// @Produce json
+// @Param deviceId query string false "Device ID"
+// @Param X-Device-ID header string false "Device ID"
+// @Success 200 {object} models.RestorePlanListResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/sync/restore [get]
+func (h *RestoreHandler) ListPlans(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	deviceID := r.URL.Query().Get("deviceId")
+	if deviceID == "" {
+		deviceID = r.Header.Get("X-Device-ID")
+	}
+
+	plans, err := h.restoreService.List(r.Context(), user.ID, deviceID)
+	if err != nil {
+		h.respondRestoreError(w, err)
+		return
+	}
+	if plans == nil {
+		plans = []*models.RestorePlan{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.RestorePlanListResponse{Plans: plans})
+}
+
+// GetPlan returns a restore plan's progress
+// @Summary Get a restore plan
+// @Tags sync
+// @Produce json
+// @Param id path string true "Restore plan ID"
+// @Success 200 {object} models.RestorePlan
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/sync/restore/{id} [get]
+func (h *RestoreHandler) GetPlan(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	plan, err := h.restoreService.Get(r.Context(), user.ID, chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondRestoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plan)
+}
+
+// GetManifest returns a page of a restore plan's photos
+// @Summary Get restore manifest
+// @Description Page through the photos of a restore plan with their sizes, hashes and download URLs. By default only photos the device has not confirmed are listed, so a device resumes by asking again from the start; pass pending=false for the full manifest.
+// @Tags sync
+// @Produce json
+// @Param id path string true "Restore plan ID"
+// @Param cursor query string false "Cursor from the previous page"
+// @Param limit query int false "Page size (default 200, max 1000)"
+// @Param pending query bool false "Only photos not yet confirmed (default true)"
+// @Success 200 {object} models.RestoreManifestResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/sync/restore/{id}/items [get]
+func (h *RestoreHandler) GetManifest(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	pending := true
+	if v := query.Get("pending"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "Invalid pending value", http.StatusBadRequest)
+			return
+		}
+		pending = parsed
+	}
+
+	manifest, err := h.restoreService.Manifest(r.Context(), user.ID, chi.URLParam(r, "id"), query.Get("cursor"), limit, pending)
+	if err != nil {
+		h.respondRestoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(manifest)
+}
+
+// ConfirmItems records photos the device has downloaded
+// @Summary Confirm restored photos
+// @Description Report photos the device has stored, each with the hash of the downloaded file. Confirmations whose photo is not in the plan or whose hash does not match are returned as rejected. The plan completes once every photo is confirmed.
+// @Tags sync
+// @Accept json
+// @Produce json
+// @Param id path string true "Restore plan ID"
+// @Param request body models.ConfirmRestoreItemsRequest true "Downloaded photos"
+// @Success 200 {object} models.ConfirmRestoreItemsResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse "Plan already completed or cancelled"
+// @Security ApiKeyAuth
+// @Router /api/sync/restore/{id}/confirm [post]
+func (h *RestoreHandler) ConfirmItems(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.ConfirmRestoreItemsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Items) > models.MaxRestoreManifestLimit {
+		http.Error(w, "Too many items", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.restoreService.Confirm(r.Context(), user.ID, chi.URLParam(r, "id"), req.Items)
+	if err != nil {
+		h.respondRestoreError(w, err)
+		return
+	}
+	if resp.Rejected == nil {
+		resp.Rejected = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// CancelPlan stops a restore plan
+// @Summary Cancel a restore plan
+// @Tags sync
+// @Produce json
+// @Param id path string true "Restore plan ID"
+// @Success 200 {object} models.RestorePlan
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse "Plan already completed or cancelled"
+// @Security ApiKeyAuth
+// @Router /api/sync/restore/{id} [delete]
+func (h *RestoreHandler) CancelPlan(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	plan, err := h.restoreService.Cancel(r.Context(), user.ID, chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondRestoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plan)
+}
+
+// respondRestoreError maps restore plan errors to HTTP responses
+func (h *RestoreHandler) respondRestoreError(w http.ResponseWriter, err error) {
+	switch err {
+	case models.ErrRestorePlanNotFound, models.ErrRestoreDeviceNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case models.ErrRestorePlanFinished:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case models.ErrRestoreDeviceRequired, models.ErrRestoreYearWithRange, models.ErrRestoreInvalidYear,
+		models.ErrRestoreInvalidRange, models.ErrRestoreInvalidCursor:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Restore plan error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/photosync/server/internal/middleware"
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+	"github.com/photosync/server/internal/services"
+)
+
+// SearchHandler handles photo search for both API key and session users
+type SearchHandler struct {
+	photoRepo repository.PhotoRepo
+	mediaURLs *services.MediaURLService
+}
+
+// NewSearchHandler creates a new SearchHandler
+func NewSearchHandler(photoRepo repository.PhotoRepo) *SearchHandler {
+	return &SearchHandler{
+		photoRepo: photoRepo,
+	}
+}
+
+// SetMediaURLs enables signed media URLs in search results
+func (h *SearchHandler) SetMediaURLs(mediaURLs *services.MediaURLService) {
+	h.mediaURLs = mediaURLs
+}
+
+// Search returns the user's photos matching the given filters
+// @Summary Search photos
+// @Description Search the user's photos with filters, newest first. Facet counts (per camera, year and device) cover the whole result set and are only returned on the first page.
+// @Tags search
+// @Produce json
+// @Param from query string false "Taken on or after (RFC3339 or YYYY-MM-DD)"
+// @Param to query string false "Taken on or before (RFC3339 or YYYY-MM-DD)"
+// @Param cameraMake query string false "Camera make (case-insensitive)"
+// @Param cameraModel query string false "Camera model (case-insensitive)"
+// @Param lens query string false "Lens model (case-insensitive)"
+// @Param isoMin query int false "Minimum ISO"
+// @Param isoMax query int false "Maximum ISO"
+// @Param apertureMin query number false "Minimum f-number"
+// @Param apertureMax query number false "Maximum f-number"
+// @Param filename query string false "Filename substring (case-insensitive)"
+// @Param deviceId query string false "Origin device ID"
+// @Param hasGps query bool false "Only photos with (true) or without (false) GPS"
+// @Param bbox query string false "Bounding box: minLat,minLng,maxLat,maxLng"
+// @Param collectionId query string false "Only photos in this collection"
+// @Param cursor query string false "Cursor from the previous page"
+// @Param limit query int false "Page size (max 200)" default(50)
+// @Success 200 {object} models.PhotoSearchResponse
+// @Failure 400 {object} models.ErrorResponse "Invalid search parameter"
+// @Failure 401 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Security SessionAuth
+// @Router /api/photos/search [get]
+// @Router /api/web/photos/search [get]
+func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query, err := models.ParsePhotoSearchQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query.UserID = user.ID
+
+	photos, nextCursor, err := h.photoRepo.Search(r.Context(), query)
+	if err != nil {
+		log.Printf("Error searching photos: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	response := models.PhotoSearchResponse{
+		Photos: make([]models.PhotoResponse, len(photos)),
+		Pagination: models.PaginationInfo{
+			Cursor:  nextCursor,
+			HasMore: nextCursor != "",
+		},
+	}
+	attachMediaURLs(h.mediaURLs, photos...)
+	for i, p := range photos {
+		response.Photos[i] = models.PhotoToResponse(p)
+	}
+
+	// Facets describe the whole result set, so they only need computing once
+	if query.Cursor == "" {
+		facets, err := h.photoRepo.GetSearchFacets(r.Context(), query)
+		if err != nil {
+			log.Printf("Error computing search facets: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		response.Facets = facets
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
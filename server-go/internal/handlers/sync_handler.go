@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"log"
@@ -20,7 +21,10 @@ type SyncHandler struct {
 	photoRepo       repository.PhotoRepo
 	deviceRepo      repository.DeviceRepo
 	syncStateRepo   repository.DeviceSyncStateRepo
+	changeRepo      repository.PhotoChangeRepo
 	storageService  *services.PhotoStorageService
+	thumbnailService *services.ThumbnailService
+	mediaURLs       *services.MediaURLService
 }
 
 // NewSyncHandler creates a new SyncHandler
@@ -28,16 +32,34 @@ func NewSyncHandler(
 	photoRepo repository.PhotoRepo,
 	deviceRepo repository.DeviceRepo,
 	syncStateRepo repository.DeviceSyncStateRepo,
+	changeRepo repository.PhotoChangeRepo,
 	storageService *services.PhotoStorageService,
+	thumbnailService *services.ThumbnailService,
 ) *SyncHandler {
 	return &SyncHandler{
 		photoRepo:      photoRepo,
 		deviceRepo:     deviceRepo,
 		syncStateRepo:  syncStateRepo,
+		changeRepo:     changeRepo,
 		storageService: storageService,
+		thumbnailService: thumbnailService,
 	}
 }
 
+// SetMediaURLs enables signed media URLs in sync responses
+func (h *SyncHandler) SetMediaURLs(mediaURLs *services.MediaURLService) {
+	h.mediaURLs = mediaURLs
+}
+
+// thumbnailURL returns the URL a client loads a photo's thumbnail from:
+// signed when media URLs are enabled, otherwise the API-key thumbnail route
+func (h *SyncHandler) thumbnailURL(photoID string) string {
+	if h.mediaURLs != nil {
+		return h.mediaURLs.URL(photoID, models.MediaMedium)
+	}
+	return "/api/sync/thumbnail/" + photoID
+}
+
 // GetSyncStatus returns sync status for a device
 // @Summary Get sync status
 // @Description Get sync status including photo counts and whether legacy claiming is needed
@@ -95,6 +117,8 @@ func (h *SyncHandler) GetSyncStatus(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	usage := user.Usage()
+
 	response := models.SyncStatusResponse{
 		TotalPhotos:       totalPhotos,
 		DevicePhotos:      devicePhotos,
@@ -103,6 +127,7 @@ func (h *SyncHandler) GetSyncStatus(w http.ResponseWriter, r *http.Request) {
 		LastSyncAt:        lastSyncAt,
 		ServerVersion:     syncVersion,
 		NeedsLegacyClaim:  legacyPhotos > 0,
+		Usage:             &usage,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -172,45 +197,7 @@ func (h *SyncHandler) SyncPhotos(w http.ResponseWriter, r *http.Request) {
 	// Convert photos to sync items
 	syncItems := make([]models.SyncPhotoItem, len(photos))
 	for i, photo := range photos {
-		item := models.SyncPhotoItem{
-			ID:               photo.ID,
-			FileHash:         photo.FileHash,
-			OriginalFilename: photo.OriginalFilename,
-			FileSize:         photo.FileSize,
-			DateTaken:        photo.DateTaken,
-			UploadedAt:       photo.UploadedAt,
-			Width:            photo.Width,
-			Height:           photo.Height,
-		}
-
-		// Add thumbnail URL if requested
-		if req.IncludeThumbnailURLs && photo.ThumbMedium != nil {
-			item.ThumbnailURL = "/api/web/photos/" + photo.ID + "/thumbnail"
-		}
-
-		// Add origin device info
-		if photo.OriginDeviceID != nil {
-			// Check cache first
-			device, ok := deviceCache[*photo.OriginDeviceID]
-			if !ok {
-				// Load device from DB
-				device, err = h.deviceRepo.GetByID(r.Context(), *photo.OriginDeviceID)
-				if err == nil && device != nil {
-					deviceCache[*photo.OriginDeviceID] = device
-				}
-			}
-
-			if device != nil {
-				item.OriginDevice = &models.OriginDeviceInfo{
-					ID:              device.ID,
-					Name:            device.DeviceName,
-					Platform:        device.Platform,
-					IsCurrentDevice: req.DeviceID == device.ID,
-				}
-			}
-		}
-
-		syncItems[i] = item
+		syncItems[i] = h.toSyncPhotoItem(r.Context(), photo, req.DeviceID, req.IncludeThumbnailURLs, deviceCache)
 	}
 
 	// Update last sync state if we have a device ID and returned photos
@@ -238,6 +225,177 @@ func (h *SyncHandler) SyncPhotos(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// GetChanges returns the user's change log after a sequence number
+// @Summary Get library changes
+// @Description Get adds, edits, claims and deletions since a change-log sequence. Deletions are returned as tombstones without photo metadata. When resetRequired is true the client must resync its library with /api/sync/photos and continue from latestSeq.
+// @Tags sync
+// @Produce json
+// @Param since query int false "Last sequence the client has applied" default(0)
+// @Param limit query int false "Maximum changes to return" default(100)
+// @Param X-Device-ID header string false "Device ID"
+// @Success 200 {object} models.SyncChangesResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/sync/changes [get]
+func (h *SyncHandler) GetChanges(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var since int64
+	if s := r.URL.Query().Get("since"); s != "" {
+		parsed, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || parsed < 0 {
+			http.Error(w, "Invalid since parameter", http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 500 {
+			limit = parsed
+		}
+	}
+
+	latestSeq, err := h.changeRepo.GetLatestSeq(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error getting latest change: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// Tombstones at or below the floor have been compacted away, so a client
+	// that stopped part way through that range may have missed deletions
+	floor, err := h.changeRepo.GetFloor(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error getting change floor: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if since > 0 && since < floor {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.SyncChangesResponse{
+			Changes:       []models.SyncChangeItem{},
+			NextSince:     latestSeq,
+			LatestSeq:     latestSeq,
+			ResetRequired: true,
+		})
+		return
+	}
+
+	// Fetch one extra to know whether there is another page
+	changes, err := h.changeRepo.GetSince(r.Context(), user.ID, since, limit+1)
+	if err != nil {
+		log.Printf("Error getting changes: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	hasMore := len(changes) > limit
+	if hasMore {
+		changes = changes[:limit]
+	}
+
+	deviceID := r.Header.Get("X-Device-ID")
+	deviceCache := make(map[string]*models.Device)
+
+	items := make([]models.SyncChangeItem, 0, len(changes))
+	nextSince := since
+	for _, change := range changes {
+		item := models.SyncChangeItem{
+			Seq:       change.Seq,
+			PhotoID:   change.PhotoID,
+			Type:      change.Type,
+			ChangedAt: change.ChangedAt,
+		}
+
+		if !change.IsTombstone() {
+			photo, err := h.photoRepo.GetByID(r.Context(), change.PhotoID)
+			if err != nil {
+				log.Printf("Error getting photo %s for change %d: %v", change.PhotoID, change.Seq, err)
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+
+			// The photo has moved on since this entry was written; a later
+			// tombstone will follow, so report it as gone now
+			if photo == nil || photo.IsTrashed() || photo.UserID == nil || *photo.UserID != user.ID {
+				item.Type = models.PhotoChangeDeleted
+			} else {
+				syncItem := h.toSyncPhotoItem(r.Context(), photo, deviceID, true, deviceCache)
+				item.Photo = &syncItem
+			}
+		}
+
+		items = append(items, item)
+		nextSince = change.Seq
+	}
+
+	response := models.SyncChangesResponse{
+		Changes:   items,
+		NextSince: nextSince,
+		LatestSeq: latestSeq,
+		HasMore:   hasMore,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// toSyncPhotoItem converts a photo to its sync representation, resolving the
+// origin device through deviceCache
+func (h *SyncHandler) toSyncPhotoItem(ctx context.Context, photo *models.Photo, currentDeviceID string, includeThumbnailURL bool, deviceCache map[string]*models.Device) models.SyncPhotoItem {
+	item := models.SyncPhotoItem{
+		ID:               photo.ID,
+		FileHash:         photo.FileHash,
+		OriginalFilename: photo.OriginalFilename,
+		FileSize:         photo.FileSize,
+		DateTaken:        photo.DateTaken,
+		UploadedAt:       photo.UploadedAt,
+		MediaType:        photo.MediaType,
+		Duration:         photo.Duration,
+		Width:            photo.Width,
+		Height:           photo.Height,
+	}
+
+	// Add thumbnail URL if requested
+	if includeThumbnailURL && photo.ThumbMedium != nil {
+		item.ThumbnailURL = h.thumbnailURL(photo.ID)
+	}
+	if h.mediaURLs != nil {
+		item.Media = h.mediaURLs.URLs(photo)
+	}
+
+	// Add origin device info
+	if photo.OriginDeviceID != nil {
+		// Check cache first
+		device, ok := deviceCache[*photo.OriginDeviceID]
+		if !ok {
+			// Load device from DB
+			var err error
+			device, err = h.deviceRepo.GetByID(ctx, *photo.OriginDeviceID)
+			if err == nil && device != nil {
+				deviceCache[*photo.OriginDeviceID] = device
+			}
+		}
+
+		if device != nil {
+			item.OriginDevice = &models.OriginDeviceInfo{
+				ID:              device.ID,
+				Name:            device.DeviceName,
+				Platform:        device.Platform,
+				IsCurrentDevice: currentDeviceID == device.ID,
+			}
+		}
+	}
+
+	return item
+}
+
 // GetLegacyPhotos returns photos without origin device
 // @Summary Get legacy photos
 // @Description Get photos uploaded before device tracking was enabled
@@ -284,10 +442,15 @@ func (h *SyncHandler) GetLegacyPhotos(w http.ResponseWriter, r *http.Request) {
 			FileSize:         photo.FileSize,
 			DateTaken:        photo.DateTaken,
 			UploadedAt:       photo.UploadedAt,
-			ThumbnailURL:     "/api/web/photos/" + photo.ID + "/thumbnail",
+			MediaType:        photo.MediaType,
+			Duration:         photo.Duration,
+			ThumbnailURL:     h.thumbnailURL(photo.ID),
 			Width:            photo.Width,
 			Height:           photo.Height,
 		}
+		if h.mediaURLs != nil {
+			syncItems[i].Media = h.mediaURLs.URLs(photo)
+		}
 	}
 
 	response := models.LegacyPhotosResponse{
@@ -406,13 +569,14 @@ func (h *SyncHandler) DownloadPhoto(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get full path
-	fullPath, err := h.storageService.GetFullPath(photo.StoredPath)
+	// Open the stored file
+	file, info, err := h.storageService.Open(r.Context(), photo.StoredPath)
 	if err != nil {
-		log.Printf("Error getting photo path: %v", err)
+		log.Printf("Error opening photo file: %v", err)
 		http.Error(w, "File not found", http.StatusNotFound)
 		return
 	}
+	defer file.Close()
 
 	// Set headers for download
 	w.Header().Set("Content-Disposition", "attachment; filename=\""+photo.OriginalFilename+"\"")
@@ -420,7 +584,7 @@ func (h *SyncHandler) DownloadPhoto(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("X-PhotoSync-DateTaken", photo.DateTaken.Format("2006-01-02T15:04:05Z"))
 
 	// Serve the file (supports range requests)
-	http.ServeFile(w, r, fullPath)
+	http.ServeContent(w, r, photo.OriginalFilename, info.ModTime, file)
 }
 
 // DownloadPhotoByHash serves photo by hash (for mobile sync)
@@ -455,18 +619,19 @@ func (h *SyncHandler) DownloadPhotoByHash(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if photo == nil {
+	if photo == nil || photo.IsTrashed() {
 		http.Error(w, "Photo not found", http.StatusNotFound)
 		return
 	}
 
-	// Get full path
-	fullPath, err := h.storageService.GetFullPath(photo.StoredPath)
+	// Open the stored file
+	file, info, err := h.storageService.Open(r.Context(), photo.StoredPath)
 	if err != nil {
-		log.Printf("Error getting photo path: %v", err)
+		log.Printf("Error opening photo file: %v", err)
 		http.Error(w, "File not found", http.StatusNotFound)
 		return
 	}
+	defer file.Close()
 
 	// Set headers for download
 	w.Header().Set("Content-Disposition", "attachment; filename=\""+photo.OriginalFilename+"\"")
@@ -475,7 +640,7 @@ func (h *SyncHandler) DownloadPhotoByHash(w http.ResponseWriter, r *http.Request
 	w.Header().Set("X-PhotoSync-DateTaken", photo.DateTaken.Format("2006-01-02T15:04:05Z"))
 
 	// Serve the file (supports range requests)
-	http.ServeFile(w, r, fullPath)
+	http.ServeContent(w, r, photo.OriginalFilename, info.ModTime, file)
 }
 
 // GetThumbnail serves thumbnail for a photo
@@ -526,15 +691,16 @@ func (h *SyncHandler) GetThumbnail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fullPath, err := h.storageService.GetFullPath(thumbPath)
+	file, info, err := h.thumbnailService.OpenThumbnail(r.Context(), thumbPath)
 	if err != nil {
 		http.Error(w, "Thumbnail not found", http.StatusNotFound)
 		return
 	}
+	defer file.Close()
 
 	w.Header().Set("Content-Type", "image/jpeg")
 	w.Header().Set("Cache-Control", "public, max-age=31536000")
-	http.ServeFile(w, r, fullPath)
+	http.ServeContent(w, r, thumbPath, info.ModTime, file)
 }
 
 // helper to suppress unused import warning
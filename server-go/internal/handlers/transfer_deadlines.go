@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// Archives, batches and originals can take far longer to transfer than the
+// server-wide timeouts allow. Rather than lifting the deadlines, handlers for
+// such transfers push them forward each time data moves, so a transfer runs
+// for as long as it makes progress but a stalled client cannot hold the
+// connection open.
+
+// transferProgressTimeout is how long a long transfer may go without any data
+// moving before the connection is dropped
+const transferProgressTimeout = 2 * time.Minute
+
+// progressDeadlineWriter pushes the connection's write deadline forward on
+// every write to the response
+type progressDeadlineWriter struct {
+	http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// newProgressDeadlineWriter wraps a response whose body is written for longer
+// than the server-wide write timeout allows
+func newProgressDeadlineWriter(w http.ResponseWriter) *progressDeadlineWriter {
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Now().Add(transferProgressTimeout))
+	return &progressDeadlineWriter{ResponseWriter: w, rc: rc}
+}
+
+func (p *progressDeadlineWriter) Write(b []byte) (int, error) {
+	p.rc.SetWriteDeadline(time.Now().Add(transferProgressTimeout))
+	return p.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying response
+func (p *progressDeadlineWriter) Unwrap() http.ResponseWriter {
+	return p.ResponseWriter
+}
+
+// progressDeadlineBody pushes the connection's read deadline forward on every
+// read of the request body. The write deadline moves with it, since the
+// server-wide one runs from the start of the request and would expire before
+// the response to a long upload is written.
+type progressDeadlineBody struct {
+	io.ReadCloser
+	rc *http.ResponseController
+}
+
+// useProgressDeadlineBody replaces the request body of an upload that takes
+// longer than the server-wide timeouts allow
+func useProgressDeadlineBody(w http.ResponseWriter, r *http.Request) {
+	body := &progressDeadlineBody{ReadCloser: r.Body, rc: http.NewResponseController(w)}
+	body.extend()
+	r.Body = body
+}
+
+func (p *progressDeadlineBody) Read(b []byte) (int, error) {
+	p.extend()
+	return p.ReadCloser.Read(b)
+}
+
+func (p *progressDeadlineBody) extend() {
+	deadline := time.Now().Add(transferProgressTimeout)
+	p.rc.SetReadDeadline(deadline)
+	p.rc.SetWriteDeadline(deadline)
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/photosync/server/internal/middleware"
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/services"
+)
+
+// TrashHandler handles trash endpoints for both API key and session users
+type TrashHandler struct {
+	trashService *services.TrashService
+}
+
+// NewTrashHandler creates a new TrashHandler
+func NewTrashHandler(trashService *services.TrashService) *TrashHandler {
+	return &TrashHandler{
+		trashService: trashService,
+	}
+}
+
+// ListTrash returns the current user's trashed photos
+// @Summary List trash
+// @Description Get a paginated list of the user's trashed photos, most recently deleted first
+// @Tags trash
+// @Produce json
+// @Param skip query int false "Number of photos to skip" default(0)
+// @Param take query int false "Number of photos to return (max 100)" default(50)
+// @Success 200 {object} models.TrashListResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Security SessionAuth
+// @Router /api/photos/trash [get]
+// @Router /api/web/trash [get]
+func (h *TrashHandler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	skip := 0
+	take := 50
+	if s := r.URL.Query().Get("skip"); s != "" {
+		if v, err := strconv.Atoi(s); err == nil && v >= 0 {
+			skip = v
+		}
+	}
+	if t := r.URL.Query().Get("take"); t != "" {
+		if v, err := strconv.Atoi(t); err == nil && v >= 1 && v <= 100 {
+			take = v
+		}
+	}
+
+	photos, total, err := h.trashService.List(r.Context(), user.ID, skip, take)
+	if err != nil {
+		log.Printf("Error listing trash: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]models.TrashPhotoResponse, len(photos))
+	for i, p := range photos {
+		items[i] = models.TrashPhotoResponse{
+			PhotoResponse: models.PhotoToResponse(p),
+			DeletedAt:     *p.DeletedAt,
+			PurgeAt:       h.trashService.PurgeAt(p),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.TrashListResponse{
+		Photos:        items,
+		TotalCount:    total,
+		Skip:          skip,
+		Take:          take,
+		RetentionDays: h.trashService.RetentionDays(),
+	})
+}
+
+// RestorePhoto takes a photo back out of the trash
+// @Summary Restore a photo
+// @Description Restore one of the user's photos from the trash
+// @Tags trash
+// @Param id path string true "Photo ID"
+// @Success 204 "Photo restored"
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse "Photo not found"
+// @Failure 409 {object} models.ErrorResponse "Photo is not in the trash"
+// @Security ApiKeyAuth
+// @Security SessionAuth
+// @Router /api/photos/trash/{id}/restore [post]
+// @Router /api/web/trash/{id}/restore [post]
+func (h *TrashHandler) RestorePhoto(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	photoID := chi.URLParam(r, "id")
+	if photoID == "" {
+		http.Error(w, "Photo ID required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.trashService.Restore(r.Context(), user.ID, photoID); err != nil {
+		switch err {
+		case models.ErrPhotoNotFound:
+			http.Error(w, "Photo not found", http.StatusNotFound)
+		case models.ErrPhotoNotInTrash:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Printf("Error restoring photo %s: %v", photoID, err)
+			http.Error(w, "Failed to restore photo", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// EmptyTrash permanently deletes everything in the user's trash
+// @Summary Empty trash
+// @Description Permanently delete all of the user's trashed photos, including files and thumbnails
+// @Tags trash
+// @Produce json
+// @Success 200 {object} models.EmptyTrashResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Security SessionAuth
+// @Router /api/photos/trash [delete]
+// @Router /api/web/trash [delete]
+func (h *TrashHandler) EmptyTrash(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	purged, err := h.trashService.Empty(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error emptying trash for user %s: %v", user.ID, err)
+		http.Error(w, "Failed to empty trash", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Trash emptied for user %s: %d photos purged", user.ID, purged)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.EmptyTrashResponse{Purged: purged})
+}
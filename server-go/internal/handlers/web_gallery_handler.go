@@ -1,33 +1,45 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"io"
+	"log"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/photosync/server/internal/middleware"
 	"github.com/photosync/server/internal/models"
 	"github.com/photosync/server/internal/repository"
+	"github.com/photosync/server/internal/services"
 )
 
 // WebGalleryHandler handles web gallery endpoints
 type WebGalleryHandler struct {
-	photoRepo   *repository.PhotoRepositoryPostgres
-	storagePath string
+	photoRepo        repository.PhotoRepo
+	thumbnailService *services.ThumbnailService
+	trashService     *services.TrashService
+	storageService   *services.PhotoStorageService
+	mediaURLs        *services.MediaURLService
 }
 
 // NewWebGalleryHandler creates a new WebGalleryHandler
-func NewWebGalleryHandler(photoRepo *repository.PhotoRepositoryPostgres, storagePath string) *WebGalleryHandler {
+func NewWebGalleryHandler(photoRepo repository.PhotoRepo, thumbnailService *services.ThumbnailService, trashService *services.TrashService, storageService *services.PhotoStorageService) *WebGalleryHandler {
 	return &WebGalleryHandler{
-		photoRepo:   photoRepo,
-		storagePath: storagePath,
+		photoRepo:        photoRepo,
+		thumbnailService: thumbnailService,
+		trashService:     trashService,
+		storageService:   storageService,
 	}
 }
 
+// SetMediaURLs enables signed media URLs in gallery listings
+func (h *WebGalleryHandler) SetMediaURLs(mediaURLs *services.MediaURLService) {
+	h.mediaURLs = mediaURLs
+}
+
 // PhotoListResponse is the response for listing photos
 type PhotoListResponse struct {
 	Photos     interface{} `json:"photos"`
@@ -89,6 +101,7 @@ func (h *WebGalleryHandler) ListPhotos(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	attachMediaURLs(h.mediaURLs, photos...)
 	response := PhotoListResponse{
 		Photos:     photos,
 		TotalCount: count,
@@ -153,6 +166,7 @@ func (h *WebGalleryHandler) ListPhotosWithLocation(w http.ResponseWriter, r *htt
 		return
 	}
 
+	attachMediaURLs(h.mediaURLs, photos...)
 	response := PhotoListResponse{
 		Photos:     photos,
 		TotalCount: count,
@@ -166,7 +180,7 @@ func (h *WebGalleryHandler) ListPhotosWithLocation(w http.ResponseWriter, r *htt
 
 // ServeImage serves the full image file
 // @Summary Get photo image
-// @Description Serve the full resolution photo
+// @Description Serve the full resolution photo. HEIC/HEIF originals are transcoded to JPEG unless the Accept header lists image/heic or image/heif.
 // @Tags web-gallery
 // @Produce image/jpeg
 // @Param id path string true "Photo ID"
@@ -205,8 +219,8 @@ func (h *WebGalleryHandler) ServeImage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Serve the file
-	imagePath := filepath.Join(h.storagePath, photo.StoredPath)
-	h.serveFile(w, imagePath)
+	file, err := openImage(w, r, h.storageService, h.thumbnailService, photo)
+	h.serveFile(w, file, err)
 }
 
 // ServeThumbnail serves a thumbnail version of the image
@@ -265,26 +279,17 @@ func (h *WebGalleryHandler) ServeThumbnail(w http.ResponseWriter, r *http.Reques
 		thumbPath = photo.ThumbSmall
 	}
 
-	// If thumbnail exists, serve it
-	if thumbPath != nil && *thumbPath != "" {
-		fullPath := filepath.Join(h.storagePath, *thumbPath)
-		if _, err := os.Stat(fullPath); err == nil {
-			h.serveFile(w, fullPath)
-			return
-		}
-	}
-
-	// Fall back to original image if no thumbnail
-	imagePath := filepath.Join(h.storagePath, photo.StoredPath)
-	h.serveFile(w, imagePath)
+	// Serve the thumbnail, falling back to the original image if there is none
+	file, err := openThumbnail(r.Context(), h.storageService, h.thumbnailService, photo, thumbPath)
+	h.serveFile(w, file, err)
 }
 
-// DeletePhoto deletes a photo (admin only or own photos)
+// DeletePhoto moves a photo to the trash (admin only or own photos)
 // @Summary Delete a photo
-// @Description Delete a photo by ID. Admins can delete any photo, users can only delete their own.
+// @Description Move a photo to the trash by ID. Admins can delete any photo, users can only delete their own.
 // @Tags web-gallery
 // @Param id path string true "Photo ID"
-// @Success 204 "Photo deleted successfully"
+// @Success 204 "Photo moved to trash"
 // @Failure 403 {string} string "Forbidden"
 // @Failure 404 {string} string "Photo not found"
 // @Security SessionAuth
@@ -321,24 +326,12 @@ func (h *WebGalleryHandler) DeletePhoto(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	// Delete the files
-	imagePath := filepath.Join(h.storagePath, photo.StoredPath)
-	os.Remove(imagePath)
-
-	// Delete thumbnails
-	if photo.ThumbSmall != nil {
-		os.Remove(filepath.Join(h.storagePath, *photo.ThumbSmall))
-	}
-	if photo.ThumbMedium != nil {
-		os.Remove(filepath.Join(h.storagePath, *photo.ThumbMedium))
-	}
-	if photo.ThumbLarge != nil {
-		os.Remove(filepath.Join(h.storagePath, *photo.ThumbLarge))
-	}
-
-	// Delete from database
-	_, err = h.photoRepo.Delete(r.Context(), photoID)
-	if err != nil {
+	// Move to trash; the file is purged once the retention period ends
+	if err := h.trashService.MoveToTrash(r.Context(), photoID); err != nil {
+		if err == models.ErrPhotoNotFound {
+			http.Error(w, "Photo not found", http.StatusNotFound)
+			return
+		}
 		http.Error(w, "Failed to delete photo", http.StatusInternalServerError)
 		return
 	}
@@ -346,9 +339,8 @@ func (h *WebGalleryHandler) DeletePhoto(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// serveFile serves a file with proper content type detection
-func (h *WebGalleryHandler) serveFile(w http.ResponseWriter, path string) {
-	file, err := os.Open(path)
+// serveFile serves an opened file with proper content type detection
+func (h *WebGalleryHandler) serveFile(w http.ResponseWriter, file io.ReadSeekCloser, err error) {
 	if err != nil {
 		http.Error(w, "File not found", http.StatusNotFound)
 		return
@@ -367,3 +359,50 @@ func (h *WebGalleryHandler) serveFile(w http.ResponseWriter, path string) {
 	w.Header().Set("Cache-Control", "private, max-age=86400")
 	io.Copy(w, file)
 }
+
+// openImage opens the file to serve for a photo's full image. HEIC/HEIF
+// originals are swapped for a cached JPEG rendition when the client does not
+// advertise HEIC support, since most browsers cannot display it.
+func openImage(w http.ResponseWriter, r *http.Request, storageService *services.PhotoStorageService, thumbnailService *services.ThumbnailService, photo *models.Photo) (io.ReadSeekCloser, error) {
+	if services.IsHEIC(photo.StoredPath) && thumbnailService != nil {
+		w.Header().Set("Vary", "Accept")
+		if !acceptsHEIC(r) {
+			jpegPath, err := thumbnailService.GetJPEGRendition(photo.ID, photo.StoredPath, photo.Orientation)
+			var file io.ReadSeekCloser
+			if err == nil {
+				file, _, err = thumbnailService.OpenThumbnail(r.Context(), jpegPath)
+			}
+			if err == nil {
+				return file, nil
+			}
+			log.Printf("Warning: failed to transcode %s to JPEG: %v", photo.ID, err)
+		}
+	}
+
+	file, _, err := storageService.Open(r.Context(), photo.StoredPath)
+	return file, err
+}
+
+// openThumbnail opens the requested thumbnail, falling back to the original
+// when the photo has no thumbnail of that size
+func openThumbnail(ctx context.Context, storageService *services.PhotoStorageService, thumbnailService *services.ThumbnailService, photo *models.Photo, thumbPath *string) (io.ReadSeekCloser, error) {
+	if thumbPath != nil && *thumbPath != "" {
+		if file, _, err := thumbnailService.OpenThumbnail(ctx, *thumbPath); err == nil {
+			return file, nil
+		}
+	}
+
+	file, _, err := storageService.Open(ctx, photo.StoredPath)
+	return file, err
+}
+
+// acceptsHEIC checks if the request's Accept header explicitly lists HEIC or HEIF
+func acceptsHEIC(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		if mediaType == "image/heic" || mediaType == "image/heif" {
+			return true
+		}
+	}
+	return false
+}
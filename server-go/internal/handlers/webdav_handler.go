@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+	"github.com/photosync/server/internal/services"
+	"golang.org/x/net/webdav"
+)
+
+// WebDAVPrefix is where the library is mounted
+const WebDAVPrefix = "/dav"
+
+// webDAVMethods are the methods a read-only share answers
+var webDAVMethods = map[string]bool{
+	http.MethodOptions: true,
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	"PROPFIND":         true,
+}
+
+// WebDAVHandler serves each user's library as a read-only WebDAV share.
+// Clients sign in with HTTP Basic auth, using the account email and either
+// the user's API key or an app password; the API key header also works.
+type WebDAVHandler struct {
+	userRepo            repository.UserRepo
+	appPasswordRepo     repository.AppPasswordRepo
+	photoRepo           repository.PhotoRepo
+	collectionRepo      repository.CollectionRepo
+	collectionPhotoRepo repository.CollectionPhotoRepo
+	deviceRepo          repository.DeviceRepo
+	storageService      *services.PhotoStorageService
+	apiKeyHeader        string
+	locks               webdav.LockSystem
+}
+
+// NewWebDAVHandler creates a new WebDAVHandler
+func NewWebDAVHandler(
+	userRepo repository.UserRepo,
+	appPasswordRepo repository.AppPasswordRepo,
+	photoRepo repository.PhotoRepo,
+	collectionRepo repository.CollectionRepo,
+	collectionPhotoRepo repository.CollectionPhotoRepo,
+	deviceRepo repository.DeviceRepo,
+	storageService *services.PhotoStorageService,
+	apiKeyHeader string,
+) *WebDAVHandler {
+	return &WebDAVHandler{
+		userRepo:            userRepo,
+		appPasswordRepo:     appPasswordRepo,
+		photoRepo:           photoRepo,
+		collectionRepo:      collectionRepo,
+		collectionPhotoRepo: collectionPhotoRepo,
+		deviceRepo:          deviceRepo,
+		storageService:      storageService,
+		apiKeyHeader:        apiKeyHeader,
+		locks:               webdav.NewMemLS(),
+	}
+}
+
+// ServeHTTP answers a WebDAV request against the signed-in user's library
+func (h *WebDAVHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, err := h.authenticate(r)
+	if err != nil {
+		log.Printf("Error authenticating WebDAV request: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="PhotoSync", charset="UTF-8"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if !webDAVMethods[r.Method] {
+		w.Header().Set("Allow", "OPTIONS, GET, HEAD, PROPFIND")
+		http.Error(w, "The library is read-only", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Listing a whole library in one response is refused, as RFC 4918 allows.
+	// A missing Depth header means infinity.
+	if r.Method == "PROPFIND" {
+		if depth := r.Header.Get("Depth"); depth != "0" && depth != "1" {
+			http.Error(w, "PROPFIND requires Depth 0 or 1", http.StatusForbidden)
+			return
+		}
+	}
+
+	if r.Method == http.MethodGet {
+		w = newProgressDeadlineWriter(w)
+	}
+
+	dav := &webdav.Handler{
+		Prefix: WebDAVPrefix,
+		FileSystem: services.NewLibraryFS(
+			user, h.photoRepo, h.collectionRepo, h.collectionPhotoRepo, h.deviceRepo, h.storageService,
+		),
+		LockSystem: h.locks,
+		Logger: func(r *http.Request, err error) {
+			if err != nil && !os.IsNotExist(err) {
+				log.Printf("WebDAV %s %s: %v", r.Method, r.URL.Path, err)
+			}
+		},
+	}
+	dav.ServeHTTP(w, r)
+}
+
+// authenticate returns the active user a request signs in as, or nil. With
+// Basic auth the username must be the account email of the key or app
+// password.
+func (h *WebDAVHandler) authenticate(r *http.Request) (*models.User, error) {
+	ctx := r.Context()
+	if key := r.Header.Get(h.apiKeyHeader); key != "" {
+		user, err := h.userRepo.GetByAPIKeyHash(ctx, models.HashAPIKey(key))
+		if err != nil || user == nil || !user.IsActive {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok || password == "" {
+		return nil, nil
+	}
+	hash := models.HashAPIKey(password)
+
+	user, err := h.userRepo.GetByAPIKeyHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	var appPassword *models.AppPassword
+	if user == nil {
+		appPassword, err = h.appPasswordRepo.GetByHash(ctx, hash)
+		if err != nil || appPassword == nil {
+			return nil, err
+		}
+		user, err = h.userRepo.GetByID(ctx, appPassword.UserID)
+		if err != nil || user == nil {
+			return nil, err
+		}
+	}
+
+	if !user.IsActive || !strings.EqualFold(strings.TrimSpace(username), user.Email) {
+		return nil, nil
+	}
+	if appPassword != nil {
+		go h.appPasswordRepo.Touch(context.Background(), appPassword.ID)
+	}
+	return user, nil
+}
@@ -11,19 +11,31 @@ import (
 	"github.com/photosync/server/internal/services"
 )
 
+// upgrader accepts connections from any origin. Apps authenticate with an
+// API key header, which a cross-site page cannot send.
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
 	CheckOrigin: func(r *http.Request) bool {
-		// Allow all origins for now - can be restricted in production
 		return true
 	},
 }
 
+// sameOriginUpgrader is used when the browser's session cookie authenticated
+// the connection. Browsers send cookies with cross-site WebSocket handshakes,
+// so any other page could otherwise open a connection as the user and read
+// their library events. Leaving CheckOrigin unset makes gorilla/websocket
+// reject an Origin whose host differs from the request's.
+var sameOriginUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
 // WebSocketHandler handles WebSocket connections
 type WebSocketHandler struct {
-	hub         *services.WebSocketHub
-	authService *services.AuthService
+	hub           *services.WebSocketHub
+	authService   *services.AuthService
+	libraryEvents *services.LibraryEventService
 }
 
 // NewWebSocketHandler creates a new WebSocketHandler
@@ -34,9 +46,21 @@ func NewWebSocketHandler(hub *services.WebSocketHub, authService *services.AuthS
 	}
 }
 
-// HandleConnection upgrades HTTP to WebSocket and manages the connection
+// SetLibraryEvents enables library events and resume for authenticated connections
+func (h *WebSocketHandler) SetLibraryEvents(libraryEvents *services.LibraryEventService) {
+	h.libraryEvents = libraryEvents
+}
+
+// HandleConnection upgrades HTTP to WebSocket and manages the connection.
+// Authenticated connections (session cookie or API key) receive the user's
+// library events; pass ?resume=<token> to replay events missed while offline.
 func (h *WebSocketHandler) HandleConnection(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	wsUpgrader := &upgrader
+	if middleware.GetSessionFromContext(r.Context()) != nil {
+		wsUpgrader = &sameOriginUpgrader
+	}
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade failed: %v", err)
 		return
@@ -45,18 +69,20 @@ func (h *WebSocketHandler) HandleConnection(w http.ResponseWriter, r *http.Reque
 	clientID := uuid.New().String()
 	client := h.hub.NewClient(clientID, conn)
 
-	// Check if user is authenticated via session cookie
-	if session := middleware.GetSessionFromContext(r.Context()); session != nil {
-		if user := middleware.GetUserFromContext(r.Context()); user != nil {
-			h.hub.SetUserID(client, user.ID)
-		}
-	}
-
 	h.hub.Register(client)
 
 	// Start the write pump in a goroutine
 	go client.WritePump()
 
+	// Check if user is authenticated via session cookie or API key
+	if user := middleware.GetUserFromContext(r.Context()); user != nil {
+		if h.libraryEvents != nil {
+			h.libraryEvents.Attach(client, user.ID, r.URL.Query().Get("resume"))
+		} else {
+			h.hub.SetUserID(client, user.ID)
+		}
+	}
+
 	// Run the read pump (blocks until connection closes)
 	client.ReadPump(h.handleMessage)
 }
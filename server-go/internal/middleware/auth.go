@@ -193,6 +193,40 @@ func SessionAuth(sessionRepo repository.WebSessionRepo, userRepo repository.User
 	}
 }
 
+// OptionalUserAuth identifies the user from a session cookie or API key when
+// one is present, without rejecting anonymous requests. It is used where the
+// handler serves both kinds of client, such as the WebSocket endpoint.
+func OptionalUserAuth(sessionRepo repository.WebSessionRepo, userRepo repository.UserRepo, headerName string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			if cookie, err := r.Cookie("session_token"); err == nil && cookie.Value != "" {
+				session, err := sessionRepo.GetByID(ctx, cookie.Value)
+				if err == nil && session != nil && session.IsActive && !session.IsExpired() {
+					user, err := userRepo.GetByID(ctx, session.UserID)
+					if err == nil && user != nil && user.IsActive {
+						go sessionRepo.Touch(context.Background(), session.ID)
+						ctx = context.WithValue(ctx, SessionContextKey, session)
+						ctx = context.WithValue(ctx, UserContextKey, user)
+						next.ServeHTTP(w, r.WithContext(ctx))
+						return
+					}
+				}
+			}
+
+			if providedKey := r.Header.Get(headerName); providedKey != "" {
+				user, err := userRepo.GetByAPIKeyHash(ctx, models.HashAPIKey(providedKey))
+				if err == nil && user != nil && user.IsActive {
+					ctx = context.WithValue(ctx, UserContextKey, user)
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
 // constantTimeEquals performs a constant-time string comparison
 func constantTimeEquals(a, b string) bool {
 	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ParseTrustedProxies parses proxy addresses given as CIDRs or single IPs
+func ParseTrustedProxies(entries []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// RealIP replaces r.RemoteAddr with the client address from X-Forwarded-For
+// or X-Real-IP, but only when the request comes from a trusted proxy. Anyone
+// else could put any address in those headers, so their requests keep the
+// address of the connection. X-Forwarded-For is read from the right, past
+// any trusted proxies, since a client can prepend whatever it likes.
+func RealIP(trustedProxies []*net.IPNet) func(http.Handler) http.Handler {
+	trusted := func(s string) bool {
+		ip := net.ParseIP(strings.TrimSpace(s))
+		if ip == nil {
+			return false
+		}
+		for _, ipNet := range trustedProxies {
+			if ipNet.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !trusted(remoteHost(r.RemoteAddr)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+				hops := strings.Split(xff, ",")
+				client := ""
+				for i := len(hops) - 1; i >= 0; i-- {
+					client = strings.TrimSpace(hops[i])
+					if !trusted(client) {
+						break
+					}
+				}
+				if net.ParseIP(client) != nil {
+					r.RemoteAddr = client
+				}
+			} else if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(xri) != nil {
+				r.RemoteAddr = xri
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// remoteHost returns the IP part of an address that may or may not carry a port
+func remoteHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clientAddr runs a request through RealIP and returns the address the next
+// handler sees
+func clientAddr(t *testing.T, trustedProxies []string, remoteAddr string, headers map[string]string) string {
+	nets, err := ParseTrustedProxies(trustedProxies)
+	require.NoError(t, err)
+
+	var seen string
+	handler := RealIP(nets)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.RemoteAddr
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	return seen
+}
+
+func TestRealIP(t *testing.T) {
+	loopback := []string{"127.0.0.1/8", "::1/128"}
+
+	t.Run("ignores forwarded headers from untrusted clients", func(t *testing.T) {
+		addr := clientAddr(t, loopback, "203.0.113.7:51234", map[string]string{
+			"X-Forwarded-For": "198.51.100.1",
+			"X-Real-IP":       "198.51.100.2",
+		})
+		assert.Equal(t, "203.0.113.7:51234", addr)
+	})
+
+	t.Run("takes the client from a trusted proxy", func(t *testing.T) {
+		addr := clientAddr(t, loopback, "127.0.0.1:40000", map[string]string{
+			"X-Forwarded-For": "198.51.100.1",
+		})
+		assert.Equal(t, "198.51.100.1", addr)
+	})
+
+	t.Run("ignores addresses a client prepends", func(t *testing.T) {
+		addr := clientAddr(t, loopback, "127.0.0.1:40000", map[string]string{
+			"X-Forwarded-For": "10.0.0.1, 198.51.100.1",
+		})
+		assert.Equal(t, "198.51.100.1", addr, "the proxy appended the real client last")
+	})
+
+	t.Run("skips trusted proxies in a chain", func(t *testing.T) {
+		addr := clientAddr(t, []string{"127.0.0.1", "10.0.0.0/8"}, "127.0.0.1:40000", map[string]string{
+			"X-Forwarded-For": "192.0.2.9, 198.51.100.1, 10.0.0.5",
+		})
+		assert.Equal(t, "198.51.100.1", addr)
+	})
+
+	t.Run("does not trust a proxy that is not listed", func(t *testing.T) {
+		addr := clientAddr(t, loopback, "10.0.0.5:40000", map[string]string{
+			"X-Forwarded-For": "198.51.100.1",
+		})
+		assert.Equal(t, "10.0.0.5:40000", addr)
+	})
+
+	t.Run("keeps the connection address when the header is not an IP", func(t *testing.T) {
+		addr := clientAddr(t, loopback, "127.0.0.1:40000", map[string]string{
+			"X-Forwarded-For": "not-an-ip",
+		})
+		assert.Equal(t, "127.0.0.1:40000", addr)
+	})
+
+	t.Run("falls back to X-Real-IP", func(t *testing.T) {
+		addr := clientAddr(t, loopback, "[::1]:40000", map[string]string{
+			"X-Real-IP": "2001:db8::1",
+		})
+		assert.Equal(t, "2001:db8::1", addr)
+	})
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	nets, err := ParseTrustedProxies([]string{" 10.0.0.0/8 ", "192.0.2.1", "::1", ""})
+	require.NoError(t, err)
+	require.Len(t, nets, 3)
+	assert.Equal(t, "192.0.2.1/32", nets[1].String())
+	assert.Equal(t, "::1/128", nets[2].String())
+
+	_, err = ParseTrustedProxies([]string{"proxy.local"})
+	assert.Error(t, err)
+}
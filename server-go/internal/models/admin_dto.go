@@ -8,19 +8,22 @@ type UpdateUserRequest struct {
 	DisplayName string `json:"displayName"`
 	IsAdmin     bool   `json:"isAdmin"`
 	IsActive    bool   `json:"isActive"`
+	QuotaBytes  *int64 `json:"quotaBytes,omitempty"`  // Unchanged when omitted; 0 = unlimited
+	QuotaPhotos *int   `json:"quotaPhotos,omitempty"` // Unchanged when omitted; 0 = unlimited
 }
 
 // AdminUserResponse contains extended user info for admin views
 type AdminUserResponse struct {
-	ID           string    `json:"id"`
-	Email        string    `json:"email"`
-	DisplayName  string    `json:"displayName"`
-	IsAdmin      bool      `json:"isAdmin"`
-	IsActive     bool      `json:"isActive"`
-	CreatedAt    time.Time `json:"createdAt"`
-	DeviceCount  int       `json:"deviceCount"`
-	SessionCount int       `json:"sessionCount"`
-	PhotoCount   int       `json:"photoCount"`
+	ID           string       `json:"id"`
+	Email        string       `json:"email"`
+	DisplayName  string       `json:"displayName"`
+	IsAdmin      bool         `json:"isAdmin"`
+	IsActive     bool         `json:"isActive"`
+	CreatedAt    time.Time    `json:"createdAt"`
+	DeviceCount  int          `json:"deviceCount"`
+	SessionCount int          `json:"sessionCount"`
+	PhotoCount   int          `json:"photoCount"`
+	Usage        StorageUsage `json:"usage"`
 }
 
 // UserListResponse contains paginated user list
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AppPassword is a revocable credential for clients that can only send a
+// username and password, such as a WebDAV mount in a file manager. Like an
+// API key it is random and only its hash is stored.
+type AppPassword struct {
+	ID           string     `json:"id"`
+	UserID       string     `json:"-"`
+	Name         string     `json:"name"`
+	PasswordHash string     `json:"-"` // Never exposed
+	CreatedAt    time.Time  `json:"createdAt"`
+	LastUsedAt   *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// CreateAppPasswordRequest is the request body for creating an app password
+type CreateAppPasswordRequest struct {
+	Name string `json:"name"`
+}
+
+// CreateAppPasswordResponse carries the new password, which is only shown once
+type CreateAppPasswordResponse struct {
+	AppPassword
+	Password string `json:"password"`
+}
+
+// AppPasswordListResponse lists a user's app passwords
+type AppPasswordListResponse struct {
+	AppPasswords []*AppPassword `json:"appPasswords"`
+}
+
+// NewAppPassword creates an app password for a user and returns it together
+// with the plain password
+func NewAppPassword(userID, name string) (*AppPassword, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", ErrEmptyAppPasswordName
+	}
+
+	password, err := GenerateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &AppPassword{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		Name:         name,
+		PasswordHash: HashAPIKey(password),
+		CreatedAt:    time.Now().UTC(),
+	}, password, nil
+}
+
+// App password errors
+var (
+	ErrEmptyAppPasswordName = UserError{"app password name cannot be empty"}
+	ErrAppPasswordNotFound  = UserError{"app password not found"}
+)
@@ -73,9 +73,21 @@ type Collection struct {
 	CreatedAt    time.Time            `json:"createdAt"`
 	UpdatedAt    time.Time            `json:"updatedAt"`
 
+	// Smart collections choose their photos by rules instead of by hand
+	Kind  CollectionKind        `json:"kind"`
+	Rules *SmartCollectionRules `json:"rules,omitempty"`
+
+	// Galleries offer all photos as one ZIP when the owner allows it
+	AllowDownloads bool `json:"allowDownloads"`
+
+	// Originals served to gallery visitors have this metadata removed
+	MetadataStripping MetadataStripping `json:"metadataStripping"`
+
 	// Computed fields (not stored in DB directly)
-	PhotoCount int  `json:"photoCount,omitempty"`
-	IsOwner    bool `json:"isOwner,omitempty"`
+	PhotoCount        int       `json:"photoCount,omitempty"`
+	IsOwner           bool      `json:"isOwner,omitempty"`
+	Role              ShareRole `json:"role,omitempty"`              // The caller's role if it is shared with them
+	UnstrippableCount int       `json:"unstrippableCount,omitempty"` // Photos whose originals the gallery cannot serve with metadata removed
 }
 
 // NewCollection creates a new collection with generated ID and slug
@@ -98,8 +110,11 @@ func NewCollection(userID, name string) (*Collection, error) {
 		Theme:       ThemeDark,
 		ThemeSource: ThemeSourceExplicit,
 		Visibility:  VisibilityPrivate,
+		Kind:        CollectionManual,
 		CreatedAt:   now,
 		UpdatedAt:   now,
+
+		MetadataStripping: StripLocation,
 	}, nil
 }
 
@@ -145,6 +160,11 @@ func (c *Collection) SetVisibility(visibility CollectionVisibility) {
 		token := GenerateSecretToken()
 		c.SecretToken = &token
 	}
+
+	// Leaving secret_link retires the token, so sharing by link again issues a new one
+	if visibility != VisibilitySecretLink {
+		c.SecretToken = nil
+	}
 }
 
 // CanView checks if a user can view this collection
@@ -164,6 +184,11 @@ func (c *Collection) CanView(userID string) bool {
 	return false
 }
 
+// IsSmart reports whether the collection's photos are chosen by rules
+func (c *Collection) IsSmart() bool {
+	return c.Kind == CollectionSmart
+}
+
 // CanEdit checks if a user can edit this collection
 func (c *Collection) CanEdit(userID string) bool {
 	return c.UserID == userID
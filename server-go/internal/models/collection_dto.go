@@ -2,21 +2,25 @@ package models
 
 // CreateCollectionRequest is the request body for creating a collection
 type CreateCollectionRequest struct {
-	Name        string  `json:"name"`
-	Description *string `json:"description,omitempty"`
-	Slug        *string `json:"slug,omitempty"` // Optional custom slug
-	Theme       *string `json:"theme,omitempty"`
-	CustomCSS   *string `json:"customCss,omitempty"`
+	Name        string                `json:"name"`
+	Description *string               `json:"description,omitempty"`
+	Slug        *string               `json:"slug,omitempty"` // Optional custom slug
+	Theme       *string               `json:"theme,omitempty"`
+	CustomCSS   *string               `json:"customCss,omitempty"`
+	Rules       *SmartCollectionRules `json:"rules,omitempty"` // Makes a smart collection
 }
 
 // UpdateCollectionRequest is the request body for updating a collection
 type UpdateCollectionRequest struct {
-	Name         *string `json:"name,omitempty"`
-	Description  *string `json:"description,omitempty"`
-	Slug         *string `json:"slug,omitempty"`
-	Theme        *string `json:"theme,omitempty"`
-	CustomCSS    *string `json:"customCss,omitempty"`
-	CoverPhotoID *string `json:"coverPhotoId,omitempty"`
+	Name              *string               `json:"name,omitempty"`
+	Description       *string               `json:"description,omitempty"`
+	Slug              *string               `json:"slug,omitempty"`
+	Theme             *string               `json:"theme,omitempty"`
+	CustomCSS         *string               `json:"customCss,omitempty"`
+	CoverPhotoID      *string               `json:"coverPhotoId,omitempty"`
+	Rules             *SmartCollectionRules `json:"rules,omitempty"` // Smart collections only
+	AllowDownloads    *bool                 `json:"allowDownloads,omitempty"`
+	MetadataStripping *string               `json:"metadataStripping,omitempty"` // none, location or all
 }
 
 // UpdateVisibilityRequest changes collection visibility
@@ -41,7 +45,13 @@ type ReorderPhotosRequest struct {
 
 // ShareCollectionRequest shares a collection with users
 type ShareCollectionRequest struct {
-	Emails []string `json:"emails"` // User emails to share with
+	Emails []string `json:"emails"`         // User emails to share with
+	Role   string   `json:"role,omitempty"` // viewer (default), contributor or editor
+}
+
+// UpdateShareRequest changes the role of a share
+type UpdateShareRequest struct {
+	Role string `json:"role"`
 }
 
 // CollectionResponse is the API response for a single collection
@@ -66,10 +76,12 @@ type CollectionSummary struct {
 	Slug         string               `json:"slug"`
 	Theme        CollectionTheme      `json:"theme"`
 	Visibility   CollectionVisibility `json:"visibility"`
+	Kind         CollectionKind       `json:"kind"`
 	PhotoCount   int                  `json:"photoCount"`
 	CoverPhotoID *string              `json:"coverPhotoId,omitempty"`
 	CoverThumb   *string              `json:"coverThumb,omitempty"`
 	IsOwner      bool                 `json:"isOwner"`
+	Role         ShareRole            `json:"role,omitempty"`
 	CreatedAt    string               `json:"createdAt"`
 	UpdatedAt    string               `json:"updatedAt"`
 }
@@ -125,9 +137,10 @@ func GetAvailableThemes() []ThemeInfo {
 
 // PublicGalleryData is the data passed to the public gallery template
 type PublicGalleryData struct {
-	Collection *Collection
-	Photos     []*Photo
-	ThemeCSS   string
-	CustomCSS  string
-	BaseURL    string
+	Collection  *Collection
+	Photos      []*Photo
+	ThemeCSS    string
+	CustomCSS   string
+	BaseURL     string
+	DownloadURL string // Set when the gallery can be downloaded as a ZIP
 }
@@ -0,0 +1,207 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// CollectionLinkUnlockTTL is how long a visitor stays signed in to a
+// password-protected link after entering the password
+const CollectionLinkUnlockTTL = 24 * time.Hour
+
+// MaxCollectionLinkLabelLength caps the length of a link label
+const MaxCollectionLinkLabelLength = 100
+
+// Outcomes recorded in a link's access log
+const (
+	LinkAccessGranted          = "granted"
+	LinkAccessPasswordRequired = "password_required"
+	LinkAccessWrongPassword    = "wrong_password"
+	LinkAccessExpired          = "expired"
+	LinkAccessRevoked          = "revoked"
+	LinkAccessViewLimit        = "view_limit"
+)
+
+// CollectionLink is one share link to a collection. A collection can have
+// many, each with its own label, optional password, expiry and view limit,
+// and each can be revoked without affecting the others.
+type CollectionLink struct {
+	ID           string     `json:"id"`
+	CollectionID string     `json:"collectionId"`
+	Token        string     `json:"token"`
+	Label        string     `json:"label"`
+	PasswordHash *string    `json:"-"`
+	ExpiresAt    *time.Time `json:"expiresAt,omitempty"`
+	MaxViews     *int       `json:"maxViews,omitempty"`
+	ViewCount    int        `json:"viewCount"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	LastViewedAt *time.Time `json:"lastViewedAt,omitempty"`
+	RevokedAt    *time.Time `json:"revokedAt,omitempty"`
+
+	// Computed fields (not stored in DB directly)
+	HasPassword bool   `json:"hasPassword"`
+	URL         string `json:"url,omitempty"`
+}
+
+// CreateCollectionLinkRequest is the request body for creating a share link
+type CreateCollectionLinkRequest struct {
+	Label     string     `json:"label"`
+	Password  string     `json:"password,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	MaxViews  int        `json:"maxViews,omitempty"`
+}
+
+// CollectionLinkListResponse lists a collection's share links
+type CollectionLinkListResponse struct {
+	Links []*CollectionLink `json:"links"`
+}
+
+// CollectionLinkAccess is one visit to a share link
+type CollectionLinkAccess struct {
+	ID         int64     `json:"id"`
+	LinkID     string    `json:"linkId"`
+	AccessedAt time.Time `json:"accessedAt"`
+	IPAddress  string    `json:"ipAddress"`
+	UserAgent  string    `json:"userAgent"`
+	Outcome    string    `json:"outcome"`
+}
+
+// CollectionLinkAccessResponse lists visits to a share link, newest first
+type CollectionLinkAccessResponse struct {
+	Accesses []*CollectionLinkAccess `json:"accesses"`
+}
+
+// NewCollectionLink validates a request and creates a link with a fresh token
+func NewCollectionLink(collectionID string, req CreateCollectionLinkRequest) (*CollectionLink, error) {
+	label := strings.TrimSpace(req.Label)
+	if len(label) > MaxCollectionLinkLabelLength {
+		return nil, ErrCollectionLinkLabelTooLong
+	}
+	now := time.Now().UTC()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		return nil, ErrCollectionLinkExpiryInPast
+	}
+	if req.MaxViews < 0 {
+		return nil, ErrCollectionLinkInvalidViews
+	}
+
+	link := &CollectionLink{
+		ID:           uuid.New().String(),
+		CollectionID: collectionID,
+		Token:        GenerateSecretToken(),
+		Label:        label,
+		ExpiresAt:    req.ExpiresAt,
+		CreatedAt:    now,
+	}
+	if req.MaxViews > 0 {
+		link.MaxViews = &req.MaxViews
+	}
+	if req.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		hashStr := string(hash)
+		link.PasswordHash = &hashStr
+		link.HasPassword = true
+	}
+	return link, nil
+}
+
+// SecretLinkLabel labels the share link behind a collection's own secret token
+const SecretLinkLabel = "Secret link"
+
+// NewSecretTokenLink creates the share link behind a collection's own secret
+// token, which the collection page shows while it is shared by secret link.
+// Like every other link it can expire, be revoked and have its visits logged.
+func NewSecretTokenLink(collection *Collection) *CollectionLink {
+	return &CollectionLink{
+		ID:           uuid.New().String(),
+		CollectionID: collection.ID,
+		Token:        *collection.SecretToken,
+		Label:        SecretLinkLabel,
+		CreatedAt:    time.Now().UTC(),
+	}
+}
+
+// Usable reports why the link can no longer be opened, or nil if it can
+func (l *CollectionLink) Usable(now time.Time) error {
+	if l.RevokedAt != nil {
+		return ErrCollectionLinkRevoked
+	}
+	if l.ExpiresAt != nil && !now.Before(*l.ExpiresAt) {
+		return ErrCollectionLinkExpired
+	}
+	if l.MaxViews != nil && l.ViewCount >= *l.MaxViews {
+		return ErrCollectionLinkViewLimit
+	}
+	return nil
+}
+
+// CheckPassword reports whether the password opens the link
+func (l *CollectionLink) CheckPassword(password string) bool {
+	if l.PasswordHash == nil {
+		return true
+	}
+	return bcrypt.CompareHashAndPassword([]byte(*l.PasswordHash), []byte(password)) == nil
+}
+
+// UnlockValue returns the cookie value that proves the visitor entered the
+// password, valid until the given time. It is keyed by the password hash, so
+// it cannot be forged without it and stops working if the password changes.
+func (l *CollectionLink) UnlockValue(until time.Time) string {
+	exp := strconv.FormatInt(until.Unix(), 10)
+	return exp + "." + l.unlockMAC(exp)
+}
+
+// VerifyUnlock checks a cookie value made by UnlockValue
+func (l *CollectionLink) VerifyUnlock(value string, now time.Time) bool {
+	if l.PasswordHash == nil {
+		return true
+	}
+	exp, mac, ok := strings.Cut(value, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || !now.Before(time.Unix(unix, 0)) {
+		return false
+	}
+	return hmac.Equal([]byte(mac), []byte(l.unlockMAC(exp)))
+}
+
+func (l *CollectionLink) unlockMAC(exp string) string {
+	h := hmac.New(sha256.New, []byte(*l.PasswordHash))
+	h.Write([]byte(l.ID + "\n" + exp))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// AccessOutcome maps why a link cannot be opened to its access log outcome
+func AccessOutcome(err error) string {
+	switch err {
+	case ErrCollectionLinkRevoked:
+		return LinkAccessRevoked
+	case ErrCollectionLinkExpired:
+		return LinkAccessExpired
+	case ErrCollectionLinkViewLimit:
+		return LinkAccessViewLimit
+	}
+	return ""
+}
+
+var (
+	ErrCollectionLinkNotFound     = CollectionError{"share link not found"}
+	ErrCollectionLinkRevoked      = CollectionError{"share link has been revoked"}
+	ErrCollectionLinkExpired      = CollectionError{"share link has expired"}
+	ErrCollectionLinkViewLimit    = CollectionError{"share link has reached its view limit"}
+	ErrCollectionLinkLabelTooLong = CollectionError{"link label is too long"}
+	ErrCollectionLinkExpiryInPast = CollectionError{"expiresAt must be in the future"}
+	ErrCollectionLinkInvalidViews = CollectionError{"maxViews must not be negative"}
+)
@@ -12,6 +12,7 @@ type CollectionPhoto struct {
 	CollectionID string    `json:"collectionId"`
 	PhotoID      string    `json:"photoId"`
 	Position     int       `json:"position"`
+	AddedBy      string    `json:"addedBy"` // User who added the photo; the owner if left empty
 	AddedAt      time.Time `json:"addedAt"`
 }
 
@@ -29,5 +30,6 @@ func NewCollectionPhoto(collectionID, photoID string, position int) *CollectionP
 // CollectionPhotoWithDetails includes photo metadata for API responses
 type CollectionPhotoWithDetails struct {
 	CollectionPhoto
-	Photo *Photo `json:"photo,omitempty"`
+	Photo       *Photo `json:"photo,omitempty"`
+	AddedByUser *User  `json:"addedByUser,omitempty"`
 }
@@ -6,20 +6,51 @@ import (
 	"github.com/google/uuid"
 )
 
+// ShareRole is what a user a collection is shared with may do with it
+type ShareRole string
+
+const (
+	ShareRoleViewer      ShareRole = "viewer"      // Sees the collection
+	ShareRoleContributor ShareRole = "contributor" // Also adds their own photos, and removes the ones they added
+	ShareRoleEditor      ShareRole = "editor"      // Also removes and reorders any photo and edits the name, description and cover
+)
+
+// IsValidShareRole checks if a string is a valid share role
+func IsValidShareRole(r string) bool {
+	switch ShareRole(r) {
+	case ShareRoleViewer, ShareRoleContributor, ShareRoleEditor:
+		return true
+	}
+	return false
+}
+
+// CanAddPhotos reports whether the role may add photos to the collection
+func (r ShareRole) CanAddPhotos() bool {
+	return r == ShareRoleContributor || r == ShareRoleEditor
+}
+
+// CanEdit reports whether the role may remove and reorder any photo and edit
+// the collection's name, description and cover
+func (r ShareRole) CanEdit() bool {
+	return r == ShareRoleEditor
+}
+
 // CollectionShare represents a collection shared with a specific user
 type CollectionShare struct {
 	ID           string    `json:"id"`
 	CollectionID string    `json:"collectionId"`
 	UserID       string    `json:"userId"`
+	Role         ShareRole `json:"role"`
 	CreatedAt    time.Time `json:"createdAt"`
 }
 
 // NewCollectionShare creates a new share association
-func NewCollectionShare(collectionID, userID string) *CollectionShare {
+func NewCollectionShare(collectionID, userID string, role ShareRole) *CollectionShare {
 	return &CollectionShare{
 		ID:           uuid.New().String(),
 		CollectionID: collectionID,
 		UserID:       userID,
+		Role:         role,
 		CreatedAt:    time.Now().UTC(),
 	}
 }
@@ -29,3 +60,8 @@ type CollectionShareWithUser struct {
 	CollectionShare
 	User *User `json:"user,omitempty"`
 }
+
+var (
+	ErrCollectionInvalidShareRole = CollectionError{"role must be viewer, contributor or editor"}
+	ErrCollectionShareNotFound    = CollectionError{"collection is not shared with this user"}
+)
@@ -9,25 +9,28 @@ type UploadResult struct {
 	StoredPath  string    `json:"storedPath" example:"2024/01/IMG_1234.jpg"`
 	UploadedAt  time.Time `json:"uploadedAt" example:"2024-01-15T10:30:00Z"`
 	IsDuplicate bool      `json:"isDuplicate" example:"false"`
+	FileHash    string    `json:"fileHash" example:"a1b2c3d4e5f6..."` // SHA-256 the server computed over the received bytes
 }
 
 // NewUploadResult creates a result for a newly uploaded photo
-func NewUploadResult(id, storedPath string, uploadedAt time.Time) UploadResult {
+func NewUploadResult(id, storedPath, fileHash string, uploadedAt time.Time) UploadResult {
 	return UploadResult{
 		ID:          id,
 		StoredPath:  storedPath,
 		UploadedAt:  uploadedAt,
 		IsDuplicate: false,
+		FileHash:    fileHash,
 	}
 }
 
 // DuplicateUploadResult creates a result for a duplicate photo
-func DuplicateUploadResult(id, storedPath string, uploadedAt time.Time) UploadResult {
+func DuplicateUploadResult(id, storedPath, fileHash string, uploadedAt time.Time) UploadResult {
 	return UploadResult{
 		ID:          id,
 		StoredPath:  storedPath,
 		UploadedAt:  uploadedAt,
 		IsDuplicate: true,
+		FileHash:    fileHash,
 	}
 }
 
@@ -47,12 +50,16 @@ type CheckHashesResult struct {
 // PhotoResponse is a single photo in API responses
 // @Description Photo metadata returned by the API
 type PhotoResponse struct {
-	ID               string    `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
-	OriginalFilename string    `json:"originalFilename" example:"IMG_1234.jpg"`
-	StoredPath       string    `json:"storedPath" example:"2024/01/IMG_1234.jpg"`
-	FileSize         int64     `json:"fileSize" example:"2048576"`
-	DateTaken        time.Time `json:"dateTaken" example:"2024-01-15T10:30:00Z"`
-	UploadedAt       time.Time `json:"uploadedAt" example:"2024-01-15T12:00:00Z"`
+	ID               string     `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	OriginalFilename string     `json:"originalFilename" example:"IMG_1234.jpg"`
+	StoredPath       string     `json:"storedPath" example:"2024/01/IMG_1234.jpg"`
+	FileSize         int64      `json:"fileSize" example:"2048576"`
+	DateTaken        time.Time  `json:"dateTaken" example:"2024-01-15T10:30:00Z"`
+	UploadedAt       time.Time  `json:"uploadedAt" example:"2024-01-15T12:00:00Z"`
+	MediaType        MediaType  `json:"mediaType" example:"image"`
+	Duration         *float64   `json:"duration,omitempty" example:"12.5"`
+	Description      *string    `json:"description,omitempty" example:"Sunset at the beach"`
+	Media            *MediaURLs `json:"media,omitempty"`
 }
 
 // PhotoListResponse is returned when listing photos
@@ -64,6 +71,30 @@ type PhotoListResponse struct {
 	Take       int             `json:"take" example:"50"`
 }
 
+// TrashPhotoResponse is a trashed photo in API responses
+// @Description Trashed photo with its deletion and purge times
+type TrashPhotoResponse struct {
+	PhotoResponse
+	DeletedAt time.Time  `json:"deletedAt" example:"2024-01-20T09:00:00Z"`
+	PurgeAt   *time.Time `json:"purgeAt,omitempty" example:"2024-02-19T09:00:00Z"`
+}
+
+// TrashListResponse is returned when listing the trash
+// @Description Paginated list of trashed photos
+type TrashListResponse struct {
+	Photos        []TrashPhotoResponse `json:"photos"`
+	TotalCount    int                  `json:"totalCount" example:"12"`
+	Skip          int                  `json:"skip" example:"0"`
+	Take          int                  `json:"take" example:"50"`
+	RetentionDays int                  `json:"retentionDays" example:"30"`
+}
+
+// EmptyTrashResponse is returned after emptying the trash
+// @Description Number of photos permanently deleted
+type EmptyTrashResponse struct {
+	Purged int `json:"purged" example:"12"`
+}
+
 // HealthResponse is returned by health check
 // @Description Server health status
 type HealthResponse struct {
@@ -81,8 +112,12 @@ type AppInfoResponse struct {
 // @Description Error response
 type ErrorResponse struct {
 	Error string `json:"error" example:"Photo not found"`
+	Code  string `json:"code,omitempty" example:"hash_mismatch"` // Machine-readable reason, set for errors a client should act on
 }
 
+// ErrorCodeHashMismatch tells the client its upload arrived corrupted and must be sent again
+const ErrorCodeHashMismatch = "hash_mismatch"
+
 // PhotoToResponse converts a Photo to PhotoResponse
 func PhotoToResponse(p *Photo) PhotoResponse {
 	return PhotoResponse{
@@ -92,6 +127,10 @@ func PhotoToResponse(p *Photo) PhotoResponse {
 		FileSize:         p.FileSize,
 		DateTaken:        p.DateTaken,
 		UploadedAt:       p.UploadedAt,
+		MediaType:        p.MediaType,
+		Duration:         p.Duration,
+		Description:      p.Description,
+		Media:            p.Media,
 	}
 }
 
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DuplicateGroupStatus represents the review state of a duplicate group
+type DuplicateGroupStatus string
+
+const (
+	DuplicateGroupPending   DuplicateGroupStatus = "pending"
+	DuplicateGroupDismissed DuplicateGroupStatus = "dismissed"
+)
+
+// DuplicateGroup is a set of a user's photos that look alike
+type DuplicateGroup struct {
+	ID        string                 `json:"id"`
+	UserID    string                 `json:"userId"`
+	Status    DuplicateGroupStatus   `json:"status"`
+	CreatedAt time.Time              `json:"createdAt"`
+	Members   []DuplicateGroupMember `json:"members"`
+}
+
+// DuplicateGroupMember is one photo in a duplicate group. Distance is the
+// Hamming distance between its perceptual hash and the group's first photo.
+type DuplicateGroupMember struct {
+	PhotoID  string `json:"photoId"`
+	Distance int    `json:"distance"`
+	Photo    *Photo `json:"photo,omitempty"`
+}
+
+// NewDuplicateGroup creates a new pending duplicate group
+func NewDuplicateGroup(userID string, members []DuplicateGroupMember) *DuplicateGroup {
+	return &DuplicateGroup{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Status:    DuplicateGroupPending,
+		CreatedAt: time.Now().UTC(),
+		Members:   members,
+	}
+}
+
+// HasPhoto reports whether the photo is a member of the group
+func (g *DuplicateGroup) HasPhoto(photoID string) bool {
+	for _, m := range g.Members {
+		if m.PhotoID == photoID {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveDuplicateGroupRequest is the request body for keeping one photo of a group
+type ResolveDuplicateGroupRequest struct {
+	KeepPhotoID string `json:"keepPhotoId"`
+}
+
+// ResolveDuplicateGroupResponse reports which photos were moved to the trash
+type ResolveDuplicateGroupResponse struct {
+	KeptPhotoID     string   `json:"keptPhotoId"`
+	TrashedPhotoIDs []string `json:"trashedPhotoIds"`
+}
+
+// DuplicateGroupListResponse is returned when listing duplicate groups
+type DuplicateGroupListResponse struct {
+	Groups []*DuplicateGroup `json:"groups"`
+}
+
+// DuplicateGroupError represents duplicate review errors
+type DuplicateGroupError struct {
+	Message string
+}
+
+func (e DuplicateGroupError) Error() string {
+	return e.Message
+}
+
+var (
+	ErrDuplicateGroupNotFound = DuplicateGroupError{"duplicate group not found"}
+	ErrPhotoNotInGroup        = DuplicateGroupError{"photo is not part of the duplicate group"}
+)
+
+// PhotoPerceptualHash pairs a photo with its perceptual hash for grouping
+type PhotoPerceptualHash struct {
+	PhotoID string
+	Hash    string
+}
@@ -0,0 +1,149 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ExportFormat is the archive format of a library export
+type ExportFormat string
+
+const (
+	ExportFormatZip ExportFormat = "zip"
+	ExportFormatTar ExportFormat = "tar"
+)
+
+// ExportManifestName is the path of the manifest inside an export archive
+const ExportManifestName = "manifest.json"
+
+// ExportOptions selects which photos go into an export. Empty filters are ignored.
+type ExportOptions struct {
+	UserID       string // Set from the authenticated user or the admin's path parameter
+	Format       ExportFormat
+	DateFrom     *time.Time
+	DateTo       *time.Time
+	CollectionID string
+}
+
+// ParseExportOptions reads export filters from URL query parameters.
+// Dates accept RFC3339 or YYYY-MM-DD; a date-only "to" covers the whole day.
+func ParseExportOptions(values url.Values) (*ExportOptions, error) {
+	opts := &ExportOptions{
+		Format:       ExportFormat(strings.ToLower(strings.TrimSpace(values.Get("format")))),
+		CollectionID: strings.TrimSpace(values.Get("collectionId")),
+	}
+	if opts.Format == "" {
+		opts.Format = ExportFormatZip
+	}
+	if opts.Format != ExportFormatZip && opts.Format != ExportFormatTar {
+		return nil, invalidExportParam("format")
+	}
+
+	var err error
+	if opts.DateFrom, err = parseSearchDate(values.Get("from"), false); err != nil {
+		return nil, invalidExportParam("from")
+	}
+	if opts.DateTo, err = parseSearchDate(values.Get("to"), true); err != nil {
+		return nil, invalidExportParam("to")
+	}
+	return opts, nil
+}
+
+func invalidExportParam(name string) error {
+	return PhotoError{fmt.Sprintf("invalid export parameter: %s", name)}
+}
+
+// ExportCollectionRef names a collection a photo belongs to
+type ExportCollectionRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// ExportDeviceRef names the device a photo was uploaded from
+type ExportDeviceRef struct {
+	ID       string `json:"id"`
+	Name     string `json:"name,omitempty"`
+	Platform string `json:"platform,omitempty"`
+}
+
+// PhotoSidecar is the JSON file written next to each original in an export
+type PhotoSidecar struct {
+	ID               string                `json:"id"`
+	OriginalFilename string                `json:"originalFilename"`
+	FileHash         string                `json:"fileHash"`
+	FileSize         int64                 `json:"fileSize"`
+	MediaType        MediaType             `json:"mediaType"`
+	DateTaken        time.Time             `json:"dateTaken"`
+	UploadedAt       time.Time             `json:"uploadedAt"`
+	Duration         *float64              `json:"duration,omitempty"`
+	Width            *int                  `json:"width,omitempty"`
+	Height           *int                  `json:"height,omitempty"`
+	CameraMake       *string               `json:"cameraMake,omitempty"`
+	CameraModel      *string               `json:"cameraModel,omitempty"`
+	LensModel        *string               `json:"lensModel,omitempty"`
+	FocalLength      *string               `json:"focalLength,omitempty"`
+	Aperture         *string               `json:"aperture,omitempty"`
+	ShutterSpeed     *string               `json:"shutterSpeed,omitempty"`
+	ISO              *int                  `json:"iso,omitempty"`
+	Orientation      int                   `json:"orientation"`
+	Latitude         *float64              `json:"latitude,omitempty"`
+	Longitude        *float64              `json:"longitude,omitempty"`
+	Altitude         *float64              `json:"altitude,omitempty"`
+	Description      *string               `json:"description,omitempty"`
+	OriginDevice     *ExportDeviceRef      `json:"originDevice,omitempty"`
+	Collections      []ExportCollectionRef `json:"collections"`
+}
+
+// NewPhotoSidecar copies a photo's metadata into a sidecar
+func NewPhotoSidecar(p *Photo) *PhotoSidecar {
+	return &PhotoSidecar{
+		ID:               p.ID,
+		OriginalFilename: p.OriginalFilename,
+		FileHash:         p.FileHash,
+		FileSize:         p.FileSize,
+		MediaType:        p.MediaType,
+		DateTaken:        p.DateTaken,
+		UploadedAt:       p.UploadedAt,
+		Duration:         p.Duration,
+		Width:            p.Width,
+		Height:           p.Height,
+		CameraMake:       p.CameraMake,
+		CameraModel:      p.CameraModel,
+		LensModel:        p.LensModel,
+		FocalLength:      p.FocalLength,
+		Aperture:         p.Aperture,
+		ShutterSpeed:     p.ShutterSpeed,
+		ISO:              p.ISO,
+		Orientation:      p.Orientation,
+		Latitude:         p.Latitude,
+		Longitude:        p.Longitude,
+		Altitude:         p.Altitude,
+		Description:      p.Description,
+		Collections:      []ExportCollectionRef{},
+	}
+}
+
+// ExportManifestEntry lists one exported original
+type ExportManifestEntry struct {
+	PhotoID  string `json:"photoId"`
+	Path     string `json:"path"`
+	Sidecar  string `json:"sidecar"`
+	FileHash string `json:"sha256"`
+	FileSize int64  `json:"size"`
+}
+
+// ExportManifest is written last in an export archive. Photos whose original
+// could not be read are listed under Missing instead of Photos.
+type ExportManifest struct {
+	UserID       string                `json:"userId"`
+	ExportedAt   time.Time             `json:"exportedAt"`
+	DateFrom     *time.Time            `json:"dateFrom,omitempty"`
+	DateTo       *time.Time            `json:"dateTo,omitempty"`
+	CollectionID string                `json:"collectionId,omitempty"`
+	Count        int                   `json:"count"`
+	TotalSize    int64                 `json:"totalSize"`
+	Photos       []ExportManifestEntry `json:"photos"`
+	Missing      []string              `json:"missing"`
+}
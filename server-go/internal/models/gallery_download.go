@@ -0,0 +1,41 @@
+package models
+
+// GalleryDownloadSize picks which file of each photo a gallery ZIP holds
+type GalleryDownloadSize string
+
+const (
+	GalleryDownloadOriginal GalleryDownloadSize = "original"
+	GalleryDownloadLarge    GalleryDownloadSize = "large"
+	GalleryDownloadMedium   GalleryDownloadSize = "medium"
+	GalleryDownloadSmall    GalleryDownloadSize = "small"
+)
+
+// ParseGalleryDownloadSize reads the size of a gallery download; empty means originals
+func ParseGalleryDownloadSize(s string) (GalleryDownloadSize, error) {
+	switch GalleryDownloadSize(s) {
+	case "":
+		return GalleryDownloadOriginal, nil
+	case GalleryDownloadOriginal, GalleryDownloadLarge, GalleryDownloadMedium, GalleryDownloadSmall:
+		return GalleryDownloadSize(s), nil
+	}
+	return "", ErrGalleryDownloadInvalidSize
+}
+
+// Thumbnail returns the photo's thumbnail of this size, or nil for originals
+func (s GalleryDownloadSize) Thumbnail(photo *Photo) *string {
+	switch s {
+	case GalleryDownloadLarge:
+		return photo.ThumbLarge
+	case GalleryDownloadMedium:
+		return photo.ThumbMedium
+	case GalleryDownloadSmall:
+		return photo.ThumbSmall
+	}
+	return nil
+}
+
+var (
+	ErrGalleryDownloadsDisabled   = CollectionError{"downloads are not enabled for this gallery"}
+	ErrGalleryDownloadInvalidSize = CollectionError{"size must be original, large, medium or small"}
+	ErrGalleryDownloadRateLimited = CollectionError{"too many downloads, try again later"}
+)
@@ -0,0 +1,208 @@
+package models
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ImportSource says where an import reads its files from
+type ImportSource string
+
+const (
+	// ImportSourceArchive reads one or more uploaded zip or tar archives
+	ImportSourceArchive ImportSource = "archive"
+	// ImportSourceDirectory reads a directory on the server
+	ImportSourceDirectory ImportSource = "directory"
+)
+
+// ImportArchiveFormat is the container format of an uploaded import archive
+type ImportArchiveFormat string
+
+const (
+	ImportArchiveZip   ImportArchiveFormat = "zip"
+	ImportArchiveTar   ImportArchiveFormat = "tar"
+	ImportArchiveTarGz ImportArchiveFormat = "tar.gz"
+)
+
+// ImportArchiveFormatForFilename returns the archive format implied by a
+// filename, or "" when it is not a supported archive
+func ImportArchiveFormatForFilename(filename string) ImportArchiveFormat {
+	name := strings.ToLower(filepath.Base(filename))
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return ImportArchiveZip
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return ImportArchiveTarGz
+	case strings.HasSuffix(name, ".tar"):
+		return ImportArchiveTar
+	}
+	return ""
+}
+
+// ImportArchiveFormatForContentType returns the archive format for a request
+// media type, or "" when it is not a supported archive
+func ImportArchiveFormatForContentType(mediaType string) ImportArchiveFormat {
+	switch mediaType {
+	case "application/zip", "application/x-zip-compressed":
+		return ImportArchiveZip
+	case "application/x-tar", "application/tar":
+		return ImportArchiveTar
+	case "application/gzip", "application/x-gzip", "application/x-compressed-tar":
+		return ImportArchiveTarGz
+	}
+	return ""
+}
+
+// ImportJobStatus is the state of an import job
+type ImportJobStatus string
+
+const (
+	ImportStatusQueued    ImportJobStatus = "queued"
+	ImportStatusRunning   ImportJobStatus = "running"
+	ImportStatusCompleted ImportJobStatus = "completed"
+	ImportStatusFailed    ImportJobStatus = "failed"
+	ImportStatusCancelled ImportJobStatus = "cancelled"
+)
+
+// MaxImportErrors caps the error report kept for a job; the Failed count
+// keeps counting past it
+const MaxImportErrors = 500
+
+// ImportError reports a file that could not be imported
+type ImportError struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// ImportArchive is one uploaded archive of an import job
+type ImportArchive struct {
+	Path   string              `json:"path"`
+	Format ImportArchiveFormat `json:"format"`
+}
+
+// ImportJob is a background import of an exported library (Google Takeout,
+// Apple Photos or a plain folder tree). Done records every file already
+// handled so an interrupted job resumes where it stopped.
+type ImportJob struct {
+	ID          string            `json:"id"`
+	UserID      string            `json:"userId"`
+	Source      ImportSource      `json:"source"`
+	Directory   string            `json:"directory,omitempty"`
+	Archives    []ImportArchive   `json:"archives,omitempty"`
+	Status      ImportJobStatus   `json:"status"`
+	Total       int               `json:"total"`
+	Processed   int               `json:"processed"`
+	Imported    int               `json:"imported"`
+	Duplicates  int               `json:"duplicates"`
+	Failed      int               `json:"failed"`
+	Errors      []ImportError     `json:"errors"`
+	Error       string            `json:"error,omitempty"` // Why a failed job stopped
+	Albums      map[string]string `json:"albums"`          // Album name -> collection ID
+	Done        map[string]bool   `json:"done"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+	CompletedAt *time.Time        `json:"completedAt,omitempty"`
+}
+
+// NewImportJob creates a queued import job for the user
+func NewImportJob(userID string, source ImportSource) *ImportJob {
+	now := time.Now().UTC()
+	return &ImportJob{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Source:    source,
+		Status:    ImportStatusQueued,
+		Errors:    []ImportError{},
+		Albums:    make(map[string]string),
+		Done:      make(map[string]bool),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// IsFinished returns true once the job will not run again on its own
+func (j *ImportJob) IsFinished() bool {
+	return j.Status == ImportStatusCompleted || j.Status == ImportStatusFailed || j.Status == ImportStatusCancelled
+}
+
+// AddError adds an entry to the error report
+func (j *ImportJob) AddError(path, message string) {
+	if len(j.Errors) < MaxImportErrors {
+		j.Errors = append(j.Errors, ImportError{Path: path, Error: message})
+	}
+}
+
+// ToResponse converts an ImportJob to its API response
+func (j *ImportJob) ToResponse() ImportJobResponse {
+	albums := make([]string, 0, len(j.Albums))
+	for name := range j.Albums {
+		albums = append(albums, name)
+	}
+	return ImportJobResponse{
+		ID:          j.ID,
+		Source:      j.Source,
+		Directory:   j.Directory,
+		Status:      j.Status,
+		Total:       j.Total,
+		Processed:   j.Processed,
+		Imported:    j.Imported,
+		Duplicates:  j.Duplicates,
+		Failed:      j.Failed,
+		Errors:      j.Errors,
+		Error:       j.Error,
+		Albums:      albums,
+		CreatedAt:   j.CreatedAt,
+		UpdatedAt:   j.UpdatedAt,
+		CompletedAt: j.CompletedAt,
+	}
+}
+
+// ImportJobResponse reports an import job's progress
+// @Description Progress and error report of a library import
+type ImportJobResponse struct {
+	ID          string          `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Source      ImportSource    `json:"source" example:"archive"`
+	Directory   string          `json:"directory,omitempty"`
+	Status      ImportJobStatus `json:"status" example:"running"`
+	Total       int             `json:"total" example:"1200"`
+	Processed   int             `json:"processed" example:"350"`
+	Imported    int             `json:"imported" example:"340"`
+	Duplicates  int             `json:"duplicates" example:"8"`
+	Failed      int             `json:"failed" example:"2"`
+	Errors      []ImportError   `json:"errors,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	Albums      []string        `json:"albums"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+	CompletedAt *time.Time      `json:"completedAt,omitempty"`
+}
+
+// ImportJobListResponse lists a user's import jobs
+type ImportJobListResponse struct {
+	Jobs []ImportJobResponse `json:"jobs"`
+}
+
+// CreateDirectoryImportRequest starts an import from a server directory
+// @Description Import a directory below the configured import root into a user's library
+type CreateDirectoryImportRequest struct {
+	UserID string `json:"userId" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Path   string `json:"path" example:"alice/Takeout"`
+}
+
+// Import errors
+var (
+	ErrImportNotFound           = PhotoError{"import not found"}
+	ErrImportUnsupportedArchive = PhotoError{"archive must be a zip, tar or tar.gz file"}
+	ErrImportNoArchives         = PhotoError{"no archive was uploaded"}
+	ErrImportDirectoryDisabled  = PhotoError{"directory imports are not enabled on this server"}
+	ErrImportPathNotAllowed     = PhotoError{"path is outside the import root"}
+	ErrImportPathNotFound       = PhotoError{"import directory does not exist"}
+	ErrImportFinished           = PhotoError{"import has already finished"}
+	ErrImportNotResumable       = PhotoError{"only failed or cancelled imports can be resumed"}
+	ErrImportArchiveTooLarge    = PhotoError{"archive is larger than this server accepts"}
+	ErrImportInsufficientSpace  = PhotoError{"not enough free disk space on the server for this archive"}
+	ErrImportEntrySizeMismatch  = PhotoError{"file is larger than the archive says"}
+)
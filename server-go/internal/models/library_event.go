@@ -0,0 +1,78 @@
+package models
+
+import "time"
+
+// LibraryEventType describes a change to a user's library pushed over the WebSocket
+type LibraryEventType string
+
+const (
+	// LibraryEventPhotoAdded: a new photo entered the library (upload or orphan claim)
+	LibraryEventPhotoAdded LibraryEventType = "photo_added"
+	// LibraryEventPhotoTrashed: the photo was moved to the trash
+	LibraryEventPhotoTrashed LibraryEventType = "photo_trashed"
+	// LibraryEventPhotoRestored: the photo came back out of the trash
+	LibraryEventPhotoRestored LibraryEventType = "photo_restored"
+	// LibraryEventPhotoDeleted: the photo was permanently deleted
+	LibraryEventPhotoDeleted LibraryEventType = "photo_deleted"
+	// LibraryEventThumbnailsReady: thumbnails were generated for an existing photo
+	LibraryEventThumbnailsReady LibraryEventType = "thumbnails_ready"
+	// LibraryEventCollectionChanged: a collection the user owns or can see was changed
+	LibraryEventCollectionChanged LibraryEventType = "collection_changed"
+	// LibraryEventOrphanAssigned: an administrator assigned an orphan file to the user
+	LibraryEventOrphanAssigned LibraryEventType = "orphan_assigned"
+)
+
+// LibraryEvent is one real-time notification about a user's library. Seq
+// increases per user; ResumeToken can be presented when reconnecting to
+// receive the events that were missed.
+type LibraryEvent struct {
+	Seq          int64            `json:"seq"`
+	ResumeToken  string           `json:"resumeToken"`
+	Type         LibraryEventType `json:"type"`
+	PhotoID      string           `json:"photoId,omitempty"`
+	Photo        *PhotoResponse   `json:"photo,omitempty"`
+	CollectionID string           `json:"collectionId,omitempty"`
+	Change       string           `json:"change,omitempty"` // What happened to the collection, e.g. "photos_added"
+	OrphanID     string           `json:"orphanId,omitempty"`
+	CreatedAt    time.Time        `json:"createdAt"`
+}
+
+// Collection changes reported in LibraryEvent.Change
+const (
+	CollectionChangeUpdated       = "updated"
+	CollectionChangeDeleted       = "deleted"
+	CollectionChangePhotosAdded   = "photos_added"
+	CollectionChangePhotosRemoved = "photos_removed"
+	CollectionChangeReordered     = "reordered"
+	CollectionChangeShared        = "shared"
+	CollectionChangeUnshared      = "unshared"
+)
+
+// NewPhotoEvent creates an event about a photo. Deleted photos carry only their ID.
+func NewPhotoEvent(eventType LibraryEventType, photo *Photo) *LibraryEvent {
+	event := &LibraryEvent{Type: eventType, PhotoID: photo.ID}
+	if eventType != LibraryEventPhotoDeleted {
+		resp := PhotoToResponse(photo)
+		event.Photo = &resp
+	}
+	return event
+}
+
+// NewCollectionEvent creates an event about a collection
+func NewCollectionEvent(collectionID, change string) *LibraryEvent {
+	return &LibraryEvent{Type: LibraryEventCollectionChanged, CollectionID: collectionID, Change: change}
+}
+
+// NewOrphanAssignedEvent creates an event for an orphan file assigned to the user
+func NewOrphanAssignedEvent(orphanID string) *LibraryEvent {
+	return &LibraryEvent{Type: LibraryEventOrphanAssigned, OrphanID: orphanID}
+}
+
+// LibraryResumeResult is sent once an authenticated WebSocket connection has
+// caught up. When ResetRequired is true the missed events are no longer
+// available and the client must resync through /api/sync/changes.
+type LibraryResumeResult struct {
+	ResumeToken   string `json:"resumeToken"`
+	Replayed      int    `json:"replayed"`
+	ResetRequired bool   `json:"resetRequired"`
+}
@@ -0,0 +1,83 @@
+package models
+
+import "time"
+
+// MediaVariant is the rendition of a photo a media URL points to
+type MediaVariant string
+
+const (
+	MediaOriginal MediaVariant = "original"
+	MediaSmall    MediaVariant = "small"
+	MediaMedium   MediaVariant = "medium"
+	MediaLarge    MediaVariant = "large"
+
+	// Originals with metadata removed, for gallery visitors
+	MediaOriginalNoLocation MediaVariant = "original-nolocation"
+	MediaOriginalNoMetadata MediaVariant = "original-nometadata"
+
+	// Thumbnails for gallery visitors. A missing thumbnail falls back to the
+	// original with the same metadata removed, never to the stored original.
+	MediaSmallNoLocation  MediaVariant = "small-nolocation"
+	MediaMediumNoLocation MediaVariant = "medium-nolocation"
+	MediaLargeNoLocation  MediaVariant = "large-nolocation"
+	MediaSmallNoMetadata  MediaVariant = "small-nometadata"
+	MediaMediumNoMetadata MediaVariant = "medium-nometadata"
+	MediaLargeNoMetadata  MediaVariant = "large-nometadata"
+)
+
+// IsValid reports whether the variant is one the media endpoint serves
+func (v MediaVariant) IsValid() bool {
+	return v.Size() != ""
+}
+
+// Size returns the plain variant this one is a rendition of: original,
+// small, medium or large. It is empty for unknown variants.
+func (v MediaVariant) Size() MediaVariant {
+	switch v {
+	case MediaOriginal, MediaOriginalNoLocation, MediaOriginalNoMetadata:
+		return MediaOriginal
+	case MediaSmall, MediaSmallNoLocation, MediaSmallNoMetadata:
+		return MediaSmall
+	case MediaMedium, MediaMediumNoLocation, MediaMediumNoMetadata:
+		return MediaMedium
+	case MediaLarge, MediaLargeNoLocation, MediaLargeNoMetadata:
+		return MediaLarge
+	}
+	return ""
+}
+
+// Stripping returns the metadata the variant has removed
+func (v MediaVariant) Stripping() MetadataStripping {
+	switch v {
+	case MediaOriginalNoLocation, MediaSmallNoLocation, MediaMediumNoLocation, MediaLargeNoLocation:
+		return StripLocation
+	case MediaOriginalNoMetadata, MediaSmallNoMetadata, MediaMediumNoMetadata, MediaLargeNoMetadata:
+		return StripAll
+	}
+	return StripNone
+}
+
+// MediaURLs are signed URLs for a photo's original and thumbnails. They need
+// no API key or session, so they work in <img> tags and native image
+// loaders, and they stop working at ExpiresAt.
+type MediaURLs struct {
+	Original  string    `json:"original"`
+	Small     string    `json:"small"`
+	Medium    string    `json:"medium"`
+	Large     string    `json:"large"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// MediaURLError represents signed media URL errors
+type MediaURLError struct {
+	Message string
+}
+
+func (e MediaURLError) Error() string {
+	return e.Message
+}
+
+var (
+	ErrMediaURLInvalid = MediaURLError{"invalid media URL signature"}
+	ErrMediaURLExpired = MediaURLError{"media URL has expired"}
+)
@@ -0,0 +1,45 @@
+package models
+
+// MetadataStripping says which metadata is removed from the originals a
+// collection's gallery serves. Thumbnails never carry metadata, and stored
+// originals are never modified; visitors get a cached copy instead.
+type MetadataStripping string
+
+const (
+	StripNone     MetadataStripping = "none"     // Originals are served as stored
+	StripLocation MetadataStripping = "location" // GPS, XMP (which holds PhotoSync's IDs), IPTC and ImageUniqueID go
+	StripAll      MetadataStripping = "all"      // Everything but orientation and colour profile goes
+)
+
+// IsValidMetadataStripping reports whether s names a stripping mode
+func IsValidMetadataStripping(s string) bool {
+	switch MetadataStripping(s) {
+	case StripNone, StripLocation, StripAll:
+		return true
+	}
+	return false
+}
+
+// Strips reports whether originals are served as modified copies
+func (m MetadataStripping) Strips() bool {
+	return m == StripLocation || m == StripAll
+}
+
+// Variant is the media variant that serves size, which is original, small,
+// medium or large, to visitors of a gallery with this mode
+func (m MetadataStripping) Variant(size MediaVariant) MediaVariant {
+	if !m.Strips() {
+		return size
+	}
+	for _, v := range []MediaVariant{
+		MediaOriginalNoLocation, MediaSmallNoLocation, MediaMediumNoLocation, MediaLargeNoLocation,
+		MediaOriginalNoMetadata, MediaSmallNoMetadata, MediaMediumNoMetadata, MediaLargeNoMetadata,
+	} {
+		if v.Size() == size && v.Stripping() == m {
+			return v
+		}
+	}
+	return size
+}
+
+var ErrCollectionInvalidStripping = CollectionError{"metadataStripping must be none, location or all"}
@@ -86,7 +86,8 @@ type BulkDeleteOrphanRequest struct {
 
 // ClaimOrphanRequest is the request to claim an orphan file
 type ClaimOrphanRequest struct {
-	DeviceID string `json:"deviceId,omitempty"`
+	DeviceID      string `json:"deviceId,omitempty"`
+	ContentSHA256 string `json:"contentSha256,omitempty"` // Optional hash the client expects the file to have
 }
 
 // ClaimOrphanResponse is the response after claiming an orphan file
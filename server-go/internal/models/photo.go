@@ -8,6 +8,35 @@ import (
 	"github.com/google/uuid"
 )
 
+// MediaType distinguishes still images from video clips
+type MediaType string
+
+const (
+	MediaTypeImage MediaType = "image"
+	MediaTypeVideo MediaType = "video"
+)
+
+// videoExtensions lists file extensions treated as video
+var videoExtensions = map[string]bool{
+	".mp4": true,
+	".mov": true,
+	".m4v": true,
+	".3gp": true,
+}
+
+// IsVideoFile checks if a filename has a video extension
+func IsVideoFile(filename string) bool {
+	return videoExtensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// MediaTypeForFilename returns the media type implied by a filename's extension
+func MediaTypeForFilename(filename string) MediaType {
+	if IsVideoFile(filename) {
+		return MediaTypeVideo
+	}
+	return MediaTypeImage
+}
+
 // Photo represents a synced photo stored on the server
 type Photo struct {
 	ID               string    `json:"id"`
@@ -20,6 +49,10 @@ type Photo struct {
 	UserID           *string   `json:"userId,omitempty"`
 	OriginDeviceID   *string   `json:"originDeviceId,omitempty"`
 
+	// Media type and video duration in seconds (nil for images)
+	MediaType MediaType `json:"mediaType"`
+	Duration  *float64  `json:"duration,omitempty"`
+
 	// Thumbnail paths (relative to storage base)
 	ThumbSmall  *string `json:"thumbSmall,omitempty"`
 	ThumbMedium *string `json:"thumbMedium,omitempty"`
@@ -43,6 +76,18 @@ type Photo struct {
 	// Image dimensions
 	Width  *int `json:"width,omitempty"`
 	Height *int `json:"height,omitempty"`
+
+	// Perceptual (difference) hash as 16 hex digits, used to find near-duplicates
+	PerceptualHash *string `json:"perceptualHash,omitempty"`
+
+	// Caption carried over from an imported library
+	Description *string `json:"description,omitempty"`
+
+	// Set when the photo is in the trash; nil for live photos
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+
+	// Signed URLs added to API responses; not stored
+	Media *MediaURLs `json:"media,omitempty"`
 }
 
 // NewPhoto creates a new Photo with validation and sanitization
@@ -68,6 +113,7 @@ func NewPhoto(originalFilename, storedPath, fileHash string, fileSize int64, dat
 		FileSize:         fileSize,
 		DateTaken:        dateTaken,
 		UploadedAt:       time.Now().UTC(),
+		MediaType:        MediaTypeForFilename(originalFilename),
 		Orientation:      1, // Default: normal orientation
 	}, nil
 }
@@ -94,6 +140,16 @@ func sanitizeFilename(filename string) string {
 	return replacer.Replace(name)
 }
 
+// IsVideo returns true if the photo record is a video clip
+func (p *Photo) IsVideo() bool {
+	return p.MediaType == MediaTypeVideo
+}
+
+// IsTrashed returns true if the photo has been moved to the trash
+func (p *Photo) IsTrashed() bool {
+	return p.DeletedAt != nil
+}
+
 // Errors
 type PhotoError struct {
 	Message string
@@ -113,4 +169,13 @@ var (
 	ErrInvalidExtension = PhotoError{"file extension not allowed"}
 	ErrFileTooLarge     = PhotoError{"file size exceeds maximum allowed"}
 	ErrPathTraversal    = PhotoError{"invalid path - path traversal detected"}
+	ErrPhotoNotInTrash  = PhotoError{"photo is not in the trash"}
+
+	// ErrHashMismatch means the received content is not what the client (or an
+	// earlier scan) said it was, usually because the transfer was truncated
+	ErrHashMismatch       = PhotoError{"content SHA-256 does not match the expected hash"}
+	ErrInvalidContentHash = PhotoError{"expected content hash is not a valid SHA-256 hex digest"}
+
+	ErrStorageQuotaExceeded = PhotoError{"storage quota exceeded"}
+	ErrPhotoQuotaExceeded   = PhotoError{"photo count quota exceeded"}
 )
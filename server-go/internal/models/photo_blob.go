@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// PhotoBlob is a stored original shared by every photo row with the same
+// content. RefCount is the number of photo rows (live or trashed) that point
+// at it; the file is only removed from storage once it drops to zero.
+type PhotoBlob struct {
+	StoredPath string    `json:"storedPath"`
+	FileHash   string    `json:"fileHash"`
+	FileSize   int64     `json:"fileSize"`
+	RefCount   int       `json:"refCount"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// PhotoChangeType describes what happened to a photo in a user's change log
+type PhotoChangeType string
+
+const (
+	// PhotoChangeAdded: the photo entered the library (upload, orphan claim or restore from trash)
+	PhotoChangeAdded PhotoChangeType = "added"
+	// PhotoChangeUpdated: the photo's metadata or thumbnails changed
+	PhotoChangeUpdated PhotoChangeType = "updated"
+	// PhotoChangeDeleted: the photo left the library (trash, purge or a change of owner)
+	PhotoChangeDeleted PhotoChangeType = "deleted"
+	// PhotoChangeClaimed: an existing photo was claimed by a device or transferred to the user
+	PhotoChangeClaimed PhotoChangeType = "claimed"
+)
+
+// PhotoChange is one entry in a user's change log. Seq increases
+// monotonically across the server, so it is also increasing per user.
+// Only the newest entry per photo is guaranteed to survive compaction, so
+// clients must treat every non-deleted entry as an upsert.
+type PhotoChange struct {
+	Seq       int64           `json:"seq"`
+	UserID    string          `json:"userId"`
+	PhotoID   string          `json:"photoId"`
+	Type      PhotoChangeType `json:"type"`
+	ChangedAt time.Time       `json:"changedAt"`
+}
+
+// IsTombstone reports whether the change removes the photo from the library
+func (c *PhotoChange) IsTombstone() bool {
+	return c.Type == PhotoChangeDeleted
+}
@@ -82,4 +82,23 @@ func TestNewPhoto(t *testing.T) {
 
 		assert.NotEqual(t, photo1.ID, photo2.ID)
 	})
+
+	t.Run("detects media type from extension", func(t *testing.T) {
+		image, err := NewPhoto("IMG_0001.HEIC", "path1", "hash1", 100, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, MediaTypeImage, image.MediaType)
+		assert.False(t, image.IsVideo())
+
+		video, err := NewPhoto("IMG_0002.MOV", "path2", "hash2", 100, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, MediaTypeVideo, video.MediaType)
+		assert.True(t, video.IsVideo())
+	})
+}
+
+func TestIsVideoFile(t *testing.T) {
+	assert.True(t, IsVideoFile("clip.mp4"))
+	assert.True(t, IsVideoFile("2024/01/clip.M4V"))
+	assert.False(t, IsVideoFile("photo.jpg"))
+	assert.False(t, IsVideoFile("mp4"))
 }
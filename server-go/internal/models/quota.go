@@ -0,0 +1,58 @@
+package models
+
+// QuotaWarningPercent is the share of a quota at which users are warned
+const QuotaWarningPercent = 90
+
+// StorageUsage reports a user's storage use against their quotas.
+// A quota of 0 means unlimited.
+type StorageUsage struct {
+	UsedBytes   int64   `json:"usedBytes"`
+	QuotaBytes  int64   `json:"quotaBytes"`
+	UsedPhotos  int     `json:"usedPhotos"`
+	QuotaPhotos int     `json:"quotaPhotos"`
+	PercentUsed float64 `json:"percentUsed"` // Of whichever quota is closest to its limit
+	NearLimit   bool    `json:"nearLimit"`
+}
+
+// Usage returns the user's current storage usage
+func (u *User) Usage() StorageUsage {
+	usage := StorageUsage{
+		UsedBytes:   u.UsedBytes,
+		QuotaBytes:  u.QuotaBytes,
+		UsedPhotos:  u.UsedPhotos,
+		QuotaPhotos: u.QuotaPhotos,
+	}
+	usage.PercentUsed = quotaPercent(u.UsedBytes, u.QuotaBytes, 0, int64(u.UsedPhotos), int64(u.QuotaPhotos), 0)
+	usage.NearLimit = usage.PercentUsed >= QuotaWarningPercent
+	return usage
+}
+
+// CheckQuota returns an error if adding one photo of size bytes would exceed a quota
+func (u *User) CheckQuota(size int64) error {
+	if u.QuotaBytes > 0 && u.UsedBytes+size > u.QuotaBytes {
+		return ErrStorageQuotaExceeded
+	}
+	if u.QuotaPhotos > 0 && u.UsedPhotos+1 > u.QuotaPhotos {
+		return ErrPhotoQuotaExceeded
+	}
+	return nil
+}
+
+// IsQuotaError reports whether err is one of the quota errors
+func IsQuotaError(err error) bool {
+	return err == ErrStorageQuotaExceeded || err == ErrPhotoQuotaExceeded
+}
+
+// quotaPercent returns the higher of the byte and photo usage percentages
+func quotaPercent(usedBytes, quotaBytes, addBytes, usedPhotos, quotaPhotos, addPhotos int64) float64 {
+	var percent float64
+	if quotaBytes > 0 {
+		percent = float64(usedBytes+addBytes) * 100 / float64(quotaBytes)
+	}
+	if quotaPhotos > 0 {
+		if p := float64(usedPhotos+addPhotos) * 100 / float64(quotaPhotos); p > percent {
+			percent = p
+		}
+	}
+	return percent
+}
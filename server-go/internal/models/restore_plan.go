@@ -0,0 +1,179 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RestorePlanStatus is the state of a device restore plan
+type RestorePlanStatus string
+
+const (
+	RestorePlanActive    RestorePlanStatus = "active"
+	RestorePlanCompleted RestorePlanStatus = "completed"
+	RestorePlanCancelled RestorePlanStatus = "cancelled"
+)
+
+const (
+	// DefaultRestoreManifestLimit is the manifest page size used when the client does not ask for one
+	DefaultRestoreManifestLimit = 200
+	// MaxRestoreManifestLimit caps the page size of a manifest request
+	MaxRestoreManifestLimit = 1000
+)
+
+// RestorePlan is the set of photos a device should download, for example to
+// bring a replacement phone back to where the old one was. Its photos are
+// fixed when the plan is made, so the manifest pages stay stable while the
+// device works through them, and the server records each photo the device
+// confirms so an interrupted restore carries on where it stopped.
+type RestorePlan struct {
+	ID             string            `json:"id"`
+	UserID         string            `json:"-"`
+	DeviceID       string            `json:"deviceId"`
+	SourceDeviceID *string           `json:"sourceDeviceId,omitempty"`
+	CollectionID   *string           `json:"collectionId,omitempty"`
+	DateFrom       *time.Time        `json:"dateFrom,omitempty"`
+	DateTo         *time.Time        `json:"dateTo,omitempty"`
+	Status         RestorePlanStatus `json:"status"`
+	CreatedAt      time.Time         `json:"createdAt"`
+	UpdatedAt      time.Time         `json:"updatedAt"`
+	CompletedAt    *time.Time        `json:"completedAt,omitempty"`
+
+	// Progress over the plan's photos that are still in the library
+	TotalItems      int   `json:"totalItems"`
+	TotalBytes      int64 `json:"totalBytes"`
+	DownloadedItems int   `json:"downloadedItems"`
+	DownloadedBytes int64 `json:"downloadedBytes"`
+}
+
+// RestorePlanItem is one photo in a restore plan's manifest
+type RestorePlanItem struct {
+	Position         int        `json:"position"`
+	PhotoID          string     `json:"photoId"`
+	FileHash         string     `json:"fileHash"`
+	FileSize         int64      `json:"fileSize"`
+	OriginalFilename string     `json:"originalFilename"`
+	DateTaken        time.Time  `json:"dateTaken"`
+	MediaType        MediaType  `json:"mediaType"`
+	DownloadURL      string     `json:"downloadUrl"`
+	DownloadedAt     *time.Time `json:"downloadedAt,omitempty"`
+}
+
+// CreateRestorePlanRequest selects the photos a device should restore. With
+// no filter the plan covers the whole library. Year is a shorthand for the
+// dateFrom/dateTo range of that calendar year (UTC).
+type CreateRestorePlanRequest struct {
+	DeviceID       string     `json:"deviceId"`                 // The device being restored
+	SourceDeviceID string     `json:"sourceDeviceId,omitempty"` // Only photos uploaded from this device
+	CollectionID   string     `json:"collectionId,omitempty"`
+	Year           int        `json:"year,omitempty"`
+	DateFrom       *time.Time `json:"dateFrom,omitempty"`
+	DateTo         *time.Time `json:"dateTo,omitempty"`
+}
+
+// RestorePlanListResponse lists restore plans
+type RestorePlanListResponse struct {
+	Plans []*RestorePlan `json:"plans"`
+}
+
+// RestoreManifestResponse is one page of a restore plan's manifest
+type RestoreManifestResponse struct {
+	Plan       *RestorePlan      `json:"plan"`
+	Items      []RestorePlanItem `json:"items"`
+	Pagination PaginationInfo    `json:"pagination"`
+}
+
+// RestoreConfirmation reports that a device has stored a photo. The hash is
+// that of the downloaded bytes, so a corrupt download is not counted.
+type RestoreConfirmation struct {
+	PhotoID  string `json:"photoId"`
+	FileHash string `json:"fileHash"`
+}
+
+// ConfirmRestoreItemsRequest is the request body for confirming downloads
+type ConfirmRestoreItemsRequest struct {
+	Items []RestoreConfirmation `json:"items"`
+}
+
+// ConfirmRestoreItemsResponse reports which confirmations were accepted.
+// Rejected lists photo IDs that are not in the plan or whose hash did not match.
+type ConfirmRestoreItemsResponse struct {
+	Confirmed int          `json:"confirmed"`
+	Rejected  []string     `json:"rejected"`
+	Plan      *RestorePlan `json:"plan"`
+}
+
+// NewRestorePlan validates a request and creates an active plan for it
+func NewRestorePlan(userID string, req CreateRestorePlanRequest) (*RestorePlan, error) {
+	if req.DeviceID == "" {
+		return nil, ErrRestoreDeviceRequired
+	}
+
+	dateFrom, dateTo := req.DateFrom, req.DateTo
+	if req.Year != 0 {
+		if dateFrom != nil || dateTo != nil {
+			return nil, ErrRestoreYearWithRange
+		}
+		if req.Year < 1800 || req.Year > 9999 {
+			return nil, ErrRestoreInvalidYear
+		}
+		from := time.Date(req.Year, 1, 1, 0, 0, 0, 0, time.UTC)
+		to := from.AddDate(1, 0, 0).Add(-time.Nanosecond)
+		dateFrom, dateTo = &from, &to
+	}
+	if dateFrom != nil && dateTo != nil && dateTo.Before(*dateFrom) {
+		return nil, ErrRestoreInvalidRange
+	}
+
+	now := time.Now().UTC()
+	plan := &RestorePlan{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		DeviceID:  req.DeviceID,
+		DateFrom:  dateFrom,
+		DateTo:    dateTo,
+		Status:    RestorePlanActive,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if req.SourceDeviceID != "" {
+		plan.SourceDeviceID = &req.SourceDeviceID
+	}
+	if req.CollectionID != "" {
+		plan.CollectionID = &req.CollectionID
+	}
+	return plan, nil
+}
+
+// SearchQuery returns the photo search that selects the plan's photos
+func (p *RestorePlan) SearchQuery() *PhotoSearchQuery {
+	q := &PhotoSearchQuery{UserID: p.UserID, DateFrom: p.DateFrom, DateTo: p.DateTo}
+	if p.SourceDeviceID != nil {
+		q.DeviceID = *p.SourceDeviceID
+	}
+	if p.CollectionID != nil {
+		q.CollectionID = *p.CollectionID
+	}
+	return q
+}
+
+// RestorePlanError represents restore plan errors
+type RestorePlanError struct {
+	Message string
+}
+
+func (e RestorePlanError) Error() string {
+	return e.Message
+}
+
+var (
+	ErrRestorePlanNotFound   = RestorePlanError{"restore plan not found"}
+	ErrRestorePlanFinished   = RestorePlanError{"restore plan is no longer active"}
+	ErrRestoreDeviceRequired = RestorePlanError{"deviceId is required"}
+	ErrRestoreDeviceNotFound = RestorePlanError{"device not found"}
+	ErrRestoreYearWithRange  = RestorePlanError{"set either year or dateFrom/dateTo, not both"}
+	ErrRestoreInvalidYear    = RestorePlanError{"invalid year"}
+	ErrRestoreInvalidRange   = RestorePlanError{"dateFrom must not be after dateTo"}
+	ErrRestoreInvalidCursor  = RestorePlanError{"invalid cursor"}
+)
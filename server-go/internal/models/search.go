@@ -0,0 +1,248 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultSearchLimit is the page size used when the client does not ask for one
+	DefaultSearchLimit = 50
+	// MaxSearchLimit caps the page size of a search request
+	MaxSearchLimit = 200
+)
+
+// BoundingBox is a latitude/longitude rectangle. MinLng may be greater than
+// MaxLng for boxes that cross the antimeridian.
+type BoundingBox struct {
+	MinLat float64 `json:"minLat"`
+	MinLng float64 `json:"minLng"`
+	MaxLat float64 `json:"maxLat"`
+	MaxLng float64 `json:"maxLng"`
+}
+
+// PhotoSearchQuery holds the filters for a photo search. Empty fields are ignored.
+type PhotoSearchQuery struct {
+	UserID       string // Set from the authenticated user, never from the request
+	DateFrom     *time.Time
+	DateTo       *time.Time
+	CameraMake   string
+	CameraModel  string
+	LensModel    string
+	ISOMin       *int
+	ISOMax       *int
+	ApertureMin  *float64
+	ApertureMax  *float64
+	Filename     string // Case-insensitive substring of the original filename
+	DeviceID     string // Origin device
+	HasGPS       *bool
+	BoundingBox  *BoundingBox
+	CollectionID string
+	Cursor       string // Opaque SearchCursor from the previous page
+	Limit        int
+}
+
+// SearchCursor marks where a page of search results ended. It carries the
+// last photo's sort key rather than just its ID, so paging carries on when
+// that photo is deleted or trashed before the next page is fetched.
+type SearchCursor struct {
+	DateTaken time.Time
+	PhotoID   string
+}
+
+// Encode returns the cursor in the opaque form handed to clients
+func (c SearchCursor) Encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.DateTaken.Format(time.RFC3339Nano) + "|" + c.PhotoID))
+}
+
+// DecodeSearchCursor parses a cursor made by Encode
+func DecodeSearchCursor(s string) (*SearchCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	date, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return nil, fmt.Errorf("malformed search cursor")
+	}
+	dateTaken, err := time.Parse(time.RFC3339Nano, date)
+	if err != nil {
+		return nil, err
+	}
+	return &SearchCursor{DateTaken: dateTaken, PhotoID: id}, nil
+}
+
+// FacetCount is the number of matching photos for one facet value
+type FacetCount struct {
+	Value string `json:"value"`
+	Label string `json:"label,omitempty"`
+	Count int    `json:"count"`
+}
+
+// CameraFacet is the number of matching photos taken with one camera
+type CameraFacet struct {
+	Make  string `json:"make"`
+	Model string `json:"model"`
+	Count int    `json:"count"`
+}
+
+// SearchFacets summarises the full result set of a search
+type SearchFacets struct {
+	TotalCount int           `json:"totalCount"`
+	Cameras    []CameraFacet `json:"cameras"`
+	Years      []FacetCount  `json:"years"`
+	Devices    []FacetCount  `json:"devices"`
+}
+
+// PhotoSearchResponse is returned by the search endpoints
+// @Description Page of search results. Facets are only included on the first page.
+type PhotoSearchResponse struct {
+	Photos     []PhotoResponse `json:"photos"`
+	Pagination PaginationInfo  `json:"pagination"`
+	Facets     *SearchFacets   `json:"facets,omitempty"`
+}
+
+// ParsePhotoSearchQuery builds a search query from URL query parameters.
+// Dates accept RFC3339 or YYYY-MM-DD; a date-only "to" covers the whole day.
+// The bounding box is "minLat,minLng,maxLat,maxLng".
+func ParsePhotoSearchQuery(values url.Values) (*PhotoSearchQuery, error) {
+	q := &PhotoSearchQuery{
+		CameraMake:   strings.TrimSpace(values.Get("cameraMake")),
+		CameraModel:  strings.TrimSpace(values.Get("cameraModel")),
+		LensModel:    strings.TrimSpace(values.Get("lens")),
+		Filename:     strings.TrimSpace(values.Get("filename")),
+		DeviceID:     strings.TrimSpace(values.Get("deviceId")),
+		CollectionID: strings.TrimSpace(values.Get("collectionId")),
+		Cursor:       strings.TrimSpace(values.Get("cursor")),
+		Limit:        DefaultSearchLimit,
+	}
+
+	var err error
+	if q.DateFrom, err = parseSearchDate(values.Get("from"), false); err != nil {
+		return nil, invalidSearchParam("from")
+	}
+	if q.DateTo, err = parseSearchDate(values.Get("to"), true); err != nil {
+		return nil, invalidSearchParam("to")
+	}
+	if q.ISOMin, err = parseSearchInt(values.Get("isoMin")); err != nil {
+		return nil, invalidSearchParam("isoMin")
+	}
+	if q.ISOMax, err = parseSearchInt(values.Get("isoMax")); err != nil {
+		return nil, invalidSearchParam("isoMax")
+	}
+	if q.ApertureMin, err = parseSearchFloat(values.Get("apertureMin")); err != nil {
+		return nil, invalidSearchParam("apertureMin")
+	}
+	if q.ApertureMax, err = parseSearchFloat(values.Get("apertureMax")); err != nil {
+		return nil, invalidSearchParam("apertureMax")
+	}
+
+	if s := values.Get("hasGps"); s != "" {
+		hasGPS, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, invalidSearchParam("hasGps")
+		}
+		q.HasGPS = &hasGPS
+	}
+
+	if s := values.Get("bbox"); s != "" {
+		if q.BoundingBox, err = parseBoundingBox(s); err != nil {
+			return nil, invalidSearchParam("bbox")
+		}
+	}
+
+	if q.Cursor != "" {
+		if _, err := DecodeSearchCursor(q.Cursor); err != nil {
+			return nil, invalidSearchParam("cursor")
+		}
+	}
+
+	if s := values.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 {
+			return nil, invalidSearchParam("limit")
+		}
+		if limit > MaxSearchLimit {
+			limit = MaxSearchLimit
+		}
+		q.Limit = limit
+	}
+
+	return q, nil
+}
+
+func invalidSearchParam(name string) error {
+	return PhotoError{fmt.Sprintf("invalid search parameter: %s", name)}
+}
+
+func parseSearchDate(s string, endOfDay bool) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		t = t.UTC()
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return &t, nil
+}
+
+func parseSearchInt(s string) (*int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func parseSearchFloat(s string) (*float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func parseBoundingBox(s string) (*BoundingBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("expected 4 coordinates")
+	}
+
+	coords := make([]float64, 4)
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, err
+		}
+		coords[i] = v
+	}
+
+	box := &BoundingBox{MinLat: coords[0], MinLng: coords[1], MaxLat: coords[2], MaxLng: coords[3]}
+	if !box.valid() {
+		return nil, fmt.Errorf("coordinates out of range")
+	}
+	return box, nil
+}
+
+// valid reports whether the box's coordinates are in range
+func (b *BoundingBox) valid() bool {
+	return b.MinLat <= b.MaxLat && b.MinLat >= -90 && b.MaxLat <= 90 &&
+		b.MinLng >= -180 && b.MinLng <= 180 && b.MaxLng >= -180 && b.MaxLng <= 180
+}
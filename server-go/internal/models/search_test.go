@@ -0,0 +1,73 @@
+package models
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePhotoSearchQuery(t *testing.T) {
+	t.Run("defaults with no parameters", func(t *testing.T) {
+		q, err := ParsePhotoSearchQuery(url.Values{})
+
+		require.NoError(t, err)
+		assert.Equal(t, DefaultSearchLimit, q.Limit)
+		assert.Nil(t, q.DateFrom)
+		assert.Nil(t, q.HasGPS)
+		assert.Nil(t, q.BoundingBox)
+	})
+
+	t.Run("parses filters", func(t *testing.T) {
+		values, _ := url.ParseQuery("from=2024-01-01&to=2024-01-31&cameraMake=Canon&isoMin=100&apertureMax=2.8" +
+			"&filename=IMG&hasGps=true&bbox=40,-75,41,-73&limit=500")
+
+		q, err := ParsePhotoSearchQuery(values)
+
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), *q.DateFrom)
+		assert.Equal(t, time.Date(2024, 1, 31, 23, 59, 59, 999999999, time.UTC), *q.DateTo)
+		assert.Equal(t, "Canon", q.CameraMake)
+		assert.Equal(t, 100, *q.ISOMin)
+		assert.Equal(t, 2.8, *q.ApertureMax)
+		assert.Equal(t, "IMG", q.Filename)
+		assert.True(t, *q.HasGPS)
+		assert.Equal(t, &BoundingBox{MinLat: 40, MinLng: -75, MaxLat: 41, MaxLng: -73}, q.BoundingBox)
+		assert.Equal(t, MaxSearchLimit, q.Limit)
+	})
+
+	t.Run("accepts bounding box across the antimeridian", func(t *testing.T) {
+		q, err := ParsePhotoSearchQuery(url.Values{"bbox": {"-10,170,10,-170"}})
+
+		require.NoError(t, err)
+		assert.Equal(t, 170.0, q.BoundingBox.MinLng)
+		assert.Equal(t, -170.0, q.BoundingBox.MaxLng)
+	})
+
+	t.Run("rejects invalid values", func(t *testing.T) {
+		for _, raw := range []string{
+			"from=yesterday",
+			"isoMin=high",
+			"hasGps=maybe",
+			"bbox=1,2,3",
+			"bbox=50,0,40,10",
+			"limit=0",
+			"cursor=photo-1",
+		} {
+			values, _ := url.ParseQuery(raw)
+			_, err := ParsePhotoSearchQuery(values)
+			assert.Error(t, err, raw)
+		}
+	})
+}
+
+func TestSearchCursor_RoundTrip(t *testing.T) {
+	taken := time.Date(2024, 6, 1, 9, 30, 0, 123456789, time.FixedZone("", 2*3600))
+	cursor, err := DecodeSearchCursor(SearchCursor{DateTaken: taken, PhotoID: "photo-1"}.Encode())
+	require.NoError(t, err)
+	assert.True(t, taken.Equal(cursor.DateTaken))
+	assert.Equal(t, taken.Format(time.RFC3339Nano), cursor.DateTaken.Format(time.RFC3339Nano), "the offset is kept")
+	assert.Equal(t, "photo-1", cursor.PhotoID)
+}
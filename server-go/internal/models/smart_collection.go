@@ -0,0 +1,88 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// CollectionKind says how a collection's photos are chosen
+type CollectionKind string
+
+const (
+	CollectionManual CollectionKind = "manual" // Photos are added and ordered by hand
+	CollectionSmart  CollectionKind = "smart"  // Photos are the ones matching the collection's rules
+)
+
+// MaxSmartRadiusKm caps the radius of a location rule. Distances are worked
+// out on a flat map around the centre, which is only accurate for small areas.
+const MaxSmartRadiusKm = 1000
+
+// MaxFilenamePatternLength caps the length of a filename rule
+const MaxFilenamePatternLength = 200
+
+// GeoRadius is a circle on the map
+type GeoRadius struct {
+	Lat      float64 `json:"lat"`
+	Lng      float64 `json:"lng"`
+	RadiusKm float64 `json:"radiusKm"`
+}
+
+// SmartCollectionRules choose the photos of a smart collection. A photo of
+// the collection's owner belongs to it if it matches every rule that is set.
+// The rules are evaluated whenever the collection is read, so new uploads
+// show up without any work.
+type SmartCollectionRules struct {
+	DateFrom           *time.Time   `json:"dateFrom,omitempty"`
+	DateTo             *time.Time   `json:"dateTo,omitempty"`
+	CameraMake         string       `json:"cameraMake,omitempty"`
+	CameraModel        string       `json:"cameraModel,omitempty"`
+	DeviceID           string       `json:"deviceId,omitempty"`        // Origin device
+	BoundingBox        *BoundingBox `json:"boundingBox,omitempty"`     // MinLng > MaxLng crosses the antimeridian
+	Near               *GeoRadius   `json:"near,omitempty"`            // Taken within a radius of a point
+	FilenamePattern    string       `json:"filenamePattern,omitempty"` // Case-insensitive, with * and ? wildcards
+	UploadedWithinDays int          `json:"uploadedWithinDays,omitempty"`
+}
+
+// Normalize trims the text rules and checks that the rules can be used
+func (r *SmartCollectionRules) Normalize() error {
+	r.CameraMake = strings.TrimSpace(r.CameraMake)
+	r.CameraModel = strings.TrimSpace(r.CameraModel)
+	r.DeviceID = strings.TrimSpace(r.DeviceID)
+	r.FilenamePattern = strings.TrimSpace(r.FilenamePattern)
+
+	if r.DateFrom == nil && r.DateTo == nil && r.CameraMake == "" && r.CameraModel == "" &&
+		r.DeviceID == "" && r.BoundingBox == nil && r.Near == nil && r.FilenamePattern == "" &&
+		r.UploadedWithinDays == 0 {
+		return ErrSmartRulesEmpty
+	}
+	if r.DateFrom != nil && r.DateTo != nil && r.DateFrom.After(*r.DateTo) {
+		return ErrSmartRulesInvalidDates
+	}
+	if r.BoundingBox != nil && !r.BoundingBox.valid() {
+		return ErrSmartRulesInvalidBox
+	}
+	if n := r.Near; n != nil {
+		if n.Lat < -90 || n.Lat > 90 || n.Lng < -180 || n.Lng > 180 ||
+			n.RadiusKm <= 0 || n.RadiusKm > MaxSmartRadiusKm {
+			return ErrSmartRulesInvalidRadius
+		}
+	}
+	if len(r.FilenamePattern) > MaxFilenamePatternLength {
+		return ErrSmartRulesPatternTooLong
+	}
+	if r.UploadedWithinDays < 0 {
+		return ErrSmartRulesInvalidDays
+	}
+	return nil
+}
+
+var (
+	ErrCollectionSmart          = CollectionError{"photos of a smart collection are chosen by its rules"}
+	ErrCollectionNotSmart       = CollectionError{"only smart collections have rules"}
+	ErrSmartRulesEmpty          = CollectionError{"a smart collection needs at least one rule"}
+	ErrSmartRulesInvalidDates   = CollectionError{"dateFrom must not be after dateTo"}
+	ErrSmartRulesInvalidBox     = CollectionError{"boundingBox coordinates are out of range"}
+	ErrSmartRulesInvalidRadius  = CollectionError{"near needs a valid point and a radius between 0 and 1000 km"}
+	ErrSmartRulesPatternTooLong = CollectionError{"filenamePattern is too long"}
+	ErrSmartRulesInvalidDays    = CollectionError{"uploadedWithinDays must not be negative"}
+)
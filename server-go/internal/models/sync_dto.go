@@ -4,13 +4,14 @@ import "time"
 
 // SyncStatusResponse for GET /api/sync/status
 type SyncStatusResponse struct {
-	TotalPhotos       int        `json:"totalPhotos"`
-	DevicePhotos      int        `json:"devicePhotos"`
-	OtherDevicePhotos int        `json:"otherDevicePhotos"`
-	LegacyPhotos      int        `json:"legacyPhotos"`
-	LastSyncAt        *time.Time `json:"lastSyncAt,omitempty"`
-	ServerVersion     int        `json:"serverVersion"`
-	NeedsLegacyClaim  bool       `json:"needsLegacyClaim"`
+	TotalPhotos       int           `json:"totalPhotos"`
+	DevicePhotos      int           `json:"devicePhotos"`
+	OtherDevicePhotos int           `json:"otherDevicePhotos"`
+	LegacyPhotos      int           `json:"legacyPhotos"`
+	LastSyncAt        *time.Time    `json:"lastSyncAt,omitempty"`
+	ServerVersion     int           `json:"serverVersion"`
+	NeedsLegacyClaim  bool          `json:"needsLegacyClaim"`
+	Usage             *StorageUsage `json:"usage,omitempty"`
 }
 
 // SyncPhotosRequest for POST /api/sync/photos
@@ -30,8 +31,11 @@ type SyncPhotoItem struct {
 	FileSize         int64             `json:"fileSize"`
 	DateTaken        time.Time         `json:"dateTaken"`
 	UploadedAt       time.Time         `json:"uploadedAt"`
+	MediaType        MediaType         `json:"mediaType"`
+	Duration         *float64          `json:"duration,omitempty"`
 	OriginDevice     *OriginDeviceInfo `json:"originDevice,omitempty"`
 	ThumbnailURL     string            `json:"thumbnailUrl,omitempty"`
+	Media            *MediaURLs        `json:"media,omitempty"`
 	Width            *int              `json:"width,omitempty"`
 	Height           *int              `json:"height,omitempty"`
 }
@@ -64,6 +68,29 @@ type SyncInfo struct {
 	ServerVersion int `json:"serverVersion"`
 }
 
+// SyncChangeItem is one change-log entry in GET /api/sync/changes. Photo holds
+// the current state for upserts and is omitted for tombstones. An entry whose
+// photo has since left the library is reported as a tombstone.
+type SyncChangeItem struct {
+	Seq       int64           `json:"seq"`
+	PhotoID   string          `json:"photoId"`
+	Type      PhotoChangeType `json:"type"`
+	ChangedAt time.Time       `json:"changedAt"`
+	Photo     *SyncPhotoItem  `json:"photo,omitempty"`
+}
+
+// SyncChangesResponse for GET /api/sync/changes. Clients store NextSince and
+// pass it as since on the next call. ResetRequired means tombstones after
+// since were compacted away: the client must resync with /api/sync/photos and
+// continue from LatestSeq.
+type SyncChangesResponse struct {
+	Changes       []SyncChangeItem `json:"changes"`
+	NextSince     int64            `json:"nextSince"`
+	LatestSeq     int64            `json:"latestSeq"`
+	HasMore       bool             `json:"hasMore"`
+	ResetRequired bool             `json:"resetRequired"`
+}
+
 // ClaimLegacyRequest for POST /api/sync/claim-legacy
 type ClaimLegacyRequest struct {
 	DeviceID string   `json:"deviceId"`
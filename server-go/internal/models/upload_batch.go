@@ -0,0 +1,175 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UploadBatchItemStatus is the state of one file in a batch upload
+type UploadBatchItemStatus string
+
+const (
+	// BatchItemNeeded is waiting for the client to send the file
+	BatchItemNeeded UploadBatchItemStatus = "needed"
+	// BatchItemExisting was already in the user's library when the manifest arrived
+	BatchItemExisting UploadBatchItemStatus = "existing"
+	// BatchItemRejected failed validation (size, extension or hash format) and should not be sent
+	BatchItemRejected UploadBatchItemStatus = "rejected"
+	// BatchItemUploaded was stored as a new photo
+	BatchItemUploaded UploadBatchItemStatus = "uploaded"
+	// BatchItemDuplicate arrived but matched a photo uploaded in the meantime
+	BatchItemDuplicate UploadBatchItemStatus = "duplicate"
+	// BatchItemFailed could not be processed; the client may send it again
+	BatchItemFailed UploadBatchItemStatus = "failed"
+	// BatchItemUnexpected is a received file whose content matches no needed manifest entry
+	BatchItemUnexpected UploadBatchItemStatus = "unexpected"
+)
+
+// UploadBatchItem is one manifest entry and its progress
+type UploadBatchItem struct {
+	Hash             string                `json:"hash"`
+	Size             int64                 `json:"size"`
+	OriginalFilename string                `json:"originalFilename"`
+	DateTaken        *time.Time            `json:"dateTaken,omitempty"`
+	Status           UploadBatchItemStatus `json:"status"`
+	PhotoID          string                `json:"photoId,omitempty"`
+	Error            string                `json:"error,omitempty"`
+}
+
+// IsNeeded returns true while the server still wants the file
+func (i *UploadBatchItem) IsNeeded() bool {
+	return i.Status == BatchItemNeeded || i.Status == BatchItemFailed
+}
+
+// UploadBatch is a manifest-driven bulk upload. The ID doubles as the upload
+// token the client presents when sending files.
+type UploadBatch struct {
+	ID        string             `json:"id"`
+	UserID    string             `json:"userId"`
+	DeviceID  string             `json:"deviceId,omitempty"`
+	Items     []*UploadBatchItem `json:"items"`
+	CreatedAt time.Time          `json:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt"`
+	ExpiresAt time.Time          `json:"expiresAt"`
+}
+
+// NewUploadBatch creates an empty batch for the user
+func NewUploadBatch(userID, deviceID string, ttl time.Duration) *UploadBatch {
+	now := time.Now().UTC()
+	return &UploadBatch{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		DeviceID:  deviceID,
+		Items:     []*UploadBatchItem{},
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+}
+
+// IsExpired returns true if the batch has passed its expiry time
+func (b *UploadBatch) IsExpired() bool {
+	return time.Now().UTC().After(b.ExpiresAt)
+}
+
+// Item returns the manifest entry for a hash, or nil
+func (b *UploadBatch) Item(hash string) *UploadBatchItem {
+	for _, item := range b.Items {
+		if item.Hash == hash {
+			return item
+		}
+	}
+	return nil
+}
+
+// ToResponse converts an UploadBatch to its API response
+func (b *UploadBatch) ToResponse() UploadBatchResponse {
+	resp := UploadBatchResponse{
+		UploadToken: b.ID,
+		Needed:      []string{},
+		Existing:    []string{},
+		Rejected:    []UploadBatchItemResult{},
+		Total:       len(b.Items),
+		ExpiresAt:   b.ExpiresAt,
+	}
+	for _, item := range b.Items {
+		switch {
+		case item.IsNeeded():
+			resp.Needed = append(resp.Needed, item.Hash)
+		case item.Status == BatchItemExisting:
+			resp.Existing = append(resp.Existing, item.Hash)
+		case item.Status == BatchItemRejected:
+			resp.Rejected = append(resp.Rejected, item.ToResult())
+		default:
+			resp.Completed++
+		}
+	}
+	return resp
+}
+
+// ToResult converts an item to its per-file result
+func (i *UploadBatchItem) ToResult() UploadBatchItemResult {
+	return UploadBatchItemResult{
+		Hash:             i.Hash,
+		OriginalFilename: i.OriginalFilename,
+		Status:           i.Status,
+		PhotoID:          i.PhotoID,
+		Error:            i.Error,
+	}
+}
+
+// UploadManifestItem describes one file the client wants to back up
+type UploadManifestItem struct {
+	Hash      string `json:"hash" example:"a1b2c3d4e5f6..."`
+	Size      int64  `json:"size" example:"2048576"`
+	Filename  string `json:"filename" example:"IMG_1234.jpg"`
+	DateTaken string `json:"dateTaken,omitempty" example:"2024-01-15T10:30:00Z"`
+}
+
+// CreateUploadBatchRequest is the manifest for a batch upload
+// @Description Manifest of files the client wants to upload
+type CreateUploadBatchRequest struct {
+	DeviceID string               `json:"deviceId,omitempty" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Items    []UploadManifestItem `json:"items"`
+}
+
+// UploadBatchResponse tells the client which manifest entries to send
+// @Description Files the server needs and the token to upload them with
+type UploadBatchResponse struct {
+	UploadToken string                  `json:"uploadToken" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Needed      []string                `json:"needed"`
+	Existing    []string                `json:"existing"`
+	Rejected    []UploadBatchItemResult `json:"rejected"`
+	Completed   int                     `json:"completed" example:"0"`
+	Total       int                     `json:"total" example:"1000"`
+	ExpiresAt   time.Time               `json:"expiresAt" example:"2024-01-16T10:30:00Z"`
+}
+
+// UploadBatchItemResult reports what happened to one file
+type UploadBatchItemResult struct {
+	Hash             string                `json:"hash"`
+	OriginalFilename string                `json:"originalFilename"`
+	Status           UploadBatchItemStatus `json:"status"`
+	PhotoID          string                `json:"photoId,omitempty"`
+	Error            string                `json:"error,omitempty"`
+}
+
+// UploadBatchResult summarises one request's worth of batch files
+// @Description Per-file results of a batch upload request
+type UploadBatchResult struct {
+	UploadToken string                  `json:"uploadToken"`
+	Results     []UploadBatchItemResult `json:"results"`
+	Uploaded    int                     `json:"uploaded"`
+	Duplicates  int                     `json:"duplicates"`
+	Failed      int                     `json:"failed"`
+	Remaining   int                     `json:"remaining"`
+}
+
+// Upload batch errors
+var (
+	ErrUploadBatchNotFound = PhotoError{"upload batch not found"}
+	ErrUploadBatchExpired  = PhotoError{"upload batch has expired"}
+	ErrUploadBatchEmpty    = PhotoError{"manifest must list at least one file"}
+	ErrUploadBatchTooLarge = PhotoError{"manifest lists too many files"}
+)
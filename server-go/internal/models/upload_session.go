@@ -0,0 +1,98 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UploadSession tracks a resumable, chunked upload in progress
+type UploadSession struct {
+	ID               string     `json:"id"`
+	UserID           string     `json:"userId"`
+	OriginalFilename string     `json:"originalFilename"`
+	TotalSize        int64      `json:"totalSize"`
+	Offset           int64      `json:"offset"`
+	DateTaken        *time.Time `json:"dateTaken,omitempty"`
+	DeviceID         string     `json:"deviceId,omitempty"`
+	ContentSHA256    string     `json:"contentSha256,omitempty"` // Hash the client expects the assembled file to have
+	HashState        []byte     `json:"hashState,omitempty"`     // Marshalled SHA-256 state over the bytes received so far
+	CreatedAt        time.Time  `json:"createdAt"`
+	UpdatedAt        time.Time  `json:"updatedAt"`
+	ExpiresAt        time.Time  `json:"expiresAt"`
+}
+
+// NewUploadSession creates a new upload session for a file of the given size
+func NewUploadSession(userID, originalFilename string, totalSize int64, ttl time.Duration) (*UploadSession, error) {
+	if strings.TrimSpace(originalFilename) == "" {
+		return nil, ErrEmptyFilename
+	}
+	if totalSize <= 0 {
+		return nil, ErrInvalidFileSize
+	}
+
+	now := time.Now().UTC()
+	return &UploadSession{
+		ID:               uuid.New().String(),
+		UserID:           userID,
+		OriginalFilename: sanitizeFilename(originalFilename),
+		TotalSize:        totalSize,
+		Offset:           0,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		ExpiresAt:        now.Add(ttl),
+	}, nil
+}
+
+// IsExpired returns true if the session has passed its expiry time
+func (s *UploadSession) IsExpired() bool {
+	return time.Now().UTC().After(s.ExpiresAt)
+}
+
+// IsComplete returns true once every byte of the file has been received
+func (s *UploadSession) IsComplete() bool {
+	return s.Offset >= s.TotalSize
+}
+
+// CreateUploadSessionRequest is the request body for starting a resumable upload
+// @Description Request to start a resumable chunked upload
+type CreateUploadSessionRequest struct {
+	OriginalFilename string `json:"originalFilename" example:"VID_1234.mp4"`
+	TotalSize        int64  `json:"totalSize" example:"104857600"`
+	DateTaken        string `json:"dateTaken,omitempty" example:"2024-01-15T10:30:00Z"`
+	DeviceID         string `json:"deviceId,omitempty" example:"550e8400-e29b-41d4-a716-446655440000"`
+	ContentSHA256    string `json:"contentSha256,omitempty" example:"a1b2c3d4e5f6..."`
+}
+
+// UploadSessionResponse describes the state of a resumable upload
+// @Description Current state of a resumable upload session
+type UploadSessionResponse struct {
+	ID               string    `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	OriginalFilename string    `json:"originalFilename" example:"VID_1234.mp4"`
+	TotalSize        int64     `json:"totalSize" example:"104857600"`
+	Offset           int64     `json:"offset" example:"5242880"`
+	IsComplete       bool      `json:"isComplete" example:"false"`
+	ExpiresAt        time.Time `json:"expiresAt" example:"2024-01-16T10:30:00Z"`
+}
+
+// ToResponse converts an UploadSession to its API response
+func (s *UploadSession) ToResponse() UploadSessionResponse {
+	return UploadSessionResponse{
+		ID:               s.ID,
+		OriginalFilename: s.OriginalFilename,
+		TotalSize:        s.TotalSize,
+		Offset:           s.Offset,
+		IsComplete:       s.IsComplete(),
+		ExpiresAt:        s.ExpiresAt,
+	}
+}
+
+// Upload session errors
+var (
+	ErrUploadSessionNotFound = PhotoError{"upload session not found"}
+	ErrUploadSessionExpired  = PhotoError{"upload session has expired"}
+	ErrUploadOffsetMismatch  = PhotoError{"upload offset does not match session offset"}
+	ErrUploadChunkTooLarge   = PhotoError{"chunk exceeds declared upload size"}
+	ErrUploadIncomplete      = PhotoError{"upload is not complete"}
+)
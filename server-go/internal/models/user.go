@@ -23,16 +23,21 @@ type User struct {
 	IsAdmin      bool      `json:"isAdmin"`
 	CreatedAt    time.Time `json:"createdAt"`
 	IsActive     bool      `json:"isActive"`
+	QuotaBytes   int64     `json:"quotaBytes"`  // 0 = unlimited
+	QuotaPhotos  int       `json:"quotaPhotos"` // 0 = unlimited
+	UsedBytes    int64     `json:"usedBytes"`
+	UsedPhotos   int       `json:"usedPhotos"`
 }
 
 // UserResponse is the safe response format (no API key)
 type UserResponse struct {
-	ID          string    `json:"id"`
-	Email       string    `json:"email"`
-	DisplayName string    `json:"displayName"`
-	IsAdmin     bool      `json:"isAdmin"`
-	CreatedAt   time.Time `json:"createdAt"`
-	IsActive    bool      `json:"isActive"`
+	ID          string       `json:"id"`
+	Email       string       `json:"email"`
+	DisplayName string       `json:"displayName"`
+	IsAdmin     bool         `json:"isAdmin"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	IsActive    bool         `json:"isActive"`
+	Usage       StorageUsage `json:"usage"`
 }
 
 // CreateUserRequest is the request body for creating a user
@@ -81,6 +86,7 @@ func (u *User) ToResponse() UserResponse {
 		IsAdmin:     u.IsAdmin,
 		CreatedAt:   u.CreatedAt,
 		IsActive:    u.IsActive,
+		Usage:       u.Usage(),
 	}
 }
 
@@ -139,6 +145,7 @@ var (
 	ErrPasswordTooShort = UserError{"password must be at least 8 characters"}
 	ErrInvalidPassword  = UserError{"invalid password"}
 	ErrPasswordNotSet   = UserError{"password not set for this user"}
+	ErrNegativeQuota    = UserError{"quota cannot be negative"}
 )
 
 type UserError struct {
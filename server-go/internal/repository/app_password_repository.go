@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+)
+
+// AppPasswordRepository implements AppPasswordRepo for PostgreSQL/SQLite
+type AppPasswordRepository struct {
+	db *sql.DB
+}
+
+// NewAppPasswordRepository creates a new AppPasswordRepository
+func NewAppPasswordRepository(db *sql.DB) *AppPasswordRepository {
+	return &AppPasswordRepository{db: db}
+}
+
+func (r *AppPasswordRepository) GetByHash(ctx context.Context, passwordHash string) (*models.AppPassword, error) {
+	query := `SELECT id, user_id, name, password_hash, created_at, last_used_at
+			  FROM app_passwords WHERE password_hash = $1`
+
+	var ap models.AppPassword
+	err := r.db.QueryRowContext(ctx, query, passwordHash).Scan(
+		&ap.ID, &ap.UserID, &ap.Name, &ap.PasswordHash, &ap.CreatedAt, &ap.LastUsedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ap, nil
+}
+
+func (r *AppPasswordRepository) GetAllForUser(ctx context.Context, userID string) ([]*models.AppPassword, error) {
+	query := `SELECT id, user_id, name, password_hash, created_at, last_used_at
+			  FROM app_passwords WHERE user_id = $1 ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	passwords := []*models.AppPassword{}
+	for rows.Next() {
+		var ap models.AppPassword
+		if err := rows.Scan(&ap.ID, &ap.UserID, &ap.Name, &ap.PasswordHash, &ap.CreatedAt, &ap.LastUsedAt); err != nil {
+			return nil, err
+		}
+		passwords = append(passwords, &ap)
+	}
+	return passwords, rows.Err()
+}
+
+func (r *AppPasswordRepository) Add(ctx context.Context, ap *models.AppPassword) error {
+	query := `INSERT INTO app_passwords (id, user_id, name, password_hash, created_at, last_used_at)
+			  VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := r.db.ExecContext(ctx, query, ap.ID, ap.UserID, ap.Name, ap.PasswordHash, ap.CreatedAt, ap.LastUsedAt)
+	return err
+}
+
+func (r *AppPasswordRepository) Touch(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE app_passwords SET last_used_at = $1 WHERE id = $2`, time.Now().UTC(), id)
+	return err
+}
+
+func (r *AppPasswordRepository) Delete(ctx context.Context, id, userID string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM app_passwords WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+)
+
+// CollectionLinkRepository implements CollectionLinkRepo for PostgreSQL/SQLite
+type CollectionLinkRepository struct {
+	db *sql.DB
+}
+
+// NewCollectionLinkRepository creates a new CollectionLinkRepository
+func NewCollectionLinkRepository(db *sql.DB) *CollectionLinkRepository {
+	return &CollectionLinkRepository{db: db}
+}
+
+const collectionLinkColumns = `id, collection_id, token, label, password_hash, expires_at, max_views,
+	view_count, created_at, last_viewed_at, revoked_at`
+
+func scanCollectionLink(row interface{ Scan(...interface{}) error }) (*models.CollectionLink, error) {
+	var link models.CollectionLink
+	var maxViews sql.NullInt64
+	err := row.Scan(
+		&link.ID, &link.CollectionID, &link.Token, &link.Label, &link.PasswordHash, &link.ExpiresAt, &maxViews,
+		&link.ViewCount, &link.CreatedAt, &link.LastViewedAt, &link.RevokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if maxViews.Valid {
+		v := int(maxViews.Int64)
+		link.MaxViews = &v
+	}
+	link.HasPassword = link.PasswordHash != nil
+	return &link, nil
+}
+
+func (r *CollectionLinkRepository) GetByID(ctx context.Context, id string) (*models.CollectionLink, error) {
+	query := `SELECT ` + collectionLinkColumns + ` FROM collection_links WHERE id = $1`
+	link, err := scanCollectionLink(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return link, err
+}
+
+func (r *CollectionLinkRepository) GetByToken(ctx context.Context, token string) (*models.CollectionLink, error) {
+	query := `SELECT ` + collectionLinkColumns + ` FROM collection_links WHERE token = $1`
+	link, err := scanCollectionLink(r.db.QueryRowContext(ctx, query, token))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return link, err
+}
+
+func (r *CollectionLinkRepository) GetForCollection(ctx context.Context, collectionID string) ([]*models.CollectionLink, error) {
+	query := `SELECT ` + collectionLinkColumns + ` FROM collection_links
+			  WHERE collection_id = $1 ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []*models.CollectionLink{}
+	for rows.Next() {
+		link, err := scanCollectionLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+func (r *CollectionLinkRepository) Add(ctx context.Context, link *models.CollectionLink) error {
+	query := `INSERT INTO collection_links (id, collection_id, token, label, password_hash, expires_at, max_views,
+			  view_count, created_at, last_viewed_at, revoked_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		link.ID, link.CollectionID, link.Token, link.Label, link.PasswordHash, link.ExpiresAt, link.MaxViews,
+		link.ViewCount, link.CreatedAt, link.LastViewedAt, link.RevokedAt,
+	)
+	return err
+}
+
+// Revoke marks a link revoked, reporting false if it was already revoked
+func (r *CollectionLinkRepository) Revoke(ctx context.Context, id string, at time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE collection_links SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL`, at, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// RecordView counts a view unless the link has reached its view limit in the
+// meantime, reporting whether the view was counted
+func (r *CollectionLinkRepository) RecordView(ctx context.Context, id string, at time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `UPDATE collection_links
+		SET view_count = view_count + 1, last_viewed_at = $1
+		WHERE id = $2 AND revoked_at IS NULL AND (max_views IS NULL OR view_count < max_views)`, at, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *CollectionLinkRepository) LogAccess(ctx context.Context, access *models.CollectionLinkAccess) error {
+	query := `INSERT INTO collection_link_access (link_id, accessed_at, ip_address, user_agent, outcome)
+			  VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		access.LinkID, access.AccessedAt, access.IPAddress, access.UserAgent, access.Outcome)
+	return err
+}
+
+func (r *CollectionLinkRepository) GetAccessLog(ctx context.Context, linkID string, limit int) ([]*models.CollectionLinkAccess, error) {
+	query := `SELECT id, link_id, accessed_at, ip_address, user_agent, outcome
+			  FROM collection_link_access WHERE link_id = $1
+			  ORDER BY accessed_at DESC, id DESC LIMIT $2`
+
+	rows, err := r.db.QueryContext(ctx, query, linkID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accesses := []*models.CollectionLinkAccess{}
+	for rows.Next() {
+		var a models.CollectionLinkAccess
+		if err := rows.Scan(&a.ID, &a.LinkID, &a.AccessedAt, &a.IPAddress, &a.UserAgent, &a.Outcome); err != nil {
+			return nil, err
+		}
+		accesses = append(accesses, &a)
+	}
+	return accesses, rows.Err()
+}
+
+// DeleteAccessBefore removes access log entries older than the cutoff
+func (r *CollectionLinkRepository) DeleteAccessBefore(ctx context.Context, cutoff time.Time) (int, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM collection_link_access WHERE accessed_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
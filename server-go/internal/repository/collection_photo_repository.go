@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/photosync/server/internal/models"
@@ -19,8 +20,29 @@ func NewCollectionPhotoRepository(db *sql.DB) *CollectionPhotoRepository {
 	return &CollectionPhotoRepository{db: db}
 }
 
+// GetByCollectionID returns a collection's entries in order. For a smart
+// collection they are made up from the photos its rules currently match.
 func (r *CollectionPhotoRepository) GetByCollectionID(ctx context.Context, collectionID string) ([]*models.CollectionPhoto, error) {
-	query := `SELECT id, collection_id, photo_id, position, added_at
+	smart, err := loadSmartCollection(ctx, r.db, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	if smart != nil {
+		photos, err := smartCollectionPhotos(ctx, r.db, smart)
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]*models.CollectionPhoto, len(photos))
+		for i, p := range photos {
+			entries[i] = &models.CollectionPhoto{
+				ID: collectionID + ":" + p.ID, CollectionID: collectionID, PhotoID: p.ID,
+				Position: i + 1, AddedBy: smart.userID, AddedAt: p.UploadedAt,
+			}
+		}
+		return entries, nil
+	}
+
+	query := `SELECT id, collection_id, photo_id, position, COALESCE(added_by, ''), added_at
 			  FROM collection_photos WHERE collection_id = $1 ORDER BY position ASC`
 
 	rows, err := r.db.QueryContext(ctx, query, collectionID)
@@ -32,7 +54,7 @@ func (r *CollectionPhotoRepository) GetByCollectionID(ctx context.Context, colle
 	var photos []*models.CollectionPhoto
 	for rows.Next() {
 		var cp models.CollectionPhoto
-		if err := rows.Scan(&cp.ID, &cp.CollectionID, &cp.PhotoID, &cp.Position, &cp.AddedAt); err != nil {
+		if err := rows.Scan(&cp.ID, &cp.CollectionID, &cp.PhotoID, &cp.Position, &cp.AddedBy, &cp.AddedAt); err != nil {
 			return nil, err
 		}
 		photos = append(photos, &cp)
@@ -40,15 +62,25 @@ func (r *CollectionPhotoRepository) GetByCollectionID(ctx context.Context, colle
 	return photos, rows.Err()
 }
 
+// GetPhotosForCollection returns a collection's photos outside the trash, in
+// their manual order or, for a smart collection, newest first
 func (r *CollectionPhotoRepository) GetPhotosForCollection(ctx context.Context, collectionID string) ([]*models.Photo, error) {
+	smart, err := loadSmartCollection(ctx, r.db, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	if smart != nil {
+		return smartCollectionPhotos(ctx, r.db, smart)
+	}
+
 	query := `SELECT p.id, p.user_id, p.original_filename, p.stored_path, p.file_hash, p.file_size,
 			  p.date_taken, p.uploaded_at, p.thumb_small, p.thumb_medium, p.thumb_large,
 			  p.camera_make, p.camera_model, p.lens_model, p.focal_length, p.aperture,
 			  p.shutter_speed, p.iso, p.orientation, p.latitude, p.longitude, p.altitude,
-			  p.width, p.height
+			  p.width, p.height, p.media_type, p.duration, p.deleted_at
 			  FROM photos p
 			  INNER JOIN collection_photos cp ON cp.photo_id = p.id
-			  WHERE cp.collection_id = $1 ORDER BY cp.position ASC`
+			  WHERE cp.collection_id = $1 AND p.deleted_at IS NULL ORDER BY cp.position ASC`
 
 	rows, err := r.db.QueryContext(ctx, query, collectionID)
 	if err != nil {
@@ -64,7 +96,7 @@ func (r *CollectionPhotoRepository) GetPhotosForCollection(ctx context.Context,
 			&p.DateTaken, &p.UploadedAt, &p.ThumbSmall, &p.ThumbMedium, &p.ThumbLarge,
 			&p.CameraMake, &p.CameraModel, &p.LensModel, &p.FocalLength, &p.Aperture,
 			&p.ShutterSpeed, &p.ISO, &p.Orientation, &p.Latitude, &p.Longitude, &p.Altitude,
-			&p.Width, &p.Height,
+			&p.Width, &p.Height, &p.MediaType, &p.Duration, &p.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -74,21 +106,32 @@ func (r *CollectionPhotoRepository) GetPhotosForCollection(ctx context.Context,
 }
 
 func (r *CollectionPhotoRepository) GetPhotoCountForCollection(ctx context.Context, collectionID string) (int, error) {
+	smart, err := loadSmartCollection(ctx, r.db, collectionID)
+	if err != nil {
+		return 0, err
+	}
+	if smart != nil {
+		return countSmartCollection(ctx, r.db, smart)
+	}
+
 	var count int
-	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM collection_photos WHERE collection_id = $1`, collectionID).Scan(&count)
+	err = r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM collection_photos cp
+			  INNER JOIN photos p ON p.id = cp.photo_id
+			  WHERE cp.collection_id = $1 AND p.deleted_at IS NULL`, collectionID).Scan(&count)
 	return count, err
 }
 
+// Add adds a photo to a collection. Without AddedBy it counts as added by the owner.
 func (r *CollectionPhotoRepository) Add(ctx context.Context, cp *models.CollectionPhoto) error {
-	query := `INSERT INTO collection_photos (id, collection_id, photo_id, position, added_at)
-			  VALUES ($1, $2, $3, $4, $5)
+	query := `INSERT INTO collection_photos (id, collection_id, photo_id, position, added_by, added_at)
+			  VALUES ($1, $2, $3, $4, COALESCE(NULLIF($5, ''), (SELECT user_id FROM collections WHERE id = $2)), $6)
 			  ON CONFLICT (collection_id, photo_id) DO NOTHING`
 
-	_, err := r.db.ExecContext(ctx, query, cp.ID, cp.CollectionID, cp.PhotoID, cp.Position, cp.AddedAt)
+	_, err := r.db.ExecContext(ctx, query, cp.ID, cp.CollectionID, cp.PhotoID, cp.Position, cp.AddedBy, cp.AddedAt)
 	return err
 }
 
-func (r *CollectionPhotoRepository) AddMultiple(ctx context.Context, collectionID string, photoIDs []string) error {
+func (r *CollectionPhotoRepository) AddMultiple(ctx context.Context, collectionID, addedBy string, photoIDs []string) error {
 	if len(photoIDs) == 0 {
 		return nil
 	}
@@ -111,10 +154,10 @@ func (r *CollectionPhotoRepository) AddMultiple(ctx context.Context, collectionI
 
 	// Use a simpler approach with individual inserts to avoid placeholder issues
 	for i, photoID := range photoIDs {
-		query := `INSERT INTO collection_photos (id, collection_id, photo_id, position, added_at)
-				  VALUES ($1, $2, $3, $4, NOW())
+		query := `INSERT INTO collection_photos (id, collection_id, photo_id, position, added_by, added_at)
+				  VALUES ($1, $2, $3, $4, $5, $6)
 				  ON CONFLICT (collection_id, photo_id) DO NOTHING`
-		_, err := r.db.ExecContext(ctx, query, uuid.New().String(), collectionID, photoID, maxPos+i+1)
+		_, err := r.db.ExecContext(ctx, query, uuid.New().String(), collectionID, photoID, maxPos+i+1, addedBy, time.Now().UTC())
 		if err != nil {
 			return err
 		}
@@ -164,9 +207,17 @@ func (r *CollectionPhotoRepository) Reorder(ctx context.Context, collectionID st
 }
 
 func (r *CollectionPhotoRepository) IsPhotoInCollection(ctx context.Context, collectionID, photoID string) (bool, error) {
+	smart, err := loadSmartCollection(ctx, r.db, collectionID)
+	if err != nil {
+		return false, err
+	}
+	if smart != nil {
+		return smartCollectionHas(ctx, r.db, smart, photoID)
+	}
+
 	var exists bool
 	query := `SELECT EXISTS(SELECT 1 FROM collection_photos WHERE collection_id = $1 AND photo_id = $2)`
-	err := r.db.QueryRowContext(ctx, query, collectionID, photoID).Scan(&exists)
+	err = r.db.QueryRowContext(ctx, query, collectionID, photoID).Scan(&exists)
 	return exists, err
 }
 
@@ -19,13 +19,14 @@ func NewCollectionRepository(db *sql.DB) *CollectionRepository {
 
 func (r *CollectionRepository) GetByID(ctx context.Context, id string) (*models.Collection, error) {
 	query := `SELECT id, user_id, name, description, slug, theme, custom_css, visibility,
-			  secret_token, cover_photo_id, created_at, updated_at
+			  secret_token, cover_photo_id, created_at, updated_at, kind, smart_rules, allow_downloads, metadata_stripping
 			  FROM collections WHERE id = $1`
 
 	var c models.Collection
+	var rules sql.NullString
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&c.ID, &c.UserID, &c.Name, &c.Description, &c.Slug, &c.Theme, &c.CustomCSS,
-		&c.Visibility, &c.SecretToken, &c.CoverPhotoID, &c.CreatedAt, &c.UpdatedAt,
+		&c.Visibility, &c.SecretToken, &c.CoverPhotoID, &c.CreatedAt, &c.UpdatedAt, &c.Kind, &rules, &c.AllowDownloads, &c.MetadataStripping,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -33,18 +34,22 @@ func (r *CollectionRepository) GetByID(ctx context.Context, id string) (*models.
 	if err != nil {
 		return nil, err
 	}
+	if err := setSmartRules(&c, rules); err != nil {
+		return nil, err
+	}
 	return &c, nil
 }
 
 func (r *CollectionRepository) GetBySlug(ctx context.Context, slug string) (*models.Collection, error) {
 	query := `SELECT id, user_id, name, description, slug, theme, custom_css, visibility,
-			  secret_token, cover_photo_id, created_at, updated_at
+			  secret_token, cover_photo_id, created_at, updated_at, kind, smart_rules, allow_downloads, metadata_stripping
 			  FROM collections WHERE slug = $1`
 
 	var c models.Collection
+	var rules sql.NullString
 	err := r.db.QueryRowContext(ctx, query, slug).Scan(
 		&c.ID, &c.UserID, &c.Name, &c.Description, &c.Slug, &c.Theme, &c.CustomCSS,
-		&c.Visibility, &c.SecretToken, &c.CoverPhotoID, &c.CreatedAt, &c.UpdatedAt,
+		&c.Visibility, &c.SecretToken, &c.CoverPhotoID, &c.CreatedAt, &c.UpdatedAt, &c.Kind, &rules, &c.AllowDownloads, &c.MetadataStripping,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -52,23 +57,7 @@ func (r *CollectionRepository) GetBySlug(ctx context.Context, slug string) (*mod
 	if err != nil {
 		return nil, err
 	}
-	return &c, nil
-}
-
-func (r *CollectionRepository) GetBySecretToken(ctx context.Context, token string) (*models.Collection, error) {
-	query := `SELECT id, user_id, name, description, slug, theme, custom_css, visibility,
-			  secret_token, cover_photo_id, created_at, updated_at
-			  FROM collections WHERE secret_token = $1 AND visibility = 'secret_link'`
-
-	var c models.Collection
-	err := r.db.QueryRowContext(ctx, query, token).Scan(
-		&c.ID, &c.UserID, &c.Name, &c.Description, &c.Slug, &c.Theme, &c.CustomCSS,
-		&c.Visibility, &c.SecretToken, &c.CoverPhotoID, &c.CreatedAt, &c.UpdatedAt,
-	)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-	if err != nil {
+	if err := setSmartRules(&c, rules); err != nil {
 		return nil, err
 	}
 	return &c, nil
@@ -76,8 +65,9 @@ func (r *CollectionRepository) GetBySecretToken(ctx context.Context, token strin
 
 func (r *CollectionRepository) GetAllForUser(ctx context.Context, userID string) ([]*models.Collection, error) {
 	query := `SELECT c.id, c.user_id, c.name, c.description, c.slug, c.theme, c.custom_css,
-			  c.visibility, c.secret_token, c.cover_photo_id, c.created_at, c.updated_at,
-			  (SELECT COUNT(*) FROM collection_photos WHERE collection_id = c.id) as photo_count
+			  c.visibility, c.secret_token, c.cover_photo_id, c.created_at, c.updated_at, c.kind, c.smart_rules, c.allow_downloads, c.metadata_stripping,
+			  (SELECT COUNT(*) FROM collection_photos cp INNER JOIN photos p ON p.id = cp.photo_id
+			   WHERE cp.collection_id = c.id AND p.deleted_at IS NULL) as photo_count
 			  FROM collections c WHERE c.user_id = $1 ORDER BY c.updated_at DESC`
 
 	rows, err := r.db.QueryContext(ctx, query, userID)
@@ -89,21 +79,29 @@ func (r *CollectionRepository) GetAllForUser(ctx context.Context, userID string)
 	var collections []*models.Collection
 	for rows.Next() {
 		var c models.Collection
+		var rules sql.NullString
 		if err := rows.Scan(&c.ID, &c.UserID, &c.Name, &c.Description, &c.Slug, &c.Theme,
 			&c.CustomCSS, &c.Visibility, &c.SecretToken, &c.CoverPhotoID, &c.CreatedAt,
-			&c.UpdatedAt, &c.PhotoCount); err != nil {
+			&c.UpdatedAt, &c.Kind, &rules, &c.AllowDownloads, &c.MetadataStripping, &c.PhotoCount); err != nil {
+			return nil, err
+		}
+		if err := setSmartRules(&c, rules); err != nil {
 			return nil, err
 		}
 		c.IsOwner = true
 		collections = append(collections, &c)
 	}
-	return collections, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return collections, r.countSmartPhotos(ctx, collections)
 }
 
 func (r *CollectionRepository) GetSharedWithUser(ctx context.Context, userID string) ([]*models.Collection, error) {
 	query := `SELECT c.id, c.user_id, c.name, c.description, c.slug, c.theme, c.custom_css,
-			  c.visibility, c.secret_token, c.cover_photo_id, c.created_at, c.updated_at,
-			  (SELECT COUNT(*) FROM collection_photos WHERE collection_id = c.id) as photo_count
+			  c.visibility, c.secret_token, c.cover_photo_id, c.created_at, c.updated_at, c.kind, c.smart_rules, c.allow_downloads, c.metadata_stripping, cs.role,
+			  (SELECT COUNT(*) FROM collection_photos cp INNER JOIN photos p ON p.id = cp.photo_id
+			   WHERE cp.collection_id = c.id AND p.deleted_at IS NULL) as photo_count
 			  FROM collections c
 			  INNER JOIN collection_shares cs ON cs.collection_id = c.id
 			  WHERE cs.user_id = $1 ORDER BY c.updated_at DESC`
@@ -117,39 +115,60 @@ func (r *CollectionRepository) GetSharedWithUser(ctx context.Context, userID str
 	var collections []*models.Collection
 	for rows.Next() {
 		var c models.Collection
+		var rules sql.NullString
 		if err := rows.Scan(&c.ID, &c.UserID, &c.Name, &c.Description, &c.Slug, &c.Theme,
 			&c.CustomCSS, &c.Visibility, &c.SecretToken, &c.CoverPhotoID, &c.CreatedAt,
-			&c.UpdatedAt, &c.PhotoCount); err != nil {
+			&c.UpdatedAt, &c.Kind, &rules, &c.AllowDownloads, &c.MetadataStripping, &c.Role, &c.PhotoCount); err != nil {
+			return nil, err
+		}
+		if err := setSmartRules(&c, rules); err != nil {
 			return nil, err
 		}
 		c.IsOwner = false
 		collections = append(collections, &c)
 	}
-	return collections, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return collections, r.countSmartPhotos(ctx, collections)
 }
 
 func (r *CollectionRepository) Add(ctx context.Context, collection *models.Collection) error {
+	rules, err := encodeSmartRules(collection.Rules)
+	if err != nil {
+		return err
+	}
+
 	query := `INSERT INTO collections (id, user_id, name, description, slug, theme, custom_css,
-			  visibility, secret_token, cover_photo_id, created_at, updated_at)
-			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+			  visibility, secret_token, cover_photo_id, created_at, updated_at, kind, smart_rules, allow_downloads, metadata_stripping)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`
 
-	_, err := r.db.ExecContext(ctx, query,
+	_, err = r.db.ExecContext(ctx, query,
 		collection.ID, collection.UserID, collection.Name, collection.Description,
 		collection.Slug, collection.Theme, collection.CustomCSS, collection.Visibility,
 		collection.SecretToken, collection.CoverPhotoID, collection.CreatedAt, collection.UpdatedAt,
+		collectionKind(collection), rules, collection.AllowDownloads, collection.MetadataStripping,
 	)
 	return err
 }
 
 func (r *CollectionRepository) Update(ctx context.Context, collection *models.Collection) error {
-	query := `UPDATE collections SET name = $2, description = $3, slug = $4, theme = $5,
-			  custom_css = $6, visibility = $7, secret_token = $8, cover_photo_id = $9, updated_at = $10
-			  WHERE id = $1`
+	rules, err := encodeSmartRules(collection.Rules)
+	if err != nil {
+		return err
+	}
+
+	// SQLite numbers $N placeholders in order of appearance, so they must
+	// appear in argument order
+	query := `UPDATE collections SET name = $1, description = $2, slug = $3, theme = $4,
+			  custom_css = $5, visibility = $6, secret_token = $7, cover_photo_id = $8, updated_at = $9,
+			  smart_rules = $10, allow_downloads = $11, metadata_stripping = $12
+			  WHERE id = $13`
 
-	_, err := r.db.ExecContext(ctx, query,
-		collection.ID, collection.Name, collection.Description, collection.Slug,
+	_, err = r.db.ExecContext(ctx, query,
+		collection.Name, collection.Description, collection.Slug,
 		collection.Theme, collection.CustomCSS, collection.Visibility, collection.SecretToken,
-		collection.CoverPhotoID, collection.UpdatedAt,
+		collection.CoverPhotoID, collection.UpdatedAt, rules, collection.AllowDownloads, collection.MetadataStripping, collection.ID,
 	)
 	return err
 }
@@ -175,3 +194,34 @@ func (r *CollectionRepository) SlugExists(ctx context.Context, slug string, excl
 	err := r.db.QueryRowContext(ctx, query, args...).Scan(&exists)
 	return exists, err
 }
+
+// countSmartPhotos fills in the photo counts of smart collections, which the
+// list queries count from collection_photos
+func (r *CollectionRepository) countSmartPhotos(ctx context.Context, collections []*models.Collection) error {
+	for _, c := range collections {
+		if c.Rules == nil {
+			continue
+		}
+		count, err := countSmartCollection(ctx, r.db, &smartCollection{userID: c.UserID, rules: c.Rules})
+		if err != nil {
+			return err
+		}
+		c.PhotoCount = count
+	}
+	return nil
+}
+
+// setSmartRules decodes the stored rules of a smart collection
+func setSmartRules(c *models.Collection, raw sql.NullString) error {
+	rules, err := decodeSmartRules(c.Kind, raw)
+	c.Rules = rules
+	return err
+}
+
+// collectionKind defaults collections built without NewCollection to manual
+func collectionKind(c *models.Collection) models.CollectionKind {
+	if c.Kind == "" {
+		return models.CollectionManual
+	}
+	return c.Kind
+}
@@ -18,7 +18,7 @@ func NewCollectionShareRepository(db *sql.DB) *CollectionShareRepository {
 }
 
 func (r *CollectionShareRepository) GetByCollectionID(ctx context.Context, collectionID string) ([]*models.CollectionShare, error) {
-	query := `SELECT id, collection_id, user_id, created_at
+	query := `SELECT id, collection_id, user_id, role, created_at
 			  FROM collection_shares WHERE collection_id = $1 ORDER BY created_at ASC`
 
 	rows, err := r.db.QueryContext(ctx, query, collectionID)
@@ -30,7 +30,7 @@ func (r *CollectionShareRepository) GetByCollectionID(ctx context.Context, colle
 	var shares []*models.CollectionShare
 	for rows.Next() {
 		var s models.CollectionShare
-		if err := rows.Scan(&s.ID, &s.CollectionID, &s.UserID, &s.CreatedAt); err != nil {
+		if err := rows.Scan(&s.ID, &s.CollectionID, &s.UserID, &s.Role, &s.CreatedAt); err != nil {
 			return nil, err
 		}
 		shares = append(shares, &s)
@@ -39,7 +39,7 @@ func (r *CollectionShareRepository) GetByCollectionID(ctx context.Context, colle
 }
 
 func (r *CollectionShareRepository) GetSharesWithUsers(ctx context.Context, collectionID string) ([]*models.CollectionShareWithUser, error) {
-	query := `SELECT cs.id, cs.collection_id, cs.user_id, cs.created_at,
+	query := `SELECT cs.id, cs.collection_id, cs.user_id, cs.role, cs.created_at,
 			  u.id, u.email, u.display_name
 			  FROM collection_shares cs
 			  INNER JOIN users u ON u.id = cs.user_id
@@ -55,7 +55,7 @@ func (r *CollectionShareRepository) GetSharesWithUsers(ctx context.Context, coll
 	for rows.Next() {
 		var s models.CollectionShareWithUser
 		var user models.User
-		if err := rows.Scan(&s.ID, &s.CollectionID, &s.UserID, &s.CreatedAt,
+		if err := rows.Scan(&s.ID, &s.CollectionID, &s.UserID, &s.Role, &s.CreatedAt,
 			&user.ID, &user.Email, &user.DisplayName); err != nil {
 			return nil, err
 		}
@@ -72,15 +72,39 @@ func (r *CollectionShareRepository) IsSharedWithUser(ctx context.Context, collec
 	return exists, err
 }
 
+// GetRole returns a user's role on a collection, or "" if it is not shared with them
+func (r *CollectionShareRepository) GetRole(ctx context.Context, collectionID, userID string) (models.ShareRole, error) {
+	var role models.ShareRole
+	query := `SELECT role FROM collection_shares WHERE collection_id = $1 AND user_id = $2`
+	err := r.db.QueryRowContext(ctx, query, collectionID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+// Add shares a collection with a user, or changes their role if it already is
 func (r *CollectionShareRepository) Add(ctx context.Context, share *models.CollectionShare) error {
-	query := `INSERT INTO collection_shares (id, collection_id, user_id, created_at)
-			  VALUES ($1, $2, $3, $4)
-			  ON CONFLICT (collection_id, user_id) DO NOTHING`
+	query := `INSERT INTO collection_shares (id, collection_id, user_id, role, created_at)
+			  VALUES ($1, $2, $3, $4, $5)
+			  ON CONFLICT (collection_id, user_id) DO UPDATE SET role = excluded.role`
 
-	_, err := r.db.ExecContext(ctx, query, share.ID, share.CollectionID, share.UserID, share.CreatedAt)
+	_, err := r.db.ExecContext(ctx, query, share.ID, share.CollectionID, share.UserID, share.Role, share.CreatedAt)
 	return err
 }
 
+// UpdateRole changes a user's role on a collection. It reports false if the
+// collection is not shared with them.
+func (r *CollectionShareRepository) UpdateRole(ctx context.Context, collectionID, userID string, role models.ShareRole) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `UPDATE collection_shares SET role = $1 WHERE collection_id = $2 AND user_id = $3`,
+		role, collectionID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
 func (r *CollectionShareRepository) Remove(ctx context.Context, collectionID, userID string) error {
 	_, err := r.db.ExecContext(ctx, `DELETE FROM collection_shares WHERE collection_id = $1 AND user_id = $2`, collectionID, userID)
 	return err
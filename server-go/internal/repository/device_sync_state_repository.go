@@ -3,8 +3,10 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"time"
 
+	"github.com/mattn/go-sqlite3"
 	"github.com/photosync/server/internal/models"
 )
 
@@ -24,13 +26,15 @@ func (r *DeviceSyncStateRepository) Get(ctx context.Context, deviceID string) (*
 		FROM device_sync_state WHERE device_id = $1`
 
 	var state models.DeviceSyncState
+	var lastSyncAt, createdAt, updatedAt syncStateTime
+	var lastSyncPhotoID sql.NullString
 	err := r.db.QueryRowContext(ctx, query, deviceID).Scan(
 		&state.DeviceID,
-		&state.LastSyncAt,
-		&state.LastSyncPhotoID,
+		&lastSyncAt,
+		&lastSyncPhotoID,
 		&state.SyncVersion,
-		&state.CreatedAt,
-		&state.UpdatedAt,
+		&createdAt,
+		&updatedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -39,9 +43,44 @@ func (r *DeviceSyncStateRepository) Get(ctx context.Context, deviceID string) (*
 	if err != nil {
 		return nil, err
 	}
+	if lastSyncAt.Valid {
+		state.LastSyncAt = &lastSyncAt.Time
+	}
+	state.LastSyncPhotoID = lastSyncPhotoID.String
+	state.CreatedAt = createdAt.Time
+	state.UpdatedAt = updatedAt.Time
 	return &state, nil
 }
 
+// syncStateTime scans the sync state timestamps. The SQLite table declares
+// them as TEXT, so the driver returns the stored string rather than a time.
+type syncStateTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+func (t *syncStateTime) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*t = syncStateTime{}
+		return nil
+	case time.Time:
+		*t = syncStateTime{Time: v, Valid: true}
+		return nil
+	case []byte:
+		return t.Scan(string(v))
+	case string:
+		for _, layout := range sqlite3.SQLiteTimestampFormats {
+			if parsed, err := time.Parse(layout, v); err == nil {
+				*t = syncStateTime{Time: parsed.UTC(), Valid: true}
+				return nil
+			}
+		}
+		return fmt.Errorf("unsupported sync state time %q", v)
+	}
+	return fmt.Errorf("unsupported sync state time type %T", value)
+}
+
 // Upsert creates or updates sync state for a device
 func (r *DeviceSyncStateRepository) Upsert(ctx context.Context, state *models.DeviceSyncState) error {
 	query := `INSERT INTO device_sync_state (device_id, last_sync_at, last_sync_photo_id, sync_version, created_at, updated_at)
@@ -66,20 +105,15 @@ func (r *DeviceSyncStateRepository) Upsert(ctx context.Context, state *models.De
 // GetSyncVersion returns the current sync version for a user
 // This is used to detect changes since last sync
 func (r *DeviceSyncStateRepository) GetSyncVersion(ctx context.Context, userID string) (int, error) {
-	// Sync version is tracked per user - we use a simple counter based on photo count
-	// More sophisticated implementations could use a sequence or version table
-	var count int
-	err := r.db.QueryRowContext(ctx,
-		"SELECT COUNT(*) FROM photos WHERE user_id = $1",
-		userID,
-	).Scan(&count)
-	return count, err
+	// The version is the user's latest change-log sequence, so it moves on
+	// every add, edit, delete and claim rather than only when the count changes
+	latest, err := NewPhotoChangeRepository(r.db).GetLatestSeq(ctx, userID)
+	return int(latest), err
 }
 
-// IncrementSyncVersion is a no-op for this simple implementation
-// Version is derived from photo count
+// IncrementSyncVersion is a no-op: every photo mutation already appends to
+// the change log, which is what GetSyncVersion reads
 func (r *DeviceSyncStateRepository) IncrementSyncVersion(ctx context.Context, userID string) error {
-	// No-op - version is derived from photo count
 	return nil
 }
 
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/photosync/server/internal/models"
+)
+
+// DuplicateGroupRepository implements DuplicateGroupRepo for PostgreSQL/SQLite
+type DuplicateGroupRepository struct {
+	db *sql.DB
+}
+
+// NewDuplicateGroupRepository creates a new DuplicateGroupRepository
+func NewDuplicateGroupRepository(db *sql.DB) *DuplicateGroupRepository {
+	return &DuplicateGroupRepository{db: db}
+}
+
+// GetByID returns a group with its members, or nil if it does not exist
+func (r *DuplicateGroupRepository) GetByID(ctx context.Context, id string) (*models.DuplicateGroup, error) {
+	groups, err := r.queryGroups(ctx, `WHERE g.id = $1`, id)
+	if err != nil || len(groups) == 0 {
+		return nil, err
+	}
+	return groups[0], nil
+}
+
+// GetForUser returns the user's groups with the given status, oldest first
+func (r *DuplicateGroupRepository) GetForUser(ctx context.Context, userID string, status models.DuplicateGroupStatus) ([]*models.DuplicateGroup, error) {
+	return r.queryGroups(ctx, `WHERE g.user_id = $1 AND g.status = $2`, userID, status)
+}
+
+// ReplacePendingForUser swaps the user's pending groups for a freshly computed set.
+// Dismissed groups are left alone.
+func (r *DuplicateGroupRepository) ReplacePendingForUser(ctx context.Context, userID string, groups []*models.DuplicateGroup) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM duplicate_group_photos WHERE group_id IN (SELECT id FROM duplicate_groups WHERE user_id = $1 AND status = $2)`,
+		userID, models.DuplicateGroupPending,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM duplicate_groups WHERE user_id = $1 AND status = $2`,
+		userID, models.DuplicateGroupPending,
+	); err != nil {
+		return err
+	}
+
+	for _, g := range groups {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO duplicate_groups (id, user_id, status, created_at) VALUES ($1, $2, $3, $4)`,
+			g.ID, g.UserID, g.Status, g.CreatedAt,
+		); err != nil {
+			return err
+		}
+		for _, m := range g.Members {
+			if _, err := tx.ExecContext(ctx,
+				`INSERT INTO duplicate_group_photos (group_id, photo_id, distance) VALUES ($1, $2, $3)`,
+				g.ID, m.PhotoID, m.Distance,
+			); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UpdateStatus sets the review status of a group
+func (r *DuplicateGroupRepository) UpdateStatus(ctx context.Context, id string, status models.DuplicateGroupStatus) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE duplicate_groups SET status = $1 WHERE id = $2`, status, id)
+	return err
+}
+
+// Delete removes a group and its membership rows
+func (r *DuplicateGroupRepository) Delete(ctx context.Context, id string) (bool, error) {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM duplicate_group_photos WHERE group_id = $1`, id); err != nil {
+		return false, err
+	}
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM duplicate_groups WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// queryGroups loads groups and their members in a single query
+func (r *DuplicateGroupRepository) queryGroups(ctx context.Context, where string, args ...interface{}) ([]*models.DuplicateGroup, error) {
+	query := `SELECT g.id, g.user_id, g.status, g.created_at, gp.photo_id, gp.distance
+			  FROM duplicate_groups g
+			  INNER JOIN duplicate_group_photos gp ON gp.group_id = g.id
+			  ` + where + `
+			  ORDER BY g.created_at ASC, g.id ASC, gp.distance ASC, gp.photo_id ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []*models.DuplicateGroup{}
+	var current *models.DuplicateGroup
+	for rows.Next() {
+		var g models.DuplicateGroup
+		var m models.DuplicateGroupMember
+		if err := rows.Scan(&g.ID, &g.UserID, &g.Status, &g.CreatedAt, &m.PhotoID, &m.Distance); err != nil {
+			return nil, err
+		}
+		if current == nil || current.ID != g.ID {
+			current = &g
+			groups = append(groups, current)
+		}
+		current.Members = append(current.Members, m)
+	}
+	return groups, rows.Err()
+}
@@ -22,12 +22,39 @@ type PhotoRepo interface {
 	AddWithUser(ctx context.Context, photo *models.Photo, userID string) error
 	Update(ctx context.Context, photo *models.Photo) error
 	Delete(ctx context.Context, id string) (bool, error)
+	DeleteReleasingBlob(ctx context.Context, id string) (bool, int, error)       // Delete, returning the stored file's remaining references
 	DeleteAll(ctx context.Context) (int, error)                                  // Delete all photos
 	VerifyExistence(ctx context.Context, ids []string) (map[string]bool, error)  // Check which IDs exist
 	GetPhotosWithoutThumbnails(ctx context.Context, limit int) ([]*models.Photo, error) // Get photos missing thumbnails
 	UpdateThumbnails(ctx context.Context, photoID, smallPath, mediumPath, largePath string) error // Update thumbnail paths
 	GetOrphanedPhotos(ctx context.Context, limit int) ([]*models.Photo, error) // Get photos without an owner
 
+	// Location (map view)
+	GetPhotosWithLocation(ctx context.Context, skip, take int) ([]*models.Photo, error)
+	GetPhotosWithLocationForUser(ctx context.Context, userID string, skip, take int) ([]*models.Photo, error)
+	GetLocationCount(ctx context.Context) (int, error)
+	GetLocationCountForUser(ctx context.Context, userID string) (int, error)
+
+	// Search
+	Search(ctx context.Context, q *models.PhotoSearchQuery) ([]*models.Photo, string, error)
+	GetSearchFacets(ctx context.Context, q *models.PhotoSearchQuery) (*models.SearchFacets, error)
+
+	// Perceptual hashes (near-duplicate detection)
+	GetPhotosWithoutPerceptualHash(ctx context.Context, limit int) ([]*models.Photo, error)
+	UpdatePerceptualHash(ctx context.Context, photoID, hash string) error
+	GetPerceptualHashesForUser(ctx context.Context, userID string) ([]models.PhotoPerceptualHash, error)
+
+	// Shared stored files (one blob per stored_path, referenced by each owner's photo row)
+	GetBlobByHash(ctx context.Context, hash string) (*models.PhotoBlob, error)
+	GetBlobRefCount(ctx context.Context, storedPath string) (int, error)
+
+	// Trash (soft delete)
+	MoveToTrash(ctx context.Context, id string, deletedAt time.Time) (bool, error)
+	Restore(ctx context.Context, id string) (bool, error)
+	GetTrashForUser(ctx context.Context, userID string, skip, take int) ([]*models.Photo, error)
+	GetTrashCountForUser(ctx context.Context, userID string) (int, error)
+	GetTrashedBefore(ctx context.Context, cutoff time.Time, limit int) ([]*models.Photo, error)
+
 	// Sync-related methods
 	GetAllForUserWithCursor(ctx context.Context, userID string, cursor string, limit int, sinceTimestamp *time.Time) ([]*models.Photo, string, error)
 	GetCountByOriginDevice(ctx context.Context, userID, deviceID string) (int, error)
@@ -135,7 +162,6 @@ type SMTPConfigRepo interface {
 type CollectionRepo interface {
 	GetByID(ctx context.Context, id string) (*models.Collection, error)
 	GetBySlug(ctx context.Context, slug string) (*models.Collection, error)
-	GetBySecretToken(ctx context.Context, token string) (*models.Collection, error)
 	GetAllForUser(ctx context.Context, userID string) ([]*models.Collection, error)
 	GetSharedWithUser(ctx context.Context, userID string) ([]*models.Collection, error)
 	Add(ctx context.Context, collection *models.Collection) error
@@ -150,7 +176,7 @@ type CollectionPhotoRepo interface {
 	GetPhotosForCollection(ctx context.Context, collectionID string) ([]*models.Photo, error)
 	GetPhotoCountForCollection(ctx context.Context, collectionID string) (int, error)
 	Add(ctx context.Context, cp *models.CollectionPhoto) error
-	AddMultiple(ctx context.Context, collectionID string, photoIDs []string) error
+	AddMultiple(ctx context.Context, collectionID, addedBy string, photoIDs []string) error
 	Remove(ctx context.Context, collectionID, photoID string) error
 	RemoveMultiple(ctx context.Context, collectionID string, photoIDs []string) error
 	Reorder(ctx context.Context, collectionID string, photoIDs []string) error
@@ -163,7 +189,9 @@ type CollectionShareRepo interface {
 	GetByCollectionID(ctx context.Context, collectionID string) ([]*models.CollectionShare, error)
 	GetSharesWithUsers(ctx context.Context, collectionID string) ([]*models.CollectionShareWithUser, error)
 	IsSharedWithUser(ctx context.Context, collectionID, userID string) (bool, error)
+	GetRole(ctx context.Context, collectionID, userID string) (models.ShareRole, error)
 	Add(ctx context.Context, share *models.CollectionShare) error
+	UpdateRole(ctx context.Context, collectionID, userID string, role models.ShareRole) (bool, error)
 	Remove(ctx context.Context, collectionID, userID string) error
 	RemoveAll(ctx context.Context, collectionID string) error
 }
@@ -221,3 +249,54 @@ type FileConflictRepo interface {
 	// Statistics
 	GetStats(ctx context.Context) (*models.FileConflictStats, error)
 }
+
+// DuplicateGroupRepo defines the interface for near-duplicate group persistence
+type DuplicateGroupRepo interface {
+	GetByID(ctx context.Context, id string) (*models.DuplicateGroup, error)
+	GetForUser(ctx context.Context, userID string, status models.DuplicateGroupStatus) ([]*models.DuplicateGroup, error)
+	ReplacePendingForUser(ctx context.Context, userID string, groups []*models.DuplicateGroup) error
+	UpdateStatus(ctx context.Context, id string, status models.DuplicateGroupStatus) error
+	Delete(ctx context.Context, id string) (bool, error)
+}
+
+// PhotoChangeRepo defines the interface for reading and compacting the per-user photo change log.
+// Entries are written by the photo repositories in the same transaction as each mutation.
+type PhotoChangeRepo interface {
+	GetSince(ctx context.Context, userID string, since int64, limit int) ([]*models.PhotoChange, error)
+	GetLatestSeq(ctx context.Context, userID string) (int64, error)
+	GetFloor(ctx context.Context, userID string) (int64, error)
+	Compact(ctx context.Context, tombstonesBefore time.Time) (int, error)
+}
+
+// AppPasswordRepo defines the interface for app password persistence
+type AppPasswordRepo interface {
+	GetByHash(ctx context.Context, passwordHash string) (*models.AppPassword, error)
+	GetAllForUser(ctx context.Context, userID string) ([]*models.AppPassword, error)
+	Add(ctx context.Context, ap *models.AppPassword) error
+	Touch(ctx context.Context, id string) error
+	Delete(ctx context.Context, id, userID string) (bool, error)
+}
+
+// RestorePlanRepo defines the interface for device restore plan persistence
+type RestorePlanRepo interface {
+	Add(ctx context.Context, plan *models.RestorePlan, items []*models.RestorePlanItem) error
+	GetByID(ctx context.Context, id string) (*models.RestorePlan, error)
+	GetForUser(ctx context.Context, userID, deviceID string) ([]*models.RestorePlan, error)
+	GetItems(ctx context.Context, planID string, afterPosition, limit int, pendingOnly bool) ([]*models.RestorePlanItem, error)
+	MarkDownloaded(ctx context.Context, planID string, confirmations []models.RestoreConfirmation, at time.Time) ([]string, error)
+	UpdateStatus(ctx context.Context, id string, status models.RestorePlanStatus, completedAt *time.Time) error
+	DeleteFinishedBefore(ctx context.Context, cutoff time.Time) (int, error)
+}
+
+// CollectionLinkRepo defines the interface for collection share link persistence
+type CollectionLinkRepo interface {
+	GetByID(ctx context.Context, id string) (*models.CollectionLink, error)
+	GetByToken(ctx context.Context, token string) (*models.CollectionLink, error)
+	GetForCollection(ctx context.Context, collectionID string) ([]*models.CollectionLink, error)
+	Add(ctx context.Context, link *models.CollectionLink) error
+	Revoke(ctx context.Context, id string, at time.Time) (bool, error)
+	RecordView(ctx context.Context, id string, at time.Time) (bool, error)
+	LogAccess(ctx context.Context, access *models.CollectionLinkAccess) error
+	GetAccessLog(ctx context.Context, linkID string, limit int) ([]*models.CollectionLinkAccess, error)
+	DeleteAccessBefore(ctx context.Context, cutoff time.Time) (int, error)
+}
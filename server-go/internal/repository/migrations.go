@@ -0,0 +1,566 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Dialect identifies the SQL flavour a migration runs against
+type Dialect string
+
+const (
+	DialectSQLite   Dialect = "sqlite"
+	DialectPostgres Dialect = "postgres"
+)
+
+// Migration is a numbered schema change that is applied exactly once per database.
+// Up receives the dialect so a single migration can cover both backends.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(tx *sql.Tx, dialect Dialect) error
+}
+
+// migrations lists every schema change in order. Append new entries with the
+// next version number; never edit or renumber a migration that has shipped.
+var migrations = []Migration{
+	{Version: 1, Description: "initial schema", Up: migrateInitialSchema},
+	{Version: 2, Description: "photo media type and duration", Up: migrateMediaType},
+	{Version: 3, Description: "photo thumbnail, EXIF, GPS and dimension columns", Up: migratePhotoMetadata},
+	{Version: 4, Description: "photo trash", Up: migratePhotoTrash},
+	{Version: 5, Description: "perceptual hashes and duplicate groups", Up: migrateDuplicateGroups},
+	{Version: 6, Description: "per-user photo ownership with shared blobs", Up: migratePhotoBlobs},
+	{Version: 7, Description: "per-user storage quotas and usage", Up: migrateUserQuotas},
+	{Version: 8, Description: "per-user photo change log", Up: migratePhotoChanges},
+	{Version: 9, Description: "photo descriptions", Up: migratePhotoDescription},
+	{Version: 10, Description: "app passwords", Up: migrateAppPasswords},
+	{Version: 11, Description: "device restore plans", Up: migrateRestorePlans},
+	{Version: 12, Description: "collection share links", Up: migrateCollectionLinks},
+	{Version: 13, Description: "smart collections", Up: migrateSmartCollections},
+	{Version: 14, Description: "collection share roles", Up: migrateShareRoles},
+	{Version: 15, Description: "gallery downloads", Up: migrateGalleryDownloads},
+	{Version: 16, Description: "gallery metadata stripping", Up: migrateMetadataStripping},
+	{Version: 17, Description: "photo change log write locks", Up: migratePhotoChangeLocks},
+	{Version: 18, Description: "share links for collection secret tokens", Up: migrateSecretTokenLinks},
+}
+
+// RunMigrations applies all pending migrations in order. Each migration runs in
+// its own transaction together with its schema_migrations record, so a failed
+// migration leaves the database at the previous version.
+func RunMigrations(db *sql.DB, dialect Dialect) error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			description TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		)
+	`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	applied, err := appliedMigrationVersions(db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+
+		if err := applyMigration(db, dialect, m); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Description, err)
+		}
+		log.Printf("Applied database migration %d: %s", m.Version, m.Description)
+	}
+
+	return nil
+}
+
+// SchemaVersion returns the highest applied migration version
+func SchemaVersion(db *sql.DB) (int, error) {
+	var version sql.NullInt64
+	if err := db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+func applyMigration(db *sql.DB, dialect Dialect, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.Up(tx, dialect); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(
+		`INSERT INTO schema_migrations (version, description, applied_at) VALUES ($1, $2, $3)`,
+		m.Version, m.Description, time.Now().UTC(),
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func appliedMigrationVersions(db *sql.DB) (map[int]bool, error) {
+	rows, err := db.Query(`SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// addColumnIfMissing adds a column unless it already exists. Databases created
+// before schema_migrations existed may already have some of the columns that
+// early migrations add, so those migrations must tolerate them.
+func addColumnIfMissing(tx *sql.Tx, dialect Dialect, table, column, definition string) error {
+	if dialect == DialectPostgres {
+		_, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s`, table, column, definition))
+		return err
+	}
+
+	var exists bool
+	if err := tx.QueryRow(
+		`SELECT COUNT(*) > 0 FROM pragma_table_info($1) WHERE name = $2`, table, column,
+	).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	_, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition))
+	return err
+}
+
+// migrateInitialSchema creates the base tables. It is written to be safe on
+// databases that predate versioned migrations.
+func migrateInitialSchema(tx *sql.Tx, dialect Dialect) error {
+	if dialect == DialectPostgres {
+		return createPostgresSchema(tx)
+	}
+	return createSQLiteSchema(tx)
+}
+
+// migrateMediaType adds video support columns to photos
+func migrateMediaType(tx *sql.Tx, dialect Dialect) error {
+	durationType := "REAL"
+	if dialect == DialectPostgres {
+		durationType = "DOUBLE PRECISION"
+	}
+
+	if err := addColumnIfMissing(tx, dialect, "photos", "media_type", "TEXT NOT NULL DEFAULT 'image'"); err != nil {
+		return err
+	}
+	return addColumnIfMissing(tx, dialect, "photos", "duration", durationType)
+}
+
+// migratePhotoMetadata brings the SQLite photos table up to the PostgreSQL
+// column set (thumbnails, EXIF, GPS, dimensions). PostgreSQL already has
+// these columns from the initial schema, so this only adds the location index there.
+func migratePhotoMetadata(tx *sql.Tx, dialect Dialect) error {
+	if dialect == DialectSQLite {
+		columns := []struct {
+			name       string
+			definition string
+		}{
+			{"thumb_small", "TEXT"},
+			{"thumb_medium", "TEXT"},
+			{"thumb_large", "TEXT"},
+			{"camera_make", "TEXT"},
+			{"camera_model", "TEXT"},
+			{"lens_model", "TEXT"},
+			{"focal_length", "TEXT"},
+			{"aperture", "TEXT"},
+			{"shutter_speed", "TEXT"},
+			{"iso", "INTEGER"},
+			{"orientation", "INTEGER DEFAULT 1"},
+			{"latitude", "REAL"},
+			{"longitude", "REAL"},
+			{"altitude", "REAL"},
+			{"width", "INTEGER"},
+			{"height", "INTEGER"},
+		}
+
+		for _, c := range columns {
+			if err := addColumnIfMissing(tx, dialect, "photos", c.name, c.definition); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Partial index for photos with GPS coordinates (for map view)
+	_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_photos_location ON photos(latitude, longitude) WHERE latitude IS NOT NULL`)
+	return err
+}
+
+// migratePhotoTrash adds soft delete support to photos
+func migratePhotoTrash(tx *sql.Tx, dialect Dialect) error {
+	timestampType := "DATETIME"
+	if dialect == DialectPostgres {
+		timestampType = "TIMESTAMP"
+	}
+
+	if err := addColumnIfMissing(tx, dialect, "photos", "deleted_at", timestampType); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_photos_deleted_at ON photos(deleted_at) WHERE deleted_at IS NOT NULL`)
+	return err
+}
+
+// migrateDuplicateGroups adds perceptual hashes to photos and the tables that
+// hold near-duplicate groups awaiting review
+func migrateDuplicateGroups(tx *sql.Tx, dialect Dialect) error {
+	timestampType := "DATETIME"
+	if dialect == DialectPostgres {
+		timestampType = "TIMESTAMP"
+	}
+
+	if err := addColumnIfMissing(tx, dialect, "photos", "phash", "TEXT"); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS duplicate_groups (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			status TEXT NOT NULL DEFAULT 'pending',
+			created_at %[1]s NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_duplicate_groups_user_status ON duplicate_groups(user_id, status);
+
+		CREATE TABLE IF NOT EXISTS duplicate_group_photos (
+			group_id TEXT NOT NULL REFERENCES duplicate_groups(id) ON DELETE CASCADE,
+			photo_id TEXT NOT NULL REFERENCES photos(id) ON DELETE CASCADE,
+			distance INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (group_id, photo_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_duplicate_group_photos_photo ON duplicate_group_photos(photo_id);
+	`, timestampType))
+	return err
+}
+
+// migratePhotoBlobs moves deduplication from a global file hash to per-user
+// ownership. Stored originals become reference-counted blobs so users who
+// upload the same content each get their own photo row over a single file.
+//
+// There are no shared rows to split. The old unique index on file_hash kept
+// one row per content, owned by whoever uploaded it first. A second user's
+// upload of the same content was answered with that row and recorded nothing,
+// and collections only ever held their owner's photos. Once per-user
+// dedup is in place, that user's next hash check or upload finds no photo of
+// theirs and gets a row of its own over the existing file.
+func migratePhotoBlobs(tx *sql.Tx, dialect Dialect) error {
+	timestampType := "DATETIME"
+	if dialect == DialectPostgres {
+		timestampType = "TIMESTAMP"
+	}
+
+	_, err := tx.Exec(fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS photo_blobs (
+			stored_path TEXT PRIMARY KEY,
+			file_hash TEXT NOT NULL,
+			file_size BIGINT NOT NULL,
+			ref_count INTEGER NOT NULL DEFAULT 0,
+			created_at %[1]s NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_photo_blobs_file_hash ON photo_blobs(file_hash);
+	`, timestampType))
+	if err != nil {
+		return err
+	}
+
+	// Every existing photo row becomes one reference to the file it points at
+	if _, err := tx.Exec(`
+		INSERT INTO photo_blobs (stored_path, file_hash, file_size, ref_count, created_at)
+		SELECT stored_path, MIN(file_hash), MAX(file_size), COUNT(*), MIN(uploaded_at)
+		FROM photos
+		GROUP BY stored_path
+	`); err != nil {
+		return err
+	}
+
+	// The same content may now exist once per user instead of once per server
+	_, err = tx.Exec(`
+		DROP INDEX IF EXISTS idx_photos_hash;
+		CREATE INDEX IF NOT EXISTS idx_photos_file_hash ON photos(file_hash);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_photos_user_hash ON photos(user_id, file_hash);
+	`)
+	return err
+}
+
+// migrateUserQuotas adds quota limits and running usage totals to users and
+// seeds the totals from the photos each user already owns
+func migrateUserQuotas(tx *sql.Tx, dialect Dialect) error {
+	columns := []struct {
+		name       string
+		definition string
+	}{
+		{"quota_bytes", "BIGINT NOT NULL DEFAULT 0"},
+		{"quota_photos", "INTEGER NOT NULL DEFAULT 0"},
+		{"used_bytes", "BIGINT NOT NULL DEFAULT 0"},
+		{"used_photos", "INTEGER NOT NULL DEFAULT 0"},
+	}
+	for _, c := range columns {
+		if err := addColumnIfMissing(tx, dialect, "users", c.name, c.definition); err != nil {
+			return err
+		}
+	}
+
+	// Trashed photos still occupy storage until they are purged
+	_, err := tx.Exec(`
+		UPDATE users SET
+			used_bytes = (SELECT COALESCE(SUM(file_size), 0) FROM photos WHERE photos.user_id = users.id),
+			used_photos = (SELECT COUNT(*) FROM photos WHERE photos.user_id = users.id)
+	`)
+	return err
+}
+
+// migratePhotoChanges adds the change log that devices sync from and seeds it
+// with an "added" entry for every live photo, so a client starting from
+// sequence 0 sees the whole library
+func migratePhotoChanges(tx *sql.Tx, dialect Dialect) error {
+	timestampType := "DATETIME"
+	// AUTOINCREMENT keeps SQLite from reusing sequence numbers after compaction
+	seqType := "INTEGER PRIMARY KEY AUTOINCREMENT"
+	if dialect == DialectPostgres {
+		timestampType = "TIMESTAMP"
+		seqType = "BIGSERIAL PRIMARY KEY"
+	}
+
+	_, err := tx.Exec(fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS photo_changes (
+			seq %[2]s,
+			user_id TEXT NOT NULL,
+			photo_id TEXT NOT NULL,
+			change_type TEXT NOT NULL,
+			changed_at %[1]s NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_photo_changes_user_seq ON photo_changes(user_id, seq);
+		CREATE INDEX IF NOT EXISTS idx_photo_changes_user_photo ON photo_changes(user_id, photo_id);
+
+		CREATE TABLE IF NOT EXISTS photo_change_floors (
+			user_id TEXT PRIMARY KEY,
+			seq BIGINT NOT NULL
+		);
+	`, timestampType, seqType))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO photo_changes (user_id, photo_id, change_type, changed_at)
+		SELECT user_id, id, 'added', uploaded_at FROM photos
+		WHERE user_id IS NOT NULL AND deleted_at IS NULL
+		ORDER BY uploaded_at, id
+	`)
+	return err
+}
+
+// migratePhotoDescription adds a caption column, filled from imported libraries
+func migratePhotoDescription(tx *sql.Tx, dialect Dialect) error {
+	return addColumnIfMissing(tx, dialect, "photos", "description", "TEXT")
+}
+
+// migrateAppPasswords adds revocable passwords for clients such as WebDAV
+// mounts that can only send a username and password
+func migrateAppPasswords(tx *sql.Tx, dialect Dialect) error {
+	timestampType := "DATETIME"
+	if dialect == DialectPostgres {
+		timestampType = "TIMESTAMP"
+	}
+
+	_, err := tx.Exec(fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS app_passwords (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			password_hash TEXT NOT NULL UNIQUE,
+			created_at %[1]s NOT NULL,
+			last_used_at %[1]s
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_app_passwords_user ON app_passwords(user_id);
+	`, timestampType))
+	return err
+}
+
+// migrateRestorePlans adds the plans that list which photos a device should
+// download to restore the library, and which of them it has confirmed
+func migrateRestorePlans(tx *sql.Tx, dialect Dialect) error {
+	timestampType := "DATETIME"
+	if dialect == DialectPostgres {
+		timestampType = "TIMESTAMP"
+	}
+
+	_, err := tx.Exec(fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS restore_plans (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			device_id TEXT NOT NULL,
+			source_device_id TEXT,
+			collection_id TEXT,
+			date_from %[1]s,
+			date_to %[1]s,
+			status TEXT NOT NULL DEFAULT 'active',
+			created_at %[1]s NOT NULL,
+			updated_at %[1]s NOT NULL,
+			completed_at %[1]s
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_restore_plans_user_device ON restore_plans(user_id, device_id);
+
+		CREATE TABLE IF NOT EXISTS restore_plan_items (
+			plan_id TEXT NOT NULL REFERENCES restore_plans(id) ON DELETE CASCADE,
+			position INTEGER NOT NULL,
+			photo_id TEXT NOT NULL,
+			file_hash TEXT NOT NULL,
+			file_size BIGINT NOT NULL,
+			downloaded_at %[1]s,
+			PRIMARY KEY (plan_id, position)
+		);
+
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_restore_plan_items_photo ON restore_plan_items(plan_id, photo_id);
+	`, timestampType))
+	return err
+}
+
+// migrateCollectionLinks adds share links to collections, each with its own
+// password, expiry and view limit, and a log of visits to them
+func migrateCollectionLinks(tx *sql.Tx, dialect Dialect) error {
+	timestampType := "DATETIME"
+	seqType := "INTEGER PRIMARY KEY AUTOINCREMENT"
+	if dialect == DialectPostgres {
+		timestampType = "TIMESTAMP"
+		seqType = "BIGSERIAL PRIMARY KEY"
+	}
+
+	_, err := tx.Exec(fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS collection_links (
+			id TEXT PRIMARY KEY,
+			collection_id TEXT NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+			token TEXT NOT NULL UNIQUE,
+			label TEXT NOT NULL DEFAULT '',
+			password_hash TEXT,
+			expires_at %[1]s,
+			max_views INTEGER,
+			view_count INTEGER NOT NULL DEFAULT 0,
+			created_at %[1]s NOT NULL,
+			last_viewed_at %[1]s,
+			revoked_at %[1]s
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_collection_links_collection ON collection_links(collection_id);
+
+		CREATE TABLE IF NOT EXISTS collection_link_access (
+			id %[2]s,
+			link_id TEXT NOT NULL REFERENCES collection_links(id) ON DELETE CASCADE,
+			accessed_at %[1]s NOT NULL,
+			ip_address TEXT NOT NULL DEFAULT '',
+			user_agent TEXT NOT NULL DEFAULT '',
+			outcome TEXT NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_collection_link_access_link ON collection_link_access(link_id, accessed_at);
+	`, timestampType, seqType))
+	return err
+}
+
+// migrateSmartCollections lets a collection's photos be chosen by saved rules
+// instead of collection_photos. The rules are stored as JSON.
+func migrateSmartCollections(tx *sql.Tx, dialect Dialect) error {
+	if err := addColumnIfMissing(tx, dialect, "collections", "kind", "TEXT NOT NULL DEFAULT 'manual'"); err != nil {
+		return err
+	}
+	return addColumnIfMissing(tx, dialect, "collections", "smart_rules", "TEXT")
+}
+
+// migrateShareRoles gives each share a role and records who added each photo
+// to a collection. Photos already in a collection were added by its owner.
+func migrateShareRoles(tx *sql.Tx, dialect Dialect) error {
+	if err := addColumnIfMissing(tx, dialect, "collection_shares", "role", "TEXT NOT NULL DEFAULT 'viewer'"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(tx, dialect, "collection_photos", "added_by", "TEXT"); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`UPDATE collection_photos SET added_by =
+		(SELECT user_id FROM collections WHERE collections.id = collection_photos.collection_id)
+		WHERE added_by IS NULL`)
+	return err
+}
+
+// migrateGalleryDownloads lets owners offer a collection's gallery as a ZIP.
+// Downloads are off until the owner turns them on.
+func migrateGalleryDownloads(tx *sql.Tx, dialect Dialect) error {
+	def := "INTEGER NOT NULL DEFAULT 0"
+	if dialect == DialectPostgres {
+		def = "BOOLEAN NOT NULL DEFAULT FALSE"
+	}
+	return addColumnIfMissing(tx, dialect, "collections", "allow_downloads", def)
+}
+
+// migrateMetadataStripping records which metadata a collection's gallery
+// removes from the originals it serves. Existing galleries stop revealing
+// where their photos were taken; items that cannot be stripped on this
+// server are shown to visitors as thumbnails only.
+func migrateMetadataStripping(tx *sql.Tx, dialect Dialect) error {
+	return addColumnIfMissing(tx, dialect, "collections", "metadata_stripping", "TEXT NOT NULL DEFAULT 'location'")
+}
+
+// migratePhotoChangeLocks adds one lock row per user that change log writers
+// take before appending, so each user's entries commit in seq order
+func migratePhotoChangeLocks(tx *sql.Tx, dialect Dialect) error {
+	timestampType := "DATETIME"
+	if dialect == DialectPostgres {
+		timestampType = "TIMESTAMP"
+	}
+
+	_, err := tx.Exec(fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS photo_change_locks (
+			user_id TEXT PRIMARY KEY,
+			locked_at %s NOT NULL
+		)
+	`, timestampType))
+	return err
+}
+
+// migrateSecretTokenLinks turns the secret token of each collection shared by
+// secret link into a share link, so it can be revoked, expired and logged like
+// any other. Tokens of collections no longer shared by link stopped working
+// when the collection left secret_link and are dropped.
+func migrateSecretTokenLinks(tx *sql.Tx, dialect Dialect) error {
+	_, err := tx.Exec(`
+		INSERT INTO collection_links (id, collection_id, token, label, created_at)
+		SELECT 'secret-' || id, id, secret_token, 'Secret link', CURRENT_TIMESTAMP FROM collections
+		WHERE visibility = 'secret_link' AND secret_token IS NOT NULL
+		  AND secret_token NOT IN (SELECT token FROM collection_links)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE collections SET secret_token = NULL WHERE visibility <> 'secret_link'`)
+	return err
+}
@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// latestVersion is the version a fully migrated database is at
+func latestVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+func appliedAt(t *testing.T, db *sql.DB) map[int]string {
+	rows, err := db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	applied := make(map[int]string)
+	for rows.Next() {
+		var version int
+		var at string
+		require.NoError(t, rows.Scan(&version, &at))
+		applied[version] = at
+	}
+	require.NoError(t, rows.Err())
+	return applied
+}
+
+func TestRunMigrations_FreshDatabase(t *testing.T) {
+	db, err := NewSQLiteDB(filepath.Join(t.TempDir(), "photosync.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	version, err := SchemaVersion(db)
+	require.NoError(t, err)
+	assert.Equal(t, latestVersion(), version)
+	assert.Len(t, appliedAt(t, db), len(migrations))
+
+	// The photo columns SQLite used to lack are usable
+	ctx := context.Background()
+	repo := NewPhotoRepository(db)
+	photo, err := models.NewPhoto("beach.jpg", "2024/01/beach.jpg", "abc", 1000, time.Now().UTC())
+	require.NoError(t, err)
+	thumb := ".thumbs/beach_small.jpg"
+	width := 4032
+	photo.ThumbSmall = &thumb
+	photo.Width = &width
+	require.NoError(t, repo.Add(ctx, photo))
+	got, err := repo.GetByID(ctx, photo.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, &thumb, got.ThumbSmall)
+	assert.Equal(t, &width, got.Width)
+}
+
+func TestRunMigrations_Idempotent(t *testing.T) {
+	db, err := NewSQLiteDB(filepath.Join(t.TempDir(), "photosync.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	before := appliedAt(t, db)
+
+	require.NoError(t, RunMigrations(db, DialectSQLite))
+	require.NoError(t, RunMigrations(db, DialectSQLite))
+	assert.Equal(t, before, appliedAt(t, db), "nothing is applied twice")
+}
+
+// baselineTime is when the rows seeded into baseline databases were taken
+var baselineTime = time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+
+// upgradeBaselineDB creates a database as the server left it before versioned
+// migrations existed, runs the seed statements against it and migrates it.
+// $1 in a statement is bound to baselineTime.
+func upgradeBaselineDB(t *testing.T, seed ...string) *sql.DB {
+	path := filepath.Join(t.TempDir(), "photosync.db")
+	baseline, err := os.ReadFile(filepath.Join("testdata", "baseline_sqlite.sql"))
+	require.NoError(t, err)
+	legacy, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	_, err = legacy.Exec(string(baseline))
+	require.NoError(t, err)
+	for _, stmt := range seed {
+		_, err := legacy.Exec(stmt, baselineTime)
+		require.NoError(t, err, stmt)
+	}
+	require.NoError(t, legacy.Close())
+
+	db, err := NewSQLiteDB(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestRunMigrations_UpgradesBaselineSchema(t *testing.T) {
+	ctx := context.Background()
+	db := upgradeBaselineDB(t,
+		`INSERT INTO users (id, email, display_name, api_key, api_key_hash) VALUES ('user-1', 'user-1@example.com', 'User', 'key', 'hash')`,
+		`INSERT INTO photos (id, user_id, original_filename, stored_path, file_hash, file_size, date_taken, uploaded_at)
+		 VALUES ('photo-1', 'user-1', 'beach.jpg', '2023/07/beach.jpg', 'abc', 1000, $1, $1)`,
+		`INSERT INTO collections (id, user_id, name, slug) VALUES ('collection-1', 'user-1', 'Beach', 'beach')`,
+		`INSERT INTO collection_photos (id, collection_id, photo_id, position, added_at) VALUES ('cp-1', 'collection-1', 'photo-1', 0, $1)`,
+	)
+
+	version, err := SchemaVersion(db)
+	require.NoError(t, err)
+	assert.Equal(t, latestVersion(), version)
+
+	// Existing rows survive and the backfills saw them
+	photo, err := NewPhotoRepository(db).GetByID(ctx, "photo-1")
+	require.NoError(t, err)
+	require.NotNil(t, photo)
+	assert.Equal(t, "2023/07/beach.jpg", photo.StoredPath)
+	assert.Equal(t, models.MediaTypeImage, photo.MediaType)
+
+	var refs, usedBytes int64
+	require.NoError(t, db.QueryRow(`SELECT ref_count FROM photo_blobs WHERE stored_path = '2023/07/beach.jpg'`).Scan(&refs))
+	assert.EqualValues(t, 1, refs)
+	require.NoError(t, db.QueryRow(`SELECT used_bytes FROM users WHERE id = 'user-1'`).Scan(&usedBytes))
+	assert.EqualValues(t, 1000, usedBytes)
+
+	entries, err := NewCollectionPhotoRepository(db).GetByCollectionID(ctx, "collection-1")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "user-1", entries[0].AddedBy)
+
+	collection, err := NewCollectionRepository(db).GetByID(ctx, "collection-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.CollectionManual, collection.Kind)
+
+	// Rerunning on the upgraded database changes nothing
+	before := appliedAt(t, db)
+	require.NoError(t, RunMigrations(db, DialectSQLite))
+	assert.Equal(t, before, appliedAt(t, db))
+}
+
+func TestMigrateMetadataStripping(t *testing.T) {
+	ctx := context.Background()
+	db := upgradeBaselineDB(t,
+		`INSERT INTO users (id, email, display_name, api_key, api_key_hash) VALUES ('user-1', 'user-1@example.com', 'User', 'key', 'hash')`,
+		`INSERT INTO collections (id, user_id, name, slug, visibility) VALUES ('collection-1', 'user-1', 'Beach', 'beach', 'public')`,
+	)
+	collectionRepo := NewCollectionRepository(db)
+
+	existing, err := collectionRepo.GetByID(ctx, "collection-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.StripLocation, existing.MetadataStripping, "existing galleries stop revealing locations")
+
+	collection, err := models.NewCollection("user-1", "Garden")
+	require.NoError(t, err)
+	require.NoError(t, collectionRepo.Add(ctx, collection))
+	created, err := collectionRepo.GetByID(ctx, collection.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StripLocation, created.MetadataStripping)
+}
+
+func TestMigrateSecretTokenLinks(t *testing.T) {
+	ctx := context.Background()
+	db := upgradeBaselineDB(t,
+		`INSERT INTO users (id, email, display_name, api_key, api_key_hash) VALUES ('user-1', 'user-1@example.com', 'User', 'key', 'hash')`,
+		`INSERT INTO collections (id, user_id, name, slug, visibility, secret_token) VALUES ('collection-1', 'user-1', 'Beach', 'beach', 'secret_link', 'token-1')`,
+		`INSERT INTO collections (id, user_id, name, slug, visibility, secret_token) VALUES ('collection-2', 'user-1', 'Garden', 'garden', 'private', 'token-2')`,
+	)
+	linkRepo := NewCollectionLinkRepository(db)
+
+	// The token of a collection shared by link becomes a revocable link
+	link, err := linkRepo.GetByToken(ctx, "token-1")
+	require.NoError(t, err)
+	require.NotNil(t, link)
+	assert.Equal(t, "collection-1", link.CollectionID)
+	assert.NoError(t, link.Usable(time.Now()))
+	revoked, err := linkRepo.Revoke(ctx, link.ID, time.Now().UTC())
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	// A token that no longer opened anything is dropped
+	link, err = linkRepo.GetByToken(ctx, "token-2")
+	require.NoError(t, err)
+	assert.Nil(t, link)
+	collection, err := NewCollectionRepository(db).GetByID(ctx, "collection-2")
+	require.NoError(t, err)
+	assert.Nil(t, collection.SecretToken)
+}
+
+func TestMigratePhotoBlobs_SecondUploaderGetsOwnRow(t *testing.T) {
+	ctx := context.Background()
+	// Before the migration user-2's upload of the same content was answered
+	// with user-1's row, so both users' devices know it by that row's ID
+	db := upgradeBaselineDB(t,
+		`INSERT INTO users (id, email, display_name, api_key, api_key_hash) VALUES ('user-1', 'user-1@example.com', 'User 1', 'key-1', 'hash-1')`,
+		`INSERT INTO users (id, email, display_name, api_key, api_key_hash) VALUES ('user-2', 'user-2@example.com', 'User 2', 'key-2', 'hash-2')`,
+		`INSERT INTO photos (id, user_id, original_filename, stored_path, file_hash, file_size, date_taken, uploaded_at)
+		 VALUES ('photo-1', 'user-1', 'beach.jpg', '2023/07/beach.jpg', 'abc', 1000, $1, $1)`,
+	)
+	repo := NewPhotoRepository(db)
+
+	// The row stays user-1's; user-2 has no photo of that content yet
+	existing, err := repo.GetByHashAndUser(ctx, "abc", "user-1")
+	require.NoError(t, err)
+	require.NotNil(t, existing)
+	assert.Equal(t, "photo-1", existing.ID)
+	theirs, err := repo.GetByHashAndUser(ctx, "abc", "user-2")
+	require.NoError(t, err)
+	assert.Nil(t, theirs, "user-2's device uploads the content again")
+
+	// That upload reuses the stored file under a row of user-2's own
+	blob, err := repo.GetBlobByHash(ctx, "abc")
+	require.NoError(t, err)
+	require.NotNil(t, blob)
+	photo, err := models.NewPhoto("beach.jpg", blob.StoredPath, "abc", 1000, baselineTime)
+	require.NoError(t, err)
+	userID := "user-2"
+	photo.UserID = &userID
+	require.NoError(t, repo.Add(ctx, photo))
+
+	refs, err := repo.GetBlobRefCount(ctx, "2023/07/beach.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, 2, refs)
+	for _, id := range []string{"user-1", "user-2"} {
+		var usedBytes int64
+		require.NoError(t, db.QueryRow(`SELECT used_bytes FROM users WHERE id = $1`, id).Scan(&usedBytes))
+		assert.EqualValues(t, 1000, usedBytes, id)
+	}
+
+	// Deleting user-1's photo leaves the file to user-2
+	deleted, refs, err := repo.DeleteReleasingBlob(ctx, "photo-1")
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	assert.Equal(t, 1, refs)
+}
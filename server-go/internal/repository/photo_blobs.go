@@ -0,0 +1,273 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+)
+
+// Photo rows own their metadata but share the stored original: every row with
+// the same stored_path holds one reference in photo_blobs. These helpers are
+// shared by the SQLite and PostgreSQL photo repositories and run inside the
+// transaction that adds or deletes the photo row. The same transaction keeps
+// the owner's usage totals in step, so quotas never need a full recount.
+
+// execQueryer is satisfied by both *sql.DB and *sql.Tx
+type execQueryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// acquireBlobRef adds a reference to the photo's stored file, creating the blob on first use
+func acquireBlobRef(ctx context.Context, db execQueryer, photo *models.Photo) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO photo_blobs (stored_path, file_hash, file_size, ref_count, created_at)
+		VALUES ($1, $2, $3, 1, $4)
+		ON CONFLICT (stored_path) DO UPDATE SET ref_count = photo_blobs.ref_count + 1
+	`, photo.StoredPath, photo.FileHash, photo.FileSize, time.Now().UTC())
+	return err
+}
+
+// releaseBlobRef drops a reference to a stored file, forgets the blob when
+// nothing points at it any more and returns how many references remain
+func releaseBlobRef(ctx context.Context, db execQueryer, storedPath string) (int, error) {
+	if _, err := db.ExecContext(ctx,
+		`UPDATE photo_blobs SET ref_count = ref_count - 1 WHERE stored_path = $1`, storedPath,
+	); err != nil {
+		return 0, err
+	}
+	refs, err := getBlobRefCount(ctx, db, storedPath)
+	if err != nil {
+		return 0, err
+	}
+	if refs > 0 {
+		return refs, nil
+	}
+	_, err = db.ExecContext(ctx, `DELETE FROM photo_blobs WHERE stored_path = $1`, storedPath)
+	return 0, err
+}
+
+// adjustUserUsage adds the given deltas to a user's usage totals. Every photo
+// row counts against its owner, even when the stored file is shared.
+func adjustUserUsage(ctx context.Context, db execQueryer, userID *string, bytes int64, photos int) error {
+	if userID == nil || *userID == "" || (bytes == 0 && photos == 0) {
+		return nil
+	}
+	_, err := db.ExecContext(ctx,
+		`UPDATE users SET used_bytes = used_bytes + $1, used_photos = used_photos + $2 WHERE id = $3`,
+		bytes, photos, *userID,
+	)
+	return err
+}
+
+// chargeUserUsage adds a new photo to its owner's usage totals, but only while
+// the owner stays within their quotas. The check and the charge are one
+// statement, so concurrent uploads cannot both pass a check that only one of
+// them fits. It returns ErrStorageQuotaExceeded or ErrPhotoQuotaExceeded when
+// the photo does not fit.
+func chargeUserUsage(ctx context.Context, db execQueryer, userID *string, bytes int64) error {
+	if userID == nil || *userID == "" {
+		return nil
+	}
+	result, err := db.ExecContext(ctx, `
+		UPDATE users SET used_bytes = used_bytes + $1, used_photos = used_photos + 1
+		WHERE id = $2
+		  AND (quota_bytes = 0 OR used_bytes + $1 <= quota_bytes)
+		  AND (quota_photos = 0 OR used_photos + 1 <= quota_photos)
+	`, bytes, *userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+
+	// Nothing was charged: find out which quota is full, if the owner exists
+	var user models.User
+	err = db.QueryRowContext(ctx,
+		`SELECT used_bytes, quota_bytes, used_photos, quota_photos FROM users WHERE id = $1`, *userID,
+	).Scan(&user.UsedBytes, &user.QuotaBytes, &user.UsedPhotos, &user.QuotaPhotos)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := user.CheckQuota(bytes); err != nil {
+		return err
+	}
+	return models.ErrStorageQuotaExceeded
+}
+
+// addPhotoWithBlobRef inserts a photo row and its blob reference atomically.
+// The owner's quota is enforced in the same transaction.
+func addPhotoWithBlobRef(ctx context.Context, db *sql.DB, photo *models.Photo, insert func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insert(tx); err != nil {
+		return err
+	}
+	if err := acquireBlobRef(ctx, tx, photo); err != nil {
+		return err
+	}
+	if err := chargeUserUsage(ctx, tx, photo.UserID, photo.FileSize); err != nil {
+		return err
+	}
+	if err := recordPhotoChange(ctx, tx, photo.UserID, photo.ID, models.PhotoChangeAdded); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// updatePhotoWithUsage rewrites a photo row and moves its usage to the new
+// owner or size atomically. A change of owner is a deletion for the old
+// owner's change log and a claim for the new one. A change of stored path
+// moves the photo's blob reference to the new file.
+func updatePhotoWithUsage(ctx context.Context, db *sql.DB, photo *models.Photo, update func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldUserID sql.NullString
+	var oldSize int64
+	var oldPath string
+	err = tx.QueryRowContext(ctx,
+		`SELECT user_id, file_size, stored_path FROM photos WHERE id = $1`, photo.ID,
+	).Scan(&oldUserID, &oldSize, &oldPath)
+	if err == sql.ErrNoRows {
+		return nil // Updating a missing photo is a no-op
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := update(tx); err != nil {
+		return err
+	}
+
+	if photo.StoredPath != oldPath {
+		if _, err := releaseBlobRef(ctx, tx, oldPath); err != nil {
+			return err
+		}
+		if err := acquireBlobRef(ctx, tx, photo); err != nil {
+			return err
+		}
+	}
+
+	if photo.UserID != nil && oldUserID.Valid && *photo.UserID == oldUserID.String {
+		if err := adjustUserUsage(ctx, tx, photo.UserID, photo.FileSize-oldSize, 0); err != nil {
+			return err
+		}
+		if err := recordPhotoChange(ctx, tx, photo.UserID, photo.ID, models.PhotoChangeUpdated); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	var oldOwner *string
+	if oldUserID.Valid {
+		oldOwner = &oldUserID.String
+	}
+	owners := []string{oldUserID.String}
+	if photo.UserID != nil {
+		owners = append(owners, *photo.UserID)
+	}
+	if err := lockPhotoChangeLogs(ctx, tx, owners...); err != nil {
+		return err
+	}
+	if err := adjustUserUsage(ctx, tx, oldOwner, -oldSize, -1); err != nil {
+		return err
+	}
+	if err := adjustUserUsage(ctx, tx, photo.UserID, photo.FileSize, 1); err != nil {
+		return err
+	}
+	if err := recordPhotoChange(ctx, tx, oldOwner, photo.ID, models.PhotoChangeDeleted); err != nil {
+		return err
+	}
+	if err := recordPhotoChange(ctx, tx, photo.UserID, photo.ID, models.PhotoChangeClaimed); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// deletePhotoWithBlobRef deletes a photo row and releases its blob reference
+// atomically. It returns whether the row existed and how many photos still
+// reference its stored file once the transaction commits.
+func deletePhotoWithBlobRef(ctx context.Context, db *sql.DB, id string) (bool, int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, 0, err
+	}
+	defer tx.Rollback()
+
+	var storedPath string
+	var userID sql.NullString
+	var fileSize int64
+	err = tx.QueryRowContext(ctx,
+		`SELECT stored_path, user_id, file_size FROM photos WHERE id = $1`, id,
+	).Scan(&storedPath, &userID, &fileSize)
+	if err == sql.ErrNoRows {
+		return false, 0, nil
+	}
+	if err != nil {
+		return false, 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM photos WHERE id = $1`, id); err != nil {
+		return false, 0, err
+	}
+	refs, err := releaseBlobRef(ctx, tx, storedPath)
+	if err != nil {
+		return false, 0, err
+	}
+	if userID.Valid {
+		if err := adjustUserUsage(ctx, tx, &userID.String, -fileSize, -1); err != nil {
+			return false, 0, err
+		}
+		if err := recordPhotoChange(ctx, tx, &userID.String, id, models.PhotoChangeDeleted); err != nil {
+			return false, 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, 0, err
+	}
+	return true, refs, nil
+}
+
+// getBlobByHash returns the oldest stored blob with the given content hash, or nil
+func getBlobByHash(ctx context.Context, db execQueryer, hash string) (*models.PhotoBlob, error) {
+	var blob models.PhotoBlob
+	err := db.QueryRowContext(ctx, `
+		SELECT stored_path, file_hash, file_size, ref_count, created_at
+		FROM photo_blobs WHERE file_hash = $1
+		ORDER BY created_at ASC LIMIT 1
+	`, hash).Scan(&blob.StoredPath, &blob.FileHash, &blob.FileSize, &blob.RefCount, &blob.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &blob, nil
+}
+
+// getBlobRefCount returns how many photo rows reference a stored file
+func getBlobRefCount(ctx context.Context, db execQueryer, storedPath string) (int, error) {
+	var count int
+	err := db.QueryRowContext(ctx,
+		`SELECT ref_count FROM photo_blobs WHERE stored_path = $1`, storedPath,
+	).Scan(&count)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return count, err
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPhotoRepository_BlobReferences(t *testing.T) {
+	ctx := context.Background()
+	db, err := NewSQLiteDB(filepath.Join(t.TempDir(), "photosync.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	userRepo := NewUserRepository(db)
+	var owners []*models.User
+	for _, email := range []string{"user-1@example.com", "user-2@example.com"} {
+		user, err := models.NewUser(email, "User", false)
+		require.NoError(t, err)
+		require.NoError(t, userRepo.Add(ctx, user))
+		owners = append(owners, user)
+	}
+
+	repo := NewPhotoRepository(db)
+	var photos []*models.Photo
+	for _, owner := range owners {
+		photo, err := models.NewPhoto("beach.jpg", "2024/01/beach.jpg", "abc", 1000, time.Now().UTC())
+		require.NoError(t, err)
+		photo.UserID = &owner.ID
+		require.NoError(t, repo.Add(ctx, photo))
+		photos = append(photos, photo)
+	}
+
+	refs, err := repo.GetBlobRefCount(ctx, "2024/01/beach.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, 2, refs)
+
+	// Moving one photo's file moves its reference
+	photos[1].StoredPath = "2024/01/beach_1.jpg"
+	require.NoError(t, repo.Update(ctx, photos[1]))
+	refs, err = repo.GetBlobRefCount(ctx, "2024/01/beach.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, 1, refs)
+	refs, err = repo.GetBlobRefCount(ctx, "2024/01/beach_1.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, 1, refs)
+
+	deleted, refs, err := repo.DeleteReleasingBlob(ctx, photos[0].ID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	assert.Equal(t, 0, refs, "the last reference to the original path is gone")
+
+	deleted, refs, err = repo.DeleteReleasingBlob(ctx, photos[0].ID)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+	assert.Equal(t, 0, refs)
+}
+
+func TestPhotoRepository_AddEnforcesQuota(t *testing.T) {
+	ctx := context.Background()
+	db, err := NewSQLiteDB(filepath.Join(t.TempDir(), "photosync.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	user, err := models.NewUser("user-1@example.com", "User", false)
+	require.NoError(t, err)
+	userRepo := NewUserRepository(db)
+	require.NoError(t, userRepo.Add(ctx, user))
+	_, err = db.Exec(`UPDATE users SET quota_bytes = 1500, quota_photos = 3 WHERE id = $1`, user.ID)
+	require.NoError(t, err)
+
+	repo := NewPhotoRepository(db)
+	add := func(name string, size int64) error {
+		photo, err := models.NewPhoto(name, "2024/01/"+name, name, size, time.Now().UTC())
+		require.NoError(t, err)
+		photo.UserID = &user.ID
+		return repo.Add(ctx, photo)
+	}
+
+	require.NoError(t, add("a.jpg", 1000))
+	assert.Equal(t, models.ErrStorageQuotaExceeded, add("b.jpg", 600))
+	require.NoError(t, add("c.jpg", 500), "exactly filling the quota is allowed")
+
+	_, err = db.Exec(`UPDATE users SET quota_bytes = 0 WHERE id = $1`, user.ID)
+	require.NoError(t, err)
+	require.NoError(t, add("d.jpg", 1))
+	assert.Equal(t, models.ErrPhotoQuotaExceeded, add("e.jpg", 1))
+
+	// A refused photo leaves no row, blob or usage behind
+	count, err := repo.GetCountForUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	refs, err := repo.GetBlobRefCount(ctx, "2024/01/b.jpg")
+	require.NoError(t, err)
+	assert.Zero(t, refs)
+	got, err := userRepo.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1501, got.UsedBytes)
+	assert.Equal(t, 3, got.UsedPhotos)
+}
@@ -0,0 +1,304 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+)
+
+// Every mutation of a photo row appends to its owner's change log in the same
+// transaction, so the log can never disagree with the photos table. The
+// helpers below are shared by the SQLite and PostgreSQL photo repositories.
+//
+// Clients use seq directly as their cursor, so a user's entries must become
+// visible in seq order. PostgreSQL hands out BIGSERIAL values when a row is
+// inserted, not when it commits, so two transactions could otherwise commit
+// out of order and a client reading in between would step past the lower seq
+// before it was visible. Every writer therefore locks the user's row in
+// photo_change_locks before it appends. The lock is held until the
+// transaction ends, so the next writer draws its seq only after the entries
+// before it are visible.
+
+// lockPhotoChangeLogs takes the change log write lock of each given user.
+// Locks are taken in a fixed order so transactions that touch several users
+// cannot deadlock each other.
+func lockPhotoChangeLogs(ctx context.Context, db execQueryer, userIDs ...string) error {
+	sorted := make([]string, 0, len(userIDs))
+	for _, id := range userIDs {
+		if id != "" {
+			sorted = append(sorted, id)
+		}
+	}
+	sort.Strings(sorted)
+
+	for i, id := range sorted {
+		if i > 0 && id == sorted[i-1] {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, `
+			INSERT INTO photo_change_locks (user_id, locked_at) VALUES ($1, CURRENT_TIMESTAMP)
+			ON CONFLICT (user_id) DO UPDATE SET locked_at = EXCLUDED.locked_at
+		`, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockAllPhotoChangeLogs takes the change log write lock of every user that
+// owns a photo, for statements that log changes for many users at once
+func lockAllPhotoChangeLogs(ctx context.Context, db execQueryer) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO photo_change_locks (user_id, locked_at)
+		SELECT DISTINCT user_id, CURRENT_TIMESTAMP FROM photos WHERE user_id IS NOT NULL
+		ORDER BY user_id
+		ON CONFLICT (user_id) DO UPDATE SET locked_at = EXCLUDED.locked_at
+	`)
+	return err
+}
+
+// recordPhotoChange appends an entry to the owner's change log. Photos
+// without an owner are not synced and have no log.
+func recordPhotoChange(ctx context.Context, db execQueryer, userID *string, photoID string, changeType models.PhotoChangeType) error {
+	if userID == nil || *userID == "" {
+		return nil
+	}
+	if err := lockPhotoChangeLogs(ctx, db, *userID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO photo_changes (user_id, photo_id, change_type, changed_at) VALUES ($1, $2, $3, $4)`,
+		*userID, photoID, string(changeType), time.Now().UTC(),
+	)
+	return err
+}
+
+// recordPhotoChangeByID logs a change for the photo's current owner
+func recordPhotoChangeByID(ctx context.Context, db execQueryer, photoID string, changeType models.PhotoChangeType) error {
+	var userID sql.NullString
+	err := db.QueryRowContext(ctx, `SELECT user_id FROM photos WHERE id = $1`, photoID).Scan(&userID)
+	if err == sql.ErrNoRows || (err == nil && !userID.Valid) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return recordPhotoChange(ctx, db, &userID.String, photoID, changeType)
+}
+
+// updatePhotoWithChange runs an UPDATE of a single photo and logs the change
+// when the update touched the row
+func updatePhotoWithChange(ctx context.Context, db *sql.DB, photoID string, changeType models.PhotoChangeType, query string, args ...interface{}) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	if err := recordPhotoChangeByID(ctx, tx, photoID, changeType); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// claimPhotosWithChanges runs an UPDATE that claims several photos and logs a
+// claim for each of them. selectQuery must return the id and user_id of
+// exactly the rows the update will change.
+func claimPhotosWithChanges(ctx context.Context, db *sql.DB, selectQuery string, selectArgs []interface{}, updateQuery string, updateArgs []interface{}) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	type claimed struct {
+		photoID string
+		userID  sql.NullString
+	}
+	rows, err := tx.QueryContext(ctx, selectQuery, selectArgs...)
+	if err != nil {
+		return 0, err
+	}
+	var photos []claimed
+	for rows.Next() {
+		var c claimed
+		if err := rows.Scan(&c.photoID, &c.userID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		photos = append(photos, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, updateQuery, updateArgs...)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	var owners []string
+	for _, c := range photos {
+		if c.userID.Valid {
+			owners = append(owners, c.userID.String)
+		}
+	}
+	if err := lockPhotoChangeLogs(ctx, tx, owners...); err != nil {
+		return 0, err
+	}
+	for _, c := range photos {
+		if !c.userID.Valid {
+			continue
+		}
+		if err := recordPhotoChange(ctx, tx, &c.userID.String, c.photoID, models.PhotoChangeClaimed); err != nil {
+			return 0, err
+		}
+	}
+
+	return int(affected), tx.Commit()
+}
+
+// PhotoChangeRepository reads and compacts the per-user photo change log
+type PhotoChangeRepository struct {
+	db *sql.DB
+}
+
+// NewPhotoChangeRepository creates a new PhotoChangeRepository
+func NewPhotoChangeRepository(db *sql.DB) *PhotoChangeRepository {
+	return &PhotoChangeRepository{db: db}
+}
+
+// GetSince returns up to limit of the user's changes with a sequence after since, oldest first.
+// Writers hold the user's change log lock until they commit, so no entry
+// with a lower seq than the ones returned can still become visible.
+func (r *PhotoChangeRepository) GetSince(ctx context.Context, userID string, since int64, limit int) ([]*models.PhotoChange, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT seq, user_id, photo_id, change_type, changed_at
+		FROM photo_changes
+		WHERE user_id = $1 AND seq > $2
+		ORDER BY seq ASC
+		LIMIT $3
+	`, userID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []*models.PhotoChange
+	for rows.Next() {
+		var c models.PhotoChange
+		var changeType string
+		if err := rows.Scan(&c.Seq, &c.UserID, &c.PhotoID, &changeType, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		c.Type = models.PhotoChangeType(changeType)
+		changes = append(changes, &c)
+	}
+	return changes, rows.Err()
+}
+
+// GetLatestSeq returns the sequence of the user's newest change, or 0 if they have none
+func (r *PhotoChangeRepository) GetLatestSeq(ctx context.Context, userID string) (int64, error) {
+	var latest sql.NullInt64
+	if err := r.db.QueryRowContext(ctx,
+		`SELECT MAX(seq) FROM photo_changes WHERE user_id = $1`, userID,
+	).Scan(&latest); err != nil {
+		return 0, err
+	}
+
+	// Compaction may have dropped the newest entries if they were tombstones
+	floor, err := r.GetFloor(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	if floor > latest.Int64 {
+		return floor, nil
+	}
+	return latest.Int64, nil
+}
+
+// GetFloor returns the newest sequence whose tombstone was compacted away.
+// A client that last synced before the floor may have missed deletions.
+func (r *PhotoChangeRepository) GetFloor(ctx context.Context, userID string) (int64, error) {
+	var floor int64
+	err := r.db.QueryRowContext(ctx,
+		`SELECT seq FROM photo_change_floors WHERE user_id = $1`, userID,
+	).Scan(&floor)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return floor, err
+}
+
+// Compact shrinks the log in two steps. Entries superseded by a newer entry
+// for the same photo are dropped, which loses nothing because clients treat
+// every entry as the photo's latest state. Tombstones older than
+// tombstonesBefore are then dropped and each affected user's floor is raised
+// past them. Returns the number of entries removed.
+func (r *PhotoChangeRepository) Compact(ctx context.Context, tombstonesBefore time.Time) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	superseded, err := tx.ExecContext(ctx, `
+		DELETE FROM photo_changes
+		WHERE seq < (
+			SELECT MAX(newer.seq) FROM photo_changes newer
+			WHERE newer.user_id = photo_changes.user_id AND newer.photo_id = photo_changes.photo_id
+		)
+	`)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO photo_change_floors (user_id, seq)
+		SELECT user_id, MAX(seq) FROM photo_changes
+		WHERE change_type = $1 AND changed_at < $2
+		GROUP BY user_id
+		ON CONFLICT (user_id) DO UPDATE SET seq = EXCLUDED.seq
+	`, string(models.PhotoChangeDeleted), tombstonesBefore); err != nil {
+		return 0, err
+	}
+
+	tombstones, err := tx.ExecContext(ctx,
+		`DELETE FROM photo_changes WHERE change_type = $1 AND changed_at < $2`,
+		string(models.PhotoChangeDeleted), tombstonesBefore,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	supersededCount, err := superseded.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	tombstoneCount, err := tombstones.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(supersededCount + tombstoneCount), tx.Commit()
+}
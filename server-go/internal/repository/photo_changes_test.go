@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/photosync/server/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// changeLogTestDBs returns a SQLite database and, when
+// PHOTOSYNC_TEST_POSTGRES_URL is set, a PostgreSQL one. Sequences are handed
+// out at insert time only on PostgreSQL, so that is where out-of-order
+// commits can actually happen.
+func changeLogTestDBs(t *testing.T) map[string]*sql.DB {
+	dbs := make(map[string]*sql.DB)
+
+	sqliteDB, err := NewSQLiteDB(filepath.Join(t.TempDir(), "photosync.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { sqliteDB.Close() })
+	dbs["sqlite"] = sqliteDB
+
+	if url := os.Getenv("PHOTOSYNC_TEST_POSTGRES_URL"); url != "" {
+		postgresDB, err := NewPostgresDB(url)
+		require.NoError(t, err)
+		t.Cleanup(func() { postgresDB.Close() })
+		dbs["postgres"] = postgresDB
+	}
+	return dbs
+}
+
+func TestRecordPhotoChange_CommitsInSeqOrder(t *testing.T) {
+	for name, db := range changeLogTestDBs(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := NewPhotoChangeRepository(db)
+			userID := uuid.New().String()
+
+			// The first writer appends and stays open
+			first, err := db.BeginTx(ctx, nil)
+			require.NoError(t, err)
+			defer first.Rollback()
+			require.NoError(t, recordPhotoChange(ctx, first, &userID, "photo-1", models.PhotoChangeAdded))
+
+			// A second writer for the same user tries to commit first
+			committed := make(chan error, 1)
+			go func() {
+				second, err := db.BeginTx(ctx, nil)
+				if err != nil {
+					committed <- err
+					return
+				}
+				defer second.Rollback()
+				if err := recordPhotoChange(ctx, second, &userID, "photo-2", models.PhotoChangeAdded); err != nil {
+					committed <- err
+					return
+				}
+				committed <- second.Commit()
+			}()
+
+			select {
+			case err := <-committed:
+				t.Fatalf("second writer committed while the first was open: %v", err)
+			case <-time.After(200 * time.Millisecond):
+			}
+
+			// A client syncing now must not see anything it could step past photo-1 with
+			changes, err := repo.GetSince(ctx, userID, 0, 10)
+			require.NoError(t, err)
+			assert.Empty(t, changes)
+
+			require.NoError(t, first.Commit())
+			require.NoError(t, <-committed)
+
+			changes, err = repo.GetSince(ctx, userID, 0, 10)
+			require.NoError(t, err)
+			require.Len(t, changes, 2)
+			assert.Equal(t, "photo-1", changes[0].PhotoID)
+			assert.Equal(t, "photo-2", changes[1].PhotoID)
+			assert.Less(t, changes[0].Seq, changes[1].Seq)
+		})
+	}
+}
@@ -20,59 +20,32 @@ func NewPhotoRepository(db *sql.DB) *PhotoRepository {
 
 // GetByID retrieves a photo by its ID
 func (r *PhotoRepository) GetByID(ctx context.Context, id string) (*models.Photo, error) {
-	query := `
-		SELECT id, original_filename, stored_path, file_hash, file_size, date_taken, uploaded_at
-		FROM photos WHERE id = ?
-	`
-
-	var photo models.Photo
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&photo.ID,
-		&photo.OriginalFilename,
-		&photo.StoredPath,
-		&photo.FileHash,
-		&photo.FileSize,
-		&photo.DateTaken,
-		&photo.UploadedAt,
-	)
+	query := `SELECT ` + photoSelectColumns + ` FROM photos WHERE id = ?`
 
+	photo, err := scanPhoto(r.db.QueryRowContext(ctx, query, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
-
-	return &photo, nil
+	return photo, nil
 }
 
-// GetByHash retrieves a photo by its file hash
+// GetByHash retrieves a photo by its file hash across all users. Several users
+// can own the same content, so the earliest upload (the blob's creator) is returned.
 func (r *PhotoRepository) GetByHash(ctx context.Context, hash string) (*models.Photo, error) {
 	normalizedHash := strings.ToLower(hash)
-	query := `
-		SELECT id, original_filename, stored_path, file_hash, file_size, date_taken, uploaded_at
-		FROM photos WHERE file_hash = ?
-	`
-
-	var photo models.Photo
-	err := r.db.QueryRowContext(ctx, query, normalizedHash).Scan(
-		&photo.ID,
-		&photo.OriginalFilename,
-		&photo.StoredPath,
-		&photo.FileHash,
-		&photo.FileSize,
-		&photo.DateTaken,
-		&photo.UploadedAt,
-	)
+	query := `SELECT ` + photoSelectColumns + ` FROM photos WHERE file_hash = ? ORDER BY uploaded_at ASC LIMIT 1`
 
+	photo, err := scanPhoto(r.db.QueryRowContext(ctx, query, normalizedHash))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
-
-	return &photo, nil
+	return photo, nil
 }
 
 // GetExistingHashes returns which hashes from the list already exist
@@ -95,7 +68,7 @@ func (r *PhotoRepository) GetExistingHashes(ctx context.Context, hashes []string
 		args[i] = h
 	}
 
-	query := `SELECT file_hash FROM photos WHERE file_hash IN (` + strings.Join(placeholders, ",") + `)`
+	query := `SELECT file_hash FROM photos WHERE deleted_at IS NULL AND file_hash IN (` + strings.Join(placeholders, ",") + `)`
 
 	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -121,68 +94,64 @@ func (r *PhotoRepository) GetExistingHashes(ctx context.Context, hashes []string
 
 // GetAll retrieves photos with pagination
 func (r *PhotoRepository) GetAll(ctx context.Context, skip, take int) ([]*models.Photo, error) {
-	query := `
-		SELECT id, original_filename, stored_path, file_hash, file_size, date_taken, uploaded_at
-		FROM photos
-		ORDER BY date_taken DESC
-		LIMIT ? OFFSET ?
-	`
-
-	rows, err := r.db.QueryContext(ctx, query, take, skip)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var photos []*models.Photo
-	for rows.Next() {
-		var photo models.Photo
-		if err := rows.Scan(
-			&photo.ID,
-			&photo.OriginalFilename,
-			&photo.StoredPath,
-			&photo.FileHash,
-			&photo.FileSize,
-			&photo.DateTaken,
-			&photo.UploadedAt,
-		); err != nil {
-			return nil, err
-		}
-		photos = append(photos, &photo)
-	}
-
-	if photos == nil {
-		photos = []*models.Photo{}
-	}
-
-	return photos, rows.Err()
+	query := `SELECT ` + photoSelectColumns + ` FROM photos WHERE deleted_at IS NULL ORDER BY date_taken DESC LIMIT ? OFFSET ?`
+	return r.queryPhotos(ctx, query, take, skip)
 }
 
 // GetCount returns the total number of photos
 func (r *PhotoRepository) GetCount(ctx context.Context) (int, error) {
 	var count int
-	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM photos").Scan(&count)
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM photos WHERE deleted_at IS NULL").Scan(&count)
 	return count, err
 }
 
-// Add inserts a new photo
+// Add inserts a new photo with all metadata
 func (r *PhotoRepository) Add(ctx context.Context, photo *models.Photo) error {
 	query := `
-		INSERT INTO photos (id, original_filename, stored_path, file_hash, file_size, date_taken, uploaded_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO photos (
+			id, original_filename, stored_path, file_hash, file_size, date_taken, uploaded_at, user_id,
+			thumb_small, thumb_medium, thumb_large,
+			camera_make, camera_model, lens_model, focal_length, aperture, shutter_speed, iso, orientation,
+			latitude, longitude, altitude, width, height, origin_device_id,
+			media_type, duration, phash, description
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := r.db.ExecContext(ctx, query,
-		photo.ID,
-		photo.OriginalFilename,
-		photo.StoredPath,
-		photo.FileHash,
-		photo.FileSize,
-		photo.DateTaken,
-		photo.UploadedAt,
-	)
-
-	return err
+	// The photo row and its reference to the shared stored file are written together
+	return addPhotoWithBlobRef(ctx, r.db, photo, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, query,
+			photo.ID,
+			photo.OriginalFilename,
+			photo.StoredPath,
+			photo.FileHash,
+			photo.FileSize,
+			photo.DateTaken,
+			photo.UploadedAt,
+			photo.UserID,
+			photo.ThumbSmall,
+			photo.ThumbMedium,
+			photo.ThumbLarge,
+			photo.CameraMake,
+			photo.CameraModel,
+			photo.LensModel,
+			photo.FocalLength,
+			photo.Aperture,
+			photo.ShutterSpeed,
+			photo.ISO,
+			photo.Orientation,
+			photo.Latitude,
+			photo.Longitude,
+			photo.Altitude,
+			photo.Width,
+			photo.Height,
+			photo.OriginDeviceID,
+			mediaTypeOrDefault(photo.MediaType),
+			photo.Duration,
+			photo.PerceptualHash,
+			photo.Description,
+		)
+		return err
+	})
 }
 
 // Update updates an existing photo record
@@ -211,83 +180,75 @@ func (r *PhotoRepository) Update(ctx context.Context, photo *models.Photo) error
 			orientation = ?,
 			latitude = ?,
 			longitude = ?,
-			altitude = ?
+			altitude = ?,
+			media_type = ?,
+			duration = ?,
+			phash = ?,
+			description = ?
 		WHERE id = ?
 	`
 
-	_, err := r.db.ExecContext(ctx, query,
-		photo.OriginalFilename,
-		photo.StoredPath,
-		photo.FileHash,
-		photo.FileSize,
-		photo.DateTaken,
-		photo.UserID,
-		photo.OriginDeviceID,
-		photo.ThumbSmall,
-		photo.ThumbMedium,
-		photo.ThumbLarge,
-		photo.Width,
-		photo.Height,
-		photo.CameraMake,
-		photo.CameraModel,
-		photo.LensModel,
-		photo.FocalLength,
-		photo.Aperture,
-		photo.ShutterSpeed,
-		photo.ISO,
-		photo.Orientation,
-		photo.Latitude,
-		photo.Longitude,
-		photo.Altitude,
-		photo.ID,
-	)
-
-	return err
+	// A change of owner or size moves the photo's usage between users in the same transaction
+	return updatePhotoWithUsage(ctx, r.db, photo, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, query,
+			photo.OriginalFilename,
+			photo.StoredPath,
+			photo.FileHash,
+			photo.FileSize,
+			photo.DateTaken,
+			photo.UserID,
+			photo.OriginDeviceID,
+			photo.ThumbSmall,
+			photo.ThumbMedium,
+			photo.ThumbLarge,
+			photo.Width,
+			photo.Height,
+			photo.CameraMake,
+			photo.CameraModel,
+			photo.LensModel,
+			photo.FocalLength,
+			photo.Aperture,
+			photo.ShutterSpeed,
+			photo.ISO,
+			photo.Orientation,
+			photo.Latitude,
+			photo.Longitude,
+			photo.Altitude,
+			mediaTypeOrDefault(photo.MediaType),
+			photo.Duration,
+			photo.PerceptualHash,
+			photo.Description,
+			photo.ID,
+		)
+		return err
+	})
 }
 
-// Delete removes a photo by ID
+// Delete removes a photo by ID and releases its reference to the stored file
 func (r *PhotoRepository) Delete(ctx context.Context, id string) (bool, error) {
-	result, err := r.db.ExecContext(ctx, "DELETE FROM photos WHERE id = ?", id)
-	if err != nil {
-		return false, err
-	}
-
-	affected, err := result.RowsAffected()
-	if err != nil {
-		return false, err
-	}
+	deleted, _, err := deletePhotoWithBlobRef(ctx, r.db, id)
+	return deleted, err
+}
 
-	return affected > 0, nil
+// DeleteReleasingBlob removes a photo like Delete and also returns how many
+// photos still reference its stored file, counted in the same transaction
+func (r *PhotoRepository) DeleteReleasingBlob(ctx context.Context, id string) (bool, int, error) {
+	return deletePhotoWithBlobRef(ctx, r.db, id)
 }
 
 // GetByHashAndUser retrieves a photo by hash for a specific user
 func (r *PhotoRepository) GetByHashAndUser(ctx context.Context, hash, userID string) (*models.Photo, error) {
 	normalizedHash := strings.ToLower(hash)
-	query := `
-		SELECT id, original_filename, stored_path, file_hash, file_size, date_taken, uploaded_at, user_id
-		FROM photos WHERE file_hash = ? AND user_id = ?
-	`
-
-	var photo models.Photo
-	err := r.db.QueryRowContext(ctx, query, normalizedHash, userID).Scan(
-		&photo.ID,
-		&photo.OriginalFilename,
-		&photo.StoredPath,
-		&photo.FileHash,
-		&photo.FileSize,
-		&photo.DateTaken,
-		&photo.UploadedAt,
-		&photo.UserID,
-	)
+	query := `SELECT ` + photoSelectColumns + ` FROM photos WHERE file_hash = ? AND user_id = ?`
 
+	photo, err := scanPhoto(r.db.QueryRowContext(ctx, query, normalizedHash, userID))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
-
-	return &photo, nil
+	return photo, nil
 }
 
 // GetExistingHashesForUser returns which hashes from the list already exist for a user
@@ -311,7 +272,7 @@ func (r *PhotoRepository) GetExistingHashesForUser(ctx context.Context, hashes [
 	}
 	args[len(normalized)] = userID
 
-	query := `SELECT file_hash FROM photos WHERE file_hash IN (` + strings.Join(placeholders, ",") + `) AND user_id = ?`
+	query := `SELECT file_hash FROM photos WHERE file_hash IN (` + strings.Join(placeholders, ",") + `) AND user_id = ? AND deleted_at IS NULL`
 
 	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -337,15 +298,26 @@ func (r *PhotoRepository) GetExistingHashesForUser(ctx context.Context, hashes [
 
 // GetAllForUser retrieves photos for a specific user with pagination
 func (r *PhotoRepository) GetAllForUser(ctx context.Context, userID string, skip, take int) ([]*models.Photo, error) {
-	query := `
-		SELECT id, original_filename, stored_path, file_hash, file_size, date_taken, uploaded_at, user_id
-		FROM photos
-		WHERE user_id = ?
-		ORDER BY date_taken DESC
-		LIMIT ? OFFSET ?
-	`
+	query := `SELECT ` + photoSelectColumns + ` FROM photos WHERE user_id = ? AND deleted_at IS NULL ORDER BY date_taken DESC LIMIT ? OFFSET ?`
+	return r.queryPhotos(ctx, query, userID, take, skip)
+}
+
+// GetCountForUser returns the total number of photos for a user
+func (r *PhotoRepository) GetCountForUser(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM photos WHERE user_id = ? AND deleted_at IS NULL", userID).Scan(&count)
+	return count, err
+}
+
+// AddWithUser inserts a new photo associated with a user
+func (r *PhotoRepository) AddWithUser(ctx context.Context, photo *models.Photo, userID string) error {
+	photo.UserID = &userID
+	return r.Add(ctx, photo)
+}
 
-	rows, err := r.db.QueryContext(ctx, query, userID, take, skip)
+// queryPhotos runs a query selecting photoSelectColumns and scans every row
+func (r *PhotoRepository) queryPhotos(ctx context.Context, query string, args ...interface{}) ([]*models.Photo, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -353,53 +325,23 @@ func (r *PhotoRepository) GetAllForUser(ctx context.Context, userID string, skip
 
 	var photos []*models.Photo
 	for rows.Next() {
-		var photo models.Photo
-		if err := rows.Scan(
-			&photo.ID,
-			&photo.OriginalFilename,
-			&photo.StoredPath,
-			&photo.FileHash,
-			&photo.FileSize,
-			&photo.DateTaken,
-			&photo.UploadedAt,
-			&photo.UserID,
-		); err != nil {
+		photo, err := scanPhoto(rows)
+		if err != nil {
 			return nil, err
 		}
-		photos = append(photos, &photo)
+		photos = append(photos, photo)
 	}
 
 	if photos == nil {
 		photos = []*models.Photo{}
 	}
-
 	return photos, rows.Err()
 }
 
-// GetCountForUser returns the total number of photos for a user
-func (r *PhotoRepository) GetCountForUser(ctx context.Context, userID string) (int, error) {
-	var count int
-	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM photos WHERE user_id = ?", userID).Scan(&count)
-	return count, err
-}
-
-// AddWithUser inserts a new photo associated with a user
-func (r *PhotoRepository) AddWithUser(ctx context.Context, photo *models.Photo, userID string) error {
-	query := `
-		INSERT INTO photos (id, original_filename, stored_path, file_hash, file_size, date_taken, uploaded_at, user_id)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-	`
-
-	_, err := r.db.ExecContext(ctx, query,
-		photo.ID,
-		photo.OriginalFilename,
-		photo.StoredPath,
-		photo.FileHash,
-		photo.FileSize,
-		photo.DateTaken,
-		photo.UploadedAt,
-		userID,
-	)
-
-	return err
+// mediaTypeOrDefault returns the stored media type, treating an unset value as an image
+func mediaTypeOrDefault(mediaType models.MediaType) models.MediaType {
+	if mediaType == "" {
+		return models.MediaTypeImage
+	}
+	return mediaType
 }
@@ -11,17 +11,40 @@ import (
 // DeleteAll deletes all photos from the database
 // Returns the number of photos deleted
 func (r *PhotoRepository) DeleteAll(ctx context.Context) (int, error) {
-	result, err := r.db.ExecContext(ctx, "DELETE FROM photos")
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, err
 	}
+	defer tx.Rollback()
+
+	// Every owned photo gets a tombstone so devices drop their copies too
+	if err := lockAllPhotoChangeLogs(ctx, tx); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO photo_changes (user_id, photo_id, change_type, changed_at)
+		SELECT user_id, id, $1, $2 FROM photos WHERE user_id IS NOT NULL
+	`, string(models.PhotoChangeDeleted), time.Now().UTC()); err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM photos")
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM photo_blobs"); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET used_bytes = 0, used_photos = 0"); err != nil {
+		return 0, err
+	}
 
 	affected, err := result.RowsAffected()
 	if err != nil {
 		return 0, err
 	}
 
-	return int(affected), nil
+	return int(affected), tx.Commit()
 }
 
 // VerifyExistence checks which photo IDs exist in the database
@@ -45,7 +68,7 @@ func (r *PhotoRepository) VerifyExistence(ctx context.Context, ids []string) (ma
 		args[i] = id
 	}
 
-	query := `SELECT id FROM photos WHERE id IN (` + strings.Join(placeholders, ",") + `)`
+	query := `SELECT id FROM photos WHERE deleted_at IS NULL AND id IN (` + strings.Join(placeholders, ",") + `)`
 
 	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -66,69 +89,240 @@ func (r *PhotoRepository) VerifyExistence(ctx context.Context, ids []string) (ma
 }
 
 // GetPhotosWithoutThumbnails returns photos that don't have thumbnails generated
-// Note: SQLite version may not have thumbnail columns, returns empty slice
 func (r *PhotoRepository) GetPhotosWithoutThumbnails(ctx context.Context, limit int) ([]*models.Photo, error) {
-	// SQLite schema may not have thumbnail columns, so return empty
-	return []*models.Photo{}, nil
+	query := `SELECT ` + photoSelectColumns + ` FROM photos WHERE thumb_small IS NULL AND deleted_at IS NULL LIMIT ?`
+	return r.queryPhotos(ctx, query, limit)
 }
 
 // UpdateThumbnails updates the thumbnail paths for a photo
-// Note: SQLite version may not have thumbnail columns, this is a no-op
 func (r *PhotoRepository) UpdateThumbnails(ctx context.Context, photoID, smallPath, mediumPath, largePath string) error {
-	// SQLite schema may not have thumbnail columns, so no-op
-	return nil
+	query := `UPDATE photos SET thumb_small = ?, thumb_medium = ?, thumb_large = ? WHERE id = ?`
+	_, err := updatePhotoWithChange(ctx, r.db, photoID, models.PhotoChangeUpdated, query, smallPath, mediumPath, largePath, photoID)
+	return err
 }
 
-// GetOrphanedPhotos returns photos that don't have an owner
-// Note: SQLite version returns empty slice
+// GetOrphanedPhotos returns photos that don't have an owner (user_id IS NULL)
 func (r *PhotoRepository) GetOrphanedPhotos(ctx context.Context, limit int) ([]*models.Photo, error) {
-	return []*models.Photo{}, nil
+	query := `SELECT ` + photoSelectColumns + ` FROM photos WHERE user_id IS NULL LIMIT ?`
+	return r.queryPhotos(ctx, query, limit)
 }
 
-// Sync-related methods for SQLite (stubs for development/testing)
+// GetPhotosWithLocation returns photos that have GPS coordinates (for map view)
+func (r *PhotoRepository) GetPhotosWithLocation(ctx context.Context, skip, take int) ([]*models.Photo, error) {
+	query := `SELECT ` + photoSelectColumns + ` FROM photos WHERE latitude IS NOT NULL AND longitude IS NOT NULL AND deleted_at IS NULL ORDER BY date_taken DESC LIMIT ? OFFSET ?`
+	return r.queryPhotos(ctx, query, take, skip)
+}
+
+// GetPhotosWithLocationForUser returns photos with GPS for a specific user
+func (r *PhotoRepository) GetPhotosWithLocationForUser(ctx context.Context, userID string, skip, take int) ([]*models.Photo, error) {
+	query := `SELECT ` + photoSelectColumns + ` FROM photos WHERE user_id = ? AND latitude IS NOT NULL AND longitude IS NOT NULL AND deleted_at IS NULL ORDER BY date_taken DESC LIMIT ? OFFSET ?`
+	return r.queryPhotos(ctx, query, userID, take, skip)
+}
 
-// GetAllForUserWithCursor returns photos with cursor-based pagination
+// GetLocationCount returns count of photos with GPS coordinates
+func (r *PhotoRepository) GetLocationCount(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM photos WHERE latitude IS NOT NULL AND longitude IS NOT NULL AND deleted_at IS NULL").Scan(&count)
+	return count, err
+}
+
+// GetLocationCountForUser returns count of photos with GPS for a specific user
+func (r *PhotoRepository) GetLocationCountForUser(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM photos WHERE user_id = ? AND latitude IS NOT NULL AND longitude IS NOT NULL AND deleted_at IS NULL", userID).Scan(&count)
+	return count, err
+}
+
+// Sync-related methods
+
+// GetAllForUserWithCursor returns photos for a user using cursor-based pagination
+// Returns photos, next cursor, and error
 func (r *PhotoRepository) GetAllForUserWithCursor(ctx context.Context, userID string, cursor string, limit int, sinceTimestamp *time.Time) ([]*models.Photo, string, error) {
-	// SQLite stub - returns all photos without cursor support
-	photos, err := r.GetAllForUser(ctx, userID, 0, limit)
+	// Cursor is the last photo ID from the previous page; uploaded_at + id gives a stable order
+	conditions := []string{"user_id = ?", "deleted_at IS NULL"}
+	args := []interface{}{userID}
+
+	if sinceTimestamp != nil {
+		conditions = append(conditions, "uploaded_at >= ?")
+		args = append(args, *sinceTimestamp)
+	}
+	if cursor != "" {
+		conditions = append(conditions, "(uploaded_at, id) > (SELECT uploaded_at, id FROM photos WHERE id = ?)")
+		args = append(args, cursor)
+	}
+	args = append(args, limit+1)
+
+	query := `SELECT ` + photoSelectColumns + ` FROM photos
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY uploaded_at ASC, id ASC
+		LIMIT ?`
+
+	photos, err := r.queryPhotos(ctx, query, args...)
 	if err != nil {
 		return nil, "", err
 	}
-	return photos, "", nil
+
+	// Check if there's a next page
+	var nextCursor string
+	if len(photos) > limit {
+		nextCursor = photos[limit-1].ID
+		photos = photos[:limit]
+	}
+
+	return photos, nextCursor, nil
 }
 
 // GetCountByOriginDevice returns count of photos from a specific device
 func (r *PhotoRepository) GetCountByOriginDevice(ctx context.Context, userID, deviceID string) (int, error) {
-	// SQLite stub - returns 0
-	return 0, nil
+	var count int
+	err := r.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM photos WHERE user_id = ? AND origin_device_id = ? AND deleted_at IS NULL",
+		userID, deviceID,
+	).Scan(&count)
+	return count, err
 }
 
-// GetLegacyPhotosForUser returns photos without an origin device
+// GetLegacyPhotosForUser returns photos without an origin device (legacy photos)
 func (r *PhotoRepository) GetLegacyPhotosForUser(ctx context.Context, userID string, limit int) ([]*models.Photo, error) {
-	// SQLite stub - returns empty
-	return []*models.Photo{}, nil
+	query := `SELECT ` + photoSelectColumns + ` FROM photos
+		WHERE user_id = ? AND origin_device_id IS NULL AND deleted_at IS NULL
+		ORDER BY date_taken DESC
+		LIMIT ?`
+	return r.queryPhotos(ctx, query, userID, limit)
 }
 
 // GetLegacyPhotoCount returns count of photos without an origin device
 func (r *PhotoRepository) GetLegacyPhotoCount(ctx context.Context, userID string) (int, error) {
-	// SQLite stub - returns 0
-	return 0, nil
+	var count int
+	err := r.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM photos WHERE user_id = ? AND origin_device_id IS NULL AND deleted_at IS NULL",
+		userID,
+	).Scan(&count)
+	return count, err
 }
 
-// ClaimLegacyPhotos sets the origin device for specific photos
+// ClaimLegacyPhotos sets origin_device_id for specific photos
 func (r *PhotoRepository) ClaimLegacyPhotos(ctx context.Context, photoIDs []string, deviceID string) (int, error) {
-	// SQLite stub - no-op
-	return 0, nil
+	if len(photoIDs) == 0 {
+		return 0, nil
+	}
+
+	placeholders := make([]string, len(photoIDs))
+	args := make([]interface{}, len(photoIDs)+1)
+	args[0] = deviceID
+	for i, id := range photoIDs {
+		placeholders[i] = "?"
+		args[i+1] = id
+	}
+
+	where := `WHERE id IN (` + strings.Join(placeholders, ",") + `) AND origin_device_id IS NULL`
+	query := `UPDATE photos SET origin_device_id = ? ` + where
+
+	return claimPhotosWithChanges(ctx, r.db, `SELECT id, user_id FROM photos `+where, args[1:], query, args)
 }
 
-// ClaimAllLegacyPhotos sets the origin device for all legacy photos for a user
+// ClaimAllLegacyPhotos sets origin_device_id for all of a user's legacy photos
 func (r *PhotoRepository) ClaimAllLegacyPhotos(ctx context.Context, userID, deviceID string) (int, error) {
-	// SQLite stub - no-op
-	return 0, nil
+	return claimPhotosWithChanges(ctx, r.db,
+		`SELECT id, user_id FROM photos WHERE user_id = ? AND origin_device_id IS NULL`,
+		[]interface{}{userID},
+		`UPDATE photos SET origin_device_id = ?
+		WHERE user_id = ? AND origin_device_id IS NULL`,
+		[]interface{}{deviceID, userID},
+	)
 }
 
 // SetOriginDevice sets the origin device for a photo
 func (r *PhotoRepository) SetOriginDevice(ctx context.Context, photoID, deviceID string) error {
-	// SQLite stub - no-op
-	return nil
+	_, err := updatePhotoWithChange(ctx, r.db, photoID, models.PhotoChangeUpdated,
+		"UPDATE photos SET origin_device_id = ? WHERE id = ?",
+		deviceID, photoID,
+	)
+	return err
+}
+
+// Perceptual hashes
+
+// GetPhotosWithoutPerceptualHash returns live images that have thumbnails but no perceptual hash yet
+func (r *PhotoRepository) GetPhotosWithoutPerceptualHash(ctx context.Context, limit int) ([]*models.Photo, error) {
+	query := `SELECT ` + photoSelectColumns + ` FROM photos
+		WHERE phash IS NULL AND thumb_small IS NOT NULL AND media_type = 'image' AND deleted_at IS NULL
+		LIMIT ?`
+	return r.queryPhotos(ctx, query, limit)
+}
+
+// UpdatePerceptualHash stores a photo's perceptual hash. An empty hash marks
+// the photo as attempted so it is not retried on every run.
+func (r *PhotoRepository) UpdatePerceptualHash(ctx context.Context, photoID, hash string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE photos SET phash = ? WHERE id = ?", hash, photoID)
+	return err
+}
+
+// GetPerceptualHashesForUser returns the perceptual hashes of a user's live photos
+func (r *PhotoRepository) GetPerceptualHashesForUser(ctx context.Context, userID string) ([]models.PhotoPerceptualHash, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT id, phash FROM photos WHERE user_id = ? AND phash IS NOT NULL AND phash <> '' AND deleted_at IS NULL ORDER BY date_taken ASC, id ASC",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []models.PhotoPerceptualHash
+	for rows.Next() {
+		var h models.PhotoPerceptualHash
+		if err := rows.Scan(&h.PhotoID, &h.Hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, h)
+	}
+	return hashes, rows.Err()
+}
+
+// Trash
+
+// MoveToTrash marks a photo as deleted without removing its row or files.
+// Returns false if the photo does not exist or is already in the trash.
+func (r *PhotoRepository) MoveToTrash(ctx context.Context, id string, deletedAt time.Time) (bool, error) {
+	// Trashed photos leave the synced library, so devices see a tombstone
+	return updatePhotoWithChange(ctx, r.db, id, models.PhotoChangeDeleted,
+		"UPDATE photos SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL", deletedAt, id)
+}
+
+// Restore takes a photo back out of the trash.
+// Returns false if the photo does not exist or is not in the trash.
+func (r *PhotoRepository) Restore(ctx context.Context, id string) (bool, error) {
+	return updatePhotoWithChange(ctx, r.db, id, models.PhotoChangeAdded,
+		"UPDATE photos SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL", id)
+}
+
+// GetTrashForUser returns a user's trashed photos, most recently deleted first
+func (r *PhotoRepository) GetTrashForUser(ctx context.Context, userID string, skip, take int) ([]*models.Photo, error) {
+	query := `SELECT ` + photoSelectColumns + ` FROM photos WHERE user_id = ? AND deleted_at IS NOT NULL ORDER BY deleted_at DESC LIMIT ? OFFSET ?`
+	return r.queryPhotos(ctx, query, userID, take, skip)
+}
+
+// GetTrashCountForUser returns the number of photos in a user's trash
+func (r *PhotoRepository) GetTrashCountForUser(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM photos WHERE user_id = ? AND deleted_at IS NOT NULL", userID).Scan(&count)
+	return count, err
+}
+
+// GetTrashedBefore returns photos (for any user) that were trashed before the cutoff
+func (r *PhotoRepository) GetTrashedBefore(ctx context.Context, cutoff time.Time, limit int) ([]*models.Photo, error) {
+	query := `SELECT ` + photoSelectColumns + ` FROM photos WHERE deleted_at IS NOT NULL AND deleted_at < ? ORDER BY deleted_at ASC LIMIT ?`
+	return r.queryPhotos(ctx, query, cutoff, limit)
+}
+
+// GetBlobByHash returns the stored file for a content hash, shared by every
+// user who uploaded it
+func (r *PhotoRepository) GetBlobByHash(ctx context.Context, hash string) (*models.PhotoBlob, error) {
+	return getBlobByHash(ctx, r.db, strings.ToLower(hash))
+}
+
+// GetBlobRefCount returns how many photo rows still reference a stored file
+func (r *PhotoRepository) GetBlobRefCount(ctx context.Context, storedPath string) (int, error) {
+	return getBlobRefCount(ctx, r.db, storedPath)
 }
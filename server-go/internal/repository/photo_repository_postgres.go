@@ -24,7 +24,8 @@ func NewPhotoRepositoryPostgres(db *sql.DB) *PhotoRepositoryPostgres {
 const photoSelectColumns = `id, original_filename, stored_path, file_hash, file_size, date_taken, uploaded_at, user_id,
 	thumb_small, thumb_medium, thumb_large,
 	camera_make, camera_model, lens_model, focal_length, aperture, shutter_speed, iso, orientation,
-	latitude, longitude, altitude, width, height, origin_device_id`
+	latitude, longitude, altitude, width, height, origin_device_id,
+	media_type, duration, phash, description, deleted_at`
 
 // scanPhoto scans a row into a Photo struct
 func scanPhoto(scanner interface{ Scan(...interface{}) error }) (*models.Photo, error) {
@@ -55,6 +56,11 @@ func scanPhoto(scanner interface{ Scan(...interface{}) error }) (*models.Photo,
 		&photo.Width,
 		&photo.Height,
 		&photo.OriginDeviceID,
+		&photo.MediaType,
+		&photo.Duration,
+		&photo.PerceptualHash,
+		&photo.Description,
+		&photo.DeletedAt,
 	)
 	return &photo, err
 }
@@ -73,10 +79,11 @@ func (r *PhotoRepositoryPostgres) GetByID(ctx context.Context, id string) (*mode
 	return photo, nil
 }
 
-// GetByHash retrieves a photo by its file hash
+// GetByHash retrieves a photo by its file hash across all users. Several users
+// can own the same content, so the earliest upload (the blob's creator) is returned.
 func (r *PhotoRepositoryPostgres) GetByHash(ctx context.Context, hash string) (*models.Photo, error) {
 	normalizedHash := strings.ToLower(hash)
-	query := `SELECT ` + photoSelectColumns + ` FROM photos WHERE file_hash = $1`
+	query := `SELECT ` + photoSelectColumns + ` FROM photos WHERE file_hash = $1 ORDER BY uploaded_at ASC LIMIT 1`
 
 	photo, err := scanPhoto(r.db.QueryRowContext(ctx, query, normalizedHash))
 	if err == sql.ErrNoRows {
@@ -121,7 +128,7 @@ func (r *PhotoRepositoryPostgres) GetExistingHashes(ctx context.Context, hashes
 		args[i] = h
 	}
 
-	query := `SELECT file_hash FROM photos WHERE file_hash IN (` + strings.Join(placeholders, ",") + `)`
+	query := `SELECT file_hash FROM photos WHERE deleted_at IS NULL AND file_hash IN (` + strings.Join(placeholders, ",") + `)`
 
 	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -163,7 +170,7 @@ func (r *PhotoRepositoryPostgres) GetExistingHashesForUser(ctx context.Context,
 		args[i+1] = h
 	}
 
-	query := `SELECT file_hash FROM photos WHERE user_id = $1 AND file_hash IN (` + strings.Join(placeholders, ",") + `)`
+	query := `SELECT file_hash FROM photos WHERE user_id = $1 AND deleted_at IS NULL AND file_hash IN (` + strings.Join(placeholders, ",") + `)`
 
 	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -188,7 +195,7 @@ func (r *PhotoRepositoryPostgres) GetExistingHashesForUser(ctx context.Context,
 
 // GetAll retrieves photos with pagination
 func (r *PhotoRepositoryPostgres) GetAll(ctx context.Context, skip, take int) ([]*models.Photo, error) {
-	query := `SELECT ` + photoSelectColumns + ` FROM photos ORDER BY date_taken DESC LIMIT $1 OFFSET $2`
+	query := `SELECT ` + photoSelectColumns + ` FROM photos WHERE deleted_at IS NULL ORDER BY date_taken DESC LIMIT $1 OFFSET $2`
 
 	rows, err := r.db.QueryContext(ctx, query, take, skip)
 	if err != nil {
@@ -213,7 +220,7 @@ func (r *PhotoRepositoryPostgres) GetAll(ctx context.Context, skip, take int) ([
 
 // GetAllForUser retrieves photos for a specific user with pagination
 func (r *PhotoRepositoryPostgres) GetAllForUser(ctx context.Context, userID string, skip, take int) ([]*models.Photo, error) {
-	query := `SELECT ` + photoSelectColumns + ` FROM photos WHERE user_id = $1 ORDER BY date_taken DESC LIMIT $2 OFFSET $3`
+	query := `SELECT ` + photoSelectColumns + ` FROM photos WHERE user_id = $1 AND deleted_at IS NULL ORDER BY date_taken DESC LIMIT $2 OFFSET $3`
 
 	rows, err := r.db.QueryContext(ctx, query, userID, take, skip)
 	if err != nil {
@@ -239,14 +246,14 @@ func (r *PhotoRepositoryPostgres) GetAllForUser(ctx context.Context, userID stri
 // GetCount returns the total number of photos
 func (r *PhotoRepositoryPostgres) GetCount(ctx context.Context) (int, error) {
 	var count int
-	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM photos").Scan(&count)
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM photos WHERE deleted_at IS NULL").Scan(&count)
 	return count, err
 }
 
 // GetCountForUser returns the total number of photos for a specific user
 func (r *PhotoRepositoryPostgres) GetCountForUser(ctx context.Context, userID string) (int, error) {
 	var count int
-	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM photos WHERE user_id = $1", userID).Scan(&count)
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM photos WHERE user_id = $1 AND deleted_at IS NULL", userID).Scan(&count)
 	return count, err
 }
 
@@ -257,39 +264,46 @@ func (r *PhotoRepositoryPostgres) Add(ctx context.Context, photo *models.Photo)
 			id, original_filename, stored_path, file_hash, file_size, date_taken, uploaded_at, user_id,
 			thumb_small, thumb_medium, thumb_large,
 			camera_make, camera_model, lens_model, focal_length, aperture, shutter_speed, iso, orientation,
-			latitude, longitude, altitude, width, height, origin_device_id
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25)
+			latitude, longitude, altitude, width, height, origin_device_id,
+			media_type, duration, phash, description
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29)
 	`
 
-	_, err := r.db.ExecContext(ctx, query,
-		photo.ID,
-		photo.OriginalFilename,
-		photo.StoredPath,
-		photo.FileHash,
-		photo.FileSize,
-		photo.DateTaken,
-		photo.UploadedAt,
-		photo.UserID,
-		photo.ThumbSmall,
-		photo.ThumbMedium,
-		photo.ThumbLarge,
-		photo.CameraMake,
-		photo.CameraModel,
-		photo.LensModel,
-		photo.FocalLength,
-		photo.Aperture,
-		photo.ShutterSpeed,
-		photo.ISO,
-		photo.Orientation,
-		photo.Latitude,
-		photo.Longitude,
-		photo.Altitude,
-		photo.Width,
-		photo.Height,
-		photo.OriginDeviceID,
-	)
-
-	return err
+	// The photo row and its reference to the shared stored file are written together
+	return addPhotoWithBlobRef(ctx, r.db, photo, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, query,
+			photo.ID,
+			photo.OriginalFilename,
+			photo.StoredPath,
+			photo.FileHash,
+			photo.FileSize,
+			photo.DateTaken,
+			photo.UploadedAt,
+			photo.UserID,
+			photo.ThumbSmall,
+			photo.ThumbMedium,
+			photo.ThumbLarge,
+			photo.CameraMake,
+			photo.CameraModel,
+			photo.LensModel,
+			photo.FocalLength,
+			photo.Aperture,
+			photo.ShutterSpeed,
+			photo.ISO,
+			photo.Orientation,
+			photo.Latitude,
+			photo.Longitude,
+			photo.Altitude,
+			photo.Width,
+			photo.Height,
+			photo.OriginDeviceID,
+			mediaTypeOrDefault(photo.MediaType),
+			photo.Duration,
+			photo.PerceptualHash,
+			photo.Description,
+		)
+		return err
+	})
 }
 
 // AddWithUser inserts a new photo with user association
@@ -324,58 +338,65 @@ func (r *PhotoRepositoryPostgres) Update(ctx context.Context, photo *models.Phot
 			orientation = $20,
 			latitude = $21,
 			longitude = $22,
-			altitude = $23
-		WHERE id = $24
+			altitude = $23,
+			media_type = $24,
+			duration = $25,
+			phash = $26,
+			description = $27
+		WHERE id = $28
 	`
 
-	_, err := r.db.ExecContext(ctx, query,
-		photo.OriginalFilename,
-		photo.StoredPath,
-		photo.FileHash,
-		photo.FileSize,
-		photo.DateTaken,
-		photo.UserID,
-		photo.OriginDeviceID,
-		photo.ThumbSmall,
-		photo.ThumbMedium,
-		photo.ThumbLarge,
-		photo.Width,
-		photo.Height,
-		photo.CameraMake,
-		photo.CameraModel,
-		photo.LensModel,
-		photo.FocalLength,
-		photo.Aperture,
-		photo.ShutterSpeed,
-		photo.ISO,
-		photo.Orientation,
-		photo.Latitude,
-		photo.Longitude,
-		photo.Altitude,
-		photo.ID,
-	)
-
-	return err
+	// A change of owner or size moves the photo's usage between users in the same transaction
+	return updatePhotoWithUsage(ctx, r.db, photo, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, query,
+			photo.OriginalFilename,
+			photo.StoredPath,
+			photo.FileHash,
+			photo.FileSize,
+			photo.DateTaken,
+			photo.UserID,
+			photo.OriginDeviceID,
+			photo.ThumbSmall,
+			photo.ThumbMedium,
+			photo.ThumbLarge,
+			photo.Width,
+			photo.Height,
+			photo.CameraMake,
+			photo.CameraModel,
+			photo.LensModel,
+			photo.FocalLength,
+			photo.Aperture,
+			photo.ShutterSpeed,
+			photo.ISO,
+			photo.Orientation,
+			photo.Latitude,
+			photo.Longitude,
+			photo.Altitude,
+			mediaTypeOrDefault(photo.MediaType),
+			photo.Duration,
+			photo.PerceptualHash,
+			photo.Description,
+			photo.ID,
+		)
+		return err
+	})
 }
 
-// Delete removes a photo by ID
+// Delete removes a photo by ID and releases its reference to the stored file
 func (r *PhotoRepositoryPostgres) Delete(ctx context.Context, id string) (bool, error) {
-	result, err := r.db.ExecContext(ctx, "DELETE FROM photos WHERE id = $1", id)
-	if err != nil {
-		return false, err
-	}
-
-	affected, err := result.RowsAffected()
-	if err != nil {
-		return false, err
-	}
+	deleted, _, err := deletePhotoWithBlobRef(ctx, r.db, id)
+	return deleted, err
+}
 
-	return affected > 0, nil
+// DeleteReleasingBlob removes a photo like Delete and also returns how many
+// photos still reference its stored file, counted in the same transaction
+func (r *PhotoRepositoryPostgres) DeleteReleasingBlob(ctx context.Context, id string) (bool, int, error) {
+	return deletePhotoWithBlobRef(ctx, r.db, id)
 }
 
 // GetPhotosWithLocation returns photos that have GPS coordinates (for map view)
 func (r *PhotoRepositoryPostgres) GetPhotosWithLocation(ctx context.Context, skip, take int) ([]*models.Photo, error) {
-	query := `SELECT ` + photoSelectColumns + ` FROM photos WHERE latitude IS NOT NULL AND longitude IS NOT NULL ORDER BY date_taken DESC LIMIT $1 OFFSET $2`
+	query := `SELECT ` + photoSelectColumns + ` FROM photos WHERE latitude IS NOT NULL AND longitude IS NOT NULL AND deleted_at IS NULL ORDER BY date_taken DESC LIMIT $1 OFFSET $2`
 
 	rows, err := r.db.QueryContext(ctx, query, take, skip)
 	if err != nil {
@@ -400,7 +421,7 @@ func (r *PhotoRepositoryPostgres) GetPhotosWithLocation(ctx context.Context, ski
 
 // GetPhotosWithLocationForUser returns photos with GPS for a specific user
 func (r *PhotoRepositoryPostgres) GetPhotosWithLocationForUser(ctx context.Context, userID string, skip, take int) ([]*models.Photo, error) {
-	query := `SELECT ` + photoSelectColumns + ` FROM photos WHERE user_id = $1 AND latitude IS NOT NULL AND longitude IS NOT NULL ORDER BY date_taken DESC LIMIT $2 OFFSET $3`
+	query := `SELECT ` + photoSelectColumns + ` FROM photos WHERE user_id = $1 AND latitude IS NOT NULL AND longitude IS NOT NULL AND deleted_at IS NULL ORDER BY date_taken DESC LIMIT $2 OFFSET $3`
 
 	rows, err := r.db.QueryContext(ctx, query, userID, take, skip)
 	if err != nil {
@@ -426,21 +447,21 @@ func (r *PhotoRepositoryPostgres) GetPhotosWithLocationForUser(ctx context.Conte
 // GetLocationCount returns count of photos with GPS coordinates
 func (r *PhotoRepositoryPostgres) GetLocationCount(ctx context.Context) (int, error) {
 	var count int
-	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM photos WHERE latitude IS NOT NULL AND longitude IS NOT NULL").Scan(&count)
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM photos WHERE latitude IS NOT NULL AND longitude IS NOT NULL AND deleted_at IS NULL").Scan(&count)
 	return count, err
 }
 
 // GetLocationCountForUser returns count of photos with GPS for a specific user
 func (r *PhotoRepositoryPostgres) GetLocationCountForUser(ctx context.Context, userID string) (int, error) {
 	var count int
-	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM photos WHERE user_id = $1 AND latitude IS NOT NULL AND longitude IS NOT NULL", userID).Scan(&count)
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM photos WHERE user_id = $1 AND latitude IS NOT NULL AND longitude IS NOT NULL AND deleted_at IS NULL", userID).Scan(&count)
 	return count, err
 }
 
 // GetPhotosWithoutThumbnails returns photos that don't have thumbnails generated
 func (r *PhotoRepositoryPostgres) GetPhotosWithoutThumbnails(ctx context.Context, limit int) ([]*models.Photo, error) {
 	query := `SELECT ` + photoSelectColumns + ` FROM photos
-		WHERE thumb_small IS NULL
+		WHERE thumb_small IS NULL AND deleted_at IS NULL
 		LIMIT $1`
 
 	rows, err := r.db.QueryContext(ctx, query, limit)
@@ -467,7 +488,7 @@ func (r *PhotoRepositoryPostgres) GetPhotosWithoutThumbnails(ctx context.Context
 // UpdateThumbnails updates the thumbnail paths for a photo
 func (r *PhotoRepositoryPostgres) UpdateThumbnails(ctx context.Context, photoID, smallPath, mediumPath, largePath string) error {
 	query := `UPDATE photos SET thumb_small = $1, thumb_medium = $2, thumb_large = $3 WHERE id = $4`
-	_, err := r.db.ExecContext(ctx, query, smallPath, mediumPath, largePath, photoID)
+	_, err := updatePhotoWithChange(ctx, r.db, photoID, models.PhotoChangeUpdated, query, smallPath, mediumPath, largePath, photoID)
 	return err
 }
 
@@ -511,7 +532,7 @@ func (r *PhotoRepositoryPostgres) GetAllForUserWithCursor(ctx context.Context, u
 		// We use uploaded_at + id for stable ordering
 		if sinceTimestamp != nil {
 			query = `SELECT ` + photoSelectColumns + ` FROM photos
-				WHERE user_id = $1 AND uploaded_at >= $2 AND (uploaded_at, id) > (
+				WHERE user_id = $1 AND deleted_at IS NULL AND uploaded_at >= $2 AND (uploaded_at, id) > (
 					SELECT uploaded_at, id FROM photos WHERE id = $3
 				)
 				ORDER BY uploaded_at ASC, id ASC
@@ -519,7 +540,7 @@ func (r *PhotoRepositoryPostgres) GetAllForUserWithCursor(ctx context.Context, u
 			args = []interface{}{userID, *sinceTimestamp, cursor, limit + 1}
 		} else {
 			query = `SELECT ` + photoSelectColumns + ` FROM photos
-				WHERE user_id = $1 AND (uploaded_at, id) > (
+				WHERE user_id = $1 AND deleted_at IS NULL AND (uploaded_at, id) > (
 					SELECT uploaded_at, id FROM photos WHERE id = $2
 				)
 				ORDER BY uploaded_at ASC, id ASC
@@ -530,13 +551,13 @@ func (r *PhotoRepositoryPostgres) GetAllForUserWithCursor(ctx context.Context, u
 		// First page
 		if sinceTimestamp != nil {
 			query = `SELECT ` + photoSelectColumns + ` FROM photos
-				WHERE user_id = $1 AND uploaded_at >= $2
+				WHERE user_id = $1 AND deleted_at IS NULL AND uploaded_at >= $2
 				ORDER BY uploaded_at ASC, id ASC
 				LIMIT $3`
 			args = []interface{}{userID, *sinceTimestamp, limit + 1}
 		} else {
 			query = `SELECT ` + photoSelectColumns + ` FROM photos
-				WHERE user_id = $1
+				WHERE user_id = $1 AND deleted_at IS NULL
 				ORDER BY uploaded_at ASC, id ASC
 				LIMIT $2`
 			args = []interface{}{userID, limit + 1}
@@ -580,7 +601,7 @@ func (r *PhotoRepositoryPostgres) GetAllForUserWithCursor(ctx context.Context, u
 func (r *PhotoRepositoryPostgres) GetCountByOriginDevice(ctx context.Context, userID, deviceID string) (int, error) {
 	var count int
 	err := r.db.QueryRowContext(ctx,
-		"SELECT COUNT(*) FROM photos WHERE user_id = $1 AND origin_device_id = $2",
+		"SELECT COUNT(*) FROM photos WHERE user_id = $1 AND origin_device_id = $2 AND deleted_at IS NULL",
 		userID, deviceID,
 	).Scan(&count)
 	return count, err
@@ -589,7 +610,7 @@ func (r *PhotoRepositoryPostgres) GetCountByOriginDevice(ctx context.Context, us
 // GetLegacyPhotosForUser returns photos without an origin device (legacy photos)
 func (r *PhotoRepositoryPostgres) GetLegacyPhotosForUser(ctx context.Context, userID string, limit int) ([]*models.Photo, error) {
 	query := `SELECT ` + photoSelectColumns + ` FROM photos
-		WHERE user_id = $1 AND origin_device_id IS NULL
+		WHERE user_id = $1 AND origin_device_id IS NULL AND deleted_at IS NULL
 		ORDER BY date_taken DESC
 		LIMIT $2`
 
@@ -618,7 +639,7 @@ func (r *PhotoRepositoryPostgres) GetLegacyPhotosForUser(ctx context.Context, us
 func (r *PhotoRepositoryPostgres) GetLegacyPhotoCount(ctx context.Context, userID string) (int, error) {
 	var count int
 	err := r.db.QueryRowContext(ctx,
-		"SELECT COUNT(*) FROM photos WHERE user_id = $1 AND origin_device_id IS NULL",
+		"SELECT COUNT(*) FROM photos WHERE user_id = $1 AND origin_device_id IS NULL AND deleted_at IS NULL",
 		userID,
 	).Scan(&count)
 	return count, err
@@ -630,46 +651,41 @@ func (r *PhotoRepositoryPostgres) ClaimLegacyPhotos(ctx context.Context, photoID
 		return 0, nil
 	}
 
-	// Build placeholder list
-	placeholders := make([]string, len(photoIDs))
+	// Build placeholder lists; the SELECT numbers its IDs from $1, the UPDATE from $2
+	selectPlaceholders := make([]string, len(photoIDs))
+	updatePlaceholders := make([]string, len(photoIDs))
 	args := make([]interface{}, len(photoIDs)+1)
 	args[0] = deviceID
 	for i, id := range photoIDs {
-		placeholders[i] = fmt.Sprintf("$%d", i+2)
+		selectPlaceholders[i] = fmt.Sprintf("$%d", i+1)
+		updatePlaceholders[i] = fmt.Sprintf("$%d", i+2)
 		args[i+1] = id
 	}
 
+	selectQuery := fmt.Sprintf(`SELECT id, user_id FROM photos
+		WHERE id IN (%s) AND origin_device_id IS NULL`,
+		strings.Join(selectPlaceholders, ", "))
 	query := fmt.Sprintf(`UPDATE photos SET origin_device_id = $1
 		WHERE id IN (%s) AND origin_device_id IS NULL`,
-		strings.Join(placeholders, ", "))
-
-	result, err := r.db.ExecContext(ctx, query, args...)
-	if err != nil {
-		return 0, err
-	}
+		strings.Join(updatePlaceholders, ", "))
 
-	affected, err := result.RowsAffected()
-	return int(affected), err
+	return claimPhotosWithChanges(ctx, r.db, selectQuery, args[1:], query, args)
 }
 
 // ClaimAllLegacyPhotos sets origin_device_id for all user's legacy photos
 func (r *PhotoRepositoryPostgres) ClaimAllLegacyPhotos(ctx context.Context, userID, deviceID string) (int, error) {
-	result, err := r.db.ExecContext(ctx,
+	return claimPhotosWithChanges(ctx, r.db,
+		`SELECT id, user_id FROM photos WHERE user_id = $1 AND origin_device_id IS NULL`,
+		[]interface{}{userID},
 		`UPDATE photos SET origin_device_id = $1
 		WHERE user_id = $2 AND origin_device_id IS NULL`,
-		deviceID, userID,
+		[]interface{}{deviceID, userID},
 	)
-	if err != nil {
-		return 0, err
-	}
-
-	affected, err := result.RowsAffected()
-	return int(affected), err
 }
 
 // SetOriginDevice sets the origin device for a photo
 func (r *PhotoRepositoryPostgres) SetOriginDevice(ctx context.Context, photoID, deviceID string) error {
-	_, err := r.db.ExecContext(ctx,
+	_, err := updatePhotoWithChange(ctx, r.db, photoID, models.PhotoChangeUpdated,
 		"UPDATE photos SET origin_device_id = $1 WHERE id = $2",
 		deviceID, photoID,
 	)
@@ -4,22 +4,48 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
+
+	"github.com/photosync/server/internal/models"
 )
 
 // DeleteAll deletes all photos from the database (PostgreSQL version)
 // Returns the number of photos deleted
 func (r *PhotoRepositoryPostgres) DeleteAll(ctx context.Context) (int, error) {
-	result, err := r.db.ExecContext(ctx, "DELETE FROM photos")
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Every owned photo gets a tombstone so devices drop their copies too
+	if err := lockAllPhotoChangeLogs(ctx, tx); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO photo_changes (user_id, photo_id, change_type, changed_at)
+		SELECT user_id, id, $1, $2 FROM photos WHERE user_id IS NOT NULL
+	`, string(models.PhotoChangeDeleted), time.Now().UTC()); err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM photos")
 	if err != nil {
 		return 0, err
 	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM photo_blobs"); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET used_bytes = 0, used_photos = 0"); err != nil {
+		return 0, err
+	}
 
 	affected, err := result.RowsAffected()
 	if err != nil {
 		return 0, err
 	}
 
-	return int(affected), nil
+	return int(affected), tx.Commit()
 }
 
 // VerifyExistence checks which photo IDs exist in the database (PostgreSQL version)
@@ -43,7 +69,7 @@ func (r *PhotoRepositoryPostgres) VerifyExistence(ctx context.Context, ids []str
 		args[i] = id
 	}
 
-	query := `SELECT id FROM photos WHERE id IN (` + strings.Join(placeholders, ",") + `)`
+	query := `SELECT id FROM photos WHERE deleted_at IS NULL AND id IN (` + strings.Join(placeholders, ",") + `)`
 
 	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -62,3 +88,136 @@ func (r *PhotoRepositoryPostgres) VerifyExistence(ctx context.Context, ids []str
 
 	return result, rows.Err()
 }
+
+// GetPhotosWithoutPerceptualHash returns live images that have thumbnails but no perceptual hash yet (PostgreSQL version)
+func (r *PhotoRepositoryPostgres) GetPhotosWithoutPerceptualHash(ctx context.Context, limit int) ([]*models.Photo, error) {
+	query := `SELECT ` + photoSelectColumns + ` FROM photos
+		WHERE phash IS NULL AND thumb_small IS NOT NULL AND media_type = 'image' AND deleted_at IS NULL
+		LIMIT $1`
+
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var photos []*models.Photo
+	for rows.Next() {
+		photo, err := scanPhoto(rows)
+		if err != nil {
+			return nil, err
+		}
+		photos = append(photos, photo)
+	}
+
+	return photos, rows.Err()
+}
+
+// UpdatePerceptualHash stores a photo's perceptual hash (PostgreSQL version)
+// An empty hash marks the photo as attempted so it is not retried on every run
+func (r *PhotoRepositoryPostgres) UpdatePerceptualHash(ctx context.Context, photoID, hash string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE photos SET phash = $1 WHERE id = $2", hash, photoID)
+	return err
+}
+
+// GetPerceptualHashesForUser returns the perceptual hashes of a user's live photos (PostgreSQL version)
+func (r *PhotoRepositoryPostgres) GetPerceptualHashesForUser(ctx context.Context, userID string) ([]models.PhotoPerceptualHash, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT id, phash FROM photos WHERE user_id = $1 AND phash IS NOT NULL AND phash <> '' AND deleted_at IS NULL ORDER BY date_taken ASC, id ASC",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []models.PhotoPerceptualHash
+	for rows.Next() {
+		var h models.PhotoPerceptualHash
+		if err := rows.Scan(&h.PhotoID, &h.Hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, h)
+	}
+	return hashes, rows.Err()
+}
+
+// MoveToTrash marks a photo as deleted without removing its row or files (PostgreSQL version)
+// Returns false if the photo does not exist or is already in the trash
+func (r *PhotoRepositoryPostgres) MoveToTrash(ctx context.Context, id string, deletedAt time.Time) (bool, error) {
+	// Trashed photos leave the synced library, so devices see a tombstone
+	return updatePhotoWithChange(ctx, r.db, id, models.PhotoChangeDeleted,
+		"UPDATE photos SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL", deletedAt, id)
+}
+
+// Restore takes a photo back out of the trash (PostgreSQL version)
+// Returns false if the photo does not exist or is not in the trash
+func (r *PhotoRepositoryPostgres) Restore(ctx context.Context, id string) (bool, error) {
+	return updatePhotoWithChange(ctx, r.db, id, models.PhotoChangeAdded,
+		"UPDATE photos SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL", id)
+}
+
+// GetTrashForUser returns a user's trashed photos, most recently deleted first (PostgreSQL version)
+func (r *PhotoRepositoryPostgres) GetTrashForUser(ctx context.Context, userID string, skip, take int) ([]*models.Photo, error) {
+	query := `SELECT ` + photoSelectColumns + ` FROM photos WHERE user_id = $1 AND deleted_at IS NOT NULL ORDER BY deleted_at DESC LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, take, skip)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var photos []*models.Photo
+	for rows.Next() {
+		photo, err := scanPhoto(rows)
+		if err != nil {
+			return nil, err
+		}
+		photos = append(photos, photo)
+	}
+
+	if photos == nil {
+		photos = []*models.Photo{}
+	}
+	return photos, rows.Err()
+}
+
+// GetTrashCountForUser returns the number of photos in a user's trash (PostgreSQL version)
+func (r *PhotoRepositoryPostgres) GetTrashCountForUser(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM photos WHERE user_id = $1 AND deleted_at IS NOT NULL", userID).Scan(&count)
+	return count, err
+}
+
+// GetTrashedBefore returns photos (for any user) that were trashed before the cutoff (PostgreSQL version)
+func (r *PhotoRepositoryPostgres) GetTrashedBefore(ctx context.Context, cutoff time.Time, limit int) ([]*models.Photo, error) {
+	query := `SELECT ` + photoSelectColumns + ` FROM photos WHERE deleted_at IS NOT NULL AND deleted_at < $1 ORDER BY deleted_at ASC LIMIT $2`
+
+	rows, err := r.db.QueryContext(ctx, query, cutoff, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var photos []*models.Photo
+	for rows.Next() {
+		photo, err := scanPhoto(rows)
+		if err != nil {
+			return nil, err
+		}
+		photos = append(photos, photo)
+	}
+
+	return photos, rows.Err()
+}
+
+// GetBlobByHash returns the stored file for a content hash, shared by every
+// user who uploaded it (PostgreSQL version)
+func (r *PhotoRepositoryPostgres) GetBlobByHash(ctx context.Context, hash string) (*models.PhotoBlob, error) {
+	return getBlobByHash(ctx, r.db, strings.ToLower(hash))
+}
+
+// GetBlobRefCount returns how many photo rows still reference a stored file (PostgreSQL version)
+func (r *PhotoRepositoryPostgres) GetBlobRefCount(ctx context.Context, storedPath string) (int, error) {
+	return getBlobRefCount(ctx, r.db, storedPath)
+}
@@ -0,0 +1,300 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+)
+
+// maxFacetValues limits how many values each search facet returns
+const maxFacetValues = 50
+
+// photoSearchFilter accumulates the WHERE conditions and arguments of a search.
+// Placeholders use the $N form, which both PostgreSQL and go-sqlite3 accept.
+type photoSearchFilter struct {
+	conditions []string
+	args       []interface{}
+}
+
+// arg binds a value and returns its placeholder
+func (f *photoSearchFilter) arg(v interface{}) string {
+	f.args = append(f.args, v)
+	return fmt.Sprintf("$%d", len(f.args))
+}
+
+func (f *photoSearchFilter) add(condition string) {
+	f.conditions = append(f.conditions, condition)
+}
+
+func (f *photoSearchFilter) where() string {
+	return strings.Join(f.conditions, " AND ")
+}
+
+// buildPhotoSearchFilter translates a search query into conditions on the photos table (aliased p).
+// The cursor is not included so the same filter can drive the facet queries.
+func buildPhotoSearchFilter(q *models.PhotoSearchQuery, dialect Dialect, smart *smartCollection) *photoSearchFilter {
+	f := &photoSearchFilter{}
+
+	f.add("p.user_id = " + f.arg(q.UserID))
+	f.add("p.deleted_at IS NULL")
+
+	if q.DateFrom != nil {
+		f.add("p.date_taken >= " + f.arg(*q.DateFrom))
+	}
+	if q.DateTo != nil {
+		f.add("p.date_taken <= " + f.arg(*q.DateTo))
+	}
+	if q.CameraMake != "" {
+		f.add("LOWER(p.camera_make) = " + f.arg(strings.ToLower(q.CameraMake)))
+	}
+	if q.CameraModel != "" {
+		f.add("LOWER(p.camera_model) = " + f.arg(strings.ToLower(q.CameraModel)))
+	}
+	if q.LensModel != "" {
+		f.add("LOWER(p.lens_model) = " + f.arg(strings.ToLower(q.LensModel)))
+	}
+	if q.ISOMin != nil {
+		f.add("p.iso >= " + f.arg(*q.ISOMin))
+	}
+	if q.ISOMax != nil {
+		f.add("p.iso <= " + f.arg(*q.ISOMax))
+	}
+
+	// Aperture is stored as text ("f/2.8"), so compare on the numeric part
+	if q.ApertureMin != nil || q.ApertureMax != nil {
+		numericType := "REAL"
+		if dialect == DialectPostgres {
+			numericType = "DOUBLE PRECISION"
+		}
+		aperture := "CAST(SUBSTR(p.aperture, 3) AS " + numericType + ")"
+
+		f.add("p.aperture LIKE 'f/%'")
+		if q.ApertureMin != nil {
+			f.add(aperture + " >= " + f.arg(*q.ApertureMin))
+		}
+		if q.ApertureMax != nil {
+			f.add(aperture + " <= " + f.arg(*q.ApertureMax))
+		}
+	}
+
+	if q.Filename != "" {
+		pattern := "%" + escapeLike(strings.ToLower(q.Filename)) + "%"
+		f.add("LOWER(p.original_filename) LIKE " + f.arg(pattern) + ` ESCAPE '\'`)
+	}
+	if q.DeviceID != "" {
+		f.add("p.origin_device_id = " + f.arg(q.DeviceID))
+	}
+	if q.HasGPS != nil {
+		if *q.HasGPS {
+			f.add("p.latitude IS NOT NULL AND p.longitude IS NOT NULL")
+		} else {
+			f.add("(p.latitude IS NULL OR p.longitude IS NULL)")
+		}
+	}
+
+	if q.BoundingBox != nil {
+		f.addBoundingBox(q.BoundingBox)
+	}
+
+	if smart != nil {
+		f.addSmartCollection(smart, time.Now().UTC())
+	} else if q.CollectionID != "" {
+		f.add("p.id IN (SELECT photo_id FROM collection_photos WHERE collection_id = " + f.arg(q.CollectionID) + ")")
+	}
+
+	return f
+}
+
+// addBoundingBox restricts photos to a latitude/longitude rectangle
+func (f *photoSearchFilter) addBoundingBox(box *models.BoundingBox) {
+	f.add("p.latitude BETWEEN " + f.arg(box.MinLat) + " AND " + f.arg(box.MaxLat))
+	if box.MinLng <= box.MaxLng {
+		f.add("p.longitude BETWEEN " + f.arg(box.MinLng) + " AND " + f.arg(box.MaxLng))
+	} else {
+		// Box crosses the antimeridian
+		f.add("(p.longitude >= " + f.arg(box.MinLng) + " OR p.longitude <= " + f.arg(box.MaxLng) + ")")
+	}
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// searchPhotos returns one page of search results, newest first.
+// Returns photos, next cursor (empty on the last page), and error.
+func searchPhotos(ctx context.Context, db *sql.DB, dialect Dialect, q *models.PhotoSearchQuery) ([]*models.Photo, string, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = models.DefaultSearchLimit
+	}
+
+	smart, err := loadSmartCollection(ctx, db, q.CollectionID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	f := buildPhotoSearchFilter(q, dialect, smart)
+	if q.Cursor != "" {
+		// The cursor holds the previous page's last sort key; date_taken + id
+		// gives a stable order that does not depend on that photo still existing
+		cursor, err := models.DecodeSearchCursor(q.Cursor)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid search cursor: %w", err)
+		}
+		f.add("(p.date_taken, p.id) < (" + f.arg(cursor.DateTaken) + ", " + f.arg(cursor.PhotoID) + ")")
+	}
+
+	query := `SELECT ` + photoSelectColumns + ` FROM photos p
+		WHERE ` + f.where() + `
+		ORDER BY p.date_taken DESC, p.id DESC
+		LIMIT ` + f.arg(limit+1)
+
+	rows, err := db.QueryContext(ctx, query, f.args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	photos := []*models.Photo{}
+	for rows.Next() {
+		photo, err := scanPhoto(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		photos = append(photos, photo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	// Check if there's a next page
+	var nextCursor string
+	if len(photos) > limit {
+		last := photos[limit-1]
+		nextCursor = models.SearchCursor{DateTaken: last.DateTaken, PhotoID: last.ID}.Encode()
+		photos = photos[:limit]
+	}
+
+	return photos, nextCursor, nil
+}
+
+// searchFacets counts the full search result set per camera, year and origin device
+func searchFacets(ctx context.Context, db *sql.DB, dialect Dialect, q *models.PhotoSearchQuery) (*models.SearchFacets, error) {
+	smart, err := loadSmartCollection(ctx, db, q.CollectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	f := buildPhotoSearchFilter(q, dialect, smart)
+	where := f.where()
+
+	facets := &models.SearchFacets{
+		Cameras: []models.CameraFacet{},
+		Years:   []models.FacetCount{},
+		Devices: []models.FacetCount{},
+	}
+
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM photos p WHERE `+where, f.args...).Scan(&facets.TotalCount); err != nil {
+		return nil, err
+	}
+
+	// Per camera
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT COALESCE(p.camera_make, ''), COALESCE(p.camera_model, ''), COUNT(*)
+		FROM photos p
+		WHERE %s AND (p.camera_make IS NOT NULL OR p.camera_model IS NOT NULL)
+		GROUP BY COALESCE(p.camera_make, ''), COALESCE(p.camera_model, '')
+		ORDER BY COUNT(*) DESC
+		LIMIT %d`, where, maxFacetValues), f.args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var c models.CameraFacet
+		if err := rows.Scan(&c.Make, &c.Model, &c.Count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		facets.Cameras = append(facets.Cameras, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Per year. SQLite stores timestamps as text starting with the year.
+	year := "SUBSTR(p.date_taken, 1, 4)"
+	if dialect == DialectPostgres {
+		year = "TO_CHAR(p.date_taken, 'YYYY')"
+	}
+	rows, err = db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT %s, COUNT(*)
+		FROM photos p
+		WHERE %s
+		GROUP BY %s
+		ORDER BY 1 DESC
+		LIMIT %d`, year, where, year, maxFacetValues), f.args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var c models.FacetCount
+		if err := rows.Scan(&c.Value, &c.Count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		facets.Years = append(facets.Years, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Per origin device (legacy photos without a device are left out)
+	rows, err = db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT p.origin_device_id, COALESCE(d.device_name, ''), COUNT(*)
+		FROM photos p
+		LEFT JOIN devices d ON d.id = p.origin_device_id
+		WHERE %s AND p.origin_device_id IS NOT NULL
+		GROUP BY p.origin_device_id, d.device_name
+		ORDER BY COUNT(*) DESC
+		LIMIT %d`, where, maxFacetValues), f.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c models.FacetCount
+		if err := rows.Scan(&c.Value, &c.Label, &c.Count); err != nil {
+			return nil, err
+		}
+		facets.Devices = append(facets.Devices, c)
+	}
+
+	return facets, rows.Err()
+}
+
+// Search returns one page of the user's photos matching the query
+func (r *PhotoRepository) Search(ctx context.Context, q *models.PhotoSearchQuery) ([]*models.Photo, string, error) {
+	return searchPhotos(ctx, r.db, DialectSQLite, q)
+}
+
+// GetSearchFacets returns facet counts for the user's photos matching the query
+func (r *PhotoRepository) GetSearchFacets(ctx context.Context, q *models.PhotoSearchQuery) (*models.SearchFacets, error) {
+	return searchFacets(ctx, r.db, DialectSQLite, q)
+}
+
+// Search returns one page of the user's photos matching the query (PostgreSQL version)
+func (r *PhotoRepositoryPostgres) Search(ctx context.Context, q *models.PhotoSearchQuery) ([]*models.Photo, string, error) {
+	return searchPhotos(ctx, r.db, DialectPostgres, q)
+}
+
+// GetSearchFacets returns facet counts for the user's photos matching the query (PostgreSQL version)
+func (r *PhotoRepositoryPostgres) GetSearchFacets(ctx context.Context, q *models.PhotoSearchQuery) (*models.SearchFacets, error) {
+	return searchFacets(ctx, r.db, DialectPostgres, q)
+}
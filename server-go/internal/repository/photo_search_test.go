@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPhotoRepository_SearchCursorSurvivesDeletion(t *testing.T) {
+	ctx := context.Background()
+	db, err := NewSQLiteDB(filepath.Join(t.TempDir(), "photosync.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	user, err := models.NewUser("user-1@example.com", "User", false)
+	require.NoError(t, err)
+	require.NoError(t, NewUserRepository(db).Add(ctx, user))
+
+	// Pairs of photos share a date so the ID breaks the tie
+	repo := NewPhotoRepository(db)
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		name := fmt.Sprintf("IMG_%04d.jpg", i)
+		photo, err := models.NewPhoto(name, "2024/01/"+name, name, 100, base.Add(time.Duration(i/2)*time.Hour))
+		require.NoError(t, err)
+		photo.UserID = &user.ID
+		require.NoError(t, repo.Add(ctx, photo))
+	}
+
+	query := &models.PhotoSearchQuery{UserID: user.ID, Limit: 3}
+	first, cursor, err := repo.Search(ctx, query)
+	require.NoError(t, err)
+	require.Len(t, first, 3)
+	require.NotEmpty(t, cursor)
+
+	// The photo the cursor points at goes away before the next page
+	_, err = repo.Delete(ctx, first[2].ID)
+	require.NoError(t, err)
+
+	query.Cursor = cursor
+	second, cursor, err := repo.Search(ctx, query)
+	require.NoError(t, err)
+	assert.Len(t, second, 3)
+	assert.Empty(t, cursor)
+
+	seen := map[string]bool{}
+	for _, photo := range append(first, second...) {
+		assert.False(t, seen[photo.ID], "no photo is returned twice")
+		seen[photo.ID] = true
+	}
+	assert.Len(t, seen, 6)
+}
@@ -19,8 +19,8 @@ func NewPostgresDB(connStr string) (*sql.DB, error) {
 		return nil, err
 	}
 
-	// Create tables
-	if err := createPostgresTables(db); err != nil {
+	// Create or upgrade the schema
+	if err := RunMigrations(db, DialectPostgres); err != nil {
 		db.Close()
 		return nil, err
 	}
@@ -28,9 +28,10 @@ func NewPostgresDB(connStr string) (*sql.DB, error) {
 	return db, nil
 }
 
-func createPostgresTables(db *sql.DB) error {
-	// First, run migrations for existing tables
-	if err := runPostgresMigrations(db); err != nil {
+// createPostgresSchema creates the base tables (migration 1)
+func createPostgresSchema(tx *sql.Tx) error {
+	// First, upgrade tables left by older releases so the indexes below can be created
+	if err := upgradeLegacyPostgresTables(tx); err != nil {
 		return err
 	}
 
@@ -389,15 +390,36 @@ func createPostgresTables(db *sql.DB) error {
 	CREATE INDEX IF NOT EXISTS idx_file_conflicts_photo_id ON file_conflicts(photo_id);
 	`
 
-	_, err := db.Exec(schema)
-	return err
+	if _, err := tx.Exec(schema); err != nil {
+		return err
+	}
+
+	// Columns that are not part of the CREATE TABLE statements above
+	columns := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"collections", "theme_source", "TEXT NOT NULL DEFAULT 'explicit'"},
+		{"users", "password_hash", "TEXT"},
+		{"auth_requests", "request_type", "TEXT NOT NULL DEFAULT 'web_login'"},
+		{"auth_requests", "new_password_hash", "TEXT"},
+	}
+	for _, c := range columns {
+		if err := addColumnIfMissing(tx, DialectPostgres, c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-// runPostgresMigrations handles incremental migrations for existing tables
-func runPostgresMigrations(db *sql.DB) error {
+// upgradeLegacyPostgresTables adds photo columns that releases before versioned
+// migrations introduced with ALTER TABLE, for databases created by those releases
+func upgradeLegacyPostgresTables(tx *sql.Tx) error {
 	// Check if photos table exists
 	var tableExists bool
-	err := db.QueryRow(`
+	err := tx.QueryRow(`
 		SELECT EXISTS (
 			SELECT 1 FROM information_schema.tables
 			WHERE table_name = 'photos'
@@ -430,38 +452,10 @@ func runPostgresMigrations(db *sql.DB) error {
 	}
 
 	for _, migration := range migrations {
-		if _, err := db.Exec(migration); err != nil {
+		if _, err := tx.Exec(migration); err != nil {
 			return err
 		}
 	}
 
-	// Create partial index for photos with GPS coordinates (for map view)
-	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_photos_location ON photos(latitude, longitude) WHERE latitude IS NOT NULL`)
-	if err != nil {
-		// Index might already exist with different definition, ignore
-	}
-
-	// Add theme_source column to collections table (for theme inheritance)
-	_, err = db.Exec(`ALTER TABLE collections ADD COLUMN IF NOT EXISTS theme_source TEXT NOT NULL DEFAULT 'explicit'`)
-	if err != nil {
-		return err
-	}
-
-	// Add password_hash column to users table (for mobile auth)
-	_, err = db.Exec(`ALTER TABLE users ADD COLUMN IF NOT EXISTS password_hash TEXT`)
-	if err != nil {
-		return err
-	}
-
-	// Add request_type and new_password_hash columns to auth_requests table (for password reset)
-	_, err = db.Exec(`ALTER TABLE auth_requests ADD COLUMN IF NOT EXISTS request_type TEXT NOT NULL DEFAULT 'web_login'`)
-	if err != nil {
-		return err
-	}
-	_, err = db.Exec(`ALTER TABLE auth_requests ADD COLUMN IF NOT EXISTS new_password_hash TEXT`)
-	if err != nil {
-		return err
-	}
-
 	return nil
 }
@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+)
+
+// RestorePlanRepository implements RestorePlanRepo for PostgreSQL/SQLite
+type RestorePlanRepository struct {
+	db *sql.DB
+}
+
+// NewRestorePlanRepository creates a new RestorePlanRepository
+func NewRestorePlanRepository(db *sql.DB) *RestorePlanRepository {
+	return &RestorePlanRepository{db: db}
+}
+
+const restorePlanColumns = `id, user_id, device_id, source_device_id, collection_id, date_from, date_to,
+			  status, created_at, updated_at, completed_at`
+
+// Add stores a plan together with its items
+func (r *RestorePlanRepository) Add(ctx context.Context, plan *models.RestorePlan, items []*models.RestorePlanItem) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO restore_plans (`+restorePlanColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		plan.ID, plan.UserID, plan.DeviceID, plan.SourceDeviceID, plan.CollectionID, plan.DateFrom, plan.DateTo,
+		plan.Status, plan.CreatedAt, plan.UpdatedAt, plan.CompletedAt,
+	); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO restore_plan_items (plan_id, position, photo_id, file_hash, file_size) VALUES ($1, $2, $3, $4, $5)`,
+			plan.ID, item.Position, item.PhotoID, item.FileHash, item.FileSize,
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetByID returns a plan with its progress, or nil if it does not exist
+func (r *RestorePlanRepository) GetByID(ctx context.Context, id string) (*models.RestorePlan, error) {
+	plans, err := r.queryPlans(ctx, `WHERE id = $1`, id)
+	if err != nil || len(plans) == 0 {
+		return nil, err
+	}
+	return plans[0], nil
+}
+
+// GetForUser returns the user's plans, newest first. A non-empty deviceID
+// limits them to plans for that device.
+func (r *RestorePlanRepository) GetForUser(ctx context.Context, userID, deviceID string) ([]*models.RestorePlan, error) {
+	if deviceID != "" {
+		return r.queryPlans(ctx, `WHERE user_id = $1 AND device_id = $2`, userID, deviceID)
+	}
+	return r.queryPlans(ctx, `WHERE user_id = $1`, userID)
+}
+
+// GetItems returns up to limit items after the given position, in plan
+// order. Photos that have since left the library are skipped.
+func (r *RestorePlanRepository) GetItems(ctx context.Context, planID string, afterPosition, limit int, pendingOnly bool) ([]*models.RestorePlanItem, error) {
+	query := `SELECT i.position, i.photo_id, i.file_hash, i.file_size, p.original_filename, p.date_taken,
+			  p.media_type, i.downloaded_at
+			  FROM restore_plan_items i
+			  INNER JOIN photos p ON p.id = i.photo_id
+			  WHERE i.plan_id = $1 AND i.position > $2 AND p.deleted_at IS NULL`
+	if pendingOnly {
+		query += ` AND i.downloaded_at IS NULL`
+	}
+	query += ` ORDER BY i.position ASC LIMIT $3`
+
+	rows, err := r.db.QueryContext(ctx, query, planID, afterPosition, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*models.RestorePlanItem{}
+	for rows.Next() {
+		var item models.RestorePlanItem
+		if err := rows.Scan(&item.Position, &item.PhotoID, &item.FileHash, &item.FileSize, &item.OriginalFilename,
+			&item.DateTaken, &item.MediaType, &item.DownloadedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, &item)
+	}
+	return items, rows.Err()
+}
+
+// MarkDownloaded records confirmed downloads. A confirmation only counts if
+// the photo is in the plan with the same hash; confirming twice is harmless.
+// Returns the photo IDs that were rejected.
+func (r *RestorePlanRepository) MarkDownloaded(ctx context.Context, planID string, confirmations []models.RestoreConfirmation, at time.Time) ([]string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rejected := []string{}
+	for _, c := range confirmations {
+		result, err := tx.ExecContext(ctx,
+			`UPDATE restore_plan_items SET downloaded_at = COALESCE(downloaded_at, $1)
+			 WHERE plan_id = $2 AND photo_id = $3 AND file_hash = $4`,
+			at, planID, c.PhotoID, c.FileHash,
+		)
+		if err != nil {
+			return nil, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if affected == 0 {
+			rejected = append(rejected, c.PhotoID)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE restore_plans SET updated_at = $1 WHERE id = $2`, at, planID); err != nil {
+		return nil, err
+	}
+	return rejected, tx.Commit()
+}
+
+// UpdateStatus sets a plan's status and completion time
+func (r *RestorePlanRepository) UpdateStatus(ctx context.Context, id string, status models.RestorePlanStatus, completedAt *time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE restore_plans SET status = $1, completed_at = $2, updated_at = $3 WHERE id = $4`,
+		status, completedAt, time.Now().UTC(), id,
+	)
+	return err
+}
+
+// DeleteFinishedBefore removes completed and cancelled plans last updated before the cutoff
+func (r *RestorePlanRepository) DeleteFinishedBefore(ctx context.Context, cutoff time.Time) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	const finished = `SELECT id FROM restore_plans WHERE status != $1 AND updated_at < $2`
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM restore_plan_items WHERE plan_id IN (`+finished+`)`,
+		models.RestorePlanActive, cutoff,
+	); err != nil {
+		return 0, err
+	}
+	result, err := tx.ExecContext(ctx,
+		`DELETE FROM restore_plans WHERE status != $1 AND updated_at < $2`,
+		models.RestorePlanActive, cutoff,
+	)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(affected), tx.Commit()
+}
+
+// queryPlans loads plans and fills in their progress
+func (r *RestorePlanRepository) queryPlans(ctx context.Context, where string, args ...interface{}) ([]*models.RestorePlan, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+restorePlanColumns+` FROM restore_plans `+where+` ORDER BY created_at DESC, id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plans := []*models.RestorePlan{}
+	for rows.Next() {
+		var p models.RestorePlan
+		if err := rows.Scan(&p.ID, &p.UserID, &p.DeviceID, &p.SourceDeviceID, &p.CollectionID, &p.DateFrom, &p.DateTo,
+			&p.Status, &p.CreatedAt, &p.UpdatedAt, &p.CompletedAt); err != nil {
+			return nil, err
+		}
+		plans = append(plans, &p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for _, p := range plans {
+		if err := r.db.QueryRowContext(ctx, `
+			SELECT COUNT(*), COALESCE(SUM(i.file_size), 0), COUNT(i.downloaded_at),
+				COALESCE(SUM(CASE WHEN i.downloaded_at IS NOT NULL THEN i.file_size ELSE 0 END), 0)
+			FROM restore_plan_items i
+			INNER JOIN photos p ON p.id = i.photo_id
+			WHERE i.plan_id = $1 AND p.deleted_at IS NULL`, p.ID,
+		).Scan(&p.TotalItems, &p.TotalBytes, &p.DownloadedItems, &p.DownloadedBytes); err != nil {
+			return nil, err
+		}
+	}
+	return plans, nil
+}
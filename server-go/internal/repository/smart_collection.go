@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+)
+
+// kmPerDegree is the length of one degree of latitude
+const kmPerDegree = 111.32
+
+// smartCollection is what the queries need to know about a smart collection
+type smartCollection struct {
+	userID string
+	rules  *models.SmartCollectionRules
+}
+
+// loadSmartCollection returns a collection's owner and rules, or nil if the
+// collection is a manual one or does not exist
+func loadSmartCollection(ctx context.Context, db *sql.DB, collectionID string) (*smartCollection, error) {
+	if collectionID == "" {
+		return nil, nil
+	}
+
+	var userID string
+	var kind models.CollectionKind
+	var raw sql.NullString
+	err := db.QueryRowContext(ctx, `SELECT user_id, kind, smart_rules FROM collections WHERE id = $1`, collectionID).
+		Scan(&userID, &kind, &raw)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := decodeSmartRules(kind, raw)
+	if err != nil || rules == nil {
+		return nil, err
+	}
+	return &smartCollection{userID: userID, rules: rules}, nil
+}
+
+// decodeSmartRules parses the stored rules of a smart collection
+func decodeSmartRules(kind models.CollectionKind, raw sql.NullString) (*models.SmartCollectionRules, error) {
+	if kind != models.CollectionSmart || !raw.Valid {
+		return nil, nil
+	}
+	var rules models.SmartCollectionRules
+	if err := json.Unmarshal([]byte(raw.String), &rules); err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// encodeSmartRules serialises rules for the smart_rules column
+func encodeSmartRules(rules *models.SmartCollectionRules) (*string, error) {
+	if rules == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return nil, err
+	}
+	s := string(data)
+	return &s, nil
+}
+
+// addSmartCollection adds the conditions that select a smart collection's
+// photos: the owner's photos outside the trash that match every rule
+func (f *photoSearchFilter) addSmartCollection(sc *smartCollection, now time.Time) {
+	rules := sc.rules
+
+	f.add("p.user_id = " + f.arg(sc.userID))
+	f.add("p.deleted_at IS NULL")
+
+	if rules.DateFrom != nil {
+		f.add("p.date_taken >= " + f.arg(rules.DateFrom.UTC()))
+	}
+	if rules.DateTo != nil {
+		f.add("p.date_taken <= " + f.arg(rules.DateTo.UTC()))
+	}
+	if rules.CameraMake != "" {
+		f.add("LOWER(p.camera_make) = " + f.arg(strings.ToLower(rules.CameraMake)))
+	}
+	if rules.CameraModel != "" {
+		f.add("LOWER(p.camera_model) = " + f.arg(strings.ToLower(rules.CameraModel)))
+	}
+	if rules.DeviceID != "" {
+		f.add("p.origin_device_id = " + f.arg(rules.DeviceID))
+	}
+	if rules.BoundingBox != nil {
+		f.addBoundingBox(rules.BoundingBox)
+	}
+	if rules.Near != nil {
+		f.addRadius(rules.Near)
+	}
+	if rules.FilenamePattern != "" {
+		// Glob wildcards become LIKE wildcards once the literal ones are escaped
+		pattern := strings.NewReplacer("*", "%", "?", "_").Replace(escapeLike(strings.ToLower(rules.FilenamePattern)))
+		f.add("LOWER(p.original_filename) LIKE " + f.arg(pattern) + ` ESCAPE '\'`)
+	}
+	if rules.UploadedWithinDays > 0 {
+		f.add("p.uploaded_at >= " + f.arg(now.AddDate(0, 0, -rules.UploadedWithinDays)))
+	}
+}
+
+// addRadius restricts photos to a circle. SQLite has no trigonometry, so
+// distances are measured on a flat map scaled to the circle's latitude,
+// after a latitude band has cut down the candidates.
+func (f *photoSearchFilter) addRadius(c *models.GeoRadius) {
+	latSpan := c.RadiusKm / kmPerDegree
+	f.add("p.latitude BETWEEN " + f.arg(c.Lat-latSpan) + " AND " + f.arg(c.Lat+latSpan))
+	f.add("p.longitude IS NOT NULL")
+
+	dy := "(p.latitude - " + f.arg(c.Lat) + ") * " + f.arg(kmPerDegree)
+	dlng := "ABS(p.longitude - " + f.arg(c.Lng) + ")"
+	// The shorter way round, for circles that cross the antimeridian
+	dx := "(CASE WHEN " + dlng + " > 180 THEN 360 - " + dlng + " ELSE " + dlng + " END) * " +
+		f.arg(kmPerDegree*math.Cos(c.Lat*math.Pi/180))
+	f.add(dy + " * " + dy + " + " + dx + " * " + dx + " <= " + f.arg(c.RadiusKm*c.RadiusKm))
+}
+
+// smartCollectionPhotos returns a smart collection's photos, newest first
+func smartCollectionPhotos(ctx context.Context, db *sql.DB, sc *smartCollection) ([]*models.Photo, error) {
+	f := &photoSearchFilter{}
+	f.addSmartCollection(sc, time.Now().UTC())
+
+	rows, err := db.QueryContext(ctx, `SELECT `+photoSelectColumns+` FROM photos p
+		WHERE `+f.where()+`
+		ORDER BY p.date_taken DESC, p.id DESC`, f.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var photos []*models.Photo
+	for rows.Next() {
+		photo, err := scanPhoto(rows)
+		if err != nil {
+			return nil, err
+		}
+		photos = append(photos, photo)
+	}
+	return photos, rows.Err()
+}
+
+// countSmartCollection counts a smart collection's photos
+func countSmartCollection(ctx context.Context, db *sql.DB, sc *smartCollection) (int, error) {
+	f := &photoSearchFilter{}
+	f.addSmartCollection(sc, time.Now().UTC())
+
+	var count int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM photos p WHERE `+f.where(), f.args...).Scan(&count)
+	return count, err
+}
+
+// smartCollectionHas reports whether a photo matches a smart collection's rules
+func smartCollectionHas(ctx context.Context, db *sql.DB, sc *smartCollection, photoID string) (bool, error) {
+	f := &photoSearchFilter{}
+	f.addSmartCollection(sc, time.Now().UTC())
+	f.add("p.id = " + f.arg(photoID))
+
+	var exists bool
+	err := db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM photos p WHERE `+f.where()+`)`, f.args...).Scan(&exists)
+	return exists, err
+}
@@ -19,8 +19,8 @@ func NewSQLiteDB(dbPath string) (*sql.DB, error) {
 		return nil, err
 	}
 
-	// Create tables
-	if err := createTables(db); err != nil {
+	// Create or upgrade the schema
+	if err := RunMigrations(db, DialectSQLite); err != nil {
 		db.Close()
 		return nil, err
 	}
@@ -28,7 +28,8 @@ func NewSQLiteDB(dbPath string) (*sql.DB, error) {
 	return db, nil
 }
 
-func createTables(db *sql.DB) error {
+// createSQLiteSchema creates the base tables (migration 1)
+func createSQLiteSchema(tx *sql.Tx) error {
 	schema := `
 	-- Users table
 	CREATE TABLE IF NOT EXISTS users (
@@ -372,92 +373,33 @@ func createTables(db *sql.DB) error {
 
 	CREATE INDEX IF NOT EXISTS idx_password_reset_user ON password_reset_tokens(user_id);
 	CREATE INDEX IF NOT EXISTS idx_password_reset_expires ON password_reset_tokens(expires_at);
-
-	-- Add theme_source column to collections (migration-compatible)
-	-- Note: SQLite doesn't support ALTER TABLE ADD COLUMN IF NOT EXISTS in older versions
-	-- This will be handled by checking the table schema first
 	`
 
 	// Execute the schema
-	if _, err := db.Exec(schema); err != nil {
-		return err
-	}
-
-	// Add theme_source column to collections if it doesn't exist
-	var hasThemeSource bool
-	err := db.QueryRow(`
-		SELECT COUNT(*) > 0 FROM pragma_table_info('collections')
-		WHERE name = 'theme_source'
-	`).Scan(&hasThemeSource)
-
-	if err != nil {
-		return err
-	}
-
-	if !hasThemeSource {
-		_, err = db.Exec(`ALTER TABLE collections ADD COLUMN theme_source TEXT NOT NULL DEFAULT 'explicit'`)
-		if err != nil {
-			return err
-		}
-	}
-
-	// Add password_hash column to users if it doesn't exist
-	var hasPasswordHash bool
-	err = db.QueryRow(`
-		SELECT COUNT(*) > 0 FROM pragma_table_info('users')
-		WHERE name = 'password_hash'
-	`).Scan(&hasPasswordHash)
-
-	if err != nil {
+	if _, err := tx.Exec(schema); err != nil {
 		return err
 	}
 
-	if !hasPasswordHash {
-		_, err = db.Exec(`ALTER TABLE users ADD COLUMN password_hash TEXT`)
-		if err != nil {
-			return err
-		}
-		// Create index for password_hash
-		db.Exec(`CREATE INDEX IF NOT EXISTS idx_users_password_hash ON users(password_hash)`)
-	}
-
-	// Add request_type column to auth_requests if it doesn't exist
-	var hasRequestType bool
-	err = db.QueryRow(`
-		SELECT COUNT(*) > 0 FROM pragma_table_info('auth_requests')
-		WHERE name = 'request_type'
-	`).Scan(&hasRequestType)
-
-	if err != nil {
-		return err
+	// Columns added before versioned migrations existed; older databases may lack them
+	columns := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"collections", "theme_source", "TEXT NOT NULL DEFAULT 'explicit'"},
+		{"users", "password_hash", "TEXT"},
+		{"auth_requests", "request_type", "TEXT NOT NULL DEFAULT 'web_login'"},
+		{"auth_requests", "new_password_hash", "TEXT"},
 	}
-
-	if !hasRequestType {
-		_, err = db.Exec(`ALTER TABLE auth_requests ADD COLUMN request_type TEXT NOT NULL DEFAULT 'web_login'`)
-		if err != nil {
+	for _, c := range columns {
+		if err := addColumnIfMissing(tx, DialectSQLite, c.table, c.column, c.definition); err != nil {
 			return err
 		}
-		// Create index for request_type
-		db.Exec(`CREATE INDEX IF NOT EXISTS idx_auth_requests_type ON auth_requests(request_type)`)
 	}
 
-	// Add new_password_hash column to auth_requests if it doesn't exist
-	var hasNewPasswordHash bool
-	err = db.QueryRow(`
-		SELECT COUNT(*) > 0 FROM pragma_table_info('auth_requests')
-		WHERE name = 'new_password_hash'
-	`).Scan(&hasNewPasswordHash)
-
-	if err != nil {
+	if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_users_password_hash ON users(password_hash)`); err != nil {
 		return err
 	}
-
-	if !hasNewPasswordHash {
-		_, err = db.Exec(`ALTER TABLE auth_requests ADD COLUMN new_password_hash TEXT`)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_auth_requests_type ON auth_requests(request_type)`)
+	return err
 }
@@ -18,7 +18,8 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 }
 
 func (r *UserRepository) GetByID(ctx context.Context, id string) (*models.User, error) {
-	query := `SELECT id, email, display_name, api_key, api_key_hash, password_hash, is_admin, created_at, is_active
+	query := `SELECT id, email, display_name, api_key, api_key_hash, password_hash, is_admin, created_at, is_active,
+			  quota_bytes, quota_photos, used_bytes, used_photos
 			  FROM users WHERE id = $1`
 
 	var user models.User
@@ -26,6 +27,7 @@ func (r *UserRepository) GetByID(ctx context.Context, id string) (*models.User,
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&user.ID, &user.Email, &user.DisplayName, &user.APIKey, &user.APIKeyHash,
 		&passwordHash, &user.IsAdmin, &user.CreatedAt, &user.IsActive,
+		&user.QuotaBytes, &user.QuotaPhotos, &user.UsedBytes, &user.UsedPhotos,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -41,7 +43,8 @@ func (r *UserRepository) GetByID(ctx context.Context, id string) (*models.User,
 }
 
 func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
-	query := `SELECT id, email, display_name, api_key, api_key_hash, password_hash, is_admin, created_at, is_active
+	query := `SELECT id, email, display_name, api_key, api_key_hash, password_hash, is_admin, created_at, is_active,
+			  quota_bytes, quota_photos, used_bytes, used_photos
 			  FROM users WHERE email = $1`
 
 	var user models.User
@@ -49,6 +52,7 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.
 	err := r.db.QueryRowContext(ctx, query, email).Scan(
 		&user.ID, &user.Email, &user.DisplayName, &user.APIKey, &user.APIKeyHash,
 		&passwordHash, &user.IsAdmin, &user.CreatedAt, &user.IsActive,
+		&user.QuotaBytes, &user.QuotaPhotos, &user.UsedBytes, &user.UsedPhotos,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -64,7 +68,8 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.
 }
 
 func (r *UserRepository) GetByAPIKeyHash(ctx context.Context, apiKeyHash string) (*models.User, error) {
-	query := `SELECT id, email, display_name, api_key, api_key_hash, password_hash, is_admin, created_at, is_active
+	query := `SELECT id, email, display_name, api_key, api_key_hash, password_hash, is_admin, created_at, is_active,
+			  quota_bytes, quota_photos, used_bytes, used_photos
 			  FROM users WHERE api_key_hash = $1 AND is_active = true`
 
 	var user models.User
@@ -72,6 +77,7 @@ func (r *UserRepository) GetByAPIKeyHash(ctx context.Context, apiKeyHash string)
 	err := r.db.QueryRowContext(ctx, query, apiKeyHash).Scan(
 		&user.ID, &user.Email, &user.DisplayName, &user.APIKey, &user.APIKeyHash,
 		&passwordHash, &user.IsAdmin, &user.CreatedAt, &user.IsActive,
+		&user.QuotaBytes, &user.QuotaPhotos, &user.UsedBytes, &user.UsedPhotos,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -87,7 +93,8 @@ func (r *UserRepository) GetByAPIKeyHash(ctx context.Context, apiKeyHash string)
 }
 
 func (r *UserRepository) GetAll(ctx context.Context) ([]*models.User, error) {
-	query := `SELECT id, email, display_name, api_key_hash, is_admin, created_at, is_active
+	query := `SELECT id, email, display_name, api_key_hash, is_admin, created_at, is_active,
+			  quota_bytes, quota_photos, used_bytes, used_photos
 			  FROM users ORDER BY created_at DESC`
 
 	rows, err := r.db.QueryContext(ctx, query)
@@ -100,7 +107,8 @@ func (r *UserRepository) GetAll(ctx context.Context) ([]*models.User, error) {
 	for rows.Next() {
 		var user models.User
 		if err := rows.Scan(&user.ID, &user.Email, &user.DisplayName, &user.APIKeyHash,
-			&user.IsAdmin, &user.CreatedAt, &user.IsActive); err != nil {
+			&user.IsAdmin, &user.CreatedAt, &user.IsActive,
+			&user.QuotaBytes, &user.QuotaPhotos, &user.UsedBytes, &user.UsedPhotos); err != nil {
 			return nil, err
 		}
 		users = append(users, &user)
@@ -115,21 +123,27 @@ func (r *UserRepository) GetCount(ctx context.Context) (int, error) {
 }
 
 func (r *UserRepository) Add(ctx context.Context, user *models.User) error {
-	query := `INSERT INTO users (id, email, display_name, api_key, api_key_hash, password_hash, is_admin, created_at, is_active)
-			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	query := `INSERT INTO users (id, email, display_name, api_key, api_key_hash, password_hash, is_admin, created_at, is_active,
+			  quota_bytes, quota_photos)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
 
 	_, err := r.db.ExecContext(ctx, query,
 		user.ID, user.Email, user.DisplayName, user.APIKey, user.APIKeyHash,
 		user.PasswordHash, user.IsAdmin, user.CreatedAt, user.IsActive,
+		user.QuotaBytes, user.QuotaPhotos,
 	)
 	return err
 }
 
 func (r *UserRepository) Update(ctx context.Context, user *models.User) error {
-	query := `UPDATE users SET email = $2, display_name = $3, is_admin = $4, is_active = $5
-			  WHERE id = $1`
-
-	_, err := r.db.ExecContext(ctx, query, user.ID, user.Email, user.DisplayName, user.IsAdmin, user.IsActive)
+	// SQLite binds $N placeholders in the order they appear, so keep them in sequence
+	query := `UPDATE users SET email = $1, display_name = $2, is_admin = $3, is_active = $4,
+			  quota_bytes = $5, quota_photos = $6
+			  WHERE id = $7`
+
+	// Usage totals are maintained by the photo repositories and never written here
+	_, err := r.db.ExecContext(ctx, query, user.Email, user.DisplayName, user.IsAdmin, user.IsActive,
+		user.QuotaBytes, user.QuotaPhotos, user.ID)
 	return err
 }
 
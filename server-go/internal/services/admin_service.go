@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/photosync/server/internal/blobstore"
 	"github.com/photosync/server/internal/models"
 	"github.com/photosync/server/internal/repository"
 )
@@ -21,6 +22,7 @@ type AdminService struct {
 	photoRepo          repository.PhotoRepo
 	setupRepo          repository.SetupConfigRepo
 	storageBasePath    string
+	blobStores         []blobstore.BlobStore
 	startTime          time.Time
 	buildVersion       string
 	buildDate          string
@@ -35,6 +37,7 @@ func NewAdminService(
 	photoRepo repository.PhotoRepo,
 	setupRepo repository.SetupConfigRepo,
 	storageBasePath string,
+	blobStores []blobstore.BlobStore,
 	buildVersion string,
 	buildDate string,
 	containerBuildDate string,
@@ -46,6 +49,7 @@ func NewAdminService(
 		photoRepo:          photoRepo,
 		setupRepo:          setupRepo,
 		storageBasePath:    storageBasePath,
+		blobStores:         blobStores,
 		startTime:          time.Now(),
 		buildVersion:       buildVersion,
 		buildDate:          buildDate,
@@ -76,6 +80,7 @@ func (s *AdminService) ListUsers(ctx context.Context) (*models.UserListResponse,
 			DeviceCount:  len(devices),
 			SessionCount: len(sessions),
 			PhotoCount:   photoCount,
+			Usage:        u.Usage(),
 		})
 	}
 
@@ -109,6 +114,7 @@ func (s *AdminService) GetUser(ctx context.Context, userID string) (*models.Admi
 		DeviceCount:  len(devices),
 		SessionCount: len(sessions),
 		PhotoCount:   photoCount,
+		Usage:        user.Usage(),
 	}, nil
 }
 
@@ -153,10 +159,20 @@ func (s *AdminService) UpdateUser(ctx context.Context, userID string, req models
 		}
 	}
 
+	if (req.QuotaBytes != nil && *req.QuotaBytes < 0) || (req.QuotaPhotos != nil && *req.QuotaPhotos < 0) {
+		return models.ErrNegativeQuota
+	}
+
 	user.Email = req.Email
 	user.DisplayName = req.DisplayName
 	user.IsAdmin = req.IsAdmin
 	user.IsActive = req.IsActive
+	if req.QuotaBytes != nil {
+		user.QuotaBytes = *req.QuotaBytes
+	}
+	if req.QuotaPhotos != nil {
+		user.QuotaPhotos = *req.QuotaPhotos
+	}
 
 	return s.userRepo.Update(ctx, user)
 }
@@ -281,14 +297,14 @@ func (s *AdminService) GetSystemStatus(ctx context.Context) (*models.SystemStatu
 		sessionCount += len(sessions)
 	}
 
-	// Calculate storage size
+	// Calculate storage size across the originals and thumbnail stores
 	var totalSize int64
-	filepath.Walk(s.storageBasePath, func(path string, info os.FileInfo, err error) error {
-		if err == nil && !info.IsDir() {
-			totalSize += info.Size()
-		}
-		return nil
-	})
+	for _, store := range s.blobStores {
+		store.List(ctx, "", func(info blobstore.ObjectInfo) error {
+			totalSize += info.Size
+			return nil
+		})
+	}
 
 	// Check Firebase configuration
 	firebaseConfigured := false
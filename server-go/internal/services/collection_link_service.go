@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+)
+
+// collectionLinkAccessRetention is how long share link visits are kept
+const collectionLinkAccessRetention = 90 * 24 * time.Hour
+
+// maxCollectionLinkAccessLog caps how many visits one access log request returns
+const maxCollectionLinkAccessLog = 500
+
+// CollectionLinkService manages the share links of collections and decides
+// whether a visitor may open one. Every visit is written to the link's
+// access log, including the ones that were turned away.
+type CollectionLinkService struct {
+	linkRepo       repository.CollectionLinkRepo
+	collectionRepo repository.CollectionRepo
+	now            func() time.Time
+}
+
+// NewCollectionLinkService creates a new CollectionLinkService
+func NewCollectionLinkService(linkRepo repository.CollectionLinkRepo, collectionRepo repository.CollectionRepo) *CollectionLinkService {
+	return &CollectionLinkService{
+		linkRepo:       linkRepo,
+		collectionRepo: collectionRepo,
+		now:            func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// CreateLink adds a share link to a collection the user owns
+func (s *CollectionLinkService) CreateLink(ctx context.Context, userID, collectionID string, req models.CreateCollectionLinkRequest) (*models.CollectionLink, error) {
+	if _, err := s.ownedCollection(ctx, userID, collectionID); err != nil {
+		return nil, err
+	}
+
+	link, err := models.NewCollectionLink(collectionID, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.linkRepo.Add(ctx, link); err != nil {
+		return nil, fmt.Errorf("failed to save share link: %w", err)
+	}
+
+	link.URL = collectionLinkURL(link)
+	return link, nil
+}
+
+// ListLinks returns a collection's share links, newest first, including
+// revoked and expired ones
+func (s *CollectionLinkService) ListLinks(ctx context.Context, userID, collectionID string) ([]*models.CollectionLink, error) {
+	if _, err := s.ownedCollection(ctx, userID, collectionID); err != nil {
+		return nil, err
+	}
+
+	links, err := s.linkRepo.GetForCollection(ctx, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get share links: %w", err)
+	}
+	for _, link := range links {
+		link.URL = collectionLinkURL(link)
+	}
+	return links, nil
+}
+
+// RevokeLink stops a share link from working. The link and its access log
+// are kept so the owner can still see who used it.
+func (s *CollectionLinkService) RevokeLink(ctx context.Context, userID, collectionID, linkID string) error {
+	if _, err := s.ownedLink(ctx, userID, collectionID, linkID); err != nil {
+		return err
+	}
+
+	revoked, err := s.linkRepo.Revoke(ctx, linkID, s.now())
+	if err != nil {
+		return fmt.Errorf("failed to revoke share link: %w", err)
+	}
+	if !revoked {
+		return models.ErrCollectionLinkRevoked
+	}
+	return nil
+}
+
+// AccessLog returns the most recent visits to a share link
+func (s *CollectionLinkService) AccessLog(ctx context.Context, userID, collectionID, linkID string, limit int) ([]*models.CollectionLinkAccess, error) {
+	if _, err := s.ownedLink(ctx, userID, collectionID, linkID); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 || limit > maxCollectionLinkAccessLog {
+		limit = maxCollectionLinkAccessLog
+	}
+	accesses, err := s.linkRepo.GetAccessLog(ctx, linkID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access log: %w", err)
+	}
+	return accesses, nil
+}
+
+// Open looks up the link behind a token and its collection. It returns
+// ErrCollectionLinkNotFound if no link has the token. A link that can no
+// longer be used is returned together with the reason, so the visit can
+// still be logged against it.
+func (s *CollectionLinkService) Open(ctx context.Context, token string) (*models.CollectionLink, *models.Collection, error) {
+	link, err := s.linkRepo.GetByToken(ctx, token)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get share link: %w", err)
+	}
+	if link == nil {
+		return nil, nil, models.ErrCollectionLinkNotFound
+	}
+
+	collection, err := s.collectionRepo.GetByID(ctx, link.CollectionID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get collection: %w", err)
+	}
+	if collection == nil {
+		return nil, nil, models.ErrCollectionLinkNotFound
+	}
+
+	return link, collection, link.Usable(s.now())
+}
+
+// Unlock checks the password of a protected link and returns the value that
+// keeps the visitor signed in to it, and until when
+func (s *CollectionLinkService) Unlock(link *models.CollectionLink, password string) (string, time.Time, bool) {
+	if !link.CheckPassword(password) {
+		return "", time.Time{}, false
+	}
+	until := s.now().Add(models.CollectionLinkUnlockTTL)
+	return link.UnlockValue(until), until, true
+}
+
+// IsUnlocked reports whether the visitor has already entered the password
+func (s *CollectionLinkService) IsUnlocked(link *models.CollectionLink, value string) bool {
+	return link.VerifyUnlock(value, s.now())
+}
+
+// CountView records a view of the gallery. It fails with
+// ErrCollectionLinkViewLimit if other visitors used up the remaining views
+// since the link was opened.
+func (s *CollectionLinkService) CountView(ctx context.Context, link *models.CollectionLink) error {
+	counted, err := s.linkRepo.RecordView(ctx, link.ID, s.now())
+	if err != nil {
+		return fmt.Errorf("failed to count view: %w", err)
+	}
+	if !counted {
+		return models.ErrCollectionLinkViewLimit
+	}
+	return nil
+}
+
+// RecordAccess adds a visit to the link's access log. Failures are logged
+// rather than returned, since they should not turn a visitor away.
+func (s *CollectionLinkService) RecordAccess(ctx context.Context, link *models.CollectionLink, ipAddress, userAgent, outcome string) {
+	access := &models.CollectionLinkAccess{
+		LinkID:     link.ID,
+		AccessedAt: s.now(),
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+		Outcome:    outcome,
+	}
+	if err := s.linkRepo.LogAccess(ctx, access); err != nil {
+		log.Printf("Failed to record share link access: %v", err)
+	}
+}
+
+// CleanupAccessLog removes share link visits after the retention period
+func (s *CollectionLinkService) CleanupAccessLog(ctx context.Context) (int, error) {
+	removed, err := s.linkRepo.DeleteAccessBefore(ctx, s.now().Add(-collectionLinkAccessRetention))
+	if err != nil {
+		return 0, err
+	}
+	if removed > 0 {
+		log.Printf("Removed %d share link access log entries", removed)
+	}
+	return removed, nil
+}
+
+// ownedCollection returns a collection if the user owns it
+func (s *CollectionLinkService) ownedCollection(ctx context.Context, userID, collectionID string) (*models.Collection, error) {
+	collection, err := s.collectionRepo.GetByID(ctx, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection: %w", err)
+	}
+	if collection == nil {
+		return nil, models.ErrCollectionNotFound
+	}
+	if collection.UserID != userID {
+		return nil, models.ErrCollectionAccessDenied
+	}
+	return collection, nil
+}
+
+// ownedLink returns a link of a collection the user owns
+func (s *CollectionLinkService) ownedLink(ctx context.Context, userID, collectionID, linkID string) (*models.CollectionLink, error) {
+	if _, err := s.ownedCollection(ctx, userID, collectionID); err != nil {
+		return nil, err
+	}
+
+	link, err := s.linkRepo.GetByID(ctx, linkID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get share link: %w", err)
+	}
+	if link == nil || link.CollectionID != collectionID {
+		return nil, models.ErrCollectionLinkNotFound
+	}
+	return link, nil
+}
+
+// collectionLinkURL returns the gallery path a link opens
+func collectionLinkURL(link *models.CollectionLink) string {
+	return "/gallery/s/" + link.Token
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectionLinkService(t *testing.T) {
+	ctx := context.Background()
+	db, _ := setupTestDB(t)
+	collectionRepo := repository.NewCollectionRepository(db)
+	linkRepo := repository.NewCollectionLinkRepository(db)
+	svc := NewCollectionLinkService(linkRepo, collectionRepo)
+	now := time.Now().UTC()
+	svc.now = func() time.Time { return now }
+
+	collection, err := models.NewCollection("user-1", "Wedding")
+	require.NoError(t, err)
+	require.NoError(t, collectionRepo.Add(ctx, collection))
+
+	_, err = svc.CreateLink(ctx, "user-2", collection.ID, models.CreateCollectionLinkRequest{})
+	assert.Equal(t, models.ErrCollectionAccessDenied, err, "only the owner manages links")
+
+	expiry := now.Add(time.Hour)
+	family, err := svc.CreateLink(ctx, "user-1", collection.ID, models.CreateCollectionLinkRequest{
+		Label: "Family", Password: "secret", ExpiresAt: &expiry, MaxViews: 2,
+	})
+	require.NoError(t, err)
+	assert.True(t, family.HasPassword)
+	assert.Equal(t, "/gallery/s/"+family.Token, family.URL)
+	open, err := svc.CreateLink(ctx, "user-1", collection.ID, models.CreateCollectionLinkRequest{Label: "Friends"})
+	require.NoError(t, err)
+
+	// The password is checked against the stored hash
+	link, got, err := svc.Open(ctx, family.Token)
+	require.NoError(t, err)
+	assert.Equal(t, collection.ID, got.ID)
+	assert.True(t, link.HasPassword)
+	_, _, ok := svc.Unlock(link, "wrong")
+	assert.False(t, ok)
+	value, until, ok := svc.Unlock(link, "secret")
+	require.True(t, ok)
+	assert.Equal(t, now.Add(models.CollectionLinkUnlockTTL), until)
+	assert.True(t, svc.IsUnlocked(link, value))
+	assert.False(t, svc.IsUnlocked(link, value+"0"))
+
+	// Views stop being counted at the limit
+	require.NoError(t, svc.CountView(ctx, link))
+	require.NoError(t, svc.CountView(ctx, link))
+	assert.Equal(t, models.ErrCollectionLinkViewLimit, svc.CountView(ctx, link))
+	_, _, err = svc.Open(ctx, family.Token)
+	assert.Equal(t, models.ErrCollectionLinkViewLimit, err)
+
+	// Expiry and revocation only affect their own link
+	now = expiry
+	_, _, err = svc.Open(ctx, family.Token)
+	assert.Equal(t, models.ErrCollectionLinkExpired, err)
+	require.NoError(t, svc.RevokeLink(ctx, "user-1", collection.ID, open.ID))
+	assert.Equal(t, models.ErrCollectionLinkRevoked, svc.RevokeLink(ctx, "user-1", collection.ID, open.ID))
+	link, _, err = svc.Open(ctx, open.Token)
+	assert.Equal(t, models.ErrCollectionLinkRevoked, err)
+	require.NotNil(t, link, "an unusable link is still returned for the access log")
+
+	_, _, err = svc.Open(ctx, "no-such-token")
+	assert.Equal(t, models.ErrCollectionLinkNotFound, err)
+
+	links, err := svc.ListLinks(ctx, "user-1", collection.ID)
+	require.NoError(t, err)
+	require.Len(t, links, 2)
+	for _, l := range links {
+		if l.ID == family.ID {
+			assert.Equal(t, 2, l.ViewCount)
+			assert.NotNil(t, l.LastViewedAt)
+		} else {
+			assert.NotNil(t, l.RevokedAt)
+		}
+	}
+
+	// Visits are logged per link, newest first
+	svc.RecordAccess(ctx, link, "10.0.0.1", "curl", models.AccessOutcome(models.ErrCollectionLinkRevoked))
+	now = now.Add(time.Minute)
+	svc.RecordAccess(ctx, link, "10.0.0.2", "curl", models.LinkAccessGranted)
+	accesses, err := svc.AccessLog(ctx, "user-1", collection.ID, open.ID, 0)
+	require.NoError(t, err)
+	require.Len(t, accesses, 2)
+	assert.Equal(t, "10.0.0.2", accesses[0].IPAddress)
+	assert.Equal(t, models.LinkAccessRevoked, accesses[1].Outcome)
+	accesses, err = svc.AccessLog(ctx, "user-1", collection.ID, family.ID, 0)
+	require.NoError(t, err)
+	assert.Empty(t, accesses)
+	_, err = svc.AccessLog(ctx, "user-2", collection.ID, open.ID, 0)
+	assert.Equal(t, models.ErrCollectionAccessDenied, err)
+
+	now = now.Add(91 * 24 * time.Hour)
+	removed, err := svc.CleanupAccessLog(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"fmt"
+	"log"
 	"regexp"
 	"strings"
 	"time"
@@ -20,6 +21,8 @@ type CollectionService struct {
 	userRepo            repository.UserRepo
 	themeService        *ThemeService
 	userPrefsRepo       repository.UserPreferencesRepository
+	linkRepo            repository.CollectionLinkRepo
+	libraryEvents       *LibraryEventService // Optional
 }
 
 // NewCollectionService creates a new CollectionService
@@ -43,6 +46,17 @@ func NewCollectionService(
 	}
 }
 
+// SetCollectionLinks backs each collection's secret token with a share link,
+// which is what opens galleries shared by secret link
+func (s *CollectionService) SetCollectionLinks(linkRepo repository.CollectionLinkRepo) {
+	s.linkRepo = linkRepo
+}
+
+// SetLibraryEvents enables real-time events when collections change
+func (s *CollectionService) SetLibraryEvents(libraryEvents *LibraryEventService) {
+	s.libraryEvents = libraryEvents
+}
+
 // CreateCollection creates a new collection
 func (s *CollectionService) CreateCollection(ctx context.Context, userID string, req *models.CreateCollectionRequest) (*models.Collection, error) {
 	collection, err := models.NewCollection(userID, req.Name)
@@ -94,6 +108,15 @@ func (s *CollectionService) CreateCollection(ctx context.Context, userID string,
 		collection.CustomCSS = req.CustomCSS
 	}
 
+	// Rules make a smart collection, whose photos are chosen when it is read
+	if req.Rules != nil {
+		if err := req.Rules.Normalize(); err != nil {
+			return nil, err
+		}
+		collection.Kind = models.CollectionSmart
+		collection.Rules = req.Rules
+	}
+
 	if err := s.collectionRepo.Add(ctx, collection); err != nil {
 		return nil, fmt.Errorf("failed to create collection: %w", err)
 	}
@@ -117,6 +140,9 @@ func (s *CollectionService) GetCollection(ctx context.Context, collectionID, use
 	}
 
 	collection.IsOwner = collection.UserID == userID
+	if !collection.IsOwner {
+		collection.Role = s.shareRole(ctx, collection, userID)
+	}
 	return collection, nil
 }
 
@@ -138,19 +164,6 @@ func (s *CollectionService) GetCollectionBySlug(ctx context.Context, slug string
 	return collection, nil
 }
 
-// GetCollectionBySecretToken retrieves a collection by secret token
-func (s *CollectionService) GetCollectionBySecretToken(ctx context.Context, token string) (*models.Collection, error) {
-	collection, err := s.collectionRepo.GetBySecretToken(ctx, token)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get collection: %w", err)
-	}
-	if collection == nil {
-		return nil, models.ErrCollectionNotFound
-	}
-
-	return collection, nil
-}
-
 // ListCollections returns collections owned by and shared with the user
 func (s *CollectionService) ListCollections(ctx context.Context, userID string) (*models.CollectionListResponse, error) {
 	owned, err := s.collectionRepo.GetAllForUser(ctx, userID)
@@ -190,9 +203,13 @@ func (s *CollectionService) UpdateCollection(ctx context.Context, collectionID,
 		return nil, models.ErrCollectionNotFound
 	}
 
-	// Only owner can edit
+	// Editors change what the collection says, only the owner how it is published
 	if collection.UserID != userID {
-		return nil, models.ErrCollectionAccessDenied
+		if !s.shareRole(ctx, collection, userID).CanEdit() ||
+			req.Slug != nil || req.Theme != nil || req.CustomCSS != nil || req.Rules != nil ||
+			req.AllowDownloads != nil || req.MetadataStripping != nil {
+			return nil, models.ErrCollectionAccessDenied
+		}
 	}
 
 	// Update fields
@@ -235,6 +252,24 @@ func (s *CollectionService) UpdateCollection(ctx context.Context, collectionID,
 		}
 		collection.CoverPhotoID = req.CoverPhotoID
 	}
+	if req.Rules != nil {
+		if !collection.IsSmart() {
+			return nil, models.ErrCollectionNotSmart
+		}
+		if err := req.Rules.Normalize(); err != nil {
+			return nil, err
+		}
+		collection.Rules = req.Rules
+	}
+	if req.AllowDownloads != nil {
+		collection.AllowDownloads = *req.AllowDownloads
+	}
+	if req.MetadataStripping != nil {
+		if !models.IsValidMetadataStripping(*req.MetadataStripping) {
+			return nil, models.ErrCollectionInvalidStripping
+		}
+		collection.MetadataStripping = models.MetadataStripping(*req.MetadataStripping)
+	}
 
 	collection.UpdatedAt = time.Now().UTC()
 
@@ -242,9 +277,36 @@ func (s *CollectionService) UpdateCollection(ctx context.Context, collectionID,
 		return nil, fmt.Errorf("failed to update collection: %w", err)
 	}
 
+	// Tell the owner which photos the gallery will refuse to serve
+	if req.MetadataStripping != nil && collection.MetadataStripping.Strips() {
+		unstrippable, err := s.countUnstrippable(ctx, collection.ID)
+		if err != nil {
+			log.Printf("Warning: failed to check metadata stripping for collection %s: %v", collection.ID, err)
+		}
+		collection.UnstrippableCount = unstrippable
+	}
+
+	s.publishChange(ctx, collection, models.CollectionChangeUpdated)
 	return collection, nil
 }
 
+// countUnstrippable returns how many of a collection's photos cannot have
+// their metadata removed on this server. Their originals are not served by a
+// gallery that strips metadata; visitors only see their thumbnails.
+func (s *CollectionService) countUnstrippable(ctx context.Context, collectionID string) (int, error) {
+	photos, err := s.GetPhotosPublic(ctx, collectionID)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, photo := range photos {
+		if !CanStripMetadata(photo.StoredPath) {
+			count++
+		}
+	}
+	return count, nil
+}
+
 // UpdateVisibility changes collection visibility
 func (s *CollectionService) UpdateVisibility(ctx context.Context, collectionID, userID string, visibility string) (*models.Collection, error) {
 	if !models.IsValidVisibility(visibility) {
@@ -264,15 +326,46 @@ func (s *CollectionService) UpdateVisibility(ctx context.Context, collectionID,
 		return nil, models.ErrCollectionAccessDenied
 	}
 
+	previousToken := collection.SecretToken
 	collection.SetVisibility(models.CollectionVisibility(visibility))
 
+	// The link behind a new token must exist before anyone is shown the token
+	newToken := collection.SecretToken != nil && (previousToken == nil || *previousToken != *collection.SecretToken)
+	if newToken && s.linkRepo != nil {
+		if err := s.linkRepo.Add(ctx, models.NewSecretTokenLink(collection)); err != nil {
+			return nil, fmt.Errorf("failed to create secret link: %w", err)
+		}
+	}
+
 	if err := s.collectionRepo.Update(ctx, collection); err != nil {
 		return nil, fmt.Errorf("failed to update visibility: %w", err)
 	}
 
+	if previousToken != nil && collection.SecretToken == nil && s.linkRepo != nil {
+		if err := s.revokeSecretLink(ctx, *previousToken); err != nil {
+			return nil, err
+		}
+	}
+
+	s.publishChange(ctx, collection, models.CollectionChangeUpdated)
 	return collection, nil
 }
 
+// revokeSecretLink stops the link behind a retired secret token from working
+func (s *CollectionService) revokeSecretLink(ctx context.Context, token string) error {
+	link, err := s.linkRepo.GetByToken(ctx, token)
+	if err != nil {
+		return fmt.Errorf("failed to get secret link: %w", err)
+	}
+	if link == nil || link.RevokedAt != nil {
+		return nil
+	}
+	if _, err := s.linkRepo.Revoke(ctx, link.ID, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to revoke secret link: %w", err)
+	}
+	return nil
+}
+
 // DeleteCollection deletes a collection
 func (s *CollectionService) DeleteCollection(ctx context.Context, collectionID, userID string) error {
 	collection, err := s.collectionRepo.GetByID(ctx, collectionID)
@@ -288,10 +381,14 @@ func (s *CollectionService) DeleteCollection(ctx context.Context, collectionID,
 		return models.ErrCollectionAccessDenied
 	}
 
+	// Shares go with the collection, so find who to tell first
+	recipients := s.eventRecipients(ctx, collection)
+
 	if err := s.collectionRepo.Delete(ctx, collectionID); err != nil {
 		return fmt.Errorf("failed to delete collection: %w", err)
 	}
 
+	s.publish(recipients, collectionID, models.CollectionChangeDeleted)
 	return nil
 }
 
@@ -305,10 +402,13 @@ func (s *CollectionService) AddPhotos(ctx context.Context, collectionID, userID
 		return models.ErrCollectionNotFound
 	}
 
-	// Only owner can add photos
-	if collection.UserID != userID {
+	// The owner, contributors and editors add photos, each only their own
+	if collection.UserID != userID && !s.shareRole(ctx, collection, userID).CanAddPhotos() {
 		return models.ErrCollectionAccessDenied
 	}
+	if collection.IsSmart() {
+		return models.ErrCollectionSmart
+	}
 
 	// Verify user owns all photos
 	for _, photoID := range photoIDs {
@@ -324,7 +424,7 @@ func (s *CollectionService) AddPhotos(ctx context.Context, collectionID, userID
 		}
 	}
 
-	if err := s.collectionPhotoRepo.AddMultiple(ctx, collectionID, photoIDs); err != nil {
+	if err := s.collectionPhotoRepo.AddMultiple(ctx, collectionID, userID, photoIDs); err != nil {
 		return fmt.Errorf("failed to add photos: %w", err)
 	}
 
@@ -332,6 +432,7 @@ func (s *CollectionService) AddPhotos(ctx context.Context, collectionID, userID
 	collection.UpdatedAt = time.Now().UTC()
 	s.collectionRepo.Update(ctx, collection)
 
+	s.publishChange(ctx, collection, models.CollectionChangePhotosAdded)
 	return nil
 }
 
@@ -345,9 +446,11 @@ func (s *CollectionService) RemovePhotos(ctx context.Context, collectionID, user
 		return models.ErrCollectionNotFound
 	}
 
-	// Only owner can remove photos
-	if collection.UserID != userID {
-		return models.ErrCollectionAccessDenied
+	if err := s.checkCanRemove(ctx, collection, userID, photoIDs); err != nil {
+		return err
+	}
+	if collection.IsSmart() {
+		return models.ErrCollectionSmart
 	}
 
 	if err := s.collectionPhotoRepo.RemoveMultiple(ctx, collectionID, photoIDs); err != nil {
@@ -358,6 +461,7 @@ func (s *CollectionService) RemovePhotos(ctx context.Context, collectionID, user
 	collection.UpdatedAt = time.Now().UTC()
 	s.collectionRepo.Update(ctx, collection)
 
+	s.publishChange(ctx, collection, models.CollectionChangePhotosRemoved)
 	return nil
 }
 
@@ -371,15 +475,19 @@ func (s *CollectionService) ReorderPhotos(ctx context.Context, collectionID, use
 		return models.ErrCollectionNotFound
 	}
 
-	// Only owner can reorder
-	if collection.UserID != userID {
+	// The owner and editors reorder
+	if collection.UserID != userID && !s.shareRole(ctx, collection, userID).CanEdit() {
 		return models.ErrCollectionAccessDenied
 	}
+	if collection.IsSmart() {
+		return models.ErrCollectionSmart
+	}
 
 	if err := s.collectionPhotoRepo.Reorder(ctx, collectionID, photoIDs); err != nil {
 		return fmt.Errorf("failed to reorder photos: %w", err)
 	}
 
+	s.publishChange(ctx, collection, models.CollectionChangeReordered)
 	return nil
 }
 
@@ -406,6 +514,41 @@ func (s *CollectionService) GetPhotos(ctx context.Context, collectionID, userID
 	return photos, nil
 }
 
+// GetPhotoEntries returns the photos in a collection with who added each one
+func (s *CollectionService) GetPhotoEntries(ctx context.Context, collectionID, userID string) ([]*models.CollectionPhotoWithDetails, error) {
+	photos, err := s.GetPhotos(ctx, collectionID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.collectionPhotoRepo.GetByCollectionID(ctx, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection entries: %w", err)
+	}
+	byPhoto := make(map[string]*models.CollectionPhoto, len(entries))
+	for _, e := range entries {
+		byPhoto[e.PhotoID] = e
+	}
+
+	// Only names are shown, as anyone who can see the collection sees them
+	users := make(map[string]*models.User)
+	result := make([]*models.CollectionPhotoWithDetails, 0, len(photos))
+	for _, p := range photos {
+		item := &models.CollectionPhotoWithDetails{Photo: p}
+		if e := byPhoto[p.ID]; e != nil {
+			item.CollectionPhoto = *e
+			if e.AddedBy != "" {
+				if _, ok := users[e.AddedBy]; !ok {
+					users[e.AddedBy] = s.contributor(ctx, e.AddedBy)
+				}
+				item.AddedByUser = users[e.AddedBy]
+			}
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
 // GetPhotosPublic returns photos for public/secret link access
 func (s *CollectionService) GetPhotosPublic(ctx context.Context, collectionID string) ([]*models.Photo, error) {
 	photos, err := s.collectionPhotoRepo.GetPhotosForCollection(ctx, collectionID)
@@ -415,8 +558,16 @@ func (s *CollectionService) GetPhotosPublic(ctx context.Context, collectionID st
 	return photos, nil
 }
 
-// ShareWithUsers shares a collection with users by email
-func (s *CollectionService) ShareWithUsers(ctx context.Context, collectionID, userID string, emails []string) ([]string, error) {
+// ShareWithUsers shares a collection with users by email, giving them role.
+// Users it is already shared with get the new role.
+func (s *CollectionService) ShareWithUsers(ctx context.Context, collectionID, userID string, emails []string, role string) ([]string, error) {
+	if role == "" {
+		role = string(models.ShareRoleViewer)
+	}
+	if !models.IsValidShareRole(role) {
+		return nil, models.ErrCollectionInvalidShareRole
+	}
+
 	collection, err := s.collectionRepo.GetByID(ctx, collectionID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get collection: %w", err)
@@ -443,12 +594,13 @@ func (s *CollectionService) ShareWithUsers(ctx context.Context, collectionID, us
 			continue
 		}
 
-		share := models.NewCollectionShare(collectionID, user.ID)
+		share := models.NewCollectionShare(collectionID, user.ID, models.ShareRole(role))
 		if err := s.collectionShareRepo.Add(ctx, share); err != nil {
 			failedEmails = append(failedEmails, email)
 		}
 	}
 
+	s.publishChange(ctx, collection, models.CollectionChangeShared)
 	return failedEmails, nil
 }
 
@@ -471,6 +623,41 @@ func (s *CollectionService) RemoveShare(ctx context.Context, collectionID, owner
 		return fmt.Errorf("failed to remove share: %w", err)
 	}
 
+	// The removed user is no longer a recipient but still needs to know
+	if s.libraryEvents != nil {
+		s.publish(append(s.eventRecipients(ctx, collection), targetUserID), collectionID, models.CollectionChangeUnshared)
+	}
+	return nil
+}
+
+// UpdateShareRole changes what a user a collection is shared with may do
+func (s *CollectionService) UpdateShareRole(ctx context.Context, collectionID, ownerID, targetUserID, role string) error {
+	if !models.IsValidShareRole(role) {
+		return models.ErrCollectionInvalidShareRole
+	}
+
+	collection, err := s.collectionRepo.GetByID(ctx, collectionID)
+	if err != nil {
+		return fmt.Errorf("failed to get collection: %w", err)
+	}
+	if collection == nil {
+		return models.ErrCollectionNotFound
+	}
+
+	// Only owner can change roles
+	if collection.UserID != ownerID {
+		return models.ErrCollectionAccessDenied
+	}
+
+	updated, err := s.collectionShareRepo.UpdateRole(ctx, collectionID, targetUserID, models.ShareRole(role))
+	if err != nil {
+		return fmt.Errorf("failed to update share: %w", err)
+	}
+	if !updated {
+		return models.ErrCollectionShareNotFound
+	}
+
+	s.publishChange(ctx, collection, models.CollectionChangeShared)
 	return nil
 }
 
@@ -531,6 +718,41 @@ func (s *CollectionService) ResolveCollectionTheme(ctx context.Context, collecti
 
 // Helper methods
 
+// publishChange notifies the owner and everyone the collection is shared with
+func (s *CollectionService) publishChange(ctx context.Context, collection *models.Collection, change string) {
+	if s.libraryEvents == nil {
+		return
+	}
+	s.publish(s.eventRecipients(ctx, collection), collection.ID, change)
+}
+
+// eventRecipients returns the users who see a collection in their library
+func (s *CollectionService) eventRecipients(ctx context.Context, collection *models.Collection) []string {
+	if s.libraryEvents == nil {
+		return nil
+	}
+	recipients := []string{collection.UserID}
+	shares, err := s.collectionShareRepo.GetByCollectionID(ctx, collection.ID)
+	if err != nil {
+		log.Printf("Warning: failed to load shares of collection %s for library event: %v", collection.ID, err)
+		return recipients
+	}
+	for _, share := range shares {
+		recipients = append(recipients, share.UserID)
+	}
+	return recipients
+}
+
+// publish sends a collection event to each user
+func (s *CollectionService) publish(userIDs []string, collectionID, change string) {
+	if s.libraryEvents == nil {
+		return
+	}
+	for _, userID := range userIDs {
+		s.libraryEvents.Publish(userID, models.NewCollectionEvent(collectionID, change))
+	}
+}
+
 func (s *CollectionService) canViewCollection(ctx context.Context, collection *models.Collection, userID string) bool {
 	// Owner can always view
 	if collection.UserID == userID {
@@ -543,14 +765,60 @@ func (s *CollectionService) canViewCollection(ctx context.Context, collection *m
 	}
 
 	// Check if shared with user
-	if collection.Visibility == models.VisibilityShared || collection.Visibility == models.VisibilitySecretLink {
-		shared, err := s.collectionShareRepo.IsSharedWithUser(ctx, collection.ID, userID)
-		if err == nil && shared {
-			return true
+	return s.shareRole(ctx, collection, userID) != ""
+}
+
+// shareRole returns the user's role on a collection that is shared with them,
+// or "" if it is not or the collection is private
+func (s *CollectionService) shareRole(ctx context.Context, collection *models.Collection, userID string) models.ShareRole {
+	if collection.Visibility == models.VisibilityPrivate {
+		return ""
+	}
+	role, err := s.collectionShareRepo.GetRole(ctx, collection.ID, userID)
+	if err != nil {
+		log.Printf("Warning: failed to get share role on collection %s: %v", collection.ID, err)
+		return ""
+	}
+	return role
+}
+
+// checkCanRemove allows the owner and editors to remove any photo, and
+// contributors the photos they added themselves
+func (s *CollectionService) checkCanRemove(ctx context.Context, collection *models.Collection, userID string, photoIDs []string) error {
+	if collection.UserID == userID {
+		return nil
+	}
+	role := s.shareRole(ctx, collection, userID)
+	if role.CanEdit() {
+		return nil
+	}
+	if !role.CanAddPhotos() {
+		return models.ErrCollectionAccessDenied
+	}
+
+	entries, err := s.collectionPhotoRepo.GetByCollectionID(ctx, collection.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get collection entries: %w", err)
+	}
+	addedBy := make(map[string]string, len(entries))
+	for _, e := range entries {
+		addedBy[e.PhotoID] = e.AddedBy
+	}
+	for _, photoID := range photoIDs {
+		if by, ok := addedBy[photoID]; ok && by != userID {
+			return models.ErrCollectionAccessDenied
 		}
 	}
+	return nil
+}
 
-	return false
+// contributor returns the name of a user who added photos to a collection
+func (s *CollectionService) contributor(ctx context.Context, userID string) *models.User {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil || user == nil {
+		return nil
+	}
+	return &models.User{ID: user.ID, DisplayName: user.DisplayName}
 }
 
 func (s *CollectionService) sanitizeSlug(slug string) string {
@@ -579,9 +847,11 @@ func (s *CollectionService) toSummary(c *models.Collection) *models.CollectionSu
 		Slug:         c.Slug,
 		Theme:        c.Theme,
 		Visibility:   c.Visibility,
+		Kind:         c.Kind,
 		PhotoCount:   c.PhotoCount,
 		CoverPhotoID: c.CoverPhotoID,
 		IsOwner:      c.IsOwner,
+		Role:         c.Role,
 		CreatedAt:    c.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:    c.UpdatedAt.Format(time.RFC3339),
 	}
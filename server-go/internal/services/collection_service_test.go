@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectionService_SmartCollection(t *testing.T) {
+	ctx := context.Background()
+	db, _ := setupTestDB(t)
+	photoRepo := repository.NewPhotoRepository(db)
+	collectionRepo := repository.NewCollectionRepository(db)
+	collectionPhotoRepo := repository.NewCollectionPhotoRepository(db)
+	svc := NewCollectionService(
+		collectionRepo, collectionPhotoRepo, repository.NewCollectionShareRepository(db),
+		photoRepo, repository.NewUserRepository(db), nil, repository.NewUserPreferencesRepository(db),
+	)
+
+	add := func(userID, filename, camera string, taken time.Time, lat, lng float64) *models.Photo {
+		photo, err := models.NewPhoto(filename, filename, "hash-"+filename, 100, taken)
+		require.NoError(t, err)
+		photo.UserID = &userID
+		photo.CameraModel = &camera
+		photo.Latitude = &lat
+		photo.Longitude = &lng
+		require.NoError(t, photoRepo.Add(ctx, photo))
+		return photo
+	}
+	// Paris, a few km from Paris, London, and a photo of someone else's
+	paris := add("user-1", "IMG_0001.HEIC", "iPhone 15", time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC), 48.8566, 2.3522)
+	versailles := add("user-1", "IMG_0002.heic", "iphone 15", time.Date(2023, 7, 2, 12, 0, 0, 0, time.UTC), 48.8049, 2.1204)
+	london := add("user-1", "IMG_0003.HEIC", "iPhone 15", time.Date(2023, 7, 3, 12, 0, 0, 0, time.UTC), 51.5074, -0.1278)
+	add("user-2", "IMG_0004.HEIC", "iPhone 15", time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC), 48.8566, 2.3522)
+
+	_, err := svc.CreateCollection(ctx, "user-1", &models.CreateCollectionRequest{Name: "Empty", Rules: &models.SmartCollectionRules{}})
+	assert.Equal(t, models.ErrSmartRulesEmpty, err)
+
+	from := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	collection, err := svc.CreateCollection(ctx, "user-1", &models.CreateCollectionRequest{
+		Name: "France 2023",
+		Rules: &models.SmartCollectionRules{
+			DateFrom:        &from,
+			CameraModel:     "IPHONE 15",
+			FilenamePattern: "img_????.heic",
+			Near:            &models.GeoRadius{Lat: 48.8566, Lng: 2.3522, RadiusKm: 25},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, models.CollectionSmart, collection.Kind)
+
+	photoIDs := func() []string {
+		photos, err := svc.GetPhotos(ctx, collection.ID, "user-1")
+		require.NoError(t, err)
+		ids := make([]string, len(photos))
+		for i, p := range photos {
+			ids[i] = p.ID
+		}
+		return ids
+	}
+	assert.Equal(t, []string{versailles.ID, paris.ID}, photoIDs(), "newest first, only the owner's photos")
+
+	inCollection, err := collectionPhotoRepo.IsPhotoInCollection(ctx, collection.ID, london.ID)
+	require.NoError(t, err)
+	assert.False(t, inCollection)
+	assert.Equal(t, models.ErrCollectionSmart, svc.AddPhotos(ctx, collection.ID, "user-1", []string{london.ID}))
+
+	// Search and the collection list see the same members
+	found, _, err := photoRepo.Search(ctx, &models.PhotoSearchQuery{UserID: "user-1", CollectionID: collection.ID})
+	require.NoError(t, err)
+	assert.Len(t, found, 2)
+	list, err := svc.ListCollections(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, list.Owned, 1)
+	assert.Equal(t, 2, list.Owned[0].PhotoCount)
+
+	// Membership follows rule changes and the trash straight away
+	_, err = svc.UpdateCollection(ctx, collection.ID, "user-1", &models.UpdateCollectionRequest{
+		Rules: &models.SmartCollectionRules{BoundingBox: &models.BoundingBox{MinLat: 48, MinLng: -1, MaxLat: 52, MaxLng: 3}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{london.ID, versailles.ID, paris.ID}, photoIDs())
+	_, err = photoRepo.MoveToTrash(ctx, versailles.ID, time.Now().UTC())
+	require.NoError(t, err)
+	assert.Equal(t, []string{london.ID, paris.ID}, photoIDs())
+
+	_, err = svc.UpdateCollection(ctx, collection.ID, "user-1", &models.UpdateCollectionRequest{
+		Rules: &models.SmartCollectionRules{UploadedWithinDays: 7},
+	})
+	require.NoError(t, err)
+	assert.Len(t, photoIDs(), 2)
+	_, err = db.Exec(`UPDATE photos SET uploaded_at = $1 WHERE id = $2`, time.Now().UTC().AddDate(0, 0, -8), london.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{paris.ID}, photoIDs())
+
+	manual, err := svc.CreateCollection(ctx, "user-1", &models.CreateCollectionRequest{Name: "Manual"})
+	require.NoError(t, err)
+	_, err = svc.UpdateCollection(ctx, manual.ID, "user-1", &models.UpdateCollectionRequest{
+		Rules: &models.SmartCollectionRules{UploadedWithinDays: 7},
+	})
+	assert.Equal(t, models.ErrCollectionNotSmart, err)
+}
+
+func TestCollectionService_ShareRoles(t *testing.T) {
+	ctx := context.Background()
+	db, _ := setupTestDB(t)
+	photoRepo := repository.NewPhotoRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	svc := NewCollectionService(
+		repository.NewCollectionRepository(db), repository.NewCollectionPhotoRepository(db),
+		repository.NewCollectionShareRepository(db), photoRepo, userRepo, nil, repository.NewUserPreferencesRepository(db),
+	)
+
+	grandma, err := models.NewUser("user-3@example.com", "Grandma", false)
+	require.NoError(t, err)
+	grandma.ID = "user-3"
+	require.NoError(t, userRepo.Add(ctx, grandma))
+
+	add := func(userID, filename string) string {
+		photo, err := models.NewPhoto(filename, filename, "hash-"+filename, 100, time.Now().UTC())
+		require.NoError(t, err)
+		photo.UserID = &userID
+		require.NoError(t, photoRepo.Add(ctx, photo))
+		return photo.ID
+	}
+	mine, theirs, grandmas := add("user-1", "beach.jpg"), add("user-2", "pier.jpg"), add("user-3", "ice-cream.jpg")
+
+	collection, err := svc.CreateCollection(ctx, "user-1", &models.CreateCollectionRequest{Name: "Vacation"})
+	require.NoError(t, err)
+	assert.Equal(t, models.StripLocation, collection.MetadataStripping, "galleries hide where photos were taken by default")
+	_, err = svc.UpdateVisibility(ctx, collection.ID, "user-1", string(models.VisibilityShared))
+	require.NoError(t, err)
+	require.NoError(t, svc.AddPhotos(ctx, collection.ID, "user-1", []string{mine}))
+
+	_, err = svc.ShareWithUsers(ctx, collection.ID, "user-1", []string{"user-2@example.com"}, "owner")
+	assert.Equal(t, models.ErrCollectionInvalidShareRole, err)
+	_, err = svc.ShareWithUsers(ctx, collection.ID, "user-1", []string{"user-2@example.com"}, "contributor")
+	require.NoError(t, err)
+	_, err = svc.ShareWithUsers(ctx, collection.ID, "user-1", []string{"user-3@example.com"}, "")
+	require.NoError(t, err)
+
+	// Viewers only look; contributors add their own photos and nothing else
+	assert.Equal(t, models.ErrCollectionAccessDenied, svc.AddPhotos(ctx, collection.ID, "user-3", []string{grandmas}))
+	assert.Equal(t, models.ErrCollectionPhotoNotOwned, svc.AddPhotos(ctx, collection.ID, "user-2", []string{mine}))
+	require.NoError(t, svc.AddPhotos(ctx, collection.ID, "user-2", []string{theirs}))
+	assert.Equal(t, models.ErrCollectionAccessDenied, svc.RemovePhotos(ctx, collection.ID, "user-2", []string{mine}))
+	assert.Equal(t, models.ErrCollectionAccessDenied, svc.ReorderPhotos(ctx, collection.ID, "user-2", []string{theirs, mine}))
+	name := "Our vacation"
+	_, err = svc.UpdateCollection(ctx, collection.ID, "user-2", &models.UpdateCollectionRequest{Name: &name})
+	assert.Equal(t, models.ErrCollectionAccessDenied, err)
+
+	// Everyone who can see the collection sees who added what
+	entries, err := svc.GetPhotoEntries(ctx, collection.ID, "user-3")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "user-1", entries[0].AddedBy)
+	assert.Equal(t, theirs, entries[1].Photo.ID)
+	require.NotNil(t, entries[1].AddedByUser)
+	assert.Equal(t, "user-2", entries[1].AddedByUser.DisplayName)
+	assert.Empty(t, entries[1].AddedByUser.Email)
+
+	// Sharing again changes the role; editors manage photos and wording but not publishing
+	_, err = svc.ShareWithUsers(ctx, collection.ID, "user-1", []string{"user-3@example.com"}, "editor")
+	require.NoError(t, err)
+	got, err := svc.GetCollection(ctx, collection.ID, "user-3")
+	require.NoError(t, err)
+	assert.Equal(t, models.ShareRoleEditor, got.Role)
+	require.NoError(t, svc.ReorderPhotos(ctx, collection.ID, "user-3", []string{theirs, mine}))
+	_, err = svc.UpdateCollection(ctx, collection.ID, "user-3", &models.UpdateCollectionRequest{Name: &name, CoverPhotoID: &theirs})
+	require.NoError(t, err)
+	slug := "vacation"
+	_, err = svc.UpdateCollection(ctx, collection.ID, "user-3", &models.UpdateCollectionRequest{Slug: &slug})
+	assert.Equal(t, models.ErrCollectionAccessDenied, err)
+	_, err = svc.UpdateVisibility(ctx, collection.ID, "user-3", string(models.VisibilityPublic))
+	assert.Equal(t, models.ErrCollectionAccessDenied, err)
+	keep := string(models.StripNone)
+	_, err = svc.UpdateCollection(ctx, collection.ID, "user-3", &models.UpdateCollectionRequest{MetadataStripping: &keep})
+	assert.Equal(t, models.ErrCollectionAccessDenied, err)
+	require.NoError(t, svc.RemovePhotos(ctx, collection.ID, "user-3", []string{mine}))
+
+	// Contributors take back their own photos
+	require.NoError(t, svc.RemovePhotos(ctx, collection.ID, "user-2", []string{theirs}))
+	photos, err := svc.GetPhotos(ctx, collection.ID, "user-1")
+	require.NoError(t, err)
+	assert.Empty(t, photos)
+
+	assert.Equal(t, models.ErrCollectionAccessDenied, svc.UpdateShareRole(ctx, collection.ID, "user-3", "user-2", "editor"))
+	assert.Equal(t, models.ErrCollectionShareNotFound, svc.UpdateShareRole(ctx, collection.ID, "user-1", "user-1", "editor"))
+	require.NoError(t, svc.UpdateShareRole(ctx, collection.ID, "user-1", "user-2", "viewer"))
+	assert.Equal(t, models.ErrCollectionAccessDenied, svc.AddPhotos(ctx, collection.ID, "user-2", []string{theirs}))
+
+	// A private collection ignores its shares
+	_, err = svc.UpdateVisibility(ctx, collection.ID, "user-1", string(models.VisibilityPrivate))
+	require.NoError(t, err)
+	assert.Equal(t, models.ErrCollectionAccessDenied, svc.AddPhotos(ctx, collection.ID, "user-3", []string{grandmas}))
+}
+
+func TestCollectionService_MetadataStripping(t *testing.T) {
+	ctx := context.Background()
+	db, _ := setupTestDB(t)
+	collectionRepo := repository.NewCollectionRepository(db)
+	photoRepo := repository.NewPhotoRepository(db)
+	svc := NewCollectionService(
+		collectionRepo, repository.NewCollectionPhotoRepository(db), repository.NewCollectionShareRepository(db),
+		photoRepo, repository.NewUserRepository(db), nil, repository.NewUserPreferencesRepository(db),
+	)
+
+	collection, err := svc.CreateCollection(ctx, "user-1", &models.CreateCollectionRequest{Name: "Garden"})
+	require.NoError(t, err)
+	var photoIDs []string
+	for _, name := range []string{"IMG_0001.jpg", "IMG_0002.mov"} {
+		photo, err := models.NewPhoto(name, "2024/01/"+name, name, 100, time.Now().UTC())
+		require.NoError(t, err)
+		require.NoError(t, photoRepo.AddWithUser(ctx, photo, "user-1"))
+		photoIDs = append(photoIDs, photo.ID)
+	}
+	require.NoError(t, svc.AddPhotos(ctx, collection.ID, "user-1", photoIDs))
+
+	invalid := "gps"
+	_, err = svc.UpdateCollection(ctx, collection.ID, "user-1", &models.UpdateCollectionRequest{MetadataStripping: &invalid})
+	assert.Equal(t, models.ErrCollectionInvalidStripping, err)
+
+	all := string(models.StripAll)
+	updated, err := svc.UpdateCollection(ctx, collection.ID, "user-1", &models.UpdateCollectionRequest{MetadataStripping: &all})
+	require.NoError(t, err)
+	stored, err := collectionRepo.GetByID(ctx, collection.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StripAll, stored.MetadataStripping)
+
+	// The owner learns the video cannot be cleaned without exiftool
+	unstrippable := 1
+	if IsExiftoolAvailable() {
+		unstrippable = 0
+	}
+	assert.Equal(t, unstrippable, updated.UnstrippableCount)
+
+	none := string(models.StripNone)
+	updated, err = svc.UpdateCollection(ctx, collection.ID, "user-1", &models.UpdateCollectionRequest{MetadataStripping: &none})
+	require.NoError(t, err)
+	assert.Zero(t, updated.UnstrippableCount, "nothing is withheld when originals are served as stored")
+}
+
+func TestCollectionService_SecretLink(t *testing.T) {
+	ctx := context.Background()
+	db, _ := setupTestDB(t)
+	collectionRepo := repository.NewCollectionRepository(db)
+	linkRepo := repository.NewCollectionLinkRepository(db)
+	svc := NewCollectionService(
+		collectionRepo, repository.NewCollectionPhotoRepository(db), repository.NewCollectionShareRepository(db),
+		repository.NewPhotoRepository(db), repository.NewUserRepository(db), nil, repository.NewUserPreferencesRepository(db),
+	)
+	svc.SetCollectionLinks(linkRepo)
+	links := NewCollectionLinkService(linkRepo, collectionRepo)
+
+	collection, err := svc.CreateCollection(ctx, "user-1", &models.CreateCollectionRequest{Name: "Wedding"})
+	require.NoError(t, err)
+
+	// The collection's token opens the gallery through a share link
+	shared, err := svc.UpdateVisibility(ctx, collection.ID, "user-1", string(models.VisibilitySecretLink))
+	require.NoError(t, err)
+	require.NotNil(t, shared.SecretToken)
+	first := *shared.SecretToken
+	link, opened, err := links.Open(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, collection.ID, opened.ID)
+	assert.Equal(t, models.SecretLinkLabel, link.Label)
+
+	// Setting it again keeps the token
+	shared, err = svc.UpdateVisibility(ctx, collection.ID, "user-1", string(models.VisibilitySecretLink))
+	require.NoError(t, err)
+	assert.Equal(t, first, *shared.SecretToken)
+
+	// Leaving secret_link revokes the link for good
+	private, err := svc.UpdateVisibility(ctx, collection.ID, "user-1", string(models.VisibilityPrivate))
+	require.NoError(t, err)
+	assert.Nil(t, private.SecretToken)
+	_, _, err = links.Open(ctx, first)
+	assert.Equal(t, models.ErrCollectionLinkRevoked, err)
+
+	shared, err = svc.UpdateVisibility(ctx, collection.ID, "user-1", string(models.VisibilitySecretLink))
+	require.NoError(t, err)
+	assert.NotEqual(t, first, *shared.SecretToken, "sharing again issues a new token")
+	_, _, err = links.Open(ctx, *shared.SecretToken)
+	require.NoError(t, err)
+	_, _, err = links.Open(ctx, first)
+	assert.Equal(t, models.ErrCollectionLinkRevoked, err)
+}
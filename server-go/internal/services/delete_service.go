@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"fmt"
+	"log"
 	"strings"
 
 	"github.com/photosync/server/internal/models"
@@ -14,7 +15,7 @@ type DeleteService struct {
 	userRepo          repository.UserRepo
 	deviceRepo        repository.DeviceRepo
 	deleteRequestRepo *repository.DeleteRequestRepository
-	photoRepo         repository.PhotoRepo
+	trashService      *TrashService
 	fcmService        *FCMService
 	deleteTimeout     int // seconds
 }
@@ -24,7 +25,7 @@ func NewDeleteService(
 	userRepo repository.UserRepo,
 	deviceRepo repository.DeviceRepo,
 	deleteRequestRepo *repository.DeleteRequestRepository,
-	photoRepo repository.PhotoRepo,
+	trashService *TrashService,
 	fcmService *FCMService,
 	deleteTimeout int,
 ) *DeleteService {
@@ -35,7 +36,7 @@ func NewDeleteService(
 		userRepo:          userRepo,
 		deviceRepo:        deviceRepo,
 		deleteRequestRepo: deleteRequestRepo,
-		photoRepo:         photoRepo,
+		trashService:      trashService,
 		fcmService:        fcmService,
 		deleteTimeout:     deleteTimeout,
 	}
@@ -129,8 +130,6 @@ func (s *DeleteService) CheckDeleteStatus(ctx context.Context, requestID string)
 
 // RespondToDelete handles approve/deny from mobile app
 func (s *DeleteService) RespondToDelete(ctx context.Context, requestID string, approved bool, deviceID string) error {
-	fmt.Printf("DEBUG: RespondToDelete called - requestID: %s, approved: %v, deviceID: %s\n", requestID, approved, deviceID)
-
 	deleteReq, err := s.deleteRequestRepo.GetByID(ctx, requestID)
 	if err != nil {
 		fmt.Printf("ERROR: Failed to get delete request: %v\n", err)
@@ -141,8 +140,6 @@ func (s *DeleteService) RespondToDelete(ctx context.Context, requestID string, a
 		return models.ErrDeleteRequestNotFound
 	}
 
-	fmt.Printf("DEBUG: Delete request found - status: %s, photoCount: %d\n", deleteReq.Status, len(deleteReq.PhotoIDs))
-
 	if deleteReq.Status != models.DeleteStatusPending {
 		fmt.Printf("ERROR: Delete request already resolved - status: %s\n", deleteReq.Status)
 		return models.ErrDeleteAlreadyResolved
@@ -156,31 +153,24 @@ func (s *DeleteService) RespondToDelete(ctx context.Context, requestID string, a
 	}
 
 	if approved {
-		fmt.Printf("DEBUG: Approving delete request - deleting %d photos\n", len(deleteReq.PhotoIDs))
 		deleteReq.Approve(deviceID)
 
-		// Actually delete the photos
+		// Move the photos to the trash; files are purged after the retention period
 		for _, photoID := range deleteReq.PhotoIDs {
-			// TODO: Also delete physical files from storage
-			if _, err := s.photoRepo.Delete(ctx, photoID); err != nil {
+			if err := s.trashService.MoveToTrash(ctx, photoID); err != nil {
 				// Log error but continue with other photos
-				fmt.Printf("ERROR: Failed to delete photo %s: %v\n", photoID, err)
-			} else {
-				fmt.Printf("DEBUG: Deleted photo %s from database\n", photoID)
+				log.Printf("Failed to trash photo %s: %v", photoID, err)
 			}
 		}
 	} else {
-		fmt.Printf("DEBUG: Denying delete request\n")
 		deleteReq.Deny(deviceID)
 	}
 
-	fmt.Printf("DEBUG: Updating delete request with status: %s\n", deleteReq.Status)
 	if err := s.deleteRequestRepo.Update(ctx, deleteReq); err != nil {
 		fmt.Printf("ERROR: Failed to update delete request: %v\n", err)
 		return fmt.Errorf("failed to update delete request: %w", err)
 	}
 
-	fmt.Printf("DEBUG: Delete request updated successfully\n")
 	return nil
 }
 
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd
+
+package services
+
+// freeDiskSpace cannot be determined on this platform
+func freeDiskSpace(dir string) int64 {
+	return -1
+}
@@ -0,0 +1,15 @@
+//go:build linux || darwin || freebsd
+
+package services
+
+import "syscall"
+
+// freeDiskSpace returns the bytes available to the server on the filesystem
+// holding dir, or -1 when it cannot be determined
+func freeDiskSpace(dir string) int64 {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return -1
+	}
+	return int64(uint64(st.Bavail) * uint64(st.Bsize))
+}
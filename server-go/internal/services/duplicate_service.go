@@ -0,0 +1,329 @@
+package services
+
+import (
+	"context"
+	"log"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+)
+
+const (
+	// NearDuplicateMaxDistance is the largest Hamming distance between two
+	// perceptual hashes that still counts as the same picture
+	NearDuplicateMaxDistance = 6
+
+	// perceptualHashBatchSize limits how many missing hashes are filled per run
+	perceptualHashBatchSize = 500
+)
+
+// DuplicateService finds near-duplicate photos by perceptual hash and lets
+// users resolve each group by keeping one photo and trashing the rest
+type DuplicateService struct {
+	photoRepo        repository.PhotoRepo
+	groupRepo        repository.DuplicateGroupRepo
+	userRepo         repository.UserRepo
+	thumbnailService *ThumbnailService
+	trashService     *TrashService
+}
+
+// NewDuplicateService creates a new DuplicateService
+func NewDuplicateService(
+	photoRepo repository.PhotoRepo,
+	groupRepo repository.DuplicateGroupRepo,
+	userRepo repository.UserRepo,
+	thumbnailService *ThumbnailService,
+	trashService *TrashService,
+) *DuplicateService {
+	return &DuplicateService{
+		photoRepo:        photoRepo,
+		groupRepo:        groupRepo,
+		userRepo:         userRepo,
+		thumbnailService: thumbnailService,
+		trashService:     trashService,
+	}
+}
+
+// BackfillPerceptualHashes computes hashes for photos uploaded before hashing
+// existed, using their small thumbnail. Returns the number hashed and any errors.
+func (s *DuplicateService) BackfillPerceptualHashes(ctx context.Context) (int, []string) {
+	var errors []string
+
+	photos, err := s.photoRepo.GetPhotosWithoutPerceptualHash(ctx, perceptualHashBatchSize)
+	if err != nil {
+		return 0, []string{"Failed to get photos without perceptual hash: " + err.Error()}
+	}
+
+	hashed := 0
+	for _, photo := range photos {
+		hash, err := s.thumbnailService.PerceptualHashFromThumbnail(*photo.ThumbSmall)
+		if err != nil {
+			// Store an empty hash so an unreadable thumbnail is not retried every run
+			log.Printf("Duplicates: failed to hash %s: %v", photo.ID, err)
+			hash = ""
+		}
+
+		if err := s.photoRepo.UpdatePerceptualHash(ctx, photo.ID, hash); err != nil {
+			errors = append(errors, "Failed to store perceptual hash for "+photo.ID+": "+err.Error())
+			continue
+		}
+		if hash != "" {
+			hashed++
+		}
+	}
+
+	return hashed, errors
+}
+
+// RebuildGroups regroups every user's photos by perceptual hash. Groups the
+// user already dismissed are not raised again unless new photos join them.
+// Returns the number of pending groups and any errors.
+func (s *DuplicateService) RebuildGroups(ctx context.Context) (int, []string) {
+	users, err := s.userRepo.GetAll(ctx)
+	if err != nil {
+		return 0, []string{"Failed to get users: " + err.Error()}
+	}
+
+	var errors []string
+	total := 0
+	for _, user := range users {
+		count, err := s.rebuildGroupsForUser(ctx, user.ID)
+		if err != nil {
+			errors = append(errors, "Failed to group duplicates for user "+user.ID+": "+err.Error())
+			continue
+		}
+		total += count
+	}
+
+	return total, errors
+}
+
+func (s *DuplicateService) rebuildGroupsForUser(ctx context.Context, userID string) (int, error) {
+	hashes, err := s.photoRepo.GetPerceptualHashesForUser(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	dismissed, err := s.groupRepo.GetForUser(ctx, userID, models.DuplicateGroupDismissed)
+	if err != nil {
+		return 0, err
+	}
+	existing, err := s.groupRepo.GetForUser(ctx, userID, models.DuplicateGroupPending)
+	if err != nil {
+		return 0, err
+	}
+
+	var groups []*models.DuplicateGroup
+	for _, members := range GroupNearDuplicates(hashes, NearDuplicateMaxDistance) {
+		if coveredByDismissed(members, dismissed) {
+			continue
+		}
+
+		group := models.NewDuplicateGroup(userID, members)
+		// Keep the ID of an unchanged group so clients reviewing it are not interrupted
+		for _, g := range existing {
+			if sameMembers(g.Members, members) {
+				group.ID = g.ID
+				group.CreatedAt = g.CreatedAt
+				break
+			}
+		}
+		groups = append(groups, group)
+	}
+
+	if err := s.groupRepo.ReplacePendingForUser(ctx, userID, groups); err != nil {
+		return 0, err
+	}
+	return len(groups), nil
+}
+
+// sameMembers reports whether two member lists contain the same photos
+func sameMembers(a, b []models.DuplicateGroupMember) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	ids := make(map[string]bool, len(a))
+	for _, m := range a {
+		ids[m.PhotoID] = true
+	}
+	for _, m := range b {
+		if !ids[m.PhotoID] {
+			return false
+		}
+	}
+	return true
+}
+
+// coveredByDismissed reports whether every member already sits in one dismissed group
+func coveredByDismissed(members []models.DuplicateGroupMember, dismissed []*models.DuplicateGroup) bool {
+	for _, g := range dismissed {
+		covered := true
+		for _, m := range members {
+			if !g.HasPhoto(m.PhotoID) {
+				covered = false
+				break
+			}
+		}
+		if covered {
+			return true
+		}
+	}
+	return false
+}
+
+// GroupNearDuplicates clusters photos whose hashes are within maxDistance of
+// each other (transitively). Only clusters of two or more photos are returned;
+// each member's distance is measured from the cluster's first photo.
+//
+// This compares every pair, which is fine for the hourly background job at
+// personal-library sizes.
+func GroupNearDuplicates(hashes []models.PhotoPerceptualHash, maxDistance int) [][]models.DuplicateGroupMember {
+	values := make([]uint64, 0, len(hashes))
+	entries := make([]models.PhotoPerceptualHash, 0, len(hashes))
+	for _, h := range hashes {
+		v, err := ParsePerceptualHash(h.Hash)
+		if err != nil {
+			continue
+		}
+		values = append(values, v)
+		entries = append(entries, h)
+	}
+
+	// Union-find over photo indexes
+	parent := make([]int, len(values))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := 0; i < len(values); i++ {
+		for j := i + 1; j < len(values); j++ {
+			if HammingDistance(values[i], values[j]) <= maxDistance {
+				if ri, rj := find(i), find(j); ri != rj {
+					parent[rj] = ri
+				}
+			}
+		}
+	}
+
+	// Collect clusters in input order so results are stable between runs
+	clusters := make(map[int][]int)
+	var roots []int
+	for i := range values {
+		root := find(i)
+		if _, ok := clusters[root]; !ok {
+			roots = append(roots, root)
+		}
+		clusters[root] = append(clusters[root], i)
+	}
+
+	var groups [][]models.DuplicateGroupMember
+	for _, root := range roots {
+		indexes := clusters[root]
+		if len(indexes) < 2 {
+			continue
+		}
+
+		first := values[indexes[0]]
+		members := make([]models.DuplicateGroupMember, len(indexes))
+		for k, idx := range indexes {
+			members[k] = models.DuplicateGroupMember{
+				PhotoID:  entries[idx].PhotoID,
+				Distance: HammingDistance(first, values[idx]),
+			}
+		}
+		groups = append(groups, members)
+	}
+
+	return groups
+}
+
+// ListGroups returns the user's pending groups with photo details attached.
+// Members that were deleted or trashed since the last rebuild are dropped.
+func (s *DuplicateService) ListGroups(ctx context.Context, userID string) ([]*models.DuplicateGroup, error) {
+	groups, err := s.groupRepo.GetForUser(ctx, userID, models.DuplicateGroupPending)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*models.DuplicateGroup, 0, len(groups))
+	for _, g := range groups {
+		members := make([]models.DuplicateGroupMember, 0, len(g.Members))
+		for _, m := range g.Members {
+			photo, err := s.photoRepo.GetByID(ctx, m.PhotoID)
+			if err != nil {
+				return nil, err
+			}
+			if photo == nil || photo.IsTrashed() {
+				continue
+			}
+			m.Photo = photo
+			members = append(members, m)
+		}
+		if len(members) < 2 {
+			continue
+		}
+		g.Members = members
+		result = append(result, g)
+	}
+
+	return result, nil
+}
+
+// Resolve keeps one photo of a group and moves the others to the trash.
+// Returns the IDs of the trashed photos.
+func (s *DuplicateService) Resolve(ctx context.Context, userID, groupID, keepPhotoID string) ([]string, error) {
+	group, err := s.getOwnedGroup(ctx, userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if !group.HasPhoto(keepPhotoID) {
+		return nil, models.ErrPhotoNotInGroup
+	}
+
+	trashed := []string{}
+	for _, m := range group.Members {
+		if m.PhotoID == keepPhotoID {
+			continue
+		}
+		if err := s.trashService.MoveToTrash(ctx, m.PhotoID); err != nil {
+			if err == models.ErrPhotoNotFound {
+				continue // Already deleted or trashed
+			}
+			return trashed, err
+		}
+		trashed = append(trashed, m.PhotoID)
+	}
+
+	if _, err := s.groupRepo.Delete(ctx, groupID); err != nil {
+		return trashed, err
+	}
+
+	log.Printf("Duplicate group %s resolved: kept %s, trashed %d photos", groupID, keepPhotoID, len(trashed))
+	return trashed, nil
+}
+
+// Dismiss marks a group as not duplicates so it is not raised again
+func (s *DuplicateService) Dismiss(ctx context.Context, userID, groupID string) error {
+	if _, err := s.getOwnedGroup(ctx, userID, groupID); err != nil {
+		return err
+	}
+	return s.groupRepo.UpdateStatus(ctx, groupID, models.DuplicateGroupDismissed)
+}
+
+func (s *DuplicateService) getOwnedGroup(ctx context.Context, userID, groupID string) (*models.DuplicateGroup, error) {
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil || group.UserID != userID || group.Status != models.DuplicateGroupPending {
+		return nil, models.ErrDuplicateGroupNotFound
+	}
+	return group, nil
+}
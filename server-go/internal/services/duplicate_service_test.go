@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gradientImage(width, height int, reverse bool) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := uint8((x*7 + y*3) * 255 / (width*7 + height*3))
+			if reverse {
+				v = 255 - v
+			}
+			img.Set(x, y, color.NRGBA{R: v, G: v / 2, B: 255 - v, A: 255})
+		}
+	}
+	return img
+}
+
+func TestComputeDHash(t *testing.T) {
+	original := gradientImage(400, 300, false)
+	resized := imaging.Resize(original, 120, 90, imaging.Lanczos)
+	different := gradientImage(400, 300, true)
+
+	hash := ComputeDHash(original)
+
+	assert.LessOrEqual(t, HammingDistance(hash, ComputeDHash(resized)), NearDuplicateMaxDistance)
+	assert.Greater(t, HammingDistance(hash, ComputeDHash(different)), NearDuplicateMaxDistance)
+
+	parsed, err := ParsePerceptualHash(FormatPerceptualHash(hash))
+	require.NoError(t, err)
+	assert.Equal(t, hash, parsed)
+}
+
+func TestGroupNearDuplicates(t *testing.T) {
+	hashes := []models.PhotoPerceptualHash{
+		{PhotoID: "a", Hash: "00000000000000ff"},
+		{PhotoID: "b", Hash: "00000000000000fe"}, // 1 bit from a
+		{PhotoID: "c", Hash: "ff00000000000000"}, // unrelated
+		{PhotoID: "d", Hash: "00000000000000f0"}, // 4 bits from a
+		{PhotoID: "e", Hash: "not-a-hash"},
+	}
+
+	groups := GroupNearDuplicates(hashes, 6)
+
+	require.Len(t, groups, 1)
+	assert.Equal(t, []models.DuplicateGroupMember{
+		{PhotoID: "a", Distance: 0},
+		{PhotoID: "b", Distance: 1},
+		{PhotoID: "d", Distance: 4},
+	}, groups[0])
+}
+
+func TestDuplicateService_RebuildAndResolve(t *testing.T) {
+	ctx := context.Background()
+	db, tempDir := setupTestDB(t)
+	photoRepo := repository.NewPhotoRepository(db)
+	trashService, storage := newTestTrashService(t, photoRepo, tempDir)
+	svc := NewDuplicateService(
+		photoRepo,
+		repository.NewDuplicateGroupRepository(db),
+		repository.NewUserRepository(db),
+		newTestThumbnailService(t, filepath.Join(tempDir, "photos")),
+		trashService,
+	)
+
+	keep := addTestPhoto(t, photoRepo, storage, "user-1", "dup001")
+	duplicate := addTestPhoto(t, photoRepo, storage, "user-1", "dup002")
+	other := addTestPhoto(t, photoRepo, storage, "user-2", "dup003")
+	require.NoError(t, photoRepo.UpdatePerceptualHash(ctx, keep.ID, "00000000000000ff"))
+	require.NoError(t, photoRepo.UpdatePerceptualHash(ctx, duplicate.ID, "00000000000000fe"))
+	require.NoError(t, photoRepo.UpdatePerceptualHash(ctx, other.ID, "00000000000000ff"))
+
+	count, errs := svc.RebuildGroups(ctx)
+	assert.Empty(t, errs)
+	assert.Equal(t, 1, count, "photos of different users must not be grouped")
+
+	groups, err := svc.ListGroups(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	groupID := groups[0].ID
+
+	// Rebuilding with unchanged photos keeps the group ID stable
+	_, errs = svc.RebuildGroups(ctx)
+	assert.Empty(t, errs)
+	groups, err = svc.ListGroups(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	assert.Equal(t, groupID, groups[0].ID)
+
+	_, err = svc.Resolve(ctx, "user-2", groupID, keep.ID)
+	assert.Equal(t, models.ErrDuplicateGroupNotFound, err)
+	_, err = svc.Resolve(ctx, "user-1", groupID, other.ID)
+	assert.Equal(t, models.ErrPhotoNotInGroup, err)
+
+	trashed, err := svc.Resolve(ctx, "user-1", groupID, keep.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{duplicate.ID}, trashed)
+
+	photo, err := photoRepo.GetByID(ctx, duplicate.ID)
+	require.NoError(t, err)
+	assert.True(t, photo.IsTrashed())
+
+	groups, err = svc.ListGroups(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Empty(t, groups)
+}
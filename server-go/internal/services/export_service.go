@@ -0,0 +1,281 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+)
+
+// ExportService streams a user's library as an archive of originals, each
+// with a JSON sidecar, followed by a manifest. Originals keep the Year/Month
+// layout used by storage.
+type ExportService struct {
+	photoRepo           repository.PhotoRepo
+	collectionRepo      repository.CollectionRepo
+	collectionPhotoRepo repository.CollectionPhotoRepo
+	deviceRepo          repository.DeviceRepo
+	storageService      *PhotoStorageService
+}
+
+// NewExportService creates a new ExportService
+func NewExportService(
+	photoRepo repository.PhotoRepo,
+	collectionRepo repository.CollectionRepo,
+	collectionPhotoRepo repository.CollectionPhotoRepo,
+	deviceRepo repository.DeviceRepo,
+	storageService *PhotoStorageService,
+) *ExportService {
+	return &ExportService{
+		photoRepo:           photoRepo,
+		collectionRepo:      collectionRepo,
+		collectionPhotoRepo: collectionPhotoRepo,
+		deviceRepo:          deviceRepo,
+		storageService:      storageService,
+	}
+}
+
+// Validate checks the export filters. Call it before writing response
+// headers; once Write has started, errors can only truncate the archive.
+func (s *ExportService) Validate(ctx context.Context, opts *models.ExportOptions) error {
+	if opts.CollectionID == "" {
+		return nil
+	}
+	collection, err := s.collectionRepo.GetByID(ctx, opts.CollectionID)
+	if err != nil {
+		return fmt.Errorf("failed to get collection: %w", err)
+	}
+	if collection == nil || collection.UserID != opts.UserID {
+		return models.ErrCollectionNotFound
+	}
+	return nil
+}
+
+// Write streams the export to w and returns the manifest it wrote last.
+// Photos whose original cannot be opened are skipped and listed as missing.
+func (s *ExportService) Write(ctx context.Context, w io.Writer, opts *models.ExportOptions) (*models.ExportManifest, error) {
+	memberships, err := s.collectionMemberships(ctx, opts.UserID)
+	if err != nil {
+		return nil, err
+	}
+	devices, err := s.devices(ctx, opts.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var archive exportArchive
+	if opts.Format == models.ExportFormatTar {
+		archive = &tarExportArchive{tw: tar.NewWriter(w)}
+	} else {
+		archive = &zipExportArchive{zw: zip.NewWriter(w)}
+	}
+
+	manifest := &models.ExportManifest{
+		UserID:       opts.UserID,
+		ExportedAt:   time.Now().UTC(),
+		DateFrom:     opts.DateFrom,
+		DateTo:       opts.DateTo,
+		CollectionID: opts.CollectionID,
+		Photos:       []models.ExportManifestEntry{},
+		Missing:      []string{},
+	}
+	usedPaths := make(map[string]bool)
+
+	query := &models.PhotoSearchQuery{
+		UserID:       opts.UserID,
+		DateFrom:     opts.DateFrom,
+		DateTo:       opts.DateTo,
+		CollectionID: opts.CollectionID,
+		Limit:        models.MaxSearchLimit,
+	}
+	for {
+		photos, nextCursor, err := s.photoRepo.Search(ctx, query)
+		if err != nil {
+			return manifest, fmt.Errorf("failed to list photos: %w", err)
+		}
+
+		for _, photo := range photos {
+			if err := ctx.Err(); err != nil {
+				return manifest, err
+			}
+
+			sidecar := models.NewPhotoSidecar(photo)
+			if refs := memberships[photo.ID]; refs != nil {
+				sidecar.Collections = refs
+			}
+			if photo.OriginDeviceID != nil {
+				sidecar.OriginDevice = devices[*photo.OriginDeviceID]
+				if sidecar.OriginDevice == nil {
+					sidecar.OriginDevice = &models.ExportDeviceRef{ID: *photo.OriginDeviceID}
+				}
+			}
+
+			entry, err := s.writePhoto(ctx, archive, photo, sidecar, usedPaths)
+			if err != nil {
+				return manifest, err
+			}
+			if entry == nil {
+				manifest.Missing = append(manifest.Missing, photo.ID)
+				continue
+			}
+			manifest.Photos = append(manifest.Photos, *entry)
+			manifest.Count++
+			manifest.TotalSize += entry.FileSize
+		}
+
+		if nextCursor == "" {
+			break
+		}
+		query.Cursor = nextCursor
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	if err := archive.add(models.ExportManifestName, int64(len(data)), manifest.ExportedAt, true, bytes.NewReader(data)); err != nil {
+		return manifest, err
+	}
+	return manifest, archive.close()
+}
+
+// writePhoto adds one original and its sidecar. It returns nil without an
+// error when the original cannot be opened, so the export can carry on.
+func (s *ExportService) writePhoto(ctx context.Context, archive exportArchive, photo *models.Photo, sidecar *models.PhotoSidecar, usedPaths map[string]bool) (*models.ExportManifestEntry, error) {
+	src, info, err := s.storageService.Open(ctx, photo.StoredPath)
+	if err != nil {
+		log.Printf("Export: skipping photo %s, original %s unavailable: %v", photo.ID, photo.StoredPath, err)
+		return nil, nil
+	}
+	defer src.Close()
+
+	name := exportPath(photo, usedPaths)
+	if err := archive.add(name, info.Size, photo.DateTaken, false, src); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", name, err)
+	}
+
+	data, err := json.MarshalIndent(sidecar, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	sidecarName := name + ".json"
+	if err := archive.add(sidecarName, int64(len(data)), photo.DateTaken, true, bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", sidecarName, err)
+	}
+
+	return &models.ExportManifestEntry{
+		PhotoID:  photo.ID,
+		Path:     name,
+		Sidecar:  sidecarName,
+		FileHash: photo.FileHash,
+		FileSize: info.Size,
+	}, nil
+}
+
+// collectionMemberships maps each photo ID to the user's collections that contain it
+func (s *ExportService) collectionMemberships(ctx context.Context, userID string) (map[string][]models.ExportCollectionRef, error) {
+	collections, err := s.collectionRepo.GetAllForUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collections: %w", err)
+	}
+
+	memberships := make(map[string][]models.ExportCollectionRef)
+	for _, collection := range collections {
+		entries, err := s.collectionPhotoRepo.GetByCollectionID(ctx, collection.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get collection photos: %w", err)
+		}
+		ref := models.ExportCollectionRef{ID: collection.ID, Name: collection.Name}
+		for _, entry := range entries {
+			memberships[entry.PhotoID] = append(memberships[entry.PhotoID], ref)
+		}
+	}
+	return memberships, nil
+}
+
+// devices maps the user's device IDs to their names
+func (s *ExportService) devices(ctx context.Context, userID string) (map[string]*models.ExportDeviceRef, error) {
+	devices, err := s.deviceRepo.GetAllForUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get devices: %w", err)
+	}
+
+	refs := make(map[string]*models.ExportDeviceRef, len(devices))
+	for _, d := range devices {
+		refs[d.ID] = &models.ExportDeviceRef{ID: d.ID, Name: d.DeviceName, Platform: d.Platform}
+	}
+	return refs, nil
+}
+
+// exportPath places a photo under Year/Month/ by the date it was taken,
+// numbering repeated names the same way storage does
+func exportPath(photo *models.Photo, used map[string]bool) string {
+	folder := path.Join(photo.DateTaken.Format("2006"), photo.DateTaken.Format("01"))
+	filename := path.Base(strings.ReplaceAll(photo.OriginalFilename, "\\", "/"))
+	if filename == "." || filename == "/" || filename == ".." {
+		filename = photo.ID + path.Ext(photo.StoredPath)
+	}
+
+	ext := path.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+	name := path.Join(folder, filename)
+	for counter := 1; used[strings.ToLower(name)]; counter++ {
+		name = path.Join(folder, fmt.Sprintf("%s_%03d%s", base, counter, ext))
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+// exportArchive is the part of the zip and tar writers an export needs
+type exportArchive interface {
+	add(name string, size int64, modTime time.Time, compress bool, r io.Reader) error
+	close() error
+}
+
+type zipExportArchive struct {
+	zw *zip.Writer
+}
+
+func (a *zipExportArchive) add(name string, size int64, modTime time.Time, compress bool, r io.Reader) error {
+	// Photos and videos are already compressed; deflating them again only costs CPU
+	method := zip.Store
+	if compress {
+		method = zip.Deflate
+	}
+	fw, err := a.zw.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: modTime})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, r)
+	return err
+}
+
+func (a *zipExportArchive) close() error {
+	return a.zw.Close()
+}
+
+type tarExportArchive struct {
+	tw *tar.Writer
+}
+
+func (a *tarExportArchive) add(name string, size int64, modTime time.Time, compress bool, r io.Reader) error {
+	if err := a.tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: size, ModTime: modTime}); err != nil {
+		return err
+	}
+	_, err := io.CopyN(a.tw, r, size)
+	return err
+}
+
+func (a *tarExportArchive) close() error {
+	return a.tw.Close()
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportService_Write(t *testing.T) {
+	ctx := context.Background()
+	db, tempDir := setupTestDB(t)
+	photoRepo := repository.NewPhotoRepository(db)
+	collectionRepo := repository.NewCollectionRepository(db)
+	collectionPhotoRepo := repository.NewCollectionPhotoRepository(db)
+	deviceRepo := repository.NewDeviceRepository(db)
+	storage := newTestStorage(t, tempDir)
+	svc := NewExportService(photoRepo, collectionRepo, collectionPhotoRepo, deviceRepo, storage)
+
+	device, err := models.NewDevice("user-1", "Pixel", "android", "token-1")
+	require.NoError(t, err)
+	require.NoError(t, deviceRepo.Add(ctx, device))
+
+	recent := addTestPhoto(t, photoRepo, storage, "user-1", "aaa111")
+	recent.OriginDeviceID = &device.ID
+	recent.DateTaken = time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, photoRepo.Update(ctx, recent))
+
+	// Same original name as recent, taken earlier in the same month
+	sameName := addTestPhoto(t, photoRepo, storage, "user-1", "bbb222")
+	sameName.OriginalFilename = recent.OriginalFilename
+	sameName.DateTaken = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, photoRepo.Update(ctx, sameName))
+
+	old := addTestPhoto(t, photoRepo, storage, "user-1", "ccc333")
+	old.DateTaken = time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, photoRepo.Update(ctx, old))
+
+	lost := addTestPhoto(t, photoRepo, storage, "user-1", "ddd444")
+	require.True(t, storage.Delete(lost.StoredPath))
+
+	addTestPhoto(t, photoRepo, storage, "user-2", "eee555")
+
+	collection, err := models.NewCollection("user-1", "Holiday")
+	require.NoError(t, err)
+	require.NoError(t, collectionRepo.Add(ctx, collection))
+	for i, id := range []string{recent.ID, old.ID} {
+		require.NoError(t, collectionPhotoRepo.Add(ctx, models.NewCollectionPhoto(collection.ID, id, i)))
+	}
+
+	var buf bytes.Buffer
+	manifest, err := svc.Write(ctx, &buf, &models.ExportOptions{UserID: "user-1", Format: models.ExportFormatZip})
+	require.NoError(t, err)
+	assert.Equal(t, 3, manifest.Count)
+	assert.Equal(t, []string{lost.ID}, manifest.Missing)
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		files[f.Name], err = io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+	}
+
+	month := "2024/03/"
+	assert.Equal(t, []byte("photo aaa111"), files[month+"aaa111.jpg"])
+	assert.Equal(t, []byte("photo bbb222"), files[month+"aaa111_001.jpg"], "repeated names are numbered")
+	assert.Equal(t, []byte("photo ccc333"), files["2020/05/ccc333.jpg"])
+
+	var sidecar models.PhotoSidecar
+	require.NoError(t, json.Unmarshal(files[month+"aaa111.jpg.json"], &sidecar))
+	assert.Equal(t, recent.FileHash, sidecar.FileHash)
+	assert.Equal(t, []models.ExportCollectionRef{{ID: collection.ID, Name: "Holiday"}}, sidecar.Collections)
+	require.NotNil(t, sidecar.OriginDevice)
+	assert.Equal(t, "Pixel", sidecar.OriginDevice.Name)
+
+	var written models.ExportManifest
+	require.NoError(t, json.Unmarshal(files[models.ExportManifestName], &written))
+	assert.Len(t, written.Photos, 3)
+
+	// Filters combine: the collection's photos taken in 2020
+	to := time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC)
+	opts := &models.ExportOptions{UserID: "user-1", Format: models.ExportFormatTar, DateTo: &to, CollectionID: collection.ID}
+	require.NoError(t, svc.Validate(ctx, opts))
+	buf.Reset()
+	manifest, err = svc.Write(ctx, &buf, opts)
+	require.NoError(t, err)
+	require.Equal(t, 1, manifest.Count)
+
+	var names []string
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+	}
+	assert.Equal(t, []string{"2020/05/ccc333.jpg", "2020/05/ccc333.jpg.json", models.ExportManifestName}, names)
+
+	assert.Equal(t, models.ErrCollectionNotFound, svc.Validate(ctx, &models.ExportOptions{UserID: "user-2", CollectionID: collection.ID}))
+}
@@ -2,14 +2,15 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/photosync/server/internal/blobstore"
 	"github.com/photosync/server/internal/models"
 	"github.com/photosync/server/internal/repository"
 )
@@ -35,7 +36,7 @@ type FileScannerService struct {
 	fileConflictRepo repository.FileConflictRepo
 	metadataService  *MetadataService
 	hashService      *HashService
-	storagePath      string
+	store            blobstore.BlobStore
 	intervalHours    int
 	wsHub            *WebSocketHub
 
@@ -54,7 +55,7 @@ func NewFileScannerService(
 	fileConflictRepo repository.FileConflictRepo,
 	metadataService *MetadataService,
 	hashService *HashService,
-	storagePath string,
+	store blobstore.BlobStore,
 	intervalHours int,
 ) *FileScannerService {
 	if intervalHours <= 0 {
@@ -67,7 +68,7 @@ func NewFileScannerService(
 		fileConflictRepo: fileConflictRepo,
 		metadataService:  metadataService,
 		hashService:      hashService,
-		storagePath:      storagePath,
+		store:            store,
 		intervalHours:    intervalHours,
 		stopChan:         make(chan struct{}),
 		enabled:          true,
@@ -228,22 +229,14 @@ func (s *FileScannerService) runScan() {
 
 	// First pass: count total files
 	totalFiles := 0
-	filepath.Walk(s.storagePath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
-		}
-		if info.IsDir() {
-			// Skip .thumbs and hidden directories
-			if strings.HasPrefix(info.Name(), ".") {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-		if s.isImageFile(info.Name()) {
+	if err := s.store.List(ctx, "", func(info blobstore.ObjectInfo) error {
+		if !isHiddenKey(info.Key) && s.isImageFile(info.Key) {
 			totalFiles++
 		}
 		return nil
-	})
+	}); err != nil {
+		log.Printf("File scan: failed to list storage: %v", err)
+	}
 
 	// Second pass: scan files
 	filesScanned := 0
@@ -251,40 +244,21 @@ func (s *FileScannerService) runScan() {
 	conflictsFound := 0
 	var errors []string
 
-	filepath.Walk(s.storagePath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			errors = append(errors, "Walk error: "+err.Error())
-			return nil
-		}
-
-		// Skip directories
-		if info.IsDir() {
-			// Skip .thumbs and hidden directories
-			if strings.HasPrefix(info.Name(), ".") {
-				return filepath.SkipDir
-			}
+	listErr := s.store.List(ctx, "", func(info blobstore.ObjectInfo) error {
+		// Skip .thumbs and hidden directories
+		if isHiddenKey(info.Key) {
 			return nil
 		}
 
 		// Skip non-image files
-		if !s.isImageFile(info.Name()) {
+		if !s.isImageFile(info.Key) {
 			return nil
 		}
 
-		// Get relative path from storage base
-		relPath, err := filepath.Rel(s.storagePath, path)
-		if err != nil {
-			errors = append(errors, "Path error for "+path+": "+err.Error())
-			return nil
-		}
-
-		// Skip thumbnails
-		if strings.Contains(relPath, ".thumbs") {
-			return nil
-		}
+		relPath := info.Key
 
 		// Process the file
-		isOrphan, isConflict, scanErr := s.processFile(ctx, path, relPath, info)
+		isOrphan, isConflict, scanErr := s.processFile(ctx, relPath, info.Size)
 		if scanErr != nil {
 			errors = append(errors, "Scan error for "+relPath+": "+scanErr.Error())
 		}
@@ -314,6 +288,9 @@ func (s *FileScannerService) runScan() {
 
 		return nil
 	})
+	if listErr != nil {
+		errors = append(errors, "List error: "+listErr.Error())
+	}
 
 	duration := time.Since(startTime)
 
@@ -341,7 +318,7 @@ func (s *FileScannerService) runScan() {
 }
 
 // processFile scans a single file and checks for orphans/conflicts
-func (s *FileScannerService) processFile(ctx context.Context, fullPath, relPath string, info os.FileInfo) (isOrphan bool, isConflict bool, err error) {
+func (s *FileScannerService) processFile(ctx context.Context, relPath string, size int64) (isOrphan bool, isConflict bool, err error) {
 	// Check if this path is already in orphan_files
 	existingOrphan, err := s.orphanFileRepo.GetByPath(ctx, relPath)
 	if err != nil {
@@ -353,7 +330,7 @@ func (s *FileScannerService) processFile(ctx context.Context, fullPath, relPath
 	}
 
 	// Compute file hash
-	file, err := os.Open(fullPath)
+	file, _, err := s.store.Get(ctx, relPath)
 	if err != nil {
 		return false, false, err
 	}
@@ -372,7 +349,7 @@ func (s *FileScannerService) processFile(ctx context.Context, fullPath, relPath
 
 	if photo == nil {
 		// File not in database - this is an orphan
-		return s.createOrphanRecord(ctx, relPath, info.Size(), fileHash)
+		return s.createOrphanRecord(ctx, relPath, size, fileHash)
 	}
 
 	// File exists in database - check for conflicts
@@ -504,6 +481,17 @@ func (s *FileScannerService) checkForConflicts(ctx context.Context, photo *model
 	return false, conflictsCreated, nil
 }
 
+// isHiddenKey reports whether a key lies in a hidden directory such as .thumbs
+// or .uploads, or is itself a hidden file
+func isHiddenKey(key string) bool {
+	for _, part := range strings.Split(key, "/") {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
+
 // isImageFile checks if a filename has an image extension
 func (s *FileScannerService) isImageFile(filename string) bool {
 	ext := strings.ToLower(filepath.Ext(filename))
@@ -530,27 +518,19 @@ func (s *FileScannerService) isImageFile(filename string) bool {
 // ScanSingleFile scans a single file by its relative path and returns the result
 func (s *FileScannerService) ScanSingleFile(relPath string) (*SingleFileScanResult, error) {
 	ctx := context.Background()
-	fullPath := filepath.Join(s.storagePath, relPath)
 
 	// Check if file exists
-	info, err := os.Stat(fullPath)
+	file, info, err := s.store.Get(ctx, relPath)
 	if err != nil {
 		return nil, err
 	}
-	if info.IsDir() {
-		return nil, fmt.Errorf("path is a directory, not a file")
-	}
 
 	result := &SingleFileScanResult{
 		FilePath: relPath,
-		FileSize: info.Size(),
+		FileSize: info.Size,
 	}
 
 	// Compute hash
-	file, err := os.Open(fullPath)
-	if err != nil {
-		return nil, err
-	}
 	defer file.Close()
 
 	fileHash, err := s.hashService.ComputeHash(file)
@@ -629,12 +609,10 @@ func (s *FileScannerService) VerifyFileIntegrity(photoID string) (*IntegrityResu
 		return nil, fmt.Errorf("photo not found")
 	}
 
-	fullPath := filepath.Join(s.storagePath, photo.StoredPath)
-
 	// Check if file exists
-	info, err := os.Stat(fullPath)
+	file, info, err := s.store.Get(ctx, photo.StoredPath)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if errors.Is(err, blobstore.ErrNotExist) {
 			return &IntegrityResult{
 				PhotoID:      photoID,
 				StoredPath:   photo.StoredPath,
@@ -651,16 +629,12 @@ func (s *FileScannerService) VerifyFileIntegrity(photoID string) (*IntegrityResu
 	result := &IntegrityResult{
 		PhotoID:     photoID,
 		StoredPath:  photo.StoredPath,
-		FileSize:    info.Size(),
+		FileSize:    info.Size,
 		DBHash:      photo.FileHash,
 		FileExists:  true,
 	}
 
 	// Compute current file hash
-	file, err := os.Open(fullPath)
-	if err != nil {
-		return nil, err
-	}
 	defer file.Close()
 
 	currentHash, err := s.hashService.ComputeHash(file)
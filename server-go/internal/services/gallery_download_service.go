@@ -0,0 +1,181 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+)
+
+// GalleryDownloadService streams the photos of a gallery as one ZIP, in the
+// collection's order. Files are copied straight from storage into the
+// response, so memory use stays flat however large the collection is.
+type GalleryDownloadService struct {
+	collectionPhotoRepo repository.CollectionPhotoRepo
+	storageService      *PhotoStorageService
+	thumbnailService    *ThumbnailService
+	now                 func() time.Time
+
+	// Downloads started per IP address in the current hour
+	mu        sync.Mutex
+	perHour   int // 0 means no limit
+	windows   map[string]*downloadWindow
+	lastPrune time.Time
+}
+
+type downloadWindow struct {
+	start time.Time
+	count int
+}
+
+// NewGalleryDownloadService creates a new GalleryDownloadService
+func NewGalleryDownloadService(
+	collectionPhotoRepo repository.CollectionPhotoRepo,
+	storageService *PhotoStorageService,
+	thumbnailService *ThumbnailService,
+) *GalleryDownloadService {
+	return &GalleryDownloadService{
+		collectionPhotoRepo: collectionPhotoRepo,
+		storageService:      storageService,
+		thumbnailService:    thumbnailService,
+		now:                 func() time.Time { return time.Now().UTC() },
+		windows:             make(map[string]*downloadWindow),
+	}
+}
+
+// SetRateLimit caps the downloads each IP address may start per hour. Zero
+// turns the limit off.
+func (s *GalleryDownloadService) SetRateLimit(perHour int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.perHour = perHour
+}
+
+// Check reports whether a visitor may download a collection now, and counts
+// the download against their IP address if so. Call it before writing
+// response headers.
+func (s *GalleryDownloadService) Check(collection *models.Collection, ip string) error {
+	if !collection.AllowDownloads {
+		return models.ErrGalleryDownloadsDisabled
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.perHour <= 0 {
+		return nil
+	}
+
+	now := s.now()
+	if now.Sub(s.lastPrune) >= time.Hour {
+		for key, w := range s.windows {
+			if now.Sub(w.start) >= time.Hour {
+				delete(s.windows, key)
+			}
+		}
+		s.lastPrune = now
+	}
+
+	w := s.windows[ip]
+	if w == nil || now.Sub(w.start) >= time.Hour {
+		w = &downloadWindow{start: now}
+		s.windows[ip] = w
+	}
+	if w.count >= s.perHour {
+		return models.ErrGalleryDownloadRateLimited
+	}
+	w.count++
+	return nil
+}
+
+// Write streams the collection's photos to w as a ZIP and returns how many it
+// wrote. Photos whose file cannot be opened are left out.
+func (s *GalleryDownloadService) Write(ctx context.Context, w io.Writer, collection *models.Collection, size models.GalleryDownloadSize) (int, error) {
+	photos, err := s.collectionPhotoRepo.GetPhotosForCollection(ctx, collection.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get photos: %w", err)
+	}
+
+	archive := &zipExportArchive{zw: zip.NewWriter(w)}
+	// Numbering the files keeps the collection's order in file managers
+	digits := len(strconv.Itoa(len(photos)))
+	written := 0
+	for i, photo := range photos {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+
+		file, ext, err := s.open(ctx, photo, size, collection.MetadataStripping)
+		if err != nil {
+			log.Printf("Gallery download: skipping photo %s of collection %s: %v", photo.ID, collection.ID, err)
+			continue
+		}
+		name := fmt.Sprintf("%0*d_%s", digits, i+1, galleryDownloadName(photo, ext))
+		err = archive.add(name, 0, photo.DateTaken, false, file)
+		file.Close()
+		if err != nil {
+			return written, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		written++
+	}
+	return written, archive.close()
+}
+
+// open opens the file of a photo to download and returns the extension of a
+// thumbnail or converted original, or "" for the original as stored. A
+// missing thumbnail falls back to the original, as the gallery itself does,
+// with the collection's choice of metadata removed.
+func (s *GalleryDownloadService) open(ctx context.Context, photo *models.Photo, size models.GalleryDownloadSize, strip models.MetadataStripping) (io.ReadCloser, string, error) {
+	if thumbPath := size.Thumbnail(photo); thumbPath != nil && *thumbPath != "" && s.thumbnailService != nil {
+		if file, _, err := s.thumbnailService.OpenThumbnail(ctx, *thumbPath); err == nil {
+			return file, path.Ext(*thumbPath), nil
+		}
+	}
+
+	if strip.Strips() {
+		if s.thumbnailService == nil {
+			return nil, "", fmt.Errorf("cannot strip metadata without the thumbnail service")
+		}
+		strippedPath, err := s.thumbnailService.GetStrippedRendition(photo.ID, photo.StoredPath, photo.Orientation, strip)
+		if err != nil {
+			return nil, "", err
+		}
+		file, _, err := s.thumbnailService.OpenThumbnail(ctx, strippedPath)
+		if err != nil {
+			return nil, "", err
+		}
+		ext := ""
+		if !strings.EqualFold(path.Ext(strippedPath), path.Ext(photo.StoredPath)) {
+			ext = path.Ext(strippedPath)
+		}
+		return file, ext, nil
+	}
+
+	file, _, err := s.storageService.Open(ctx, photo.StoredPath)
+	if err != nil {
+		return nil, "", err
+	}
+	return file, "", nil
+}
+
+// galleryDownloadName is the photo's original filename, with the extension
+// of the file actually downloaded
+func galleryDownloadName(photo *models.Photo, ext string) string {
+	filename := path.Base(strings.ReplaceAll(photo.OriginalFilename, "\\", "/"))
+	if filename == "." || filename == "/" || filename == ".." {
+		filename = photo.ID + path.Ext(photo.StoredPath)
+	}
+	base := strings.TrimSuffix(filename, path.Ext(filename))
+	if ext == "" {
+		ext = path.Ext(filename)
+	}
+	return base + ext
+}
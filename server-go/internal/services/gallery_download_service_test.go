@@ -0,0 +1,110 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGalleryDownloadService(t *testing.T) {
+	ctx := context.Background()
+	db, tempDir := setupTestDB(t)
+	photoRepo := repository.NewPhotoRepository(db)
+	collectionRepo := repository.NewCollectionRepository(db)
+	collectionPhotoRepo := repository.NewCollectionPhotoRepository(db)
+	storage := newTestStorage(t, tempDir)
+	svc := NewGalleryDownloadService(collectionPhotoRepo, storage, newTestThumbnailService(t, filepath.Join(tempDir, "photos")))
+
+	first := addTestPhoto(t, photoRepo, storage, "user-1", "aaa111")
+	second := addTestPhoto(t, photoRepo, storage, "user-1", "bbb222")
+	missing := addTestPhoto(t, photoRepo, storage, "user-1", "ccc333")
+	require.NoError(t, os.Remove(filepath.Join(tempDir, "photos", missing.StoredPath)))
+
+	// Only the second photo has a medium thumbnail
+	thumb := filepath.Join(".thumbs", second.ID+"_medium.jpg")
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "photos", ".thumbs"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "photos", thumb), []byte("medium bbb222"), 0644))
+	_, err := db.Exec(`UPDATE photos SET thumb_medium = $1 WHERE id = $2`, thumb, second.ID)
+	require.NoError(t, err)
+
+	collection, err := models.NewCollection("user-1", "Picnic")
+	require.NoError(t, err)
+	require.NoError(t, collectionRepo.Add(ctx, collection))
+	for i, id := range []string{second.ID, missing.ID, first.ID} {
+		require.NoError(t, collectionPhotoRepo.Add(ctx, models.NewCollectionPhoto(collection.ID, id, i)))
+	}
+
+	assert.Equal(t, models.ErrGalleryDownloadsDisabled, svc.Check(collection, "10.0.0.1"))
+	collection.AllowDownloads = true
+	require.NoError(t, svc.Check(collection, "10.0.0.1"))
+	// The test photos are not real images, so they are served as stored
+	collection.MetadataStripping = models.StripNone
+
+	download := func(size models.GalleryDownloadSize) map[string]string {
+		var buf bytes.Buffer
+		count, err := svc.Write(ctx, &buf, collection, size)
+		require.NoError(t, err)
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+		assert.Len(t, zr.File, count)
+
+		files := make(map[string]string)
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			require.NoError(t, err)
+			data, err := io.ReadAll(rc)
+			rc.Close()
+			require.NoError(t, err)
+			files[f.Name] = string(data)
+		}
+		return files
+	}
+
+	// Originals in collection order, skipping the one that is gone
+	assert.Equal(t, map[string]string{
+		"1_bbb222.jpg": "photo bbb222",
+		"3_aaa111.jpg": "photo aaa111",
+	}, download(models.GalleryDownloadOriginal))
+
+	// Thumbnails where there are any, originals otherwise
+	assert.Equal(t, map[string]string{
+		"1_bbb222.jpg": "medium bbb222",
+		"3_aaa111.jpg": "photo aaa111",
+	}, download(models.GalleryDownloadMedium))
+
+	// Originals whose metadata cannot be removed are left out, never sent as stored
+	collection.MetadataStripping = models.StripLocation
+	assert.Equal(t, map[string]string{
+		"1_bbb222.jpg": "medium bbb222",
+	}, download(models.GalleryDownloadMedium))
+
+	_, err = models.ParseGalleryDownloadSize("huge")
+	assert.Equal(t, models.ErrGalleryDownloadInvalidSize, err)
+}
+
+func TestGalleryDownloadService_RateLimit(t *testing.T) {
+	svc := NewGalleryDownloadService(nil, nil, nil)
+	now := time.Now().UTC()
+	svc.now = func() time.Time { return now }
+	collection := &models.Collection{ID: "c1", AllowDownloads: true}
+
+	require.NoError(t, svc.Check(collection, "10.0.0.1"), "no limit by default")
+	svc.SetRateLimit(2)
+	require.NoError(t, svc.Check(collection, "10.0.0.2"))
+	require.NoError(t, svc.Check(collection, "10.0.0.2"))
+	assert.Equal(t, models.ErrGalleryDownloadRateLimited, svc.Check(collection, "10.0.0.2"))
+	require.NoError(t, svc.Check(collection, "10.0.0.3"), "each address has its own allowance")
+
+	now = now.Add(time.Hour)
+	require.NoError(t, svc.Check(collection, "10.0.0.2"))
+}
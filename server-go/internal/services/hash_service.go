@@ -6,6 +6,8 @@ import (
 	"io"
 	"regexp"
 	"strings"
+
+	"github.com/photosync/server/internal/models"
 )
 
 // HashService handles file hashing
@@ -56,3 +58,27 @@ func (s *HashService) IsValidHash(hash string) bool {
 	normalized := s.NormalizeHash(hash)
 	return s.sha256Regex.MatchString(normalized)
 }
+
+// ValidateExpected checks the format of a client-asserted hash before any
+// content arrives. An empty hash means the client asserted nothing.
+func (s *HashService) ValidateExpected(expected string) error {
+	if strings.TrimSpace(expected) == "" || s.IsValidHash(expected) {
+		return nil
+	}
+	return models.ErrInvalidContentHash
+}
+
+// Verify compares the hash computed over received content with the one the
+// client asserted. An empty expected hash skips the check.
+func (s *HashService) Verify(expected, actual string) error {
+	if strings.TrimSpace(expected) == "" {
+		return nil
+	}
+	if err := s.ValidateExpected(expected); err != nil {
+		return err
+	}
+	if s.NormalizeHash(expected) != s.NormalizeHash(actual) {
+		return models.ErrHashMismatch
+	}
+	return nil
+}
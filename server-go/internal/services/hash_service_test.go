@@ -5,6 +5,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/photosync/server/internal/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -111,3 +112,14 @@ func TestHashService_NormalizeHash(t *testing.T) {
 		assert.Equal(t, expected, result)
 	})
 }
+
+func TestHashService_Verify(t *testing.T) {
+	svc := NewHashService()
+	actual := svc.ComputeHashBytes([]byte("photo bytes"))
+
+	assert.NoError(t, svc.Verify("", actual), "no assertion means no check")
+	assert.NoError(t, svc.Verify(actual, actual))
+	assert.NoError(t, svc.Verify("SHA256:"+strings.ToUpper(actual), actual))
+	assert.Equal(t, models.ErrHashMismatch, svc.Verify(svc.ComputeHashBytes([]byte("photo")), actual))
+	assert.Equal(t, models.ErrInvalidContentHash, svc.Verify("abc123", actual))
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"encoding/json"
+	"html"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// importMetadata is what an import takes from a media file's sidecar
+type importMetadata struct {
+	DateTaken   *time.Time
+	Description string
+	Latitude    *float64
+	Longitude   *float64
+	Altitude    *float64
+}
+
+// importIndex is built in a first pass over an import. It knows which
+// entries are media, the metadata of every sidecar, and album titles from
+// Takeout's per-folder metadata.json.
+type importIndex struct {
+	storageService *PhotoStorageService
+	media          map[string]bool
+	sidecars       map[string]*importMetadata // Keyed by lower-cased entry name
+	albumTitles    map[string]string          // Folder -> album title
+}
+
+func newImportIndex(storageService *PhotoStorageService) *importIndex {
+	return &importIndex{
+		storageService: storageService,
+		media:          make(map[string]bool),
+		sidecars:       make(map[string]*importMetadata),
+		albumTitles:    make(map[string]string),
+	}
+}
+
+// add records one entry. Unreadable or unrelated sidecars are ignored:
+// Takeout ships other JSON files alongside the photo metadata.
+func (idx *importIndex) add(entry importEntry) error {
+	for _, part := range strings.Split(entry.Name, "/") {
+		if isHiddenImportName(part) {
+			return nil
+		}
+	}
+
+	lower := strings.ToLower(entry.Name)
+	switch {
+	case strings.HasSuffix(lower, ".json"), strings.HasSuffix(lower, ".xmp"):
+		if entry.Size > maxImportSidecarSize {
+			return nil
+		}
+		data, err := readImportEntry(entry)
+		if err != nil {
+			return nil
+		}
+		if path.Base(lower) == "metadata.json" {
+			var album struct {
+				Title string `json:"title"`
+			}
+			if json.Unmarshal(data, &album) == nil && strings.TrimSpace(album.Title) != "" {
+				idx.albumTitles[path.Dir(entry.Name)] = strings.TrimSpace(album.Title)
+				return nil
+			}
+		}
+		var meta *importMetadata
+		if strings.HasSuffix(lower, ".json") {
+			meta = parseTakeoutSidecar(data)
+		} else {
+			meta = parseXMPSidecar(data)
+		}
+		if meta != nil {
+			idx.sidecars[lower] = meta
+		}
+	case idx.storageService.ValidateUpload(entry.Name, 0) == nil:
+		idx.media[entry.Name] = true
+	}
+	return nil
+}
+
+// metadata returns the sidecar metadata for a media entry, or nil
+func (idx *importIndex) metadata(name string) *importMetadata {
+	for _, candidate := range sidecarCandidates(name) {
+		if meta := idx.sidecars[strings.ToLower(candidate)]; meta != nil {
+			return meta
+		}
+	}
+	return nil
+}
+
+// nonAlbumFolder matches folders that organise an export rather than name an album
+var nonAlbumFolder = regexp.MustCompile(`(?i)^(takeout|google photos|photos from \d{4}|trash|bin|archive)$`)
+
+// album returns the album a media entry belongs to, or "" if none. The
+// album is the entry's folder, titled by its metadata.json when present.
+func (idx *importIndex) album(name string) string {
+	dir := path.Dir(name)
+	if dir == "." {
+		return ""
+	}
+	if title := idx.albumTitles[dir]; title != "" {
+		return title
+	}
+	folder := path.Base(dir)
+	if nonAlbumFolder.MatchString(folder) {
+		return ""
+	}
+	return folder
+}
+
+// takeoutNumbered splits Takeout's "IMG_1234(1)" into name and "(1)"
+var takeoutNumbered = regexp.MustCompile(`^(.*)(\(\d+\))$`)
+
+// sidecarCandidates lists the names a media file's sidecar may have, most
+// specific first. Takeout writes "IMG.jpg.supplemental-metadata.json" (older
+// exports "IMG.jpg.json") and truncates long names. It numbers repeats as
+// "IMG(1).jpg" with "IMG.jpg.supplemental-metadata(1).json", and gives
+// "IMG-edited.jpg" the original's sidecar. Apple Photos writes "IMG.xmp"
+// next to the original.
+func sidecarCandidates(name string) []string {
+	dir, base := path.Split(name)
+	ext := path.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+
+	names := []string{
+		takeoutSidecarName(base + ".supplemental-metadata"),
+		takeoutSidecarName(base),
+	}
+	if m := takeoutNumbered.FindStringSubmatch(stem); m != nil {
+		names = append(names,
+			strings.TrimSuffix(takeoutSidecarName(m[1]+ext+".supplemental-metadata"), ".json")+m[2]+".json",
+			m[1]+ext+m[2]+".json",
+		)
+	}
+	if original := strings.TrimSuffix(stem, "-edited"); original != stem {
+		names = append(names,
+			takeoutSidecarName(original+ext+".supplemental-metadata"),
+			takeoutSidecarName(original+ext),
+		)
+	}
+	names = append(names, stem+".json", stem+".xmp", base+".xmp")
+
+	for i := range names {
+		names[i] = dir + names[i]
+	}
+	return names
+}
+
+// takeoutSidecarName appends ".json" the way Takeout does, cutting the name
+// so the whole stays within 51 characters
+func takeoutSidecarName(name string) string {
+	if runes := []rune(name); len(runes) > 46 {
+		name = string(runes[:46])
+	}
+	return name + ".json"
+}
+
+// takeoutSidecar is the part of a Google Takeout photo sidecar an import uses
+type takeoutSidecar struct {
+	Description    string `json:"description"`
+	PhotoTakenTime *struct {
+		Timestamp string `json:"timestamp"`
+	} `json:"photoTakenTime"`
+	GeoData     *takeoutGeoData `json:"geoData"`
+	GeoDataExif *takeoutGeoData `json:"geoDataExif"`
+}
+
+type takeoutGeoData struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude"`
+}
+
+// parseTakeoutSidecar reads a Takeout sidecar, returning nil when it carries
+// nothing useful. Takeout writes 0,0 for an unknown location.
+func parseTakeoutSidecar(data []byte) *importMetadata {
+	var sidecar takeoutSidecar
+	if err := json.Unmarshal(data, &sidecar); err != nil {
+		return nil
+	}
+
+	meta := &importMetadata{Description: strings.TrimSpace(sidecar.Description)}
+	if sidecar.PhotoTakenTime != nil {
+		if seconds, err := strconv.ParseInt(sidecar.PhotoTakenTime.Timestamp, 10, 64); err == nil && seconds > 0 {
+			taken := time.Unix(seconds, 0).UTC()
+			meta.DateTaken = &taken
+		}
+	}
+	for _, geo := range []*takeoutGeoData{sidecar.GeoData, sidecar.GeoDataExif} {
+		if geo == nil || (geo.Latitude == 0 && geo.Longitude == 0) {
+			continue
+		}
+		lat, lon := geo.Latitude, geo.Longitude
+		meta.Latitude, meta.Longitude = &lat, &lon
+		if geo.Altitude != 0 {
+			alt := geo.Altitude
+			meta.Altitude = &alt
+		}
+		break
+	}
+
+	if meta.DateTaken == nil && meta.Latitude == nil && meta.Description == "" {
+		return nil
+	}
+	return meta
+}
+
+// xmpListItem pulls the first value out of an rdf:Alt, rdf:Seq or rdf:Bag
+var xmpListItem = regexp.MustCompile(`(?s)<rdf:li[^>]*>(.*?)</rdf:li>`)
+
+// parseXMPSidecar reads the date, caption and location from an XMP sidecar
+// such as Apple Photos writes with "Export IPTC as XMP"
+func parseXMPSidecar(data []byte) *importMetadata {
+	meta := &importMetadata{Description: xmpValue(data, "dc:description")}
+
+	for _, name := range []string{"exif:DateTimeOriginal", "photoshop:DateCreated", "xmp:CreateDate"} {
+		if taken, ok := parseXMPDate(xmpValue(data, name)); ok {
+			meta.DateTaken = &taken
+			break
+		}
+	}
+
+	lat, latOK := parseXMPCoordinate(xmpValue(data, "exif:GPSLatitude"))
+	lon, lonOK := parseXMPCoordinate(xmpValue(data, "exif:GPSLongitude"))
+	if latOK && lonOK {
+		meta.Latitude, meta.Longitude = &lat, &lon
+		if alt, ok := parseXMPRational(xmpValue(data, "exif:GPSAltitude")); ok {
+			if xmpValue(data, "exif:GPSAltitudeRef") == "1" {
+				alt = -alt
+			}
+			meta.Altitude = &alt
+		}
+	}
+
+	if meta.DateTaken == nil && meta.Latitude == nil && meta.Description == "" {
+		return nil
+	}
+	return meta
+}
+
+// xmpValue returns a property written either as an attribute of
+// rdf:Description or as an element, taking the first item of a list
+func xmpValue(data []byte, name string) string {
+	quoted := regexp.QuoteMeta(name)
+	if m := regexp.MustCompile(quoted + `\s*=\s*"([^"]*)"`).FindSubmatch(data); m != nil {
+		return strings.TrimSpace(html.UnescapeString(string(m[1])))
+	}
+	if m := regexp.MustCompile(`(?s)<` + quoted + `(?:\s[^>]*)?>(.*?)</` + quoted + `>`).FindSubmatch(data); m != nil {
+		value := string(m[1])
+		if item := xmpListItem.FindStringSubmatch(value); item != nil {
+			value = item[1]
+		}
+		return strings.TrimSpace(html.UnescapeString(value))
+	}
+	return ""
+}
+
+// parseXMPDate accepts the ISO 8601 forms XMP allows. Dates without a zone
+// are taken as UTC, as EXIF dates are.
+func parseXMPDate(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02T15:04:05.999999999", "2006-01-02T15:04Z07:00", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseXMPCoordinate reads an XMP GPS coordinate, "DDD,MM.mmk" or
+// "DDD,MM,SSk" where k is N, S, E or W
+func parseXMPCoordinate(value string) (float64, bool) {
+	if len(value) < 2 {
+		return 0, false
+	}
+	ref := strings.ToUpper(value[len(value)-1:])
+	if !strings.Contains("NSEW", ref) {
+		return 0, false
+	}
+
+	parts := strings.Split(value[:len(value)-1], ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+	coordinate := 0.0
+	for i, part := range parts {
+		n, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return 0, false
+		}
+		coordinate += n / []float64{1, 60, 3600}[i]
+	}
+	if ref == "S" || ref == "W" {
+		coordinate = -coordinate
+	}
+	return coordinate, true
+}
+
+// parseXMPRational reads "n/d" or a plain number
+func parseXMPRational(value string) (float64, bool) {
+	num, den, found := strings.Cut(value, "/")
+	n, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil {
+		return 0, false
+	}
+	if !found {
+		return n, true
+	}
+	d, err := strconv.ParseFloat(strings.TrimSpace(den), 64)
+	if err != nil || d == 0 {
+		return 0, false
+	}
+	return n / d, true
+}
+
+func readImportEntry(entry importEntry) ([]byte, error) {
+	r, err := entry.open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
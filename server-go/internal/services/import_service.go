@@ -0,0 +1,944 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+)
+
+const (
+	// Progress is saved and reported after this many files or this long, whichever comes first
+	importCheckpointFiles    = 25
+	importCheckpointInterval = 2 * time.Second
+
+	// Finished jobs and abandoned archives are removed after this long
+	importRetention = 7 * 24 * time.Hour
+
+	// Sidecars larger than this are not metadata and are ignored
+	maxImportSidecarSize = 1 << 20
+)
+
+// ImportService imports exported libraries (Google Takeout, Apple Photos or a
+// plain folder tree) as background jobs. Every media file goes through the
+// same pipeline as an upload, with the dates, locations and captions from its
+// sidecar, and album folders are recreated as collections. Jobs run one at a
+// time; their state is kept under <basePath>/.imports so an interrupted job
+// resumes after a restart, skipping the files it already handled.
+type ImportService struct {
+	jobDir            string
+	archiveDir        string
+	importRoot        string // Server directory admins may import from; empty disables directory imports
+	storageService    *PhotoStorageService
+	uploadService     *UploadService
+	collectionService *CollectionService
+	wsHub             *WebSocketHub // Optional, for progress notifications
+	quotaService      *QuotaService // Optional, archives are not checked against quotas when nil
+	maxArchiveBytes   int64         // Per request; 0 means unlimited
+
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+	wake    chan struct{}
+	started bool
+}
+
+// NewImportService creates a new ImportService
+func NewImportService(
+	basePath string,
+	importRoot string,
+	storageService *PhotoStorageService,
+	uploadService *UploadService,
+	collectionService *CollectionService,
+) (*ImportService, error) {
+	jobDir := filepath.Join(basePath, ".imports", "jobs")
+	archiveDir := filepath.Join(basePath, ".imports", "archives")
+	for _, dir := range []string{jobDir, archiveDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create import directory: %w", err)
+		}
+	}
+
+	if importRoot != "" {
+		abs, err := filepath.Abs(importRoot)
+		if err != nil {
+			return nil, fmt.Errorf("invalid import root: %w", err)
+		}
+		if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = resolved
+		}
+		importRoot = abs
+	}
+
+	return &ImportService{
+		jobDir:            jobDir,
+		archiveDir:        archiveDir,
+		importRoot:        importRoot,
+		storageService:    storageService,
+		uploadService:     uploadService,
+		collectionService: collectionService,
+		cancels:           make(map[string]context.CancelFunc),
+		wake:              make(chan struct{}, 1),
+	}, nil
+}
+
+// SetWebSocketHub enables progress notifications to the importing user
+func (s *ImportService) SetWebSocketHub(hub *WebSocketHub) {
+	s.wsHub = hub
+}
+
+// SetQuotaService refuses archives that would not fit the user's quota
+func (s *ImportService) SetQuotaService(quotaService *QuotaService) {
+	s.quotaService = quotaService
+}
+
+// SetMaxArchiveSize caps the archives uploaded in one request
+func (s *ImportService) SetMaxArchiveSize(bytes int64) {
+	s.maxArchiveBytes = bytes
+}
+
+// MaxArchiveSize returns the most archive bytes one request may upload, or 0
+// when there is no limit
+func (s *ImportService) MaxArchiveSize() int64 {
+	return s.maxArchiveBytes
+}
+
+// CheckArchiveUpload refuses an upload of size bytes of archives before any
+// of it is spooled: it must be within the request limit, fit the user's
+// storage quota and fit on the disk that keeps archives until the import
+// finishes. A negative size means the length is not known up front; the
+// limit is then enforced while spooling.
+func (s *ImportService) CheckArchiveUpload(ctx context.Context, userID string, size int64) error {
+	if size < 0 {
+		size = 0
+	}
+	if s.maxArchiveBytes > 0 && size > s.maxArchiveBytes {
+		return models.ErrImportArchiveTooLarge
+	}
+	if s.quotaService != nil {
+		if err := s.quotaService.CheckUpload(ctx, userID, size); err != nil {
+			return err
+		}
+	}
+	if free := freeDiskSpace(s.archiveDir); free >= 0 && size > free {
+		return models.ErrImportInsufficientSpace
+	}
+	return nil
+}
+
+// Start launches the worker. Jobs left queued or running by a previous
+// process are picked up again.
+func (s *ImportService) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+	go s.worker()
+	s.notify()
+}
+
+// CreateFromArchives spools every archive from src and queues a job that
+// imports them together. Google Takeout splits large exports over several
+// archives and a photo's sidecar may sit in a different one.
+func (s *ImportService) CreateFromArchives(userID string, src BatchFileSource) (*models.ImportJob, error) {
+	job := models.NewImportJob(userID, models.ImportSourceArchive)
+	var spooled int64
+	for {
+		file, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			s.removeArchives(job)
+			return nil, err
+		}
+
+		format := models.ImportArchiveFormatForFilename(file.Filename)
+		if format == "" {
+			s.removeArchives(job)
+			return nil, models.ErrImportUnsupportedArchive
+		}
+		archivePath := filepath.Join(s.archiveDir, fmt.Sprintf("%s-%d.%s", job.ID, len(job.Archives)+1, format))
+		job.Archives = append(job.Archives, models.ImportArchive{Path: archivePath, Format: format})
+		limit := int64(-1)
+		if s.maxArchiveBytes > 0 {
+			limit = s.maxArchiveBytes - spooled
+		}
+		n, err := spoolImportArchive(archivePath, file.Reader, limit)
+		if err != nil {
+			s.removeArchives(job)
+			if err == models.ErrImportArchiveTooLarge {
+				return nil, err
+			}
+			return nil, fmt.Errorf("failed to save archive: %w", err)
+		}
+		spooled += n
+	}
+	if len(job.Archives) == 0 {
+		return nil, models.ErrImportNoArchives
+	}
+
+	if err := s.save(job); err != nil {
+		s.removeArchives(job)
+		return nil, err
+	}
+	s.notify()
+	return job, nil
+}
+
+// CreateFromDirectory queues a job that imports a directory below the import
+// root. Relative paths are taken from the import root.
+func (s *ImportService) CreateFromDirectory(userID, dir string) (*models.ImportJob, error) {
+	if s.importRoot == "" {
+		return nil, models.ErrImportDirectoryDisabled
+	}
+
+	target := dir
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(s.importRoot, target)
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Clean(target))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, models.ErrImportPathNotFound
+		}
+		return nil, err
+	}
+	rel, err := filepath.Rel(s.importRoot, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, models.ErrImportPathNotAllowed
+	}
+	if info, err := os.Stat(resolved); err != nil || !info.IsDir() {
+		return nil, models.ErrImportPathNotFound
+	}
+
+	job := models.NewImportJob(userID, models.ImportSourceDirectory)
+	job.Directory = resolved
+	if err := s.save(job); err != nil {
+		return nil, err
+	}
+	s.notify()
+	return job, nil
+}
+
+// Get returns a job owned by the user
+func (s *ImportService) Get(jobID, userID string) (*models.ImportJob, error) {
+	job, err := s.load(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.UserID != userID {
+		return nil, models.ErrImportNotFound
+	}
+	return job, nil
+}
+
+// List returns the user's jobs, newest first
+func (s *ImportService) List(userID string) ([]*models.ImportJob, error) {
+	jobs, err := s.loadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var owned []*models.ImportJob
+	for _, job := range jobs {
+		if job.UserID == userID {
+			owned = append(owned, job)
+		}
+	}
+	sort.Slice(owned, func(i, j int) bool {
+		return owned[i].CreatedAt.After(owned[j].CreatedAt)
+	})
+	return owned, nil
+}
+
+// Cancel stops a queued or running job. Files already imported stay in the
+// library; a cancelled job can be resumed later.
+func (s *ImportService) Cancel(jobID, userID string) (*models.ImportJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, err := s.Get(jobID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A running job records its own cancellation when the worker notices
+	if cancel, ok := s.cancels[jobID]; ok {
+		cancel()
+		return job, nil
+	}
+	if job.IsFinished() {
+		return nil, models.ErrImportFinished
+	}
+
+	job.Status = models.ImportStatusCancelled
+	job.UpdatedAt = time.Now().UTC()
+	if err := s.save(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Resume queues a failed or cancelled job again. Files it already handled
+// are skipped.
+func (s *ImportService) Resume(jobID, userID string) (*models.ImportJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, err := s.Get(jobID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != models.ImportStatusFailed && job.Status != models.ImportStatusCancelled {
+		return nil, models.ErrImportNotResumable
+	}
+
+	job.Status = models.ImportStatusQueued
+	job.Error = ""
+	job.UpdatedAt = time.Now().UTC()
+	if err := s.save(job); err != nil {
+		return nil, err
+	}
+	s.notify()
+	return job, nil
+}
+
+// CleanupExpired removes finished jobs older than the retention period along
+// with their archives, and archives whose upload never finished. Returns the
+// number of jobs removed.
+func (s *ImportService) CleanupExpired() (int, error) {
+	jobs, err := s.loadAll()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	known := make(map[string]bool)
+	for _, job := range jobs {
+		if job.IsFinished() && time.Since(job.UpdatedAt) > importRetention {
+			s.removeArchives(job)
+			os.Remove(s.statePath(job.ID))
+			removed++
+			continue
+		}
+		for _, archive := range job.Archives {
+			known[archive.Path] = true
+		}
+	}
+
+	entries, err := os.ReadDir(s.archiveDir)
+	if err != nil {
+		return removed, err
+	}
+	for _, entry := range entries {
+		name := filepath.Join(s.archiveDir, entry.Name())
+		if known[name] {
+			continue
+		}
+		if info, err := entry.Info(); err == nil && time.Since(info.ModTime()) > importRetention {
+			os.Remove(name)
+		}
+	}
+
+	if removed > 0 {
+		log.Printf("Removed %d finished import jobs", removed)
+	}
+	return removed, nil
+}
+
+// notify wakes the worker without blocking
+func (s *ImportService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *ImportService) worker() {
+	for {
+		ctx, job, err := s.claimNext()
+		if err != nil {
+			log.Printf("Import: failed to pick next job: %v", err)
+		}
+		if job == nil {
+			<-s.wake
+			continue
+		}
+		s.run(ctx, job)
+	}
+}
+
+// claimNext marks the oldest waiting job as running. A job found running
+// was interrupted, since only one runs at a time, and continues.
+func (s *ImportService) claimNext() (context.Context, *models.ImportJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs, err := s.loadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var next *models.ImportJob
+	for _, job := range jobs {
+		if job.Status != models.ImportStatusQueued && job.Status != models.ImportStatusRunning {
+			continue
+		}
+		if next == nil || job.CreatedAt.Before(next.CreatedAt) {
+			next = job
+		}
+	}
+	if next == nil {
+		return nil, nil, nil
+	}
+
+	next.Status = models.ImportStatusRunning
+	next.UpdatedAt = time.Now().UTC()
+	if err := s.save(next); err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancels[next.ID] = cancel
+	return ctx, next, nil
+}
+
+// run imports a claimed job and records how it ended
+func (s *ImportService) run(ctx context.Context, job *models.ImportJob) {
+	log.Printf("Import %s started for user %s", job.ID, job.UserID)
+	err := s.importAll(ctx, job)
+
+	s.mu.Lock()
+	now := time.Now().UTC()
+	job.UpdatedAt = now
+	switch {
+	case err == nil:
+		job.Status = models.ImportStatusCompleted
+		job.CompletedAt = &now
+	case ctx.Err() != nil:
+		job.Status = models.ImportStatusCancelled
+	default:
+		job.Status = models.ImportStatusFailed
+		job.Error = err.Error()
+	}
+	if cancel, ok := s.cancels[job.ID]; ok {
+		cancel()
+		delete(s.cancels, job.ID)
+	}
+	if saveErr := s.save(job); saveErr != nil {
+		log.Printf("Import %s: failed to save state: %v", job.ID, saveErr)
+	}
+	s.mu.Unlock()
+
+	if job.Status == models.ImportStatusCompleted {
+		s.removeArchives(job)
+	}
+	log.Printf("Import %s %s: %d imported, %d duplicates, %d failed of %d", job.ID, job.Status, job.Imported, job.Duplicates, job.Failed, job.Total)
+	s.send(job, WSTypeImportComplete)
+}
+
+// importAll reads the source twice: first to index sidecars and albums, then
+// to import each media file that an earlier run has not handled
+func (s *ImportService) importAll(ctx context.Context, job *models.ImportJob) error {
+	src, err := s.openSource(job)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	index := newImportIndex(s.storageService)
+	if err := src.walk(ctx, index.add); err != nil {
+		return fmt.Errorf("failed to read import: %w", err)
+	}
+	job.Total = len(index.media)
+
+	pending := make(map[string][]string)
+	if err := s.checkpoint(ctx, job, pending); err != nil {
+		return err
+	}
+
+	count := 0
+	lastCheckpoint := time.Now()
+	err = src.walk(ctx, func(entry importEntry) error {
+		if !index.media[entry.Name] || job.Done[entry.Name] {
+			return nil
+		}
+
+		photoID, err := s.importFile(ctx, job, index, entry)
+		if err != nil {
+			return err
+		}
+		job.Processed++
+		job.Done[entry.Name] = true
+		if album := index.album(entry.Name); album != "" && photoID != "" {
+			pending[album] = append(pending[album], photoID)
+		}
+
+		count++
+		if count%importCheckpointFiles == 0 || time.Since(lastCheckpoint) >= importCheckpointInterval {
+			lastCheckpoint = time.Now()
+			return s.checkpoint(ctx, job, pending)
+		}
+		return nil
+	})
+
+	// Keep what was done before a failure or cancellation so a resume skips it
+	if checkpointErr := s.checkpoint(context.WithoutCancel(ctx), job, pending); err == nil {
+		err = checkpointErr
+	}
+	return err
+}
+
+// importFile runs one media file through the upload pipeline and returns the
+// photo it became, whether new or a duplicate. Per-file problems go into the
+// job's error report; the returned error stops the whole job.
+func (s *ImportService) importFile(ctx context.Context, job *models.ImportJob, index *importIndex, entry importEntry) (string, error) {
+	fail := func(err error) (string, error) {
+		job.Failed++
+		job.AddError(entry.Name, err.Error())
+		return "", nil
+	}
+
+	if err := s.storageService.ValidateUpload(entry.Name, entry.Size); err != nil {
+		return fail(err)
+	}
+
+	tmp, size, err := s.spoolEntry(job.ID, entry)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return fail(err)
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	opts := UploadOptions{
+		OriginalFilename: path.Base(entry.Name),
+		UserID:           job.UserID,
+	}
+	if meta := index.metadata(entry.Name); meta != nil {
+		opts.DateTaken = meta.DateTaken
+		opts.Description = meta.Description
+		opts.Latitude = meta.Latitude
+		opts.Longitude = meta.Longitude
+		opts.Altitude = meta.Altitude
+	}
+
+	result, err := s.uploadService.Process(ctx, tmp, size, opts)
+	switch {
+	case ctx.Err() != nil:
+		return "", ctx.Err()
+	case err == models.ErrStorageQuotaExceeded || err == models.ErrPhotoQuotaExceeded:
+		// Every remaining file would fail the same way
+		return "", err
+	case err != nil:
+		if _, ok := err.(models.PhotoError); !ok {
+			log.Printf("Import %s: failed to process %s: %v", job.ID, entry.Name, err)
+		}
+		return fail(err)
+	case result.IsDuplicate:
+		job.Duplicates++
+	default:
+		job.Imported++
+	}
+	return result.ID, nil
+}
+
+// spoolEntry copies an entry to a temporary file, since the upload pipeline
+// needs to seek and archive entries are read front to back
+func (s *ImportService) spoolEntry(jobID string, entry importEntry) (*os.File, int64, error) {
+	r, err := entry.open()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer r.Close()
+
+	tmp, err := os.CreateTemp(s.archiveDir, jobID+"-*.part")
+	if err != nil {
+		return nil, 0, err
+	}
+	// The size was validated from the archive's header; never write more
+	size, err := io.Copy(tmp, io.LimitReader(r, entry.Size+1))
+	if err == nil && size > entry.Size {
+		err = models.ErrImportEntrySizeMismatch
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, 0, err
+	}
+	return tmp, size, nil
+}
+
+// checkpoint adds pending photos to their albums, then saves and reports
+// progress. Albums are filled before Done is saved, so a file is never
+// marked done while its album membership is still outstanding.
+func (s *ImportService) checkpoint(ctx context.Context, job *models.ImportJob, pending map[string][]string) error {
+	for album, photoIDs := range pending {
+		s.addToAlbum(ctx, job, album, photoIDs)
+		delete(pending, album)
+	}
+
+	job.UpdatedAt = time.Now().UTC()
+	if err := s.save(job); err != nil {
+		return fmt.Errorf("failed to save progress: %w", err)
+	}
+	s.send(job, WSTypeImportProgress)
+	return nil
+}
+
+// addToAlbum adds photos to the collection recreating an album, creating it
+// on first use. A collection the user deleted since an earlier run is
+// created again.
+func (s *ImportService) addToAlbum(ctx context.Context, job *models.ImportJob, album string, photoIDs []string) {
+	if collectionID, ok := job.Albums[album]; ok {
+		err := s.collectionService.AddPhotos(ctx, collectionID, job.UserID, photoIDs)
+		if err == nil {
+			return
+		}
+		if err != models.ErrCollectionNotFound {
+			job.AddError(album, fmt.Sprintf("failed to add photos to album: %v", err))
+			return
+		}
+	}
+
+	collection, err := s.collectionService.CreateCollection(ctx, job.UserID, &models.CreateCollectionRequest{Name: album})
+	if err != nil {
+		job.AddError(album, fmt.Sprintf("failed to create album: %v", err))
+		return
+	}
+	job.Albums[album] = collection.ID
+	if err := s.collectionService.AddPhotos(ctx, collection.ID, job.UserID, photoIDs); err != nil {
+		job.AddError(album, fmt.Sprintf("failed to add photos to album: %v", err))
+	}
+}
+
+// send reports a job to its owner. Progress messages leave out the error
+// report; the completion message carries it.
+func (s *ImportService) send(job *models.ImportJob, msgType string) {
+	if s.wsHub == nil {
+		return
+	}
+	payload := job.ToResponse()
+	if msgType == WSTypeImportProgress {
+		payload.Errors = nil
+	}
+	s.wsHub.SendToUser(job.UserID, WSMessage{Type: msgType, Payload: payload})
+}
+
+func (s *ImportService) openSource(job *models.ImportJob) (importSource, error) {
+	if job.Source == models.ImportSourceDirectory {
+		if info, err := os.Stat(job.Directory); err != nil || !info.IsDir() {
+			return nil, models.ErrImportPathNotFound
+		}
+		return &dirImportSource{root: job.Directory}, nil
+	}
+	return openArchiveImportSource(job.Archives)
+}
+
+func (s *ImportService) removeArchives(job *models.ImportJob) {
+	for _, archive := range job.Archives {
+		os.Remove(archive.Path)
+	}
+}
+
+func (s *ImportService) load(jobID string) (*models.ImportJob, error) {
+	if !isValidSessionID(jobID) {
+		return nil, models.ErrImportNotFound
+	}
+
+	data, err := os.ReadFile(s.statePath(jobID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, models.ErrImportNotFound
+		}
+		return nil, err
+	}
+
+	var job models.ImportJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("corrupt import job %s: %w", jobID, err)
+	}
+	if job.Albums == nil {
+		job.Albums = make(map[string]string)
+	}
+	if job.Done == nil {
+		job.Done = make(map[string]bool)
+	}
+	return &job, nil
+}
+
+func (s *ImportService) loadAll() ([]*models.ImportJob, error) {
+	entries, err := os.ReadDir(s.jobDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []*models.ImportJob
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		job, err := s.load(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			log.Printf("Import: skipping unreadable job %s: %v", name, err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// save writes the job state atomically via a temp file and rename
+func (s *ImportService) save(job *models.ImportJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := s.statePath(job.ID) + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.statePath(job.ID))
+}
+
+func (s *ImportService) statePath(jobID string) string {
+	return filepath.Join(s.jobDir, jobID+".json")
+}
+
+// spoolImportArchive copies an uploaded archive to dst and returns its size.
+// An archive longer than limit bytes fails with ErrImportArchiveTooLarge; a
+// negative limit means no limit.
+func spoolImportArchive(dst string, r io.Reader, limit int64) (int64, error) {
+	f, err := os.Create(dst)
+	if err != nil {
+		return 0, err
+	}
+	if limit >= 0 {
+		r = io.LimitReader(r, limit+1)
+	}
+	n, err := io.Copy(f, r)
+	if err == nil && limit >= 0 && n > limit {
+		err = models.ErrImportArchiveTooLarge
+	}
+	if err != nil {
+		f.Close()
+		return 0, err
+	}
+	return n, f.Close()
+}
+
+// importEntry is one file of an import source. Name is a slash-separated
+// path relative to the source root; open is only valid during the walk
+// callback that received the entry.
+type importEntry struct {
+	Name string
+	Size int64
+	open func() (io.ReadCloser, error)
+}
+
+// importSource lists the files of an import. It can be walked more than once.
+type importSource interface {
+	walk(ctx context.Context, fn func(importEntry) error) error
+	Close() error
+}
+
+// dirImportSource reads a directory tree on the server
+type dirImportSource struct {
+	root string
+}
+
+func (d *dirImportSource) walk(ctx context.Context, fn func(importEntry) error) error {
+	return filepath.WalkDir(d.root, func(p string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if p != d.root && isHiddenImportName(entry.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(d.root, p)
+		if err != nil {
+			return err
+		}
+		return fn(importEntry{
+			Name: filepath.ToSlash(rel),
+			Size: info.Size(),
+			open: func() (io.ReadCloser, error) { return os.Open(p) },
+		})
+	})
+}
+
+func (d *dirImportSource) Close() error {
+	return nil
+}
+
+// archiveImportSource reads uploaded archives as one tree
+type archiveImportSource struct {
+	archives []models.ImportArchive
+	zips     map[string]*zip.ReadCloser
+}
+
+// openArchiveImportSource opens zip archives up front so a damaged upload
+// fails the job before anything is imported
+func openArchiveImportSource(archives []models.ImportArchive) (*archiveImportSource, error) {
+	src := &archiveImportSource{archives: archives, zips: make(map[string]*zip.ReadCloser)}
+	for _, archive := range archives {
+		if archive.Format != models.ImportArchiveZip {
+			continue
+		}
+		zr, err := zip.OpenReader(archive.Path)
+		if err != nil {
+			src.Close()
+			return nil, fmt.Errorf("failed to open archive: %w", err)
+		}
+		src.zips[archive.Path] = zr
+	}
+	return src, nil
+}
+
+func (a *archiveImportSource) walk(ctx context.Context, fn func(importEntry) error) error {
+	for _, archive := range a.archives {
+		var err error
+		if zr, ok := a.zips[archive.Path]; ok {
+			err = a.walkZip(ctx, zr, fn)
+		} else {
+			err = a.walkTar(ctx, archive, fn)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *archiveImportSource) walkZip(ctx context.Context, zr *zip.ReadCloser, fn func(importEntry) error) error {
+	for _, f := range zr.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		if err := fn(importEntry{
+			Name: cleanImportName(f.Name),
+			Size: int64(f.UncompressedSize64),
+			open: f.Open,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *archiveImportSource) walkTar(ctx context.Context, archive models.ImportArchive, fn func(importEntry) error) error {
+	f, err := os.Open(archive.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if archive.Format == models.ImportArchiveTarGz {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(importEntry{
+			Name: cleanImportName(header.Name),
+			Size: header.Size,
+			open: func() (io.ReadCloser, error) { return io.NopCloser(tr), nil },
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+func (a *archiveImportSource) Close() error {
+	for _, zr := range a.zips {
+		zr.Close()
+	}
+	return nil
+}
+
+// cleanImportName normalises an archive entry name to a relative slash path
+func cleanImportName(name string) string {
+	name = path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))
+	return strings.TrimPrefix(name, "/")
+}
+
+// isHiddenImportName matches dot files and the resource forks macOS adds to zips
+func isHiddenImportName(name string) bool {
+	return strings.HasPrefix(name, ".") || name == "__MACOSX"
+}
+
+// singleArchiveSource yields one archive sent as a raw request body
+type singleArchiveSource struct {
+	file *BatchFile
+}
+
+// NewSingleArchiveSource returns a BatchFileSource over one archive of the
+// given format, for imports uploaded as a plain request body
+func NewSingleArchiveSource(format models.ImportArchiveFormat, r io.Reader) BatchFileSource {
+	return &singleArchiveSource{file: &BatchFile{Filename: "import." + string(format), Reader: r}}
+}
+
+func (s *singleArchiveSource) Next() (*BatchFile, error) {
+	if s.file == nil {
+		return nil, io.EOF
+	}
+	file := s.file
+	s.file = nil
+	return file, nil
+}
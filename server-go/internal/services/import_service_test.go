@@ -0,0 +1,196 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestImportService(t *testing.T, importRoot string) (*ImportService, repository.PhotoRepo, *repository.CollectionPhotoRepository) {
+	db, tempDir := setupTestDB(t)
+	photoRepo := repository.NewPhotoRepository(db)
+	storage := newTestStorage(t, tempDir)
+	uploadService := newTestUploadService(t, photoRepo, storage, tempDir)
+	collectionPhotoRepo := repository.NewCollectionPhotoRepository(db)
+	collectionService := NewCollectionService(
+		repository.NewCollectionRepository(db), collectionPhotoRepo, repository.NewCollectionShareRepository(db),
+		photoRepo, repository.NewUserRepository(db), nil, repository.NewUserPreferencesRepository(db),
+	)
+
+	svc, err := NewImportService(tempDir, importRoot, storage, uploadService, collectionService)
+	require.NoError(t, err)
+	return svc, photoRepo, collectionPhotoRepo
+}
+
+// runNextImport runs the next queued job the way the worker does
+func runNextImport(t *testing.T, svc *ImportService) *models.ImportJob {
+	ctx, job, err := svc.claimNext()
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	svc.run(ctx, job)
+	return job
+}
+
+func TestImportService_TakeoutArchive(t *testing.T) {
+	ctx := context.Background()
+	svc, photoRepo, collectionPhotoRepo := newTestImportService(t, "")
+
+	const root = "Takeout/Google Photos/"
+	files := []struct {
+		name    string
+		content string
+	}{
+		{root + "Photos from 2019/IMG_0001.jpg", "beach picture"},
+		{root + "Photos from 2019/IMG_0001.jpg.supplemental-metadata.json",
+			`{"title":"IMG_0001.jpg","description":"Sunset at the beach","photoTakenTime":{"timestamp":"1560000000"},"geoData":{"latitude":43.48,"longitude":-1.56,"altitude":12}}`},
+		{root + "Photos from 2019/empty.jpg", ""},
+		{root + "Photos from 2019/print-subscriptions.json", `{}`},
+		{root + "Summer/metadata.json", `{"title":"Summer Trip 2019"}`},
+		{root + "Summer/IMG_0001.jpg", "beach picture"},
+		{root + "Summer/IMG_0002(1).jpg", "harbour picture"},
+		{root + "Summer/IMG_0002.jpg.supplemental-metadata(1).json", `{"photoTakenTime":{"timestamp":"1560100000"},"geoData":{"latitude":0,"longitude":0}}`},
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(f.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	created, err := svc.CreateFromArchives("user-1", NewSingleArchiveSource(models.ImportArchiveZip, &buf))
+	require.NoError(t, err)
+	runNextImport(t, svc)
+
+	job, err := svc.Get(created.ID, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.ImportStatusCompleted, job.Status)
+	assert.Equal(t, 4, job.Total)
+	assert.Equal(t, 4, job.Processed)
+	assert.Equal(t, 2, job.Imported)
+	assert.Equal(t, 1, job.Duplicates, "the album copy matches the year folder copy")
+	assert.Equal(t, 1, job.Failed)
+	require.Len(t, job.Errors, 1)
+	assert.Equal(t, root+"Photos from 2019/empty.jpg", job.Errors[0].Path)
+	_, err = os.Stat(job.Archives[0].Path)
+	assert.True(t, os.IsNotExist(err), "a completed import removes its archive")
+
+	hashService := NewHashService()
+	beach, err := photoRepo.GetByHashAndUser(ctx, hashService.ComputeHashBytes([]byte("beach picture")), "user-1")
+	require.NoError(t, err)
+	require.NotNil(t, beach)
+	assert.Equal(t, "IMG_0001.jpg", beach.OriginalFilename)
+	assert.True(t, beach.DateTaken.Equal(time.Unix(1560000000, 0)))
+	require.NotNil(t, beach.Description)
+	assert.Equal(t, "Sunset at the beach", *beach.Description)
+	require.NotNil(t, beach.Latitude)
+	assert.InDelta(t, 43.48, *beach.Latitude, 1e-9)
+	assert.InDelta(t, 12.0, *beach.Altitude, 1e-9)
+
+	harbour, err := photoRepo.GetByHashAndUser(ctx, hashService.ComputeHashBytes([]byte("harbour picture")), "user-1")
+	require.NoError(t, err)
+	require.NotNil(t, harbour)
+	assert.True(t, harbour.DateTaken.Equal(time.Unix(1560100000, 0)), "numbered files find their sidecar")
+	assert.Nil(t, harbour.Latitude, "0,0 means no location")
+
+	// Only the album folder becomes a collection, holding the duplicate too
+	require.Len(t, job.Albums, 1)
+	entries, err := collectionPhotoRepo.GetByCollectionID(ctx, job.Albums["Summer Trip 2019"])
+	require.NoError(t, err)
+	var inAlbum []string
+	for _, entry := range entries {
+		inAlbum = append(inAlbum, entry.PhotoID)
+	}
+	assert.ElementsMatch(t, []string{beach.ID, harbour.ID}, inAlbum)
+
+	_, err = svc.Get(created.ID, "user-2")
+	assert.Equal(t, models.ErrImportNotFound, err)
+}
+
+func TestImportService_DirectoryResume(t *testing.T) {
+	importRoot := t.TempDir()
+	svc, photoRepo, _ := newTestImportService(t, importRoot)
+
+	album := filepath.Join(importRoot, "alice", "Lisbon")
+	require.NoError(t, os.MkdirAll(album, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(album, "a.jpg"), []byte("tram"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(album, "b.jpg"), []byte("castle"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(album, "b.xmp"), []byte(
+		`<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF><rdf:Description exif:DateTimeOriginal="2021-05-02T10:15:00"
+		exif:GPSLatitude="38,42.6N" exif:GPSLongitude="9,8.4W"><dc:description><rdf:Alt><rdf:li xml:lang="x-default">Castle &amp; view</rdf:li></rdf:Alt></dc:description>
+		</rdf:Description></rdf:RDF></x:xmpmeta>`), 0644))
+
+	_, err := svc.CreateFromDirectory("user-1", "../elsewhere")
+	assert.Equal(t, models.ErrImportPathNotFound, err)
+	_, err = svc.CreateFromDirectory("user-1", os.TempDir())
+	assert.Equal(t, models.ErrImportPathNotAllowed, err)
+
+	created, err := svc.CreateFromDirectory("user-1", "alice")
+	require.NoError(t, err)
+
+	// Cancelled before it ran, then resumed as if a.jpg had been handled already
+	_, err = svc.Cancel(created.ID, "user-1")
+	require.NoError(t, err)
+	job, err := svc.Resume(created.ID, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.ImportStatusQueued, job.Status)
+	job.Done["Lisbon/a.jpg"] = true
+	job.Processed = 1
+	require.NoError(t, svc.save(job))
+
+	runNextImport(t, svc)
+	job, err = svc.Get(created.ID, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.ImportStatusCompleted, job.Status)
+	assert.Equal(t, 2, job.Total)
+	assert.Equal(t, 2, job.Processed)
+	assert.Equal(t, 1, job.Imported)
+	assert.Contains(t, job.Albums, "Lisbon")
+
+	hashService := NewHashService()
+	skipped, err := photoRepo.GetByHashAndUser(context.Background(), hashService.ComputeHashBytes([]byte("tram")), "user-1")
+	require.NoError(t, err)
+	assert.Nil(t, skipped, "files done before the resume are not imported again")
+
+	castle, err := photoRepo.GetByHashAndUser(context.Background(), hashService.ComputeHashBytes([]byte("castle")), "user-1")
+	require.NoError(t, err)
+	require.NotNil(t, castle)
+	assert.True(t, castle.DateTaken.Equal(time.Date(2021, 5, 2, 10, 15, 0, 0, time.UTC)))
+	require.NotNil(t, castle.Description)
+	assert.Equal(t, "Castle & view", *castle.Description)
+	require.NotNil(t, castle.Longitude)
+	assert.InDelta(t, 38.71, *castle.Latitude, 1e-9)
+	assert.InDelta(t, -9.14, *castle.Longitude, 1e-9)
+
+	_, err = svc.Cancel(created.ID, "user-1")
+	assert.Equal(t, models.ErrImportFinished, err)
+}
+
+func TestImportService_ArchiveLimits(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestImportService(t, "")
+
+	assert.NoError(t, svc.CheckArchiveUpload(ctx, "user-1", -1), "unknown lengths are checked while spooling")
+	assert.Equal(t, models.ErrImportInsufficientSpace, svc.CheckArchiveUpload(ctx, "user-1", 1<<62))
+
+	svc.SetMaxArchiveSize(100)
+	assert.Equal(t, models.ErrImportArchiveTooLarge, svc.CheckArchiveUpload(ctx, "user-1", 101))
+
+	// A body without a length is cut off at the limit and nothing is kept
+	_, err := svc.CreateFromArchives("user-1", NewSingleArchiveSource(models.ImportArchiveZip, bytes.NewReader(make([]byte, 101))))
+	assert.Equal(t, models.ErrImportArchiveTooLarge, err)
+	spooled, err := os.ReadDir(svc.archiveDir)
+	require.NoError(t, err)
+	assert.Empty(t, spooled)
+}
@@ -0,0 +1,317 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+)
+
+// InboxQuarantineDir is the folder inside each inbox that files which could
+// not be ingested are moved to, each with a .reason.txt next to it
+const InboxQuarantineDir = ".quarantine"
+
+// InboxDir is one watched folder and the email of the user who owns what is
+// dropped into it
+type InboxDir struct {
+	Path  string `json:"path"`
+	Owner string `json:"owner"`
+}
+
+// InboxStatus reports what the inbox watcher has done since it started
+type InboxStatus struct {
+	Running     bool       `json:"running"`
+	Inboxes     []InboxDir `json:"inboxes"`
+	LastPoll    *time.Time `json:"lastPoll,omitempty"`
+	Ingested    int        `json:"ingested"`
+	Duplicates  int        `json:"duplicates"`
+	Quarantined int        `json:"quarantined"`
+	Errors      []string   `json:"errors,omitempty"`
+}
+
+// inboxFileState is how a file looked when it was last polled
+type inboxFileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// InboxService watches inbox folders and ingests dropped files through the
+// upload pipeline, which stores them in the Year/Month layout by EXIF date.
+// Folders are polled rather than watched for events because SMB and NFS
+// mounts rarely deliver them. A file is only taken once it is unchanged
+// between two polls and older than the settle time, so one still being
+// written by a scanner is left alone. Ingested files are removed from the
+// inbox; failures are moved to its quarantine folder with the reason.
+//
+// An inbox inside the storage tree should be a dot folder, which the file
+// scanner skips, or its files are reported as orphans before they are taken.
+type InboxService struct {
+	inboxes        []InboxDir
+	pollInterval   time.Duration
+	settle         time.Duration
+	userRepo       repository.UserRepo
+	storageService *PhotoStorageService
+	uploadService  *UploadService
+
+	pollMu   sync.Mutex // Serialises polls so a file is never ingested twice
+	mu       sync.RWMutex
+	seen     map[string]inboxFileState
+	status   InboxStatus
+	stopChan chan struct{}
+}
+
+// NewInboxService creates a new InboxService
+func NewInboxService(
+	inboxes []InboxDir,
+	pollSeconds int,
+	settleSeconds int,
+	userRepo repository.UserRepo,
+	storageService *PhotoStorageService,
+	uploadService *UploadService,
+) *InboxService {
+	if pollSeconds <= 0 {
+		pollSeconds = 30
+	}
+	if settleSeconds < 0 {
+		settleSeconds = 0
+	}
+
+	return &InboxService{
+		inboxes:        inboxes,
+		pollInterval:   time.Duration(pollSeconds) * time.Second,
+		settle:         time.Duration(settleSeconds) * time.Second,
+		userRepo:       userRepo,
+		storageService: storageService,
+		uploadService:  uploadService,
+		seen:           make(map[string]inboxFileState),
+		status: InboxStatus{
+			Inboxes: inboxes,
+			Errors:  []string{},
+		},
+	}
+}
+
+// Start begins polling the inboxes
+func (s *InboxService) Start() {
+	s.mu.Lock()
+	if s.stopChan != nil {
+		s.mu.Unlock()
+		return // Already started
+	}
+	for _, inbox := range s.inboxes {
+		if err := os.MkdirAll(inbox.Path, 0755); err != nil {
+			log.Printf("Warning: failed to create inbox %s: %v", inbox.Path, err)
+		}
+	}
+	stopChan := make(chan struct{})
+	s.stopChan = stopChan
+	s.status.Running = true
+	s.mu.Unlock()
+
+	log.Printf("Inbox watcher started for %d folders (polling every %s)", len(s.inboxes), s.pollInterval)
+
+	go func() {
+		ticker := time.NewTicker(s.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.Poll(context.Background())
+			case <-stopChan:
+				log.Println("Inbox watcher stopped")
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops polling. A file being ingested is finished first.
+func (s *InboxService) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopChan == nil {
+		return
+	}
+	close(s.stopChan)
+	s.stopChan = nil
+	s.status.Running = false
+}
+
+// GetStatus returns the watcher's counters
+func (s *InboxService) GetStatus() InboxStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := s.status
+	status.Errors = append([]string{}, s.status.Errors...)
+	return status
+}
+
+// Poll checks every inbox once and ingests the files that have settled
+func (s *InboxService) Poll(ctx context.Context) {
+	s.pollMu.Lock()
+	defer s.pollMu.Unlock()
+
+	present := make(map[string]bool)
+	var errs []string
+
+	for _, inbox := range s.inboxes {
+		owner, err := s.userRepo.GetByEmail(ctx, inbox.Owner)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: failed to look up owner: %v", inbox.Path, err))
+			continue
+		}
+		if owner == nil {
+			// Leave the files where they are until the configuration is fixed
+			errs = append(errs, fmt.Sprintf("%s: owner %s does not exist", inbox.Path, inbox.Owner))
+			continue
+		}
+
+		err = filepath.WalkDir(inbox.Path, func(path string, entry os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(entry.Name(), ".") && path != inbox.Path {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !entry.Type().IsRegular() {
+				return nil
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				return nil // Removed since the directory was read
+			}
+			present[path] = true
+			if s.settled(path, info) {
+				s.ingest(ctx, inbox, owner.ID, path, info.Size())
+			}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			errs = append(errs, fmt.Sprintf("%s: %v", inbox.Path, err))
+		}
+	}
+
+	for _, e := range errs {
+		log.Printf("Inbox: %s", e)
+	}
+
+	now := time.Now().UTC()
+	s.mu.Lock()
+	for path := range s.seen {
+		if !present[path] {
+			delete(s.seen, path)
+		}
+	}
+	s.status.LastPoll = &now
+	s.status.Errors = append([]string{}, errs...)
+	s.mu.Unlock()
+}
+
+// settled records how a file looks now and reports whether it looked the
+// same at the previous poll and has not been written for the settle time
+func (s *InboxService) settled(path string, info os.FileInfo) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := inboxFileState{size: info.Size(), modTime: info.ModTime()}
+	previous, ok := s.seen[path]
+	s.seen[path] = current
+	unchanged := ok && previous.size == current.size && previous.modTime.Equal(current.modTime)
+	return unchanged && time.Since(current.modTime) >= s.settle
+}
+
+// ingest runs one file through the upload pipeline and clears it from the inbox
+func (s *InboxService) ingest(ctx context.Context, inbox InboxDir, userID, path string, size int64) {
+	rel, err := filepath.Rel(inbox.Path, path)
+	if err != nil {
+		rel = filepath.Base(path)
+	}
+
+	if err := s.storageService.ValidateUpload(path, size); err != nil {
+		s.quarantine(inbox, path, rel, err.Error())
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		log.Printf("Inbox: failed to open %s: %v", path, err)
+		return
+	}
+	result, err := s.uploadService.Process(ctx, file, size, UploadOptions{
+		OriginalFilename: filepath.Base(path),
+		UserID:           userID,
+	})
+	file.Close()
+	if err != nil {
+		// Server-side failures such as a database error, and a full quota, are
+		// retried at the next poll; only problems with the file itself send it
+		// to quarantine
+		if _, ok := err.(models.PhotoError); !ok || models.IsQuotaError(err) {
+			log.Printf("Inbox: failed to ingest %s, will retry: %v", path, err)
+			return
+		}
+		s.quarantine(inbox, path, rel, err.Error())
+		return
+	}
+
+	// The library now holds the file (or already did), so the inbox copy goes
+	if err := os.Remove(path); err != nil {
+		log.Printf("Inbox: ingested %s but failed to remove it: %v", path, err)
+	}
+
+	s.mu.Lock()
+	delete(s.seen, path)
+	if result.IsDuplicate {
+		s.status.Duplicates++
+	} else {
+		s.status.Ingested++
+	}
+	s.mu.Unlock()
+
+	log.Printf("Inbox: ingested %s as photo %s (duplicate: %v)", rel, result.ID, result.IsDuplicate)
+}
+
+// quarantine moves a file that could not be ingested into the inbox's
+// quarantine folder, keeping its relative path, and writes the reason next to it
+func (s *InboxService) quarantine(inbox InboxDir, path, rel, reason string) {
+	dst := filepath.Join(inbox.Path, InboxQuarantineDir, rel)
+	if _, err := os.Stat(dst); err == nil {
+		ext := filepath.Ext(dst)
+		dst = fmt.Sprintf("%s_%s%s", strings.TrimSuffix(dst, ext), time.Now().UTC().Format("20060102T150405"), ext)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		log.Printf("Inbox: failed to quarantine %s: %v", path, err)
+		return
+	}
+	if err := os.Rename(path, dst); err != nil {
+		log.Printf("Inbox: failed to quarantine %s: %v", path, err)
+		return
+	}
+
+	note := fmt.Sprintf("file: %s\ntime: %s\nreason: %s\n", rel, time.Now().UTC().Format(time.RFC3339), reason)
+	if err := os.WriteFile(dst+".reason.txt", []byte(note), 0644); err != nil {
+		log.Printf("Inbox: failed to write quarantine reason for %s: %v", dst, err)
+	}
+
+	s.mu.Lock()
+	delete(s.seen, path)
+	s.status.Quarantined++
+	s.mu.Unlock()
+
+	log.Printf("Inbox: quarantined %s: %s", rel, reason)
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/photosync/server/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInboxService_Poll(t *testing.T) {
+	ctx := context.Background()
+	db, tempDir := setupTestDB(t)
+	photoRepo := repository.NewPhotoRepository(db)
+	storage := newTestStorage(t, tempDir)
+	uploadService := newTestUploadService(t, photoRepo, storage, tempDir)
+
+	inbox := t.TempDir()
+	svc := NewInboxService(
+		[]InboxDir{{Path: inbox, Owner: "user-1@example.com"}}, 30, 0,
+		repository.NewUserRepository(db), storage, uploadService,
+	)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(inbox, "scanner"), 0755))
+	scan := filepath.Join(inbox, "scanner", "scan.jpg")
+	require.NoError(t, os.WriteFile(scan, []byte("scanned print"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(inbox, "copy.jpg"), []byte("scanned print"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(inbox, "notes.txt"), []byte("not a photo"), 0644))
+
+	// The first poll only notes the files; they might still be being written
+	svc.Poll(ctx)
+	_, err := os.Stat(scan)
+	require.NoError(t, err)
+	assert.Equal(t, 0, svc.GetStatus().Ingested)
+
+	svc.Poll(ctx)
+	status := svc.GetStatus()
+	assert.Equal(t, 1, status.Ingested)
+	assert.Equal(t, 1, status.Duplicates)
+	assert.Equal(t, 1, status.Quarantined)
+	assert.Empty(t, status.Errors)
+
+	photo, err := photoRepo.GetByHashAndUser(ctx, NewHashService().ComputeHashBytes([]byte("scanned print")), "user-1")
+	require.NoError(t, err)
+	require.NotNil(t, photo)
+	for _, name := range []string{scan, filepath.Join(inbox, "copy.jpg"), filepath.Join(inbox, "notes.txt")} {
+		_, err := os.Stat(name)
+		assert.True(t, os.IsNotExist(err), "%s is cleared from the inbox", name)
+	}
+
+	quarantined := filepath.Join(inbox, InboxQuarantineDir, "notes.txt")
+	_, err = os.Stat(quarantined)
+	require.NoError(t, err)
+	reason, err := os.ReadFile(quarantined + ".reason.txt")
+	require.NoError(t, err)
+	assert.Contains(t, string(reason), "reason:")
+
+	// Quarantined files are not picked up again
+	svc.Poll(ctx)
+	svc.Poll(ctx)
+	assert.Equal(t, 1, svc.GetStatus().Quarantined)
+}
+
+func TestInboxService_UnknownOwner(t *testing.T) {
+	db, tempDir := setupTestDB(t)
+	storage := newTestStorage(t, tempDir)
+
+	inbox := t.TempDir()
+	dropped := filepath.Join(inbox, "photo.jpg")
+	require.NoError(t, os.WriteFile(dropped, []byte("card reader"), 0644))
+
+	svc := NewInboxService(
+		[]InboxDir{{Path: inbox, Owner: "nobody@example.com"}}, 30, 0,
+		repository.NewUserRepository(db), storage, nil,
+	)
+	svc.Poll(context.Background())
+	svc.Poll(context.Background())
+
+	status := svc.GetStatus()
+	require.Len(t, status.Errors, 1)
+	assert.Contains(t, status.Errors[0], "nobody@example.com")
+	_, err := os.Stat(dropped)
+	assert.NoError(t, err, "files wait until the owner exists")
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+)
+
+// libraryEventBufferSize is how many recent events are kept per user for
+// reconnecting clients. Clients that missed more must resync. It stays below
+// a client's send buffer so a full replay cannot overflow it.
+const libraryEventBufferSize = 200
+
+// LibraryEventService publishes library changes to every authenticated
+// WebSocket connection of a user and keeps a short per-user history so
+// clients can resume after a reconnect instead of polling /api/sync/status.
+//
+// Resume tokens have the form "<epoch>.<seq>". The epoch changes on every
+// server start, so a token from before a restart always asks for a resync.
+type LibraryEventService struct {
+	hub        *WebSocketHub
+	epoch      string
+	bufferSize int
+
+	mu    sync.Mutex
+	users map[string]*libraryEventLog
+
+	// sendMu is taken before mu is released and held while sending, so
+	// messages reach the hub in the order mu was held without blocking
+	// readers of the history on a busy hub
+	sendMu sync.Mutex
+}
+
+// libraryEventLog is the recent history of one user's events
+type libraryEventLog struct {
+	seq    int64
+	events []models.LibraryEvent
+}
+
+// NewLibraryEventService creates a new LibraryEventService
+func NewLibraryEventService(hub *WebSocketHub) *LibraryEventService {
+	return &LibraryEventService{
+		hub:        hub,
+		epoch:      strconv.FormatInt(time.Now().UnixNano(), 36),
+		bufferSize: libraryEventBufferSize,
+		users:      make(map[string]*libraryEventLog),
+	}
+}
+
+// Publish records an event for the user and sends it to their connections
+func (s *LibraryEventService) Publish(userID string, event *models.LibraryEvent) {
+	if userID == "" || event == nil {
+		return
+	}
+
+	s.mu.Lock()
+	userLog := s.users[userID]
+	if userLog == nil {
+		userLog = &libraryEventLog{}
+		s.users[userID] = userLog
+	}
+
+	userLog.seq++
+	event.Seq = userLog.seq
+	event.ResumeToken = s.token(userLog.seq)
+	event.CreatedAt = time.Now().UTC()
+
+	userLog.events = append(userLog.events, *event)
+	if len(userLog.events) > s.bufferSize {
+		userLog.events = userLog.events[len(userLog.events)-s.bufferSize:]
+	}
+
+	// The connections are copied with the event recorded: one attached later
+	// gets the event from its replay instead
+	clients := s.hub.UserClients(userID)
+	s.sendMu.Lock()
+	s.mu.Unlock()
+	defer s.sendMu.Unlock()
+
+	for _, client := range clients {
+		s.hub.SendToClient(client, WSMessage{Type: WSTypeLibraryEvent, Payload: event})
+	}
+}
+
+// Since returns the user's events after a resume token and the token of the
+// latest event. ok is false when the token is from another server run or the
+// events it follows have already been dropped; the client must then resync.
+// An empty token returns no events, only the current position.
+func (s *LibraryEventService) Since(userID, resumeToken string) (events []models.LibraryEvent, latest string, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.since(userID, resumeToken)
+}
+
+func (s *LibraryEventService) since(userID, resumeToken string) ([]models.LibraryEvent, string, bool) {
+	var seq int64
+	var events []models.LibraryEvent
+	if userLog := s.users[userID]; userLog != nil {
+		seq = userLog.seq
+		events = userLog.events
+	}
+	latest := s.token(seq)
+
+	if resumeToken == "" {
+		return nil, latest, true
+	}
+
+	from, err := s.parseToken(resumeToken)
+	if err != nil || from > seq {
+		return nil, latest, false
+	}
+	if from == seq {
+		return nil, latest, true
+	}
+	// The oldest kept event must directly follow what the client has seen
+	if len(events) == 0 || events[0].Seq > from+1 {
+		return nil, latest, false
+	}
+
+	missed := events[from-events[0].Seq+1:]
+	return append([]models.LibraryEvent(nil), missed...), latest, true
+}
+
+// Attach links an authenticated connection to the user's events. Events after
+// resumeToken are replayed to the connection, followed by a library_resumed
+// message with the token to present next time. The client must already be
+// registered with the hub.
+func (s *LibraryEventService) Attach(client *WSClient, userID, resumeToken string) {
+	s.mu.Lock()
+	events, latest, ok := s.since(userID, resumeToken)
+
+	// Live events follow the replay: a Publish that sees the connection is
+	// recorded after it and sends after it
+	s.hub.SetUserID(client, userID)
+	s.sendMu.Lock()
+	s.mu.Unlock()
+	defer s.sendMu.Unlock()
+
+	for i := range events {
+		s.hub.SendToClient(client, WSMessage{Type: WSTypeLibraryEvent, Payload: &events[i]})
+	}
+	s.hub.SendToClient(client, WSMessage{
+		Type: WSTypeLibraryResumed,
+		Payload: models.LibraryResumeResult{
+			ResumeToken:   latest,
+			Replayed:      len(events),
+			ResetRequired: !ok,
+		},
+	})
+}
+
+func (s *LibraryEventService) token(seq int64) string {
+	return s.epoch + "." + strconv.FormatInt(seq, 10)
+}
+
+func (s *LibraryEventService) parseToken(token string) (int64, error) {
+	epoch, seq, found := strings.Cut(token, ".")
+	if !found || epoch != s.epoch {
+		return 0, fmt.Errorf("resume token is from another server run")
+	}
+	n, err := strconv.ParseInt(seq, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid resume token")
+	}
+	return n, nil
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLibraryEventService(t *testing.T) *LibraryEventService {
+	hub := NewWebSocketHub()
+	go hub.Run()
+	return NewLibraryEventService(hub)
+}
+
+func TestLibraryEventService_Resume(t *testing.T) {
+	events := newTestLibraryEventService(t)
+	events.bufferSize = 3
+
+	_, start, ok := events.Since("user-1", "")
+	require.True(t, ok)
+
+	for _, id := range []string{"a", "b"} {
+		events.Publish("user-1", models.NewCollectionEvent(id, models.CollectionChangeUpdated))
+	}
+	events.Publish("user-2", models.NewOrphanAssignedEvent("orphan-1"))
+
+	missed, latest, ok := events.Since("user-1", start)
+	require.True(t, ok)
+	require.Len(t, missed, 2)
+	assert.Equal(t, "a", missed[0].CollectionID)
+	assert.Equal(t, int64(2), missed[1].Seq, "sequences are per user")
+	assert.Equal(t, missed[1].ResumeToken, latest)
+
+	missed, _, ok = events.Since("user-1", latest)
+	assert.True(t, ok)
+	assert.Empty(t, missed, "an up-to-date client has nothing to replay")
+
+	// Overflow the buffer: the first two events are dropped
+	for _, id := range []string{"c", "d", "e"} {
+		events.Publish("user-1", models.NewCollectionEvent(id, models.CollectionChangeUpdated))
+	}
+	missed, _, ok = events.Since("user-1", latest)
+	require.True(t, ok)
+	assert.Len(t, missed, 3)
+
+	_, _, ok = events.Since("user-1", start)
+	assert.False(t, ok, "events before the buffer are gone, so the client must resync")
+
+	restarted := newTestLibraryEventService(t)
+	restarted.epoch = "other"
+	_, _, ok = restarted.Since("user-1", latest)
+	assert.False(t, ok, "tokens do not survive a restart")
+
+	_, _, ok = events.Since("user-1", "garbage")
+	assert.False(t, ok)
+}
+
+func TestLibraryEventService_PublishDoesNotHoldHistoryLock(t *testing.T) {
+	hub := NewWebSocketHub() // Not running yet, so its queue fills up
+	events := NewLibraryEventService(hub)
+	hub.SetUserID(hub.NewClient("client-1", nil), "user-1")
+
+	published := cap(hub.broadcast) + 1
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < published; i++ {
+			events.Publish("user-1", models.NewCollectionEvent("a", models.CollectionChangeUpdated))
+		}
+	}()
+
+	// The last Publish is stuck sending, but the history stays readable
+	assert.Eventually(t, func() bool {
+		_, latest, _ := events.Since("user-1", "")
+		return latest == events.token(int64(published))
+	}, time.Second, 10*time.Millisecond)
+
+	go hub.Run()
+	<-done
+}
+
+func TestTrashService_PublishesLibraryEvents(t *testing.T) {
+	ctx := context.Background()
+	db, tempDir := setupTestDB(t)
+	photoRepo := repository.NewPhotoRepository(db)
+	trashService, storage := newTestTrashService(t, photoRepo, tempDir)
+	events := newTestLibraryEventService(t)
+	trashService.SetLibraryEvents(events)
+
+	photo := addTestPhoto(t, photoRepo, storage, "user-1", "aaa111")
+	require.NoError(t, trashService.MoveToTrash(ctx, photo.ID))
+	require.NoError(t, trashService.Restore(ctx, "user-1", photo.ID))
+	require.NoError(t, trashService.MoveToTrash(ctx, photo.ID))
+	_, err := trashService.Empty(ctx, "user-1")
+	require.NoError(t, err)
+
+	missed, _, ok := events.Since("user-1", events.token(0))
+	require.True(t, ok)
+
+	var types []models.LibraryEventType
+	for _, e := range missed {
+		assert.Equal(t, photo.ID, e.PhotoID)
+		types = append(types, e.Type)
+	}
+	assert.Equal(t, []models.LibraryEventType{
+		models.LibraryEventPhotoTrashed, models.LibraryEventPhotoRestored,
+		models.LibraryEventPhotoTrashed, models.LibraryEventPhotoDeleted,
+	}, types)
+	assert.Nil(t, missed[3].Photo, "deleted photos carry only their ID")
+}
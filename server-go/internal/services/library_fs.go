@@ -0,0 +1,497 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+	"golang.org/x/net/webdav"
+)
+
+// Top-level folders of a library mounted over WebDAV
+const (
+	LibraryDirByDate      = "by-date"
+	LibraryDirCollections = "collections"
+	LibraryDirDevices     = "devices"
+)
+
+// libraryPageSize is how many photos a folder listing reads per query
+const libraryPageSize = 500
+
+// LibraryFS presents one user's library as a read-only webdav.FileSystem:
+//
+//	/by-date/YYYY/MM/    photos by the month they were taken
+//	/collections/<name>/ photos in each of the user's collections
+//	/devices/<name>/     photos by the device that uploaded them
+//
+// Nothing is stored; every folder is listed from the database when it is
+// read. A photo's size is that of its original and its modification time is
+// the date it was taken, as in exports. Any attempt to write fails with
+// os.ErrPermission.
+type LibraryFS struct {
+	userID              string
+	createdAt           time.Time
+	photoRepo           repository.PhotoRepo
+	collectionRepo      repository.CollectionRepo
+	collectionPhotoRepo repository.CollectionPhotoRepo
+	deviceRepo          repository.DeviceRepo
+	storageService      *PhotoStorageService
+}
+
+// NewLibraryFS creates the WebDAV view of a user's library
+func NewLibraryFS(
+	user *models.User,
+	photoRepo repository.PhotoRepo,
+	collectionRepo repository.CollectionRepo,
+	collectionPhotoRepo repository.CollectionPhotoRepo,
+	deviceRepo repository.DeviceRepo,
+	storageService *PhotoStorageService,
+) *LibraryFS {
+	return &LibraryFS{
+		userID:              user.ID,
+		createdAt:           user.CreatedAt,
+		photoRepo:           photoRepo,
+		collectionRepo:      collectionRepo,
+		collectionPhotoRepo: collectionPhotoRepo,
+		deviceRepo:          deviceRepo,
+		storageService:      storageService,
+	}
+}
+
+// Mkdir is not allowed: the library is read-only
+func (fs *LibraryFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return os.ErrPermission
+}
+
+// RemoveAll is not allowed: the library is read-only
+func (fs *LibraryFS) RemoveAll(ctx context.Context, name string) error {
+	return os.ErrPermission
+}
+
+// Rename is not allowed: the library is read-only
+func (fs *LibraryFS) Rename(ctx context.Context, oldName, newName string) error {
+	return os.ErrPermission
+}
+
+// Stat returns the folder or photo at name
+func (fs *LibraryFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	return fs.resolve(ctx, name)
+}
+
+// OpenFile opens the folder or photo at name for reading
+func (fs *LibraryFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, os.ErrPermission
+	}
+
+	node, err := fs.resolve(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &libraryFile{ctx: ctx, storageService: fs.storageService, node: node}, nil
+}
+
+// resolve walks from the root to the node at name
+func (fs *LibraryFS) resolve(ctx context.Context, name string) (*libraryNode, error) {
+	node := fs.root()
+	clean := strings.Trim(path.Clean("/"+name), "/")
+	if clean == "" {
+		return node, nil
+	}
+
+	for _, part := range strings.Split(clean, "/") {
+		if !node.IsDir() {
+			return nil, os.ErrNotExist
+		}
+		child, err := node.child(ctx, part)
+		if err != nil {
+			return nil, err
+		}
+		node = child
+	}
+	return node, nil
+}
+
+func (fs *LibraryFS) root() *libraryNode {
+	return &libraryNode{
+		name:    "/",
+		modTime: fs.createdAt,
+		list: func(ctx context.Context) ([]*libraryNode, error) {
+			return []*libraryNode{fs.byDateDir(), fs.collectionsDir(), fs.devicesDir()}, nil
+		},
+	}
+}
+
+// fourDigits matches a year folder name
+var fourDigits = regexp.MustCompile(`^\d{4}$`)
+
+func (fs *LibraryFS) byDateDir() *libraryNode {
+	return &libraryNode{
+		name:    LibraryDirByDate,
+		modTime: fs.createdAt,
+		list: func(ctx context.Context) ([]*libraryNode, error) {
+			facets, err := fs.photoRepo.GetSearchFacets(ctx, &models.PhotoSearchQuery{UserID: fs.userID})
+			if err != nil {
+				return nil, fmt.Errorf("failed to list years: %w", err)
+			}
+			years := []*libraryNode{}
+			for _, facet := range facets.Years {
+				if year, err := strconv.Atoi(facet.Value); err == nil {
+					years = append(years, fs.yearDir(year))
+				}
+			}
+			return years, nil
+		},
+		lookup: func(ctx context.Context, name string) (*libraryNode, error) {
+			if !fourDigits.MatchString(name) {
+				return nil, os.ErrNotExist
+			}
+			year, _ := strconv.Atoi(name)
+			from := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+			if err := fs.requirePhotos(ctx, from, from.AddDate(1, 0, 0)); err != nil {
+				return nil, err
+			}
+			return fs.yearDir(year), nil
+		},
+	}
+}
+
+func (fs *LibraryFS) yearDir(year int) *libraryNode {
+	start := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &libraryNode{
+		name:    strconv.Itoa(year),
+		modTime: start,
+		list: func(ctx context.Context) ([]*libraryNode, error) {
+			months := []*libraryNode{}
+			for month := 1; month <= 12; month++ {
+				from := start.AddDate(0, month-1, 0)
+				err := fs.requirePhotos(ctx, from, from.AddDate(0, 1, 0))
+				if os.IsNotExist(err) {
+					continue
+				}
+				if err != nil {
+					return nil, err
+				}
+				months = append(months, fs.monthDir(from))
+			}
+			return months, nil
+		},
+		lookup: func(ctx context.Context, name string) (*libraryNode, error) {
+			month, err := strconv.Atoi(name)
+			if err != nil || len(name) != 2 || month < 1 || month > 12 {
+				return nil, os.ErrNotExist
+			}
+			from := start.AddDate(0, month-1, 0)
+			if err := fs.requirePhotos(ctx, from, from.AddDate(0, 1, 0)); err != nil {
+				return nil, err
+			}
+			return fs.monthDir(from), nil
+		},
+	}
+}
+
+func (fs *LibraryFS) monthDir(start time.Time) *libraryNode {
+	return &libraryNode{
+		name:    start.Format("01"),
+		modTime: start,
+		list: func(ctx context.Context) ([]*libraryNode, error) {
+			dateTo := start.AddDate(0, 1, 0).Add(-time.Nanosecond)
+			photos, err := fs.searchAll(ctx, &models.PhotoSearchQuery{UserID: fs.userID, DateFrom: &start, DateTo: &dateTo})
+			if err != nil {
+				return nil, err
+			}
+			return photoNodes(photos), nil
+		},
+	}
+}
+
+func (fs *LibraryFS) collectionsDir() *libraryNode {
+	return &libraryNode{
+		name:    LibraryDirCollections,
+		modTime: fs.createdAt,
+		list: func(ctx context.Context) ([]*libraryNode, error) {
+			collections, err := fs.collectionRepo.GetAllForUser(ctx, fs.userID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list collections: %w", err)
+			}
+			sort.Slice(collections, func(i, j int) bool {
+				return collections[i].CreatedAt.Before(collections[j].CreatedAt)
+			})
+
+			used := make(map[string]bool)
+			dirs := []*libraryNode{}
+			for _, collection := range collections {
+				collectionID := collection.ID
+				dirs = append(dirs, &libraryNode{
+					name:    uniqueFolderName(collection.Name, collection.ID, used),
+					modTime: collection.UpdatedAt,
+					list: func(ctx context.Context) ([]*libraryNode, error) {
+						photos, err := fs.collectionPhotoRepo.GetPhotosForCollection(ctx, collectionID)
+						if err != nil {
+							return nil, fmt.Errorf("failed to list collection photos: %w", err)
+						}
+						return photoNodes(photos), nil
+					},
+				})
+			}
+			return dirs, nil
+		},
+	}
+}
+
+func (fs *LibraryFS) devicesDir() *libraryNode {
+	return &libraryNode{
+		name:    LibraryDirDevices,
+		modTime: fs.createdAt,
+		list: func(ctx context.Context) ([]*libraryNode, error) {
+			devices, err := fs.deviceRepo.GetAllForUser(ctx, fs.userID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list devices: %w", err)
+			}
+			sort.Slice(devices, func(i, j int) bool {
+				return devices[i].RegisteredAt.Before(devices[j].RegisteredAt)
+			})
+
+			used := make(map[string]bool)
+			dirs := []*libraryNode{}
+			for _, device := range devices {
+				deviceID := device.ID
+				dirs = append(dirs, &libraryNode{
+					name:    uniqueFolderName(device.DeviceName, device.ID, used),
+					modTime: device.LastSeenAt,
+					list: func(ctx context.Context) ([]*libraryNode, error) {
+						photos, err := fs.searchAll(ctx, &models.PhotoSearchQuery{UserID: fs.userID, DeviceID: deviceID})
+						if err != nil {
+							return nil, err
+						}
+						return photoNodes(photos), nil
+					},
+				})
+			}
+			return dirs, nil
+		},
+	}
+}
+
+// requirePhotos returns os.ErrNotExist unless the user has a photo taken in [from, to)
+func (fs *LibraryFS) requirePhotos(ctx context.Context, from, to time.Time) error {
+	dateTo := to.Add(-time.Nanosecond)
+	photos, _, err := fs.photoRepo.Search(ctx, &models.PhotoSearchQuery{
+		UserID: fs.userID, DateFrom: &from, DateTo: &dateTo, Limit: 1,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list photos: %w", err)
+	}
+	if len(photos) == 0 {
+		return os.ErrNotExist
+	}
+	return nil
+}
+
+// searchAll reads every page of a search
+func (fs *LibraryFS) searchAll(ctx context.Context, q *models.PhotoSearchQuery) ([]*models.Photo, error) {
+	return searchAllPhotos(ctx, fs.photoRepo, q, libraryPageSize)
+}
+
+// searchAllPhotos reads every page of a search, newest first
+func searchAllPhotos(ctx context.Context, photoRepo repository.PhotoRepo, q *models.PhotoSearchQuery, pageSize int) ([]*models.Photo, error) {
+	q.Limit = pageSize
+	var all []*models.Photo
+	for {
+		photos, next, err := photoRepo.Search(ctx, q)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list photos: %w", err)
+		}
+		all = append(all, photos...)
+		if next == "" {
+			return all, nil
+		}
+		q.Cursor = next
+	}
+}
+
+// photoNodes names the photos of one folder. The earliest upload keeps its
+// original filename and later ones with the same name are numbered the way
+// exports number them, so a new upload never renames a file already listed.
+func photoNodes(photos []*models.Photo) []*libraryNode {
+	sorted := append([]*models.Photo(nil), photos...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if !sorted[i].UploadedAt.Equal(sorted[j].UploadedAt) {
+			return sorted[i].UploadedAt.Before(sorted[j].UploadedAt)
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	used := make(map[string]bool)
+	nodes := make([]*libraryNode, 0, len(sorted))
+	for _, photo := range sorted {
+		name := path.Base(exportPath(photo, used))
+		nodes = append(nodes, &libraryNode{name: name, modTime: photo.DateTaken, photo: photo})
+	}
+	return nodes
+}
+
+// uniqueFolderName turns a collection or device name into a folder name,
+// numbering repeats and falling back to the ID for names that cannot be used
+func uniqueFolderName(name, id string, used map[string]bool) string {
+	name = strings.TrimSpace(strings.NewReplacer("/", "_", "\\", "_").Replace(name))
+	if name == "" || name == "." || name == ".." {
+		name = id
+	}
+
+	unique := name
+	for counter := 1; used[strings.ToLower(unique)]; counter++ {
+		unique = fmt.Sprintf("%s_%03d", name, counter)
+	}
+	used[strings.ToLower(unique)] = true
+	return unique
+}
+
+// libraryNode is a folder or photo in a LibraryFS. It is its own os.FileInfo.
+type libraryNode struct {
+	name    string
+	modTime time.Time
+	photo   *models.Photo // Nil for folders
+
+	list   func(ctx context.Context) ([]*libraryNode, error)
+	lookup func(ctx context.Context, name string) (*libraryNode, error) // Optional; saves listing the folder
+}
+
+func (n *libraryNode) child(ctx context.Context, name string) (*libraryNode, error) {
+	if n.lookup != nil {
+		return n.lookup(ctx, name)
+	}
+	children, err := n.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range children {
+		if c.name == name {
+			return c, nil
+		}
+	}
+	return nil, os.ErrNotExist
+}
+
+func (n *libraryNode) Name() string       { return n.name }
+func (n *libraryNode) ModTime() time.Time { return n.modTime }
+func (n *libraryNode) IsDir() bool        { return n.photo == nil }
+func (n *libraryNode) Sys() interface{}   { return nil }
+
+func (n *libraryNode) Size() int64 {
+	if n.photo == nil {
+		return 0
+	}
+	return n.photo.FileSize
+}
+
+func (n *libraryNode) Mode() os.FileMode {
+	if n.photo == nil {
+		return os.ModeDir | 0555
+	}
+	return 0444
+}
+
+// ETag implements webdav.ETager. A photo's content hash never changes, so
+// clients can cache originals for good.
+func (n *libraryNode) ETag(ctx context.Context) (string, error) {
+	if n.photo == nil {
+		return "", webdav.ErrNotImplemented
+	}
+	return `"` + n.photo.FileHash + `"`, nil
+}
+
+// libraryFile is an open folder or photo. A photo's original is only opened
+// when it is first read, since PROPFIND opens files without reading them.
+type libraryFile struct {
+	ctx            context.Context
+	storageService *PhotoStorageService
+	node           *libraryNode
+
+	reader  io.ReadSeekCloser
+	entries []os.FileInfo
+	listed  bool
+	pos     int
+}
+
+func (f *libraryFile) open() error {
+	if f.node.IsDir() {
+		return os.ErrInvalid
+	}
+	if f.reader == nil {
+		reader, _, err := f.storageService.Open(f.ctx, f.node.photo.StoredPath)
+		if err != nil {
+			return err
+		}
+		f.reader = reader
+	}
+	return nil
+}
+
+func (f *libraryFile) Read(p []byte) (int, error) {
+	if err := f.open(); err != nil {
+		return 0, err
+	}
+	return f.reader.Read(p)
+}
+
+func (f *libraryFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.open(); err != nil {
+		return 0, err
+	}
+	return f.reader.Seek(offset, whence)
+}
+
+func (f *libraryFile) Write(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+func (f *libraryFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !f.node.IsDir() {
+		return nil, os.ErrInvalid
+	}
+	if !f.listed {
+		children, err := f.node.list(f.ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range children {
+			f.entries = append(f.entries, c)
+		}
+		f.listed = true
+	}
+
+	remaining := f.entries[f.pos:]
+	if count <= 0 {
+		f.pos = len(f.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(remaining) {
+		count = len(remaining)
+	}
+	f.pos += count
+	return remaining[:count], nil
+}
+
+func (f *libraryFile) Stat() (os.FileInfo, error) {
+	return f.node, nil
+}
+
+func (f *libraryFile) Close() error {
+	if f.reader != nil {
+		return f.reader.Close()
+	}
+	return nil
+}
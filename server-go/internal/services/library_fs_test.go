@@ -0,0 +1,110 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/webdav"
+)
+
+func TestLibraryFS(t *testing.T) {
+	ctx := context.Background()
+	db, tempDir := setupTestDB(t)
+	photoRepo := repository.NewPhotoRepository(db)
+	storage := newTestStorage(t, tempDir)
+	uploadService := newTestUploadService(t, photoRepo, storage, tempDir)
+	collectionRepo := repository.NewCollectionRepository(db)
+	collectionPhotoRepo := repository.NewCollectionPhotoRepository(db)
+	deviceRepo := repository.NewDeviceRepository(db)
+
+	upload := func(userID, name, content string, taken time.Time) string {
+		result, err := uploadService.Process(ctx, strings.NewReader(content), int64(len(content)), UploadOptions{
+			OriginalFilename: name, UserID: userID, DateTaken: &taken,
+		})
+		require.NoError(t, err)
+		return result.ID
+	}
+	march := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	first := upload("user-1", "IMG_0001.jpg", "first", march)
+	second := upload("user-1", "IMG_0001.jpg", "second", march.Add(time.Hour))
+	upload("user-1", "IMG_0002.jpg", "july", time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC))
+	upload("user-2", "other.jpg", "not mine", march)
+
+	collection, err := models.NewCollection("user-1", "Trip / 2024")
+	require.NoError(t, err)
+	require.NoError(t, collectionRepo.Add(ctx, collection))
+	require.NoError(t, collectionPhotoRepo.AddMultiple(ctx, collection.ID, "user-1", []string{second}))
+
+	device, err := models.NewDevice("user-1", "Pixel", "android", "token")
+	require.NoError(t, err)
+	require.NoError(t, deviceRepo.Add(ctx, device))
+	require.NoError(t, photoRepo.SetOriginDevice(ctx, first, device.ID))
+
+	fs := NewLibraryFS(&models.User{ID: "user-1"}, photoRepo, collectionRepo, collectionPhotoRepo, deviceRepo, storage)
+
+	names := func(dir string) []string {
+		f, err := fs.OpenFile(ctx, dir, os.O_RDONLY, 0)
+		require.NoError(t, err)
+		defer f.Close()
+		infos, err := f.Readdir(0)
+		require.NoError(t, err)
+		var result []string
+		for _, info := range infos {
+			result = append(result, info.Name())
+		}
+		return result
+	}
+
+	assert.ElementsMatch(t, []string{"by-date", "collections", "devices"}, names("/"))
+	assert.Equal(t, []string{"2024"}, names("/by-date"))
+	assert.Equal(t, []string{"03", "07"}, names("/by-date/2024"))
+	assert.Equal(t, []string{"IMG_0001.jpg", "IMG_0001_001.jpg"}, names("/by-date/2024/03"))
+	assert.Equal(t, []string{"Trip _ 2024"}, names("/collections"))
+	assert.Equal(t, []string{"IMG_0001.jpg"}, names("/collections/Trip _ 2024"))
+	assert.Equal(t, []string{"Pixel"}, names("/devices"))
+	assert.Equal(t, []string{"IMG_0001.jpg"}, names("/devices/Pixel"))
+
+	info, err := fs.Stat(ctx, "/by-date/2024/03/IMG_0001_001.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len("second")), info.Size())
+	assert.True(t, info.ModTime().Equal(march.Add(time.Hour)), "modification time is the date taken")
+
+	f, err := fs.OpenFile(ctx, "/by-date/2024/03/IMG_0001_001.jpg", os.O_RDONLY, 0)
+	require.NoError(t, err)
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+	f.Close()
+	assert.Equal(t, "second", string(content))
+
+	for _, missing := range []string{"/by-date/2023", "/by-date/2024/04", "/by-date/2024/3", "/collections/Nope", "/by-date/2024/03/other.jpg"} {
+		_, err := fs.Stat(ctx, missing)
+		assert.True(t, os.IsNotExist(err), missing)
+	}
+
+	_, err = fs.OpenFile(ctx, "/by-date/2024/03/new.jpg", os.O_RDWR|os.O_CREATE, 0644)
+	assert.Equal(t, os.ErrPermission, err)
+	assert.Equal(t, os.ErrPermission, fs.Mkdir(ctx, "/collections/New", 0755))
+
+	// PROPFIND reports sizes and dates the way clients see them
+	dav := &webdav.Handler{Prefix: "/dav", FileSystem: fs, LockSystem: webdav.NewMemLS()}
+	req := httptest.NewRequest("PROPFIND", "/dav/by-date/2024/03/", bytes.NewReader(nil))
+	req.Header.Set("Depth", "1")
+	rec := httptest.NewRecorder()
+	dav.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMultiStatus, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, "<D:href>/dav/by-date/2024/03/IMG_0001_001.jpg</D:href>")
+	assert.Contains(t, body, "<D:getcontentlength>6</D:getcontentlength>")
+	assert.Contains(t, body, "<D:getlastmodified>Fri, 15 Mar 2024 11:00:00 GMT</D:getlastmodified>")
+}
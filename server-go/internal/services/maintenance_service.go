@@ -3,11 +3,10 @@ package services
 import (
 	"context"
 	"log"
-	"os"
-	"path/filepath"
 	"sync"
 	"time"
 
+	"github.com/photosync/server/internal/models"
 	"github.com/photosync/server/internal/repository"
 )
 
@@ -19,6 +18,10 @@ type MaintenanceStatus struct {
 	LastRunDuration   string    `json:"lastRunDuration,omitempty"`
 	OrphansRemoved    int       `json:"orphansRemoved"`
 	ThumbsGenerated   int       `json:"thumbsGenerated"`
+	TrashPurged       int       `json:"trashPurged"`
+	HashesComputed    int       `json:"hashesComputed"`
+	DuplicateGroups   int       `json:"duplicateGroups"`
+	ChangesCompacted  int       `json:"changesCompacted"`
 	Errors            []string  `json:"errors,omitempty"`
 	NextScheduledRun  time.Time `json:"nextScheduledRun,omitempty"`
 }
@@ -27,7 +30,11 @@ type MaintenanceStatus struct {
 type MaintenanceService struct {
 	photoRepo        repository.PhotoRepo
 	thumbnailService *ThumbnailService
-	storagePath      string
+	trashService     *TrashService
+	duplicateService *DuplicateService
+	changeRepo       repository.PhotoChangeRepo
+	changeRetention  time.Duration
+	libraryEvents    *LibraryEventService
 
 	mu         sync.RWMutex
 	enabled    bool
@@ -41,12 +48,14 @@ type MaintenanceService struct {
 func NewMaintenanceService(
 	photoRepo repository.PhotoRepo,
 	thumbnailService *ThumbnailService,
-	storagePath string,
+	trashService *TrashService,
+	duplicateService *DuplicateService,
 ) *MaintenanceService {
 	return &MaintenanceService{
 		photoRepo:        photoRepo,
 		thumbnailService: thumbnailService,
-		storagePath:      storagePath,
+		trashService:     trashService,
+		duplicateService: duplicateService,
 		stopChan:         make(chan struct{}),
 		enabled:          true,
 		status: MaintenanceStatus{
@@ -56,6 +65,22 @@ func NewMaintenanceService(
 	}
 }
 
+// SetChangeLog enables compaction of the sync change log. Tombstones older
+// than retentionDays are dropped along with entries superseded by newer ones.
+func (s *MaintenanceService) SetChangeLog(changeRepo repository.PhotoChangeRepo, retentionDays int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changeRepo = changeRepo
+	s.changeRetention = time.Duration(retentionDays) * 24 * time.Hour
+}
+
+// SetLibraryEvents enables real-time events when missing thumbnails are generated
+func (s *MaintenanceService) SetLibraryEvents(libraryEvents *LibraryEventService) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.libraryEvents = libraryEvents
+}
+
 // Start begins the background maintenance loop
 func (s *MaintenanceService) Start() {
 	s.mu.Lock()
@@ -152,6 +177,23 @@ func (s *MaintenanceService) runMaintenance() {
 	// Task 2: Generate missing thumbnails
 	thumbsGenerated, thumbErrors := s.generateMissingThumbnails(ctx)
 
+	// Task 3: Purge photos that have been in the trash past the retention period
+	trashPurged, trashErrors := s.trashService.PurgeExpired(ctx)
+	for _, errMsg := range trashErrors {
+		log.Printf("Maintenance: %s", errMsg)
+	}
+
+	// Task 4: Hash photos for near-duplicate detection and regroup them
+	hashesComputed, hashErrors := s.duplicateService.BackfillPerceptualHashes(ctx)
+	duplicateGroups, groupErrors := s.duplicateService.RebuildGroups(ctx)
+	duplicateErrors := append(hashErrors, groupErrors...)
+	for _, errMsg := range duplicateErrors {
+		log.Printf("Maintenance: %s", errMsg)
+	}
+
+	// Task 5: Compact the sync change log
+	changesCompacted, compactErrors := s.compactChangeLog(ctx)
+
 	duration := time.Since(startTime)
 
 	s.mu.Lock()
@@ -161,7 +203,11 @@ func (s *MaintenanceService) runMaintenance() {
 	s.status.LastRunDuration = duration.Round(time.Millisecond).String()
 	s.status.OrphansRemoved = orphansRemoved
 	s.status.ThumbsGenerated = thumbsGenerated
-	s.status.Errors = append(orphanErrors, thumbErrors...)
+	s.status.TrashPurged = trashPurged
+	s.status.HashesComputed = hashesComputed
+	s.status.DuplicateGroups = duplicateGroups
+	s.status.ChangesCompacted = changesCompacted
+	s.status.Errors = append(append(append(append(orphanErrors, thumbErrors...), trashErrors...), duplicateErrors...), compactErrors...)
 	s.mu.Unlock()
 
 	if orphansRemoved > 0 {
@@ -170,13 +216,41 @@ func (s *MaintenanceService) runMaintenance() {
 	if thumbsGenerated > 0 {
 		log.Printf("Maintenance: Generated thumbnails for %d photos", thumbsGenerated)
 	}
-	if len(orphanErrors) > 0 || len(thumbErrors) > 0 {
-		log.Printf("Maintenance: Completed with %d errors", len(orphanErrors)+len(thumbErrors))
+	if trashPurged > 0 {
+		log.Printf("Maintenance: Purged %d photos from the trash", trashPurged)
+	}
+	if hashesComputed > 0 {
+		log.Printf("Maintenance: Computed perceptual hashes for %d photos", hashesComputed)
+	}
+	if changesCompacted > 0 {
+		log.Printf("Maintenance: Compacted %d change log entries", changesCompacted)
+	}
+	if errorCount := len(orphanErrors) + len(thumbErrors) + len(trashErrors) + len(duplicateErrors) + len(compactErrors); errorCount > 0 {
+		log.Printf("Maintenance: Completed with %d errors", errorCount)
 	}
 
 	log.Printf("Maintenance tasks completed in %s", duration.Round(time.Millisecond))
 }
 
+// compactChangeLog drops superseded change log entries and expired tombstones
+func (s *MaintenanceService) compactChangeLog(ctx context.Context) (int, []string) {
+	s.mu.RLock()
+	changeRepo, retention := s.changeRepo, s.changeRetention
+	s.mu.RUnlock()
+
+	if changeRepo == nil {
+		return 0, nil
+	}
+
+	removed, err := changeRepo.Compact(ctx, time.Now().UTC().Add(-retention))
+	if err != nil {
+		errMsg := "Failed to compact change log: " + err.Error()
+		log.Printf("Maintenance: %s", errMsg)
+		return 0, []string{errMsg}
+	}
+	return removed, nil
+}
+
 // cleanupOrphanedPhotos removes photos without an owner
 func (s *MaintenanceService) cleanupOrphanedPhotos(ctx context.Context) (int, []string) {
 	var errors []string
@@ -191,27 +265,8 @@ func (s *MaintenanceService) cleanupOrphanedPhotos(ctx context.Context) (int, []
 
 	removed := 0
 	for _, photo := range photos {
-		// Delete the actual file
-		filePath := filepath.Join(s.storagePath, photo.StoredPath)
-		if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
-			errMsg := "Failed to delete file " + photo.StoredPath + ": " + err.Error()
-			log.Printf("Maintenance: %s", errMsg)
-			errors = append(errors, errMsg)
-		}
-
-		// Delete thumbnails if they exist
-		if photo.ThumbSmall != nil {
-			os.Remove(filepath.Join(s.storagePath, *photo.ThumbSmall))
-		}
-		if photo.ThumbMedium != nil {
-			os.Remove(filepath.Join(s.storagePath, *photo.ThumbMedium))
-		}
-		if photo.ThumbLarge != nil {
-			os.Remove(filepath.Join(s.storagePath, *photo.ThumbLarge))
-		}
-
-		// Delete from database
-		if _, err := s.photoRepo.Delete(ctx, photo.ID); err != nil {
+		// Removes the row, thumbnails and rendition; the file goes with its last reference
+		if err := s.trashService.purge(ctx, photo); err != nil {
 			errMsg := "Failed to delete photo " + photo.ID + " from DB: " + err.Error()
 			log.Printf("Maintenance: %s", errMsg)
 			errors = append(errors, errMsg)
@@ -228,6 +283,10 @@ func (s *MaintenanceService) cleanupOrphanedPhotos(ctx context.Context) (int, []
 func (s *MaintenanceService) generateMissingThumbnails(ctx context.Context) (int, []string) {
 	var errors []string
 
+	s.mu.RLock()
+	libraryEvents := s.libraryEvents
+	s.mu.RUnlock()
+
 	// Process in batches
 	batchSize := 50
 	totalGenerated := 0
@@ -248,11 +307,11 @@ func (s *MaintenanceService) generateMissingThumbnails(ctx context.Context) (int
 
 		for _, photo := range photos {
 			// Skip unsupported formats silently
-			if !IsSupportedFormat(photo.StoredPath) {
+			if !s.thumbnailService.CanGenerateThumbnails(photo.StoredPath) {
 				continue
 			}
 
-			result, err := s.thumbnailService.RegenerateThumbnailsFromFile(photo.ID, photo.StoredPath)
+			result, err := s.thumbnailService.RegenerateThumbnailsFromFile(photo.ID, photo.StoredPath, photo.Orientation)
 			if err != nil {
 				// Log errors but don't add to errors list (too noisy)
 				log.Printf("Maintenance: Failed to generate thumbnails for %s: %v", photo.ID, err)
@@ -267,6 +326,13 @@ func (s *MaintenanceService) generateMissingThumbnails(ctx context.Context) (int
 				continue
 			}
 
+			if libraryEvents != nil && photo.UserID != nil {
+				photo.ThumbSmall = &result.SmallPath
+				photo.ThumbMedium = &result.MediumPath
+				photo.ThumbLarge = &result.LargePath
+				libraryEvents.Publish(*photo.UserID, models.NewPhotoEvent(models.LibraryEventThumbnailsReady, photo))
+			}
+
 			totalGenerated++
 		}
 
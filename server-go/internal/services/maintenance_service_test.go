@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func changeTypes(t *testing.T, changeRepo *repository.PhotoChangeRepository, userID string, since int64) []models.PhotoChangeType {
+	changes, err := changeRepo.GetSince(context.Background(), userID, since, 100)
+	require.NoError(t, err)
+
+	types := make([]models.PhotoChangeType, len(changes))
+	for i, c := range changes {
+		types[i] = c.Type
+	}
+	return types
+}
+
+func TestMaintenanceService_CompactChangeLog(t *testing.T) {
+	ctx := context.Background()
+	db, tempDir := setupTestDB(t)
+	photoRepo := repository.NewPhotoRepository(db)
+	changeRepo := repository.NewPhotoChangeRepository(db)
+	trashService, storage := newTestTrashService(t, photoRepo, tempDir)
+
+	kept := addTestPhoto(t, photoRepo, storage, "user-1", "aaa111")
+	trashed := addTestPhoto(t, photoRepo, storage, "user-1", "bbb222")
+	moved := addTestPhoto(t, photoRepo, storage, "user-1", "ccc333")
+
+	require.NoError(t, trashService.MoveToTrash(ctx, kept.ID))
+	require.NoError(t, trashService.Restore(ctx, "user-1", kept.ID))
+	require.NoError(t, trashService.MoveToTrash(ctx, trashed.ID))
+
+	user2 := "user-2"
+	moved.UserID = &user2
+	require.NoError(t, photoRepo.Update(ctx, moved))
+
+	// A device that synced after the first upload
+	clientSeq := int64(1)
+
+	assert.Equal(t, []models.PhotoChangeType{
+		models.PhotoChangeAdded, models.PhotoChangeAdded, models.PhotoChangeAdded,
+		models.PhotoChangeDeleted, models.PhotoChangeAdded, models.PhotoChangeDeleted,
+		models.PhotoChangeDeleted,
+	}, changeTypes(t, changeRepo, "user-1", 0))
+	assert.Equal(t, []models.PhotoChangeType{models.PhotoChangeClaimed}, changeTypes(t, changeRepo, "user-2", 0))
+
+	latest, err := changeRepo.GetLatestSeq(ctx, "user-1")
+	require.NoError(t, err)
+
+	maintenance := NewMaintenanceService(photoRepo, nil, trashService, nil)
+	maintenance.SetChangeLog(changeRepo, 0)
+	removed, errs := maintenance.compactChangeLog(ctx)
+	require.Empty(t, errs)
+	assert.Equal(t, 6, removed, "superseded entries and expired tombstones are dropped")
+
+	// Only the restored photo's latest entry and user-2's claim survive
+	changes, err := changeRepo.GetSince(ctx, "user-1", 0, 100)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, kept.ID, changes[0].PhotoID)
+	assert.Equal(t, models.PhotoChangeAdded, changes[0].Type)
+	assert.Equal(t, []models.PhotoChangeType{models.PhotoChangeClaimed}, changeTypes(t, changeRepo, "user-2", 0))
+
+	floor, err := changeRepo.GetFloor(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Less(t, clientSeq, floor, "the device may have missed compacted tombstones")
+
+	latestAfter, err := changeRepo.GetLatestSeq(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, latest, latestAfter, "compaction never moves the latest sequence backwards")
+}
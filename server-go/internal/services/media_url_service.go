@@ -0,0 +1,141 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+)
+
+// MediaURLPrefix is the path the signed media endpoint is served under
+const MediaURLPrefix = "/media/"
+
+// MediaURLService signs and verifies time-limited media URLs. A URL carries
+// its expiry and an HMAC-SHA256 over the photo ID, variant and expiry, so the
+// media endpoint needs no API key, session or database lookup to trust it.
+//
+// Expiry times are rounded up to a fixed step, so a photo keeps the same URL
+// across responses for a while and browsers and image loaders can cache it.
+type MediaURLService struct {
+	secret []byte
+	ttl    time.Duration
+	step   time.Duration
+	now    func() time.Time
+}
+
+// NewMediaURLService creates a new MediaURLService. URLs are valid for at
+// least ttlMinutes. Without a secret a random one is used, which invalidates
+// issued URLs whenever the server restarts.
+func NewMediaURLService(secret string, ttlMinutes int) (*MediaURLService, error) {
+	if ttlMinutes <= 0 {
+		ttlMinutes = 60
+	}
+	key := []byte(secret)
+	if secret == "" {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+
+	ttl := time.Duration(ttlMinutes) * time.Minute
+	step := ttl / 4
+	if step < time.Minute {
+		step = time.Minute
+	}
+	return &MediaURLService{secret: key, ttl: ttl, step: step, now: time.Now}, nil
+}
+
+// URLs returns signed URLs for a photo's original and each thumbnail size
+func (s *MediaURLService) URLs(photo *models.Photo) *models.MediaURLs {
+	expires := s.expiry()
+	return &models.MediaURLs{
+		Original:  s.sign(photo.ID, models.MediaOriginal, expires),
+		Small:     s.sign(photo.ID, models.MediaSmall, expires),
+		Medium:    s.sign(photo.ID, models.MediaMedium, expires),
+		Large:     s.sign(photo.ID, models.MediaLarge, expires),
+		ExpiresAt: expires,
+	}
+}
+
+// Attach sets the signed URLs on each photo
+func (s *MediaURLService) Attach(photos ...*models.Photo) {
+	for _, photo := range photos {
+		if photo != nil {
+			photo.Media = s.URLs(photo)
+		}
+	}
+}
+
+// AttachStripped sets signed URLs on each photo whose original is served
+// with strip's metadata removed. The thumbnail URLs carry the mode too, so
+// the media endpoint never falls back to the stored original for them.
+func (s *MediaURLService) AttachStripped(strip models.MetadataStripping, photos ...*models.Photo) {
+	s.Attach(photos...)
+	if !strip.Strips() {
+		return
+	}
+
+	for _, photo := range photos {
+		if photo == nil {
+			continue
+		}
+		media := photo.Media
+		media.Original = s.sign(photo.ID, strip.Variant(models.MediaOriginal), media.ExpiresAt)
+		media.Small = s.sign(photo.ID, strip.Variant(models.MediaSmall), media.ExpiresAt)
+		media.Medium = s.sign(photo.ID, strip.Variant(models.MediaMedium), media.ExpiresAt)
+		media.Large = s.sign(photo.ID, strip.Variant(models.MediaLarge), media.ExpiresAt)
+	}
+}
+
+// URL returns a signed URL for one variant of a photo
+func (s *MediaURLService) URL(photoID string, variant models.MediaVariant) string {
+	return s.sign(photoID, variant, s.expiry())
+}
+
+// Verify checks a media URL's expiry and signature and returns the expiry
+func (s *MediaURLService) Verify(photoID string, variant models.MediaVariant, exp, sig string) (time.Time, error) {
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return time.Time{}, models.ErrMediaURLInvalid
+	}
+	given, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(given, s.mac(photoID, variant, unix)) {
+		return time.Time{}, models.ErrMediaURLInvalid
+	}
+
+	expires := time.Unix(unix, 0).UTC()
+	if !s.now().Before(expires) {
+		return time.Time{}, models.ErrMediaURLExpired
+	}
+	return expires, nil
+}
+
+// expiry returns the expiry for URLs issued now: at least the TTL away,
+// rounded up to the next step
+func (s *MediaURLService) expiry() time.Time {
+	earliest := s.now().Add(s.ttl)
+	expires := earliest.Truncate(s.step)
+	if expires.Before(earliest) {
+		expires = expires.Add(s.step)
+	}
+	return expires.UTC()
+}
+
+func (s *MediaURLService) sign(photoID string, variant models.MediaVariant, expires time.Time) string {
+	q := url.Values{}
+	q.Set("exp", strconv.FormatInt(expires.Unix(), 10))
+	q.Set("sig", hex.EncodeToString(s.mac(photoID, variant, expires.Unix())))
+	return MediaURLPrefix + url.PathEscape(photoID) + "/" + string(variant) + "?" + q.Encode()
+}
+
+func (s *MediaURLService) mac(photoID string, variant models.MediaVariant, expires int64) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(photoID + "\n" + string(variant) + "\n" + strconv.FormatInt(expires, 10)))
+	return h.Sum(nil)
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMediaURLService(t *testing.T) {
+	svc, err := NewMediaURLService("test-secret", 60)
+	require.NoError(t, err)
+	now := time.Date(2024, 3, 15, 10, 7, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	verify := func(raw string) (time.Time, error) {
+		u, err := url.Parse(raw)
+		require.NoError(t, err)
+		parts := strings.Split(strings.TrimPrefix(u.Path, MediaURLPrefix), "/")
+		require.Len(t, parts, 2)
+		return svc.Verify(parts[0], models.MediaVariant(parts[1]), u.Query().Get("exp"), u.Query().Get("sig"))
+	}
+
+	urls := svc.URLs(&models.Photo{ID: "photo-1"})
+	assert.True(t, strings.HasPrefix(urls.Medium, "/media/photo-1/medium?"))
+	assert.Equal(t, time.Date(2024, 3, 15, 11, 15, 0, 0, time.UTC), urls.ExpiresAt, "at least the TTL, rounded up to a quarter of it")
+
+	expires, err := verify(urls.Original)
+	require.NoError(t, err)
+	assert.Equal(t, urls.ExpiresAt, expires)
+
+	// URLs stay the same within a step, so clients can cache them
+	now = now.Add(5 * time.Minute)
+	assert.Equal(t, urls.Small, svc.URL("photo-1", models.MediaSmall))
+
+	// A signature is bound to the photo and the variant
+	_, err = verify(strings.Replace(urls.Small, "/small?", "/large?", 1))
+	assert.Equal(t, models.ErrMediaURLInvalid, err)
+	_, err = verify(strings.Replace(urls.Small, "photo-1", "photo-2", 1))
+	assert.Equal(t, models.ErrMediaURLInvalid, err)
+
+	other, err := NewMediaURLService("other-secret", 60)
+	require.NoError(t, err)
+	_, err = verify(other.URL("photo-1", models.MediaSmall))
+	assert.Equal(t, models.ErrMediaURLInvalid, err)
+
+	now = urls.ExpiresAt
+	_, err = verify(urls.Small)
+	assert.Equal(t, models.ErrMediaURLExpired, err)
+}
+
+func TestMediaURLService_AttachStripped(t *testing.T) {
+	svc, err := NewMediaURLService("test-secret", 60)
+	require.NoError(t, err)
+
+	medium := ".thumbs/photo-1_medium.jpg"
+	photo := &models.Photo{ID: "photo-1", ThumbMedium: &medium}
+	svc.AttachStripped(models.StripAll, photo)
+	assert.True(t, strings.HasPrefix(photo.Media.Original, "/media/photo-1/original-nometadata?"))
+	assert.True(t, strings.HasPrefix(photo.Media.Medium, "/media/photo-1/medium-nometadata?"))
+	assert.True(t, strings.HasPrefix(photo.Media.Small, "/media/photo-1/small-nometadata?"), "thumbnails carry the mode so a missing one falls back to the stripped original")
+
+	svc.AttachStripped(models.StripLocation, photo)
+	assert.True(t, strings.HasPrefix(photo.Media.Large, "/media/photo-1/large-nolocation?"))
+
+	svc.AttachStripped(models.StripNone, photo)
+	assert.True(t, strings.HasPrefix(photo.Media.Original, "/media/photo-1/original?"))
+}
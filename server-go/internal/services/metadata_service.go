@@ -1,54 +1,71 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os/exec"
 	"strings"
 	"time"
+
+	"github.com/photosync/server/internal/blobstore"
 )
 
 // MetadataService handles embedding metadata into image files
 type MetadataService struct {
-	basePath string
+	store blobstore.BlobStore
 }
 
-// NewMetadataService creates a new MetadataService
-func NewMetadataService(basePath string) *MetadataService {
+// NewMetadataService creates a new MetadataService for files in store
+func NewMetadataService(store blobstore.BlobStore) *MetadataService {
 	return &MetadataService{
-		basePath: basePath,
+		store: store,
 	}
 }
 
+// withFile runs exiftool work against a local copy of the stored file,
+// uploading it again afterwards when the work modified it
+func (s *MetadataService) withFile(storedPath string, modifies bool, fn func(fullPath string) error) error {
+	return blobstore.WithLocalFile(context.Background(), s.store, storedPath, modifies, fn)
+}
+
 // EmbedPhotoID writes the photo UUID to the image's EXIF/XMP metadata
 // Uses ImageUniqueID (EXIF) and XMP-photosync:PhotoID (custom XMP) for redundancy
 func (s *MetadataService) EmbedPhotoID(storedPath string, photoID string) error {
-	fullPath := fmt.Sprintf("%s/%s", s.basePath, storedPath)
-
-	// Use exiftool to write the photo ID to multiple metadata fields
-	// - ImageUniqueID: Standard EXIF field for unique image identifiers
-	// - XMP-photosync:PhotoID: Custom XMP field specific to our application
-	cmd := exec.Command("exiftool",
-		"-overwrite_original",
-		fmt.Sprintf("-ImageUniqueID=%s", photoID),
-		fmt.Sprintf("-XMP-photosync:PhotoID=%s", photoID),
-		fullPath,
-	)
-
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		// Log but don't fail - metadata embedding is optional
-		log.Printf("Warning: failed to embed metadata in %s: %v (output: %s)", storedPath, err, string(output))
-		return err
-	}
+	return s.withFile(storedPath, true, func(fullPath string) error {
+		// Use exiftool to write the photo ID to multiple metadata fields
+		// - ImageUniqueID: Standard EXIF field for unique image identifiers
+		// - XMP-photosync:PhotoID: Custom XMP field specific to our application
+		cmd := exec.Command("exiftool",
+			"-overwrite_original",
+			fmt.Sprintf("-ImageUniqueID=%s", photoID),
+			fmt.Sprintf("-XMP-photosync:PhotoID=%s", photoID),
+			fullPath,
+		)
+
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			// Log but don't fail - metadata embedding is optional
+			log.Printf("Warning: failed to embed metadata in %s: %v (output: %s)", storedPath, err, string(output))
+			return err
+		}
 
-	return nil
+		return nil
+	})
 }
 
 // ReadPhotoID reads the embedded photo ID from an image file
 func (s *MetadataService) ReadPhotoID(storedPath string) (string, error) {
-	fullPath := fmt.Sprintf("%s/%s", s.basePath, storedPath)
+	var photoID string
+	err := s.withFile(storedPath, false, func(fullPath string) error {
+		var err error
+		photoID, err = readPhotoID(fullPath)
+		return err
+	})
+	return photoID, err
+}
 
+func readPhotoID(fullPath string) (string, error) {
 	// Try to read ImageUniqueID first, fall back to XMP field
 	cmd := exec.Command("exiftool",
 		"-s3",
@@ -97,36 +114,68 @@ type PhotoMetadata struct {
 // EmbedFullMetadata writes all PhotoSync metadata to the image's EXIF/XMP tags
 // This includes PhotoID, UserID, DeviceID, FileHash, and UploadedAt
 func (s *MetadataService) EmbedFullMetadata(storedPath string, metadata PhotoMetadata) error {
-	fullPath := fmt.Sprintf("%s/%s", s.basePath, storedPath)
-
-	// Build exiftool arguments
-	args := []string{
-		"-overwrite_original",
-		fmt.Sprintf("-ImageUniqueID=%s", metadata.PhotoID),
-		fmt.Sprintf("-XMP-photosync:PhotoID=%s", metadata.PhotoID),
-		fmt.Sprintf("-XMP-photosync:UserID=%s", metadata.UserID),
-		fmt.Sprintf("-XMP-photosync:DeviceID=%s", metadata.DeviceID),
-		fmt.Sprintf("-XMP-photosync:FileHash=%s", metadata.FileHash),
-		fmt.Sprintf("-XMP-photosync:UploadedAt=%s", metadata.UploadedAt.Format(time.RFC3339)),
-		fullPath,
-	}
+	return s.withFile(storedPath, true, func(fullPath string) error {
+		// Build exiftool arguments
+		args := []string{
+			"-overwrite_original",
+			fmt.Sprintf("-ImageUniqueID=%s", metadata.PhotoID),
+			fmt.Sprintf("-XMP-photosync:PhotoID=%s", metadata.PhotoID),
+			fmt.Sprintf("-XMP-photosync:UserID=%s", metadata.UserID),
+			fmt.Sprintf("-XMP-photosync:DeviceID=%s", metadata.DeviceID),
+			fmt.Sprintf("-XMP-photosync:FileHash=%s", metadata.FileHash),
+			fmt.Sprintf("-XMP-photosync:UploadedAt=%s", metadata.UploadedAt.Format(time.RFC3339)),
+			fullPath,
+		}
 
-	cmd := exec.Command("exiftool", args...)
+		cmd := exec.Command("exiftool", args...)
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		log.Printf("Warning: failed to embed full metadata in %s: %v (output: %s)", storedPath, err, string(output))
-		return err
-	}
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			log.Printf("Warning: failed to embed full metadata in %s: %v (output: %s)", storedPath, err, string(output))
+			return err
+		}
+
+		return nil
+	})
+}
+
+// RemoveOwnerMetadata deletes the photo, user and device IDs from a stored
+// file and keeps its content hash and upload time. A file shared by several
+// users' photos must not name any one of them.
+func (s *MetadataService) RemoveOwnerMetadata(storedPath string) error {
+	return s.withFile(storedPath, true, func(fullPath string) error {
+		cmd := exec.Command("exiftool",
+			"-overwrite_original",
+			"-ImageUniqueID=",
+			"-XMP-photosync:PhotoID=",
+			"-XMP-photosync:UserID=",
+			"-XMP-photosync:DeviceID=",
+			fullPath,
+		)
+
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			log.Printf("Warning: failed to remove owner metadata from %s: %v (output: %s)", storedPath, err, string(output))
+			return err
+		}
 
-	return nil
+		return nil
+	})
 }
 
 // ReadFullMetadata extracts all PhotoSync metadata from an image file
 // Returns nil if no PhotoSync metadata is found
 func (s *MetadataService) ReadFullMetadata(storedPath string) (*PhotoMetadata, error) {
-	fullPath := fmt.Sprintf("%s/%s", s.basePath, storedPath)
+	var metadata *PhotoMetadata
+	err := s.withFile(storedPath, false, func(fullPath string) error {
+		var err error
+		metadata, err = readFullMetadata(fullPath)
+		return err
+	})
+	return metadata, err
+}
 
+func readFullMetadata(fullPath string) (*PhotoMetadata, error) {
 	// Read all PhotoSync XMP fields at once
 	cmd := exec.Command("exiftool",
 		"-s3",
@@ -142,7 +191,7 @@ func (s *MetadataService) ReadFullMetadata(storedPath string) (*PhotoMetadata, e
 	output, err := cmd.Output()
 	if err != nil {
 		// Try reading just ImageUniqueID as fallback for legacy files
-		return s.readLegacyMetadata(fullPath)
+		return readLegacyMetadata(fullPath)
 	}
 
 	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
@@ -187,7 +236,7 @@ func (s *MetadataService) ReadFullMetadata(storedPath string) (*PhotoMetadata, e
 }
 
 // readLegacyMetadata reads just the PhotoID from ImageUniqueID for legacy files
-func (s *MetadataService) readLegacyMetadata(fullPath string) (*PhotoMetadata, error) {
+func readLegacyMetadata(fullPath string) (*PhotoMetadata, error) {
 	cmd := exec.Command("exiftool",
 		"-s3",
 		"-ImageUniqueID",
@@ -218,34 +267,34 @@ func (s *MetadataService) HasEmbeddedMetadata(storedPath string) bool {
 // UpdateEmbeddedMetadata updates specific fields in the embedded metadata
 // This is useful when only certain fields need to change (e.g., after user assignment)
 func (s *MetadataService) UpdateEmbeddedMetadata(storedPath string, updates map[string]string) error {
-	fullPath := fmt.Sprintf("%s/%s", s.basePath, storedPath)
-
-	args := []string{"-overwrite_original"}
-
-	for field, value := range updates {
-		switch field {
-		case "PhotoID":
-			args = append(args, fmt.Sprintf("-ImageUniqueID=%s", value))
-			args = append(args, fmt.Sprintf("-XMP-photosync:PhotoID=%s", value))
-		case "UserID":
-			args = append(args, fmt.Sprintf("-XMP-photosync:UserID=%s", value))
-		case "DeviceID":
-			args = append(args, fmt.Sprintf("-XMP-photosync:DeviceID=%s", value))
-		case "FileHash":
-			args = append(args, fmt.Sprintf("-XMP-photosync:FileHash=%s", value))
-		case "UploadedAt":
-			args = append(args, fmt.Sprintf("-XMP-photosync:UploadedAt=%s", value))
+	return s.withFile(storedPath, true, func(fullPath string) error {
+		args := []string{"-overwrite_original"}
+
+		for field, value := range updates {
+			switch field {
+			case "PhotoID":
+				args = append(args, fmt.Sprintf("-ImageUniqueID=%s", value))
+				args = append(args, fmt.Sprintf("-XMP-photosync:PhotoID=%s", value))
+			case "UserID":
+				args = append(args, fmt.Sprintf("-XMP-photosync:UserID=%s", value))
+			case "DeviceID":
+				args = append(args, fmt.Sprintf("-XMP-photosync:DeviceID=%s", value))
+			case "FileHash":
+				args = append(args, fmt.Sprintf("-XMP-photosync:FileHash=%s", value))
+			case "UploadedAt":
+				args = append(args, fmt.Sprintf("-XMP-photosync:UploadedAt=%s", value))
+			}
 		}
-	}
 
-	args = append(args, fullPath)
+		args = append(args, fullPath)
 
-	cmd := exec.Command("exiftool", args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		log.Printf("Warning: failed to update metadata in %s: %v (output: %s)", storedPath, err, string(output))
-		return err
-	}
+		cmd := exec.Command("exiftool", args...)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			log.Printf("Warning: failed to update metadata in %s: %v (output: %s)", storedPath, err, string(output))
+			return err
+		}
 
-	return nil
+		return nil
+	})
 }
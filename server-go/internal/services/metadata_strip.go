@@ -0,0 +1,550 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/photosync/server/internal/blobstore"
+	"github.com/photosync/server/internal/models"
+)
+
+// errStripUnsupported means a file is not a JPEG, PNG or WebP image, so its
+// metadata can only be removed with exiftool
+var errStripUnsupported = errors.New("no built-in metadata stripper for this format")
+
+// errStripMalformed means an image could not be parsed well enough to be sure
+// all of its metadata was found
+var errStripMalformed = errors.New("malformed image")
+
+var (
+	jpegEXIFHeader = []byte("Exif\x00\x00")
+	jpegICCHeader  = []byte("ICC_PROFILE\x00")
+	pngSignature   = []byte("\x89PNG\r\n\x1a\n")
+)
+
+const (
+	tiffTagOrientation   = 0x0112
+	tiffTagExifIFD       = 0x8769
+	tiffTagGPSIFD        = 0x8825
+	tiffTagImageUniqueID = 0xA420
+)
+
+// CanStripMetadata reports whether GetStrippedRendition can remove metadata
+// from an original on this server. JPEG, PNG, WebP and HEIC/HEIF are handled
+// built in; other images and videos need exiftool.
+func CanStripMetadata(storedPath string) bool {
+	if IsHEIC(storedPath) {
+		return true
+	}
+	switch strings.ToLower(filepath.Ext(storedPath)) {
+	case ".jpg", ".jpeg", ".png", ".webp":
+		return true
+	}
+	return IsExiftoolAvailable()
+}
+
+// StrippedRenditionPath returns the relative path of the cached copy of a
+// photo with strip's metadata removed
+func StrippedRenditionPath(photoID string, storedPath string, strip models.MetadataStripping) string {
+	ext := strings.ToLower(filepath.Ext(storedPath))
+	return filepath.Join(filepath.Dir(storedPath), ".thumbs", photoID+"_strip-"+string(strip)+ext)
+}
+
+// GetStrippedRendition returns the thumbnail-store path of a copy of the
+// original with strip's metadata removed; open it with OpenThumbnail. The
+// copy is made on first request and cached next to the thumbnails; it is
+// rebuilt if the original is newer than the cache. HEIC/HEIF originals get
+// their JPEG rendition, which is encoded from the pixels alone.
+//
+// JPEG, PNG and WebP images are rewritten without touching the pixel data.
+// Other formats need exiftool; without it an error is returned, so callers
+// never fall back to serving the original.
+func (s *ThumbnailService) GetStrippedRendition(photoID string, storedPath string, orientation int, strip models.MetadataStripping) (string, error) {
+	if !strip.Strips() {
+		return "", fmt.Errorf("nothing to strip for %q", strip)
+	}
+	if IsHEIC(storedPath) {
+		return s.GetJPEGRendition(photoID, storedPath, orientation)
+	}
+
+	ctx := context.Background()
+	cachePath := StrippedRenditionPath(photoID, storedPath, strip)
+
+	sourceInfo, err := s.originals.Stat(ctx, storedPath)
+	if err != nil {
+		return "", fmt.Errorf("file not found: %s", storedPath)
+	}
+	if cacheInfo, err := s.thumbnails.Stat(ctx, cachePath); err == nil && !cacheInfo.ModTime.Before(sourceInfo.ModTime) {
+		return cachePath, nil
+	}
+
+	// Stores write atomically, so concurrent requests at worst both do the work
+	err = errStripUnsupported
+	switch strings.ToLower(filepath.Ext(storedPath)) {
+	case ".jpg", ".jpeg", ".png", ".webp":
+		var data []byte
+		data, err = blobstore.ReadAll(ctx, s.originals, storedPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read file: %w", err)
+		}
+		if data, err = stripMetadata(data, strip); err == nil {
+			err = s.thumbnails.Put(ctx, cachePath, bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				return "", fmt.Errorf("failed to write cache file: %w", err)
+			}
+		}
+	}
+	if err == errStripUnsupported {
+		if !IsExiftoolAvailable() {
+			return "", fmt.Errorf("cannot strip metadata from %s without exiftool", filepath.Ext(storedPath))
+		}
+		err = s.stripWithExiftool(ctx, storedPath, cachePath, strip)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return cachePath, nil
+}
+
+// DeleteStrippedRenditions removes the cached copies of a photo with
+// metadata removed, if any
+func (s *ThumbnailService) DeleteStrippedRenditions(photoID string, storedPath string) {
+	for _, strip := range []models.MetadataStripping{models.StripLocation, models.StripAll} {
+		s.thumbnails.Delete(context.Background(), StrippedRenditionPath(photoID, storedPath, strip))
+	}
+}
+
+// stripWithExiftool writes a copy of the original without strip's metadata
+// to cachePath. exiftool writes to a new file, so the original is only read.
+func (s *ThumbnailService) stripWithExiftool(ctx context.Context, storedPath string, cachePath string, strip models.MetadataStripping) error {
+	return blobstore.WithLocalFile(ctx, s.originals, storedPath, false, func(fullPath string) error {
+		dir, err := os.MkdirTemp("", "strip-*")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+
+		target := filepath.Join(dir, "stripped"+filepath.Ext(storedPath))
+		args := []string{"-all=", "-tagsFromFile", "@", "-ICC_Profile", "-Orientation"}
+		if strip == models.StripLocation {
+			args = []string{
+				"-gps:all=", "-xmp:all=", "-iptc:all=", "-ImageUniqueID=",
+				"-Keys:GPSCoordinates=", "-UserData:GPSCoordinates=", "-ItemList:GPSCoordinates=",
+			}
+		}
+		args = append(args, "-o", target, fullPath)
+
+		output, err := exec.CommandContext(ctx, "exiftool", args...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("exiftool failed: %v (output: %s)", err, string(output))
+		}
+
+		file, err := os.Open(target)
+		if err != nil {
+			return fmt.Errorf("exiftool wrote no file: %w", err)
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		if err := s.thumbnails.Put(ctx, cachePath, file, info.Size()); err != nil {
+			return fmt.Errorf("failed to write cache file: %w", err)
+		}
+		return nil
+	})
+}
+
+// stripMetadata returns a copy of a JPEG, PNG or WebP image without strip's
+// metadata. Pixel data is copied as is. Data after the end of the image, such
+// as the extra images of an MPO or vendor trailers, is dropped.
+func stripMetadata(data []byte, strip models.MetadataStripping) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		return stripJPEG(data, strip)
+	case bytes.HasPrefix(data, pngSignature):
+		return stripPNG(data, strip)
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return stripWebP(data, strip)
+	}
+	return nil, errStripUnsupported
+}
+
+// stripJPEG walks a JPEG's segments. EXIF is rewritten by stripEXIF; XMP,
+// extended XMP and Photoshop/IPTC segments always go. Removing everything
+// also drops comments and the APPn segments not needed to show the image.
+func stripJPEG(data []byte, strip models.MetadataStripping) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+
+	pos := 2
+	for {
+		// Markers may be preceded by fill bytes
+		if pos >= len(data) || data[pos] != 0xFF {
+			return nil, errStripMalformed
+		}
+		for pos < len(data) && data[pos] == 0xFF {
+			pos++
+		}
+		if pos >= len(data) {
+			return nil, errStripMalformed
+		}
+		marker := data[pos]
+		pos++
+
+		switch {
+		case marker == 0xD9:
+			return append(out, 0xFF, 0xD9), nil
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			out = append(out, 0xFF, marker)
+			continue
+		}
+
+		if pos+2 > len(data) {
+			return nil, errStripMalformed
+		}
+		length := int(binary.BigEndian.Uint16(data[pos:]))
+		if length < 2 || pos+length > len(data) {
+			return nil, errStripMalformed
+		}
+		segment := data[pos+2 : pos+length]
+		next := pos + length
+
+		if marker == 0xDA {
+			// The scan's entropy-coded data runs up to the next marker other
+			// than a restart marker; 0xFF bytes in it are followed by 0x00
+			end := next
+			for end+1 < len(data) && (data[end] != 0xFF || data[end+1] == 0x00 || (data[end+1] >= 0xD0 && data[end+1] <= 0xD7)) {
+				end++
+			}
+			if end+1 >= len(data) {
+				// Truncated after the scan; what is there is image data
+				out = append(out, data[pos-2:]...)
+				return append(out, 0xFF, 0xD9), nil
+			}
+			out = append(out, data[pos-2:end]...)
+			pos = end
+			continue
+		}
+
+		switch {
+		case marker == 0xE1 && bytes.HasPrefix(segment, jpegEXIFHeader):
+			if tiff := stripEXIF(segment[len(jpegEXIFHeader):], strip); tiff != nil {
+				out = appendJPEGSegment(out, marker, append(append([]byte{}, jpegEXIFHeader...), tiff...))
+			}
+		case marker == 0xE1 || marker == 0xED:
+			// XMP, extended XMP and Photoshop resources, which hold IPTC
+		case marker == 0xE2:
+			// Only the colour profile; MPF would point at the dropped extra images
+			if bytes.HasPrefix(segment, jpegICCHeader) {
+				out = appendJPEGSegment(out, marker, segment)
+			}
+		case strip == models.StripAll && marker != 0xE0 && marker != 0xEE && (marker >= 0xE0 && marker <= 0xEF || marker == 0xFE):
+			// JFIF (APP0) and Adobe (APP14) are kept, as decoders need them
+			// to get the colours right
+		default:
+			out = appendJPEGSegment(out, marker, segment)
+		}
+		pos = next
+	}
+}
+
+func appendJPEGSegment(out []byte, marker byte, segment []byte) []byte {
+	out = append(out, 0xFF, marker)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(segment)+2))
+	return append(out, segment...)
+}
+
+// stripPNG copies a PNG's chunks, rewriting eXIf with stripEXIF. Removing the
+// location also drops XMP and raw profile text chunks, which may carry GPS
+// tags; removing everything drops all text and the modification time.
+func stripPNG(data []byte, strip models.MetadataStripping) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+
+	pos := len(pngSignature)
+	for pos+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		if length > len(data)-pos-12 {
+			return nil, errStripMalformed
+		}
+		kind := string(data[pos+4 : pos+8])
+		body := data[pos+8 : pos+8+length]
+		next := pos + 12 + length
+
+		switch kind {
+		case "eXIf":
+			if tiff := stripEXIF(body, strip); tiff != nil {
+				out = appendPNGChunk(out, kind, tiff)
+			}
+		case "tEXt", "zTXt", "iTXt":
+			keyword, _, _ := bytes.Cut(body, []byte{0})
+			if strip != models.StripAll && !bytes.HasPrefix(keyword, []byte("XML:com.adobe.xmp")) &&
+				!bytes.HasPrefix(keyword, []byte("Raw profile type")) {
+				out = append(out, data[pos:next]...)
+			}
+		case "tIME":
+			if strip != models.StripAll {
+				out = append(out, data[pos:next]...)
+			}
+		case "IEND":
+			return append(out, data[pos:next]...), nil
+		default:
+			out = append(out, data[pos:next]...)
+		}
+		pos = next
+	}
+	return nil, errStripMalformed
+}
+
+func appendPNGChunk(out []byte, kind string, body []byte) []byte {
+	out = binary.BigEndian.AppendUint32(out, uint32(len(body)))
+	start := len(out)
+	out = append(out, kind...)
+	out = append(out, body...)
+	return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(out[start:]))
+}
+
+// stripWebP copies a WebP's chunks, rewriting EXIF with stripEXIF and
+// dropping XMP, and keeps the extended header's flags in step
+func stripWebP(data []byte, strip models.MetadataStripping) ([]byte, error) {
+	end := 8 + int(binary.LittleEndian.Uint32(data[4:]))
+	if end > len(data) || end < 12 {
+		return nil, errStripMalformed
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[0:12]...)
+	vp8x, hasEXIF := -1, false
+
+	pos := 12
+	for pos+8 <= end {
+		kind := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		if size > end-pos-8 {
+			return nil, errStripMalformed
+		}
+		body := data[pos+8 : pos+8+size]
+		pos += 8 + size + size&1
+
+		switch kind {
+		case "EXIF":
+			// Some writers keep the JPEG APP1 header
+			if tiff := stripEXIF(bytes.TrimPrefix(body, jpegEXIFHeader), strip); tiff != nil {
+				out = appendRIFFChunk(out, kind, tiff)
+				hasEXIF = true
+			}
+		case "XMP ":
+		case "VP8X":
+			vp8x = len(out)
+			out = appendRIFFChunk(out, kind, body)
+		default:
+			out = appendRIFFChunk(out, kind, body)
+		}
+	}
+
+	if vp8x >= 0 && len(out) > vp8x+8 {
+		flags := out[vp8x+8] &^ 0x0C // EXIF and XMP present
+		if hasEXIF {
+			flags |= 0x08
+		}
+		out[vp8x+8] = flags
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
+
+func appendRIFFChunk(out []byte, kind string, body []byte) []byte {
+	out = append(out, kind...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(body)))
+	out = append(out, body...)
+	if len(body)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+// stripEXIF returns the TIFF-format EXIF data to keep in place of tiff, or
+// nil to drop it. Removing the location empties the GPS IFD and blanks
+// ImageUniqueID, where PhotoSync embeds the photo's ID, in a copy with the
+// same layout, so every other offset stays valid. Removing everything, or
+// EXIF that cannot be parsed, leaves only the orientation, which viewers
+// need to show the image upright.
+func stripEXIF(tiff []byte, strip models.MetadataStripping) []byte {
+	if strip == models.StripLocation {
+		if kept, err := removeEXIFLocation(tiff); err == nil {
+			return kept
+		}
+	}
+	if orientation := exifOrientation(tiff); orientation > 1 && orientation <= 8 {
+		return orientationEXIF(orientation)
+	}
+	return nil
+}
+
+// tiffEntry is one 12-byte IFD entry
+type tiffEntry struct {
+	at    int // Offset of the entry
+	tag   uint16
+	typ   uint16
+	count uint32
+	value uint32 // The value itself if it fits in four bytes, otherwise its offset
+}
+
+// size returns the byte length of the entry's value, or 0 for unknown types
+func (e tiffEntry) size() int {
+	var unit int
+	switch e.typ {
+	case 1, 2, 6, 7:
+		unit = 1
+	case 3, 8:
+		unit = 2
+	case 4, 9, 11, 13:
+		unit = 4
+	case 5, 10, 12:
+		unit = 8
+	}
+	return unit * int(e.count)
+}
+
+// tiffHeader reads the byte order and first IFD offset of TIFF data
+func tiffHeader(tiff []byte) (binary.ByteOrder, uint32, error) {
+	if len(tiff) < 8 {
+		return nil, 0, errStripMalformed
+	}
+	var order binary.ByteOrder
+	switch string(tiff[0:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, 0, errStripMalformed
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return nil, 0, errStripMalformed
+	}
+	return order, order.Uint32(tiff[4:]), nil
+}
+
+// readIFD reads the entries of the IFD at offset
+func readIFD(tiff []byte, order binary.ByteOrder, offset uint32) ([]tiffEntry, error) {
+	if int64(offset)+2 > int64(len(tiff)) {
+		return nil, errStripMalformed
+	}
+	at := int(offset)
+	n := int(order.Uint16(tiff[at:]))
+	if at+2+12*n+4 > len(tiff) {
+		return nil, errStripMalformed
+	}
+	entries := make([]tiffEntry, n)
+	for i := range entries {
+		p := at + 2 + 12*i
+		entries[i] = tiffEntry{
+			at:    p,
+			tag:   order.Uint16(tiff[p:]),
+			typ:   order.Uint16(tiff[p+2:]),
+			count: order.Uint32(tiff[p+4:]),
+			value: order.Uint32(tiff[p+8:]),
+		}
+	}
+	return entries, nil
+}
+
+// zeroValue overwrites an entry's value with zeros, wherever it is stored
+func zeroValue(tiff []byte, e tiffEntry) error {
+	size := e.size()
+	start, end := e.at+8, e.at+12
+	if size > 4 {
+		start, end = int(e.value), int(e.value)+size
+		if e.value > uint32(len(tiff)) || end > len(tiff) {
+			return errStripMalformed
+		}
+	}
+	clear(tiff[start:end])
+	return nil
+}
+
+// removeEXIFLocation returns a copy of tiff with an empty GPS IFD and a
+// blank ImageUniqueID
+func removeEXIFLocation(tiff []byte) ([]byte, error) {
+	order, ifd0, err := tiffHeader(tiff)
+	if err != nil {
+		return nil, err
+	}
+	out := append([]byte{}, tiff...)
+	entries, err := readIFD(out, order, ifd0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		switch e.tag {
+		case tiffTagGPSIFD:
+			gps, err := readIFD(out, order, e.value)
+			if err != nil {
+				return nil, err
+			}
+			for _, g := range gps {
+				if err := zeroValue(out, g); err != nil {
+					return nil, err
+				}
+			}
+			// No entries and, in the bytes that follow, no next IFD
+			at := int(e.value)
+			clear(out[at : at+2+12*len(gps)+4])
+		case tiffTagExifIFD:
+			exif, err := readIFD(out, order, e.value)
+			if err != nil {
+				return nil, err
+			}
+			for _, x := range exif {
+				if x.tag == tiffTagImageUniqueID {
+					if err := zeroValue(out, x); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+// exifOrientation returns the Orientation tag of IFD0, or 0 if there is none
+func exifOrientation(tiff []byte) int {
+	order, ifd0, err := tiffHeader(tiff)
+	if err != nil {
+		return 0
+	}
+	entries, err := readIFD(tiff, order, ifd0)
+	if err != nil {
+		return 0
+	}
+	for _, e := range entries {
+		if e.tag == tiffTagOrientation && e.typ == 3 && e.count == 1 {
+			return int(order.Uint16(tiff[e.at+8:]))
+		}
+	}
+	return 0
+}
+
+// orientationEXIF returns TIFF-format EXIF holding only an orientation
+func orientationEXIF(orientation int) []byte {
+	out := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	out = binary.BigEndian.AppendUint16(out, 1)
+	out = binary.BigEndian.AppendUint16(out, tiffTagOrientation)
+	out = binary.BigEndian.AppendUint16(out, 3)
+	out = binary.BigEndian.AppendUint32(out, 1)
+	out = binary.BigEndian.AppendUint16(out, uint16(orientation))
+	out = append(out, 0, 0)
+	return binary.BigEndian.AppendUint32(out, 0)
+}
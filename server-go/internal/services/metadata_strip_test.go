@@ -0,0 +1,201 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPhotoUniqueID = "0123456789abcdef0123456789abcdef"
+
+// testEXIF builds big-endian EXIF with a camera make, orientation 6, a
+// unique ID and a GPS position of 48°51'24"N
+func testEXIF() []byte {
+	be := binary.BigEndian
+	entry := func(b []byte, tag, typ uint16, count, value uint32) []byte {
+		b = be.AppendUint16(b, tag)
+		b = be.AppendUint16(b, typ)
+		b = be.AppendUint32(b, count)
+		return be.AppendUint32(b, value)
+	}
+
+	b := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	// IFD0 at 8, its make at 62
+	b = be.AppendUint16(b, 4)
+	b = entry(b, 0x010F, 2, 6, 62)
+	b = entry(b, tiffTagOrientation, 3, 1, 6<<16)
+	b = entry(b, tiffTagExifIFD, 4, 1, 68)
+	b = entry(b, tiffTagGPSIFD, 4, 1, 120)
+	b = be.AppendUint32(b, 0)
+	b = append(b, "Canon\x00"...)
+	// Exif IFD at 68, its unique ID at 86
+	b = be.AppendUint16(b, 1)
+	b = entry(b, tiffTagImageUniqueID, 2, 33, 86)
+	b = be.AppendUint32(b, 0)
+	b = append(b, testPhotoUniqueID+"\x00"...)
+	b = append(b, 0)
+	// GPS IFD at 120, its latitude at 150
+	b = be.AppendUint16(b, 2)
+	b = entry(b, 0x0001, 2, 2, uint32('N')<<24)
+	b = entry(b, 0x0002, 5, 3, 150)
+	b = be.AppendUint32(b, 0)
+	for _, v := range []uint32{48, 1, 51, 1, 2400, 100} {
+		b = be.AppendUint32(b, v)
+	}
+	return b
+}
+
+const testXMP = `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:Description photosync:PhotoID="` + testPhotoUniqueID + `"/></x:xmpmeta>`
+
+// testJPEG encodes a small JPEG and adds EXIF, XMP, a comment and a trailer
+// after the end of the image
+func testJPEG(t *testing.T) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 16, 8)), nil))
+	encoded := buf.Bytes()
+
+	out := append([]byte{}, encoded[:2]...)
+	out = appendJPEGSegment(out, 0xE1, append([]byte("Exif\x00\x00"), testEXIF()...))
+	out = appendJPEGSegment(out, 0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00"+testXMP))
+	out = appendJPEGSegment(out, 0xFE, []byte("Holiday at home"))
+	out = append(out, encoded[2:]...)
+	return append(out, "vendor trailer with GPS"...)
+}
+
+// jpegEXIF returns the TIFF data of a JPEG's EXIF segment, or nil
+func jpegEXIF(t *testing.T, data []byte) []byte {
+	i := bytes.Index(data, []byte("Exif\x00\x00"))
+	if i < 0 {
+		return nil
+	}
+	length := int(binary.BigEndian.Uint16(data[i-2:]))
+	return data[i+6 : i-2+length]
+}
+
+func TestStripMetadata_JPEG(t *testing.T) {
+	original := testJPEG(t)
+	latitude := testEXIF()[150:174]
+
+	t.Run("location", func(t *testing.T) {
+		stripped, err := stripMetadata(original, models.StripLocation)
+		require.NoError(t, err)
+
+		_, err = jpeg.Decode(bytes.NewReader(stripped))
+		require.NoError(t, err)
+		assert.NotContains(t, string(stripped), testPhotoUniqueID, "unique ID and XMP are gone")
+		assert.False(t, bytes.Contains(stripped, latitude), "GPS position is gone")
+		assert.NotContains(t, string(stripped), "vendor trailer")
+
+		exif := jpegEXIF(t, stripped)
+		require.NotNil(t, exif)
+		assert.Equal(t, 6, exifOrientation(exif))
+		assert.Contains(t, string(exif), "Canon", "camera details stay")
+		assert.Contains(t, string(stripped), "Holiday at home")
+	})
+
+	t.Run("all", func(t *testing.T) {
+		stripped, err := stripMetadata(original, models.StripAll)
+		require.NoError(t, err)
+
+		_, err = jpeg.Decode(bytes.NewReader(stripped))
+		require.NoError(t, err)
+		assert.NotContains(t, string(stripped), testPhotoUniqueID)
+		assert.NotContains(t, string(stripped), "Canon")
+		assert.NotContains(t, string(stripped), "Holiday at home")
+		assert.Equal(t, 6, exifOrientation(jpegEXIF(t, stripped)), "orientation is all that is left")
+	})
+
+	_, err := stripMetadata(original[:40], models.StripLocation)
+	assert.Error(t, err, "a cut-off header is not passed through")
+}
+
+func TestStripMetadata_PNG(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))))
+	encoded := buf.Bytes()
+
+	// Metadata chunks go right after IHDR, which is 25 bytes long
+	original := append([]byte{}, encoded[:33]...)
+	original = appendPNGChunk(original, "eXIf", testEXIF())
+	original = appendPNGChunk(original, "iTXt", []byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00"+testXMP))
+	original = appendPNGChunk(original, "tEXt", []byte("Comment\x00Holiday at home"))
+	original = append(original, encoded[33:]...)
+
+	stripped, err := stripMetadata(original, models.StripLocation)
+	require.NoError(t, err)
+	_, err = png.Decode(bytes.NewReader(stripped))
+	require.NoError(t, err, "chunk checksums are valid")
+	assert.NotContains(t, string(stripped), testPhotoUniqueID)
+	assert.False(t, bytes.Contains(stripped, testEXIF()[150:174]))
+	assert.Contains(t, string(stripped), "Holiday at home")
+
+	stripped, err = stripMetadata(original, models.StripAll)
+	require.NoError(t, err)
+	_, err = png.Decode(bytes.NewReader(stripped))
+	require.NoError(t, err)
+	assert.NotContains(t, string(stripped), "Canon")
+	assert.NotContains(t, string(stripped), "Holiday at home")
+	assert.Contains(t, string(stripped), "eXIf", "orientation is kept")
+}
+
+func TestStripMetadata_WebP(t *testing.T) {
+	original := []byte("RIFF\x00\x00\x00\x00WEBP")
+	original = appendRIFFChunk(original, "VP8X", []byte{0x0C, 0, 0, 0, 3, 0, 0, 3, 0, 0})
+	original = appendRIFFChunk(original, "VP8L", []byte("pixels"))
+	original = appendRIFFChunk(original, "EXIF", testEXIF())
+	original = appendRIFFChunk(original, "XMP ", []byte(testXMP))
+	binary.LittleEndian.PutUint32(original[4:], uint32(len(original)-8))
+
+	stripped, err := stripMetadata(original, models.StripLocation)
+	require.NoError(t, err)
+	assert.NotContains(t, string(stripped), testPhotoUniqueID)
+	assert.Contains(t, string(stripped), "Canon")
+	assert.Equal(t, byte(0x08), stripped[20], "the header no longer announces XMP")
+	assert.Equal(t, uint32(len(stripped)-8), binary.LittleEndian.Uint32(stripped[4:]))
+	assert.Contains(t, string(stripped), "pixels")
+}
+
+func TestThumbnailService_GetStrippedRendition(t *testing.T) {
+	tempDir := t.TempDir()
+	svc := newTestThumbnailService(t, tempDir)
+
+	storedPath := filepath.Join("2024", "01", "IMG_0001.JPG")
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "2024", "01"), 0755))
+	original := testJPEG(t)
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, storedPath), original, 0644))
+
+	path, err := svc.GetStrippedRendition("photo-1", storedPath, 6, models.StripLocation)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("2024", "01", ".thumbs", "photo-1_strip-location.jpg"), path)
+
+	cached, err := os.ReadFile(filepath.Join(tempDir, path))
+	require.NoError(t, err)
+	assert.NotContains(t, string(cached), testPhotoUniqueID)
+	kept, err := os.ReadFile(filepath.Join(tempDir, storedPath))
+	require.NoError(t, err)
+	assert.Equal(t, original, kept, "the original is untouched")
+
+	svc.DeleteStrippedRenditions("photo-1", storedPath)
+	assert.NoFileExists(t, filepath.Join(tempDir, path))
+
+	_, err = svc.GetStrippedRendition("photo-1", storedPath, 6, models.StripNone)
+	assert.Error(t, err)
+}
+
+func TestCanStripMetadata(t *testing.T) {
+	for _, path := range []string{"a.JPG", "b.jpeg", "c.png", "d.webp", "e.heic"} {
+		assert.True(t, CanStripMetadata(path), path)
+	}
+	for _, path := range []string{"f.mov", "g.tiff", "h.gif"} {
+		assert.Equal(t, IsExiftoolAvailable(), CanStripMetadata(path), path)
+	}
+}
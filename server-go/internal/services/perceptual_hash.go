@@ -0,0 +1,43 @@
+package services
+
+import (
+	"fmt"
+	"image"
+	"math/bits"
+	"strconv"
+
+	"github.com/disintegration/imaging"
+)
+
+// ComputeDHash returns the 64-bit difference hash of an image. The image is
+// shrunk to 9x8 greyscale and each bit records whether a pixel is brighter
+// than its right-hand neighbour, so re-compressed, resized or lightly edited
+// copies hash to nearby values.
+func ComputeDHash(img image.Image) uint64 {
+	small := imaging.Grayscale(imaging.Resize(img, 9, 8, imaging.Box))
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if small.NRGBAAt(x, y).R > small.NRGBAAt(x+1, y).R {
+				hash |= 1 << uint(y*8+x)
+			}
+		}
+	}
+	return hash
+}
+
+// FormatPerceptualHash encodes a hash as 16 hex digits for storage
+func FormatPerceptualHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// ParsePerceptualHash decodes a stored perceptual hash
+func ParsePerceptualHash(s string) (uint64, error) {
+	return strconv.ParseUint(s, 16, 64)
+}
+
+// HammingDistance returns the number of differing bits between two hashes
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+)
+
+// QuotaService enforces per-user storage quotas and warns users as they
+// approach them. Usage totals are kept up to date by the photo repositories.
+type QuotaService struct {
+	userRepo   repository.UserRepo
+	deviceRepo repository.DeviceRepo
+	fcmService *FCMService // Optional, nil when Firebase is not configured
+	wsHub      *WebSocketHub
+}
+
+// NewQuotaService creates a new QuotaService
+func NewQuotaService(userRepo repository.UserRepo, deviceRepo repository.DeviceRepo, fcmService *FCMService) *QuotaService {
+	return &QuotaService{
+		userRepo:   userRepo,
+		deviceRepo: deviceRepo,
+		fcmService: fcmService,
+	}
+}
+
+// SetWebSocketHub sets the WebSocket hub for real-time notifications
+func (s *QuotaService) SetWebSocketHub(hub *WebSocketHub) {
+	s.wsHub = hub
+}
+
+// CheckUpload returns ErrStorageQuotaExceeded or ErrPhotoQuotaExceeded when
+// adding a photo of size bytes would take the user over a quota. Photos
+// without an owner are never limited. This is an early check that saves
+// storing a file that cannot be kept; the photo repositories enforce the
+// quota atomically when the photo is added.
+func (s *QuotaService) CheckUpload(ctx context.Context, userID string, size int64) error {
+	if userID == "" {
+		return nil
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to load quota: %w", err)
+	}
+	if user == nil {
+		return models.ErrUserNotFound
+	}
+	return user.CheckQuota(size)
+}
+
+// Usage returns the user's current storage usage
+func (s *QuotaService) Usage(ctx context.Context, userID string) (*models.StorageUsage, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, models.ErrUserNotFound
+	}
+	usage := user.Usage()
+	return &usage, nil
+}
+
+// NotifyAdded warns the user once an added photo of size bytes takes them
+// past QuotaWarningPercent. Only the upload that crosses the threshold sends
+// a warning, so clients are not notified on every upload after it.
+func (s *QuotaService) NotifyAdded(ctx context.Context, userID string, size int64) {
+	if userID == "" {
+		return
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil || user == nil {
+		return
+	}
+
+	usage := user.Usage()
+	if !usage.NearLimit {
+		return
+	}
+	before := *user
+	before.UsedBytes -= size
+	before.UsedPhotos--
+	if before.Usage().NearLimit {
+		return
+	}
+
+	log.Printf("User %s is at %.0f%% of their storage quota", userID, usage.PercentUsed)
+
+	if s.wsHub != nil {
+		s.wsHub.SendToUser(userID, WSMessage{Type: WSTypeQuotaWarning, Payload: usage})
+	}
+
+	if s.fcmService != nil {
+		// Push delivery can be slow; never hold up the upload that triggered it
+		go s.sendPushWarning(context.Background(), userID, usage)
+	}
+}
+
+// sendPushWarning notifies every active device of the user
+func (s *QuotaService) sendPushWarning(ctx context.Context, userID string, usage models.StorageUsage) {
+	devices, err := s.deviceRepo.GetActiveForUser(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to load devices for quota warning: %v", err)
+		return
+	}
+
+	body := fmt.Sprintf("You have used %.0f%% of your storage quota", usage.PercentUsed)
+	data := map[string]string{
+		"type":        WSTypeQuotaWarning,
+		"usedBytes":   strconv.FormatInt(usage.UsedBytes, 10),
+		"quotaBytes":  strconv.FormatInt(usage.QuotaBytes, 10),
+		"usedPhotos":  strconv.Itoa(usage.UsedPhotos),
+		"quotaPhotos": strconv.Itoa(usage.QuotaPhotos),
+	}
+	for _, device := range devices {
+		if device.FCMToken == "" {
+			continue
+		}
+		if err := s.fcmService.SendDataNotification(ctx, device.FCMToken, "Storage almost full", body, data); err != nil {
+			log.Printf("Failed to send quota warning to device %s: %v", device.ID, err)
+		}
+	}
+}
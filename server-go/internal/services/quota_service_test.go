@@ -0,0 +1,108 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotaService_EnforcesQuotaOnUpload(t *testing.T) {
+	ctx := context.Background()
+	db, tempDir := setupTestDB(t)
+	photoRepo := repository.NewPhotoRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	trashService, storage := newTestTrashService(t, photoRepo, tempDir)
+	quotaService := NewQuotaService(userRepo, repository.NewDeviceRepository(db), nil)
+	uploadService := newTestUploadService(t, photoRepo, storage, tempDir)
+	uploadService.SetQuotaService(quotaService)
+
+	user, err := userRepo.GetByID(ctx, "user-1")
+	require.NoError(t, err)
+	user.QuotaBytes = 20
+	require.NoError(t, userRepo.Update(ctx, user))
+
+	upload := func(content string) (*models.UploadResult, error) {
+		return uploadService.Process(ctx, bytes.NewReader([]byte(content)), int64(len(content)), UploadOptions{
+			OriginalFilename: "beach.jpg",
+			UserID:           "user-1",
+		})
+	}
+
+	first, err := upload("twelve bytes")
+	require.NoError(t, err)
+
+	usage, err := quotaService.Usage(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), usage.UsedBytes)
+	assert.Equal(t, 1, usage.UsedPhotos)
+	assert.False(t, usage.NearLimit)
+
+	_, err = upload("another ten")
+	assert.ErrorIs(t, err, models.ErrStorageQuotaExceeded)
+
+	// Re-uploading content the user already has costs nothing
+	again, err := upload("twelve bytes")
+	require.NoError(t, err)
+	assert.True(t, again.IsDuplicate)
+
+	// Trashed photos still count; purging them frees the space
+	require.NoError(t, trashService.MoveToTrash(ctx, first.ID))
+	_, err = upload("another ten")
+	assert.ErrorIs(t, err, models.ErrStorageQuotaExceeded)
+
+	_, err = trashService.Empty(ctx, "user-1")
+	require.NoError(t, err)
+	_, err = upload("another ten")
+	require.NoError(t, err)
+
+	usage, err = quotaService.Usage(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), usage.UsedBytes)
+	assert.Equal(t, 1, usage.UsedPhotos)
+
+	// Other users are unaffected
+	require.NoError(t, quotaService.CheckUpload(ctx, "user-2", 1<<30))
+}
+
+func TestQuotaService_OwnerChangeMovesUsage(t *testing.T) {
+	ctx := context.Background()
+	db, tempDir := setupTestDB(t)
+	photoRepo := repository.NewPhotoRepository(db)
+	quotaService := NewQuotaService(repository.NewUserRepository(db), repository.NewDeviceRepository(db), nil)
+	storage := newTestStorage(t, tempDir)
+
+	photo := addTestPhoto(t, photoRepo, storage, "user-1", "abc")
+	user2 := "user-2"
+	photo.UserID = &user2
+	require.NoError(t, photoRepo.Update(ctx, photo))
+
+	usage, err := quotaService.Usage(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.StorageUsage{}, *usage)
+
+	usage, err = quotaService.Usage(ctx, "user-2")
+	require.NoError(t, err)
+	assert.Equal(t, photo.FileSize, usage.UsedBytes)
+	assert.Equal(t, 1, usage.UsedPhotos)
+}
+
+func TestUser_Usage(t *testing.T) {
+	user := &models.User{QuotaBytes: 1000, QuotaPhotos: 10, UsedBytes: 500, UsedPhotos: 9}
+
+	usage := user.Usage()
+	assert.InDelta(t, 90, usage.PercentUsed, 0.001, "the quota closest to its limit wins")
+	assert.True(t, usage.NearLimit)
+
+	assert.NoError(t, user.CheckQuota(500))
+	user.UsedPhotos = 10
+	assert.ErrorIs(t, user.CheckQuota(1), models.ErrPhotoQuotaExceeded)
+
+	unlimited := &models.User{UsedBytes: 1 << 40, UsedPhotos: 1 << 20}
+	assert.NoError(t, unlimited.CheckQuota(1<<40))
+	assert.False(t, unlimited.Usage().NearLimit)
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+)
+
+// restorePlanRetention is how long finished restore plans are kept
+const restorePlanRetention = 30 * 24 * time.Hour
+
+// RestoreService builds the download manifests that restore a library onto a
+// device and tracks which photos the device has confirmed. Confirmations
+// also move the device's sync state on, so /api/sync/status reflects the
+// restore.
+type RestoreService struct {
+	restoreRepo   repository.RestorePlanRepo
+	photoRepo     repository.PhotoRepo
+	deviceRepo    repository.DeviceRepo
+	syncStateRepo repository.DeviceSyncStateRepo
+}
+
+// NewRestoreService creates a new RestoreService
+func NewRestoreService(
+	restoreRepo repository.RestorePlanRepo,
+	photoRepo repository.PhotoRepo,
+	deviceRepo repository.DeviceRepo,
+	syncStateRepo repository.DeviceSyncStateRepo,
+) *RestoreService {
+	return &RestoreService{
+		restoreRepo:   restoreRepo,
+		photoRepo:     photoRepo,
+		deviceRepo:    deviceRepo,
+		syncStateRepo: syncStateRepo,
+	}
+}
+
+// CreatePlan fixes the set of photos a device should download. A plan with
+// nothing to download is completed straight away.
+func (s *RestoreService) CreatePlan(ctx context.Context, userID string, req models.CreateRestorePlanRequest) (*models.RestorePlan, error) {
+	plan, err := models.NewRestorePlan(userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.requireDevice(ctx, userID, plan.DeviceID); err != nil {
+		return nil, err
+	}
+	if plan.SourceDeviceID != nil {
+		if err := s.requireDevice(ctx, userID, *plan.SourceDeviceID); err != nil {
+			return nil, err
+		}
+	}
+
+	photos, err := searchAllPhotos(ctx, s.photoRepo, plan.SearchQuery(), libraryPageSize)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*models.RestorePlanItem, len(photos))
+	for i, photo := range photos {
+		items[i] = &models.RestorePlanItem{
+			Position: i + 1,
+			PhotoID:  photo.ID,
+			FileHash: photo.FileHash,
+			FileSize: photo.FileSize,
+		}
+	}
+	if len(items) == 0 {
+		plan.Status = models.RestorePlanCompleted
+		plan.CompletedAt = &plan.CreatedAt
+	}
+
+	if err := s.restoreRepo.Add(ctx, plan, items); err != nil {
+		return nil, fmt.Errorf("failed to save restore plan: %w", err)
+	}
+	log.Printf("Created restore plan %s for device %s with %d photos", plan.ID, plan.DeviceID, len(items))
+
+	return s.Get(ctx, userID, plan.ID)
+}
+
+// Get returns one of the user's plans with its progress
+func (s *RestoreService) Get(ctx context.Context, userID, planID string) (*models.RestorePlan, error) {
+	plan, err := s.restoreRepo.GetByID(ctx, planID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get restore plan: %w", err)
+	}
+	if plan == nil || plan.UserID != userID {
+		return nil, models.ErrRestorePlanNotFound
+	}
+	return plan, nil
+}
+
+// List returns the user's plans, newest first, optionally for one device
+func (s *RestoreService) List(ctx context.Context, userID, deviceID string) ([]*models.RestorePlan, error) {
+	plans, err := s.restoreRepo.GetForUser(ctx, userID, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list restore plans: %w", err)
+	}
+	return plans, nil
+}
+
+// Manifest returns one page of a plan's photos in plan order. The cursor is
+// the position of the last item on the previous page. With pendingOnly set,
+// photos the device has already confirmed are left out, which is how a
+// device resumes an interrupted restore.
+func (s *RestoreService) Manifest(ctx context.Context, userID, planID, cursor string, limit int, pendingOnly bool) (*models.RestoreManifestResponse, error) {
+	plan, err := s.Get(ctx, userID, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	after := 0
+	if cursor != "" {
+		if after, err = strconv.Atoi(cursor); err != nil || after < 0 {
+			return nil, models.ErrRestoreInvalidCursor
+		}
+	}
+	if limit <= 0 {
+		limit = models.DefaultRestoreManifestLimit
+	}
+	if limit > models.MaxRestoreManifestLimit {
+		limit = models.MaxRestoreManifestLimit
+	}
+
+	items, err := s.restoreRepo.GetItems(ctx, plan.ID, after, limit+1, pendingOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get restore plan items: %w", err)
+	}
+
+	resp := &models.RestoreManifestResponse{Plan: plan, Items: []models.RestorePlanItem{}}
+	if len(items) > limit {
+		items = items[:limit]
+		resp.Pagination = models.PaginationInfo{Cursor: strconv.Itoa(items[limit-1].Position), HasMore: true}
+	}
+	for _, item := range items {
+		item.DownloadURL = "/api/photos/" + item.PhotoID + "/download"
+		resp.Items = append(resp.Items, *item)
+	}
+	return resp, nil
+}
+
+// Confirm records the photos a device has downloaded and completes the plan
+// once every photo still in the library is confirmed
+func (s *RestoreService) Confirm(ctx context.Context, userID, planID string, confirmations []models.RestoreConfirmation) (*models.ConfirmRestoreItemsResponse, error) {
+	plan, err := s.Get(ctx, userID, planID)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Status != models.RestorePlanActive {
+		return nil, models.ErrRestorePlanFinished
+	}
+
+	now := time.Now().UTC()
+	rejected, err := s.restoreRepo.MarkDownloaded(ctx, plan.ID, confirmations, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record downloads: %w", err)
+	}
+
+	confirmed := len(confirmations) - len(rejected)
+	if confirmed > 0 {
+		last := ""
+		rejectedSet := make(map[string]bool, len(rejected))
+		for _, id := range rejected {
+			rejectedSet[id] = true
+		}
+		for _, c := range confirmations {
+			if !rejectedSet[c.PhotoID] {
+				last = c.PhotoID
+			}
+		}
+		if err := s.syncStateRepo.UpdateLastSync(ctx, plan.DeviceID, last); err != nil {
+			log.Printf("Warning: failed to update sync state for device %s: %v", plan.DeviceID, err)
+		}
+	}
+
+	plan, err = s.Get(ctx, userID, plan.ID)
+	if err != nil {
+		return nil, err
+	}
+	if plan.DownloadedItems >= plan.TotalItems {
+		if err := s.restoreRepo.UpdateStatus(ctx, plan.ID, models.RestorePlanCompleted, &now); err != nil {
+			return nil, fmt.Errorf("failed to complete restore plan: %w", err)
+		}
+		plan.Status = models.RestorePlanCompleted
+		plan.CompletedAt = &now
+		log.Printf("Restore plan %s completed for device %s", plan.ID, plan.DeviceID)
+	}
+
+	return &models.ConfirmRestoreItemsResponse{Confirmed: confirmed, Rejected: rejected, Plan: plan}, nil
+}
+
+// Cancel stops an active plan. Photos already downloaded stay on the device.
+func (s *RestoreService) Cancel(ctx context.Context, userID, planID string) (*models.RestorePlan, error) {
+	plan, err := s.Get(ctx, userID, planID)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Status != models.RestorePlanActive {
+		return nil, models.ErrRestorePlanFinished
+	}
+
+	now := time.Now().UTC()
+	if err := s.restoreRepo.UpdateStatus(ctx, plan.ID, models.RestorePlanCancelled, &now); err != nil {
+		return nil, fmt.Errorf("failed to cancel restore plan: %w", err)
+	}
+	plan.Status = models.RestorePlanCancelled
+	plan.CompletedAt = &now
+	return plan, nil
+}
+
+// CleanupFinished removes completed and cancelled plans after the retention period
+func (s *RestoreService) CleanupFinished(ctx context.Context) (int, error) {
+	removed, err := s.restoreRepo.DeleteFinishedBefore(ctx, time.Now().UTC().Add(-restorePlanRetention))
+	if err != nil {
+		return 0, err
+	}
+	if removed > 0 {
+		log.Printf("Removed %d finished restore plans", removed)
+	}
+	return removed, nil
+}
+
+// requireDevice checks that a device is registered to the user
+func (s *RestoreService) requireDevice(ctx context.Context, userID, deviceID string) error {
+	device, err := s.deviceRepo.GetByID(ctx, deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to get device: %w", err)
+	}
+	if device == nil || device.UserID != userID {
+		return models.ErrRestoreDeviceNotFound
+	}
+	return nil
+}
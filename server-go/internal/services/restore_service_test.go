@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreService(t *testing.T) {
+	ctx := context.Background()
+	db, tempDir := setupTestDB(t)
+	photoRepo := repository.NewPhotoRepository(db)
+	trash, storage := newTestTrashService(t, photoRepo, tempDir)
+	uploadService := newTestUploadService(t, photoRepo, storage, tempDir)
+	deviceRepo := repository.NewDeviceRepository(db)
+	syncStateRepo := repository.NewDeviceSyncStateRepository(db)
+	svc := NewRestoreService(repository.NewRestorePlanRepository(db), photoRepo, deviceRepo, syncStateRepo)
+
+	upload := func(content string, taken time.Time) *models.Photo {
+		result, err := uploadService.Process(ctx, strings.NewReader(content), int64(len(content)), UploadOptions{
+			OriginalFilename: content + ".jpg", UserID: "user-1", DateTaken: &taken,
+		})
+		require.NoError(t, err)
+		photo, err := photoRepo.GetByID(ctx, result.ID)
+		require.NoError(t, err)
+		return photo
+	}
+	oldPhone, err := models.NewDevice("user-1", "Old phone", "android", "token-old")
+	require.NoError(t, err)
+	require.NoError(t, deviceRepo.Add(ctx, oldPhone))
+	newPhone, err := models.NewDevice("user-1", "New phone", "android", "token-new")
+	require.NoError(t, err)
+	require.NoError(t, deviceRepo.Add(ctx, newPhone))
+
+	a := upload("a", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	b := upload("b", time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC))
+	c := upload("c", time.Date(2021, 12, 31, 23, 0, 0, 0, time.UTC))
+	upload("d", time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, photoRepo.SetOriginDevice(ctx, a.ID, oldPhone.ID))
+
+	_, err = svc.CreatePlan(ctx, "user-2", models.CreateRestorePlanRequest{DeviceID: newPhone.ID})
+	assert.Equal(t, models.ErrRestoreDeviceNotFound, err, "another user's device")
+	_, err = svc.CreatePlan(ctx, "user-1", models.CreateRestorePlanRequest{DeviceID: newPhone.ID, Year: 2021, DateFrom: &a.DateTaken})
+	assert.Equal(t, models.ErrRestoreYearWithRange, err)
+
+	fromOld, err := svc.CreatePlan(ctx, "user-1", models.CreateRestorePlanRequest{DeviceID: newPhone.ID, SourceDeviceID: oldPhone.ID})
+	require.NoError(t, err)
+	assert.Equal(t, 1, fromOld.TotalItems)
+
+	plan, err := svc.CreatePlan(ctx, "user-1", models.CreateRestorePlanRequest{DeviceID: newPhone.ID, Year: 2021})
+	require.NoError(t, err)
+	assert.Equal(t, models.RestorePlanActive, plan.Status)
+	assert.Equal(t, 3, plan.TotalItems)
+	assert.Equal(t, a.FileSize+b.FileSize+c.FileSize, plan.TotalBytes)
+
+	// Pages follow the plan order, newest first
+	page, err := svc.Manifest(ctx, "user-1", plan.ID, "", 2, true)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, c.ID, page.Items[0].PhotoID)
+	assert.Equal(t, c.FileHash, page.Items[0].FileHash)
+	assert.Equal(t, "/api/photos/"+c.ID+"/download", page.Items[0].DownloadURL)
+	assert.True(t, page.Pagination.HasMore)
+	page, err = svc.Manifest(ctx, "user-1", plan.ID, page.Pagination.Cursor, 2, true)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, a.ID, page.Items[0].PhotoID)
+	assert.False(t, page.Pagination.HasMore)
+
+	_, err = svc.Manifest(ctx, "user-2", plan.ID, "", 0, true)
+	assert.Equal(t, models.ErrRestorePlanNotFound, err)
+
+	// A wrong hash and a photo outside the plan are rejected
+	resp, err := svc.Confirm(ctx, "user-1", plan.ID, []models.RestoreConfirmation{
+		{PhotoID: c.ID, FileHash: c.FileHash},
+		{PhotoID: b.ID, FileHash: "corrupt"},
+		{PhotoID: "not-in-plan", FileHash: c.FileHash},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, resp.Confirmed)
+	assert.ElementsMatch(t, []string{b.ID, "not-in-plan"}, resp.Rejected)
+	assert.Equal(t, 1, resp.Plan.DownloadedItems)
+	assert.Equal(t, c.FileSize, resp.Plan.DownloadedBytes)
+
+	state, err := syncStateRepo.Get(ctx, newPhone.ID)
+	require.NoError(t, err)
+	require.NotNil(t, state)
+	assert.Equal(t, c.ID, state.LastSyncPhotoID)
+
+	// Resuming only lists what is still to download
+	page, err = svc.Manifest(ctx, "user-1", plan.ID, "", 0, true)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, b.ID, page.Items[0].PhotoID)
+	page, err = svc.Manifest(ctx, "user-1", plan.ID, "", 0, false)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 3)
+	assert.NotNil(t, page.Items[0].DownloadedAt)
+
+	// A trashed photo drops out, so confirming the rest completes the plan
+	require.NoError(t, trash.MoveToTrash(ctx, b.ID))
+	resp, err = svc.Confirm(ctx, "user-1", plan.ID, []models.RestoreConfirmation{{PhotoID: a.ID, FileHash: a.FileHash}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, resp.Plan.TotalItems)
+	assert.Equal(t, models.RestorePlanCompleted, resp.Plan.Status)
+	assert.NotNil(t, resp.Plan.CompletedAt)
+
+	_, err = svc.Confirm(ctx, "user-1", plan.ID, []models.RestoreConfirmation{{PhotoID: a.ID, FileHash: a.FileHash}})
+	assert.Equal(t, models.ErrRestorePlanFinished, err)
+
+	plans, err := svc.List(ctx, "user-1", newPhone.ID)
+	require.NoError(t, err)
+	assert.Len(t, plans, 2)
+}
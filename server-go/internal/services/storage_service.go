@@ -1,44 +1,53 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/photosync/server/internal/blobstore"
 	"github.com/photosync/server/internal/models"
 )
 
-// PhotoStorageService handles file storage with Year/Month organization
+// PhotoStorageService handles file storage with Year/Month organization.
+// Originals live in a BlobStore, so the layout is the same on local disk and
+// object storage.
 type PhotoStorageService struct {
-	basePath          string
+	store             blobstore.BlobStore
 	allowedExtensions map[string]bool
 	maxFileSizeBytes  int64
-}
 
-// NewPhotoStorageService creates a new PhotoStorageService
-func NewPhotoStorageService(basePath string, allowedExtensions []string, maxFileSizeMB int64) (*PhotoStorageService, error) {
-	if strings.TrimSpace(basePath) == "" {
-		return nil, fmt.Errorf("base path cannot be empty")
-	}
+	blobMu    sync.Mutex
+	blobLocks map[string]*blobLock // Keyed by content hash
+}
 
-	absPath, err := filepath.Abs(basePath)
-	if err != nil {
-		return nil, err
-	}
+// blobLock serialises work on one stored file; waiters counts the holders and
+// waiters so the entry can be dropped when nobody needs it
+type blobLock struct {
+	mu      sync.Mutex
+	waiters int
+}
 
-	// Ensure directory exists
-	if err := os.MkdirAll(absPath, 0755); err != nil {
-		return nil, err
+// NewPhotoStorageService creates a new PhotoStorageService
+func NewPhotoStorageService(store blobstore.BlobStore, allowedExtensions []string, maxFileSizeMB int64) (*PhotoStorageService, error) {
+	if store == nil {
+		return nil, fmt.Errorf("blob store cannot be nil")
 	}
 
 	// Build extension set
 	extSet := make(map[string]bool)
 	if len(allowedExtensions) == 0 {
 		// Defaults
-		for _, ext := range []string{".jpg", ".jpeg", ".png", ".gif", ".webp", ".heic", ".heif", ".bmp", ".tiff", ".tif"} {
+		for _, ext := range []string{
+			".jpg", ".jpeg", ".png", ".gif", ".webp", ".heic", ".heif", ".bmp", ".tiff", ".tif",
+			".mp4", ".mov", ".m4v", ".3gp",
+		} {
 			extSet[strings.ToLower(ext)] = true
 		}
 	} else {
@@ -48,12 +57,43 @@ func NewPhotoStorageService(basePath string, allowedExtensions []string, maxFile
 	}
 
 	return &PhotoStorageService{
-		basePath:          absPath,
+		store:             store,
 		allowedExtensions: extSet,
 		maxFileSizeBytes:  maxFileSizeMB * 1024 * 1024,
+		blobLocks:         make(map[string]*blobLock),
 	}, nil
 }
 
+// LockBlob serialises work on the stored file for a content hash and returns
+// the unlock function. Uploads hold it from reusing a stored file until their
+// photo row references it, and purges hold it from releasing the last
+// reference until the file is gone, so a shared file is never deleted under
+// a new owner.
+func (s *PhotoStorageService) LockBlob(fileHash string) func() {
+	fileHash = strings.ToLower(fileHash)
+
+	s.blobMu.Lock()
+	lock, ok := s.blobLocks[fileHash]
+	if !ok {
+		lock = &blobLock{}
+		s.blobLocks[fileHash] = lock
+	}
+	lock.waiters++
+	s.blobMu.Unlock()
+
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+
+		s.blobMu.Lock()
+		lock.waiters--
+		if lock.waiters == 0 {
+			delete(s.blobLocks, fileHash)
+		}
+		s.blobMu.Unlock()
+	}
+}
+
 // Store saves a file and returns the relative storage path
 func (s *PhotoStorageService) Store(reader io.Reader, originalFilename string, dateTaken time.Time, fileSize int64) (string, error) {
 	// Validate file size
@@ -69,40 +109,35 @@ func (s *PhotoStorageService) Store(reader io.Reader, originalFilename string, d
 		return "", models.ErrInvalidExtension
 	}
 
-	// Create Year/Month folder structure
-	year := dateTaken.Format("2006")
-	month := dateTaken.Format("01")
-	relativeFolderPath := filepath.Join(year, month)
-	absoluteFolderPath := filepath.Join(s.basePath, relativeFolderPath)
-
-	if err := os.MkdirAll(absoluteFolderPath, 0755); err != nil {
-		return "", err
-	}
+	// Year/Month folder structure
+	ctx := context.Background()
+	folder := path.Join(dateTaken.Format("2006"), dateTaken.Format("01"))
 
 	// Generate unique filename
-	uniqueFilename := generateUniqueFilename(sanitizedFilename, absoluteFolderPath)
-	relativeFilePath := filepath.Join(relativeFolderPath, uniqueFilename)
-	absoluteFilePath := filepath.Join(s.basePath, relativeFilePath)
-
-	// Security check: ensure path is within base path
-	if !strings.HasPrefix(absoluteFilePath, s.basePath) {
+	storedPath := path.Join(folder, s.generateUniqueFilename(ctx, sanitizedFilename, folder))
+	if _, err := blobstore.CleanKey(storedPath); err != nil {
 		return "", models.ErrPathTraversal
 	}
 
-	// Write file
-	file, err := os.OpenFile(absoluteFilePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
-	if err != nil {
+	if err := s.store.Put(ctx, storedPath, reader, fileSize); err != nil {
 		return "", err
 	}
-	defer file.Close()
 
-	if _, err := io.Copy(file, reader); err != nil {
-		os.Remove(absoluteFilePath) // Clean up on error
-		return "", err
+	return storedPath, nil
+}
+
+// ValidateUpload checks a filename and size against storage limits without storing anything
+func (s *PhotoStorageService) ValidateUpload(originalFilename string, fileSize int64) error {
+	if fileSize > s.maxFileSizeBytes {
+		return models.ErrFileTooLarge
 	}
 
-	// Return path with forward slashes for consistency
-	return strings.ReplaceAll(relativeFilePath, string(os.PathSeparator), "/"), nil
+	ext := strings.ToLower(filepath.Ext(sanitizeFilename(originalFilename)))
+	if !s.allowedExtensions[ext] {
+		return models.ErrInvalidExtension
+	}
+
+	return nil
 }
 
 // Delete removes a file by its stored path
@@ -111,50 +146,59 @@ func (s *PhotoStorageService) Delete(storedPath string) bool {
 		return false
 	}
 
-	fullPath, err := s.GetFullPath(storedPath)
-	if err != nil {
+	ctx := context.Background()
+	if !blobstore.Exists(ctx, s.store, storedPath) {
 		return false
 	}
 
-	if err := os.Remove(fullPath); err != nil {
-		return false
+	return s.store.Delete(ctx, storedPath) == nil
+}
+
+// Open opens a stored file for reading
+func (s *PhotoStorageService) Open(ctx context.Context, storedPath string) (io.ReadSeekCloser, *blobstore.ObjectInfo, error) {
+	if strings.TrimSpace(storedPath) == "" {
+		return nil, nil, fmt.Errorf("stored path cannot be empty")
 	}
 
-	return true
+	r, info, err := s.store.Get(ctx, storedPath)
+	if errors.Is(err, blobstore.ErrInvalidKey) {
+		return nil, nil, models.ErrPathTraversal
+	}
+	return r, info, err
 }
 
-// GetFullPath returns the absolute path for a stored path
+// ReadFile returns the full contents of a stored file
+func (s *PhotoStorageService) ReadFile(storedPath string) ([]byte, error) {
+	file, _, err := s.Open(context.Background(), storedPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// GetFullPath returns the absolute path for a stored path. Only stores that
+// keep originals on the local filesystem have one.
 func (s *PhotoStorageService) GetFullPath(storedPath string) (string, error) {
 	if strings.TrimSpace(storedPath) == "" {
 		return "", fmt.Errorf("stored path cannot be empty")
 	}
 
-	// Normalize path separators
-	normalizedPath := filepath.FromSlash(storedPath)
-	fullPath := filepath.Join(s.basePath, normalizedPath)
-
-	// Security check
-	absPath, err := filepath.Abs(fullPath)
-	if err != nil {
-		return "", err
+	lp, ok := s.store.(blobstore.LocalPather)
+	if !ok {
+		return "", fmt.Errorf("photo storage is not on the local filesystem")
 	}
 
-	if !strings.HasPrefix(absPath, s.basePath) {
+	fullPath, err := lp.LocalPath(storedPath)
+	if errors.Is(err, blobstore.ErrInvalidKey) {
 		return "", models.ErrPathTraversal
 	}
-
-	return absPath, nil
+	return fullPath, err
 }
 
 // Exists checks if a file exists at the given stored path
 func (s *PhotoStorageService) Exists(storedPath string) bool {
-	fullPath, err := s.GetFullPath(storedPath)
-	if err != nil {
-		return false
-	}
-
-	_, err = os.Stat(fullPath)
-	return err == nil
+	return blobstore.Exists(context.Background(), s.store, storedPath)
 }
 
 // sanitizeFilename removes path components and invalid characters
@@ -194,78 +238,41 @@ func sanitizeFilename(filename string) string {
 // MoveFile moves a file from one location to another within storage
 // Returns the new relative path
 func (s *PhotoStorageService) MoveFile(currentPath, newRelativeFolder, newFilename string) (string, error) {
-	// Get full path of current file
-	currentFullPath, err := s.GetFullPath(currentPath)
-	if err != nil {
-		return "", fmt.Errorf("invalid current path: %w", err)
-	}
+	ctx := context.Background()
 
 	// Check current file exists
-	if _, err := os.Stat(currentFullPath); os.IsNotExist(err) {
-		return "", fmt.Errorf("source file does not exist: %s", currentPath)
-	}
-
-	// Create new folder
-	newFolderFullPath := filepath.Join(s.basePath, newRelativeFolder)
-	if err := os.MkdirAll(newFolderFullPath, 0755); err != nil {
-		return "", fmt.Errorf("failed to create destination folder: %w", err)
+	if _, err := s.store.Stat(ctx, currentPath); err != nil {
+		if errors.Is(err, blobstore.ErrNotExist) {
+			return "", fmt.Errorf("source file does not exist: %s", currentPath)
+		}
+		return "", fmt.Errorf("invalid current path: %w", err)
 	}
 
 	// Generate unique filename in new location
-	uniqueFilename := generateUniqueFilename(newFilename, newFolderFullPath)
-	newRelativePath := filepath.Join(newRelativeFolder, uniqueFilename)
-	newFullPath := filepath.Join(s.basePath, newRelativePath)
+	newFolder := filepath.ToSlash(newRelativeFolder)
+	newPath := path.Join(newFolder, s.generateUniqueFilename(ctx, newFilename, newFolder))
 
 	// Security check
-	absNewPath, err := filepath.Abs(newFullPath)
-	if err != nil {
-		return "", err
-	}
-	if !strings.HasPrefix(absNewPath, s.basePath) {
+	if _, err := blobstore.CleanKey(newPath); err != nil {
 		return "", models.ErrPathTraversal
 	}
 
-	// Move the file
-	if err := os.Rename(currentFullPath, newFullPath); err != nil {
-		// If rename fails (cross-device), try copy+delete
-		if err := copyFile(currentFullPath, newFullPath); err != nil {
-			return "", fmt.Errorf("failed to move file: %w", err)
-		}
-		os.Remove(currentFullPath)
-	}
-
-	// Return path with forward slashes for consistency
-	return strings.ReplaceAll(newRelativePath, string(os.PathSeparator), "/"), nil
-}
-
-// copyFile copies a file from src to dst
-func copyFile(src, dst string) error {
-	sourceFile, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer sourceFile.Close()
-
-	destFile, err := os.Create(dst)
-	if err != nil {
-		return err
+	if err := s.store.Move(ctx, currentPath, newPath); err != nil {
+		return "", fmt.Errorf("failed to move file: %w", err)
 	}
-	defer destFile.Close()
 
-	_, err = io.Copy(destFile, sourceFile)
-	return err
+	return newPath, nil
 }
 
 // generateUniqueFilename creates a unique filename if collision exists
-func generateUniqueFilename(filename, folderPath string) string {
+func (s *PhotoStorageService) generateUniqueFilename(ctx context.Context, filename, folder string) string {
 	nameWithoutExt := strings.TrimSuffix(filename, filepath.Ext(filename))
 	ext := filepath.Ext(filename)
 	candidate := filename
 	counter := 1
 
 	for {
-		fullPath := filepath.Join(folderPath, candidate)
-		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+		if !blobstore.Exists(ctx, s.store, path.Join(folder, candidate)) {
 			break
 		}
 
@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/photosync/server/internal/blobstore"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -16,12 +17,19 @@ func setupTestStorage(t *testing.T) (*PhotoStorageService, string) {
 	tempDir, err := os.MkdirTemp("", "photosync-test-*")
 	require.NoError(t, err)
 
-	svc, err := NewPhotoStorageService(tempDir, nil, 50)
+	svc, err := NewPhotoStorageService(newTestStore(t, tempDir), nil, 50)
 	require.NoError(t, err)
 
 	return svc, tempDir
 }
 
+// newTestStore creates a local blob store rooted at dir
+func newTestStore(t *testing.T, dir string) blobstore.BlobStore {
+	store, err := blobstore.NewLocalStore(dir)
+	require.NoError(t, err)
+	return store
+}
+
 func cleanupTestStorage(tempDir string) {
 	os.RemoveAll(tempDir)
 }
@@ -179,3 +187,30 @@ func TestPhotoStorageService_Exists(t *testing.T) {
 		assert.False(t, svc.Exists("2024/01/nonexistent.jpg"))
 	})
 }
+
+func TestPhotoStorageService_LockBlob(t *testing.T) {
+	svc, tempDir := setupTestStorage(t)
+	defer cleanupTestStorage(tempDir)
+
+	unlock := svc.LockBlob("ABC")
+	acquired := make(chan struct{})
+	go func() {
+		defer svc.LockBlob("abc")()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("the same hash was locked twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+	svc.LockBlob("def")() // Other content is not blocked
+
+	unlock()
+	<-acquired
+	assert.Eventually(t, func() bool {
+		svc.blobMu.Lock()
+		defer svc.blobMu.Unlock()
+		return len(svc.blobLocks) == 0
+	}, time.Second, 10*time.Millisecond, "unused locks are dropped")
+}
@@ -2,15 +2,19 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"image"
 	"image/jpeg"
-	"os"
+	"io"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/disintegration/imaging"
 	"github.com/jdeng/goheif"
+	"github.com/photosync/server/internal/blobstore"
+	"github.com/photosync/server/internal/models"
 )
 
 // ThumbnailSize represents a thumbnail size configuration
@@ -31,39 +35,82 @@ var (
 
 // ThumbnailResult contains paths to generated thumbnails
 type ThumbnailResult struct {
-	SmallPath  string
-	MediumPath string
-	LargePath  string
-	Width      int
-	Height     int
+	SmallPath      string
+	MediumPath     string
+	LargePath      string
+	Width          int
+	Height         int
+	PerceptualHash string // dHash of the oriented image, see ComputeDHash
 }
 
 // ThumbnailService handles thumbnail generation
 type ThumbnailService struct {
-	basePath string
+	originals    blobstore.BlobStore // Where the photos being thumbnailed live
+	thumbnails   blobstore.BlobStore // Where .thumbs/ renditions are written
+	videoService *VideoService       // Optional, enables video poster thumbnails
+
+	// transcodeMu serializes full-size HEIC decodes, which are memory heavy
+	transcodeMu sync.Mutex
+}
+
+// NewThumbnailService creates a new ThumbnailService. Thumbnails keep the
+// Year/Month layout of the originals but may live in a different store, so
+// originals can sit on object storage while thumbnails stay on fast disk.
+func NewThumbnailService(originals, thumbnails blobstore.BlobStore) *ThumbnailService {
+	return &ThumbnailService{originals: originals, thumbnails: thumbnails}
+}
+
+// SetVideoService enables poster-frame thumbnails for videos
+func (s *ThumbnailService) SetVideoService(videoService *VideoService) {
+	s.videoService = videoService
 }
 
-// NewThumbnailService creates a new ThumbnailService
-func NewThumbnailService(basePath string) *ThumbnailService {
-	return &ThumbnailService{basePath: basePath}
+// CanGenerateThumbnails checks if thumbnails can be produced for the file,
+// taking into account whether video support is available
+func (s *ThumbnailService) CanGenerateThumbnails(filename string) bool {
+	if models.IsVideoFile(filename) {
+		return s.videoService != nil
+	}
+	return IsSupportedFormat(filename)
+}
+
+// GenerateVideoThumbnails creates thumbnails from a poster frame of a stored video
+func (s *ThumbnailService) GenerateVideoThumbnails(photoID string, storedPath string) (*ThumbnailResult, error) {
+	if s.videoService == nil {
+		return nil, fmt.Errorf("video thumbnails require ffmpeg")
+	}
+
+	frame, err := s.videoService.ExtractPosterFrame(storedPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract poster frame: %w", err)
+	}
+
+	// ffmpeg applies rotation metadata itself, so no orientation correction
+	return s.GenerateThumbnails(frame, photoID, storedPath, 1)
 }
 
 // GenerateThumbnails creates thumbnails for an image and returns their paths
 func (s *ThumbnailService) GenerateThumbnails(imageData []byte, photoID string, storedPath string, orientation int) (*ThumbnailResult, error) {
+	return s.GenerateThumbnailsFromReader(bytes.NewReader(imageData), photoID, storedPath, orientation)
+}
+
+// GenerateThumbnailsFromReader creates thumbnails for an image decoded straight
+// from the reader, so the encoded file is never held in memory
+func (s *ThumbnailService) GenerateThumbnailsFromReader(r io.Reader, photoID string, storedPath string, orientation int) (*ThumbnailResult, error) {
 	var img image.Image
 	var format string
 	var err error
 
 	// Check if this is a HEIC/HEIF file
 	if IsHEIC(storedPath) {
-		img, err = decodeHEIC(imageData)
+		img, err = decodeHEIC(r)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decode HEIC image: %w", err)
+			return nil, err
 		}
 		format = "heic"
 	} else {
 		// Standard image decode
-		img, format, err = image.Decode(bytes.NewReader(imageData))
+		img, format, err = image.Decode(r)
 		if err != nil {
 			return nil, fmt.Errorf("failed to decode image: %w", err)
 		}
@@ -82,15 +129,10 @@ func (s *ThumbnailService) GenerateThumbnails(imageData []byte, photoID string,
 	dir := filepath.Dir(storedPath)
 	thumbDir := filepath.Join(dir, ".thumbs")
 
-	// Create thumbnail directory if it doesn't exist
-	fullThumbDir := filepath.Join(s.basePath, thumbDir)
-	if err := os.MkdirAll(fullThumbDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create thumbnail directory: %w", err)
-	}
-
 	result := &ThumbnailResult{
-		Width:  width,
-		Height: height,
+		Width:          width,
+		Height:         height,
+		PerceptualHash: FormatPerceptualHash(ComputeDHash(img)),
 	}
 
 	// Generate each thumbnail size
@@ -146,22 +188,18 @@ func (s *ThumbnailService) generateThumbnail(img image.Image, photoID string, th
 	// Generate filename: {photoID}_{size}.jpg
 	filename := fmt.Sprintf("%s_%s.jpg", photoID, size.Name)
 	relativePath := filepath.Join(thumbDir, filename)
-	fullPath := filepath.Join(s.basePath, relativePath)
-
-	// Create the file
-	out, err := os.Create(fullPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to create thumbnail file: %w", err)
-	}
-	defer out.Close()
 
 	// Encode as JPEG
+	var buf bytes.Buffer
 	opts := &jpeg.Options{Quality: size.Quality}
-	if err := jpeg.Encode(out, resized, opts); err != nil {
-		os.Remove(fullPath) // Clean up on failure
+	if err := jpeg.Encode(&buf, resized, opts); err != nil {
 		return "", fmt.Errorf("failed to encode thumbnail: %w", err)
 	}
 
+	if err := s.thumbnails.Put(context.Background(), relativePath, &buf, int64(buf.Len())); err != nil {
+		return "", fmt.Errorf("failed to write thumbnail file: %w", err)
+	}
+
 	return relativePath, nil
 }
 
@@ -197,9 +235,25 @@ func applyOrientation(img image.Image, orientation int) image.Image {
 	}
 }
 
-// GetThumbnailPath returns the full filesystem path for a thumbnail
-func (s *ThumbnailService) GetThumbnailPath(relativePath string) string {
-	return filepath.Join(s.basePath, relativePath)
+// PerceptualHashFromThumbnail computes the perceptual hash of an existing
+// thumbnail, which is much cheaper than decoding the original again
+func (s *ThumbnailService) PerceptualHashFromThumbnail(thumbPath string) (string, error) {
+	r, _, err := s.thumbnails.Get(context.Background(), thumbPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open thumbnail: %w", err)
+	}
+	defer r.Close()
+
+	img, err := imaging.Decode(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to open thumbnail: %w", err)
+	}
+	return FormatPerceptualHash(ComputeDHash(img)), nil
+}
+
+// OpenThumbnail opens a thumbnail (or cached rendition) by its relative path
+func (s *ThumbnailService) OpenThumbnail(ctx context.Context, relativePath string) (io.ReadSeekCloser, *blobstore.ObjectInfo, error) {
+	return s.thumbnails.Get(ctx, relativePath)
 }
 
 // DeleteThumbnails removes all thumbnails for a photo
@@ -207,8 +261,7 @@ func (s *ThumbnailService) DeleteThumbnails(smallPath, mediumPath, largePath str
 	paths := []string{smallPath, mediumPath, largePath}
 	for _, p := range paths {
 		if p != "" {
-			fullPath := filepath.Join(s.basePath, p)
-			os.Remove(fullPath) // Ignore errors for non-existent files
+			s.thumbnails.Delete(context.Background(), p) // Ignore errors for non-existent files
 		}
 	}
 	return nil
@@ -238,33 +291,91 @@ func IsHEIC(filename string) bool {
 	return ext == ".heic" || ext == ".heif"
 }
 
-// RegenerateThumbnailsFromFile generates thumbnails from an existing file on disk
-func (s *ThumbnailService) RegenerateThumbnailsFromFile(photoID string, storedPath string) (*ThumbnailResult, error) {
-	fullPath := filepath.Join(s.basePath, storedPath)
-
+// RegenerateThumbnailsFromFile generates thumbnails from an existing stored original
+func (s *ThumbnailService) RegenerateThumbnailsFromFile(photoID string, storedPath string, orientation int) (*ThumbnailResult, error) {
 	// Check if file exists
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+	if !blobstore.Exists(context.Background(), s.originals, storedPath) {
 		return nil, fmt.Errorf("file not found: %s", storedPath)
 	}
 
+	// Videos go through ffmpeg rather than the image decoders
+	if models.IsVideoFile(storedPath) {
+		return s.GenerateVideoThumbnails(photoID, storedPath)
+	}
+
 	// Skip unsupported formats
 	if !IsSupportedFormat(storedPath) {
 		return nil, fmt.Errorf("unsupported format: %s", storedPath)
 	}
 
 	// Read file
-	imageData, err := os.ReadFile(fullPath)
+	imageData, err := blobstore.ReadAll(context.Background(), s.originals, storedPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
-	// Generate thumbnails (orientation=1 means no rotation, can be enhanced later)
-	return s.GenerateThumbnails(imageData, photoID, storedPath, 1)
+	return s.GenerateThumbnails(imageData, photoID, storedPath, orientation)
+}
+
+// TranscodedJPEGPath returns the relative path of the cached JPEG rendition of a photo
+func TranscodedJPEGPath(photoID string, storedPath string) string {
+	return filepath.Join(filepath.Dir(storedPath), ".thumbs", photoID+"_full.jpg")
+}
+
+// GetJPEGRendition returns the thumbnail-store path of a full-resolution JPEG
+// copy of a HEIC/HEIF original; open it with OpenThumbnail. The copy is
+// transcoded on first request and cached next to the thumbnails; it is
+// rebuilt if the original is newer than the cache.
+func (s *ThumbnailService) GetJPEGRendition(photoID string, storedPath string, orientation int) (string, error) {
+	if !IsHEIC(storedPath) {
+		return "", fmt.Errorf("not a HEIC file: %s", storedPath)
+	}
+
+	ctx := context.Background()
+	cachePath := TranscodedJPEGPath(photoID, storedPath)
+
+	s.transcodeMu.Lock()
+	defer s.transcodeMu.Unlock()
+
+	sourceInfo, err := s.originals.Stat(ctx, storedPath)
+	if err != nil {
+		return "", fmt.Errorf("file not found: %s", storedPath)
+	}
+	if cacheInfo, err := s.thumbnails.Stat(ctx, cachePath); err == nil && !cacheInfo.ModTime.Before(sourceInfo.ModTime) {
+		return cachePath, nil
+	}
+
+	data, err := blobstore.ReadAll(ctx, s.originals, storedPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	img, err := decodeHEIC(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	img = applyOrientation(img, orientation)
+
+	// Stores write atomically, so a concurrent reader never sees a partial JPEG
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		return "", fmt.Errorf("failed to encode JPEG: %w", err)
+	}
+	if err := s.thumbnails.Put(ctx, cachePath, &buf, int64(buf.Len())); err != nil {
+		return "", fmt.Errorf("failed to write cache file: %w", err)
+	}
+
+	return cachePath, nil
+}
+
+// DeleteJPEGRendition removes the cached JPEG rendition of a photo, if any
+func (s *ThumbnailService) DeleteJPEGRendition(photoID string, storedPath string) {
+	s.thumbnails.Delete(context.Background(), TranscodedJPEGPath(photoID, storedPath))
 }
 
 // decodeHEIC decodes a HEIC/HEIF image using goheif (pure Go)
-func decodeHEIC(data []byte) (image.Image, error) {
-	img, err := goheif.Decode(bytes.NewReader(data))
+func decodeHEIC(r io.Reader) (image.Image, error) {
+	img, err := goheif.Decode(r)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode HEIC image: %w", err)
 	}
@@ -281,7 +392,7 @@ func (s *ThumbnailService) GenerateSingleThumbnail(imageData []byte, maxDim int,
 	img, _, err = image.Decode(bytes.NewReader(imageData))
 	if err != nil {
 		// Try HEIC
-		img, err = decodeHEIC(imageData)
+		img, err = decodeHEIC(bytes.NewReader(imageData))
 		if err != nil {
 			return nil, fmt.Errorf("failed to decode image: %w", err)
 		}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestThumbnailService keeps originals and thumbnails in one local store at dir
+func newTestThumbnailService(t *testing.T, dir string) *ThumbnailService {
+	store := newTestStore(t, dir)
+	return NewThumbnailService(store, store)
+}
+
+func TestTranscodedJPEGPath(t *testing.T) {
+	path := TranscodedJPEGPath("photo-1", "2024/01/IMG_0001.HEIC")
+
+	assert.Equal(t, filepath.Join("2024", "01", ".thumbs", "photo-1_full.jpg"), path)
+}
+
+func TestThumbnailService_GetJPEGRendition(t *testing.T) {
+	t.Run("rejects non-HEIC files", func(t *testing.T) {
+		svc := newTestThumbnailService(t, t.TempDir())
+
+		_, err := svc.GetJPEGRendition("photo-1", "2024/01/photo.jpg", 1)
+		assert.Error(t, err)
+	})
+
+	t.Run("serves existing cache without decoding", func(t *testing.T) {
+		tempDir := t.TempDir()
+		svc := newTestThumbnailService(t, tempDir)
+
+		storedPath := "2024/01/IMG_0001.HEIC"
+		require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "2024", "01", ".thumbs"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, storedPath), []byte("not really heic"), 0644))
+
+		cachePath := filepath.Join(tempDir, TranscodedJPEGPath("photo-1", storedPath))
+		require.NoError(t, os.WriteFile(cachePath, []byte("cached jpeg"), 0644))
+
+		path, err := svc.GetJPEGRendition("photo-1", storedPath, 1)
+		require.NoError(t, err)
+		assert.Equal(t, TranscodedJPEGPath("photo-1", storedPath), path)
+	})
+
+	t.Run("returns decode error for corrupt original", func(t *testing.T) {
+		tempDir := t.TempDir()
+		svc := newTestThumbnailService(t, tempDir)
+
+		storedPath := "2024/01/IMG_0002.HEIC"
+		require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "2024", "01"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, storedPath), []byte("not really heic"), 0644))
+
+		_, err := svc.GetJPEGRendition("photo-2", storedPath, 1)
+		assert.Error(t, err)
+		assert.NoFileExists(t, filepath.Join(tempDir, TranscodedJPEGPath("photo-2", storedPath)))
+	})
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+)
+
+// trashPurgeBatchSize limits how many photos are purged per repository query
+const trashPurgeBatchSize = 100
+
+// TrashService manages soft-deleted photos. Trashed photos keep their file
+// and thumbnails until they are restored, the trash is emptied or the
+// retention period ends.
+type TrashService struct {
+	photoRepo        repository.PhotoRepo
+	storageService   *PhotoStorageService
+	thumbnailService *ThumbnailService
+	libraryEvents    *LibraryEventService // Optional
+	retention        time.Duration
+}
+
+// NewTrashService creates a new TrashService
+func NewTrashService(
+	photoRepo repository.PhotoRepo,
+	storageService *PhotoStorageService,
+	thumbnailService *ThumbnailService,
+	retentionDays int,
+) *TrashService {
+	if retentionDays <= 0 {
+		retentionDays = 30
+	}
+	return &TrashService{
+		photoRepo:        photoRepo,
+		storageService:   storageService,
+		thumbnailService: thumbnailService,
+		retention:        time.Duration(retentionDays) * 24 * time.Hour,
+	}
+}
+
+// SetLibraryEvents enables real-time events when photos are trashed, restored or purged
+func (s *TrashService) SetLibraryEvents(libraryEvents *LibraryEventService) {
+	s.libraryEvents = libraryEvents
+}
+
+// RetentionDays returns how long photos stay in the trash before being purged
+func (s *TrashService) RetentionDays() int {
+	return int(s.retention / (24 * time.Hour))
+}
+
+// PurgeAt returns when a trashed photo will be permanently deleted
+func (s *TrashService) PurgeAt(photo *models.Photo) *time.Time {
+	if photo.DeletedAt == nil {
+		return nil
+	}
+	purgeAt := photo.DeletedAt.Add(s.retention)
+	return &purgeAt
+}
+
+// MoveToTrash puts a live photo in the trash
+func (s *TrashService) MoveToTrash(ctx context.Context, photoID string) error {
+	trashed, err := s.photoRepo.MoveToTrash(ctx, photoID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if !trashed {
+		return models.ErrPhotoNotFound
+	}
+
+	if s.libraryEvents != nil {
+		photo, err := s.photoRepo.GetByID(ctx, photoID)
+		if err != nil {
+			log.Printf("Warning: failed to load trashed photo %s for library event: %v", photoID, err)
+		} else if photo != nil && photo.UserID != nil {
+			s.libraryEvents.Publish(*photo.UserID, models.NewPhotoEvent(models.LibraryEventPhotoTrashed, photo))
+		}
+	}
+	return nil
+}
+
+// Restore takes one of the user's photos back out of the trash
+func (s *TrashService) Restore(ctx context.Context, userID, photoID string) error {
+	photo, err := s.photoRepo.GetByID(ctx, photoID)
+	if err != nil {
+		return err
+	}
+	if photo == nil || photo.UserID == nil || *photo.UserID != userID {
+		return models.ErrPhotoNotFound
+	}
+	if !photo.IsTrashed() {
+		return models.ErrPhotoNotInTrash
+	}
+
+	if _, err := s.photoRepo.Restore(ctx, photoID); err != nil {
+		return err
+	}
+
+	if s.libraryEvents != nil {
+		photo.DeletedAt = nil
+		s.libraryEvents.Publish(userID, models.NewPhotoEvent(models.LibraryEventPhotoRestored, photo))
+	}
+	return nil
+}
+
+// List returns a page of the user's trash and the total number of trashed photos
+func (s *TrashService) List(ctx context.Context, userID string, skip, take int) ([]*models.Photo, int, error) {
+	photos, err := s.photoRepo.GetTrashForUser(ctx, userID, skip, take)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.photoRepo.GetTrashCountForUser(ctx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return photos, total, nil
+}
+
+// Empty permanently deletes everything in the user's trash.
+// Returns the number of photos purged.
+func (s *TrashService) Empty(ctx context.Context, userID string) (int, error) {
+	purged := 0
+	for {
+		photos, err := s.photoRepo.GetTrashForUser(ctx, userID, 0, trashPurgeBatchSize)
+		if err != nil {
+			return purged, err
+		}
+		if len(photos) == 0 {
+			return purged, nil
+		}
+
+		for _, photo := range photos {
+			if err := s.purge(ctx, photo); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+	}
+}
+
+// PurgeExpired permanently deletes photos that have been in the trash longer
+// than the retention period. Returns the number purged and any errors.
+func (s *TrashService) PurgeExpired(ctx context.Context) (int, []string) {
+	var errors []string
+
+	cutoff := time.Now().UTC().Add(-s.retention)
+	photos, err := s.photoRepo.GetTrashedBefore(ctx, cutoff, trashPurgeBatchSize)
+	if err != nil {
+		return 0, []string{"Failed to get expired trash: " + err.Error()}
+	}
+
+	purged := 0
+	for _, photo := range photos {
+		if err := s.purge(ctx, photo); err != nil {
+			errors = append(errors, "Failed to purge photo "+photo.ID+": "+err.Error())
+			continue
+		}
+		purged++
+	}
+
+	return purged, errors
+}
+
+// purge removes a photo's database row, thumbnails and cached rendition. The
+// stored original is shared between owners of the same content, so it is only
+// deleted once no other photo references it. The blob lock keeps an upload of
+// the same content from reusing the file between the count and the delete.
+func (s *TrashService) purge(ctx context.Context, photo *models.Photo) error {
+	unlock := s.storageService.LockBlob(photo.FileHash)
+	defer unlock()
+
+	deleted, refs, err := s.photoRepo.DeleteReleasingBlob(ctx, photo.ID)
+	if err != nil {
+		return err
+	}
+	if deleted && refs == 0 {
+		if !s.storageService.Delete(photo.StoredPath) && s.storageService.Exists(photo.StoredPath) {
+			log.Printf("Warning: failed to delete file %s for purged photo %s", photo.StoredPath, photo.ID)
+		}
+	}
+
+	s.thumbnailService.DeleteJPEGRendition(photo.ID, photo.StoredPath)
+	s.thumbnailService.DeleteStrippedRenditions(photo.ID, photo.StoredPath)
+	var small, medium, large string
+	if photo.ThumbSmall != nil {
+		small = *photo.ThumbSmall
+	}
+	if photo.ThumbMedium != nil {
+		medium = *photo.ThumbMedium
+	}
+	if photo.ThumbLarge != nil {
+		large = *photo.ThumbLarge
+	}
+	s.thumbnailService.DeleteThumbnails(small, medium, large)
+
+	if s.libraryEvents != nil && photo.UserID != nil {
+		s.libraryEvents.Publish(*photo.UserID, models.NewPhotoEvent(models.LibraryEventPhotoDeleted, photo))
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestTrash(t *testing.T) (*TrashService, repository.PhotoRepo, *PhotoStorageService) {
+	db, tempDir := setupTestDB(t)
+	photoRepo := repository.NewPhotoRepository(db)
+	trashService, storageService := newTestTrashService(t, photoRepo, tempDir)
+	return trashService, photoRepo, storageService
+}
+
+// setupTestDB opens a SQLite database in a temp dir with users "user-1" and "user-2"
+func setupTestDB(t *testing.T) (*sql.DB, string) {
+	tempDir := t.TempDir()
+
+	db, err := repository.NewSQLiteDB(filepath.Join(tempDir, "photosync.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	// Photos reference their owner, so the test users must exist
+	userRepo := repository.NewUserRepository(db)
+	for _, id := range []string{"user-1", "user-2"} {
+		user, err := models.NewUser(id+"@example.com", id, false)
+		require.NoError(t, err)
+		user.ID = id
+		require.NoError(t, userRepo.Add(context.Background(), user))
+	}
+
+	return db, tempDir
+}
+
+// newTestStorage stores originals under <tempDir>/photos
+func newTestStorage(t *testing.T, tempDir string) *PhotoStorageService {
+	storageService, err := NewPhotoStorageService(newTestStore(t, filepath.Join(tempDir, "photos")), nil, 10)
+	require.NoError(t, err)
+	return storageService
+}
+
+// newTestUploadService ingests into the storage with thumbnails next to the originals
+func newTestUploadService(t *testing.T, photoRepo repository.PhotoRepo, storageService *PhotoStorageService, tempDir string) *UploadService {
+	return NewUploadService(
+		photoRepo, storageService, NewHashService(), NewEXIFService(),
+		newTestThumbnailService(t, filepath.Join(tempDir, "photos")), nil, nil,
+	)
+}
+
+func newTestTrashService(t *testing.T, photoRepo repository.PhotoRepo, tempDir string) (*TrashService, *PhotoStorageService) {
+	storageService := newTestStorage(t, tempDir)
+	thumbnailService := newTestThumbnailService(t, filepath.Join(tempDir, "photos"))
+	return NewTrashService(photoRepo, storageService, thumbnailService, 30), storageService
+}
+
+func addTestPhoto(t *testing.T, repo repository.PhotoRepo, storage *PhotoStorageService, userID, hash string) *models.Photo {
+	content := []byte("photo " + hash)
+	storedPath, err := storage.Store(bytes.NewReader(content), hash+".jpg", time.Now(), int64(len(content)))
+	require.NoError(t, err)
+
+	photo, err := models.NewPhoto(hash+".jpg", storedPath, hash, int64(len(content)), time.Now())
+	require.NoError(t, err)
+	photo.UserID = &userID
+	require.NoError(t, repo.Add(context.Background(), photo))
+	return photo
+}
+
+func TestTrashService_MoveToTrashAndRestore(t *testing.T) {
+	ctx := context.Background()
+	svc, repo, storage := setupTestTrash(t)
+	photo := addTestPhoto(t, repo, storage, "user-1", "aaa111")
+
+	require.NoError(t, svc.MoveToTrash(ctx, photo.ID))
+
+	count, err := repo.GetCountForUser(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	trash, total, err := svc.List(ctx, "user-1", 0, 50)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, trash, 1)
+	assert.True(t, trash[0].IsTrashed())
+	assert.True(t, storage.Exists(photo.StoredPath), "file should stay on disk while trashed")
+
+	assert.Equal(t, models.ErrPhotoNotFound, svc.MoveToTrash(ctx, photo.ID))
+	assert.Equal(t, models.ErrPhotoNotFound, svc.Restore(ctx, "user-2", photo.ID))
+
+	require.NoError(t, svc.Restore(ctx, "user-1", photo.ID))
+	assert.Equal(t, models.ErrPhotoNotInTrash, svc.Restore(ctx, "user-1", photo.ID))
+
+	count, err = repo.GetCountForUser(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestTrashService_Empty(t *testing.T) {
+	ctx := context.Background()
+	svc, repo, storage := setupTestTrash(t)
+	trashed := addTestPhoto(t, repo, storage, "user-1", "bbb222")
+	kept := addTestPhoto(t, repo, storage, "user-1", "ccc333")
+	other := addTestPhoto(t, repo, storage, "user-2", "ddd444")
+
+	require.NoError(t, svc.MoveToTrash(ctx, trashed.ID))
+	require.NoError(t, svc.MoveToTrash(ctx, other.ID))
+
+	purged, err := svc.Empty(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	gone, err := repo.GetByID(ctx, trashed.ID)
+	require.NoError(t, err)
+	assert.Nil(t, gone)
+	assert.False(t, storage.Exists(trashed.StoredPath))
+
+	assert.True(t, storage.Exists(kept.StoredPath))
+	assert.True(t, storage.Exists(other.StoredPath), "other users' trash must not be emptied")
+}
+
+func TestTrashService_PurgeExpired(t *testing.T) {
+	ctx := context.Background()
+	svc, repo, storage := setupTestTrash(t)
+	expired := addTestPhoto(t, repo, storage, "user-1", "eee555")
+	recent := addTestPhoto(t, repo, storage, "user-1", "fff666")
+
+	_, err := repo.MoveToTrash(ctx, expired.ID, time.Now().UTC().Add(-31*24*time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, svc.MoveToTrash(ctx, recent.ID))
+
+	purged, errors := svc.PurgeExpired(ctx)
+	assert.Empty(t, errors)
+	assert.Equal(t, 1, purged)
+
+	assert.False(t, storage.Exists(expired.StoredPath))
+	assert.True(t, storage.Exists(recent.StoredPath))
+}
@@ -0,0 +1,607 @@
+package services
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+)
+
+// MaxBatchManifestItems caps a single manifest; larger libraries are split
+// across several batches
+const MaxBatchManifestItems = 5000
+
+// BatchFile is one file read from a batch upload request
+type BatchFile struct {
+	Filename     string
+	ExpectedHash string // Optional SHA-256 the client asserted for this file
+	Reader       io.Reader
+}
+
+// BatchFileSource yields the files of a batch upload request one at a time.
+// Next returns io.EOF once every file has been read; each reader is only
+// valid until the following call.
+type BatchFileSource interface {
+	Next() (*BatchFile, error)
+}
+
+// BatchHashHeader carries a file's SHA-256 on a multipart part; tar streams
+// use the PAX record BatchHashPAXRecord instead
+const (
+	BatchHashHeader    = "X-Content-SHA256"
+	BatchHashPAXRecord = "PHOTOSYNC.sha256"
+)
+
+// UploadBatchService runs manifest-driven bulk uploads. A manifest is
+// checked against the user's library once, then files are streamed in and
+// handed to the upload pipeline through a bounded worker pool. Batch state is
+// kept under <basePath>/.uploads/batches so a batch survives restarts and can
+// be filled by several requests.
+type UploadBatchService struct {
+	batchDir       string
+	ttl            time.Duration
+	workers        int
+	photoRepo      repository.PhotoRepo
+	hashService    *HashService
+	storageService *PhotoStorageService
+	uploadService  *UploadService
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// NewUploadBatchService creates a new UploadBatchService
+func NewUploadBatchService(
+	basePath string,
+	ttlHours int,
+	workers int,
+	photoRepo repository.PhotoRepo,
+	hashService *HashService,
+	storageService *PhotoStorageService,
+	uploadService *UploadService,
+) (*UploadBatchService, error) {
+	batchDir := filepath.Join(basePath, ".uploads", "batches")
+	if err := os.MkdirAll(batchDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create batch directory: %w", err)
+	}
+
+	if ttlHours <= 0 {
+		ttlHours = 24
+	}
+	if workers <= 0 {
+		workers = 4
+	}
+
+	return &UploadBatchService{
+		batchDir:       batchDir,
+		ttl:            time.Duration(ttlHours) * time.Hour,
+		workers:        workers,
+		photoRepo:      photoRepo,
+		hashService:    hashService,
+		storageService: storageService,
+		uploadService:  uploadService,
+		locks:          make(map[string]*sync.Mutex),
+	}, nil
+}
+
+// Create records a manifest and works out which files the server still needs
+func (s *UploadBatchService) Create(ctx context.Context, userID string, req *models.CreateUploadBatchRequest) (*models.UploadBatch, error) {
+	if len(req.Items) == 0 {
+		return nil, models.ErrUploadBatchEmpty
+	}
+	if len(req.Items) > MaxBatchManifestItems {
+		return nil, models.ErrUploadBatchTooLarge
+	}
+
+	batch := models.NewUploadBatch(userID, req.DeviceID, s.ttl)
+	seen := make(map[string]bool)
+	var candidates []string
+
+	for _, entry := range req.Items {
+		item := &models.UploadBatchItem{
+			Hash:             s.hashService.NormalizeHash(entry.Hash),
+			Size:             entry.Size,
+			OriginalFilename: entry.Filename,
+			Status:           models.BatchItemNeeded,
+		}
+		if entry.DateTaken != "" {
+			if parsed, err := time.Parse(time.RFC3339, entry.DateTaken); err == nil {
+				item.DateTaken = &parsed
+			}
+		}
+
+		// The same content listed twice only needs sending once
+		if seen[item.Hash] {
+			continue
+		}
+		seen[item.Hash] = true
+
+		switch {
+		case !s.hashService.IsValidHash(item.Hash):
+			item.Status = models.BatchItemRejected
+			item.Error = "invalid SHA256 hash"
+		case item.Size <= 0:
+			item.Status = models.BatchItemRejected
+			item.Error = models.ErrInvalidFileSize.Error()
+		default:
+			if err := s.storageService.ValidateUpload(item.OriginalFilename, item.Size); err != nil {
+				item.Status = models.BatchItemRejected
+				item.Error = err.Error()
+			} else {
+				candidates = append(candidates, item.Hash)
+			}
+		}
+
+		batch.Items = append(batch.Items, item)
+	}
+
+	// Look hashes up in CheckHashes-sized chunks to stay under driver parameter limits
+	existing := make(map[string]bool)
+	for start := 0; start < len(candidates); start += 1000 {
+		end := start + 1000
+		if end > len(candidates) {
+			end = len(candidates)
+		}
+		hashes, err := s.photoRepo.GetExistingHashesForUser(ctx, candidates[start:end], userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, hash := range hashes {
+			existing[hash] = true
+		}
+	}
+	for _, item := range batch.Items {
+		if item.Status == models.BatchItemNeeded && existing[item.Hash] {
+			item.Status = models.BatchItemExisting
+		}
+	}
+
+	if err := s.save(batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// Get returns a batch owned by the user
+func (s *UploadBatchService) Get(batchID, userID string) (*models.UploadBatch, error) {
+	batch, err := s.load(batchID)
+	if err != nil {
+		return nil, err
+	}
+	if batch.UserID != userID {
+		return nil, models.ErrUploadBatchNotFound
+	}
+	if batch.IsExpired() {
+		return nil, models.ErrUploadBatchExpired
+	}
+	return batch, nil
+}
+
+// batchJob is a received file spooled to disk and matched to its manifest entry
+type batchJob struct {
+	index int
+	path  string
+	size  int64
+	item  models.UploadBatchItem
+}
+
+// Process reads every file from src and uploads the ones the manifest still
+// needs. Files are matched to manifest entries by content hash, so names in
+// the request do not matter and a file that arrives corrupted can never be
+// stored under another entry. Reading stays at most one file ahead of the
+// workers, which bounds the temporary disk space in use.
+func (s *UploadBatchService) Process(ctx context.Context, batchID, userID string, src BatchFileSource) (*models.UploadBatchResult, error) {
+	batch, err := s.Get(batchID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	needed := make(map[string]models.UploadBatchItem)
+	var maxSize int64
+	for _, item := range batch.Items {
+		if item.IsNeeded() {
+			needed[item.Hash] = *item
+			if item.Size > maxSize {
+				maxSize = item.Size
+			}
+		}
+	}
+
+	var (
+		resultsMu sync.Mutex
+		results   []models.UploadBatchItemResult
+		wg        sync.WaitGroup
+	)
+	setResult := func(index int, result models.UploadBatchItemResult) {
+		resultsMu.Lock()
+		defer resultsMu.Unlock()
+		for len(results) <= index {
+			results = append(results, models.UploadBatchItemResult{})
+		}
+		results[index] = result
+	}
+
+	// Files already read are finished even if the client goes away mid-request
+	workCtx := context.WithoutCancel(ctx)
+	jobs := make(chan batchJob)
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				setResult(job.index, s.processJob(workCtx, batch, job))
+			}
+		}()
+	}
+
+	var readErr error
+	for index := 0; ; index++ {
+		file, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+
+		path, hash, size, err := s.spool(batchID, file.Reader, maxSize)
+		if err != nil {
+			readErr = err
+			break
+		}
+
+		// An asserted hash pins down which entry a corrupted file was meant to be
+		if err := s.hashService.Verify(file.ExpectedHash, hash); err != nil {
+			os.Remove(path)
+			expected := s.hashService.NormalizeHash(file.ExpectedHash)
+			if item, ok := needed[expected]; ok && err == models.ErrHashMismatch {
+				item.Status = models.BatchItemFailed
+				item.Error = err.Error()
+				setResult(index, item.ToResult())
+			} else {
+				setResult(index, models.UploadBatchItemResult{
+					Hash:             hash,
+					OriginalFilename: file.Filename,
+					Status:           models.BatchItemUnexpected,
+					Error:            err.Error(),
+				})
+			}
+			continue
+		}
+
+		item, ok := needed[hash]
+		switch {
+		case !ok:
+			os.Remove(path)
+			setResult(index, models.UploadBatchItemResult{
+				Hash:             hash,
+				OriginalFilename: file.Filename,
+				Status:           models.BatchItemUnexpected,
+				Error:            "file does not match any needed manifest entry",
+			})
+		case size != item.Size:
+			os.Remove(path)
+			item.Status = models.BatchItemFailed
+			item.Error = fmt.Sprintf("received %d bytes, manifest declared %d", size, item.Size)
+			setResult(index, item.ToResult())
+		default:
+			// A file sent twice in one request is only processed once
+			delete(needed, hash)
+			jobs <- batchJob{index: index, path: path, size: size, item: item}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	result, err := s.record(batchID, results)
+	if err != nil {
+		return nil, err
+	}
+	if readErr != nil {
+		return result, fmt.Errorf("failed to read batch files: %w", readErr)
+	}
+	return result, nil
+}
+
+// processJob runs one spooled file through the upload pipeline
+func (s *UploadBatchService) processJob(ctx context.Context, batch *models.UploadBatch, job batchJob) models.UploadBatchItemResult {
+	defer os.Remove(job.path)
+	item := job.item
+
+	file, err := os.Open(job.path)
+	if err != nil {
+		item.Status = models.BatchItemFailed
+		item.Error = err.Error()
+		return item.ToResult()
+	}
+	defer file.Close()
+
+	upload, err := s.uploadService.Process(ctx, file, job.size, UploadOptions{
+		OriginalFilename: item.OriginalFilename,
+		DateTaken:        item.DateTaken,
+		DeviceID:         batch.DeviceID,
+		UserID:           batch.UserID,
+		ExpectedHash:     item.Hash,
+	})
+	switch {
+	case err != nil:
+		if _, ok := err.(models.PhotoError); !ok {
+			log.Printf("Batch %s: failed to process %s: %v", batch.ID, item.Hash, err)
+		}
+		item.Status = models.BatchItemFailed
+		item.Error = err.Error()
+	case upload.IsDuplicate:
+		item.Status = models.BatchItemDuplicate
+		item.PhotoID = upload.ID
+		item.Error = ""
+	default:
+		item.Status = models.BatchItemUploaded
+		item.PhotoID = upload.ID
+		item.Error = ""
+	}
+	return item.ToResult()
+}
+
+// spool copies one file to a temporary file while hashing it. Anything
+// larger than maxSize cannot match a needed entry and is discarded.
+func (s *UploadBatchService) spool(batchID string, r io.Reader, maxSize int64) (path, hash string, size int64, err error) {
+	tmp, err := os.CreateTemp(s.batchDir, batchID+"-*.part")
+	if err != nil {
+		return "", "", 0, err
+	}
+	defer tmp.Close()
+
+	h := sha256.New()
+	size, err = io.Copy(io.MultiWriter(tmp, h), io.LimitReader(r, maxSize+1))
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", "", 0, err
+	}
+
+	// Drain the rest of an oversized file so the next one can be read
+	if size > maxSize {
+		extra, err := io.Copy(io.Discard, r)
+		if err != nil {
+			os.Remove(tmp.Name())
+			return "", "", 0, err
+		}
+		size += extra
+	}
+
+	return tmp.Name(), hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// record merges a request's results into the stored batch. The batch is
+// reloaded under its lock because other requests may be filling it too.
+func (s *UploadBatchService) record(batchID string, results []models.UploadBatchItemResult) (*models.UploadBatchResult, error) {
+	lock := s.lockFor(batchID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	batch, err := s.load(batchID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &models.UploadBatchResult{
+		UploadToken: batchID,
+		Results:     []models.UploadBatchItemResult{},
+	}
+	for _, result := range results {
+		if result.Status == "" {
+			continue
+		}
+		summary.Results = append(summary.Results, result)
+
+		switch result.Status {
+		case models.BatchItemUploaded:
+			summary.Uploaded++
+		case models.BatchItemDuplicate:
+			summary.Duplicates++
+		case models.BatchItemFailed:
+			summary.Failed++
+		}
+
+		// Never let a late failure undo an upload another request completed
+		if item := batch.Item(result.Hash); item != nil && item.IsNeeded() {
+			item.Status = result.Status
+			item.PhotoID = result.PhotoID
+			item.Error = result.Error
+		}
+	}
+
+	for _, item := range batch.Items {
+		if item.IsNeeded() {
+			summary.Remaining++
+		}
+	}
+
+	batch.UpdatedAt = time.Now().UTC()
+	batch.ExpiresAt = batch.UpdatedAt.Add(s.ttl)
+	if err := s.save(batch); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// Delete removes a batch owned by the user
+func (s *UploadBatchService) Delete(batchID, userID string) error {
+	batch, err := s.load(batchID)
+	if err != nil {
+		return err
+	}
+	if batch.UserID != userID {
+		return models.ErrUploadBatchNotFound
+	}
+
+	s.Remove(batchID)
+	return nil
+}
+
+// CleanupExpired removes expired batches and temporary files left behind by
+// interrupted requests. Returns the number of batches removed.
+func (s *UploadBatchService) CleanupExpired() (int, error) {
+	entries, err := os.ReadDir(s.batchDir)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+
+		switch {
+		case strings.HasSuffix(name, ".json"):
+			batchID := strings.TrimSuffix(name, ".json")
+			batch, err := s.load(batchID)
+			if err != nil || batch.IsExpired() {
+				s.Remove(batchID)
+				removed++
+			}
+		case strings.HasSuffix(name, ".part"):
+			// Part files only live for the length of a request
+			if info, err := entry.Info(); err == nil && time.Since(info.ModTime()) > s.ttl {
+				os.Remove(filepath.Join(s.batchDir, name))
+			}
+		}
+	}
+
+	if removed > 0 {
+		log.Printf("Removed %d expired upload batches", removed)
+	}
+	return removed, nil
+}
+
+// Remove deletes a batch's state without an ownership check
+func (s *UploadBatchService) Remove(batchID string) {
+	os.Remove(s.statePath(batchID))
+
+	s.mu.Lock()
+	delete(s.locks, batchID)
+	s.mu.Unlock()
+}
+
+func (s *UploadBatchService) lockFor(batchID string) *sync.Mutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lock, ok := s.locks[batchID]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.locks[batchID] = lock
+	}
+	return lock
+}
+
+func (s *UploadBatchService) load(batchID string) (*models.UploadBatch, error) {
+	if !isValidSessionID(batchID) {
+		return nil, models.ErrUploadBatchNotFound
+	}
+
+	data, err := os.ReadFile(s.statePath(batchID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, models.ErrUploadBatchNotFound
+		}
+		return nil, err
+	}
+
+	var batch models.UploadBatch
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return nil, fmt.Errorf("corrupt upload batch %s: %w", batchID, err)
+	}
+	return &batch, nil
+}
+
+// save writes the batch state atomically via a temp file and rename
+func (s *UploadBatchService) save(batch *models.UploadBatch) error {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := s.statePath(batch.ID) + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.statePath(batch.ID))
+}
+
+func (s *UploadBatchService) statePath(batchID string) string {
+	return filepath.Join(s.batchDir, batchID+".json")
+}
+
+// multipartBatchSource reads the file parts of a multipart/form-data body
+type multipartBatchSource struct {
+	reader *multipart.Reader
+	part   *multipart.Part
+}
+
+// NewMultipartBatchSource returns a BatchFileSource over every file part of a
+// multipart body; plain form fields are skipped
+func NewMultipartBatchSource(reader *multipart.Reader) BatchFileSource {
+	return &multipartBatchSource{reader: reader}
+}
+
+func (m *multipartBatchSource) Next() (*BatchFile, error) {
+	for {
+		if m.part != nil {
+			m.part.Close()
+		}
+		part, err := m.reader.NextPart()
+		if err != nil {
+			return nil, err
+		}
+		m.part = part
+		if part.FileName() != "" {
+			return &BatchFile{
+				Filename:     part.FileName(),
+				ExpectedHash: part.Header.Get(BatchHashHeader),
+				Reader:       part,
+			}, nil
+		}
+	}
+}
+
+// tarBatchSource reads the regular files of a tar stream
+type tarBatchSource struct {
+	reader *tar.Reader
+}
+
+// NewTarBatchSource returns a BatchFileSource over the regular files of a tar stream
+func NewTarBatchSource(r io.Reader) BatchFileSource {
+	return &tarBatchSource{reader: tar.NewReader(r)}
+}
+
+func (t *tarBatchSource) Next() (*BatchFile, error) {
+	for {
+		header, err := t.reader.Next()
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag == tar.TypeReg {
+			return &BatchFile{
+				Filename:     filepath.Base(header.Name),
+				ExpectedHash: header.PAXRecords[BatchHashPAXRecord],
+				Reader:       t.reader,
+			}, nil
+		}
+	}
+}
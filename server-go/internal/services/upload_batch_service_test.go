@@ -0,0 +1,107 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tarOf(t *testing.T, files map[string][]byte, order ...string) *bytes.Buffer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range order {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name]))}))
+		_, err := tw.Write(files[name])
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return &buf
+}
+
+func TestUploadBatchService_ManifestAndUpload(t *testing.T) {
+	ctx := context.Background()
+	db, tempDir := setupTestDB(t)
+	photoRepo := repository.NewPhotoRepository(db)
+	storage := newTestStorage(t, tempDir)
+	hashService := NewHashService()
+	uploadService := newTestUploadService(t, photoRepo, storage, tempDir)
+	svc, err := NewUploadBatchService(tempDir, 1, 2, photoRepo, hashService, storage, uploadService)
+	require.NoError(t, err)
+
+	files := map[string][]byte{
+		"a.jpg":     []byte("first photo"),
+		"b.jpg":     []byte("second photo"),
+		"c.jpg":     []byte("third photo"),
+		"have.jpg":  []byte("already backed up"),
+		"stray.jpg": []byte("not in the manifest"),
+	}
+	hash := func(name string) string { return hashService.ComputeHashBytes(files[name]) }
+	manifestItem := func(name string) models.UploadManifestItem {
+		return models.UploadManifestItem{Hash: hash(name), Size: int64(len(files[name])), Filename: name}
+	}
+
+	_, err = uploadService.Process(ctx, bytes.NewReader(files["have.jpg"]), int64(len(files["have.jpg"])), UploadOptions{
+		OriginalFilename: "have.jpg",
+		UserID:           "user-1",
+	})
+	require.NoError(t, err)
+
+	batch, err := svc.Create(ctx, "user-1", &models.CreateUploadBatchRequest{
+		Items: []models.UploadManifestItem{
+			manifestItem("a.jpg"), manifestItem("b.jpg"), manifestItem("c.jpg"), manifestItem("have.jpg"),
+			manifestItem("a.jpg"), // listed twice
+			{Hash: hashService.ComputeHashBytes([]byte("notes")), Size: 5, Filename: "notes.txt"},
+			{Hash: "not-a-hash", Size: 10, Filename: "x.jpg"},
+		},
+	})
+	require.NoError(t, err)
+
+	resp := batch.ToResponse()
+	assert.ElementsMatch(t, []string{hash("a.jpg"), hash("b.jpg"), hash("c.jpg")}, resp.Needed)
+	assert.Equal(t, []string{hash("have.jpg")}, resp.Existing)
+	require.Len(t, resp.Rejected, 2, "duplicate manifest entries are dropped, invalid ones rejected")
+	assert.Equal(t, models.ErrInvalidExtension.Error(), resp.Rejected[0].Error)
+	assert.Equal(t, "not-a-hash", resp.Rejected[1].Hash)
+
+	// Names in the stream do not matter; content is matched by hash
+	stream := map[string][]byte{
+		"renamed.bin": files["a.jpg"],
+		"b.jpg":       files["b.jpg"],
+		"stray.jpg":   files["stray.jpg"],
+	}
+	_, err = svc.Process(ctx, batch.ID, "user-2", NewTarBatchSource(tarOf(t, stream, "renamed.bin")))
+	assert.Equal(t, models.ErrUploadBatchNotFound, err, "batches belong to their creator")
+
+	body := tarOf(t, stream, "renamed.bin", "b.jpg", "stray.jpg")
+	result, err := svc.Process(ctx, batch.ID, "user-1", NewTarBatchSource(body))
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Uploaded)
+	assert.Equal(t, 1, result.Remaining)
+	require.Len(t, result.Results, 3)
+	assert.Equal(t, models.BatchItemUploaded, result.Results[0].Status)
+	assert.Equal(t, "a.jpg", result.Results[0].OriginalFilename, "the manifest name is kept")
+	assert.Equal(t, models.BatchItemUnexpected, result.Results[2].Status)
+
+	photo, err := photoRepo.GetByHashAndUser(ctx, hash("a.jpg"), "user-1")
+	require.NoError(t, err)
+	require.NotNil(t, photo)
+	assert.Equal(t, "a.jpg", photo.OriginalFilename)
+
+	// A follow-up request sends the rest
+	result, err = svc.Process(ctx, batch.ID, "user-1", NewTarBatchSource(tarOf(t, files, "c.jpg")))
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Uploaded)
+	assert.Equal(t, 0, result.Remaining)
+
+	batch, err = svc.Get(batch.ID, "user-1")
+	require.NoError(t, err)
+	resp = batch.ToResponse()
+	assert.Empty(t, resp.Needed)
+	assert.Equal(t, 3, resp.Completed)
+}
@@ -0,0 +1,373 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+)
+
+// UploadOptions carries the client-supplied metadata for an upload
+type UploadOptions struct {
+	OriginalFilename string
+	DateTaken        *time.Time // Explicit date from the client; EXIF date is used when nil
+	DeviceID         string
+	UserID           string
+	ExpectedHash     string // SHA-256 the client says it sent; the upload is rejected if the content differs
+	FileHash         string // SHA-256 already computed over the source as it arrived; the source is hashed when empty
+	Description      string
+	Latitude         *float64 // Location from the client, used when the file has no EXIF GPS
+	Longitude        *float64
+	Altitude         *float64
+}
+
+// UploadService runs the shared ingest pipeline (dedup, EXIF, storage,
+// thumbnails and database record) for every upload path
+type UploadService struct {
+	photoRepo        repository.PhotoRepo
+	storageService   *PhotoStorageService
+	hashService      *HashService
+	exifService      *EXIFService
+	thumbnailService *ThumbnailService
+	metadataService  *MetadataService
+	videoService     *VideoService        // Optional, nil when ffmpeg is unavailable
+	quotaService     *QuotaService        // Optional, uploads are unlimited when nil
+	libraryEvents    *LibraryEventService // Optional
+}
+
+// NewUploadService creates a new UploadService
+func NewUploadService(
+	photoRepo repository.PhotoRepo,
+	storageService *PhotoStorageService,
+	hashService *HashService,
+	exifService *EXIFService,
+	thumbnailService *ThumbnailService,
+	metadataService *MetadataService,
+	videoService *VideoService,
+) *UploadService {
+	return &UploadService{
+		photoRepo:        photoRepo,
+		storageService:   storageService,
+		hashService:      hashService,
+		exifService:      exifService,
+		thumbnailService: thumbnailService,
+		metadataService:  metadataService,
+		videoService:     videoService,
+	}
+}
+
+// SetQuotaService enables per-user quota enforcement
+func (s *UploadService) SetQuotaService(quotaService *QuotaService) {
+	s.quotaService = quotaService
+}
+
+// SetLibraryEvents enables real-time events for new and restored photos
+func (s *UploadService) SetLibraryEvents(libraryEvents *LibraryEventService) {
+	s.libraryEvents = libraryEvents
+}
+
+// Process ingests a file. The source is read several times (hash, EXIF,
+// store, thumbnails) so it must be seekable; a file on disk keeps memory use flat.
+// Duplicates are reported through the result rather than as an error.
+func (s *UploadService) Process(ctx context.Context, src io.ReadSeeker, size int64, opts UploadOptions) (*models.UploadResult, error) {
+	// Compute hash
+	fileHash := opts.FileHash
+	if fileHash == "" {
+		if _, err := src.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		computed, err := s.hashService.ComputeHash(src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash file: %w", err)
+		}
+		fileHash = computed
+	}
+	if err := s.hashService.Verify(opts.ExpectedHash, fileHash); err != nil {
+		if err == models.ErrHashMismatch {
+			log.Printf("Rejected upload of %s: expected hash %s, received %s (%d bytes)", opts.OriginalFilename, opts.ExpectedHash, fileHash, size)
+		}
+		return nil, err
+	}
+
+	// Check for a duplicate in the uploader's own library. Other users' copies
+	// of the same content never count: each owner gets their own photo.
+	existing, err := s.photoRepo.GetByHashAndUser(ctx, fileHash, opts.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check hash: %w", err)
+	}
+	if existing != nil {
+		// Uploading a photo that is in the trash brings it back
+		if existing.IsTrashed() {
+			if _, err := s.photoRepo.Restore(ctx, existing.ID); err != nil {
+				return nil, fmt.Errorf("failed to restore trashed photo: %w", err)
+			}
+			log.Printf("Restored trashed photo on re-upload: %s", existing.ID)
+			if s.libraryEvents != nil {
+				existing.DeletedAt = nil
+				s.libraryEvents.Publish(opts.UserID, models.NewPhotoEvent(models.LibraryEventPhotoRestored, existing))
+			}
+		}
+
+		log.Printf("Duplicate photo detected: %s", fileHash)
+		result := models.DuplicateUploadResult(existing.ID, existing.StoredPath, existing.FileHash, existing.UploadedAt)
+		return &result, nil
+	}
+
+	// Duplicates cost nothing, so quotas are only checked for new content. This
+	// turns away uploads that cannot fit before they are stored; the database
+	// enforces the quota again when the photo is added.
+	if s.quotaService != nil {
+		if err := s.quotaService.CheckUpload(ctx, opts.UserID, size); err != nil {
+			return nil, err
+		}
+	}
+
+	// Extract EXIF metadata (videos carry none)
+	isVideo := models.IsVideoFile(opts.OriginalFilename)
+	exifData := &EXIFData{Orientation: 1}
+	if !isVideo {
+		if _, err := src.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if extracted, err := s.exifService.ExtractFromReader(src); err != nil {
+			log.Printf("Warning: failed to extract EXIF data: %v", err)
+		} else {
+			exifData = extracted
+		}
+	}
+
+	// Use EXIF date if available and no date was provided
+	dateTaken := time.Now().UTC()
+	if opts.DateTaken != nil {
+		dateTaken = *opts.DateTaken
+	} else if exifData.DateTaken != nil {
+		dateTaken = *exifData.DateTaken
+	}
+
+	// Store the file, or share the stored original if someone else already
+	// uploaded it. The blob stays locked until the photo row references it so a
+	// purge cannot delete a file this upload is about to share.
+	unlock := s.storageService.LockBlob(fileHash)
+	defer unlock()
+	storedPath, sharedBlob, err := s.storeOrShare(ctx, src, fileHash, opts.OriginalFilename, dateTaken, size)
+	if err != nil {
+		return nil, err
+	}
+	cleanup := func() {
+		if !sharedBlob {
+			s.storageService.Delete(storedPath)
+		}
+	}
+
+	// Create database record
+	photo, err := models.NewPhoto(opts.OriginalFilename, storedPath, fileHash, size, dateTaken)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	// Copy EXIF metadata to photo
+	photo.CameraMake = exifData.CameraMake
+	photo.CameraModel = exifData.CameraModel
+	photo.LensModel = exifData.LensModel
+	photo.FocalLength = exifData.FocalLength
+	photo.Aperture = exifData.Aperture
+	photo.ShutterSpeed = exifData.ShutterSpeed
+	photo.ISO = exifData.ISO
+	photo.Orientation = exifData.Orientation
+	photo.Latitude = exifData.Latitude
+	photo.Longitude = exifData.Longitude
+	photo.Altitude = exifData.Altitude
+	if photo.Latitude == nil && opts.Latitude != nil && opts.Longitude != nil {
+		photo.Latitude = opts.Latitude
+		photo.Longitude = opts.Longitude
+		photo.Altitude = opts.Altitude
+	}
+	if opts.Description != "" {
+		description := opts.Description
+		photo.Description = &description
+	}
+
+	if opts.UserID != "" {
+		userID := opts.UserID
+		photo.UserID = &userID
+	}
+
+	// Set origin device if provided
+	if opts.DeviceID != "" {
+		deviceID := opts.DeviceID
+		photo.OriginDeviceID = &deviceID
+	}
+
+	// Generate thumbnails (if supported format)
+	if isVideo {
+		s.processVideo(photo)
+	} else if IsSupportedFormat(opts.OriginalFilename) {
+		s.generateThumbnails(src, photo, storedPath, exifData.Orientation)
+	}
+
+	if err := s.photoRepo.Add(ctx, photo); err != nil {
+		cleanup()
+		if photo.ThumbSmall != nil {
+			s.thumbnailService.DeleteThumbnails(*photo.ThumbSmall, *photo.ThumbMedium, *photo.ThumbLarge)
+		}
+
+		// Check if this is a unique constraint violation (race condition with concurrent upload)
+		errStr := err.Error()
+		if strings.Contains(errStr, "duplicate key") || strings.Contains(errStr, "UNIQUE constraint") {
+			log.Printf("Duplicate detected via constraint for hash: %s", fileHash)
+			existing, lookupErr := s.photoRepo.GetByHashAndUser(ctx, fileHash, opts.UserID)
+			if lookupErr == nil && existing != nil {
+				result := models.DuplicateUploadResult(existing.ID, existing.StoredPath, existing.FileHash, existing.UploadedAt)
+				return &result, nil
+			}
+		}
+		if models.IsQuotaError(err) {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("failed to save photo record: %w", err)
+	}
+
+	// A shared original serves several users' photos, so it carries none of
+	// their IDs. Its first owner's are removed while the blob is still locked.
+	if sharedBlob {
+		s.removeOwnerMetadata(photo)
+	} else {
+		s.embedMetadata(photo)
+	}
+
+	log.Printf("Photo uploaded: %s -> %s (type: %s, GPS: %v, shared: %v)", photo.ID, storedPath, photo.MediaType, photo.Latitude != nil, sharedBlob)
+
+	if s.quotaService != nil {
+		s.quotaService.NotifyAdded(ctx, opts.UserID, size)
+	}
+	if s.libraryEvents != nil {
+		s.libraryEvents.Publish(opts.UserID, models.NewPhotoEvent(models.LibraryEventPhotoAdded, photo))
+	}
+
+	result := models.NewUploadResult(photo.ID, storedPath, fileHash, photo.UploadedAt)
+	return &result, nil
+}
+
+// storeOrShare returns the stored path for the upload's content. When another
+// user already stored identical content its file is reused and shared is true;
+// otherwise the source is written to storage.
+func (s *UploadService) storeOrShare(ctx context.Context, src io.ReadSeeker, fileHash, filename string, dateTaken time.Time, size int64) (storedPath string, shared bool, err error) {
+	blob, err := s.photoRepo.GetBlobByHash(ctx, fileHash)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to look up stored file: %w", err)
+	}
+	if blob != nil && s.storageService.Exists(blob.StoredPath) {
+		return blob.StoredPath, true, nil
+	}
+
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return "", false, err
+	}
+	storedPath, err = s.storageService.Store(src, filename, dateTaken, size)
+	if err != nil {
+		return "", false, err
+	}
+	return storedPath, false, nil
+}
+
+// generateThumbnails reads the source and attaches thumbnail paths to the photo
+func (s *UploadService) generateThumbnails(src io.ReadSeeker, photo *models.Photo, storedPath string, orientation int) {
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		log.Printf("Warning: failed to rewind for thumbnails: %v", err)
+		return
+	}
+	thumbResult, err := s.thumbnailService.GenerateThumbnailsFromReader(src, photo.ID, storedPath, orientation)
+	if err != nil {
+		log.Printf("Warning: failed to generate thumbnails: %v", err)
+		return
+	}
+
+	photo.ThumbSmall = &thumbResult.SmallPath
+	photo.ThumbMedium = &thumbResult.MediumPath
+	photo.ThumbLarge = &thumbResult.LargePath
+	photo.Width = &thumbResult.Width
+	photo.Height = &thumbResult.Height
+	photo.PerceptualHash = &thumbResult.PerceptualHash
+}
+
+// processVideo probes the duration and generates poster thumbnails for a stored video
+func (s *UploadService) processVideo(photo *models.Photo) {
+	if s.videoService == nil {
+		return
+	}
+
+	if duration, err := s.videoService.ProbeDuration(photo.StoredPath); err != nil {
+		log.Printf("Warning: failed to probe video duration: %v", err)
+	} else {
+		photo.Duration = &duration
+	}
+
+	thumbResult, err := s.thumbnailService.GenerateVideoThumbnails(photo.ID, photo.StoredPath)
+	if err != nil {
+		log.Printf("Warning: failed to generate video thumbnails: %v", err)
+		return
+	}
+
+	photo.ThumbSmall = &thumbResult.SmallPath
+	photo.ThumbMedium = &thumbResult.MediumPath
+	photo.ThumbLarge = &thumbResult.LargePath
+	photo.Width = &thumbResult.Width
+	photo.Height = &thumbResult.Height
+}
+
+// embedMetadata writes the PhotoSync metadata into the stored file (non-blocking)
+func (s *UploadService) embedMetadata(photo *models.Photo) {
+	if s.metadataService == nil {
+		return
+	}
+
+	// Capture values for goroutine
+	metadata := PhotoMetadata{
+		PhotoID:    photo.ID,
+		FileHash:   photo.FileHash,
+		UploadedAt: photo.UploadedAt,
+	}
+	if photo.UserID != nil {
+		metadata.UserID = *photo.UserID
+	}
+	if photo.OriginDeviceID != nil {
+		metadata.DeviceID = *photo.OriginDeviceID
+	}
+	storedPath := photo.StoredPath
+
+	go func() {
+		// Another user's upload may have shared the file in the meantime
+		unlock := s.storageService.LockBlob(metadata.FileHash)
+		defer unlock()
+		refs, err := s.photoRepo.GetBlobRefCount(context.Background(), storedPath)
+		if err != nil {
+			log.Printf("Warning: not embedding metadata for %s: %v", metadata.PhotoID, err)
+			return
+		}
+		if refs > 1 {
+			return
+		}
+
+		if err := s.metadataService.EmbedFullMetadata(storedPath, metadata); err != nil {
+			log.Printf("Warning: failed to embed metadata for %s: %v", metadata.PhotoID, err)
+		}
+	}()
+}
+
+// removeOwnerMetadata strips the IDs of the photo's first owner from a stored
+// file that has just become shared. The caller holds the blob's lock.
+func (s *UploadService) removeOwnerMetadata(photo *models.Photo) {
+	if s.metadataService == nil || !s.metadataService.HasEmbeddedMetadata(photo.StoredPath) {
+		return
+	}
+	if err := s.metadataService.RemoveOwnerMetadata(photo.StoredPath); err != nil {
+		log.Printf("Warning: shared file %s still names its first owner: %v", photo.StoredPath, err)
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/photosync/server/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadService_SameContentForTwoUsers(t *testing.T) {
+	ctx := context.Background()
+	db, tempDir := setupTestDB(t)
+	photoRepo := repository.NewPhotoRepository(db)
+	trashService, storage := newTestTrashService(t, photoRepo, tempDir)
+	uploadService := newTestUploadService(t, photoRepo, storage, tempDir)
+
+	content := []byte("the same holiday picture")
+	upload := func(userID string) (string, bool) {
+		result, err := uploadService.Process(ctx, bytes.NewReader(content), int64(len(content)), UploadOptions{
+			OriginalFilename: "beach.jpg",
+			UserID:           userID,
+		})
+		require.NoError(t, err)
+		return result.ID, result.IsDuplicate
+	}
+
+	firstID, duplicate := upload("user-1")
+	assert.False(t, duplicate)
+	secondID, duplicate := upload("user-2")
+	assert.False(t, duplicate, "another user's copy must not count as a duplicate")
+	assert.NotEqual(t, firstID, secondID)
+
+	againID, duplicate := upload("user-2")
+	assert.True(t, duplicate)
+	assert.Equal(t, secondID, againID)
+
+	first, err := photoRepo.GetByID(ctx, firstID)
+	require.NoError(t, err)
+	second, err := photoRepo.GetByID(ctx, secondID)
+	require.NoError(t, err)
+	assert.Equal(t, "user-2", *second.UserID)
+	assert.Equal(t, first.StoredPath, second.StoredPath, "owners share one stored file")
+
+	refs, err := photoRepo.GetBlobRefCount(ctx, first.StoredPath)
+	require.NoError(t, err)
+	assert.Equal(t, 2, refs)
+
+	// Purging one owner's photo keeps the file for the other
+	require.NoError(t, trashService.MoveToTrash(ctx, firstID))
+	_, err = trashService.Empty(ctx, "user-1")
+	require.NoError(t, err)
+	assert.True(t, storage.Exists(first.StoredPath))
+
+	require.NoError(t, trashService.MoveToTrash(ctx, secondID))
+	_, err = trashService.Empty(ctx, "user-2")
+	require.NoError(t, err)
+	assert.False(t, storage.Exists(first.StoredPath), "file goes with its last reference")
+
+	refs, err = photoRepo.GetBlobRefCount(ctx, first.StoredPath)
+	require.NoError(t, err)
+	assert.Equal(t, 0, refs)
+}
+
+func TestUploadService_RejectsHashMismatch(t *testing.T) {
+	ctx := context.Background()
+	db, tempDir := setupTestDB(t)
+	photoRepo := repository.NewPhotoRepository(db)
+	storage := newTestStorage(t, tempDir)
+	hashService := NewHashService()
+	uploadService := newTestUploadService(t, photoRepo, storage, tempDir)
+
+	content := []byte("the whole picture")
+	truncated := content[:8]
+
+	_, err := uploadService.Process(ctx, bytes.NewReader(truncated), int64(len(truncated)), UploadOptions{
+		OriginalFilename: "cut.jpg",
+		UserID:           "user-1",
+		ExpectedHash:     hashService.ComputeHashBytes(content),
+	})
+	assert.Equal(t, models.ErrHashMismatch, err)
+
+	count, err := photoRepo.GetCountForUser(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, 0, count, "nothing is stored for a corrupted upload")
+
+	result, err := uploadService.Process(ctx, bytes.NewReader(content), int64(len(content)), UploadOptions{
+		OriginalFilename: "whole.jpg",
+		UserID:           "user-1",
+		ExpectedHash:     strings.ToUpper(hashService.ComputeHashBytes(content)),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, hashService.ComputeHashBytes(content), result.FileHash, "the verified hash is echoed back")
+}
@@ -0,0 +1,390 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+)
+
+// UploadSessionService stores resumable upload sessions under <basePath>/.uploads.
+// Each session is a JSON state file plus a .part file that chunks are written into.
+type UploadSessionService struct {
+	uploadDir   string
+	ttl         time.Duration
+	hashService *HashService
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// NewUploadSessionService creates a new UploadSessionService
+func NewUploadSessionService(basePath string, ttlHours int) (*UploadSessionService, error) {
+	uploadDir := filepath.Join(basePath, ".uploads")
+	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+
+	if ttlHours <= 0 {
+		ttlHours = 24
+	}
+
+	return &UploadSessionService{
+		uploadDir:   uploadDir,
+		ttl:         time.Duration(ttlHours) * time.Hour,
+		hashService: NewHashService(),
+		locks:       make(map[string]*sync.Mutex),
+	}, nil
+}
+
+// Create starts a new upload session and allocates its part file
+func (s *UploadSessionService) Create(userID string, req *models.CreateUploadSessionRequest) (*models.UploadSession, error) {
+	session, err := models.NewUploadSession(userID, req.OriginalFilename, req.TotalSize, s.ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	// A malformed hash is refused now rather than after the whole file has arrived
+	if err := s.hashService.ValidateExpected(req.ContentSHA256); err != nil {
+		return nil, err
+	}
+	if req.ContentSHA256 != "" {
+		session.ContentSHA256 = s.hashService.NormalizeHash(req.ContentSHA256)
+	}
+
+	if req.DateTaken != "" {
+		if parsed, err := time.Parse(time.RFC3339, req.DateTaken); err == nil {
+			session.DateTaken = &parsed
+		}
+	}
+	session.DeviceID = req.DeviceID
+
+	lock := s.lockFor(session.ID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	file, err := os.OpenFile(s.partPath(session.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+
+	if err := s.save(session); err != nil {
+		os.Remove(s.partPath(session.ID))
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// Get returns a session owned by the user
+func (s *UploadSessionService) Get(sessionID, userID string) (*models.UploadSession, error) {
+	session, err := s.load(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID != userID {
+		return nil, models.ErrUploadSessionNotFound
+	}
+	if session.IsExpired() {
+		return nil, models.ErrUploadSessionExpired
+	}
+	return session, nil
+}
+
+// WriteChunk appends data at the given offset. The offset must equal the
+// number of bytes already received so that a retried chunk cannot corrupt the file.
+func (s *UploadSessionService) WriteChunk(sessionID, userID string, offset int64, r io.Reader) (*models.UploadSession, error) {
+	lock := s.lockFor(sessionID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	session, err := s.Get(sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset != session.Offset {
+		return session, models.ErrUploadOffsetMismatch
+	}
+
+	file, err := os.OpenFile(s.partPath(sessionID), os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	// Read one byte past the remaining size to detect oversized chunks
+	remaining := session.TotalSize - session.Offset
+	hasher, err := resumeHash(session)
+	if err != nil {
+		return nil, err
+	}
+	written, copyErr := io.Copy(hashingWriter{file, hasher}, io.LimitReader(r, remaining))
+	if copyErr == nil && written == remaining {
+		// Probe for one byte past the declared size to detect oversized chunks
+		if n, _ := io.ReadFull(r, make([]byte, 1)); n > 0 {
+			copyErr = models.ErrUploadChunkTooLarge
+		}
+	}
+
+	// Keep whatever arrived before a dropped connection so the client can resume
+	if written > 0 {
+		session.Offset += written
+		if hasher != nil {
+			if session.HashState, err = hasher.(encoding.BinaryMarshaler).MarshalBinary(); err != nil {
+				return nil, err
+			}
+		}
+		session.UpdatedAt = time.Now().UTC()
+		session.ExpiresAt = session.UpdatedAt.Add(s.ttl)
+		if err := s.save(session); err != nil {
+			return nil, err
+		}
+	}
+
+	if copyErr != nil {
+		return session, copyErr
+	}
+
+	return session, nil
+}
+
+// Complete hands the assembled file of a finished session to finalize while
+// holding the session's lock, so no chunk, second completion or cleanup can
+// touch the file meanwhile. fileHash is the SHA-256 computed as the chunks
+// arrived, or empty for sessions started before hashes were kept. The session is removed once finalize succeeds, and
+// also when the data fails its hash check, since resuming cannot repair it.
+func (s *UploadSessionService) Complete(sessionID, userID string, finalize func(session *models.UploadSession, file *os.File, fileHash string) error) error {
+	lock := s.lockFor(sessionID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	session, err := s.Get(sessionID, userID)
+	if err != nil {
+		return err
+	}
+	if !session.IsComplete() {
+		return models.ErrUploadIncomplete
+	}
+
+	file, err := os.Open(s.partPath(sessionID))
+	if err != nil {
+		return err
+	}
+	fileHash := ""
+	if hasher, err := resumeHash(session); err == nil && hasher != nil {
+		fileHash = hex.EncodeToString(hasher.Sum(nil))
+	}
+	err = finalize(session, file, fileHash)
+	file.Close()
+
+	if err == nil || err == models.ErrHashMismatch {
+		s.remove(sessionID)
+	}
+	return err
+}
+
+// Delete removes a session owned by the user along with any received data
+func (s *UploadSessionService) Delete(sessionID, userID string) error {
+	lock := s.lockFor(sessionID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	session, err := s.load(sessionID)
+	if err != nil {
+		return err
+	}
+	if session.UserID != userID {
+		return models.ErrUploadSessionNotFound
+	}
+
+	s.remove(sessionID)
+	return nil
+}
+
+// CleanupExpired removes expired sessions and any part files left without state.
+// Returns the number of sessions removed.
+func (s *UploadSessionService) CleanupExpired() (int, error) {
+	entries, err := os.ReadDir(s.uploadDir)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+
+		switch {
+		case strings.HasSuffix(name, ".json"):
+			if s.removeIfExpired(strings.TrimSuffix(name, ".json")) {
+				removed++
+			}
+		case strings.HasSuffix(name, ".part"):
+			s.removeIfOrphaned(strings.TrimSuffix(name, ".part"))
+		}
+	}
+
+	if removed > 0 {
+		log.Printf("Removed %d expired upload sessions", removed)
+	}
+	return removed, nil
+}
+
+// removeIfExpired removes a session that has expired or cannot be read.
+// It waits for any chunk or completion in progress on the session.
+func (s *UploadSessionService) removeIfExpired(sessionID string) bool {
+	lock := s.lockFor(sessionID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	session, err := s.load(sessionID)
+	if err == models.ErrUploadSessionNotFound {
+		return false // Completed or deleted since the directory was listed
+	}
+	if err != nil || session.IsExpired() {
+		s.remove(sessionID)
+		return true
+	}
+	return false
+}
+
+// removeIfOrphaned removes a part file whose session state is gone. Create
+// holds the lock between allocating the part file and saving the state.
+func (s *UploadSessionService) removeIfOrphaned(sessionID string) {
+	lock := s.lockFor(sessionID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, err := os.Stat(s.statePath(sessionID)); os.IsNotExist(err) {
+		s.remove(sessionID)
+	}
+}
+
+// remove deletes a session's state and data without an ownership check. The
+// caller holds the session's lock; anyone still waiting on it finds the
+// session gone once they get it.
+func (s *UploadSessionService) remove(sessionID string) {
+	os.Remove(s.statePath(sessionID))
+	os.Remove(s.partPath(sessionID))
+
+	s.mu.Lock()
+	delete(s.locks, sessionID)
+	s.mu.Unlock()
+}
+
+// resumeHash restores the running SHA-256 of the bytes a session has received.
+// It returns nil for sessions started before hashes were kept, which are
+// hashed in full on completion instead.
+func resumeHash(session *models.UploadSession) (hash.Hash, error) {
+	hasher := sha256.New()
+	if session.HashState == nil {
+		if session.Offset > 0 {
+			return nil, nil
+		}
+		return hasher, nil
+	}
+	if err := hasher.(encoding.BinaryUnmarshaler).UnmarshalBinary(session.HashState); err != nil {
+		return nil, fmt.Errorf("corrupt hash state in upload session %s: %w", session.ID, err)
+	}
+	return hasher, nil
+}
+
+// hashingWriter feeds the hash exactly the bytes the underlying writer accepted
+type hashingWriter struct {
+	w    io.Writer
+	hash hash.Hash
+}
+
+func (hw hashingWriter) Write(p []byte) (int, error) {
+	n, err := hw.w.Write(p)
+	if hw.hash != nil {
+		hw.hash.Write(p[:n])
+	}
+	return n, err
+}
+
+func (s *UploadSessionService) lockFor(sessionID string) *sync.Mutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lock, ok := s.locks[sessionID]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.locks[sessionID] = lock
+	}
+	return lock
+}
+
+func (s *UploadSessionService) load(sessionID string) (*models.UploadSession, error) {
+	if !isValidSessionID(sessionID) {
+		return nil, models.ErrUploadSessionNotFound
+	}
+
+	data, err := os.ReadFile(s.statePath(sessionID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, models.ErrUploadSessionNotFound
+		}
+		return nil, err
+	}
+
+	var session models.UploadSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("corrupt upload session %s: %w", sessionID, err)
+	}
+	return &session, nil
+}
+
+// save writes the session state atomically via a temp file and rename
+func (s *UploadSessionService) save(session *models.UploadSession) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := s.statePath(session.ID) + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.statePath(session.ID))
+}
+
+func (s *UploadSessionService) statePath(sessionID string) string {
+	return filepath.Join(s.uploadDir, sessionID+".json")
+}
+
+func (s *UploadSessionService) partPath(sessionID string) string {
+	return filepath.Join(s.uploadDir, sessionID+".part")
+}
+
+// isValidSessionID guards against path traversal through the session ID
+func isValidSessionID(sessionID string) bool {
+	if sessionID == "" || len(sessionID) > 64 {
+		return false
+	}
+	for _, c := range sessionID {
+		if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
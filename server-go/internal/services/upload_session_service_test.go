@@ -0,0 +1,199 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/photosync/server/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestUploadSessions(t *testing.T) (*UploadSessionService, string) {
+	tempDir, err := os.MkdirTemp("", "photosync-uploads-test-*")
+	require.NoError(t, err)
+
+	svc, err := NewUploadSessionService(tempDir, 1)
+	require.NoError(t, err)
+
+	return svc, tempDir
+}
+
+func TestUploadSessionService_WriteChunk(t *testing.T) {
+	t.Run("assembles chunks in order", func(t *testing.T) {
+		svc, tempDir := setupTestUploadSessions(t)
+		defer os.RemoveAll(tempDir)
+
+		content := []byte("hello resumable world")
+		session, err := svc.Create("user-1", &models.CreateUploadSessionRequest{
+			OriginalFilename: "clip.jpg",
+			TotalSize:        int64(len(content)),
+		})
+		require.NoError(t, err)
+
+		session, err = svc.WriteChunk(session.ID, "user-1", 0, bytes.NewReader(content[:5]))
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), session.Offset)
+		assert.False(t, session.IsComplete())
+
+		session, err = svc.WriteChunk(session.ID, "user-1", 5, bytes.NewReader(content[5:]))
+		require.NoError(t, err)
+		assert.True(t, session.IsComplete())
+
+		var data []byte
+		var fileHash string
+		err = svc.Complete(session.ID, "user-1", func(_ *models.UploadSession, file *os.File, hash string) error {
+			fileHash = hash
+			data, err = io.ReadAll(file)
+			return err
+		})
+		require.NoError(t, err)
+		assert.Equal(t, content, data)
+		assert.Equal(t, NewHashService().ComputeHashBytes(content), fileHash, "hashed as the chunks arrived")
+	})
+
+	t.Run("rejects mismatched offset", func(t *testing.T) {
+		svc, tempDir := setupTestUploadSessions(t)
+		defer os.RemoveAll(tempDir)
+
+		session, err := svc.Create("user-1", &models.CreateUploadSessionRequest{
+			OriginalFilename: "photo.jpg",
+			TotalSize:        10,
+		})
+		require.NoError(t, err)
+
+		_, err = svc.WriteChunk(session.ID, "user-1", 4, bytes.NewReader([]byte("abcd")))
+		assert.Equal(t, models.ErrUploadOffsetMismatch, err)
+	})
+
+	t.Run("rejects data beyond declared size", func(t *testing.T) {
+		svc, tempDir := setupTestUploadSessions(t)
+		defer os.RemoveAll(tempDir)
+
+		session, err := svc.Create("user-1", &models.CreateUploadSessionRequest{
+			OriginalFilename: "photo.jpg",
+			TotalSize:        4,
+		})
+		require.NoError(t, err)
+
+		session, err = svc.WriteChunk(session.ID, "user-1", 0, bytes.NewReader([]byte("abcdef")))
+		assert.Equal(t, models.ErrUploadChunkTooLarge, err)
+		assert.Equal(t, int64(4), session.Offset)
+
+		var fileHash string
+		require.NoError(t, svc.Complete(session.ID, "user-1", func(_ *models.UploadSession, _ *os.File, hash string) error {
+			fileHash = hash
+			return nil
+		}))
+		assert.Equal(t, NewHashService().ComputeHashBytes([]byte("abcd")), fileHash)
+	})
+
+	t.Run("hides sessions from other users", func(t *testing.T) {
+		svc, tempDir := setupTestUploadSessions(t)
+		defer os.RemoveAll(tempDir)
+
+		session, err := svc.Create("user-1", &models.CreateUploadSessionRequest{
+			OriginalFilename: "photo.jpg",
+			TotalSize:        4,
+		})
+		require.NoError(t, err)
+
+		_, err = svc.WriteChunk(session.ID, "user-2", 0, bytes.NewReader([]byte("abcd")))
+		assert.Equal(t, models.ErrUploadSessionNotFound, err)
+	})
+}
+
+func TestUploadSessionService_Complete(t *testing.T) {
+	t.Run("refuses incomplete uploads", func(t *testing.T) {
+		svc, tempDir := setupTestUploadSessions(t)
+		defer os.RemoveAll(tempDir)
+
+		session, err := svc.Create("user-1", &models.CreateUploadSessionRequest{
+			OriginalFilename: "photo.jpg",
+			TotalSize:        10,
+		})
+		require.NoError(t, err)
+
+		err = svc.Complete(session.ID, "user-1", func(*models.UploadSession, *os.File, string) error {
+			t.Fatal("finalize ran for an incomplete upload")
+			return nil
+		})
+		assert.Equal(t, models.ErrUploadIncomplete, err)
+	})
+
+	t.Run("rejects path traversal in session ID", func(t *testing.T) {
+		svc, tempDir := setupTestUploadSessions(t)
+		defer os.RemoveAll(tempDir)
+
+		err := svc.Complete("../../etc/passwd", "user-1", func(*models.UploadSession, *os.File, string) error {
+			return nil
+		})
+		assert.Equal(t, models.ErrUploadSessionNotFound, err)
+	})
+
+	t.Run("finalizes a session only once", func(t *testing.T) {
+		svc, tempDir := setupTestUploadSessions(t)
+		defer os.RemoveAll(tempDir)
+
+		session, err := svc.Create("user-1", &models.CreateUploadSessionRequest{
+			OriginalFilename: "photo.jpg",
+			TotalSize:        4,
+		})
+		require.NoError(t, err)
+		_, err = svc.WriteChunk(session.ID, "user-1", 0, bytes.NewReader([]byte("abcd")))
+		require.NoError(t, err)
+
+		var finalized int32
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = svc.Complete(session.ID, "user-1", func(*models.UploadSession, *os.File, string) error {
+					atomic.AddInt32(&finalized, 1)
+					time.Sleep(50 * time.Millisecond)
+					return nil
+				})
+			}(i)
+		}
+		wg.Wait()
+
+		assert.EqualValues(t, 1, finalized)
+		assert.ElementsMatch(t, []error{nil, models.ErrUploadSessionNotFound}, errs)
+	})
+
+	t.Run("keeps the session when finalize fails", func(t *testing.T) {
+		svc, tempDir := setupTestUploadSessions(t)
+		defer os.RemoveAll(tempDir)
+
+		session, err := svc.Create("user-1", &models.CreateUploadSessionRequest{
+			OriginalFilename: "photo.jpg",
+			TotalSize:        4,
+		})
+		require.NoError(t, err)
+		_, err = svc.WriteChunk(session.ID, "user-1", 0, bytes.NewReader([]byte("abcd")))
+		require.NoError(t, err)
+
+		failed := errors.New("disk full")
+		err = svc.Complete(session.ID, "user-1", func(*models.UploadSession, *os.File, string) error {
+			return failed
+		})
+		assert.Equal(t, failed, err)
+		_, err = svc.Get(session.ID, "user-1")
+		assert.NoError(t, err, "the client can retry completion")
+
+		err = svc.Complete(session.ID, "user-1", func(*models.UploadSession, *os.File, string) error {
+			return models.ErrHashMismatch
+		})
+		assert.Equal(t, models.ErrHashMismatch, err)
+		_, err = svc.Get(session.ID, "user-1")
+		assert.Equal(t, models.ErrUploadSessionNotFound, err)
+	})
+}
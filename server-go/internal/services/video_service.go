@@ -0,0 +1,106 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/photosync/server/internal/blobstore"
+)
+
+// VideoService extracts poster frames and durations from video files.
+// It shells out to ffmpeg/ffprobe, which are optional: when they are not
+// installed videos are still stored and synced, just without thumbnails.
+type VideoService struct {
+	store blobstore.BlobStore
+}
+
+// NewVideoService creates a new VideoService reading videos from store
+func NewVideoService(store blobstore.BlobStore) *VideoService {
+	return &VideoService{
+		store: store,
+	}
+}
+
+// IsFFmpegAvailable checks if ffmpeg and ffprobe are installed
+func IsFFmpegAvailable() bool {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return false
+	}
+	_, err := exec.LookPath("ffprobe")
+	return err == nil
+}
+
+// ExtractPosterFrame returns a JPEG frame from near the start of the video
+func (s *VideoService) ExtractPosterFrame(storedPath string) ([]byte, error) {
+	var frame []byte
+	err := blobstore.WithLocalFile(context.Background(), s.store, storedPath, false, func(fullPath string) error {
+		var err error
+		frame, err = extractPosterFrame(fullPath, storedPath)
+		return err
+	})
+	return frame, err
+}
+
+func extractPosterFrame(fullPath, storedPath string) ([]byte, error) {
+	// Seek one second in to skip black lead-in frames; fall back to the first
+	// frame for clips shorter than that
+	for _, offset := range []string{"1", "0"} {
+		cmd := exec.Command("ffmpeg",
+			"-v", "error",
+			"-ss", offset,
+			"-i", fullPath,
+			"-frames:v", "1",
+			"-f", "image2pipe",
+			"-vcodec", "mjpeg",
+			"-",
+		)
+
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		output, err := cmd.Output()
+		if err == nil && len(output) > 0 {
+			return output, nil
+		}
+		if err != nil && offset == "0" {
+			return nil, fmt.Errorf("ffmpeg failed: %w (%s)", err, strings.TrimSpace(stderr.String()))
+		}
+	}
+
+	return nil, fmt.Errorf("no video frame found in %s", storedPath)
+}
+
+// ProbeDuration returns the duration of the video in seconds
+func (s *VideoService) ProbeDuration(storedPath string) (float64, error) {
+	var duration float64
+	err := blobstore.WithLocalFile(context.Background(), s.store, storedPath, false, func(fullPath string) error {
+		var err error
+		duration, err = probeDuration(fullPath)
+		return err
+	})
+	return duration, err
+}
+
+func probeDuration(fullPath string) (float64, error) {
+	cmd := exec.Command("ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		fullPath,
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	duration, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: %w", strings.TrimSpace(string(output)), err)
+	}
+
+	return duration, nil
+}
@@ -40,7 +40,8 @@ type WebSocketHub struct {
 
 type broadcastMsg struct {
 	topic   string
-	userID  string // if set, only send to this user
+	userID  string    // if set, only send to this user
+	client  *WSClient // if set, only send to this connection
 	message []byte
 }
 
@@ -97,7 +98,12 @@ func (h *WebSocketHub) Run() {
 			h.mu.RLock()
 			var targets map[*WSClient]bool
 
-			if msg.userID != "" {
+			if msg.client != nil {
+				// Send to one connection, unless it has already gone away
+				if h.clients[msg.client] {
+					targets = map[*WSClient]bool{msg.client: true}
+				}
+			} else if msg.userID != "" {
 				// Send to specific user
 				targets = h.userConns[msg.userID]
 			} else if msg.topic != "" {
@@ -182,6 +188,18 @@ func (h *WebSocketHub) SetUserID(client *WSClient, userID string) {
 	h.userConns[userID][client] = true
 }
 
+// UserClients returns the user's current connections
+func (h *WebSocketHub) UserClients(userID string) []*WSClient {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	clients := make([]*WSClient, 0, len(h.userConns[userID]))
+	for client := range h.userConns[userID] {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
 // BroadcastToTopic sends a message to all clients subscribed to a topic
 func (h *WebSocketHub) BroadcastToTopic(topic string, msg WSMessage) {
 	data, err := json.Marshal(msg)
@@ -210,6 +228,21 @@ func (h *WebSocketHub) SendToUser(userID string, msg WSMessage) {
 	}
 }
 
+// SendToClient sends a message to a single connection. Messages go through
+// the hub's queue, so they are delivered in order with other broadcasts.
+func (h *WebSocketHub) SendToClient(client *WSClient, msg WSMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Error marshaling WebSocket message: %v", err)
+		return
+	}
+
+	h.broadcast <- &broadcastMsg{
+		client:  client,
+		message: data,
+	}
+}
+
 // BroadcastAll sends a message to all connected clients
 func (h *WebSocketHub) BroadcastAll(msg WSMessage) {
 	data, err := json.Marshal(msg)
@@ -329,6 +362,11 @@ const (
 	WSTypeOrphanFound     = "orphan_found"
 	WSTypeConflictFound   = "conflict_found"
 	WSTypePhotoUploaded   = "photo_uploaded"
+	WSTypeQuotaWarning    = "quota_warning"
+	WSTypeLibraryEvent    = "library_event"
+	WSTypeLibraryResumed  = "library_resumed"
+	WSTypeImportProgress  = "import_progress"
+	WSTypeImportComplete  = "import_complete"
 	WSTypeError           = "error"
 	WSTypeSubscribe       = "subscribe"
 	WSTypeUnsubscribe     = "unsubscribe"